@@ -63,6 +63,21 @@ echo '
 cat deploy/yamls/local.openebs.io_devicenodes.yaml >> deploy/yamls/devicenode-crd.yaml
 rm deploy/yamls/local.openebs.io_devicenodes.yaml
 
+echo '
+
+##############################################
+###########                       ############
+###########   DeviceQuota CRD     ############
+###########                       ############
+##############################################
+
+# DeviceQuota CRD is autogenerated via `make manifests` command.
+# Do the modification in the code and run the `make manifests` command
+# to generate the CRD definition' > deploy/yamls/devicequota-crd.yaml
+
+cat deploy/yamls/local.openebs.io_devicequotas.yaml >> deploy/yamls/devicequota-crd.yaml
+rm deploy/yamls/local.openebs.io_devicequotas.yaml
+
 ## create the operator file using all the yamls
 
 echo '# This manifest is autogenerated via `make manifests` command
@@ -82,6 +97,9 @@ cat deploy/yamls/devicevolume-crd.yaml >> deploy/device-operator.yaml
 # Add DeviceNode v1alpha1 CRDs to the Operator yaml
 cat deploy/yamls/devicenode-crd.yaml >> deploy/device-operator.yaml
 
+# Add DeviceQuota v1alpha1 CRDs to the Operator yaml
+cat deploy/yamls/devicequota-crd.yaml >> deploy/device-operator.yaml
+
 # Add the driver deployment to the Operator yaml
 cat deploy/yamls/device-driver.yaml >> deploy/device-operator.yaml
 
@@ -17,14 +17,22 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	config "github.com/openebs/device-localpv/pkg/config"
 	"github.com/openebs/device-localpv/pkg/device"
 	"github.com/openebs/device-localpv/pkg/driver"
+	"github.com/openebs/device-localpv/pkg/mgmt/devicenode"
+	"github.com/openebs/device-localpv/pkg/mgmt/volume"
 	"github.com/openebs/device-localpv/pkg/version"
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
@@ -47,6 +55,9 @@ func main() {
 		Short: "driver for provisioning disk volume",
 		Long: `provisions and deprovisions the volume
 		    on the node which has devices configured.`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			device.DeviceNamespace = config.Namespace
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			run(config)
 		},
@@ -86,6 +97,504 @@ func main() {
 		&config.DisableExporterMetrics, "disable-exporter-metrics", true, "Excludes additional process or go runtime related metrics (i.e process_*, go_*). Default is true.",
 	)
 
+	cmd.PersistentFlags().DurationVar(
+		&config.VolumeStatsCacheTTL, "volume-stats-cache-ttl", 5*time.Second, "Duration for which volume stats are cached and reused by NodeGetVolumeStats. Zero disables the cache.",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&config.CommandHistorySize, "command-history-size", 0, "Number of the last executed disk commands exposed at `/debug/commands` on the listen address. Zero disables the command history.",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&config.MaxConcurrentCommands, "max-concurrent-commands", 4, "Maximum number of disk commands like lsblk and parted run at the same time by the node agent. Zero means no limit.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.CommandTimeout, "command-timeout", 2*time.Minute, "Duration after which a disk command like lsblk or parted print is killed, releasing its slot. The commands modifying the disks are bound by --mutating-command-timeout instead. Zero disables the timeout.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.DiskDiscovery, "disk-discovery", device.DiskDiscoveryAuto, "Backend used for listing the disks on the node i.e. lsblk, sysfs or auto. With auto, lsblk is used if it supports json output, otherwise sysfs.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.DeviceVerifyInterval, "device-verify-interval", 30*time.Minute, "Interval at which the DeviceNode is verified against the partition tables read from the disks. Zero disables the verification.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.DeviceMissingGracePeriod, "device-missing-grace-period", 0, "Duration for which a device has to be absent from the node before it is removed from the DeviceNode and its volumes are flagged, so that the devices disappearing momentarily are ignored. Zero disables the grace period.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.DeviceNodeOwner, "devicenode-owner", devicenode.OwnerNode, "Owner of the DeviceNode objects i.e. node or workload. With workload, the DaemonSet or Deployment running the node agent is resolved from the POD_NAME and POD_NAMESPACE environment variables.",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&config.IDMappedMounts, "idmapped-mounts", false, "Whether to advertise idmapped mounts support to user namespaced pods, if the kernel supports them.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.Threadiness, "threadiness", "1", "Number of volumes created or deleted in parallel by the node agent, or auto to derive it from the CPUs available to it. It is clamped to the number of disks, as a disk is changed by one volume at a time.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.TrimInterval, "trim-interval", 0, "Interval at which fstrim is run on the mounted volumes backed by discard capable SSDs. Zero disables the trimming.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.DebugAddress, "debug-address", "", "Loopback TCP address serving the partitions, free regions, reservations and recent allocation decisions as json. (e.g: `127.0.0.1:9081`). Default is empty string, which means the debug server is disabled.",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&config.FsckOnMount, "fsck-on-mount", false, "Whether to run e2fsck in preen mode on the ext filesystems marked dirty, e.g. after an unclean shutdown of the node, before mounting them.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.FsckTimeout, "fsck-timeout", 10*time.Minute, "Duration after which the filesystem check of a volume is killed, failing its mount. Zero disables the timeout.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.SlowReconcileThreshold, "slow-reconcile-threshold", 10*time.Second, "Duration after which a reconcile of a DeviceVolume or DeviceNode is reported as slow with a warning event and a log naming its slowest disk operation. Zero disables the reporting.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.HostMountNamespace, "host-mount-namespace", "", "Path of the mount namespace the volumes are mounted in via nsenter, e.g. /proc/1/ns/mnt for the host mount namespace, which requires hostPID. Empty mounts them in the namespace of the plugin container.",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&config.MediaTypeBenchmark, "media-type-benchmark", false, "Whether to classify the media type of the disks reported as rotational by sysfs, which virtual disks often are, by a short read-only random read latency benchmark run once per disk.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.OperationTimeout, "operation-timeout", 0, "Duration after which the device operations of a node request, like formatting and checking the filesystem, are aborted, on top of the deadline of the request set by the sidecars. Zero leaves them bounded by the deadline of the request only.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.Partitioner, "partitioner", device.PartitionerAuto, "Tool modifying the partition tables of the disks, sgdisk or parted. auto picks sgdisk if it is available, else parted. The partition tables are read with parted in any case.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.AuditLog, "audit-log", "", "Path of the append-only file recording every operation modifying the disks, like creating, deleting and wiping the partitions, as json lines synced to the disk before the operation completes. Empty disables the audit log.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.DevRoot, "dev-root", "/dev", "Directory holding the device nodes of the disks and their partitions, for the runtimes exposing them at another path.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.SysRoot, "sys-root", "/sys", "Directory sysfs is mounted at, for the runtimes exposing it at another path.",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&config.MinFreeRegion, "min-free-region-mib", 8, "Size in MiB below which the free regions left between the partitions are treated as unusable, leaving them out of the free capacity of the disks. The total size of such regions is logged. Zero treats every free region as usable.",
+	)
+
+	cmd.PersistentFlags().Int64Var(
+		&config.ProtectedLeadingBytes, "protected-leading-bytes", 0, "Number of bytes at the start of every disk never allocated to the volumes, protecting the bootloaders installed there. It can be overridden per device in the DeviceNode spec. Zero protects only the primary GPT.",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&config.AllowForeignSignatures, "allow-foreign-signatures", false, "Use the disks carrying an LVM physical volume or mdraid member signature, on the disk or one of its partitions, for the volumes. They are left out by default, as using them would destroy the data of the volume groups and the arrays.",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&config.ReadinessGate, "readiness-gate", true, "Report the node plugin as not ready, on the CSI probe and on "+driver.ReadyzPath+" of the listen address, and fail the publish requests with Unavailable till the disks got discovered and kubelet registered the plugin.",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&config.MinVolumeSize, "min-volume-size-mib", 0, "Size in MiB of the smallest volume. The devices whose largest free region is smaller are reported as full in the DeviceNode and the device_localpv_device_full metric, with an event recorded on entering and leaving the state. Zero never reports a device as full.",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&config.SkipFullDevices, "skip-full-devices", false, "Skip the devices found full by the last discovery when allocating the partitions of the volumes, without scanning their free regions, till a later discovery finds room on them again.",
+	)
+
+	cmd.PersistentFlags().StringSliceVar(
+		&config.TopologyLabels, "topology-labels", nil, "Comma separated keys of the labels of the Kubernetes Node, e.g. its region, zone and rack, imported as its topology. Only these, along with the keys of the driver, are advertised as the topology of the node instead of all its labels, and they are copied onto the DeviceNode and the DeviceVolumes of the node. Empty advertises all the labels of the node and copies none.",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&config.RejectOversizedVolumes, "reject-oversized-volumes", true, "Fail the volumes larger than the largest free region of the devices matching their devname on any node, as per the DeviceNodes, with OutOfRange right away instead of retrying them. The striped volumes and the ones sized by a percentage of the free capacity are never rejected, nor is any volume while a node has not published its devices.",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&config.MountRetries, "mount-retries", 3, "Number of times a mount failing for a transient cause, e.g. the device node of the partition not created yet, is retried once the devices settle. The mounts failing for a corrupt or unsupported filesystem are failed right away with FailedPrecondition and a FilesystemUnmountable event on the DeviceVolume. Zero fails every mount on the first failure.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.MountRetryBackoff, "mount-retry-backoff", time.Second, "Time waited before the first retry of a mount failing for a transient cause, doubled on each retry.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.Namespace, "namespace", device.DeviceNamespace, "Namespace of the DeviceNode and DeviceVolume objects of this install, defaulting to the DEVICE_DRIVER_NAMESPACE environment variable. It must exist at startup.",
+	)
+
+	cmd.PersistentFlags().Float64Var(
+		&config.ResyncRate, "resync-rate", volume.DefaultResyncRate, "Number of volumes enqueued per second by a resync of all the volumes of the node, requested with a POST on /debug/resync of the debug server. It keeps the reconciles from flooding the disks.",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&config.HotDiskTemperature, "hot-disk-temperature", 0, "Temperature in degrees Celsius above which the node agent places the new volumes on the other disks of their device, as long as one of them has room. The disks are not taken offline, and their temperature is reported in the DeviceNode when their hwmon sensor exposes it. Zero disables it.",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&config.MaxConcurrentFormats, "max-concurrent-formats", device.DefaultMaxConcurrentFormats, "Maximum number of filesystems created at the same time by the node plugin. Zero means no limit.",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&config.DeviceReadyDiscoveries, "device-ready-discoveries", 0, "Number of discoveries in a row a disk appearing while the node agent runs must be present for, and be readable, before it gets new volumes. Zero uses the new disks right away.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.FormatHooksDir, "format-hooks-dir", "", "Directory holding the executables of the format hooks, which must resolve within it.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.PreFormatHook, "pre-format-hook", "", "Name of the executable in the format hooks directory run before the filesystem of a new volume is created. Empty runs none.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.PostFormatHook, "post-format-hook", "", "Name of the executable in the format hooks directory run once the filesystem of a new volume is mounted, before the volume is published. Empty runs none.",
+	)
+
+	cmd.PersistentFlags().Float64Var(
+		&config.BackfillRate, "status-backfill-rate", volume.DefaultBackfillRate, "Number of volumes per second whose status fields missing for having been created by an older release, like the capacity and the disk identifier, get backfilled from their partition. Each backfill scans the partition tables of the disks, zero disables them.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.MutatingCommandTimeout, "mutating-command-timeout", 30*time.Minute, "Duration after which a disk command modifying the partition tables, the partitions or the filesystems, like sgdisk, parted mkpart or wipefs, is killed. Zero disables the timeout.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.KubeletDir, "kubelet-dir", "/var/lib/kubelet", "Root directory of kubelet, holding the mount targets of the volumes. The node plugin checks it is writable at startup.",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&config.MaxCreateFailures, "max-create-failures", 20, "Number of consecutive failures after which the node agent stops retrying the creation of a volume, marking its DeviceVolume as Failed so that CreateVolume fails without retrying. The failures are counted from the start of the node agent. Zero retries the creation forever.",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&config.ExclusiveDeviceCheck, "exclusive-device-check", true, "Whether to open the disks, and the partitions getting wiped, with O_EXCL before modifying them, refusing to modify the ones the kernel reports busy, like a disk used as a md raid member or a LVM physical volume. Disable it for the setups holding the disks on purpose.",
+	)
+
+	cmd.PersistentFlags().Int64Var(
+		&config.MaxVolumesPerNode, "max-volumes-per-node", 0, "Maximum number of volumes a node can hold across all of its devices, e.g. to keep the tiny volumes from using up the GPT entries of the disks. The node plugin advertises it to kubelet and the controller refuses to provision volumes on the nodes holding as many, so both need the same value. Zero means no limit.",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&config.SchedulerExtender, "scheduler-extender", "", "URL of the HTTP endpoint the controller POSTs the candidate nodes of each volume to, to filter and order them, e.g. with custom placement rules. Empty disables the extender.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.SchedulerExtenderTimeout, "scheduler-extender-timeout", 5*time.Second, "Duration after which the call to the scheduler extender is abandoned.",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&config.SchedulerExtenderFailOpen, "scheduler-extender-fail-open", true, "Whether to use the order of the built-in scheduler when the scheduler extender fails or times out. Disable it to fail the volume creation instead.",
+	)
+
+	cmd.PersistentFlags().DurationVar(
+		&config.StaleMountCheckInterval, "stale-mount-check-interval", 10*time.Minute, "Interval at which the node agent looks for the mounts of its partitions whose volume is gone or being deleted, or whose pod is not on the node anymore. Zero disables the check.",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&config.StaleMountThreshold, "stale-mount-threshold", 0, "Number of stale mounts above which the node gets the DeviceLocalPVStaleMounts condition.",
+	)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "repair-partition-table <disk>",
+		Short: "Repairs the partition table of the disk which failed the verification",
+		Long: `rewrites the primary and backup GPT of the disk, e.g. sdb,
+		    from the valid copy and verifies them again. It needs sgdisk.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := device.SetDeviceRoots(config.DevRoot, config.SysRoot); err != nil {
+				return err
+			}
+			if err := device.SetAuditLog(config.AuditLog); err != nil {
+				return err
+			}
+			return device.RepairPartitionTable(args[0])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "adopt-partition <disk> <partition-number> <volume-name>",
+		Short: "Adopts an existing partition of the disk as a DeviceVolume",
+		Long: `creates a DeviceVolume named after the volume, e.g. pvc-data, for
+		    the existing partition of the disk carrying the meta partition,
+		    so that it can be used by a statically provisioned PV. The data
+		    on the partition is not touched.`,
+		Args: cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			partNum, err := strconv.ParseUint(args[1], 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid partition number %q", args[1])
+			}
+			if err = device.SetDeviceRoots(config.DevRoot, config.SysRoot); err != nil {
+				return err
+			}
+			if err = device.SetPartitioner(config.Partitioner); err != nil {
+				return err
+			}
+			if err = device.SetAuditLog(config.AuditLog); err != nil {
+				return err
+			}
+			device.SetExclusiveCheck(config.ExclusiveDeviceCheck)
+			_, err = device.AdoptPartition(args[0], uint32(partNum), args[2])
+			return err
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "export-layout <file>",
+		Short: "Exports the partition layout of the node along with its DeviceVolumes",
+		Long: `writes the partitions of the disks of the node carrying the meta
+		    partition, with the disks identified by their identifier and
+		    WWN and the partitions by their unique GUID, along with the
+		    DeviceVolumes of the partitions, to the file as json. Use - to
+		    write to the standard output.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := device.SetDeviceRoots(config.DevRoot, config.SysRoot); err != nil {
+				return err
+			}
+			layout, err := device.ExportLayout()
+			if err != nil {
+				return err
+			}
+			out := os.Stdout
+			if args[0] != "-" {
+				if out, err = os.Create(args[0]); err != nil {
+					return err
+				}
+				defer out.Close()
+			}
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(layout)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "import-layout <file>",
+		Short: "Recreates the DeviceVolumes of an exported partition layout",
+		Long: `recreates the DeviceVolumes of the layout exported with
+		    export-layout, owned by this node, once the disks of the layout
+		    are found on the node by their identifier or WWN and the
+		    partitions of the volumes are checked to be unchanged. Nothing
+		    is created if any partition doesn't match. The volumes already
+		    present are left as they are.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			var layout device.Layout
+			if err = json.Unmarshal(data, &layout); err != nil {
+				return fmt.Errorf("invalid layout %s: %v", args[0], err)
+			}
+			if err = device.SetDeviceRoots(config.DevRoot, config.SysRoot); err != nil {
+				return err
+			}
+			created, err := device.ImportLayout(&layout)
+			for _, vol := range created {
+				fmt.Printf("created volume %s\n", vol.Name)
+			}
+			return err
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "activate-volume <volume-name>",
+		Short: "Activates a standby DeviceVolume",
+		Long: `clears the standby flag of the DeviceVolume reserved as a warm
+		    standby, after which the node agent marks it ready and the
+		    volume can be mounted.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return device.ActivateStandbyVolume(args[0])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "relocate-volume <volume-name> <disk>",
+		Short: "Moves the partition of a DeviceVolume to another disk of its node",
+		Long: `requests the move of the partition of the DeviceVolume to the
+		    given disk of its node, e.g. sdc, carrying the meta partition of
+		    its device. The node agent copies the data to a new partition on
+		    the disk, switches the volume to it and deletes the old one. The
+		    pods using the volume have to be stopped till it completes, as
+		    tracked in status.relocation of the DeviceVolume.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return device.RequestVolumeRelocation(args[0], args[1])
+		},
+	})
+
+	var (
+		maxMoves   int
+		jsonOutput bool
+	)
+	rebalanceCmd := &cobra.Command{
+		Use:   "recommend-rebalance <device-name>",
+		Short: "Recommends moves of the volumes between the disks of the device",
+		Long: `analyzes the partitions on the disks carrying the meta partition
+		    of the device and prints the moves of the volumes between the
+		    disks which even out their free space or grow the largest free
+		    region, with the expected effect of each move. Nothing is moved.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := device.SetDeviceRoots(config.DevRoot, config.SysRoot); err != nil {
+				return err
+			}
+			if err := device.SetMinFreeRegion(config.MinFreeRegion); err != nil {
+				return err
+			}
+			if err := device.SetProtectedLeadingBytes(config.ProtectedLeadingBytes); err != nil {
+				return err
+			}
+			moves, err := device.RecommendRebalance(args[0], maxMoves)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(moves)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "VOLUME\tFROM\tTO\tSIZE(MiB)\tIMBALANCE(MiB)\tLARGEST FREE(MiB)")
+			for _, m := range moves {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d -> %d\t%d -> %d\n", m.Volume, m.FromDisk, m.ToDisk,
+					m.SizeMiB, m.ImbalanceBeforeMiB, m.ImbalanceAfterMiB,
+					m.LargestFreeBeforeMiB, m.LargestFreeAfterMiB)
+			}
+			return w.Flush()
+		},
+	}
+	rebalanceCmd.Flags().IntVar(&maxMoves, "max-moves", 10, "Maximum number of moves recommended.")
+	rebalanceCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the moves as json.")
+	cmd.AddCommand(rebalanceCmd)
+
+	var usersJSON bool
+	usersCmd := &cobra.Command{
+		Use:   "list-device-users [<disk>]",
+		Short: "Lists the volumes mounted from the disks and the pods using them",
+		Long: `lists the active mounts of the volumes on the disks of the node,
+		    or of the given disk, e.g. sdb, along with the pods using them,
+		    resolved from the kubelet directory. It shows what breaks if a
+		    disk is taken offline. Mounts outside of the publish targets of
+		    kubelet are listed without a pod.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := device.SetDeviceRoots(config.DevRoot, config.SysRoot); err != nil {
+				return err
+			}
+			if err := device.SetHostMountNamespace(config.HostMountNamespace); err != nil {
+				return err
+			}
+			users, err := device.ListDeviceUsers(config.KubeletDir)
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				var filtered []device.DeviceUser
+				for _, u := range users {
+					if u.Disk == args[0] {
+						filtered = append(filtered, u)
+					}
+				}
+				users = filtered
+			}
+			if usersJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(users)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "DISK\tVOLUME\tPOD\tMOUNT")
+			for _, u := range users {
+				pod := u.Pod
+				if pod == "" {
+					pod = "<unknown>"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.Disk, u.Volume, pod, u.MountPath)
+			}
+			return w.Flush()
+		},
+	}
+	usersCmd.Flags().BoolVar(&usersJSON, "json", false, "Print the users as json.")
+	cmd.AddCommand(usersCmd)
+
+	var dryRun bool
+	cleanupCmd := &cobra.Command{
+		Use:   "cleanup-stale-mounts",
+		Short: "Unmounts the stale mounts of the partitions of the node",
+		Long: `unmounts the mounts of the partitions of the driver whose volume
+		    is gone or being deleted, or which are published to pods not on
+		    the node anymore, e.g. left behind by a crash, the ones the
+		    device_localpv_stale_mounts metric counts.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := device.SetDeviceRoots(config.DevRoot, config.SysRoot); err != nil {
+				return err
+			}
+			if err := device.SetHostMountNamespace(config.HostMountNamespace); err != nil {
+				return err
+			}
+			stale, err := device.FindStaleMounts(config.KubeletDir)
+			if err != nil {
+				return err
+			}
+			var failed int
+			for _, m := range stale {
+				fmt.Printf("%s	%s	%s\n", m.Volume, m.MountPath, m.Reason)
+				if dryRun {
+					continue
+				}
+				if err = device.CleanupStaleMount(m); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("could not clean up %d of the %d stale mounts", failed, len(stale))
+			}
+			return nil
+		},
+	}
+	cleanupCmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the stale mounts without unmounting them.")
+	cmd.AddCommand(cleanupCmd)
+
+	var (
+		kubeConfigPath string
+		reportFormat   string
+		reportColumns  []string
+	)
+	reportCmd := &cobra.Command{
+		Use:   "capacity-report",
+		Short: "Reports the capacity of the devices and the volumes of the cluster",
+		Long: `prints a row for every device of the DeviceNodes, with its total,
+		    used and free bytes and the size of its largest free region, and
+		    a row for every DeviceVolume, with its size, node and device, as
+		    csv or json. It runs out of the cluster with a kubeconfig.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rows, err := device.GetCapacityReport(kubeConfigPath)
+			if err != nil {
+				return err
+			}
+			return device.WriteCapacityReport(os.Stdout, reportFormat, reportColumns, rows)
+		},
+	}
+	reportCmd.Flags().StringVar(&kubeConfigPath, "kubeconfig", os.Getenv("KUBECONFIG"), "Path of the kubeconfig of the cluster, the in-cluster config if empty.")
+	reportCmd.Flags().StringVarP(&reportFormat, "output", "o", device.ReportFormatCSV, "Format of the report, csv or json.")
+	reportCmd.Flags().StringSliceVar(&reportColumns, "columns", nil,
+		"Comma separated columns of the report, out of "+strings.Join(device.ReportColumnNames(), ", ")+". All of them if empty.")
+	cmd.AddCommand(reportCmd)
+
 	err := cmd.Execute()
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "%s", err.Error())
@@ -35,9 +35,40 @@ type DeviceNode struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
+	// Spec expresses the intent of the operator about the devices to be
+	// used on the node. It is never modified by the node-agent.
+	Spec DeviceNodeSpec `json:"spec,omitempty"`
+
+	// Devices lists the discovered devices which are allowed by the spec.
 	Devices []Device `json:"devices"`
 }
 
+// DeviceNodeSpec specifies the devices to be used on the node. The devices
+// are referred by their UUID or by their name, in which case all the
+// devices having the name are matched.
+type DeviceNodeSpec struct {
+	// AllowedDevices lists the devices to be used on the node. All the
+	// discovered devices are used if it is empty.
+	AllowedDevices []string `json:"allowedDevices,omitempty"`
+
+	// BlockedDevices lists the devices not to be used on the node. It
+	// takes precedence over AllowedDevices.
+	BlockedDevices []string `json:"blockedDevices,omitempty"`
+
+	// ProtectedLeadingBytes maps the devices to the size of the region at
+	// the start of their disks which is never allocated, protecting the
+	// bootloaders installed there. It overrides the setting of the node
+	// agent for every disk.
+	ProtectedLeadingBytes map[string]resource.Quantity `json:"protectedLeadingBytes,omitempty"`
+
+	// MaxPartitionEntries maps the devices to the number of entries their
+	// partition tables are grown to, so that their disks can hold more
+	// partitions. The tables are grown in place by the node agent, as long
+	// as the larger tables don't overlap the partitions, and are never
+	// shrunk.
+	MaxPartitionEntries map[string]int32 `json:"maxPartitionEntries,omitempty"`
+}
+
 // Device specifies attributes of a given device that exists on node.
 type Device struct {
 	// Name of the device(from the meta partition)
@@ -56,6 +87,75 @@ type Device struct {
 	// Free specifies the available capacity of the device.
 	// +kubebuilder:validation:Required
 	Free resource.Quantity `json:"free"`
+
+	// Full denotes the largest free region of the device is smaller than
+	// the minimum volume size set on the node agent, i.e. no volume fits
+	// on the device anymore.
+	Full bool `json:"full,omitempty"`
+
+	// Used specifies the capacity of the device committed to the
+	// partitions of the volumes, including their growth reserves. The
+	// partitions are allocated upfront, so it is the actual usage of the
+	// device as well.
+	Used resource.Quantity `json:"used,omitempty"`
+
+	// Stranded specifies the free capacity of the device in the regions
+	// smaller than the minimum free region size set on the node agent,
+	// which can't hold a volume till the device is defragmented.
+	Stranded resource.Quantity `json:"stranded,omitempty"`
+
+	// EfficiencyPercent specifies the used capacity of the device as a
+	// percentage of the used and the stranded capacity, i.e. how little of
+	// the capacity is lost to fragmentation.
+	EfficiencyPercent int32 `json:"efficiencyPercent"`
+
+	// MediaType specifies the type of media backing the device, i.e.
+	// ssd or hdd. It is empty if the media type could not be detected.
+	// +kubebuilder:validation:Enum=ssd;hdd
+	MediaType string `json:"mediaType,omitempty"`
+
+	// MediaTypeSource specifies how the media type got detected, i.e.
+	// from the rotational attribute in sysfs or from a random read
+	// latency benchmark of the device.
+	// +kubebuilder:validation:Enum=sysfs;benchmark
+	MediaTypeSource string `json:"mediaTypeSource,omitempty"`
+
+	// Firmware specifies the firmware revision of the device. It is
+	// informational and empty if it could not be read.
+	Firmware string `json:"firmware,omitempty"`
+
+	// QueueDepth specifies the number of requests the kernel queues for
+	// the device. It is informational and zero if it could not be read.
+	QueueDepth int32 `json:"queueDepth,omitempty"`
+
+	// Temperature specifies the temperature of the device in degrees
+	// Celsius, as reported by its hwmon sensor. It is informational and
+	// zero if the device doesn't report it.
+	Temperature int32 `json:"temperature,omitempty"`
+
+	// Hot denotes the temperature of the device is above the hot
+	// temperature set on the node agent, so that the new volumes are
+	// placed on the other devices while they have room.
+	Hot bool `json:"hot,omitempty"`
+
+	// PendingReadiness denotes the device appeared recently and is not
+	// used for the new volumes till it has been present for the number of
+	// discoveries set on the node agent and its start can be read.
+	PendingReadiness bool `json:"pendingReadiness,omitempty"`
+
+	// ProtectedBytes specifies the size of the region at the start of the
+	// disks of the device which is never allocated, including the primary
+	// GPT.
+	ProtectedBytes resource.Quantity `json:"protectedBytes,omitempty"`
+
+	// PartitionEntries specifies the number of entries of the partition
+	// table in use, including the meta partition. It is zero if the
+	// partition table could not be read.
+	PartitionEntries int32 `json:"partitionEntries,omitempty"`
+
+	// MaxPartitionEntries specifies the number of entries of the partition
+	// table, i.e. the number of partitions the disk can hold.
+	MaxPartitionEntries int32 `json:"maxPartitionEntries,omitempty"`
 }
 
 // DeviceNodeList is a collection of DeviceNode resources
@@ -0,0 +1,75 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resource:path=devicequota
+
+// DeviceQuota limits the capacity the volumes of a namespace can take on
+// the devices of a node. It is created in the namespace of the driver by
+// the cluster administrator, so that the tenants can't modify their own
+// quota. The usage is derived from the DeviceVolumes of the namespace,
+// so deleting a volume releases its capacity.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=devquota
+// +kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.spec.namespace`,description="Namespace of the claims"
+// +kubebuilder:printcolumn:name="DeviceName",type=string,JSONPath=`.spec.deviceName`,description="Device pool of the quota"
+// +kubebuilder:printcolumn:name="Capacity",type=string,JSONPath=`.spec.capacity`,description="Capacity allotted on each node"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="Age of the quota"
+type DeviceQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DeviceQuotaSpec `json:"spec"`
+}
+
+// DeviceQuotaSpec specifies the capacity allotted to a namespace on the
+// devices of each node.
+type DeviceQuotaSpec struct {
+	// Namespace is the namespace of the persistent volume claims the
+	// quota applies to.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// DeviceName is the device pool the quota applies to, i.e. the
+	// devname parameter of the storage classes.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	DeviceName string `json:"deviceName"`
+
+	// Capacity is the capacity the volumes of the namespace can take on
+	// the devices of a node, including their growth reserves.
+	// +kubebuilder:validation:Required
+	Capacity resource.Quantity `json:"capacity"`
+}
+
+// DeviceQuotaList is a collection of DeviceQuota resources
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resource:path=devicequotas
+type DeviceQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []DeviceQuota `json:"items"`
+}
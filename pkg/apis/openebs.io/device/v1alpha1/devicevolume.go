@@ -59,7 +59,8 @@ type VolumeInfo struct {
 	// +kubebuilder:validation:Required
 	OwnerNodeID string `json:"ownerNodeID"`
 
-	// Capacity of the volume
+	// Capacity of the volume. For the volumes sized by SizePercent, it is
+	// the capacity resolved from the free capacity of the node.
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:MinLength=1
 	Capacity string `json:"capacity"`
@@ -69,6 +70,98 @@ type VolumeInfo struct {
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:MinLength=1
 	DevName string `json:"devname"`
+
+	// AntiAffinityGroup is the group of the volume, as the label key and
+	// value of its claim, like app=db. The partition of the volume is kept
+	// off the disks holding the partitions of the other volumes of the
+	// group on the node.
+	AntiAffinityGroup string `json:"antiAffinityGroup,omitempty"`
+
+	// AntiAffinityPolicy is what happens when only the disks holding the
+	// other volumes of the group have room for the partition. "strict"
+	// fails the creation of the volume and "relaxed" places it on them
+	// anyway. Defaults to strict.
+	// +kubebuilder:validation:Enum=strict;relaxed
+	AntiAffinityPolicy string `json:"antiAffinityPolicy,omitempty"`
+
+	// BytesPerInode is the bytes-per-inode ratio of an ext3 or ext4
+	// filesystem, i.e. one inode is created for every BytesPerInode bytes
+	// of the partition. It is applied when the filesystem is created on the
+	// partition of the volume. Empty leaves the default of mkfs.
+	// +kubebuilder:validation:Pattern=`^[0-9]+$`
+	BytesPerInode string `json:"bytesPerInode,omitempty"`
+
+	// FsLabel is the label set on the filesystem of the volume when it is
+	// created, truncated to the length limit of the filesystem.
+	FsLabel string `json:"fsLabel,omitempty"`
+
+	// FsType is the filesystem found on the partition of an adopted
+	// volume when it got adopted. It is empty for the provisioned volumes
+	// and for the partitions holding no filesystem.
+	FsType string `json:"fsType,omitempty"`
+
+	// GrowthReserve is the size in bytes of the space kept free right after
+	// the partition of the volume by the earlier versions. The new volumes
+	// don't get one.
+	GrowthReserve string `json:"growthReserve,omitempty"`
+
+	// PartitionType is the GPT partition type of the partition, either as
+	// a sgdisk type code like 8300 or as a type GUID.
+	// +kubebuilder:validation:Pattern=`^([0-9a-fA-F]{4}|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`
+	PartitionType string `json:"partitionType,omitempty"`
+
+	// Placement is the policy for picking the disk of the partition among
+	// the disks having the device name. "binpack" packs the partitions on
+	// as few disks as possible, "spread" distributes them across the
+	// disks and "fit" prefers the free regions close to the size of the
+	// partition, keeping the large ones intact. Defaults to binpack.
+	// +kubebuilder:validation:Enum=binpack;spread;fit
+	Placement string `json:"placement,omitempty"`
+
+	// FitTolerancePercent is how much larger than the partition, in
+	// percent, a free region can be to be preferred by the fit placement.
+	// +kubebuilder:validation:Pattern=`^([0-9]|[1-9][0-9]|100)$`
+	FitTolerancePercent string `json:"fitTolerancePercent,omitempty"`
+
+	// ReservedBlocksPercent is the percentage of the blocks of an ext3 or
+	// ext4 filesystem reserved for the super-user. It is applied when the
+	// filesystem is created on the partition of the volume.
+	// +kubebuilder:validation:Pattern=`^([0-9]|[1-4][0-9]|50)$`
+	ReservedBlocksPercent string `json:"reservedBlocksPercent,omitempty"`
+
+	// RootDirMode is the permission mode, in octal like 0770, set on the
+	// root directory of the filesystem of the volume after its first mount.
+	// +kubebuilder:validation:Pattern=`^0?[0-7]{3}$`
+	RootDirMode string `json:"rootDirMode,omitempty"`
+
+	// SizePercent is the percentage of the free capacity of a device on the
+	// node the capacity of the volume got resolved from, instead of the
+	// requested capacity.
+	// +kubebuilder:validation:Pattern=`^([1-9]|[1-9][0-9]|100)$`
+	SizePercent string `json:"sizePercent,omitempty"`
+
+	// SlotBudget is the number of GPT partition entries the volumes of
+	// SlotClass can take on each disk of the node, counting the growth
+	// reserves. The partition of the volume is kept off the disks where
+	// the class used up its budget.
+	// +kubebuilder:validation:Pattern=`^[1-9][0-9]*$`
+	SlotBudget string `json:"slotBudget,omitempty"`
+
+	// SlotClass is the class the GPT partition entries of the volume are
+	// accounted to, as set by the storage class of the volume.
+	SlotClass string `json:"slotClass,omitempty"`
+
+	// StripeCount is the number of disks the volume is striped across as
+	// a RAID0 array. Empty means the volume is a single partition. A
+	// failure of any of the disks loses the data of the whole volume.
+	// +kubebuilder:validation:Pattern=`^([2-8])$`
+	StripeCount string `json:"stripeCount,omitempty"`
+
+	// Standby marks the volume as a warm standby reservation. Its
+	// partition is created and held, but the volume is not mounted till it
+	// gets activated by setting Standby to false.
+	// +kubebuilder:validation:Enum=true;false
+	Standby string `json:"standby,omitempty"`
 }
 
 // VolStatus string that specifies the current state of the volume provisioning request.
@@ -76,15 +169,113 @@ type VolStatus struct {
 	// State specifies the current state of the volume provisioning request.
 	// The state "Pending" means that the volume creation request has not
 	// processed yet. The state "Ready" means that the volume has been created
-	// and it is ready for the use.
-	// +kubebuilder:validation:Enum=Pending;Ready
+	// and it is ready for the use. The state "Failed" means that the
+	// creation of the volume failed too many times in a row and is not
+	// retried anymore. The state "Reserved" means that the partition of a
+	// standby volume has been created, but the volume can't be used till
+	// it gets activated.
+	// +kubebuilder:validation:Enum=Pending;Ready;Failed;Reserved
 	State string `json:"state,omitempty"`
 
+	// Capacity denotes the actual size in bytes of the partition allocated
+	// for the volume. It can be larger than the requested capacity, as the
+	// partitions are created on sector and alignment boundaries.
+	Capacity string `json:"capacity,omitempty"`
+
+	// DiskUUID denotes the identifier of the disk holding the partition of
+	// the volume. It is used to detect the replacement of the disk.
+	DiskUUID string `json:"diskUUID,omitempty"`
+
+	// DiskWWN denotes the world wide identifier of the disk holding the
+	// partition of the volume. It is used to follow the disk across the
+	// changes of its kernel name.
+	DiskWWN string `json:"diskWWN,omitempty"`
+
+	// DevicePath denotes the path of the device node of the partition of
+	// the volume. It is updated by the node agent when the kernel name of
+	// the disk changes.
+	DevicePath string `json:"devicePath,omitempty"`
+
+	// Members denotes the device paths of the partitions the RAID0 array
+	// of a striped volume is assembled from.
+	Members []string `json:"members,omitempty"`
+
+	// AppliedAttributes denotes the mutable attributes of the spec, like
+	// partitionType and reservedBlocksPercent, applied to the partition of
+	// the volume. The attributes modified after the creation of the volume
+	// are applied by the node agent till they match the spec.
+	AppliedAttributes map[string]string `json:"appliedAttributes,omitempty"`
+
+	// RootDirInitialized denotes that the mode of the root directory of the
+	// filesystem of the volume got set, so it is not set again.
+	RootDirInitialized bool `json:"rootDirInitialized,omitempty"`
+
+	// Unformatted denotes the partition of the volume got created, but no
+	// filesystem got created on it yet. The filesystem is created by the
+	// first mount of the volume, the block volumes are never formatted.
+	Unformatted bool `json:"unformatted,omitempty"`
+
+	// Relocation denotes the progress of the move of the partition of the
+	// volume to another disk of the node.
+	Relocation *VolumeRelocation `json:"relocation,omitempty"`
+
+	// Conditions denotes the abnormal conditions observed on the volume.
+	Conditions []VolumeCondition `json:"conditions,omitempty"`
+
 	// Error denotes the error occurred during provisioning a volume.
 	// Error field should only be set when State becomes Failed.
 	Error *VolumeError `json:"error,omitempty"`
 }
 
+// VolumeCondition specifies an abnormal condition observed on the volume.
+type VolumeCondition struct {
+	Type VolumeConditionType `json:"type"`
+	// Message is a human readable description of the condition.
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is the time the condition was observed first.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// VolumeConditionType represents the type of the volume condition.
+type VolumeConditionType string
+
+const (
+	// DeviceMissing represents that the disk holding the partition of the
+	// volume is not present on the node anymore, e.g. it got replaced.
+	DeviceMissing VolumeConditionType = "DeviceMissing"
+	// PartitionTableInvalid represents that the partition table of the disk
+	// failed the verification, so the partition of the volume can't be
+	// created or deleted till the table is repaired.
+	PartitionTableInvalid VolumeConditionType = "PartitionTableInvalid"
+	// NodeLost represents that the node holding the partition of the
+	// volume is removed from the cluster, so the data of the volume is
+	// lost.
+	NodeLost VolumeConditionType = "NodeLost"
+	// DeviceRemapAmbiguous represents that more than one disk has the world
+	// wide identifier of the disk holding the partition of the volume, so
+	// the device path of the volume is not updated till an operator
+	// reviews the disks.
+	DeviceRemapAmbiguous VolumeConditionType = "DeviceRemapAmbiguous"
+	// CreationFailed represents that the partition of the volume failed to
+	// be created too many times in a row, so the node agent gave up on
+	// the volume. The claim of the volume has to be recreated.
+	CreationFailed VolumeConditionType = "CreationFailed"
+)
+
+// VolumeRelocation specifies the progress of the move of the partition of
+// a volume to another disk of the node.
+type VolumeRelocation struct {
+	// TargetDisk is the disk the partition of the volume is moved to.
+	TargetDisk string `json:"targetDisk"`
+
+	// Phase is the step of the relocation to be run next, or its outcome.
+	// +kubebuilder:validation:Enum=Allocating;Copying;Switching;Releasing;Completed;Failed
+	Phase string `json:"phase"`
+
+	// Message explains why the relocation failed.
+	Message string `json:"message,omitempty"`
+}
+
 // VolumeError specifies the error occurred during volume provisioning.
 type VolumeError struct {
 	Code    VolumeErrorCode `json:"code,omitempty"`
@@ -73,6 +73,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&DeviceVolumeList{},
 		&DeviceNode{},
 		&DeviceNodeList{},
+		&DeviceQuota{},
+		&DeviceQuotaList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -21,6 +21,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	resource "k8s.io/apimachinery/pkg/api/resource"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -29,6 +30,9 @@ func (in *Device) DeepCopyInto(out *Device) {
 	*out = *in
 	out.Size = in.Size.DeepCopy()
 	out.Free = in.Free.DeepCopy()
+	out.Used = in.Used.DeepCopy()
+	out.Stranded = in.Stranded.DeepCopy()
+	out.ProtectedBytes = in.ProtectedBytes.DeepCopy()
 	return
 }
 
@@ -47,6 +51,7 @@ func (in *DeviceNode) DeepCopyInto(out *DeviceNode) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
 	if in.Devices != nil {
 		in, out := &in.Devices, &out.Devices
 		*out = make([]Device, len(*in))
@@ -108,6 +113,123 @@ func (in *DeviceNodeList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceNodeSpec) DeepCopyInto(out *DeviceNodeSpec) {
+	*out = *in
+	if in.AllowedDevices != nil {
+		in, out := &in.AllowedDevices, &out.AllowedDevices
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BlockedDevices != nil {
+		in, out := &in.BlockedDevices, &out.BlockedDevices
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProtectedLeadingBytes != nil {
+		in, out := &in.ProtectedLeadingBytes, &out.ProtectedLeadingBytes
+		*out = make(map[string]resource.Quantity, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.MaxPartitionEntries != nil {
+		in, out := &in.MaxPartitionEntries, &out.MaxPartitionEntries
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceNodeSpec.
+func (in *DeviceNodeSpec) DeepCopy() *DeviceNodeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceNodeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceQuota) DeepCopyInto(out *DeviceQuota) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceQuota.
+func (in *DeviceQuota) DeepCopy() *DeviceQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DeviceQuota) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceQuotaList) DeepCopyInto(out *DeviceQuotaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DeviceQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceQuotaList.
+func (in *DeviceQuotaList) DeepCopy() *DeviceQuotaList {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceQuotaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DeviceQuotaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceQuotaSpec) DeepCopyInto(out *DeviceQuotaSpec) {
+	*out = *in
+	out.Capacity = in.Capacity.DeepCopy()
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceQuotaSpec.
+func (in *DeviceQuotaSpec) DeepCopy() *DeviceQuotaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceQuotaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeviceVolume) DeepCopyInto(out *DeviceVolume) {
 	*out = *in
@@ -172,6 +294,30 @@ func (in *DeviceVolumeList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolStatus) DeepCopyInto(out *VolStatus) {
 	*out = *in
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AppliedAttributes != nil {
+		in, out := &in.AppliedAttributes, &out.AppliedAttributes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Relocation != nil {
+		in, out := &in.Relocation, &out.Relocation
+		*out = new(VolumeRelocation)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]VolumeCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Error != nil {
 		in, out := &in.Error, &out.Error
 		*out = new(VolumeError)
@@ -190,6 +336,39 @@ func (in *VolStatus) DeepCopy() *VolStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeCondition) DeepCopyInto(out *VolumeCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeCondition.
+func (in *VolumeCondition) DeepCopy() *VolumeCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeRelocation) DeepCopyInto(out *VolumeRelocation) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeRelocation.
+func (in *VolumeRelocation) DeepCopy() *VolumeRelocation {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeRelocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolumeError) DeepCopyInto(out *VolumeError) {
 	*out = *in
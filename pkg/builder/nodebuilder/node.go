@@ -107,6 +107,23 @@ func (b *Builder) WithDevices(devices []apis.Device) *Builder {
 	return b
 }
 
+// WithLabels merges existing labels if any
+// with the ones that are provided here
+func (b *Builder) WithLabels(labels map[string]string) *Builder {
+	if len(labels) == 0 {
+		return b
+	}
+
+	if b.node.Object.Labels == nil {
+		b.node.Object.Labels = map[string]string{}
+	}
+
+	for key, value := range labels {
+		b.node.Object.Labels[key] = value
+	}
+	return b
+}
+
 // WithOwnerReferences sets the owner references of DeviceNode
 func (b *Builder) WithOwnerReferences(ownerRefs ...metav1.OwnerReference) *Builder {
 	b.node.Object.OwnerReferences = ownerRefs
@@ -159,6 +159,23 @@ func (b *Builder) WithLabels(labels map[string]string) *Builder {
 	return b
 }
 
+// WithAnnotations merges existing annotations if any
+// with the ones that are provided here
+func (b *Builder) WithAnnotations(annotations map[string]string) *Builder {
+	if len(annotations) == 0 {
+		return b
+	}
+
+	if b.volume.Object.Annotations == nil {
+		b.volume.Object.Annotations = map[string]string{}
+	}
+
+	for key, value := range annotations {
+		b.volume.Object.Annotations[key] = value
+	}
+	return b
+}
+
 // WithFinalizer sets Finalizer name creating the volume
 func (b *Builder) WithFinalizer(finalizer []string) *Builder {
 	b.volume.Object.Finalizers = append(b.volume.Object.Finalizers, finalizer...)
@@ -180,6 +197,94 @@ func (b *Builder) WithDeviceName(deviceName string) *Builder {
 	return b
 }
 
+// WithFsType sets the filesystem found on the partition of an adopted volume
+func (b *Builder) WithFsType(fsType string) *Builder {
+	b.volume.Object.Spec.FsType = fsType
+	return b
+}
+
+// WithFsLabel sets the label of the filesystem created on the partition of
+// the volume
+func (b *Builder) WithFsLabel(label string) *Builder {
+	b.volume.Object.Spec.FsLabel = label
+	return b
+}
+
+// WithPartitionType sets the GPT partition type for creating volume
+func (b *Builder) WithPartitionType(partitionType string) *Builder {
+	b.volume.Object.Spec.PartitionType = partitionType
+	return b
+}
+
+// WithPlacement sets the disk placement policy for creating volume
+func (b *Builder) WithPlacement(placement string) *Builder {
+	b.volume.Object.Spec.Placement = placement
+	return b
+}
+
+// WithFitTolerancePercent sets how much larger than the partition a free
+// region can be to be preferred by the fit placement
+func (b *Builder) WithFitTolerancePercent(percent string) *Builder {
+	b.volume.Object.Spec.FitTolerancePercent = percent
+	return b
+}
+
+// WithAntiAffinity sets the anti-affinity group of the volume and the
+// policy applied when only the disks of the group have room for it
+func (b *Builder) WithAntiAffinity(group, policy string) *Builder {
+	b.volume.Object.Spec.AntiAffinityGroup = group
+	b.volume.Object.Spec.AntiAffinityPolicy = policy
+	return b
+}
+
+// WithBytesPerInode sets the bytes-per-inode ratio of the filesystem of
+// the volume
+func (b *Builder) WithBytesPerInode(ratio string) *Builder {
+	b.volume.Object.Spec.BytesPerInode = ratio
+	return b
+}
+
+// WithReservedBlocksPercent sets the percentage of the filesystem blocks
+// reserved for the super-user
+func (b *Builder) WithReservedBlocksPercent(percent string) *Builder {
+	b.volume.Object.Spec.ReservedBlocksPercent = percent
+	return b
+}
+
+// WithRootDirMode sets the mode of the root directory of the filesystem of
+// the volume
+func (b *Builder) WithRootDirMode(mode string) *Builder {
+	b.volume.Object.Spec.RootDirMode = mode
+	return b
+}
+
+// WithSizePercent sets the percentage of the free capacity the capacity
+// of the volume got resolved from
+func (b *Builder) WithSizePercent(percent string) *Builder {
+	b.volume.Object.Spec.SizePercent = percent
+	return b
+}
+
+// WithStripeCount sets the number of disks the volume is striped across
+func (b *Builder) WithStripeCount(count string) *Builder {
+	b.volume.Object.Spec.StripeCount = count
+	return b
+}
+
+// WithSlotBudget sets the class the GPT partition entries of the volume are
+// accounted to and the number of entries the class can take on a disk
+func (b *Builder) WithSlotBudget(class, budget string) *Builder {
+	b.volume.Object.Spec.SlotClass = class
+	b.volume.Object.Spec.SlotBudget = budget
+	return b
+}
+
+// WithStandby sets whether the volume is a warm standby reservation
+func (b *Builder) WithStandby(standby string) *Builder {
+	b.volume.Object.Spec.Standby = standby
+	return b
+}
+
 // Build returns DeviceVolume API object
 func (b *Builder) Build() (*apis.DeviceVolume, error) {
 	if len(b.errs) > 0 {
@@ -17,7 +17,8 @@
 package collector
 
 import (
-	"strings"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -28,48 +29,136 @@ import (
 
 const refreshInterval = 1 * time.Minute
 
+// claim is the PVC a volume got provisioned for.
+type claim struct {
+	name      string
+	namespace string
+}
+
 type deviceCollector struct {
-	volSizeMetric *prometheus.Desc
+	volSizeMetric      *prometheus.Desc
+	readOpsMetric      *prometheus.Desc
+	readSectorsMetric  *prometheus.Desc
+	writeOpsMetric     *prometheus.Desc
+	writeSectorsMetric *prometheus.Desc
 
 	mtx   sync.RWMutex
 	parts []device.PartUsed
+	// claims of the DeviceVolumes owned by the node, by volume name.
+	claims map[string]claim
 }
 
 func (c *deviceCollector) Describe(descs chan<- *prometheus.Desc) {
 	descs <- c.volSizeMetric
+	descs <- c.readOpsMetric
+	descs <- c.readSectorsMetric
+	descs <- c.writeOpsMetric
+	descs <- c.writeSectorsMetric
 }
 
 func (c *deviceCollector) Collect(metrics chan<- prometheus.Metric) {
 	c.mtx.RLock()
 	parts := c.parts
+	claims := c.claims
 	c.mtx.RUnlock()
 
 	for _, part := range parts {
 		metrics <- prometheus.MustNewConstMetric(c.volSizeMetric,
 			prometheus.GaugeValue, float64(part.Size),
-			part.GetPVName(), strings.TrimLeft(part.DevicePath, "/dev/"),
+			part.GetPVName(), filepath.Base(part.DevicePath),
 		)
+		// the partitions not belonging to a volume of the node, e.g. left
+		// over by a deleted volume, can't be attributed to a claim.
+		if pvc, ok := claims[part.GetPVName()]; ok {
+			c.collectIOStats(metrics, part, pvc)
+		}
 	}
 }
 
+// collectIOStats reads the IO statistics of the partition at every scrape,
+// so that the counters are up to date.
+func (c *deviceCollector) collectIOStats(metrics chan<- prometheus.Metric,
+	part device.PartUsed, pvc claim) {
+	stats, err := device.GetPartitionIOStats(part)
+	if os.IsNotExist(err) {
+		klog.V(4).Infof("partition %s has no IO stats", part.DevicePath)
+		return
+	}
+	if err != nil {
+		klog.Errorf("read IO stats of partition %s: %v", part.DevicePath, err)
+		return
+	}
+	labels := []string{part.GetPVName(), pvc.namespace, pvc.name}
+	metrics <- prometheus.MustNewConstMetric(c.readOpsMetric,
+		prometheus.CounterValue, float64(stats.ReadOps), labels...)
+	metrics <- prometheus.MustNewConstMetric(c.readSectorsMetric,
+		prometheus.CounterValue, float64(stats.ReadSectors), labels...)
+	metrics <- prometheus.MustNewConstMetric(c.writeOpsMetric,
+		prometheus.CounterValue, float64(stats.WriteOps), labels...)
+	metrics <- prometheus.MustNewConstMetric(c.writeSectorsMetric,
+		prometheus.CounterValue, float64(stats.WriteSectors), labels...)
+}
+
+// listClaims lists the claims of the DeviceVolumes owned by the node.
+func listClaims() (map[string]claim, error) {
+	vols, err := device.ListDeviceVolumes()
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]claim{}
+	for _, vol := range vols.Items {
+		if vol.Spec.OwnerNodeID != device.NodeID {
+			continue
+		}
+		claims[vol.Name] = claim{
+			name:      vol.Annotations[device.PVCNameKey],
+			namespace: vol.Annotations[device.PVCNamespaceKey],
+		}
+	}
+	return claims, nil
+}
+
 func (c *deviceCollector) listPartitions() {
 	parts, err := device.ListPartUsed()
 	if err != nil {
 		klog.Errorf("list device partitions: %v", err)
 		parts = nil
 	}
+	claims, err := listClaims()
+	if err != nil {
+		klog.Errorf("list device volumes: %v", err)
+		claims = nil
+	}
 	c.mtx.Lock()
 	c.parts = parts
+	c.claims = claims
 	c.mtx.Unlock()
 }
 
 // NewDeviceCollector collects disk partition related metrics.
 func NewDeviceCollector(stopCh <-chan struct{}) prometheus.Collector {
+	ioLabels := []string{"volumename", "pvc_namespace", "pvc_name"}
 	dc := &deviceCollector{
 		volSizeMetric: prometheus.NewDesc(
 			prometheus.BuildFQName("openebs", "size_of", "volume"),
 			"Partition volume total size in bytes",
 			[]string{"volumename", "device"}, nil),
+		readOpsMetric: prometheus.NewDesc(
+			prometheus.BuildFQName("openebs", "device_volume", "read_ops_total"),
+			"Number of read IOs completed on the partition of the volume",
+			ioLabels, nil),
+		readSectorsMetric: prometheus.NewDesc(
+			prometheus.BuildFQName("openebs", "device_volume", "read_sectors_total"),
+			"Number of 512 byte sectors read from the partition of the volume",
+			ioLabels, nil),
+		writeOpsMetric: prometheus.NewDesc(
+			prometheus.BuildFQName("openebs", "device_volume", "write_ops_total"),
+			"Number of write IOs completed on the partition of the volume",
+			ioLabels, nil),
+		writeSectorsMetric: prometheus.NewDesc(
+			prometheus.BuildFQName("openebs", "device_volume", "write_sectors_total"),
+			"Number of 512 byte sectors written to the partition of the volume",
+			ioLabels, nil),
 	}
 
 	dc.listPartitions()
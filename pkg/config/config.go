@@ -16,6 +16,8 @@ limitations under the License.
 
 package config
 
+import "time"
+
 // Config struct fills the parameters of request or user input
 type Config struct {
 	// DriverName to be registered at CSI
@@ -54,6 +56,237 @@ type Config struct {
 	// Excludes additional process or go runtime related metrics (i.e process_*, go_*).
 	// Default is true
 	DisableExporterMetrics bool
+
+	// VolumeStatsCacheTTL denotes how long the volume stats served to
+	// NodeGetVolumeStats are reused before running statfs again.
+	// Zero disables the cache.
+	VolumeStatsCacheTTL time.Duration
+
+	// CommandHistorySize denotes the number of the last executed disk
+	// commands exposed on the metrics server for debugging.
+	// Zero disables the command history.
+	CommandHistorySize int
+
+	// MaxConcurrentCommands denotes the maximum number of disk commands
+	// like lsblk and parted running at the same time. Zero means no limit.
+	MaxConcurrentCommands int
+
+	// CommandTimeout denotes the time after which a disk command is
+	// killed, unless it modifies the disks. Zero disables the timeout.
+	CommandTimeout time.Duration
+
+	// DiskDiscovery denotes the backend used for listing the disks on the
+	// node, i.e. lsblk, sysfs or auto to pick the best available.
+	DiskDiscovery string
+
+	// DeviceVerifyInterval denotes how often the DeviceNode is verified
+	// against the partition tables read from the disks.
+	// Zero disables the verification.
+	DeviceVerifyInterval time.Duration
+
+	// DeviceMissingGracePeriod denotes how long a device has to be absent
+	// from the node before it is treated as gone. Zero disables it.
+	DeviceMissingGracePeriod time.Duration
+
+	// DeviceNodeOwner denotes the owner of the DeviceNode objects, i.e.
+	// the kubernetes node or the workload running the node agent.
+	DeviceNodeOwner string
+
+	// IDMappedMounts enables idmapped mounts of the volumes into user
+	// namespaced pods, if the kernel supports them.
+	IDMappedMounts bool
+
+	// Threadiness denotes the number of workers of the volume controller,
+	// either a number or auto to derive it from the available CPUs. It is
+	// clamped to the number of disks.
+	Threadiness string
+
+	// TrimInterval denotes the interval at which fstrim is run on the
+	// volumes backed by SSDs. Zero disables the trimming.
+	TrimInterval time.Duration
+
+	// DebugAddress denotes the loopback tcp address serving the in-memory
+	// state of the allocator as json. (example: "127.0.0.1:9081").
+	// Default is empty string, which means the debug server is disabled.
+	DebugAddress string
+
+	// FsckOnMount enables the check of the ext filesystems marked dirty,
+	// e.g. after an unclean shutdown, before mounting them.
+	FsckOnMount bool
+
+	// FsckTimeout denotes the time after which the filesystem check of a
+	// volume is killed. Zero disables the timeout.
+	FsckTimeout time.Duration
+
+	// SlowReconcileThreshold denotes the duration after which a reconcile
+	// of the node agent controllers is reported as slow with a warning
+	// event and a log. Zero disables the reporting.
+	SlowReconcileThreshold time.Duration
+
+	// HostMountNamespace is the path of the mount namespace the volumes
+	// are mounted in via nsenter, e.g. /proc/1/ns/mnt for the host mount
+	// namespace. Empty mounts them in the namespace of the plugin.
+	HostMountNamespace string
+
+	// MediaTypeBenchmark enables the classification of the media type of
+	// the disks which sysfs reports as rotational, or doesn't report, by
+	// a random read latency benchmark.
+	MediaTypeBenchmark bool
+
+	// OperationTimeout bounds the device operations of a node request, on
+	// top of the deadline of the request. Zero leaves them bounded by the
+	// deadline of the request only.
+	OperationTimeout time.Duration
+
+	// Partitioner is the tool modifying the partition tables of the disks,
+	// i.e. sgdisk or parted, or auto to pick the one available.
+	Partitioner string
+
+	// AuditLog is the path of the file recording the operations modifying
+	// the disks. Empty disables the audit log.
+	AuditLog string
+
+	// DevRoot is the directory holding the device nodes of the disks,
+	// /dev by default.
+	DevRoot string
+
+	// SysRoot is the directory sysfs is mounted at, /sys by default.
+	SysRoot string
+
+	// MinFreeRegion is the size in MiB below which the free regions left
+	// between the partitions are treated as unusable.
+	MinFreeRegion int
+
+	// KubeletDir is the root directory of kubelet, holding the mount
+	// targets of the volumes created by the node plugin.
+	KubeletDir string
+
+	// MaxCreateFailures is the number of consecutive failures after which
+	// the node agent stops retrying the creation of a volume and marks it
+	// as failed. Zero retries it forever.
+	MaxCreateFailures int
+
+	// ExclusiveDeviceCheck enables opening the disks and partitions with
+	// O_EXCL before modifying them, refusing to modify the ones held by
+	// another subsystem.
+	ExclusiveDeviceCheck bool
+
+	// MaxVolumesPerNode is the maximum number of volumes a node can hold
+	// across all of its devices. It is advertised by the node plugin and
+	// enforced by the controller. Zero means no limit.
+	MaxVolumesPerNode int64
+
+	// SchedulerExtender is the URL of the HTTP endpoint the controller
+	// calls to filter and order the nodes picked by the built-in scheduler.
+	// Empty disables the extender.
+	SchedulerExtender string
+
+	// SchedulerExtenderTimeout bounds the call to the scheduler extender.
+	SchedulerExtenderTimeout time.Duration
+
+	// SchedulerExtenderFailOpen falls back to the order of the built-in
+	// scheduler when the scheduler extender fails, instead of failing the
+	// volume creation.
+	SchedulerExtenderFailOpen bool
+
+	// StaleMountCheckInterval is the interval at which the node agent
+	// looks for the mounts of its partitions which don't belong to a live
+	// volume or pod anymore. Zero disables the check.
+	StaleMountCheckInterval time.Duration
+
+	// StaleMountThreshold is the number of stale mounts above which the
+	// node gets the stale mounts condition.
+	StaleMountThreshold int
+
+	// ProtectedLeadingBytes is the number of bytes at the start of every
+	// disk which are never allocated, unless overridden per device in the
+	// DeviceNode spec.
+	ProtectedLeadingBytes int64
+
+	// AllowForeignSignatures lets the disks carrying LVM or mdraid
+	// signatures be used for the volumes.
+	AllowForeignSignatures bool
+
+	// ReadinessGate holds the node plugin back from publishing the
+	// volumes, and reports it as not ready, till the disks got discovered
+	// and kubelet registered the plugin.
+	ReadinessGate bool
+
+	// MinVolumeSize is the size in MiB of the smallest volume, the devices
+	// whose largest free region is smaller are reported as full. Zero
+	// never reports a device as full.
+	MinVolumeSize int
+
+	// SkipFullDevices lets the node agent skip the devices found
+	// full by the last discovery when allocating the partitions.
+	SkipFullDevices bool
+
+	// TopologyLabels are the keys of the labels of the Kubernetes Node,
+	// e.g. its region, zone and rack, imported as its topology. They are
+	// advertised as the topology segments of the node instead of all its
+	// labels, and set on the DeviceNode and the DeviceVolumes of the node.
+	TopologyLabels []string
+
+	// RejectOversizedVolumes lets the controller fail the volumes larger
+	// than the largest free region of the devices on any node, rather than
+	// retrying them.
+	RejectOversizedVolumes bool
+
+	// MountRetries is the number of times the node plugin retries a mount
+	// failing for a transient cause, e.g. the device node of the partition
+	// not created yet, waiting for the devices to settle in between.
+	MountRetries int
+
+	// MountRetryBackoff is the time waited before the first retry of a
+	// mount, doubled on each retry.
+	MountRetryBackoff time.Duration
+
+	// Namespace is the namespace of the DeviceNode and DeviceVolume
+	// objects of the install, so that several installs can run in the
+	// same cluster.
+	Namespace string
+
+	// ResyncRate is the number of volumes enqueued per second by a resync
+	// of all the volumes of the node requested on the debug server.
+	ResyncRate float64
+
+	// HotDiskTemperature is the temperature in degrees Celsius above which
+	// the disks are avoided by the new volumes while the other disks have
+	// room for them. Zero disables it.
+	HotDiskTemperature int
+
+	// MaxConcurrentFormats is the maximum number of filesystems created
+	// at the same time by the node plugin. Zero means no limit.
+	MaxConcurrentFormats int
+
+	// DeviceReadyDiscoveries is the number of discoveries in a row a disk
+	// appearing while the node agent runs must be present for, and be
+	// readable, before the new volumes get placed on it. Zero uses the
+	// new disks right away.
+	DeviceReadyDiscoveries int
+
+	// FormatHooksDir is the directory holding the format hooks, they
+	// can't be run from anywhere else.
+	FormatHooksDir string
+
+	// PreFormatHook is the name of the executable in FormatHooksDir run
+	// before the filesystem of a new volume is created. Empty runs none.
+	PreFormatHook string
+
+	// PostFormatHook is the name of the executable in FormatHooksDir run
+	// once the new filesystem of a volume is mounted, before the volume
+	// gets published. Empty runs none.
+	PostFormatHook string
+
+	// BackfillRate is the number of volumes per second whose status
+	// fields missing for having been created by an older release get
+	// backfilled from their partition. Zero disables it.
+	BackfillRate float64
+
+	// MutatingCommandTimeout denotes the time after which a disk command
+	// modifying the partition tables, the partitions or the filesystems is
+	// killed. Zero disables the timeout.
+	MutatingCommandTimeout time.Duration
 }
 
 // Default returns a new instance of config
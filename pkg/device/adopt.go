@@ -0,0 +1,189 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/builder/volbuilder"
+)
+
+// Partition adoption commands
+const (
+	PartitionSetName = "parted %s name %d %s --script"
+	// blkid exits with 2 when it finds no filesystem on the device
+	FilesystemType   = "blkid -o value -s TYPE %s"
+	blkidNotFoundRet = 2
+)
+
+// AdoptPartition creates a DeviceVolume named volName for the existing
+// partition partNum of the disk, so that it can be used by a statically
+// provisioned PV. The disk has to carry the meta partition of the driver.
+// The data on the partition is left untouched, only the GPT name of the
+// partition is changed to the one the driver looks the volume up by.
+func AdoptPartition(disk string, partNum uint32, volName string) (*apis.DeviceVolume, error) {
+	if NodeID == "" {
+		return nil, errors.New("node id is not set, the partition has to be adopted from the node agent")
+	}
+	if err := validateAdoptedName(volName); err != nil {
+		return nil, err
+	}
+	if _, err := GetDeviceVolume(volName); err == nil {
+		return nil, errors.Errorf("volume %s already exists", volName)
+	} else if !k8serror.IsNotFound(err) {
+		return nil, err
+	}
+
+	unlock := lockDisks([]string{disk})
+	defer unlock()
+
+	if err := activePartitioner.verify(disk); err != nil {
+		return nil, err
+	}
+	rows, err := GetPartitionList(disk, "", false)
+	if err != nil {
+		return nil, err
+	}
+	diskMetaName, part, err := findAdoptablePartition(disk, rows, partNum)
+	if err != nil {
+		return nil, err
+	}
+
+	partitionName := volName[4:]
+	if part.Name != partitionName {
+		if err := checkPartitionUnmanaged(diskMetaName, part, partitionName); err != nil {
+			return nil, err
+		}
+		if err := activePartitioner.setName(disk, partNum, partitionName); err != nil {
+			return nil, errors.Wrapf(err, "could not name partition %d of disk %s", partNum, disk)
+		}
+	}
+
+	fsType, err := getFilesystemType(part.DevicePath)
+	if err != nil {
+		return nil, err
+	}
+
+	size := strconv.FormatUint(part.Size, 10)
+	vol, err := volbuilder.NewBuilder().
+		WithName(volName).
+		WithCapacity(size).
+		WithDeviceName(diskMetaName).
+		WithOwnerNode(NodeID).
+		WithFsType(fsType).
+		WithLabels(map[string]string{DeviceNodeKey: NodeID}).
+		WithAnnotations(map[string]string{AdoptedKey: "true"}).
+		WithFinalizer([]string{DeviceFinalizer}).
+		WithVolumeStatus(DeviceStatusReady).Build()
+	if err != nil {
+		return nil, err
+	}
+	vol.Status.Capacity = size
+	setDiskUUID(vol, disk)
+
+	vol, err = ProvisionVolume(vol)
+	if err != nil {
+		return nil, err
+	}
+	klog.Infof("adopted partition %d of disk %s as volume %s with filesystem %q", partNum, disk, volName, fsType)
+	return vol, nil
+}
+
+// validateAdoptedName checks that the volume name has the form of the
+// names of the provisioned volumes, as the partition is named after it.
+func validateAdoptedName(volName string) error {
+	if !strings.HasPrefix(volName, "pvc-") || len(volName) == len("pvc-") {
+		return errors.Errorf("invalid volume name %q, it must start with pvc-", volName)
+	}
+	if isReservePart(volName[4:]) {
+		return errors.Errorf("invalid volume name %q, it must not end with %s", volName, ReservePartitionSuffix)
+	}
+	return nil
+}
+
+// findAdoptablePartition returns the device name of the disk and the
+// partition partNum found in its parted print output.
+func findAdoptablePartition(disk string, rows [][]string, partNum uint32) (string, PartUsed, error) {
+	if len(rows) == 0 {
+		return "", PartUsed{}, errors.Errorf("disk %s has no partitions", disk)
+	}
+	diskMetaName, ok := getMetaPartition(rows[0])
+	if !ok {
+		return "", PartUsed{}, errors.Errorf("disk %s has no meta partition, it is not managed by the driver", disk)
+	}
+	if partNum == 1 {
+		return "", PartUsed{}, errors.Errorf("partition 1 of disk %s is the meta partition", disk)
+	}
+	for _, row := range rows {
+		if row[0] != strconv.FormatUint(uint64(partNum), 10) {
+			continue
+		}
+		// unnamed partitions without a filesystem have no name column
+		if len(row) == 4 {
+			row = append(row, "")
+		}
+		part, err := parsePartUsed(disk, row)
+		return diskMetaName, part, err
+	}
+	return "", PartUsed{}, errors.Errorf("partition %d does not exist on disk %s", partNum, disk)
+}
+
+// checkPartitionUnmanaged fails if the partition already belongs to a
+// volume or another partition with the device name is named partitionName.
+func checkPartitionUnmanaged(diskMetaName string, part PartUsed, partitionName string) error {
+	if isReservePart(part.Name) {
+		return errors.Errorf("partition %d of disk %s is a growth reserve", part.PartNum, part.DiskName)
+	}
+	if part.Name != "" {
+		if _, err := GetDeviceVolume(part.GetPVName()); err == nil {
+			return errors.Errorf("partition %d of disk %s is already managed as volume %s",
+				part.PartNum, part.DiskName, part.GetPVName())
+		} else if !k8serror.IsNotFound(err) {
+			return err
+		}
+	}
+	pList, err := getAllPartsUsed(diskMetaName, partitionName)
+	if err != nil {
+		return err
+	}
+	if len(pList) != 0 {
+		return errors.Errorf("partition %d of disk %s is already named %s",
+			pList[0].PartNum, pList[0].DiskName, partitionName)
+	}
+	return nil
+}
+
+// getFilesystemType returns the type of the filesystem on the device, or
+// empty if the device holds no filesystem.
+func getFilesystemType(devicePath string) (string, error) {
+	out, code, err := runCommand(context.Background(), strings.Split(fmt.Sprintf(FilesystemType, devicePath), " "), nil, limits.timeout)
+	if code == blkidNotFoundRet {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "could not detect filesystem of %s", devicePath)
+	}
+	return strings.TrimSpace(out), nil
+}
@@ -0,0 +1,81 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAdoptedName(t *testing.T) {
+	assert.NoError(t, validateAdoptedName("pvc-data"))
+	assert.Error(t, validateAdoptedName("data"))
+	assert.Error(t, validateAdoptedName("pvc-"))
+	assert.Error(t, validateAdoptedName("pvc-data"+ReservePartitionSuffix))
+}
+
+func TestFindAdoptablePartition(t *testing.T) {
+	rows := [][]string{
+		{"1", "1048576B", "11534335B", "10485760B", "disk1"},
+		{"2", "11534336B", "1085276159B", "1073741824B", "ext4", "data"},
+		{"3", "1085276160B", "2159017983B", "1073741824B"},
+	}
+	unmanaged := [][]string{
+		{"1", "1048576B", "1073741823B", "1072693248B", "ext4", "data"},
+	}
+
+	tests := []struct {
+		name     string
+		rows     [][]string
+		partNum  uint32
+		wantMeta string
+		wantPart PartUsed
+		wantErr  bool
+	}{
+		{
+			name:     "named partition",
+			rows:     rows,
+			partNum:  2,
+			wantMeta: "disk1",
+			wantPart: PartUsed{DiskName: "sdb", PartNum: 2, Name: "data", DevicePath: "/dev/sdb2", Size: 1073741824},
+		},
+		{
+			name:     "unnamed partition without filesystem",
+			rows:     rows,
+			partNum:  3,
+			wantMeta: "disk1",
+			wantPart: PartUsed{DiskName: "sdb", PartNum: 3, DevicePath: "/dev/sdb3", Size: 1073741824},
+		},
+		{name: "meta partition", rows: rows, partNum: 1, wantErr: true},
+		{name: "missing partition", rows: rows, partNum: 4, wantErr: true},
+		{name: "disk without meta partition", rows: unmanaged, partNum: 1, wantErr: true},
+		{name: "disk without partitions", partNum: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, part, err := findAdoptablePartition("sdb", tt.rows, tt.partNum)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantMeta, meta)
+			assert.Equal(t, tt.wantPart, part)
+		})
+	}
+}
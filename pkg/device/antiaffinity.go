@@ -0,0 +1,121 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// Anti-affinity policies, applied when only the disks holding the other
+// volumes of the group of a volume have room for its partition
+const (
+	// AntiAffinityStrict fails the creation of the volume.
+	AntiAffinityStrict = "strict"
+	// AntiAffinityRelaxed places the partition on the disks of the group.
+	AntiAffinityRelaxed = "relaxed"
+)
+
+// antiAffinity holds the disks to keep the partition of a volume off.
+type antiAffinity struct {
+	group  string
+	strict bool
+	// disks are the identifiers of the disks holding the partitions of
+	// the other volumes of the group on the node.
+	disks map[string]bool
+}
+
+// getAntiAffinity returns the disks to keep the partition of the volume
+// off, nil if the volume has no anti-affinity group.
+func getAntiAffinity(vol *apis.DeviceVolume) (*antiAffinity, error) {
+	if vol.Spec.AntiAffinityGroup == "" {
+		return nil, nil
+	}
+	volumes, err := ListDeviceVolumes()
+	if err != nil {
+		return nil, errors.Wrapf(err, "list volumes of anti-affinity group %s", vol.Spec.AntiAffinityGroup)
+	}
+	return newAntiAffinity(vol, volumes.Items), nil
+}
+
+// newAntiAffinity collects the disks of the volumes of the group of the
+// volume on its node. The volumes being deleted and the ones which have no
+// partition yet are left out.
+func newAntiAffinity(vol *apis.DeviceVolume, volumes []apis.DeviceVolume) *antiAffinity {
+	avoid := &antiAffinity{
+		group:  vol.Spec.AntiAffinityGroup,
+		strict: vol.Spec.AntiAffinityPolicy != AntiAffinityRelaxed,
+		disks:  map[string]bool{},
+	}
+	for _, peer := range volumes {
+		if peer.Name == vol.Name ||
+			peer.Spec.AntiAffinityGroup != avoid.group ||
+			peer.Spec.OwnerNodeID != vol.Spec.OwnerNodeID ||
+			peer.DeletionTimestamp != nil ||
+			peer.Status.DiskUUID == "" {
+			continue
+		}
+		avoid.disks[peer.Status.DiskUUID] = true
+	}
+	return avoid
+}
+
+// filter leaves out the free regions on the disks of the group, recording
+// them as rejected in disks, as long as another disk has room for a
+// partition of partSize MiB. diskID returns the identifier of a disk.
+// Otherwise the strict policy leaves out the regions anyway, so that the
+// allocation fails, while the relaxed policy keeps them all.
+func (a *antiAffinity) filter(pList []partFree, disks map[string]string,
+	diskID func(string) string, partSize uint64) []partFree {
+	if a == nil || len(a.disks) == 0 {
+		return pList
+	}
+	avoided := map[string]bool{}
+	for disk, rejected := range disks {
+		if rejected == "" && a.disks[diskID(disk)] {
+			avoided[disk] = true
+		}
+	}
+	if len(avoided) == 0 {
+		return pList
+	}
+
+	var kept []partFree
+	for _, region := range pList {
+		if !avoided[region.DiskName] {
+			kept = append(kept, region)
+		}
+	}
+	if _, ok := selectFreeRegion(kept, partSize); !ok && !a.strict {
+		klog.Warningf("only the disks of anti-affinity group %s have room for %d MiB, relaxing the anti-affinity",
+			a.group, partSize)
+		return pList
+	}
+	for disk := range avoided {
+		disks[disk] = TraceRejectedAntiAffinity
+	}
+	return kept
+}
+
+// diskIdentifier returns the identifier of the disk, empty if it can't be
+// read.
+func diskIdentifier(disk string) string {
+	id, _ := getDiskIdentifier(disk)
+	return id
+}
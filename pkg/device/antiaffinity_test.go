@@ -0,0 +1,134 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func Test_newAntiAffinity(t *testing.T) {
+	volume := func(name, group, node, disk string) apis.DeviceVolume {
+		vol := apis.DeviceVolume{}
+		vol.Name = name
+		vol.Spec.AntiAffinityGroup = group
+		vol.Spec.OwnerNodeID = node
+		vol.Status.DiskUUID = disk
+		return vol
+	}
+	deleted := volume("pvc-deleted", "app=db", "node-1", "uuid-4")
+	deleted.DeletionTimestamp = &metav1.Time{}
+
+	vol := volume("pvc-new", "app=db", "node-1", "")
+	vol.Spec.AntiAffinityPolicy = AntiAffinityRelaxed
+	avoid := newAntiAffinity(&vol, []apis.DeviceVolume{
+		volume("pvc-new", "app=db", "node-1", "uuid-0"),
+		volume("pvc-1", "app=db", "node-1", "uuid-1"),
+		volume("pvc-2", "app=web", "node-1", "uuid-2"),
+		volume("pvc-3", "app=db", "node-2", "uuid-3"),
+		deleted,
+		volume("pvc-pending", "app=db", "node-1", ""),
+		volume("pvc-5", "app=db", "node-1", "uuid-5"),
+	})
+	want := map[string]bool{"uuid-1": true, "uuid-5": true}
+	if !reflect.DeepEqual(avoid.disks, want) {
+		t.Errorf("newAntiAffinity() disks = %v, want %v", avoid.disks, want)
+	}
+	if avoid.strict {
+		t.Errorf("newAntiAffinity() expected the relaxed policy")
+	}
+
+	vol.Spec.AntiAffinityPolicy = ""
+	if avoid = newAntiAffinity(&vol, nil); !avoid.strict {
+		t.Errorf("newAntiAffinity() expected the strict policy by default")
+	}
+}
+
+func Test_antiAffinityFilter(t *testing.T) {
+	pList := []partFree{
+		{"sdb", 2, 1002, 1000},
+		{"sdc", 2, 502, 500},
+		{"sdd", 2, 2002, 2000},
+	}
+	ids := map[string]string{"sdb": "uuid-b", "sdc": "uuid-c", "sdd": "uuid-d"}
+	diskID := func(disk string) string { return ids[disk] }
+
+	tests := []struct {
+		name     string
+		avoid    *antiAffinity
+		size     uint64
+		disks    []string
+		rejected []string
+	}{
+		{
+			name:  "no anti-affinity group",
+			avoid: nil, size: 100,
+			disks: []string{"sdb", "sdc", "sdd"},
+		},
+		{
+			name:  "no other volume of the group",
+			avoid: &antiAffinity{group: "app=db", strict: true, disks: map[string]bool{}}, size: 100,
+			disks: []string{"sdb", "sdc", "sdd"},
+		},
+		{
+			name:  "disks of the group avoided",
+			avoid: &antiAffinity{group: "app=db", strict: true, disks: map[string]bool{"uuid-b": true, "uuid-d": true}}, size: 100,
+			disks: []string{"sdc"}, rejected: []string{"sdb", "sdd"},
+		},
+		{
+			name:  "strict without room off the group",
+			avoid: &antiAffinity{group: "app=db", strict: true, disks: map[string]bool{"uuid-d": true}}, size: 1500,
+			disks: []string{"sdb", "sdc"}, rejected: []string{"sdd"},
+		},
+		{
+			name:  "relaxed without room off the group",
+			avoid: &antiAffinity{group: "app=db", strict: false, disks: map[string]bool{"uuid-d": true}}, size: 1500,
+			disks: []string{"sdb", "sdc", "sdd"},
+		},
+		{
+			name:  "relaxed with room off the group",
+			avoid: &antiAffinity{group: "app=db", strict: false, disks: map[string]bool{"uuid-d": true}}, size: 800,
+			disks: []string{"sdb", "sdc"}, rejected: []string{"sdd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disks := map[string]string{"sdb": "", "sdc": "", "sdd": "", "sde": TraceRejectedDevName}
+			got := tt.avoid.filter(pList, disks, diskID, tt.size)
+			var gotDisks []string
+			for _, region := range got {
+				gotDisks = append(gotDisks, region.DiskName)
+			}
+			if !reflect.DeepEqual(gotDisks, tt.disks) {
+				t.Errorf("filter() kept %v, want %v", gotDisks, tt.disks)
+			}
+			var rejected []string
+			for _, disk := range []string{"sdb", "sdc", "sdd"} {
+				if disks[disk] == TraceRejectedAntiAffinity {
+					rejected = append(rejected, disk)
+				}
+			}
+			if !reflect.DeepEqual(rejected, tt.rejected) {
+				t.Errorf("filter() rejected %v, want %v", rejected, tt.rejected)
+			}
+		})
+	}
+}
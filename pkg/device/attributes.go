@@ -0,0 +1,152 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// Mutable attributes of the volumes, which can be modified after the
+// volume got created without recreating its partition.
+const (
+	AttributePartitionType         = "partitionType"
+	AttributeReservedBlocksPercent = "reservedBlocksPercent"
+)
+
+// getAttributes returns the mutable attributes set in the spec of the
+// volume.
+func getAttributes(vol *apis.DeviceVolume) map[string]string {
+	attrs := map[string]string{}
+	// the members of the striped volumes keep the Linux RAID type.
+	if vol.Spec.PartitionType != "" && !IsStripedVolume(vol) {
+		attrs[AttributePartitionType] = vol.Spec.PartitionType
+	}
+	if vol.Spec.ReservedBlocksPercent != "" {
+		attrs[AttributeReservedBlocksPercent] = vol.Spec.ReservedBlocksPercent
+	}
+	return attrs
+}
+
+// pendingAttributes returns the mutable attributes of the spec of the
+// volume which are not applied to its partition yet.
+func pendingAttributes(vol *apis.DeviceVolume) map[string]string {
+	pending := map[string]string{}
+	for key, value := range getAttributes(vol) {
+		if vol.Status.AppliedAttributes[key] != value {
+			pending[key] = value
+		}
+	}
+	return pending
+}
+
+// HasPendingAttributes checks if the volume has mutable attributes to be
+// applied to its partition.
+func HasPendingAttributes(vol *apis.DeviceVolume) bool {
+	return len(pendingAttributes(vol)) != 0
+}
+
+// SetAppliedAttributes records the mutable attributes of the spec as
+// applied, for the volumes whose partition got created with them.
+func SetAppliedAttributes(vol *apis.DeviceVolume) {
+	vol.Status.AppliedAttributes = getAttributes(vol)
+}
+
+// ApplyVolumeAttributes applies the mutable attributes modified after the
+// creation of the volume to its partition and records them in the status
+// of the volume. The data on the partition is not touched.
+func ApplyVolumeAttributes(vol *apis.DeviceVolume) error {
+	pending := pendingAttributes(vol)
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var err error
+	if IsStripedVolume(vol) {
+		var devicePath string
+		if devicePath, err = GetVolumeDevPath(vol); err == nil {
+			err = applyFilesystemAttributes(devicePath, pending)
+		}
+	} else {
+		err = applyPartitionAttributes(vol, pending)
+	}
+	if err != nil {
+		return err
+	}
+
+	if vol.Status.AppliedAttributes == nil {
+		vol.Status.AppliedAttributes = map[string]string{}
+	}
+	for key, value := range pending {
+		vol.Status.AppliedAttributes[key] = value
+	}
+	if err = UpdateVolume(vol); err != nil {
+		return err
+	}
+	klog.Infof("applied attributes %v to volume %s", pending, vol.Name)
+	return nil
+}
+
+// applyPartitionAttributes applies the pending attributes to the partition
+// of the volume.
+func applyPartitionAttributes(vol *apis.DeviceVolume, pending map[string]string) error {
+	pList, err := getAllPartsUsed(vol.Spec.DevName, vol.Name[4:])
+	if err != nil {
+		return err
+	}
+	if len(pList) != 1 {
+		return errors.Errorf("volume %s has %d partitions, expected 1", vol.Name, len(pList))
+	}
+	part := pList[0]
+
+	unlock := lockDisks([]string{part.DiskName})
+	defer unlock()
+
+	if partitionType, ok := pending[AttributePartitionType]; ok {
+		if err = activePartitioner.setType(part.DiskName, part.PartNum, partitionType); err != nil {
+			return errors.Wrapf(err, "could not set type of partition %d of disk %s", part.PartNum, part.DiskName)
+		}
+	}
+	return applyFilesystemAttributes(part.DevicePath, pending)
+}
+
+// applyFilesystemAttributes applies the pending attributes of the
+// filesystem on the device of the volume.
+func applyFilesystemAttributes(devicePath string, pending map[string]string) error {
+	percent, ok := pending[AttributeReservedBlocksPercent]
+	if !ok {
+		return nil
+	}
+	fsType, err := getFilesystemType(devicePath)
+	if err != nil {
+		return err
+	}
+	// the percentage is applied when the filesystem gets created, if
+	// the device is not formatted yet.
+	if fsType == "ext3" || fsType == "ext4" {
+		_, err = RunCommand(strings.Split(fmt.Sprintf(FilesystemReserve, percent, devicePath), " "))
+		if err != nil {
+			return errors.Wrapf(err, "could not reserve %s%% of the blocks of %s", percent, devicePath)
+		}
+	}
+	return nil
+}
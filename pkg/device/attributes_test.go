@@ -0,0 +1,76 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestPendingAttributes(t *testing.T) {
+	newVol := func(partitionType, percent string, applied map[string]string) *apis.DeviceVolume {
+		vol := &apis.DeviceVolume{}
+		vol.Spec.PartitionType = partitionType
+		vol.Spec.ReservedBlocksPercent = percent
+		vol.Status.AppliedAttributes = applied
+		return vol
+	}
+
+	tests := map[string]struct {
+		vol      *apis.DeviceVolume
+		expected map[string]string
+	}{
+		"no attributes": {vol: newVol("", "", nil), expected: map[string]string{}},
+		"all applied": {
+			vol: newVol("8300", "5", map[string]string{
+				AttributePartitionType: "8300", AttributeReservedBlocksPercent: "5",
+			}),
+			expected: map[string]string{},
+		},
+		"modified reserved blocks": {
+			vol: newVol("8300", "1", map[string]string{
+				AttributePartitionType: "8300", AttributeReservedBlocksPercent: "5",
+			}),
+			expected: map[string]string{AttributeReservedBlocksPercent: "1"},
+		},
+		"volume created before the attributes got recorded": {
+			vol:      newVol("8300", "", nil),
+			expected: map[string]string{AttributePartitionType: "8300"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, pendingAttributes(test.vol))
+			assert.Equal(t, len(test.expected) != 0, HasPendingAttributes(test.vol))
+		})
+	}
+}
+
+func TestSetAppliedAttributes(t *testing.T) {
+	vol := &apis.DeviceVolume{}
+	vol.Spec.PartitionType = "8300"
+	vol.Spec.ReservedBlocksPercent = "5"
+
+	SetAppliedAttributes(vol)
+	assert.False(t, HasPendingAttributes(vol))
+	assert.Equal(t, map[string]string{
+		AttributePartitionType: "8300", AttributeReservedBlocksPercent: "5",
+	}, vol.Status.AppliedAttributes)
+}
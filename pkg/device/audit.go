@@ -0,0 +1,272 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"golang.org/x/sys/unix"
+	"k8s.io/klog"
+)
+
+// Audited disk operations
+const (
+	AuditOperationCreate  = "create"
+	AuditOperationDelete  = "delete"
+	AuditOperationWipe    = "wipe"
+	AuditOperationRename  = "rename"
+	AuditOperationSetType = "settype"
+	AuditOperationRepair  = "repair"
+	// AuditOperationResizeTable grows the partition table of the disk.
+	AuditOperationResizeTable = "resizetable"
+)
+
+// Outcomes of the audited disk operations. A started record without an
+// outcome record means the node agent crashed while running the operation.
+const (
+	AuditOutcomeStarted   = "started"
+	AuditOutcomeSucceeded = "succeeded"
+	AuditOutcomeFailed    = "failed"
+)
+
+// sysfs attributes holding the world wide identifier of the disk, exposed
+// by nvme disks on the block device and by scsi disks on the device.
+const (
+	DiskWWIDPath       = "block/%s/wwid"
+	DiskDeviceWWIDPath = "block/%s/device/wwid"
+)
+
+// fsAppendFlag is the inode flag allowing the file to be opened for
+// appending only, see chattr(1).
+const fsAppendFlag = 0x20
+
+// AuditRecord records an operation modifying a disk. It only identifies the
+// disk, the partition and the volume, never the parameters of the volume.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	Node string    `json:"node"`
+	Disk string    `json:"disk"`
+	// WWN is the world wide identifier of the disk, empty if the disk
+	// doesn't expose it, e.g. virtual disks.
+	WWN       string `json:"wwn,omitempty"`
+	Operation string `json:"operation"`
+	// Partition is the number of the partition, zero for the operations on
+	// the whole disk and for the partitions yet to be created.
+	Partition     uint32 `json:"partition,omitempty"`
+	PartitionName string `json:"partitionName,omitempty"`
+	// Volume is the volume the partition belongs to, empty for the
+	// partitions not belonging to a volume, e.g. the meta partition.
+	Volume  string `json:"volume,omitempty"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// auditLog appends the records to a file as json lines, syncing the file
+// after every record.
+type auditLog struct {
+	mtx  sync.Mutex
+	file *os.File
+	now  func() time.Time
+}
+
+// audit is the audit log in use, nil if auditing is disabled.
+var audit *auditLog
+
+// SetAuditLog records the operations modifying the disks in the file at
+// path, which is created if needed. The file is made append-only where the
+// filesystem and the capabilities of the node agent allow it. Empty path
+// disables the audit log.
+func SetAuditLog(path string) error {
+	if path == "" {
+		audit = nil
+		return nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "could not open audit log %s", path)
+	}
+	if err = setAppendOnly(file); err != nil {
+		klog.Warningf("could not make audit log %s append-only: %v", path, err)
+	}
+	audit = &auditLog{file: file, now: time.Now}
+	klog.Infof("recording the disk operations in audit log %s", path)
+	return nil
+}
+
+// setAppendOnly sets the append-only flag of the file, so that the records
+// can't be modified or truncated, even by root, till the flag is cleared.
+func setAppendOnly(file *os.File) error {
+	fd := int(file.Fd())
+	flags, err := unix.IoctlGetInt(fd, unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		return err
+	}
+	if flags&fsAppendFlag != 0 {
+		return nil
+	}
+	return unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, flags|fsAppendFlag)
+}
+
+// write appends the record to the audit log and syncs it to the disk.
+func (l *auditLog) write(rec AuditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if _, err = l.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// auditOperation runs the operation on the disk, recording it in the audit
+// log before and after running it. The operation is not run if it can't be
+// recorded, and it fails if its outcome can't be recorded.
+func auditOperation(operation, disk string, partNum uint32, partitionName string, run func() error) error {
+	l := audit
+	if l == nil {
+		return run()
+	}
+	rec := AuditRecord{
+		Time:          l.now(),
+		Node:          NodeID,
+		Disk:          disk,
+		WWN:           getDiskWWN(disk),
+		Operation:     operation,
+		Partition:     partNum,
+		PartitionName: partitionName,
+		Volume:        getPartitionVolume(partitionName),
+		Outcome:       AuditOutcomeStarted,
+	}
+	if err := l.write(rec); err != nil {
+		return errors.Wrapf(err, "could not record %s of partition %s on disk %s in the audit log",
+			operation, partitionName, disk)
+	}
+
+	err := run()
+	rec.Time = l.now()
+	rec.Outcome = AuditOutcomeSucceeded
+	if err != nil {
+		rec.Outcome = AuditOutcomeFailed
+		rec.Error = truncateOutput(err.Error())
+	}
+	if werr := l.write(rec); werr != nil {
+		klog.Errorf("could not record the outcome of %s of partition %s on disk %s in the audit log: %v",
+			operation, partitionName, disk, werr)
+		if err == nil {
+			err = errors.Wrapf(werr, "could not record %s of partition %s on disk %s in the audit log",
+				operation, partitionName, disk)
+		}
+	}
+	return err
+}
+
+// getPartitionVolume returns the volume the partition belongs to. The
+// partitions of the volumes are named after the volume without its pvc-
+// prefix, the placeholders holding their growth reserve and the members of
+// the striped volumes have a suffix. The driver doesn't modify the other
+// partitions, e.g. the meta partition.
+func getPartitionVolume(partitionName string) string {
+	name := strings.TrimSuffix(partitionName, ReservePartitionSuffix)
+	name = stripeMemberRegex.ReplaceAllString(name, "")
+	if name == "" {
+		return ""
+	}
+	return "pvc-" + name
+}
+
+// getDiskWWN reads the world wide identifier of the disk. It returns empty
+// string if the disk doesn't expose it.
+func getDiskWWN(disk string) string {
+	if wwid, ok := getMultipathWWID(sysBlockPath(), disk); ok {
+		return wwid
+	}
+	for _, path := range []string{DiskWWIDPath, DiskDeviceWWIDPath} {
+		out, err := ioutil.ReadFile(sysfsPath(path, disk))
+		if err == nil {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	return ""
+}
+
+// getPartitionName returns the name of the partition partNum of the disk,
+// empty if it can't be read.
+func getPartitionName(disk string, partNum uint32) string {
+	rows, err := GetPartitionList(disk, "", false)
+	if err != nil {
+		klog.Warningf("could not read the partitions of disk %s for the audit log: %v", disk, err)
+		return ""
+	}
+	for _, row := range rows {
+		part, err := parsePartUsed(disk, row)
+		if err == nil && part.PartNum == partNum {
+			return part.Name
+		}
+	}
+	return ""
+}
+
+// auditedPartitioner records the operations of the partitioner in the audit
+// log, if it is enabled.
+type auditedPartitioner struct {
+	partitioner
+}
+
+func (p auditedPartitioner) String() string { return fmt.Sprint(p.partitioner) }
+
+func (p auditedPartitioner) create(disk, name string, startMiB, endMiB uint64) error {
+	return auditOperation(AuditOperationCreate, disk, 0, name, func() error {
+		return p.partitioner.create(disk, name, startMiB, endMiB)
+	})
+}
+
+func (p auditedPartitioner) remove(disk string, partNum uint32) error {
+	return auditPartition(AuditOperationDelete, disk, partNum, func() error {
+		return p.partitioner.remove(disk, partNum)
+	})
+}
+
+func (p auditedPartitioner) setName(disk string, partNum uint32, name string) error {
+	return auditPartition(AuditOperationRename, disk, partNum, func() error {
+		return p.partitioner.setName(disk, partNum, name)
+	})
+}
+
+func (p auditedPartitioner) setType(disk string, partNum uint32, partitionType string) error {
+	return auditPartition(AuditOperationSetType, disk, partNum, func() error {
+		return p.partitioner.setType(disk, partNum, partitionType)
+	})
+}
+
+// auditPartition runs the operation on the partition partNum of the disk,
+// looking up the name of the partition only if the audit log is enabled.
+func auditPartition(operation, disk string, partNum uint32, run func() error) error {
+	if audit == nil {
+		return run()
+	}
+	return auditOperation(operation, disk, partNum, getPartitionName(disk, partNum), run)
+}
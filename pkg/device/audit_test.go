@@ -0,0 +1,163 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func readAuditRecords(t *testing.T, path string) []AuditRecord {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid audit record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func Test_auditOperation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	nodeID := NodeID
+	NodeID = "node1"
+	defer func() { NodeID = nodeID; audit = nil }()
+
+	path := filepath.Join(dir, "audit.log")
+	if err = SetAuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	// the append-only file can't be removed, even by root.
+	defer clearAppendOnly(t, path)
+	now := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	audit.now = func() time.Time { return now }
+
+	var ran int
+	if err = auditOperation(AuditOperationCreate, "sdb", 0, "7f1b", func() error {
+		ran++
+		return nil
+	}); err != nil {
+		t.Fatalf("auditOperation() unexpected error %v", err)
+	}
+	if err = auditOperation(AuditOperationDelete, "sdb", 3, "7f1b-reserve", func() error {
+		ran++
+		return errors.New("exit status 1")
+	}); err == nil {
+		t.Fatalf("auditOperation() expected the error of the operation")
+	}
+	if ran != 2 {
+		t.Errorf("expected the operations to run once each, ran %d times", ran)
+	}
+
+	base := AuditRecord{Time: now, Node: "node1", Disk: "sdb", Volume: "pvc-7f1b"}
+	created, deleted := base, base
+	created.Operation, created.PartitionName = AuditOperationCreate, "7f1b"
+	deleted.Operation, deleted.PartitionName, deleted.Partition = AuditOperationDelete, "7f1b-reserve", 3
+
+	want := []AuditRecord{created, created, deleted, deleted}
+	want[0].Outcome, want[1].Outcome = AuditOutcomeStarted, AuditOutcomeSucceeded
+	want[2].Outcome, want[3].Outcome, want[3].Error = AuditOutcomeStarted, AuditOutcomeFailed, "exit status 1"
+
+	got := readAuditRecords(t, path)
+	if len(got) != len(want) {
+		t.Fatalf("got %d audit records, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) {
+			t.Errorf("record %d: time = %v, want %v", i, got[i].Time, want[i].Time)
+		}
+		got[i].Time = want[i].Time
+		if got[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// the operation doesn't run if it can't be recorded
+	audit.file.Close()
+	if err = auditOperation(AuditOperationWipe, "sdb", 2, "7f1b", func() error {
+		ran++
+		return nil
+	}); err == nil {
+		t.Errorf("auditOperation() expected error for a failing audit log")
+	}
+	if ran != 2 {
+		t.Errorf("expected the operation not to run without its audit record")
+	}
+
+	// without the audit log the operation just runs
+	if err = SetAuditLog(""); err != nil {
+		t.Fatal(err)
+	}
+	if err = auditOperation(AuditOperationWipe, "sdb", 2, "7f1b", func() error {
+		ran++
+		return nil
+	}); err != nil || ran != 3 {
+		t.Errorf("auditOperation() without audit log, err %v, ran %d times", err, ran)
+	}
+}
+
+// clearAppendOnly clears the append-only flag of the file, if it got set.
+func clearAppendOnly(t *testing.T, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	flags, err := unix.IoctlGetInt(int(file.Fd()), unix.FS_IOC_GETFLAGS)
+	if err != nil || flags&fsAppendFlag == 0 {
+		return
+	}
+	if err = unix.IoctlSetPointerInt(int(file.Fd()), unix.FS_IOC_SETFLAGS, flags&^fsAppendFlag); err != nil {
+		t.Errorf("could not clear the append-only flag of %s: %v", path, err)
+	}
+}
+
+func Test_getPartitionVolume(t *testing.T) {
+	tests := map[string]string{
+		"7f1b":         "pvc-7f1b",
+		"7f1b-reserve": "pvc-7f1b",
+		"7f1b-m1":      "pvc-7f1b",
+		"":             "",
+	}
+	for name, want := range tests {
+		if got := getPartitionVolume(name); got != want {
+			t.Errorf("getPartitionVolume(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
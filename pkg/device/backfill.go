@@ -0,0 +1,80 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"strconv"
+
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// NeedsStatusBackfill checks if the volume was created by a release
+// recording less of its partition in the status than the current one, i.e.
+// its capacity or the identifier of its disk is missing. The striped
+// volumes came along with these fields, so they always have them.
+func NeedsStatusBackfill(vol *apis.DeviceVolume) bool {
+	if vol.Status.State != DeviceStatusReady || IsStripedVolume(vol) {
+		return false
+	}
+	return vol.Status.Capacity == "" || vol.Status.DiskUUID == ""
+}
+
+// BackfillVolumeStatus fills the status fields missing on the volume from
+// its partition on the disks. It returns false if the partition could not
+// be found, or if nothing was missing. It scans the partition tables of all
+// the disks of the node, so it is to be called sparingly.
+func BackfillVolumeStatus(vol *apis.DeviceVolume) (bool, error) {
+	pList, err := getAllPartsUsed(vol.Spec.DevName, vol.Name[4:])
+	if err != nil {
+		return false, err
+	}
+	if len(pList) != 1 {
+		klog.Warningf("Device LocalPV: found %d partitions for volume %s, not backfilling its status",
+			len(pList), vol.Name)
+		return false, nil
+	}
+	disk := pList[0].DiskName
+	var id string
+	if vol.Status.DiskUUID == "" {
+		if id, err = getDiskIdentifier(disk); err != nil {
+			klog.Warningf("could not get identifier of disk %s for volume %s: %v", disk, vol.Name, err)
+		}
+	}
+	return backfillVolumeStatus(vol, pList[0], id, getDiskWWN(disk)), nil
+}
+
+// backfillVolumeStatus fills the empty status fields of the volume from its
+// partition and the identifiers of its disk, keeping the recorded ones. It
+// returns true if any field got filled.
+func backfillVolumeStatus(vol *apis.DeviceVolume, part PartUsed, diskID, diskWWN string) bool {
+	var filled bool
+	if vol.Status.Capacity == "" {
+		vol.Status.Capacity = strconv.FormatUint(part.Size, 10)
+		filled = true
+	}
+	if vol.Status.DiskUUID == "" && diskID != "" {
+		vol.Status.DiskUUID = diskID
+		filled = true
+	}
+	if vol.Status.DiskWWN == "" && diskWWN != "" {
+		vol.Status.DiskWWN = diskWWN
+		filled = true
+	}
+	return filled
+}
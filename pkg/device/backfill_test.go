@@ -0,0 +1,98 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestNeedsStatusBackfill(t *testing.T) {
+	tests := map[string]struct {
+		spec   apis.VolumeInfo
+		status apis.VolStatus
+		want   bool
+	}{
+		"up to date": {
+			status: apis.VolStatus{State: DeviceStatusReady, Capacity: "1048576", DiskUUID: "uuid-1"},
+		},
+		"disk identifier missing": {
+			status: apis.VolStatus{State: DeviceStatusReady, Capacity: "1048576"},
+			want:   true,
+		},
+		"capacity missing": {
+			status: apis.VolStatus{State: DeviceStatusReady, DiskUUID: "uuid-1"},
+			want:   true,
+		},
+		"not created yet": {
+			status: apis.VolStatus{State: DeviceStatusPending},
+		},
+		"striped": {
+			spec:   apis.VolumeInfo{StripeCount: "2"},
+			status: apis.VolStatus{State: DeviceStatusReady},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			vol := &apis.DeviceVolume{Spec: tt.spec, Status: tt.status}
+			if got := NeedsStatusBackfill(vol); got != tt.want {
+				t.Errorf("NeedsStatusBackfill() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackfillVolumeStatus(t *testing.T) {
+	part := PartUsed{DiskName: "sdb", PartNum: 2, Name: "1234", Size: 2097152}
+	tests := map[string]struct {
+		status     apis.VolStatus
+		id, wwn    string
+		want       apis.VolStatus
+		wantFilled bool
+	}{
+		"older volume gets the new fields": {
+			status:     apis.VolStatus{State: DeviceStatusReady},
+			id:         "uuid-1",
+			wwn:        "naa.5000c500a1b2c3d4",
+			want:       apis.VolStatus{State: DeviceStatusReady, Capacity: "2097152", DiskUUID: "uuid-1", DiskWWN: "naa.5000c500a1b2c3d4"},
+			wantFilled: true,
+		},
+		"recorded fields are kept": {
+			status:     apis.VolStatus{State: DeviceStatusReady, Capacity: "1048576"},
+			id:         "uuid-1",
+			want:       apis.VolStatus{State: DeviceStatusReady, Capacity: "1048576", DiskUUID: "uuid-1"},
+			wantFilled: true,
+		},
+		"disk identifier unknown": {
+			status: apis.VolStatus{State: DeviceStatusReady, Capacity: "1048576"},
+			want:   apis.VolStatus{State: DeviceStatusReady, Capacity: "1048576"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			vol := &apis.DeviceVolume{Status: tt.status}
+			if got := backfillVolumeStatus(vol, part, tt.id, tt.wwn); got != tt.wantFilled {
+				t.Errorf("backfillVolumeStatus() = %v, want %v", got, tt.wantFilled)
+			}
+			if vol.Status.Capacity != tt.want.Capacity || vol.Status.DiskUUID != tt.want.DiskUUID ||
+				vol.Status.DiskWWN != tt.want.DiskWWN {
+				t.Errorf("status = %+v, want %+v", vol.Status, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// debugHistorySize is the number of the last allocation decisions and
+// reconciles kept for the debug endpoint.
+const debugHistorySize = 50
+
+// AllocationRecord describes a decision of the allocator, along with the
+// free regions it picked from.
+type AllocationRecord struct {
+	Time      time.Time `json:"time"`
+	Partition string    `json:"partition"`
+	DevName   string    `json:"devName"`
+	Placement string    `json:"placement"`
+	// FitTolerance is the tolerance in percent of the fit placement.
+	FitTolerance int        `json:"fitTolerance,omitempty"`
+	SizeMiB      uint64     `json:"sizeMiB"`
+	FreeRegions  []partFree `json:"freeRegions"`
+	// Disks maps the disks of the node to the reason they were left
+	// out, empty for the disks whose free regions are listed.
+	Disks           map[string]string `json:"disks,omitempty"`
+	PartitionCounts map[string]int    `json:"partitionCounts,omitempty"`
+	Disk            string            `json:"disk,omitempty"`
+	StartMiB        uint64            `json:"startMiB,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+// ReconcileRecord describes a reconcile of a controller.
+type ReconcileRecord struct {
+	Controller string        `json:"controller"`
+	Key        string        `json:"key"`
+	StartTime  time.Time     `json:"startTime"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// DiskState is the view of a disk as seen by the allocator.
+type DiskState struct {
+	Name        string     `json:"name"`
+	Size        uint64     `json:"size"`
+	MetaName    string     `json:"metaName,omitempty"`
+	Excluded    bool       `json:"excluded,omitempty"`
+	Partitions  []PartUsed `json:"partitions,omitempty"`
+	FreeRegions []partFree `json:"freeRegions,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// DebugState is a point-in-time dump of the state of the allocator on the
+// node, for explaining its decisions.
+type DebugState struct {
+	Time        time.Time          `json:"time"`
+	Disks       []DiskState        `json:"disks"`
+	Allocations []AllocationRecord `json:"allocations"`
+	Reconciles  []ReconcileRecord  `json:"reconciles"`
+}
+
+// records is a ring buffer of the last records of a kind.
+type records struct {
+	mtx   sync.Mutex
+	items []interface{}
+	next  int
+	full  bool
+}
+
+func newRecords(size int) *records {
+	return &records{items: make([]interface{}, size)}
+}
+
+func (r *records) add(item interface{}) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.items[r.next] = item
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the records, oldest first.
+func (r *records) list() []interface{} {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if !r.full {
+		return append([]interface{}{}, r.items[:r.next]...)
+	}
+	return append(append([]interface{}{}, r.items[r.next:]...), r.items[:r.next]...)
+}
+
+var (
+	allocations = newRecords(debugHistorySize)
+	reconciles  = newRecords(debugHistorySize)
+)
+
+func recordAllocation(rec AllocationRecord) {
+	allocations.add(rec)
+}
+
+// RecordReconcile records the outcome of a reconcile of the controller
+// started at the given time.
+func RecordReconcile(controller, key string, start time.Time, err error) {
+	rec := ReconcileRecord{
+		Controller: controller,
+		Key:        key,
+		StartTime:  start,
+		Duration:   time.Since(start),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	reconciles.add(rec)
+}
+
+// getDiskStates reads the partitions and the free regions of the disks the
+// same way the allocator does.
+func getDiskStates() ([]DiskState, error) {
+	diskList, err := getDiskList()
+	if err != nil {
+		return nil, err
+	}
+	var states []DiskState
+	for _, disk := range diskList {
+		state := DiskState{Name: disk.DiskName, Size: disk.Size}
+		tmpList, err := GetPartitionList(disk.DiskName, "", false)
+		if err != nil {
+			state.Error = err.Error()
+			states = append(states, state)
+			continue
+		}
+		if len(tmpList) == 0 {
+			states = append(states, state)
+			continue
+		}
+		metaName, ok := getMetaPartition(tmpList[0])
+		if !ok {
+			// not managed by the driver
+			states = append(states, state)
+			continue
+		}
+		state.MetaName = metaName
+		state.Excluded = isDiskExcluded(disk.DiskName)
+		for _, tmp := range tmpList[1:] {
+			if part, err := parsePartUsed(disk.DiskName, tmp); err == nil {
+				state.Partitions = append(state.Partitions, part)
+			}
+		}
+		if state.FreeRegions, err = getPartsFree(disk.DiskName, disk.Size, metaName); err != nil {
+			state.Error = err.Error()
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// GetDebugState returns the current state of the allocator along with the
+// last allocation decisions and reconciles.
+func GetDebugState() (*DebugState, error) {
+	disks, err := getDiskStates()
+	if err != nil {
+		return nil, err
+	}
+	state := &DebugState{Time: time.Now(), Disks: disks}
+	for _, item := range allocations.list() {
+		state.Allocations = append(state.Allocations, item.(AllocationRecord))
+	}
+	for _, item := range reconciles.list() {
+		state.Reconciles = append(state.Reconciles, item.(ReconcileRecord))
+	}
+	return state, nil
+}
+
+// DebugStateHandler serves the state of the allocator as json.
+func DebugStateHandler(w http.ResponseWriter, _ *http.Request) {
+	state, err := GetDebugState()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		klog.Errorf("Device LocalPV: could not encode debug state: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecords(t *testing.T) {
+	r := newRecords(3)
+	assert.Empty(t, r.list())
+
+	r.add(1)
+	r.add(2)
+	assert.Equal(t, []interface{}{1, 2}, r.list())
+
+	r.add(3)
+	assert.Equal(t, []interface{}{1, 2, 3}, r.list())
+
+	// the oldest records are dropped once the buffer is full
+	r.add(4)
+	r.add(5)
+	assert.Equal(t, []interface{}{3, 4, 5}, r.list())
+}
@@ -18,12 +18,14 @@ limitations under the License.
 
 import (
 	"fmt"
-	"math"
-	"os/exec"
+	"hash/fnv"
+	"io/ioutil"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/openebs/lib-csi/pkg/common/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -34,13 +36,66 @@ import (
 
 // Partition Commands
 const (
-	PartitionDiskID    = "fdisk -l /dev/%s"
-	PartitionDiskList  = "lsblk -b"
-	PartitionPrintFree = "parted /dev/%s unit b print free --script"
-	PartitionPrint     = "parted /dev/%s unit b print --script"
-	PartitionCreate    = "parted /dev/%s mkpart %s %dMiB %dMiB --script"
-	PartitionDelete    = "parted /dev/%s rm %d --script"
+	PartitionDiskID    = "fdisk -l %s"
+	PartitionDiskList  = "lsblk -J -b -d -o NAME,SIZE,TYPE"
+	PartitionPrintFree = "parted %s unit b print free --script"
+	PartitionPrint     = "parted %s unit b print --script"
+	PartitionCreate    = "parted %s mkpart %s %dMiB %dMiB --script"
+	PartitionDelete    = "parted %s rm %d --script"
 	PartitionWipeFS    = "wipefs --force -a %s"
+	PartitionSetType   = "sgdisk --typecode=%d:%s %s"
+)
+
+// Placement policies for picking the disk of a partition among the disks
+// having the same meta partition name
+const (
+	// PlacementBinpack picks the smallest free region that fits the
+	// partition, packing the partitions on as few disks as possible.
+	PlacementBinpack = "binpack"
+	// PlacementSpread picks the disk having the fewest partitions, so that
+	// the partitions get spread across the disks.
+	PlacementSpread = "spread"
+	// PlacementFit picks the smallest free region which fits the
+	// partition within a tolerance, falling back to the largest free
+	// region, so that the large regions are kept for the large partitions.
+	PlacementFit = "fit"
+)
+
+// ReservePartitionSuffix is appended to the partition name of a volume to
+// name the placeholder partition holding its growth reserve. The
+// placeholders got created by the earlier versions, they are only deleted
+// along with their volume now.
+const ReservePartitionSuffix = "-reserve"
+
+// DefaultPartitionType is the sgdisk type code of the Linux filesystem
+// partition type, which the partitioners set on the partitions they create.
+const DefaultPartitionType = "8300"
+
+// sysfs attributes of the disk, relative to SysRoot
+const (
+	DiskRotationalPath = "block/%s/queue/rotational"
+	DiskQueueDepthPath = "block/%s/queue/nr_requests"
+	// the firmware revision is exposed as firmware_rev by nvme disks and
+	// as rev by scsi disks.
+	DiskFirmwarePath = "block/%s/device/firmware_rev"
+	DiskRevisionPath = "block/%s/device/rev"
+)
+
+// Media types of the disk
+const (
+	MediaTypeSSD = "ssd"
+	MediaTypeHDD = "hdd"
+)
+
+// GPT layout constants used for free space accounting. The primary GPT
+// (protective MBR + header + 128 entries) occupies the first 34 sectors and
+// the backup GPT (entries + header) occupies the last 33 sectors of the disk.
+// Partitions are always created on MiB boundaries.
+const (
+	SectorSize              = 512
+	GPTPrimaryReservedBytes = 34 * SectorSize
+	GPTBackupReservedBytes  = 33 * SectorSize
+	PartitionAlignmentBytes = 1024 * 1024
 )
 
 // PartUsed represents disk partition created by device plugin.
@@ -81,12 +136,26 @@ func CreateVolume(vol *apis.DeviceVolume) error {
 	diskMetaName := vol.Spec.DevName
 	partitionName := vol.Name[4:]
 
-	capacityBytes, err := strconv.ParseInt(vol.Spec.Capacity, 10, 64)
+	unlock, err := lockMetaDisks(diskMetaName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	capacityBytes, err := strconv.ParseUint(vol.Spec.Capacity, 10, 64)
 	if err != nil {
 		klog.Warning("error parsing vol.Spec.Capacity. Skipping CreateVolume", err)
 		return err
 	}
-	capacityMiB := uint64(math.Floor(float64(capacityBytes) / (1024 * 1024)))
+	capacityMiB := getAllocationSizeMiB(capacityBytes)
+
+	if IsStripedVolume(vol) {
+		stripes, err := getStripeCount(vol)
+		if err != nil {
+			return err
+		}
+		return createStripedVolume(vol, diskMetaName, partitionName, capacityMiB, stripes)
+	}
 
 	pList, err := getAllPartsUsed(diskMetaName, partitionName)
 	if err != nil {
@@ -96,20 +165,85 @@ func CreateVolume(vol *apis.DeviceVolume) error {
 	if len(pList) > 0 {
 		klog.Infof("Partition %s already exist, Skipping creation", partitionName)
 		// Making Volume creation Idempotent
+		if err = setPartitionType(pList[0].DiskName, pList[0].PartNum, vol.Spec.PartitionType); err != nil {
+			return err
+		}
+		vol.Status.Capacity = strconv.FormatUint(pList[0].Size, 10)
+		setDiskUUID(vol, pList[0].DiskName)
 		return nil
 	}
-	disk, start, err := findBestPart(diskMetaName, capacityMiB)
+	if vol.Status.DiskUUID != "" {
+		// the partition was created earlier on a disk which is gone now,
+		// creating it again would hand out an empty partition in place of
+		// the volume data.
+		return errors.Errorf("disk %s holding the partition %s is missing, the partition is not created again",
+			vol.Status.DiskUUID, partitionName)
+	}
+	avoid, err := getAntiAffinity(vol)
+	if err != nil {
+		return err
+	}
+	budget, err := getSlotBudget(vol)
+	if err != nil {
+		return err
+	}
+	rec, err := findBestPart(diskMetaName, capacityMiB, vol.Spec.Placement, getFitTolerance(vol), avoid,
+		budget, partitionName)
 	if err != nil {
 		klog.Errorf("findBestPart Failed")
 		return err
 	}
-	return wipefsAndCreatePart(disk, start, partitionName, capacityMiB, diskMetaName)
+	disk, start := rec.Disk, rec.StartMiB
+	if err = activePartitioner.verify(disk); err != nil {
+		return err
+	}
+	if err = wipefsAndCreatePart(disk, start, partitionName, capacityMiB, diskMetaName, vol.Spec.PartitionType); err != nil {
+		return err
+	}
+	vol.Status.Capacity = strconv.FormatUint(capacityMiB*PartitionAlignmentBytes, 10)
+	setDiskUUID(vol, disk)
+	setAllocationTrace(vol, rec)
+	return nil
+}
+
+// isReservePart checks if the partition is a placeholder holding the growth
+// reserve of a volume.
+func isReservePart(partitionName string) bool {
+	return strings.HasSuffix(partitionName, ReservePartitionSuffix)
+}
+
+// setDiskUUID records the identifier of the disk holding the partition of
+// the volume, along with its world wide identifier if the disk exposes it.
+// Failing to get the identifier is not fatal, it only leaves the
+// replacement of the disk undetected.
+func setDiskUUID(vol *apis.DeviceVolume, disk string) {
+	vol.Status.DiskWWN = getDiskWWN(disk)
+	id, err := getDiskIdentifier(disk)
+	if err != nil {
+		klog.Warningf("could not get identifier of disk %s for volume %s: %v", disk, vol.Name, err)
+		return
+	}
+	vol.Status.DiskUUID = id
+}
+
+// roundUpToSector rounds up the given size in bytes to the sector boundary.
+func roundUpToSector(size uint64) uint64 {
+	return ((size + SectorSize - 1) / SectorSize) * SectorSize
+}
+
+// getAllocationSizeMiB returns the size in MiB of the partition that gets
+// allocated for the requested capacity. The capacity is rounded up to the
+// sector boundary first and then to the partition alignment, so the
+// allocated partition is never smaller than the request.
+func getAllocationSizeMiB(capacityBytes uint64) uint64 {
+	size := roundUpToSector(capacityBytes)
+	return (size + PartitionAlignmentBytes - 1) / PartitionAlignmentBytes
 }
 
 // DeletePart Todo
-func wipefsAndCreatePart(disk string, start uint64, partitionName string, size uint64, diskMetaName string) error {
+func wipefsAndCreatePart(disk string, start uint64, partitionName string, size uint64, diskMetaName string, partitionType string) error {
 	klog.Infof("Creating Partition %s %s", partitionName, diskMetaName)
-	_, err := RunCommand(strings.Split(fmt.Sprintf(PartitionCreate, disk, partitionName, start, start+size), " "))
+	err := activePartitioner.create(disk, partitionName, start, start+size)
 	if err != nil {
 		klog.Errorf("Create Partition failed %s", err)
 		return err
@@ -126,8 +260,11 @@ func wipefsAndCreatePart(disk string, start uint64, partitionName string, size u
 	}
 
 	err = wipeFsPartition(pList[0].DiskName, pList[0].PartNum)
+	if err == nil {
+		err = setPartitionType(pList[0].DiskName, pList[0].PartNum, partitionType)
+	}
 	if err != nil {
-		klog.Infof("Deleting partition %d on disk %s because wipefs failed", pList[0].PartNum, pList[0].DiskName)
+		klog.Infof("Deleting partition %d on disk %s because wipefs or setting the type failed", pList[0].PartNum, pList[0].DiskName)
 		err1 := deletePartition(pList[0].DiskName, pList[0].PartNum)
 		if err1 != nil {
 			klog.Errorf("could not delete partition %d on disk %s, created during CreateVolume(). Error: %s", pList[0].PartNum, pList[0].DiskName, err1)
@@ -139,46 +276,278 @@ func wipefsAndCreatePart(disk string, start uint64, partitionName string, size u
 	return nil
 }
 
+// setPartitionType sets the GPT partition type of the partition. The
+// partitions are created with the default type, so it is a
+// no-op for the default type.
+func setPartitionType(disk string, partNum uint32, partitionType string) error {
+	if partitionType == "" || strings.EqualFold(partitionType, DefaultPartitionType) {
+		return nil
+	}
+	err := activePartitioner.setType(disk, partNum, partitionType)
+	if err != nil {
+		klog.Errorf("Setting type %s of partition %d on disk %s failed %s", partitionType, partNum, disk, err)
+	}
+	return err
+}
+
 // getAllPartsFree Todo
+// excludedDevices holds the UUIDs of the disks not to be used for new
+// partitions, as requested in the DeviceNode spec.
+var excludedDevices = struct {
+	sync.RWMutex
+	uuids map[string]bool
+}{}
+
+// SetExcludedDevices sets the UUIDs of the disks not to be used for new
+// partitions. The existing partitions on them are left intact.
+func SetExcludedDevices(uuids []string) {
+	excluded := make(map[string]bool, len(uuids))
+	for _, uuid := range uuids {
+		excluded[uuid] = true
+	}
+	excludedDevices.Lock()
+	defer excludedDevices.Unlock()
+	excludedDevices.uuids = excluded
+}
+
+// isDiskExcluded checks if the disk is excluded from the new partitions.
+func isDiskExcluded(diskName string) bool {
+	excludedDevices.RLock()
+	defer excludedDevices.RUnlock()
+	if len(excludedDevices.uuids) == 0 {
+		return false
+	}
+	id, err := getDiskIdentifier(diskName)
+	if err != nil {
+		return false
+	}
+	return excludedDevices.uuids[id]
+}
+
 func getAllPartsFree(diskName string) ([]partFree, error) {
+	pList, _, err := getAllPartsFreeTraced(diskName)
+	return pList, err
+}
+
+// getAllPartsFreeTraced returns the free regions of the disks having the
+// meta partition name, along with every disk of the node mapped to the
+// reason it was left out, empty for the disks whose regions are listed.
+func getAllPartsFreeTraced(diskName string) ([]partFree, map[string]string, error) {
 	diskList, err := getDiskList()
 	if err != nil {
 		klog.Errorf("GetDiskList failed %s", err)
-		return nil, err
+		return nil, nil, err
 	}
-	var pList []partFree
+	var (
+		pList []partFree
+		probe *signatureProbe
+	)
+	disks := map[string]string{}
 	for _, disk := range diskList {
-		tmpList, err := getPartsFree(disk.DiskName, diskName)
+		if isDiskExcluded(disk.DiskName) {
+			klog.Infof("skipping disk %s excluded in the DeviceNode spec", disk.DiskName)
+			disks[disk.DiskName] = TraceRejectedExcluded
+			continue
+		}
+		if diskWeight(disk.DiskName) == 0 {
+			klog.Infof("skipping disk %s of weight zero", disk.DiskName)
+			disks[disk.DiskName] = TraceRejectedZeroWeight
+			continue
+		}
+		if isDiskFull(disk.DiskName) {
+			klog.Infof("skipping disk %s found full by the last discovery", disk.DiskName)
+			disks[disk.DiskName] = TraceRejectedFull
+			continue
+		}
+		if isDiskPendingReadiness(disk.DiskName) {
+			klog.Infof("skipping disk %s pending readiness", disk.DiskName)
+			disks[disk.DiskName] = TraceRejectedPendingReadiness
+			continue
+		}
+		tmpList, err := getPartsFree(disk.DiskName, disk.Size, diskName)
 		if err != nil {
 			klog.Infof("GetPart Error, %s", disk.DiskName)
+			disks[disk.DiskName] = TraceRejectedDevName
 			continue
 		}
+		if isDiskOfQuarantinedDevice(disk.DiskName) {
+			klog.Infof("skipping disk %s, its device is quarantined", disk.DiskName)
+			disks[disk.DiskName] = TraceRejectedQuarantined
+			continue
+		}
+		if !allowForeignSignatures {
+			if probe == nil {
+				probe = newSignatureProbe()
+			}
+			if sig, ok := probe.find(disk.DiskName); ok {
+				klog.Warningf("skipping disk %s, it carries an %s", disk.DiskName, sig)
+				disks[disk.DiskName] = TraceRejectedSignature
+				continue
+			}
+		}
+		disks[disk.DiskName] = ""
 		pList = append(pList, tmpList...)
 	}
-	return pList, nil
+	return pList, disks, nil
 }
 
-func findBestPart(diskName string, partSize uint64) (string, uint64, error) {
-	pList, err := getAllPartsFree(diskName)
+// findBestPart picks the free region for a partition of partSize MiB on
+// the disks having the meta partition name, as per the placement policy.
+// The fit tolerance in percent only applies to the fit placement, and the
+// disks of the anti-affinity group, if any, are avoided. The returned
+// record holds the picked disk and start of the region.
+func findBestPart(diskName string, partSize uint64, placement string, fitTolerance int,
+	avoid *antiAffinity, budget *slotBudget, partitionName string) (AllocationRecord, error) {
+	pList, disks, err := getAllPartsFreeTraced(diskName)
 	if err != nil {
 		klog.Errorln("Device LocalPV: GetAllPartsFree error")
-		return "", 0, err
+		return AllocationRecord{}, err
 	}
+	pList = avoid.filter(pList, disks, diskIdentifier, partSize)
+	pList = budget.filter(pList, disks, diskIdentifier)
 
-	if len(pList) > 0 {
-		sort.Slice(pList, func(i, j int) bool {
-			// "<" Ascending order
-			return pList[i].SizeMiB < pList[j].SizeMiB
-		})
-		for _, tmp := range pList {
-			if tmp.SizeMiB > partSize {
-				return tmp.DiskName, tmp.StartMiB, nil
-			}
+	var (
+		tmp partFree
+		ok  bool
+	)
+	rec := AllocationRecord{
+		Time:        time.Now(),
+		Partition:   partitionName,
+		DevName:     diskName,
+		Placement:   placement,
+		SizeMiB:     partSize,
+		FreeRegions: pList,
+		Disks:       disks,
+	}
+	// the weights narrow the disks down before the placement policy, the
+	// others show as outranked in the trace.
+	pList = weighDisks(pList, partSize)
+	// the hot disks are only used when the others have no room.
+	pList = avoidHotDisks(pList, partSize)
+	if placement == PlacementSpread {
+		counts, err := getPartitionCounts(diskName)
+		if err != nil {
+			klog.Errorln("Device LocalPV: GetPartitionCounts error")
+			rec.Error = err.Error()
+			recordAllocation(rec)
+			return rec, err
 		}
+		rec.PartitionCounts = counts
+		tmp, ok = selectSpreadRegion(pList, counts, partSize, partitionName)
+	} else if placement == PlacementFit {
+		rec.FitTolerance = fitTolerance
+		tmp, ok = selectFitRegion(pList, partSize, fitTolerance)
+	} else {
+		tmp, ok = selectFreeRegion(pList, partSize)
+	}
+	if ok {
+		rec.Disk, rec.StartMiB = tmp.DiskName, tmp.StartMiB
+		recordAllocation(rec)
+		return rec, nil
 	}
 	klog.Errorln("Device LocalPV: Free space for partition is not found")
-	return "", 0, err
+	err = errors.Errorf("no free region of %d MiB found", partSize)
+	if avoid != nil && avoid.strict && len(avoid.disks) > 0 {
+		err = errors.Errorf("no free region of %d MiB found off the disks of anti-affinity group %s",
+			partSize, avoid.group)
+	}
+	if budget != nil && len(budget.exhausted) > 0 {
+		err = errors.Errorf("no free region of %d MiB found off the disks %s where slot class %s used its budget of %d GPT partition entries",
+			partSize, strings.Join(budget.exhausted, ", "), budget.class, budget.limit)
+	}
+	rec.Error = err.Error()
+	recordAllocation(rec)
+	return rec, err
+}
+
+// selectFreeRegion picks the smallest free region which can hold a partition
+// of partSize MiB. Since the regions are already aligned and exclude the GPT
+// reserved areas, a region of exactly partSize MiB is a valid fit.
+func selectFreeRegion(pList []partFree, partSize uint64) (partFree, bool) {
+	sorted := make([]partFree, len(pList))
+	copy(sorted, pList)
+	sort.Slice(sorted, func(i, j int) bool {
+		// "<" Ascending order
+		return sorted[i].SizeMiB < sorted[j].SizeMiB
+	})
+	for _, tmp := range sorted {
+		if tmp.SizeMiB >= partSize {
+			return tmp, true
+		}
+	}
+	return partFree{}, false
+}
+
+// selectSpreadRegion picks the disk with the fewest partitions among the
+// disks having a free region that can hold a partition of partSize MiB,
+// preferring the disk with more free space when the counts are equal. The
+// remaining ties are broken by hashing the partition name with the disk
+// name, so that the choice is deterministic for a given partition but
+// differs across partitions. Within the picked disk the smallest fitting
+// region is used.
+func selectSpreadRegion(pList []partFree, counts map[string]int,
+	partSize uint64, partitionName string) (partFree, bool) {
+	diskFree := map[string]uint64{}
+	diskRegions := map[string][]partFree{}
+	for _, tmp := range pList {
+		diskFree[tmp.DiskName] += tmp.SizeMiB
+		diskRegions[tmp.DiskName] = append(diskRegions[tmp.DiskName], tmp)
+	}
 
+	var (
+		best     partFree
+		bestHash uint32
+		found    bool
+	)
+	for disk, regions := range diskRegions {
+		region, ok := selectFreeRegion(regions, partSize)
+		if !ok {
+			continue
+		}
+		h := fnv.New32a()
+		h.Write([]byte(partitionName + "/" + disk))
+		hash := h.Sum32()
+
+		if found {
+			if counts[disk] != counts[best.DiskName] {
+				if counts[disk] > counts[best.DiskName] {
+					continue
+				}
+			} else if diskFree[disk] != diskFree[best.DiskName] {
+				if diskFree[disk] < diskFree[best.DiskName] {
+					continue
+				}
+			} else if hash < bestHash || (hash == bestHash && disk > best.DiskName) {
+				continue
+			}
+		}
+		best, bestHash, found = region, hash, true
+	}
+	return best, found
+}
+
+// getPartitionCounts returns the number of volume partitions on each of the
+// disks having the given meta partition name.
+func getPartitionCounts(diskMetaName string) (map[string]int, error) {
+	diskList, err := getDiskList()
+	if err != nil {
+		klog.Errorf("GetDiskList failed %s", err)
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, disk := range diskList {
+		tmpList, err := GetPartitionList(disk.DiskName, diskMetaName, false)
+		if err != nil || len(tmpList) == 0 {
+			continue
+		}
+		// leave out the meta partition and the growth reserves
+		for _, tmp := range tmpList[1:] {
+			if !isReservePart(tmp[len(tmp)-1]) {
+				counts[disk.DiskName]++
+			}
+		}
+	}
+	return counts, nil
 }
 
 // GetAllPartsUsed Todo
@@ -243,6 +612,21 @@ func parsePartUsed(diskName string, row []string) (PartUsed, error) {
 func DestroyVolume(vol *apis.DeviceVolume) error {
 	diskMetaName := vol.Spec.DevName
 	partitionName := vol.Name[4:]
+
+	unlock, err := lockMetaDisks(diskMetaName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if IsStripedVolume(vol) {
+		stripes, err := getStripeCount(vol)
+		if err != nil {
+			return err
+		}
+		return destroyStripedVolume(vol, diskMetaName, partitionName, stripes)
+	}
+
 	pList, err := getAllPartsUsed(diskMetaName, partitionName)
 	if err != nil {
 		klog.Errorf("GetAllPartsUsed failed %s", err)
@@ -252,12 +636,36 @@ func DestroyVolume(vol *apis.DeviceVolume) error {
 		klog.Errorf("More than one partition of same name %s\n", partitionName)
 		return errors.New("More than one partition of same name")
 	}
-	if len(pList) == 0 {
+	if len(pList) == 1 {
+		if err = activePartitioner.verify(pList[0].DiskName); err != nil {
+			return err
+		}
+		if err = wipefsAndDeletePart(pList[0].DiskName, pList[0].PartNum); err != nil {
+			return err
+		}
+	} else {
 		klog.Infof("%s Partition not found, Skipping Deletion\n", partitionName)
-		return nil
 	}
-	return wipefsAndDeletePart(pList[0].DiskName, pList[0].PartNum)
+	return deleteReservePart(diskMetaName, partitionName)
+}
 
+// deleteReservePart deletes the placeholder partition holding the growth
+// reserve of the volume, if any.
+func deleteReservePart(diskMetaName string, partitionName string) error {
+	pList, err := getAllPartsUsed(diskMetaName, partitionName+ReservePartitionSuffix)
+	if err != nil {
+		klog.Errorf("GetAllPartsUsed failed %s", err)
+		return err
+	}
+	for _, part := range pList {
+		if err = activePartitioner.verify(part.DiskName); err != nil {
+			return err
+		}
+		if err = deletePartition(part.DiskName, part.PartNum); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func wipefsAndDeletePart(disk string, partNum uint32) error {
@@ -270,7 +678,7 @@ func wipefsAndDeletePart(disk string, partNum uint32) error {
 
 // deletes the given partition from the disk
 func deletePartition(disk string, partNum uint32) error {
-	_, err := RunCommand(strings.Split(fmt.Sprintf(PartitionDelete, disk, partNum), " "))
+	err := activePartitioner.remove(disk, partNum)
 	if err != nil {
 		klog.Errorf("Delete Partition failed for disk: %s, partition: %d . Error: %s", disk, partNum, err)
 	}
@@ -280,7 +688,14 @@ func deletePartition(disk string, partNum uint32) error {
 // performs a force wipefs on the given partition
 func wipeFsPartition(disk string, partNum uint32) error {
 	klog.Infof("Running WipeFS for disk: %s, partition %d", disk, partNum)
-	_, err := RunCommand(strings.Split(fmt.Sprintf(PartitionWipeFS, getPartitionPath(disk, partNum)), " "))
+	if err := checkPartitionExclusive(getPartitionPath(disk, partNum)); err != nil {
+		klog.Errorf("WipeFS skipped for disk: %s, partition: %d . Error: %s", disk, partNum, err)
+		return err
+	}
+	err := auditPartition(AuditOperationWipe, disk, partNum, func() error {
+		_, err := RunCommand(strings.Split(fmt.Sprintf(PartitionWipeFS, getPartitionPath(disk, partNum)), " "))
+		return err
+	})
 	if err != nil {
 		klog.Errorf("WipeFS failed for disk: %s, partition: %d . Error: %s", disk, partNum, err)
 	}
@@ -289,6 +704,9 @@ func wipeFsPartition(disk string, partNum uint32) error {
 
 // GetVolumeDevPath Todo
 func GetVolumeDevPath(vol *apis.DeviceVolume) (string, error) {
+	if IsStripedVolume(vol) {
+		return getStripedVolumeDevPath(vol)
+	}
 	diskMetaName := vol.Spec.DevName
 	partitionName := vol.Name[4:]
 	pList, err := getAllPartsUsed(diskMetaName, partitionName)
@@ -305,18 +723,11 @@ func GetVolumeDevPath(vol *apis.DeviceVolume) (string, error) {
 		return "", errors.New("Partition not found")
 	}
 
-	return getPartitionPath(pList[0].DiskName, pList[0].PartNum), nil
-}
-
-// RunCommand Todo
-func RunCommand(cList []string) (string, error) {
-	cmd := exec.Command(cList[0], cList[1:]...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		klog.Errorf("Device LocalPV: could not Run command %+v\n", cList)
+	devicePath := getPartitionPath(pList[0].DiskName, pList[0].PartNum)
+	if err = waitForPartitionDevice(pList[0].DiskName, devicePath, DeviceNodeTimeout); err != nil {
 		return "", err
 	}
-	return string(out), nil
+	return devicePath, nil
 }
 
 // GetPartitionList Todo
@@ -327,7 +738,7 @@ func GetPartitionList(diskName string, diskMetaName string, free bool) ([][]stri
 	} else {
 		command = PartitionPrint
 	}
-	out, err := RunCommand(strings.Split(fmt.Sprintf(command, diskName), " "))
+	out, err := RunCommand(strings.Split(fmt.Sprintf(command, devicePath(diskName)), " "))
 	if err != nil {
 		klog.Errorf("Device LocalPV: could not get parts error: %s %v\n", string(out), err)
 		return nil, err
@@ -364,72 +775,126 @@ func GetPartitionList(diskName string, diskMetaName string, free bool) ([][]stri
 	return result, nil
 }
 
-func getPartsFree(diskName string, diskMetaName string) ([]partFree, error) {
-	var pList []partFree
+// getPartsFree returns the free regions of the disk large enough to be
+// used.
+func getPartsFree(diskName string, diskSize uint64, diskMetaName string) ([]partFree, error) {
+	pList, err := listFreeRegions(diskName, diskSize, diskMetaName)
+	if err != nil {
+		return nil, err
+	}
+	return usableRegions(pList), nil
+}
+
+// listFreeRegions returns all of the free regions of the disk, including
+// the ones too small to be used.
+func listFreeRegions(diskName string, diskSize uint64, diskMetaName string) ([]partFree, error) {
 	tmpList, err := GetPartitionList(diskName, diskMetaName, true)
 	if err != nil {
 		klog.Infof("GetPart Error, %s %s", diskName, diskMetaName)
 		return nil, errors.New("GetPartitionList Error")
 	}
-	for _, tmp := range tmpList {
-		if tmp[3] == "Free" {
-			beginBytes, _ := strconv.ParseUint(string(tmp[0][:len(tmp[0])-1]), 10, 64)
-			endBytes, _ := strconv.ParseUint(string(tmp[1][:len(tmp[1])-1]), 10, 64)
-			beginMib := math.Ceil(float64(beginBytes) / 1024 / 1024)
-			endMib := math.Floor(float64(endBytes) / 1024 / 1024)
-			size := uint64(0)
-			if endMib > beginMib {
-				size = uint64(endMib - beginMib)
-			}
-			pList = append(pList, partFree{diskName, uint64(beginMib), uint64(endMib), size})
+	return parseFreeRegions(diskName, diskSize, diskProtectedBytes(diskName), tmpList), nil
+}
+
+// parseFreeRegions converts the free space rows of parted print free output
+// into the regions that can actually be handed out by the allocator.
+// for example:
+//
+//	$ parted /dev/sdc unit b print free --script
+//	....
+//	Number  Start      End           Size          File system  Name         Flags
+//	        17408B     1048575B      1031168B      Free Space
+//	 1      1048576B   10485759B     9437184B                   test-device
+//	        10485760B  17179852287B  17169366528B  Free Space
+//
+// Each free region is trimmed so that it never overlaps the protected
+// leading bytes of the disk, which cover at least the primary GPT, or the
+// backup GPT and both its ends lie on a partition alignment boundary. The
+// remaining size is what a partition created in the region can really use.
+func parseFreeRegions(diskName string, diskSize uint64, protectedBytes uint64, rows [][]string) []partFree {
+	if protectedBytes < GPTPrimaryReservedBytes {
+		protectedBytes = GPTPrimaryReservedBytes
+	}
+	var pList []partFree
+	for _, tmp := range rows {
+		if len(tmp) < 4 || tmp[3] != "Free" {
+			continue
+		}
+		beginBytes, err := strconv.ParseUint(strings.TrimSuffix(tmp[0], "B"), 10, 64)
+		if err != nil {
+			continue
+		}
+		endBytes, err := strconv.ParseUint(strings.TrimSuffix(tmp[1], "B"), 10, 64)
+		if err != nil {
+			continue
+		}
+		// parted reports the end of a region inclusively
+		endBytes++
+
+		if endBytes <= protectedBytes {
+			// the region lies entirely in the protected area
+			continue
 		}
+		if beginBytes < protectedBytes {
+			beginBytes = protectedBytes
+		}
+		if diskSize > GPTBackupReservedBytes && endBytes > diskSize-GPTBackupReservedBytes {
+			endBytes = diskSize - GPTBackupReservedBytes
+		}
+
+		beginMiB := (beginBytes + PartitionAlignmentBytes - 1) / PartitionAlignmentBytes
+		endMiB := endBytes / PartitionAlignmentBytes
+		size := uint64(0)
+		if endMiB > beginMiB {
+			size = endMiB - beginMiB
+		}
+		pList = append(pList, partFree{diskName, beginMiB, endMiB, size})
 	}
-	return pList, nil
+	return pList
+}
+
+// largestFreeRegion returns the size of the largest free region in MiB.
+func largestFreeRegion(pList []partFree) uint64 {
+	var largest uint64
+	for _, p := range pList {
+		if p.SizeMiB > largest {
+			largest = p.SizeMiB
+		}
+	}
+	return largest
 }
 
-// GetFreeCapacity Todo
-func GetFreeCapacity(diskName string) (uint64, error) {
-	pList, err := getPartsFree(diskName, "")
+// GetFreeCapacity returns the size of the largest partition in MiB that can
+// be created on the given disk, along with the total size in MiB of the
+// free regions too small to be used.
+func GetFreeCapacity(diskName string, diskSize uint64) (uint64, uint64, error) {
+	pList, err := listFreeRegions(diskName, diskSize, "")
 	if err != nil {
 		klog.Errorln("Device LocalPV: GetAllPartsFree error")
-		return 0, err
+		return 0, 0, err
 	}
-	if len(pList) > 0 {
-		sort.Slice(pList, func(i, j int) bool {
-			// ">" Descending order
-			return pList[i].SizeMiB > pList[j].SizeMiB
-		})
-		return pList[0].SizeMiB, nil
-	}
-	klog.Errorln("Device LocalPV: Free space for partition is not found")
-	return 0, err
+	return largestFreeRegion(usableRegions(pList)), strandedMiB(pList), nil
 }
 
-// GetDiskList Todo
+// getDiskList lists the disks using the active disk discovery backend.
 func getDiskList() ([]diskDetail, error) {
-	var result []diskDetail
-	out, err := RunCommand(strings.Split(fmt.Sprintf(PartitionDiskList), " "))
+	result, err := discoverer.listDisks()
 	if err != nil {
-		klog.Errorf("Device LocalPV: could not list disk error: %s %s", string(out), err)
+		klog.Errorf("Device LocalPV: could not list disk error: %s", err)
 		return nil, err
 	}
-	sli := strings.Split(string(out), "\n")
-	for _, value := range sli {
-		tmp := strings.Fields(value)
-		if len(tmp) == 0 {
-			continue
-		}
-		// loop is added here for testing purposes
-		if tmp[5] == "disk" || tmp[5] == "loop" {
-			tsize, _ := strconv.ParseUint(tmp[3], 10, 64)
-			result = append(result, diskDetail{tmp[0], tsize})
-		}
-	}
-	return result, nil
+	// the paths of the multipath devices are hidden behind the multipath
+	// device, partitioning a path directly would bypass the multipath.
+	return applyMultipath(result, listMultipathDevices(sysBlockPath())), nil
 }
 
+// getDiskIdentifier returns the identity of the disk, the WWID for the
+// multipath devices and the GPT disk identifier for the other disks.
 func getDiskIdentifier(disk string) (string, error) {
-	out, err := RunCommand(strings.Split(fmt.Sprintf(PartitionDiskID, disk), " "))
+	if wwid, ok := getMultipathWWID(sysBlockPath(), disk); ok {
+		return wwid, nil
+	}
+	out, err := RunCommand(strings.Split(fmt.Sprintf(PartitionDiskID, devicePath(disk)), " "))
 	if err != nil {
 		klog.Errorf("Device LocalPV: could not list disk error: %s %s", string(out), err)
 		return "", err
@@ -477,14 +942,94 @@ func getMetaPartition(tmp []string) (string, bool) {
 	return "", false
 }
 
+// getDiskMediaType detects the media type of the disk from the rotational
+// attribute exposed by the kernel. It returns empty string if the media type
+// could not be detected.
+func getDiskMediaType(diskName string) string {
+	out, err := ioutil.ReadFile(sysfsPath(DiskRotationalPath, diskName))
+	if err != nil {
+		klog.Warningf("Device LocalPV: could not read rotational attribute of %s: %v", diskName, err)
+		return ""
+	}
+	switch strings.TrimSpace(string(out)) {
+	case "0":
+		return MediaTypeSSD
+	case "1":
+		return MediaTypeHDD
+	}
+	return ""
+}
+
+// getDiskFirmware reads the firmware revision of the disk. It returns empty
+// string if the disk doesn't expose it, e.g. virtual disks.
+func getDiskFirmware(diskName string) string {
+	for _, path := range []string{DiskFirmwarePath, DiskRevisionPath} {
+		out, err := ioutil.ReadFile(sysfsPath(path, diskName))
+		if err == nil {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	klog.V(4).Infof("Device LocalPV: could not read firmware revision of %s", diskName)
+	return ""
+}
+
+// getDiskQueueDepth reads the number of requests the kernel queues for the
+// disk. It returns zero if it could not be read.
+func getDiskQueueDepth(diskName string) int32 {
+	out, err := ioutil.ReadFile(sysfsPath(DiskQueueDepthPath, diskName))
+	if err != nil {
+		klog.V(4).Infof("Device LocalPV: could not read queue depth of %s: %v", diskName, err)
+		return 0
+	}
+	depth, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 32)
+	if err != nil {
+		klog.V(4).Infof("Device LocalPV: invalid queue depth of %s: %v", diskName, err)
+		return 0
+	}
+	return int32(depth)
+}
+
+// getDiskUsed returns the size in bytes of the partitions of the volumes
+// on the disk, i.e. all its partitions but the meta partition.
+func getDiskUsed(diskName string) (uint64, error) {
+	tmpList, err := GetPartitionList(diskName, "", false)
+	if err != nil {
+		return 0, err
+	}
+	var used uint64
+	for i := 1; i < len(tmpList); i++ {
+		part, err := parsePartUsed(diskName, tmpList[i])
+		if err != nil {
+			return 0, err
+		}
+		used += part.Size
+	}
+	return used, nil
+}
+
 // GetDiskDetails Todo
 func GetDiskDetails() ([]apis.Device, error) {
-	var result []apis.Device
+	var (
+		result   []apis.Device
+		stranded uint64
+		probe    *signatureProbe
+	)
 	diskList, err := getDiskList()
 	if err != nil {
 		klog.Errorf("Device LocalPV: could not list disk error: %+v", err)
 		return nil, err
 	}
+	if !allowForeignSignatures {
+		probe = newSignatureProbe()
+	}
+	signed := map[string]ForeignSignature{}
+	defer func() { setSignedDevices(signed) }()
+	full := map[string]bool{}
+	defer func() { setFullDevices(full) }()
+	hot := map[string]bool{}
+	defer func() { setHotDevices(hot) }()
+	present := map[string]bool{}
+	defer func() { readiness.endDiscovery(present) }()
 	for _, diskIter := range diskList {
 		metaName, err := getDiskMetaName(diskIter.DiskName)
 		if err != nil {
@@ -496,17 +1041,64 @@ func GetDiskDetails() ([]apis.Device, error) {
 			klog.Errorf("Device LocalPV: getDiskIdentifier Failed %s", diskIter.DiskName)
 			continue
 		}
-		free, err := GetFreeCapacity(diskIter.DiskName)
+		present[id] = true
+		pending := readiness.observe(id, diskIter.DiskName)
+		if probe != nil {
+			if sig, ok := probe.find(diskIter.DiskName); ok {
+				klog.Warningf("Device LocalPV: leaving out disk %s (%s) of device %s, it carries an %s",
+					diskIter.DiskName, id, metaName, sig)
+				signed[id] = sig
+				continue
+			}
+		}
+		free, diskStranded, err := GetFreeCapacity(diskIter.DiskName, diskIter.Size)
 		if err != nil {
 			klog.Errorf("Device LocalPV: GetFreeCapacity Failed %s", diskIter.DiskName)
 			continue
 		}
-		result = append(result, apis.Device{metaName, id,
-			*resource.NewQuantity(int64(diskIter.Size), resource.DecimalSI),
-			*resource.NewQuantity(int64(free*1024*1024), resource.DecimalSI)})
+		stranded += diskStranded
+		used, err := getDiskUsed(diskIter.DiskName)
+		if err != nil {
+			klog.Errorf("Device LocalPV: getDiskUsed Failed %s", diskIter.DiskName)
+			continue
+		}
+		mediaType, mediaTypeSource := getMediaType(diskIter, id)
+		maxEntries, entries := getPartitionTableEntries(diskIter.DiskName)
+		if isFull(free) {
+			full[id] = true
+		}
+		temperature, _ := getDiskTemperature(diskIter.DiskName)
+		if isHot(temperature) {
+			hot[id] = true
+		}
+		result = append(result, apis.Device{
+			Name:                metaName,
+			UUID:                id,
+			Size:                *resource.NewQuantity(int64(diskIter.Size), resource.DecimalSI),
+			Free:                *resource.NewQuantity(int64(free*PartitionAlignmentBytes), resource.DecimalSI),
+			Used:                *resource.NewQuantity(int64(used), resource.DecimalSI),
+			Stranded:            *resource.NewQuantity(int64(diskStranded*PartitionAlignmentBytes), resource.DecimalSI),
+			EfficiencyPercent:   efficiencyPercent(int64(used), int64(diskStranded*PartitionAlignmentBytes)),
+			MediaType:           mediaType,
+			MediaTypeSource:     mediaTypeSource,
+			Firmware:            getDiskFirmware(diskIter.DiskName),
+			QueueDepth:          getDiskQueueDepth(diskIter.DiskName),
+			Temperature:         temperature,
+			Hot:                 hot[id],
+			PendingReadiness:    pending,
+			ProtectedBytes:      *resource.NewQuantity(int64(getProtectedBytes(id, metaName)), resource.BinarySI),
+			PartitionEntries:    entries,
+			MaxPartitionEntries: maxEntries,
+			Full:                full[id],
+		})
 	}
 
+	if stranded > 0 {
+		klog.Infof("Device LocalPV: %d MiB free in regions smaller than %d MiB, unusable till the disks are defragmented",
+			stranded, minFreeRegionMiB)
+	}
 	klog.Infof("%+v", result)
+	setDiscovered()
 	return result, nil
 }
 
@@ -536,6 +1128,9 @@ func ListPartUsed() ([]PartUsed, error) {
 			if err != nil {
 				return nil, fmt.Errorf("failed to parse parted output: %v", err)
 			}
+			if isReservePart(part.Name) {
+				continue
+			}
 			plist = append(plist, part)
 		}
 	}
@@ -544,10 +1139,13 @@ func ListPartUsed() ([]PartUsed, error) {
 
 // getPartitionPath gets the partition path from disk name and partition number.
 func getPartitionPath(diskName string, partNum uint32) string {
+	if path, ok := getMultipathPartitionPath(sysBlockPath(), diskName, partNum); ok {
+		return path
+	}
 	r := regexp.MustCompile(".+[0-9]+$")
 	// if the disk name ends in a number, then partition will be of the format /dev/nvme0n1p1
 	if r.MatchString(diskName) {
-		return fmt.Sprintf("/dev/%sp%d", diskName, partNum)
+		return devicePath(fmt.Sprintf("%sp%d", diskName, partNum))
 	}
-	return fmt.Sprintf("/dev/%s%d", diskName, partNum)
+	return devicePath(fmt.Sprintf("%s%d", diskName, partNum))
 }
@@ -17,6 +17,7 @@
 package device
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 )
@@ -98,3 +99,145 @@ func Test_parsePartUsed(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseFreeRegions(t *testing.T) {
+	// simulated 16GiB disk, fully laid out with a meta partition and two
+	// volume partitions, leaving a gap between them and a tail region.
+	const diskSize = 16 * 1024 * 1024 * 1024
+	rows := [][]string{
+		{"17408B", "1048575B", "1031168B", "Free", "Space"},
+		{"1", "1048576B", "10485759B", "9437184B", "test-device"},
+		{"2", "10485760B", "1084227583B", "1073741824B", "ext4", "5d8d56cb-e291-4dfd-81ac-fb664dd5ec75"},
+		{"1084227584B", "2147483647B", "1063256064B", "Free", "Space"},
+		{"3", "2147483648B", "16777215999B", "14629732352B", "ext4", "8a2d1f05-6c2a-4a5e-9e0f-0c43a8c58b5d"},
+		// older parted versions report the tail up to the last sector of
+		// the disk, which overlaps the backup GPT.
+		{"16777216000B", "17179869183B", "402653184B", "Free", "Space"},
+	}
+
+	pList := parseFreeRegions("sdc", diskSize, GPTPrimaryReservedBytes, rows)
+	want := []partFree{
+		{"sdc", 1, 1, 0},
+		{"sdc", 1034, 2048, 1014},
+		{"sdc", 16000, 16383, 383},
+	}
+	if !reflect.DeepEqual(pList, want) {
+		t.Fatalf("parseFreeRegions() got = %v, want %v", pList, want)
+	}
+
+	free := largestFreeRegion(pList)
+	if free != 1014 {
+		t.Fatalf("largestFreeRegion() got = %v, want %v", free, 1014)
+	}
+
+	// reported free space must be exactly what the allocator can hand out.
+	region, ok := selectFreeRegion(pList, free)
+	if !ok {
+		t.Fatalf("selectFreeRegion() could not fit reported free space %d MiB", free)
+	}
+	if region.StartMiB != 1034 || region.StartMiB+free > region.EndMiB {
+		t.Errorf("selectFreeRegion() got = %v, does not fit %d MiB", region, free)
+	}
+	if _, ok := selectFreeRegion(pList, free+1); ok {
+		t.Errorf("selectFreeRegion() fitted %d MiB, larger than reported free space", free+1)
+	}
+}
+
+func Test_getAllocationSizeMiB(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity uint64
+		sector   uint64
+		sizeMiB  uint64
+	}{
+		{name: "sub-sector request", capacity: 1, sector: SectorSize, sizeMiB: 1},
+		{name: "exact sector request", capacity: SectorSize, sector: SectorSize, sizeMiB: 1},
+		{name: "request spanning sectors", capacity: SectorSize + 1, sector: 2 * SectorSize, sizeMiB: 1},
+		{name: "exact MiB request", capacity: 4 * 1024 * 1024, sector: 4 * 1024 * 1024, sizeMiB: 4},
+		{name: "request crossing MiB boundary", capacity: 4*1024*1024 + 1, sector: 4*1024*1024 + SectorSize, sizeMiB: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := roundUpToSector(tt.capacity); got != tt.sector {
+				t.Errorf("roundUpToSector() got = %v, want %v", got, tt.sector)
+			}
+			if got := getAllocationSizeMiB(tt.capacity); got != tt.sizeMiB {
+				t.Errorf("getAllocationSizeMiB() got = %v, want %v", got, tt.sizeMiB)
+			}
+		})
+	}
+}
+
+func Test_selectSpreadRegion(t *testing.T) {
+	// three disks of different sizes, the smallest of them fills up
+	// before the others.
+	pList := []partFree{
+		{"sdb", 2, 2002, 2000},
+		{"sdc", 2, 1002, 1000},
+		{"sdd", 2, 302, 300},
+	}
+	counts := map[string]int{}
+
+	allocate := func(name string, size uint64) (string, bool) {
+		region, ok := selectSpreadRegion(pList, counts, size, name)
+		if !ok {
+			return "", false
+		}
+		for i := range pList {
+			if pList[i] == region {
+				pList[i].StartMiB += size
+				pList[i].SizeMiB -= size
+			}
+		}
+		counts[region.DiskName]++
+		return region.DiskName, true
+	}
+
+	// while all the disks have room, the volumes are spread evenly
+	for i := 0; i < 30; i++ {
+		if _, ok := allocate(fmt.Sprintf("vol-%d", i), 10); !ok {
+			t.Fatalf("allocation %d failed", i)
+		}
+	}
+	for _, disk := range []string{"sdb", "sdc", "sdd"} {
+		if counts[disk] != 10 {
+			t.Errorf("expected 10 volumes on %s, got %d", disk, counts[disk])
+		}
+	}
+
+	// sdd has 200 MiB left, a larger volume falls back to the other disks
+	disk, ok := allocate("vol-large", 500)
+	if !ok || disk == "sdd" {
+		t.Errorf("expected large volume on sdb or sdc, got %q", disk)
+	}
+
+	// the selection is deterministic for the same state
+	first, _ := selectSpreadRegion(pList, counts, 10, "vol-x")
+	second, _ := selectSpreadRegion(pList, counts, 10, "vol-x")
+	if first != second {
+		t.Errorf("expected same region, got %+v and %+v", first, second)
+	}
+
+	if _, ok := selectSpreadRegion(pList, counts, 5000, "vol-huge"); ok {
+		t.Errorf("expected no region for a volume larger than the disks")
+	}
+}
+
+func Test_isReservePart(t *testing.T) {
+	tests := []struct {
+		name    string
+		part    string
+		reserve bool
+	}{
+		{name: "volume partition", part: "5e2c7f3a-9a4b-4d3e-8d1a-1c0f6e3b2a11", reserve: false},
+		{name: "reserve partition", part: "5e2c7f3a-9a4b-4d3e-8d1a-1c0f6e3b2a11" + ReservePartitionSuffix, reserve: true},
+		{name: "meta partition", part: "test-device", reserve: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isReservePart(tt.part); got != tt.reserve {
+				t.Errorf("isReservePart() got = %v, want %v", got, tt.reserve)
+			}
+		})
+	}
+}
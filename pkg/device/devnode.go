@@ -0,0 +1,60 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"os"
+	"time"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// DeviceNodeTimeout bounds the wait for the device node of a partition to
+// appear after the partition is created.
+var DeviceNodeTimeout = 30 * time.Second
+
+// deviceNodePollInterval is the interval the device node of a partition is
+// checked at while waiting for it.
+const deviceNodePollInterval = 100 * time.Millisecond
+
+// waitForPartitionDevice waits till the device node of the partition of the
+// disk is present. The partition is created by the volume controller, which
+// marks the volume ready once the partition table is written, while udev
+// creates the device node asynchronously, so a volume staged right after
+// its creation could find no device. The disk is locked while checking, as
+// the partitioner rereads the partition table of the disk, which removes
+// and adds back the device nodes of all of its partitions.
+func waitForPartitionDevice(disk, devicePath string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		unlock := lockDisks([]string{disk})
+		_, err := os.Stat(devicePath)
+		unlock()
+		if err == nil {
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return errors.Wrapf(err, "check device %s", devicePath)
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("device %s of disk %s didn't appear within %v", devicePath, disk, timeout)
+		}
+		klog.V(4).Infof("waiting for device %s of disk %s", devicePath, disk)
+		time.Sleep(deviceNodePollInterval)
+	}
+}
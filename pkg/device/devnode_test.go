@@ -0,0 +1,60 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_waitForPartitionDevice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	devicePath := filepath.Join(dir, "sdz2")
+
+	// the volume controller creates the partition under the disk lock and
+	// udev adds its device node some time after, while the volume is
+	// staged right away.
+	created := make(chan time.Time, 1)
+	unlock := lockDisks([]string{"sdz"})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		unlock()
+		time.Sleep(200 * time.Millisecond)
+		created <- time.Now()
+		if err := ioutil.WriteFile(devicePath, nil, 0600); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if err := waitForPartitionDevice("sdz", devicePath, 5*time.Second); err != nil {
+		t.Fatalf("waitForPartitionDevice() unexpected error %v", err)
+	}
+	if returned := time.Now(); returned.Before(<-created) {
+		t.Errorf("waitForPartitionDevice() returned before the device was created")
+	}
+
+	if err := waitForPartitionDevice("sdz", filepath.Join(dir, "sdz3"), 300*time.Millisecond); err == nil {
+		t.Errorf("waitForPartitionDevice() expected error for a device not appearing")
+	}
+}
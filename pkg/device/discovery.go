@@ -0,0 +1,167 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// Disk discovery backends
+const (
+	// DiskDiscoveryAuto picks lsblk if it supports json output and
+	// falls back to sysfs otherwise.
+	DiskDiscoveryAuto  = "auto"
+	DiskDiscoveryLsblk = "lsblk"
+	DiskDiscoverySysfs = "sysfs"
+)
+
+// diskDiscoverer lists the disks present on the node.
+type diskDiscoverer interface {
+	name() string
+	listDisks() ([]diskDetail, error)
+}
+
+// discoverer is the disk discovery backend in use.
+var discoverer diskDiscoverer = lsblkDiscoverer{}
+
+// InitDiskDiscovery sets up the disk discovery backend. With auto, lsblk
+// is used if it is present and supports json output, else the disks are
+// read from sysfs.
+func InitDiskDiscovery(backend string) error {
+	switch backend {
+	case DiskDiscoveryLsblk:
+		discoverer = lsblkDiscoverer{}
+	case DiskDiscoverySysfs:
+		discoverer = sysfsDiscoverer{root: sysBlockPath()}
+	case DiskDiscoveryAuto, "":
+		discoverer = lsblkDiscoverer{}
+		if _, err := discoverer.listDisks(); err != nil {
+			klog.Warningf("Device LocalPV: lsblk disk discovery is not usable: %v", err)
+			discoverer = sysfsDiscoverer{root: sysBlockPath()}
+		}
+	default:
+		return errors.Errorf("invalid disk discovery backend %q", backend)
+	}
+	klog.Infof("Device LocalPV: using %s disk discovery", discoverer.name())
+	return nil
+}
+
+// discovered is set once the disks got discovered successfully.
+var discovered int32
+
+func setDiscovered() {
+	atomic.StoreInt32(&discovered, 1)
+}
+
+// IsDiscovered checks if the disks of the node got discovered successfully
+// at least once since the start of the node agent.
+func IsDiscovered() bool {
+	return atomic.LoadInt32(&discovered) == 1
+}
+
+// lsblkDiscoverer lists the disks using the json output of lsblk.
+type lsblkDiscoverer struct{}
+
+func (lsblkDiscoverer) name() string {
+	return DiskDiscoveryLsblk
+}
+
+func (lsblkDiscoverer) listDisks() ([]diskDetail, error) {
+	out, err := RunCommand(strings.Split(PartitionDiskList, " "))
+	if err != nil {
+		return nil, err
+	}
+	return parseLsblkOutput([]byte(out))
+}
+
+// parseLsblkOutput decodes the json output of lsblk. Older versions of
+// lsblk report the size as a string even with -b.
+func parseLsblkOutput(out []byte) ([]diskDetail, error) {
+	var output struct {
+		BlockDevices []struct {
+			Name string          `json:"name"`
+			Size json.RawMessage `json:"size"`
+			Type string          `json:"type"`
+		} `json:"blockdevices"`
+	}
+	if err := json.Unmarshal(out, &output); err != nil {
+		return nil, errors.Wrap(err, "failed to parse lsblk output")
+	}
+
+	var result []diskDetail
+	for _, dev := range output.BlockDevices {
+		// loop is added here for testing purposes
+		if dev.Type != "disk" && dev.Type != "loop" {
+			continue
+		}
+		size, err := strconv.ParseUint(strings.Trim(string(dev.Size), `"`), 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid size of disk %s", dev.Name)
+		}
+		result = append(result, diskDetail{dev.Name, size})
+	}
+	return result, nil
+}
+
+// sysfsDiscoverer lists the disks by reading the block devices in sysfs.
+type sysfsDiscoverer struct {
+	root string
+}
+
+func (sysfsDiscoverer) name() string {
+	return DiskDiscoverySysfs
+}
+
+func (s sysfsDiscoverer) listDisks() ([]diskDetail, error) {
+	entries, err := ioutil.ReadDir(s.root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", s.root)
+	}
+
+	var result []diskDetail
+	for _, entry := range entries {
+		name := entry.Name()
+		// only the devices backed by hardware have a device link, loop
+		// devices are added here for testing purposes
+		if _, err := os.Stat(filepath.Join(s.root, name, "device")); err != nil &&
+			!strings.HasPrefix(name, "loop") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.root, name, "size"))
+		if err != nil {
+			klog.Warningf("Device LocalPV: could not read size of disk %s: %v", name, err)
+			continue
+		}
+		sectors, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil || sectors == 0 {
+			continue
+		}
+		// sysfs reports the size in 512 byte sectors irrespective of
+		// the logical sector size of the disk
+		result = append(result, diskDetail{name, sectors * SectorSize})
+	}
+	return result, nil
+}
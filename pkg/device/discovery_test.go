@@ -0,0 +1,111 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_parseLsblkOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    []diskDetail
+		wantErr bool
+	}{
+		{
+			name: "numeric sizes",
+			out: `{"blockdevices": [
+				{"name": "sda", "size": 17179869184, "type": "disk"},
+				{"name": "sr0", "size": 1073741312, "type": "rom"},
+				{"name": "loop0", "size": 1048576, "type": "loop"}
+			]}`,
+			want: []diskDetail{{"sda", 17179869184}, {"loop0", 1048576}},
+		},
+		{
+			name: "string sizes of older lsblk",
+			out:  `{"blockdevices": [{"name": "sdb", "size": "10737418240", "type": "disk"}]}`,
+			want: []diskDetail{{"sdb", 10737418240}},
+		},
+		{
+			name:    "plain text output",
+			out:     "NAME SIZE TYPE\nsda 17179869184 disk\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLsblkOutput([]byte(tt.out))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLsblkOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLsblkOutput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sysfsDiscoverer(t *testing.T) {
+	root, err := ioutil.TempDir("", "sysblock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	addDev := func(name, size string, hasDevice bool) {
+		dir := filepath.Join(root, name)
+		if hasDevice {
+			if err := os.MkdirAll(filepath.Join(dir, "device"), 0755); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "size"), []byte(size+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addDev("sda", "33554432", true)
+	addDev("dm-0", "2048", false)
+	addDev("loop0", "2048", false)
+	addDev("loop1", "0", false)
+
+	got, err := sysfsDiscoverer{root: root}.listDisks()
+	if err != nil {
+		t.Fatalf("listDisks() error = %v", err)
+	}
+	want := []diskDetail{{"loop0", 1048576}, {"sda", 17179869184}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listDisks() = %v, want %v", got, want)
+	}
+}
+
+func Test_InitDiskDiscovery(t *testing.T) {
+	defer func() { discoverer = lsblkDiscoverer{} }()
+
+	if err := InitDiskDiscovery(DiskDiscoverySysfs); err != nil || discoverer.name() != DiskDiscoverySysfs {
+		t.Errorf("expected sysfs discovery, got %s, err %v", discoverer.name(), err)
+	}
+	if err := InitDiskDiscovery("udev"); err == nil {
+		t.Errorf("expected error for invalid backend")
+	}
+}
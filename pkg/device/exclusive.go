@@ -0,0 +1,151 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"golang.org/x/sys/unix"
+	"k8s.io/klog"
+)
+
+// exclusiveCheck enables opening the disks and partitions with O_EXCL
+// before modifying them, disabled unless set at startup.
+var exclusiveCheck bool
+
+// SetExclusiveCheck makes the partitioner open the disks, and the partitions
+// getting wiped, exclusively before modifying them, so that the devices held
+// by another subsystem, like a mounted filesystem, a md raid array or a LVM
+// physical volume, are not clobbered.
+func SetExclusiveCheck(enabled bool) {
+	exclusiveCheck = enabled
+	if enabled {
+		klog.Infof("Device LocalPV: opening the devices exclusively before modifying them")
+	}
+}
+
+// DeviceBusyError is returned when a disk or partition to be modified is
+// held by another subsystem.
+type DeviceBusyError struct {
+	Path string
+}
+
+func (e *DeviceBusyError) Error() string {
+	return fmt.Sprintf("device %s in use by another subsystem, e.g. a mounted filesystem, "+
+		"a md raid array or a LVM physical volume, not modifying it", e.Path)
+}
+
+// openExclusive opens the block device at path with O_EXCL and closes it.
+// The kernel fails the open with EBUSY if the device, or a partition of a
+// disk, is held by another subsystem.
+func openExclusive(path string) error {
+	f, err := os.OpenFile(path, os.O_RDONLY|unix.O_EXCL, 0)
+	if err != nil {
+		if pathErr, ok := err.(*os.PathError); ok {
+			return pathErr.Err
+		}
+		return err
+	}
+	return f.Close()
+}
+
+// checkExclusive checks that the disk at diskPath is not held by another
+// subsystem. A disk with a held partition, e.g. the mounted partition of a
+// volume, can't be opened exclusively either, so a busy disk is accepted if
+// any of its partitions listed by listParts is busy as well.
+func checkExclusive(diskPath string, listParts func() ([]string, error), open func(string) error) error {
+	err := open(diskPath)
+	if err == nil {
+		return nil
+	}
+	if err != unix.EBUSY {
+		return errors.Wrapf(err, "could not open %s exclusively", diskPath)
+	}
+	parts, err := listParts()
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if open(part) == unix.EBUSY {
+			return nil
+		}
+	}
+	return &DeviceBusyError{Path: diskPath}
+}
+
+// checkDiskExclusive checks that the disk is not held by another subsystem
+// before modifying its partition table, if the check is enabled.
+func checkDiskExclusive(disk string) error {
+	if !exclusiveCheck {
+		return nil
+	}
+	return checkExclusive(devicePath(disk), func() ([]string, error) {
+		rows, err := GetPartitionList(disk, "", false)
+		if err != nil {
+			return nil, err
+		}
+		var parts []string
+		for _, row := range rows {
+			partNum, err := strconv.ParseUint(row[0], 10, 32)
+			if err != nil {
+				continue
+			}
+			parts = append(parts, getPartitionPath(disk, uint32(partNum)))
+		}
+		return parts, nil
+	}, openExclusive)
+}
+
+// checkPartitionExclusive checks that the partition is not held by another
+// subsystem before wiping it, if the check is enabled.
+func checkPartitionExclusive(path string) error {
+	if !exclusiveCheck {
+		return nil
+	}
+	return checkPartitionNotHeld(path)
+}
+
+// checkPartitionNotHeld checks that the partition is not held by another
+// subsystem, e.g. mounted, whether the exclusive check is enabled or not.
+func checkPartitionNotHeld(path string) error {
+	switch err := openExclusive(path); err {
+	case nil:
+		return nil
+	case unix.EBUSY:
+		return &DeviceBusyError{Path: path}
+	default:
+		return errors.Wrapf(err, "could not open %s exclusively", path)
+	}
+}
+
+// exclusivePartitioner checks that the disks are not held by another
+// subsystem before modifying their partition tables.
+type exclusivePartitioner struct {
+	partitioner
+}
+
+func (p exclusivePartitioner) String() string { return fmt.Sprint(p.partitioner) }
+
+func (p exclusivePartitioner) verify(disk string) error {
+	if err := checkDiskExclusive(disk); err != nil {
+		return err
+	}
+	return p.partitioner.verify(disk)
+}
@@ -0,0 +1,67 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func Test_checkExclusive(t *testing.T) {
+	parts := func() ([]string, error) {
+		return []string{"/dev/sdb1", "/dev/sdb2", "/dev/sdb3"}, nil
+	}
+	tests := []struct {
+		name string
+		busy map[string]bool
+		// busyDisk denotes whether the error is a DeviceBusyError
+		busyDisk bool
+	}{
+		{name: "unused disk", busy: map[string]bool{}},
+		{
+			name: "mounted partition of a volume",
+			busy: map[string]bool{"/dev/sdb": true, "/dev/sdb3": true},
+		},
+		{
+			name:     "md raid member or LVM physical volume",
+			busy:     map[string]bool{"/dev/sdb": true},
+			busyDisk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open := func(path string) error {
+				if tt.busy[path] {
+					return unix.EBUSY
+				}
+				return nil
+			}
+			err := checkExclusive("/dev/sdb", parts, open)
+			_, isBusy := err.(*DeviceBusyError)
+			if isBusy != tt.busyDisk || (!tt.busyDisk && err != nil) {
+				t.Errorf("checkExclusive() = %v, want busy %v", err, tt.busyDisk)
+			}
+		})
+	}
+
+	// other errors of the open are returned as is.
+	err := checkExclusive("/dev/sdb", parts, func(string) error { return unix.ENOENT })
+	if _, isBusy := err.(*DeviceBusyError); err == nil || isBusy {
+		t.Errorf("checkExclusive() = %v, want the open error", err)
+	}
+}
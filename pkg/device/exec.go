@@ -0,0 +1,300 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// maxRecordedOutput is the maximum number of bytes of the command output
+// kept in the logs and the command history.
+const maxRecordedOutput = 512
+
+// CommandRecord describes an executed command.
+type CommandRecord struct {
+	Command   string        `json:"command"`
+	StartTime time.Time     `json:"startTime"`
+	Duration  time.Duration `json:"duration"`
+	ExitCode  int           `json:"exitCode"`
+	Output    string        `json:"output"`
+}
+
+// commandHistory is a ring buffer of the last executed commands.
+type commandHistory struct {
+	mtx     sync.Mutex
+	records []CommandRecord
+	next    int
+	full    bool
+	// size is the number of the last commands served as the command
+	// history, the buffer keeps more of them for the slow reconciles.
+	size int
+}
+
+// minCommandHistorySize is the number of the last executed commands kept
+// at least, for finding the slow disk operations of a reconcile, whatever
+// the size of the command history.
+const minCommandHistorySize = 64
+
+var history = &commandHistory{records: make([]CommandRecord, minCommandHistorySize)}
+
+// SetCommandHistorySize sets the number of the last executed commands kept
+// in the command history. Zero disables the history.
+func SetCommandHistorySize(size int) {
+	history.mtx.Lock()
+	defer history.mtx.Unlock()
+	kept := size
+	if kept < minCommandHistorySize {
+		kept = minCommandHistorySize
+	}
+	history.records = make([]CommandRecord, kept)
+	history.next, history.full = 0, false
+	history.size = size
+}
+
+func (h *commandHistory) add(rec CommandRecord) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.records[h.next] = rec
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// recent returns all of the kept commands, oldest first.
+func (h *commandHistory) recent() []CommandRecord {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if !h.full {
+		return append([]CommandRecord{}, h.records[:h.next]...)
+	}
+	return append(append([]CommandRecord{}, h.records[h.next:]...), h.records[:h.next]...)
+}
+
+// list returns the commands of the command history, oldest first.
+func (h *commandHistory) list() []CommandRecord {
+	records := h.recent()
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if len(records) > h.size {
+		records = records[len(records)-h.size:]
+	}
+	return records
+}
+
+// CommandHistoryHandler serves the command history as json.
+func CommandHistoryHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(history.list()); err != nil {
+		klog.Errorf("Device LocalPV: could not encode command history: %v", err)
+	}
+}
+
+// commandLimits bounds the external commands run by the process, so that
+// the discovery and the partitioning don't overwhelm a node shared with
+// other storage drivers.
+type commandLimits struct {
+	// slots holds a token for every running command, it is nil when the
+	// number of concurrent commands is not limited.
+	slots chan struct{}
+	// timeout is the time after which a command is killed, zero disables
+	// the timeout.
+	timeout time.Duration
+	// mutatingTimeout replaces the timeout for the commands modifying the
+	// disks, which are only killed as a last resort, as a partition table
+	// or a filesystem is left half written.
+	mutatingTimeout time.Duration
+}
+
+var limits = &commandLimits{}
+
+// SetCommandLimits sets the maximum number of external commands running
+// concurrently, the time after which a command is killed and the one
+// after which a command modifying the disks is killed. Zero disables the
+// respective limit. It must be called before running any command.
+func SetCommandLimits(maxConcurrent int, timeout, mutatingTimeout time.Duration) {
+	limits = newCommandLimits(maxConcurrent, timeout, mutatingTimeout)
+}
+
+func newCommandLimits(maxConcurrent int, timeout, mutatingTimeout time.Duration) *commandLimits {
+	l := &commandLimits{timeout: timeout, mutatingTimeout: mutatingTimeout}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	return l
+}
+
+// timeoutFor returns the time after which the command is killed.
+func (l *commandLimits) timeoutFor(cList []string) time.Duration {
+	if isMutatingCommand(cList) {
+		return l.mutatingTimeout
+	}
+	return l.timeout
+}
+
+// mutatingCommands are the programs modifying the partition tables, the
+// partitions or the filesystems of the disks.
+var mutatingCommands = map[string]bool{
+	"sgdisk":  true,
+	"parted":  true,
+	"wipefs":  true,
+	"dd":      true,
+	"mdadm":   true,
+	"tune2fs": true,
+	"e2fsck":  true,
+}
+
+// inspectionArgs are the arguments running the mutating programs read-only.
+var inspectionArgs = map[string]bool{
+	"print":     true,
+	"--print":   true,
+	"--verify":  true,
+	"--examine": true,
+}
+
+// isMutatingCommand checks if the command modifies the disks.
+func isMutatingCommand(cList []string) bool {
+	if len(cList) == 0 || !mutatingCommands[cList[0]] {
+		return false
+	}
+	for _, arg := range cList[1:] {
+		if inspectionArgs[arg] {
+			return false
+		}
+	}
+	return true
+}
+
+// acquire waits for a free command slot. The wait is bounded by the
+// command timeout, as a command holding the slot is killed by then.
+func (l *commandLimits) acquire(ctx context.Context) (func(), error) {
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// contextWithTimeout returns the context bounding the execution of a
+// command within the parent context, zero disables the timeout.
+func contextWithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// RunCommand runs the given command and returns its combined output.
+func RunCommand(cList []string) (string, error) {
+	return RunCommandWithInput(cList, nil)
+}
+
+// RunCommandWithInput runs the given command with the input fed to its
+// stdin and returns its combined output. The input is never logged or
+// recorded, so secrets like passphrases must be passed through it rather
+// than as arguments.
+func RunCommandWithInput(cList []string, input []byte) (string, error) {
+	out, _, err := runCommand(context.Background(), cList, input, limits.timeoutFor(cList))
+	return out, err
+}
+
+// RunCommandContext runs the given command and returns its combined
+// output. The command is killed when the context is done, e.g. when the
+// deadline of the request it serves is exceeded.
+func RunCommandContext(ctx context.Context, cList []string) (string, error) {
+	out, _, err := runCommand(ctx, cList, nil, limits.timeoutFor(cList))
+	return out, err
+}
+
+// runCommand runs the given command, killing it when the parent context is
+// done or after the timeout unless it is zero, and returns its combined
+// output and exit code. The error is set if the command couldn't be run or
+// exited with a non-zero code.
+func runCommand(parent context.Context, cList []string, input []byte, timeout time.Duration) (string, int, error) {
+	l := limits
+	ctx, cancel := contextWithTimeout(parent, timeout)
+	defer cancel()
+
+	release, err := l.acquire(ctx)
+	if err != nil {
+		return "", -1, errors.Wrapf(err, "command %q could not get an execution slot", strings.Join(cList, " "))
+	}
+	defer release()
+	return execCommand(parent, ctx, cList, input, timeout)
+}
+
+// runUnslottedCommand runs the given command outside of the execution
+// slots and without timeout, killing it only when the context is done. It
+// serves the commands outlasting any timeout, like the copy of a
+// partition, which would hold a slot for their whole run.
+func runUnslottedCommand(ctx context.Context, cList []string) (string, int, error) {
+	return execCommand(ctx, ctx, cList, nil, 0)
+}
+
+// execCommand runs the given command within ctx, the context derived from
+// the parent one by the timeout.
+func execCommand(parent, ctx context.Context, cList []string, input []byte, timeout time.Duration) (string, int, error) {
+	cmd := exec.CommandContext(ctx, cList[0], cList[1:]...)
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	rec := CommandRecord{
+		Command:   strings.Join(cList, " "),
+		StartTime: start,
+		Duration:  time.Since(start),
+		ExitCode:  cmd.ProcessState.ExitCode(),
+		Output:    truncateOutput(string(out)),
+	}
+	history.add(rec)
+	klog.V(4).Infof("Device LocalPV: ran command %q in %v, exit code %d, output %q",
+		rec.Command, rec.Duration, rec.ExitCode, rec.Output)
+
+	if parent.Err() != nil {
+		err = errors.Wrapf(parent.Err(), "aborted")
+	} else if ctx.Err() == context.DeadlineExceeded {
+		err = errors.Errorf("timed out after %v", timeout)
+	}
+	if err != nil {
+		klog.Errorf("Device LocalPV: could not Run command %+v\n", cList)
+		return "", rec.ExitCode, errors.Wrapf(err, "command %q failed with output %q", rec.Command, rec.Output)
+	}
+	return string(out), rec.ExitCode, nil
+}
+
+func truncateOutput(out string) string {
+	if len(out) <= maxRecordedOutput {
+		return out
+	}
+	return out[:maxRecordedOutput] + "...(truncated)"
+}
@@ -0,0 +1,200 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+)
+
+func Test_commandHistory(t *testing.T) {
+	SetCommandHistorySize(3)
+	defer SetCommandHistorySize(0)
+
+	for i := 0; i < 5; i++ {
+		if _, err := RunCommand([]string{"echo", fmt.Sprint(i)}); err != nil {
+			t.Fatalf("echo failed: %v", err)
+		}
+	}
+	records := history.list()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	for i, rec := range records {
+		if expected := fmt.Sprintf("echo %d", i+2); rec.Command != expected {
+			t.Errorf("expected command %q, got %q", expected, rec.Command)
+		}
+	}
+
+	if _, err := RunCommand([]string{"sh", "-c", "echo failed; exit 3"}); err == nil {
+		t.Errorf("expected command to fail")
+	}
+	last := history.list()[2]
+	if last.ExitCode != 3 || last.Output != "failed\n" {
+		t.Errorf("unexpected record of failed command %+v", last)
+	}
+
+	// the commands are kept for the slow reconciles without the history.
+	SetCommandHistorySize(0)
+	if _, err := RunCommand([]string{"echo", "kept"}); err != nil {
+		t.Fatalf("echo failed: %v", err)
+	}
+	if records := history.list(); len(records) != 0 {
+		t.Errorf("expected no command history, got %+v", records)
+	}
+	if recent := history.recent(); len(recent) != 1 || recent[0].Command != "echo kept" {
+		t.Errorf("expected the command kept for the slow reconciles, got %+v", recent)
+	}
+}
+
+func Test_RunCommandWithInput(t *testing.T) {
+	SetCommandHistorySize(1)
+	defer SetCommandHistorySize(0)
+
+	out, err := RunCommandWithInput([]string{"wc", "-c"}, []byte("secret"))
+	if err != nil {
+		t.Fatalf("wc failed: %v", err)
+	}
+	if strings.TrimSpace(out) != "6" {
+		t.Errorf("expected input to be passed to the command, got %q", out)
+	}
+	rec := history.list()[0]
+	if strings.Contains(rec.Command, "secret") || strings.Contains(rec.Output, "secret") {
+		t.Errorf("input must not be recorded, got %+v", rec)
+	}
+}
+
+func Test_commandLimits(t *testing.T) {
+	SetCommandLimits(1, 100*time.Millisecond, 0)
+	defer SetCommandLimits(0, 0, 0)
+
+	start := time.Now()
+	if _, err := RunCommand([]string{"sleep", "10"}); err == nil {
+		t.Errorf("expected command to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected command to be killed at the timeout, took %v", elapsed)
+	}
+
+	// the slot of the killed command must have been released
+	release, err := limits.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expected a free slot, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := limits.acquire(ctx); err == nil {
+		t.Errorf("expected no free slot while the only one is held")
+	}
+	release()
+	if _, err := RunCommand([]string{"true"}); err != nil {
+		t.Errorf("expected command to run once the slot is released, got %v", err)
+	}
+}
+
+func Test_runUnslottedCommand(t *testing.T) {
+	SetCommandLimits(1, 100*time.Millisecond, 0)
+	defer SetCommandLimits(0, 0, 0)
+
+	// the command runs past the timeout while the only slot is held.
+	release, err := limits.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expected a free slot, got %v", err)
+	}
+	defer release()
+	if _, _, err := runUnslottedCommand(context.Background(), []string{"sleep", "0.3"}); err != nil {
+		t.Errorf("expected command to run outside of the slots and the timeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := runUnslottedCommand(ctx, []string{"sleep", "10"}); errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("expected command to be aborted with the context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected command to be killed with the context, took %v", elapsed)
+	}
+}
+
+func Test_isMutatingCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		mutating bool
+	}{
+		{command: "lsblk -J -b -d -o NAME,SIZE,TYPE", mutating: false},
+		{command: "parted /dev/sdb unit b print free --script", mutating: false},
+		{command: "parted /dev/sdb mkpart 5e2c7f3a 1MiB 1025MiB --script", mutating: true},
+		{command: "sgdisk --verify /dev/sdb", mutating: false},
+		{command: "sgdisk --print /dev/sdb", mutating: false},
+		{command: "sgdisk --delete=2 /dev/sdb", mutating: true},
+		{command: "wipefs --force -a /dev/sdb2", mutating: true},
+		{command: "mdadm --examine --scan --verbose", mutating: false},
+		{command: "mdadm --stop /dev/md/vol", mutating: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			if got := isMutatingCommand(strings.Split(tt.command, " ")); got != tt.mutating {
+				t.Errorf("isMutatingCommand() got = %v, want %v", got, tt.mutating)
+			}
+		})
+	}
+
+	l := newCommandLimits(0, 2*time.Minute, 30*time.Minute)
+	if got := l.timeoutFor([]string{"sgdisk", "--delete=2", "/dev/sdb"}); got != 30*time.Minute {
+		t.Errorf("expected the mutating timeout for sgdisk --delete, got %v", got)
+	}
+	if got := l.timeoutFor([]string{"lsblk"}); got != 2*time.Minute {
+		t.Errorf("expected the command timeout for lsblk, got %v", got)
+	}
+}
+
+func Test_RunCommandContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := RunCommandContext(ctx, []string{"sleep", "10"})
+	if err == nil {
+		t.Fatalf("expected command to be aborted at the deadline")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected command to be killed at the deadline, took %v", elapsed)
+	}
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+
+	if _, err := RunCommandContext(context.Background(), []string{"true"}); err != nil {
+		t.Errorf("expected command to run without a deadline, got %v", err)
+	}
+}
+
+func Test_truncateOutput(t *testing.T) {
+	long := strings.Repeat("a", maxRecordedOutput+1)
+	if out := truncateOutput(long); len(out) != maxRecordedOutput+len("...(truncated)") {
+		t.Errorf("expected output to be truncated, got %d bytes", len(out))
+	}
+	if out := truncateOutput("short"); out != "short" {
+		t.Errorf("expected short output unchanged, got %q", out)
+	}
+}
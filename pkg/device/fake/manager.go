@@ -0,0 +1,250 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package fake provides a DeviceManager backed by memory, for testing the
+// controllers of the node agent without root or real disks.
+package fake
+
+import (
+	"context"
+	"path"
+	"sync"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+	"github.com/openebs/lib-csi/pkg/common/errors"
+)
+
+// DeviceManager is a device.DeviceManager holding the devices in memory and
+// recording the operations done on them. The errors, when set, are
+// returned by the corresponding operations.
+type DeviceManager struct {
+	sync.Mutex
+
+	// Devices are the devices discovered on the node.
+	Devices []apis.Device
+	// Signed are the UUIDs of the disks left out for carrying a foreign
+	// signature.
+	Signed []string
+	// WWNs are the world wide identifiers by the disk names.
+	WWNs map[string]string
+	// Partitions are the device paths of the partitions by the disk names
+	// and the partition names.
+	Partitions map[string]map[string]string
+	// Layouts are the disks along with the partitions read from their
+	// partition tables.
+	Layouts []device.LayoutDisk
+
+	DiscoveryErr error
+	GrowErr      error
+	CreateErr    error
+	DestroyErr   error
+	ApplyErr     error
+	RelocateErr  error
+	BackfillErr  error
+
+	// Excluded, Quarantined, Protected and Weights are the last allocator
+	// settings.
+	Excluded    []string
+	Quarantined map[string]bool
+	Protected   map[string]uint64
+	Weights     map[string]int32
+	// Grown are the partition table entries of the devices last asked
+	// for.
+	Grown map[string]int32
+
+	// Created, Destroyed, Applied and Relocated are the names of the
+	// volumes the operations were called for, in order.
+	Created   []string
+	Destroyed []string
+	Applied   []string
+	Relocated []string
+	// Backfilled are the names of the volumes whose status got backfilled
+	// with BackfillStatus, in order.
+	Backfilled     []string
+	BackfillStatus apis.VolStatus
+}
+
+var _ device.DeviceManager = &DeviceManager{}
+
+// NewDeviceManager returns a fake DeviceManager with the given devices.
+func NewDeviceManager(devices ...apis.Device) *DeviceManager {
+	return &DeviceManager{
+		Devices:    devices,
+		WWNs:       map[string]string{},
+		Partitions: map[string]map[string]string{},
+	}
+}
+
+// GetDiskDetails returns a copy of the devices.
+func (m *DeviceManager) GetDiskDetails() ([]apis.Device, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.DiscoveryErr != nil {
+		return nil, m.DiscoveryErr
+	}
+	devices := make([]apis.Device, len(m.Devices))
+	for i := range m.Devices {
+		m.Devices[i].DeepCopyInto(&devices[i])
+	}
+	return devices, nil
+}
+
+// ListSignedDevices returns a copy of the signed devices.
+func (m *DeviceManager) ListSignedDevices() []string {
+	m.Lock()
+	defer m.Unlock()
+	return append([]string(nil), m.Signed...)
+}
+
+// ListDiskWWNs returns a copy of the world wide identifiers.
+func (m *DeviceManager) ListDiskWWNs() (map[string]string, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.DiscoveryErr != nil {
+		return nil, m.DiscoveryErr
+	}
+	wwns := make(map[string]string, len(m.WWNs))
+	for name, wwn := range m.WWNs {
+		wwns[name] = wwn
+	}
+	return wwns, nil
+}
+
+// ListDiskLayouts returns a copy of the layouts of the disks.
+func (m *DeviceManager) ListDiskLayouts() ([]device.LayoutDisk, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.DiscoveryErr != nil {
+		return nil, m.DiscoveryErr
+	}
+	return append([]device.LayoutDisk(nil), m.Layouts...), nil
+}
+
+// IsDiskPartition checks if the device path is one of the partitions of
+// the disk.
+func (m *DeviceManager) IsDiskPartition(disk, devicePath string) bool {
+	m.Lock()
+	defer m.Unlock()
+	for _, partPath := range m.Partitions[disk] {
+		if path.Clean(partPath) == path.Clean(devicePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDiskPartitionPath returns the device path of the partition of the
+// disk.
+func (m *DeviceManager) GetDiskPartitionPath(disk, partitionName string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	partPath, ok := m.Partitions[disk][partitionName]
+	if !ok {
+		return "", errors.Errorf("partition %s not found on disk %s", partitionName, disk)
+	}
+	return partPath, nil
+}
+
+// SetExcludedDevices records the excluded devices.
+func (m *DeviceManager) SetExcludedDevices(uuids []string) {
+	m.Lock()
+	defer m.Unlock()
+	m.Excluded = uuids
+}
+
+// SetQuarantinedDevices records the quarantined devices.
+func (m *DeviceManager) SetQuarantinedDevices(names map[string]bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.Quarantined = names
+}
+
+// SetProtectedDevices records the protected leading bytes of the devices.
+func (m *DeviceManager) SetProtectedDevices(devices map[string]uint64) {
+	m.Lock()
+	defer m.Unlock()
+	m.Protected = devices
+}
+
+// SetDeviceWeights records the scheduling weights of the devices.
+func (m *DeviceManager) SetDeviceWeights(weights map[string]int32) {
+	m.Lock()
+	defer m.Unlock()
+	m.Weights = weights
+}
+
+// GrowPartitionTables records the partition table entries of the devices.
+func (m *DeviceManager) GrowPartitionTables(entries map[string]int32) error {
+	m.Lock()
+	defer m.Unlock()
+	m.Grown = entries
+	return m.GrowErr
+}
+
+// CreateVolume records the creation of the volume.
+func (m *DeviceManager) CreateVolume(vol *apis.DeviceVolume) error {
+	m.Lock()
+	defer m.Unlock()
+	m.Created = append(m.Created, vol.Name)
+	return m.CreateErr
+}
+
+// DestroyVolume records the deletion of the volume.
+func (m *DeviceManager) DestroyVolume(vol *apis.DeviceVolume) error {
+	m.Lock()
+	defer m.Unlock()
+	m.Destroyed = append(m.Destroyed, vol.Name)
+	return m.DestroyErr
+}
+
+// ApplyVolumeAttributes records the attributes of the volume being
+// applied.
+func (m *DeviceManager) ApplyVolumeAttributes(vol *apis.DeviceVolume) error {
+	m.Lock()
+	defer m.Unlock()
+	m.Applied = append(m.Applied, vol.Name)
+	return m.ApplyErr
+}
+
+// RelocateVolume records the relocation of the volume.
+func (m *DeviceManager) RelocateVolume(_ context.Context, vol *apis.DeviceVolume) error {
+	m.Lock()
+	defer m.Unlock()
+	m.Relocated = append(m.Relocated, vol.Name)
+	return m.RelocateErr
+}
+
+// BackfillVolumeStatus records the backfill of the status of the volume,
+// filling its empty capacity and disk identifier from BackfillStatus.
+func (m *DeviceManager) BackfillVolumeStatus(vol *apis.DeviceVolume) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.Backfilled = append(m.Backfilled, vol.Name)
+	if m.BackfillErr != nil {
+		return false, m.BackfillErr
+	}
+	var filled bool
+	if vol.Status.Capacity == "" && m.BackfillStatus.Capacity != "" {
+		vol.Status.Capacity = m.BackfillStatus.Capacity
+		filled = true
+	}
+	if vol.Status.DiskUUID == "" && m.BackfillStatus.DiskUUID != "" {
+		vol.Status.DiskUUID = m.BackfillStatus.DiskUUID
+		filled = true
+	}
+	return filled, nil
+}
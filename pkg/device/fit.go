@@ -0,0 +1,69 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"sort"
+	"strconv"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// DefaultFitTolerancePercent is how much larger than the partition, in
+// percent, a free region can be to be preferred by the fit placement, if
+// the volume doesn't set it.
+const DefaultFitTolerancePercent = 10
+
+// getFitTolerance returns the fit tolerance of the volume in percent.
+func getFitTolerance(vol *apis.DeviceVolume) int {
+	tolerance, err := strconv.Atoi(vol.Spec.FitTolerancePercent)
+	if err != nil || tolerance < 0 {
+		return DefaultFitTolerancePercent
+	}
+	return tolerance
+}
+
+// selectFitRegion picks the free region for a partition of partSize MiB
+// as per the fit placement. The regions at most tolerance percent larger
+// than the partition are preferred, the smallest of them first, so that
+// the large regions are kept intact for the large partitions. If none
+// qualifies, the largest region is used, leaving the smaller regions for
+// the partitions fitting them better.
+func selectFitRegion(pList []partFree, partSize uint64, tolerance int) (partFree, bool) {
+	limit := partSize + partSize*uint64(tolerance)/100
+	sorted := make([]partFree, len(pList))
+	copy(sorted, pList)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SizeMiB < sorted[j].SizeMiB
+	})
+	for _, region := range sorted {
+		if region.SizeMiB >= partSize && region.SizeMiB <= limit {
+			return region, true
+		}
+	}
+	if len(sorted) == 0 || sorted[len(sorted)-1].SizeMiB < partSize {
+		return partFree{}, false
+	}
+	// the largest region, the first one listed on ties
+	largest := sorted[len(sorted)-1]
+	for _, region := range pList {
+		if region.SizeMiB == largest.SizeMiB {
+			return region, true
+		}
+	}
+	return largest, true
+}
@@ -0,0 +1,81 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func Test_selectFitRegion(t *testing.T) {
+	pList := []partFree{
+		{"sdb", 2, 2002, 2000},
+		{"sdc", 2, 1052, 1050},
+		{"sdd", 2, 1102, 1100},
+		{"sde", 2, 502, 500},
+	}
+	tests := []struct {
+		name      string
+		pList     []partFree
+		size      uint64
+		tolerance int
+		disk      string
+		ok        bool
+	}{
+		{name: "smallest region within tolerance", pList: pList, size: 1000, tolerance: 10, disk: "sdc", ok: true},
+		{name: "exact fit", pList: pList, size: 500, tolerance: 0, disk: "sde", ok: true},
+		{name: "larger tolerance", pList: pList, size: 1000, tolerance: 100, disk: "sdc", ok: true},
+		{name: "none within tolerance falls back to the largest", pList: pList, size: 600, tolerance: 10, disk: "sdb", ok: true},
+		{name: "zero tolerance falls back to the largest", pList: pList, size: 1000, tolerance: 0, disk: "sdb", ok: true},
+		{name: "largest region on ties is the first listed", pList: []partFree{{"sdb", 2, 1002, 1000}, {"sdc", 2, 1002, 1000}}, size: 100, tolerance: 10, disk: "sdb", ok: true},
+		{name: "no region large enough", pList: pList, size: 3000, tolerance: 10, ok: false},
+		{name: "no free regions", size: 100, tolerance: 10, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			region, ok := selectFitRegion(tt.pList, tt.size, tt.tolerance)
+			if ok != tt.ok {
+				t.Fatalf("selectFitRegion() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && region.DiskName != tt.disk {
+				t.Errorf("selectFitRegion() picked %s, want %s", region.DiskName, tt.disk)
+			}
+		})
+	}
+}
+
+func Test_getFitTolerance(t *testing.T) {
+	tests := []struct {
+		name    string
+		percent string
+		want    int
+	}{
+		{name: "set", percent: "25", want: 25},
+		{name: "zero", percent: "0", want: 0},
+		{name: "volume created before the parameter", percent: "", want: DefaultFitTolerancePercent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vol := &apis.DeviceVolume{}
+			vol.Spec.FitTolerancePercent = tt.percent
+			if got := getFitTolerance(vol); got != tt.want {
+				t.Errorf("getFitTolerance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"time"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultMaxConcurrentFormats is the default number of filesystems created
+// at the same time by the node plugin.
+const DefaultMaxConcurrentFormats = 2
+
+var (
+	// FormatsInFlight is the number of filesystems being created by the
+	// node plugin.
+	FormatsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "openebs",
+		Subsystem: "device",
+		Name:      "formats_in_flight",
+		Help:      "Number of filesystems being created.",
+	})
+
+	// FormatWaitDuration observes the time the volumes waited for a free
+	// format slot before their filesystem got created.
+	FormatWaitDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "openebs",
+		Subsystem: "device",
+		Name:      "format_wait_duration_seconds",
+		Help:      "Time waited for a free format slot before creating a filesystem.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300},
+	})
+)
+
+// formatLimiter bounds the number of filesystems created at the same time,
+// so that publishing many new volumes at once doesn't saturate the IO of
+// the node with mkfs.
+type formatLimiter struct {
+	// slots holds a token for every filesystem being created, it is nil
+	// when the number of concurrent formats is not limited.
+	slots chan struct{}
+}
+
+var formats = newFormatLimiter(DefaultMaxConcurrentFormats)
+
+// SetMaxConcurrentFormats sets the maximum number of filesystems created at
+// the same time. Zero disables the limit. It must be called before publishing
+// any volume.
+func SetMaxConcurrentFormats(maxConcurrent int) error {
+	if maxConcurrent < 0 {
+		return errors.Errorf("invalid maximum number of concurrent formats %d", maxConcurrent)
+	}
+	formats = newFormatLimiter(maxConcurrent)
+	return nil
+}
+
+func newFormatLimiter(maxConcurrent int) *formatLimiter {
+	l := &formatLimiter{}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	return l
+}
+
+// acquire waits for a free format slot, or till the context is done. The
+// returned func releases the slot.
+func (l *formatLimiter) acquire(ctx context.Context) (func(), error) {
+	start := time.Now()
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "could not get a format slot")
+		}
+	}
+	FormatWaitDuration.Observe(time.Since(start).Seconds())
+	FormatsInFlight.Inc()
+	return func() {
+		FormatsInFlight.Dec()
+		if l.slots != nil {
+			<-l.slots
+		}
+	}, nil
+}
@@ -0,0 +1,103 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_formatLimiter(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxConcurrent int
+		formats       int
+		want          int
+	}{
+		{name: "limited to one", maxConcurrent: 1, formats: 8, want: 1},
+		{name: "limited to two", maxConcurrent: 2, formats: 8, want: 2},
+		{name: "not limited", maxConcurrent: 0, formats: 8, want: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newFormatLimiter(tt.maxConcurrent)
+			var mu sync.Mutex
+			running, peak := 0, 0
+			var wg sync.WaitGroup
+			for i := 0; i < tt.formats; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					release, err := l.acquire(context.Background())
+					if err != nil {
+						t.Errorf("acquire() unexpected error %v", err)
+						return
+					}
+					defer release()
+					mu.Lock()
+					running++
+					if running > peak {
+						peak = running
+					}
+					mu.Unlock()
+					time.Sleep(20 * time.Millisecond)
+					mu.Lock()
+					running--
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+			if peak != tt.want {
+				t.Errorf("%d formats ran at the same time, want %d", peak, tt.want)
+			}
+		})
+	}
+}
+
+func Test_formatLimiterContext(t *testing.T) {
+	l := newFormatLimiter(1)
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire() unexpected error %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx); err == nil {
+		t.Errorf("expected no free slot while the only one is held")
+	}
+	release()
+	release, err = l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expected a free slot once released, got %v", err)
+	}
+	release()
+}
+
+func TestSetMaxConcurrentFormats(t *testing.T) {
+	defer func() { formats = newFormatLimiter(DefaultMaxConcurrentFormats) }()
+	if err := SetMaxConcurrentFormats(-1); err == nil {
+		t.Errorf("expected a negative limit to be rejected")
+	}
+	if err := SetMaxConcurrentFormats(3); err != nil || cap(formats.slots) != 3 {
+		t.Errorf("SetMaxConcurrentFormats(3) got %v, %d slots", err, cap(formats.slots))
+	}
+	if err := SetMaxConcurrentFormats(0); err != nil || formats.slots != nil {
+		t.Errorf("SetMaxConcurrentFormats(0) got %v, expected no limit", err)
+	}
+}
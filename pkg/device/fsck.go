@@ -0,0 +1,133 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// Filesystem check commands
+const (
+	FilesystemState = "dumpe2fs -h %s"
+	FilesystemCheck = "e2fsck -p %s"
+)
+
+// e2fsck exit codes, see e2fsck(8).
+const (
+	fsckErrorsCorrected = 1
+	fsckRebootRequired  = 2
+)
+
+// fsckTimeout is the time after which the filesystem check of a volume is
+// killed.
+var fsckTimeout = 10 * time.Minute
+
+// SetFsckTimeout sets the time after which the filesystem check of a
+// volume is killed. Zero disables the timeout.
+func SetFsckTimeout(timeout time.Duration) {
+	fsckTimeout = timeout
+}
+
+// isExtFilesystem checks if the filesystem is checked by e2fsck.
+func isExtFilesystem(fsType string) bool {
+	switch fsType {
+	case "", "ext2", "ext3", "ext4":
+		// kubernetes defaults to ext4
+		return true
+	}
+	return false
+}
+
+// isFilesystemDirty checks the superblock of an ext filesystem, as printed
+// by dumpe2fs -h, for an unclean state or a journal to be recovered.
+func isFilesystemDirty(superblock string) bool {
+	for _, line := range strings.Split(superblock, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "Filesystem state":
+			if value != "clean" {
+				return true
+			}
+		case "Filesystem features":
+			for _, feature := range strings.Fields(value) {
+				if feature == "needs_recovery" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// CheckFilesystem runs e2fsck in preen mode on the ext filesystem of the
+// partition if its superblock is marked dirty, e.g. after an unclean
+// shutdown of the node. XFS replays its log at mount time, so the other
+// filesystems are left to the mount. It returns true if the filesystem got
+// repaired, and an error if it could not be, in which case it must not be
+// mounted. The check is aborted when the context is done.
+func CheckFilesystem(ctx context.Context, devicePath, fsType string) (bool, error) {
+	if !isExtFilesystem(fsType) {
+		return false, nil
+	}
+
+	// checking a mounted filesystem corrupts it.
+	mounts, err := getPublishedMounts(devicePath)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not list the mounts of %s", devicePath)
+	}
+	if len(mounts) > 0 {
+		klog.Infof("device: %s is mounted at %s, skipping the filesystem check", devicePath, mounts[0].Path)
+		return false, nil
+	}
+
+	superblock, err := RunCommandContext(ctx, strings.Split(fmt.Sprintf(FilesystemState, devicePath), " "))
+	if ctx.Err() != nil {
+		return false, err
+	}
+	if err != nil {
+		// not formatted yet or not an ext filesystem.
+		klog.V(4).Infof("device: could not read the superblock of %s, skipping the filesystem check: %v",
+			devicePath, err)
+		return false, nil
+	}
+	if !isFilesystemDirty(superblock) {
+		return false, nil
+	}
+
+	klog.Infof("device: filesystem on %s is not clean, running e2fsck", devicePath)
+	start := time.Now()
+	_, code, err := runCommand(ctx, strings.Split(fmt.Sprintf(FilesystemCheck, devicePath), " "), nil, fsckTimeout)
+	if code == fsckErrorsCorrected || code == fsckRebootRequired {
+		klog.Infof("device: e2fsck repaired the filesystem on %s in %v", devicePath, time.Since(start))
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "e2fsck could not repair the filesystem on %s", devicePath)
+	}
+	klog.Infof("device: e2fsck found no errors on %s in %v", devicePath, time.Since(start))
+	return false, nil
+}
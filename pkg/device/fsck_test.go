@@ -0,0 +1,67 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_isFilesystemDirty(t *testing.T) {
+	const superblock = `dumpe2fs 1.45.5 (07-Jan-2020)
+Filesystem volume name:   <none>
+Filesystem magic number:  0xEF53
+Filesystem features:      has_journal ext_attr resize_inode dir_index filetype%s extent 64bit flex_bg sparse_super
+Filesystem flags:         signed_directory_hash
+Default mount options:    user_xattr acl
+Filesystem state:         %s
+Errors behavior:          Continue
+`
+	tests := []struct {
+		name     string
+		features string
+		state    string
+		want     bool
+	}{
+		{name: "clean", state: "clean", want: false},
+		{name: "unclean shutdown", features: " needs_recovery", state: "clean", want: true},
+		{name: "not clean", state: "not clean", want: true},
+		{name: "errors", state: "clean with errors", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFilesystemDirty(fmt.Sprintf(superblock, tt.features, tt.state)); got != tt.want {
+				t.Errorf("isFilesystemDirty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isExtFilesystem(t *testing.T) {
+	tests := map[string]bool{
+		"":      true,
+		"ext3":  true,
+		"ext4":  true,
+		"xfs":   false,
+		"btrfs": false,
+	}
+	for fsType, want := range tests {
+		if got := isExtFilesystem(fsType); got != want {
+			t.Errorf("isExtFilesystem(%q) = %v, want %v", fsType, got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"github.com/openebs/lib-csi/pkg/common/errors"
+)
+
+// fsLabelLimits are the maximum lengths in bytes of the labels of the
+// filesystems whose mkfs sets the label with -L.
+var fsLabelLimits = map[string]int{
+	// kubernetes defaults to ext4
+	"":      16,
+	"ext2":  16,
+	"ext3":  16,
+	"ext4":  16,
+	"xfs":   12,
+	"btrfs": 255,
+}
+
+// TruncateFsLabel returns the label cut down to the length limit of the
+// filesystem. It fails for the filesystems a label can't be set on.
+func TruncateFsLabel(fsType, label string) (string, error) {
+	limit, ok := fsLabelLimits[fsType]
+	if !ok {
+		return "", errors.Errorf("filesystem label is not supported for %s", fsType)
+	}
+	if len(label) > limit {
+		return label[:limit], nil
+	}
+	return label, nil
+}
@@ -0,0 +1,84 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"sync"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+)
+
+// minVolumeSizeMiB is the size of the smallest volume, the disks whose
+// largest free region is smaller are full. Zero never marks a disk full.
+var minVolumeSizeMiB uint64
+
+// skipFullDevices lets the allocation skip the full disks without
+// scanning their free regions.
+var skipFullDevices bool
+
+// SetMinVolumeSize sets the size in MiB of the smallest volume, the disks
+// whose largest free region is smaller are reported as full.
+func SetMinVolumeSize(sizeMiB int) error {
+	if sizeMiB < 0 {
+		return errors.Errorf("invalid minimum volume size %d MiB", sizeMiB)
+	}
+	minVolumeSizeMiB = uint64(sizeMiB)
+	return nil
+}
+
+// SetSkipFullDevices sets whether the disks found full by the last
+// discovery are left out of the allocation of the new partitions, till a
+// later discovery finds room on them again.
+func SetSkipFullDevices(skip bool) {
+	skipFullDevices = skip
+}
+
+// isFull checks if no volume fits in the largest free region of a disk.
+func isFull(freeMiB uint64) bool {
+	return freeMiB < minVolumeSizeMiB
+}
+
+// fullDevices holds the UUIDs of the disks found full by the last
+// discovery.
+var fullDevices = struct {
+	sync.RWMutex
+	uuids map[string]bool
+}{}
+
+func setFullDevices(full map[string]bool) {
+	fullDevices.Lock()
+	defer fullDevices.Unlock()
+	fullDevices.uuids = full
+}
+
+// isDiskFull checks if the disk was found full by the last discovery and
+// is left out of the allocation of the new partitions.
+func isDiskFull(diskName string) bool {
+	if !skipFullDevices {
+		return false
+	}
+	fullDevices.RLock()
+	defer fullDevices.RUnlock()
+	if len(fullDevices.uuids) == 0 {
+		return false
+	}
+	id, err := getDiskIdentifier(diskName)
+	if err != nil {
+		return false
+	}
+	return fullDevices.uuids[id]
+}
@@ -0,0 +1,67 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+)
+
+func Test_isFull(t *testing.T) {
+	tests := []struct {
+		name    string
+		minMiB  int
+		freeMiB uint64
+		want    bool
+	}{
+		{name: "disabled", minMiB: 0, freeMiB: 0, want: false},
+		{name: "room for a volume", minMiB: 1024, freeMiB: 4096, want: false},
+		{name: "room for exactly one volume", minMiB: 1024, freeMiB: 1024, want: false},
+		{name: "sliver left", minMiB: 1024, freeMiB: 1023, want: true},
+		{name: "nothing left", minMiB: 1024, freeMiB: 0, want: true},
+	}
+	defer SetMinVolumeSize(0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetMinVolumeSize(tt.minMiB); err != nil {
+				t.Fatal(err)
+			}
+			if got := isFull(tt.freeMiB); got != tt.want {
+				t.Errorf("isFull(%d) = %v, want %v", tt.freeMiB, got, tt.want)
+			}
+		})
+	}
+	if err := SetMinVolumeSize(-1); err == nil {
+		t.Errorf("SetMinVolumeSize(-1) expected error")
+	}
+}
+
+func Test_isDiskFull(t *testing.T) {
+	defer SetSkipFullDevices(false)
+	defer setFullDevices(nil)
+
+	// no disk is skipped without the option, or without full disks,
+	// before the identity of the disk is looked up.
+	setFullDevices(map[string]bool{"uuid-1": true})
+	if isDiskFull("sdb") {
+		t.Errorf("expected no full disk without the option")
+	}
+	SetSkipFullDevices(true)
+	setFullDevices(nil)
+	if isDiskFull("sdb") {
+		t.Errorf("expected no full disk without full disks")
+	}
+}
@@ -0,0 +1,385 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// Partition table resize commands
+const (
+	PartitionTablePrint  = "sgdisk --print %s"
+	PartitionTableResize = "sgdisk --resize-table=%d %s"
+)
+
+// DiskLogicalBlockSizePath is the sysfs attribute holding the logical
+// sector size of the disk.
+const DiskLogicalBlockSizePath = "block/%s/queue/logical_block_size"
+
+// PartitionTableResizeFailedReason is the reason of the warning events
+// recorded on the DeviceNode when the partition table of a disk could not
+// be grown to the number of entries set in its spec.
+const PartitionTableResizeFailedReason = "PartitionTableResizeFailed"
+
+// GPT on-disk format, the header is in the second sector of the disk.
+const (
+	gptSignature          = "EFI PART"
+	gptHeaderSize         = 92
+	gptEntriesLBAOffset   = 72
+	gptEntryCountOffset   = 80
+	gptEntrySizeOffset    = 84
+	gptDefaultEntrySize   = 128
+	gptMaxEntries         = 65536
+	gptPrimaryHeaderLBA   = 1
+	gptPrimaryEntriesLBA  = 2
+	gptBackupHeaderOffset = 1
+)
+
+// sgdisk --print output, older releases print the logical sector size
+// only.
+var (
+	sgdiskDiskSectorsRegex = regexp.MustCompile(`(?m)^Disk .*: (\d+) sectors`)
+	sgdiskSectorSizeRegex  = regexp.MustCompile(`(?m)^(?:Sector size \(logical(?:/physical)?\)|Logical sector size): (\d+)`)
+	sgdiskEntriesRegex     = regexp.MustCompile(`(?m)^Partition table holds up to (\d+) entries`)
+)
+
+// gptLayout is the layout of a GPT, as printed by sgdisk.
+type gptLayout struct {
+	diskSectors uint64
+	sectorSize  uint64
+	entries     uint64
+	// partitions are the partitions by their number, as the first and the
+	// last sector they span.
+	partitions map[uint64][2]uint64
+}
+
+// parseGPTLayout parses the output of sgdisk --print, e.g.
+//
+//	Disk /dev/sdb: 2097152 sectors, 1024.0 MiB
+//	Sector size (logical/physical): 512/512 bytes
+//	...
+//	Partition table holds up to 128 entries
+//	...
+//	Number  Start (sector)    End (sector)  Size       Code  Name
+//	   1            2048            4095   1024.0 KiB  8300  test-device
+func parseGPTLayout(out string) (gptLayout, error) {
+	layout := gptLayout{partitions: map[uint64][2]uint64{}}
+	for _, field := range []struct {
+		regex *regexp.Regexp
+		value *uint64
+		name  string
+	}{
+		{sgdiskDiskSectorsRegex, &layout.diskSectors, "disk size"},
+		{sgdiskSectorSizeRegex, &layout.sectorSize, "sector size"},
+		{sgdiskEntriesRegex, &layout.entries, "number of entries"},
+	} {
+		match := field.regex.FindStringSubmatch(out)
+		if match == nil {
+			return gptLayout{}, errors.Errorf("no %s in the partition table: %s", field.name, truncateOutput(out))
+		}
+		*field.value, _ = strconv.ParseUint(match[1], 10, 64)
+	}
+	if layout.sectorSize == 0 {
+		return gptLayout{}, errors.Errorf("invalid sector size in the partition table: %s", truncateOutput(out))
+	}
+
+	inTable := false
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "Number" {
+			inTable = true
+			continue
+		}
+		if !inTable || len(fields) < 3 {
+			continue
+		}
+		num, err1 := strconv.ParseUint(fields[0], 10, 64)
+		start, err2 := strconv.ParseUint(fields[1], 10, 64)
+		end, err3 := strconv.ParseUint(fields[2], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return gptLayout{}, errors.Errorf("invalid partition in the partition table: %q", line)
+		}
+		layout.partitions[num] = [2]uint64{start, end}
+	}
+	return layout, nil
+}
+
+// tableSectors returns the number of sectors taken by the given number of
+// partition entries.
+func (l gptLayout) tableSectors(entries uint64) uint64 {
+	return (entries*gptDefaultEntrySize + l.sectorSize - 1) / l.sectorSize
+}
+
+// maxEntries returns the largest number of entries the partition table can
+// hold without overlapping the partitions. The primary table grows from the
+// third sector towards the first partition and the backup table from the
+// last sector but one towards the last partition. Without partitions, the
+// tables are kept within the first and the last MiB.
+func (l gptLayout) maxEntries() uint64 {
+	alignment := PartitionAlignmentBytes / l.sectorSize
+	if alignment*2 >= l.diskSectors {
+		return l.entries
+	}
+	firstStart, lastEnd := alignment, l.diskSectors-alignment-1
+	if len(l.partitions) > 0 {
+		firstStart, lastEnd = l.diskSectors, 0
+		for _, part := range l.partitions {
+			if part[0] < firstStart {
+				firstStart = part[0]
+			}
+			if part[1] > lastEnd {
+				lastEnd = part[1]
+			}
+		}
+	}
+	var primary, backup uint64
+	if firstStart > gptPrimaryEntriesLBA {
+		primary = firstStart - gptPrimaryEntriesLBA
+	}
+	// the backup header is in the last sector, the table right before it
+	if backupEnd := l.diskSectors - gptBackupHeaderOffset; backupEnd > lastEnd+1 {
+		backup = backupEnd - lastEnd - 1
+	}
+	sectors := primary
+	if backup < sectors {
+		sectors = backup
+	}
+	return sectors * l.sectorSize / gptDefaultEntrySize
+}
+
+// getGPTLayout reads the layout of the partition table of the disk.
+func getGPTLayout(disk string) (gptLayout, error) {
+	out, err := RunCommand(strings.Split(fmt.Sprintf(PartitionTablePrint, devicePath(disk)), " "))
+	if err != nil {
+		return gptLayout{}, errors.Wrapf(err, "could not print the partition table of disk %s", disk)
+	}
+	return parseGPTLayout(out)
+}
+
+// ResizePartitionTable grows the partition table of the disk to hold the
+// given number of entries, so that more partitions can be created on it.
+// The partitions are not moved: the resize is refused if the larger primary
+// or backup table would overlap them. The table is verified before and after
+// the resize, and the partitions are checked to be unchanged.
+func ResizePartitionTable(disk string, entries uint64) error {
+	unlock := lockDisks([]string{disk})
+	defer unlock()
+
+	if err := verifyPartitionTable(disk); err != nil {
+		return err
+	}
+	before, err := getGPTLayout(disk)
+	if err != nil {
+		return err
+	}
+	if entries <= before.entries {
+		klog.Infof("partition table of disk %s holds %d entries already, not resizing it to %d",
+			disk, before.entries, entries)
+		return nil
+	}
+	if max := before.maxEntries(); entries > max {
+		return errors.Errorf("partition table of disk %s can hold at most %d entries "+
+			"without overlapping its partitions, not resizing it to %d", disk, max, entries)
+	}
+
+	if err = auditOperation(AuditOperationResizeTable, disk, 0, "", func() error {
+		_, err := RunCommand(strings.Split(fmt.Sprintf(PartitionTableResize, entries, devicePath(disk)), " "))
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if err = verifyPartitionTable(disk); err != nil {
+		return err
+	}
+	after, err := getGPTLayout(disk)
+	if err != nil {
+		return err
+	}
+	if after.entries < entries {
+		return errors.Errorf("partition table of disk %s holds %d entries after resizing it to %d",
+			disk, after.entries, entries)
+	}
+	if !reflect.DeepEqual(before.partitions, after.partitions) {
+		return errors.Errorf("partitions of disk %s changed while resizing its partition table, "+
+			"from %v to %v", disk, before.partitions, after.partitions)
+	}
+	klog.Infof("resized partition table of disk %s from %d to %d entries", disk, before.entries, after.entries)
+	return nil
+}
+
+// GrowPartitionTables grows the partition tables of the disks to the number
+// of entries set for them by their UUID or device name, as the
+// PartitionTableEntries of the DeviceNode spec. The tables already holding
+// as many entries are left alone, they are never shrunk. It returns the
+// errors of all the disks which could not be grown.
+func GrowPartitionTables(targets map[string]int32) error {
+	if len(targets) == 0 {
+		return nil
+	}
+	diskList, err := getDiskList()
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, disk := range diskList {
+		name, err := getDiskMetaName(disk.DiskName)
+		if err != nil {
+			continue
+		}
+		uuid, _ := getDiskIdentifier(disk.DiskName)
+		target, ok := targets[uuid]
+		if !ok {
+			target, ok = targets[name]
+		}
+		if !ok || target <= 0 {
+			continue
+		}
+		current, _ := getPartitionTableEntries(disk.DiskName)
+		if current >= target {
+			continue
+		}
+		if err = ResizePartitionTable(disk.DiskName, uint64(target)); err != nil {
+			klog.Errorf("could not grow partition table of disk %s to %d entries: %v", disk.DiskName, target, err)
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// getPartitionTableEntries returns the number of entries of the partition
+// table of the disk and the number of them in use, read from the primary
+// GPT. It returns zeros if the table can't be read.
+func getPartitionTableEntries(diskName string) (int32, int32) {
+	entries, used, err := readGPTEntries(devicePath(diskName), getDiskSectorSize(diskName))
+	if err != nil {
+		klog.V(4).Infof("Device LocalPV: could not read partition table of %s: %v", diskName, err)
+		return 0, 0
+	}
+	return entries, used
+}
+
+// getDiskSectorSize returns the logical sector size of the disk, as per
+// sysfs, or the default sector size if it can't be read.
+func getDiskSectorSize(diskName string) uint64 {
+	if out, err := ioutil.ReadFile(sysfsPath(DiskLogicalBlockSizePath, diskName)); err == nil {
+		if size, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64); err == nil && size > 0 {
+			return size
+		}
+	}
+	return SectorSize
+}
+
+// readGPTEntries reads the number of entries of the primary GPT of the
+// device and counts the ones in use, i.e. having a partition type.
+func readGPTEntries(path string, sectorSize uint64) (int32, int32, error) {
+	count, used, err := readGPTTable(path, sectorSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, int32(len(used)), nil
+}
+
+// gptEntry is an entry in use of a GPT.
+type gptEntry struct {
+	number uint32
+	// guid is the unique GUID of the partition.
+	guid string
+	// first and last are the first and the last sector of the partition.
+	first, last uint64
+	name        string
+}
+
+// readGPTTable reads the primary GPT of the device, it returns the number
+// of its entries and the entries in use.
+func readGPTTable(path string, sectorSize uint64) (int32, []gptEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, gptHeaderSize)
+	if _, err = file.ReadAt(header, int64(gptPrimaryHeaderLBA*sectorSize)); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(gptSignature)]) != gptSignature {
+		return 0, nil, errors.Errorf("no GPT header on %s", path)
+	}
+	entriesLBA := binary.LittleEndian.Uint64(header[gptEntriesLBAOffset:])
+	count := binary.LittleEndian.Uint32(header[gptEntryCountOffset:])
+	entrySize := binary.LittleEndian.Uint32(header[gptEntrySizeOffset:])
+	if count > gptMaxEntries || entrySize < gptDefaultEntrySize {
+		return 0, nil, errors.Errorf("invalid GPT header on %s: %d entries of %d bytes", path, count, entrySize)
+	}
+
+	table := make([]byte, uint64(count)*uint64(entrySize))
+	if _, err = file.ReadAt(table, int64(entriesLBA*sectorSize)); err != nil {
+		return 0, nil, err
+	}
+	unused := make([]byte, 16)
+	var used []gptEntry
+	for i := uint64(0); i < uint64(count); i++ {
+		entry := table[i*uint64(entrySize) : (i+1)*uint64(entrySize)]
+		if bytes.Equal(entry[:16], unused) {
+			continue
+		}
+		used = append(used, gptEntry{
+			number: uint32(i + 1),
+			guid:   formatGUID(entry[16:32]),
+			first:  binary.LittleEndian.Uint64(entry[32:]),
+			last:   binary.LittleEndian.Uint64(entry[40:]),
+			name:   decodeGPTName(entry[56:gptDefaultEntrySize]),
+		})
+	}
+	return int32(count), used, nil
+}
+
+// formatGUID formats the GUID stored in the mixed endian layout of GPT,
+// e.g. 0fc63daf-8483-4772-8e79-3d69d8477de4.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]), binary.LittleEndian.Uint16(b[6:8]), b[8:10], b[10:16])
+}
+
+// decodeGPTName decodes the UTF-16LE name of a GPT entry.
+func decodeGPTName(b []byte) string {
+	var units []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		unit := binary.LittleEndian.Uint16(b[i:])
+		if unit == 0 {
+			break
+		}
+		units = append(units, unit)
+	}
+	return string(utf16.Decode(units))
+}
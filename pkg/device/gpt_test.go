@@ -0,0 +1,219 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const sgdiskPrintOutput = `Disk /dev/sdb: 2097152 sectors, 1024.0 MiB
+Model: QEMU HARDDISK
+Sector size (logical/physical): 512/512 bytes
+Disk identifier (GUID): 5A6C7E8F-1B2C-4D3E-9F40-112233445566
+Partition table holds up to 128 entries
+Main partition table begins at sector 2 and ends at sector 33
+First usable sector is 34, last usable sector is 2097118
+Partitions will be aligned on 2048-sector boundaries
+Total free space is 4029 sectors (2.0 MiB)
+
+Number  Start (sector)    End (sector)  Size       Code  Name
+   1            2048            4095   1024.0 KiB  8300  test-device
+   2            4096         2095103   1021.0 MiB  8300  0f9a2c3e-volume
+`
+
+func Test_parseGPTLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    gptLayout
+		wantErr bool
+	}{
+		{
+			name: "sgdisk output",
+			out:  sgdiskPrintOutput,
+			want: gptLayout{diskSectors: 2097152, sectorSize: 512, entries: 128, partitions: map[uint64][2]uint64{
+				1: {2048, 4095}, 2: {4096, 2095103},
+			}},
+		},
+		{
+			name: "older sgdisk output",
+			out: "Disk /dev/sdc: 262144 sectors, 1024.0 MiB\nLogical sector size: 4096 bytes\n" +
+				"Partition table holds up to 256 entries\n\nNumber  Start (sector)    End (sector)  Size       Code  Name\n",
+			want: gptLayout{diskSectors: 262144, sectorSize: 4096, entries: 256, partitions: map[uint64][2]uint64{}},
+		},
+		{
+			name:    "no partition table",
+			out:     "Creating new GPT entries in memory.\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid partition",
+			out:     sgdiskPrintOutput + "   3            lots            4095   1024.0 KiB  8300  broken\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGPTLayout(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGPTLayout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGPTLayout() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_gptLayoutMaxEntries(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout gptLayout
+		want   uint64
+	}{
+		{
+			name: "partitions on MiB boundaries",
+			layout: gptLayout{diskSectors: 2097152, sectorSize: 512, entries: 128, partitions: map[uint64][2]uint64{
+				1: {2048, 4095}, 2: {4096, 1048575},
+			}},
+			// 2046 sectors before the first partition
+			want: 2046 * 4,
+		},
+		{
+			name: "last partition close to the backup table",
+			layout: gptLayout{diskSectors: 2097152, sectorSize: 512, entries: 128, partitions: map[uint64][2]uint64{
+				1: {2048, 4095}, 2: {4096, 2097118},
+			}},
+			want: 128,
+		},
+		{
+			name:   "4KiB sectors",
+			layout: gptLayout{diskSectors: 262144, sectorSize: 4096, entries: 128, partitions: map[uint64][2]uint64{1: {256, 511}}},
+			want:   254 * 32,
+		},
+		{
+			name:   "no partitions",
+			layout: gptLayout{diskSectors: 2097152, sectorSize: 512, entries: 128, partitions: map[uint64][2]uint64{}},
+			want:   2046 * 4,
+		},
+		{
+			name:   "disk smaller than the alignment",
+			layout: gptLayout{diskSectors: 2048, sectorSize: 512, entries: 128, partitions: map[uint64][2]uint64{}},
+			want:   128,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.layout.maxEntries(); got != tt.want {
+				t.Errorf("maxEntries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeGPT writes a primary GPT header with the given number of entries,
+// the first used ones having a partition type, to the file.
+func writeGPT(t *testing.T, path string, sectorSize, entries, used int) {
+	t.Helper()
+	data := make([]byte, sectorSize*2+entries*gptDefaultEntrySize)
+	header := data[sectorSize:]
+	copy(header, gptSignature)
+	binary.LittleEndian.PutUint64(header[gptEntriesLBAOffset:], gptPrimaryEntriesLBA)
+	binary.LittleEndian.PutUint32(header[gptEntryCountOffset:], uint32(entries))
+	binary.LittleEndian.PutUint32(header[gptEntrySizeOffset:], gptDefaultEntrySize)
+	for i := 0; i < used; i++ {
+		data[sectorSize*2+i*gptDefaultEntrySize] = 0xaf
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_readGPTEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "disk.img")
+
+	writeGPT(t, path, 512, 128, 3)
+	if entries, used, err := readGPTEntries(path, 512); err != nil || entries != 128 || used != 3 {
+		t.Errorf("readGPTEntries() = %d, %d, %v, want 128, 3", entries, used, err)
+	}
+	writeGPT(t, path, 4096, 1024, 1)
+	if entries, used, err := readGPTEntries(path, 4096); err != nil || entries != 1024 || used != 1 {
+		t.Errorf("readGPTEntries() = %d, %d, %v, want 1024, 1", entries, used, err)
+	}
+	if _, _, err := readGPTEntries(path, 512); err == nil {
+		t.Errorf("readGPTEntries() expected error for the wrong sector size")
+	}
+	if _, _, err := readGPTEntries(filepath.Join(dir, "missing"), 512); err == nil {
+		t.Errorf("readGPTEntries() expected error for a missing device")
+	}
+}
+
+func Test_ResizePartitionTable(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("creating the loop devices needs root")
+	}
+	for _, bin := range []string{"losetup", "parted", "sgdisk"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s is not available", bin)
+		}
+	}
+
+	disk := newLoopDisk(t, 64*PartitionAlignmentBytes)
+	p := sgdiskPartitioner{}
+	for i, err := range []error{
+		p.create(disk, "meta", 1, 2),
+		p.create(disk, "vol-1", 2, 12),
+		p.create(disk, "vol-2", 12, 30),
+	} {
+		if err != nil {
+			t.Fatalf("step %d failed: %v", i, err)
+		}
+	}
+	layout := partitionLayout(t, disk)
+
+	if err := ResizePartitionTable(disk, 512); err != nil {
+		t.Fatalf("ResizePartitionTable() unexpected error %v", err)
+	}
+	if entries, used := getPartitionTableEntries(disk); entries != 512 || used != 3 {
+		t.Errorf("partition table holds %d entries with %d in use, want 512 and 3", entries, used)
+	}
+	if got := partitionLayout(t, disk); !reflect.DeepEqual(got, layout) {
+		t.Errorf("partitions changed from\n%v\nto\n%v", layout, got)
+	}
+
+	// the tables are never shrunk, nor grown into the first partition
+	if err := ResizePartitionTable(disk, 128); err != nil {
+		t.Errorf("ResizePartitionTable() unexpected error %v when shrinking", err)
+	}
+	if entries, _ := getPartitionTableEntries(disk); entries != 512 {
+		t.Errorf("partition table holds %d entries, want 512", entries)
+	}
+	if err := ResizePartitionTable(disk, 10000); err == nil {
+		t.Errorf("ResizePartitionTable() expected error when overlapping the partitions")
+	}
+}
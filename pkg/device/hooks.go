@@ -0,0 +1,121 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// Stages of the first mount of a volume the format hooks run at.
+const (
+	// PreFormatHook runs before the filesystem of the volume is created.
+	PreFormatHook = "pre-format"
+	// PostFormatHook runs once the new filesystem of the volume is
+	// mounted, before the volume gets published to the pods.
+	PostFormatHook = "post-format"
+)
+
+// formatHooks holds the executables run around the creation of the
+// filesystem of the new volumes, by stage. They are resolved within dir.
+var formatHooks = struct {
+	dir   string
+	hooks map[string]string
+}{}
+
+// SetFormatHooks sets the executables run before and after the creation of
+// the filesystem of the new volumes, given by their name in dir. Empty
+// names run no hook. The hooks must resolve to files within dir, so that
+// only the executables put there by the administrators of the node run.
+func SetFormatHooks(dir, preFormat, postFormat string) error {
+	hooks := map[string]string{}
+	for stage, name := range map[string]string{PreFormatHook: preFormat, PostFormatHook: postFormat} {
+		if name == "" {
+			continue
+		}
+		if dir == "" {
+			return errors.Errorf("the %s hook %q needs the hooks directory", stage, name)
+		}
+		if _, err := resolveHook(dir, name); err != nil {
+			return errors.Wrapf(err, "invalid %s hook", stage)
+		}
+		hooks[stage] = name
+	}
+	formatHooks.dir, formatHooks.hooks = dir, hooks
+	return nil
+}
+
+// resolveHook returns the path of the executable of the given name in dir,
+// with the symbolic links resolved. It fails if the executable lies out of
+// dir or can't be executed.
+func resolveHook(dir, name string) (string, error) {
+	if filepath.IsAbs(name) || name != filepath.Clean(name) || strings.HasPrefix(name, "..") {
+		return "", errors.Errorf("hook %q must be a name within the hooks directory", name)
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid hooks directory %q", dir)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return "", errors.Wrapf(err, "hook %q not found", name)
+	}
+	if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", errors.Errorf("hook %q resolves to %s, out of the hooks directory %s", name, path, root)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		return "", errors.Errorf("hook %q is not an executable file", name)
+	}
+	return path, nil
+}
+
+// runFormatHook runs the hook of the stage, if any, for the first mount of
+// the volume. The hook gets the device path, the name, filesystem type,
+// mount path, capacity and claim of the volume as arguments, the claim
+// being empty if unknown. It fails the publish of the volume if the hook
+// fails, the hook being run again when the publish is retried.
+func runFormatHook(ctx context.Context, stage string, vol *apis.DeviceVolume,
+	devicePath string, mountInfo *MountInfo) error {
+	name, ok := formatHooks.hooks[stage]
+	if !ok {
+		return nil
+	}
+	path, err := resolveHook(formatHooks.dir, name)
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "%s hook of volume %s: %v", stage, vol.Name, err)
+	}
+	args := []string{path, devicePath, vol.Name, mountInfo.FSType, mountInfo.MountPath,
+		vol.Spec.Capacity, vol.Annotations[PVCNamespaceKey], vol.Annotations[PVCNameKey]}
+	klog.Infof("device: running the %s hook %s for volume %s", stage, path, vol.Name)
+	if _, err := RunCommandContext(ctx, args); err != nil {
+		return status.Errorf(codes.Internal, "%s hook %s failed for volume %s: %v", stage, path, vol.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestFormatHooks(t *testing.T) {
+	root, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	dir := filepath.Join(root, "hooks")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(root, "args")
+	writeScript := func(path, script string, mode os.FileMode) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeScript(filepath.Join(dir, "record"), `echo "$@" > `+out, 0755)
+	writeScript(filepath.Join(dir, "fail"), "echo cannot set the acl; exit 1", 0755)
+	writeScript(filepath.Join(dir, "not-executable"), "true", 0644)
+	writeScript(filepath.Join(root, "outside"), "true", 0755)
+	if err := os.Symlink(filepath.Join(dir, "record"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "outside"), filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = SetFormatHooks("", "", "") }()
+
+	setupTests := []struct {
+		name       string
+		dir        string
+		preFormat  string
+		postFormat string
+		wantErr    bool
+	}{
+		{name: "no hooks"},
+		{name: "hooks", dir: dir, preFormat: "record", postFormat: "link"},
+		{name: "no directory", postFormat: "record", wantErr: true},
+		{name: "missing hook", dir: dir, postFormat: "missing", wantErr: true},
+		{name: "not executable", dir: dir, postFormat: "not-executable", wantErr: true},
+		{name: "absolute path", dir: dir, postFormat: filepath.Join(dir, "record"), wantErr: true},
+		{name: "parent directory", dir: dir, postFormat: "../outside", wantErr: true},
+		{name: "symlink out of the directory", dir: dir, preFormat: "escape", wantErr: true},
+	}
+	for _, tt := range setupTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SetFormatHooks(tt.dir, tt.preFormat, tt.postFormat)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetFormatHooks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	vol := &apis.DeviceVolume{ObjectMeta: metav1.ObjectMeta{
+		Name:        "pvc-1",
+		Annotations: map[string]string{PVCNamespaceKey: "apps", PVCNameKey: "data"},
+	}}
+	vol.Spec.Capacity = "1073741824"
+	mountInfo := &MountInfo{FSType: "ext4", MountPath: "/var/lib/kubelet/pods/pod-1/volumes/kubernetes.io~csi/pvc-1/mount"}
+
+	if err := SetFormatHooks(dir, "", "record"); err != nil {
+		t.Fatal(err)
+	}
+	if err := runFormatHook(context.Background(), PreFormatHook, vol, "/dev/sdb2", mountInfo); err != nil {
+		t.Errorf("runFormatHook() without a hook got %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("expected no hook to run for the stage without one")
+	}
+	if err := runFormatHook(context.Background(), PostFormatHook, vol, "/dev/sdb2", mountInfo); err != nil {
+		t.Fatalf("runFormatHook() unexpected error %v", err)
+	}
+	got, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "/dev/sdb2 pvc-1 ext4 /var/lib/kubelet/pods/pod-1/volumes/kubernetes.io~csi/pvc-1/mount 1073741824 apps data"
+	if strings.TrimSpace(string(got)) != want {
+		t.Errorf("hook got arguments %q, want %q", strings.TrimSpace(string(got)), want)
+	}
+
+	if err := SetFormatHooks(dir, "fail", ""); err != nil {
+		t.Fatal(err)
+	}
+	err = runFormatHook(context.Background(), PreFormatHook, vol, "/dev/sdb2", mountInfo)
+	if status.Code(err) != codes.Internal || !strings.Contains(err.Error(), "cannot set the acl") {
+		t.Errorf("runFormatHook() of a failing hook got %v, want Internal with the output of the hook", err)
+	}
+
+	// the hook is resolved again when run, a link changed to point out of
+	// the directory is refused.
+	if err := SetFormatHooks(dir, "link", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "outside"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	err = runFormatHook(context.Background(), PreFormatHook, vol, "/dev/sdb2", mountInfo)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("runFormatHook() of a hook out of the directory got %v, want FailedPrecondition", err)
+	}
+}
+
+func TestPostFormatHookRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the hook fails on its first run only.
+	runs := filepath.Join(dir, "runs")
+	script := "#!/bin/sh\necho run >> " + runs + "\n[ $(wc -l < " + runs + ") -gt 1 ] || exit 1\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "flaky"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFormatHooks(dir, "", "flaky"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = SetFormatHooks("", "", "") }()
+
+	vol := &apis.DeviceVolume{ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"}}
+	vol.Status.Unformatted = true
+	mountInfo := &MountInfo{FSType: "ext4", MountPath: "/var/lib/kubelet/pods/pod-1/volumes/kubernetes.io~csi/pvc-1/mount"}
+
+	changed, err := runPostFormatHook(context.Background(), vol, "/dev/sdb2", mountInfo)
+	if status.Code(err) != codes.Internal || changed {
+		t.Fatalf("runPostFormatHook() of a failing hook got (%v, %v), want (false, Internal)", changed, err)
+	}
+	if !vol.Status.Unformatted {
+		t.Fatalf("volume got marked formatted although its post-format hook failed")
+	}
+
+	// the retry of the publish runs the hook again.
+	changed, err = runPostFormatHook(context.Background(), vol, "/dev/sdb2", mountInfo)
+	if err != nil || !changed {
+		t.Fatalf("runPostFormatHook() on the retry got (%v, %v), want (true, nil)", changed, err)
+	}
+	if vol.Status.Unformatted {
+		t.Errorf("volume still unformatted after its post-format hook succeeded")
+	}
+
+	// the hook doesn't run anymore once the volume got formatted.
+	changed, err = runPostFormatHook(context.Background(), vol, "/dev/sdb2", mountInfo)
+	if err != nil || changed {
+		t.Errorf("runPostFormatHook() of a formatted volume got (%v, %v), want (false, nil)", changed, err)
+	}
+	got, err := ioutil.ReadFile(runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(got), "run"); n != 2 {
+		t.Errorf("hook ran %d times, want 2", n)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+	"k8s.io/utils/mount"
+)
+
+// selfMountNamespace is the mount namespace of the plugin.
+const selfMountNamespace = "/proc/self/ns/mnt"
+
+// hostMountNamespace is the path of the mount namespace the volumes are
+// mounted in, e.g. /proc/1/ns/mnt. Empty means the namespace of the plugin.
+var hostMountNamespace string
+
+// SetHostMountNamespace makes the mounts of the volumes run in the mount
+// namespace at the given path via nsenter, so that they are visible to
+// kubelet even if the mount propagation of the plugin container is not
+// reliable. It fails if the namespace can't be entered. Empty path mounts
+// the volumes in the namespace of the plugin.
+func SetHostMountNamespace(path string) error {
+	if path == "" {
+		hostMountNamespace = ""
+		return nil
+	}
+	if err := checkHostMountNamespace(path, selfMountNamespace); err != nil {
+		return err
+	}
+	if out, err := newHostMounter(path).run("true"); err != nil {
+		return errors.Wrapf(err, "could not enter the host mount namespace %s, output %q", path, string(out))
+	}
+	hostMountNamespace = path
+	klog.Infof("mounting the volumes in the host mount namespace %s", path)
+	return nil
+}
+
+// checkHostMountNamespace checks that the namespace at path is accessible
+// and is not the namespace of the plugin itself, which is the case when the
+// pod doesn't share the PID namespace of the host.
+func checkHostMountNamespace(path, self string) error {
+	hostNS, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "host mount namespace %s is not accessible, "+
+			"the node plugin has to run privileged with hostPID", path)
+	}
+	selfNS, err := os.Stat(self)
+	if err != nil {
+		return errors.Wrapf(err, "could not get the mount namespace of the plugin")
+	}
+	if os.SameFile(hostNS, selfNS) {
+		return errors.Errorf("host mount namespace %s is the mount namespace of the plugin, "+
+			"the node plugin has to run with hostPID", path)
+	}
+	return nil
+}
+
+// newMounter returns the mounter of the volumes, which mounts them in the
+// host mount namespace if it is set.
+func newMounter() mount.Interface {
+	if hostMountNamespace == "" {
+		return mount.New("")
+	}
+	return newHostMounter(hostMountNamespace)
+}
+
+// IsMountPath returns true if path is a mount path in the mount namespace
+// the volumes are mounted in.
+func IsMountPath(path string) bool {
+	mounts, err := newMounter().List()
+	if err != nil {
+		return false
+	}
+	for _, mp := range mounts {
+		if mp.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// hostMounter mounts and unmounts in the given mount namespace via nsenter
+// and lists the mounts of the namespace. The other operations work on the
+// paths, which are shared with the host, so they are left to the default
+// mounter.
+type hostMounter struct {
+	mount.Interface
+
+	// namespace is the path of the mount namespace, e.g. /proc/1/ns/mnt
+	namespace string
+
+	// run runs the command in the namespace, it is replaced by the tests
+	run func(args ...string) ([]byte, error)
+}
+
+var _ mount.Interface = &hostMounter{}
+
+func newHostMounter(namespace string) *hostMounter {
+	m := &hostMounter{Interface: mount.New(""), namespace: namespace}
+	m.run = func(args ...string) ([]byte, error) {
+		return exec.Command("nsenter", m.nsenterArgs(args...)...).CombinedOutput()
+	}
+	return m
+}
+
+// nsenterArgs returns the nsenter arguments running the command in the
+// mount namespace.
+func (m *hostMounter) nsenterArgs(args ...string) []string {
+	return append([]string{"--mount=" + m.namespace, "--"}, args...)
+}
+
+// Mount mounts source at target in the mount namespace.
+func (m *hostMounter) Mount(source string, target string, fstype string, options []string) error {
+	return m.MountSensitive(source, target, fstype, options, nil)
+}
+
+// MountSensitive mounts source at target in the mount namespace, leaving
+// the sensitive options out of the logs and the errors.
+func (m *hostMounter) MountSensitive(source string, target string, fstype string,
+	options []string, sensitiveOptions []string) error {
+	args, logStr := mount.MakeMountArgsSensitive(source, target, fstype, options, sensitiveOptions)
+	klog.V(4).Infof("mounting in %s with arguments (%s)", m.namespace, logStr)
+	if out, err := m.run(append([]string{"mount"}, args...)...); err != nil {
+		return fmt.Errorf("mount in %s failed: %v\nMounting arguments: %s\nOutput: %s",
+			m.namespace, err, logStr, string(out))
+	}
+	return nil
+}
+
+// Unmount unmounts target in the mount namespace.
+func (m *hostMounter) Unmount(target string) error {
+	klog.V(4).Infof("unmounting %s in %s", target, m.namespace)
+	if out, err := m.run("umount", target); err != nil {
+		return fmt.Errorf("unmount in %s failed: %v\nUnmounting arguments: %s\nOutput: %s",
+			m.namespace, err, target, string(out))
+	}
+	return nil
+}
+
+// List lists the mounts of the mount namespace, from the mounts file of
+// the process owning it, e.g. /proc/1/mounts for /proc/1/ns/mnt.
+func (m *hostMounter) List() ([]mount.MountPoint, error) {
+	return mount.ListProcMounts(filepath.Join(filepath.Dir(filepath.Dir(m.namespace)), "mounts"))
+}
@@ -0,0 +1,110 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/mount"
+)
+
+// fakeHostMounter returns a hostMounter recording the commands it runs in
+// the namespace instead of running them.
+func fakeHostMounter(namespace string) (*hostMounter, *[][]string) {
+	var commands [][]string
+	m := newHostMounter(namespace)
+	m.run = func(args ...string) ([]byte, error) {
+		commands = append(commands, m.nsenterArgs(args...))
+		return nil, nil
+	}
+	return m, &commands
+}
+
+func TestHostMounterMount(t *testing.T) {
+	m, commands := fakeHostMounter("/proc/1/ns/mnt")
+
+	assert.NoError(t, m.Mount("/dev/sdb2", "/var/lib/kubelet/pods/pod-1/volumes/pvc-1", "ext4", []string{"ro"}))
+	assert.NoError(t, m.Unmount("/var/lib/kubelet/pods/pod-1/volumes/pvc-1"))
+
+	assert.Equal(t, [][]string{
+		{"--mount=/proc/1/ns/mnt", "--", "mount", "-t", "ext4", "-o", "ro",
+			"/dev/sdb2", "/var/lib/kubelet/pods/pod-1/volumes/pvc-1"},
+		{"--mount=/proc/1/ns/mnt", "--", "umount", "/var/lib/kubelet/pods/pod-1/volumes/pvc-1"},
+	}, *commands)
+}
+
+func TestHostMounterList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hostns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = os.MkdirAll(filepath.Join(dir, "1", "ns"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mounts := "/dev/sdb2 /var/lib/kubelet/pods/pod-1/volumes/pvc-1 ext4 rw,relatime 0 0\n"
+	if err = ioutil.WriteFile(filepath.Join(dir, "1", "mounts"), []byte(mounts), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _ := fakeHostMounter(filepath.Join(dir, "1", "ns", "mnt"))
+	list, err := m.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []mount.MountPoint{{
+		Device: "/dev/sdb2",
+		Path:   "/var/lib/kubelet/pods/pod-1/volumes/pvc-1",
+		Type:   "ext4",
+		Opts:   []string{"rw", "relatime"},
+	}}, list)
+}
+
+func TestCheckHostMountNamespace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hostns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	host, self := filepath.Join(dir, "host"), filepath.Join(dir, "self")
+	for _, path := range []string{host, self} {
+		if err = ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.NoError(t, checkHostMountNamespace(host, self))
+	assert.Error(t, checkHostMountNamespace(self, self), "namespace of the plugin")
+	assert.Error(t, checkHostMountNamespace(filepath.Join(dir, "missing"), self))
+}
+
+func TestNewMounter(t *testing.T) {
+	defer func() { hostMountNamespace = "" }()
+
+	_, ok := newMounter().(*hostMounter)
+	assert.False(t, ok)
+
+	hostMountNamespace = "/proc/1/ns/mnt"
+	m, ok := newMounter().(*hostMounter)
+	if assert.True(t, ok) {
+		assert.Equal(t, "/proc/1/ns/mnt", m.namespace)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog"
+)
+
+// IDMappedMountsTopologyKey is the topology key telling whether the volumes
+// can be idmapped into user namespaced pods on the node.
+const IDMappedMountsTopologyKey = "openebs.io/idmapped-mounts"
+
+// kernelVersion is the major and minor version of the linux kernel.
+type kernelVersion struct {
+	major, minor int
+}
+
+func (v kernelVersion) atLeast(o kernelVersion) bool {
+	return v.major > o.major || (v.major == o.major && v.minor >= o.minor)
+}
+
+// idmapMinKernel is the first kernel version supporting idmapped mounts
+// for the filesystem.
+var idmapMinKernel = map[string]kernelVersion{
+	"ext4":  {5, 12},
+	"xfs":   {5, 12},
+	"btrfs": {5, 15},
+}
+
+// idmapKernel is the kernel version found by ProbeIDMappedMounts, it is
+// left zero when idmapped mounts are disabled.
+var idmapKernel kernelVersion
+
+// ProbeIDMappedMounts checks if the running kernel supports idmapped mounts
+// and enables them for the supported filesystems. It returns false if the
+// kernel is too old, in which case the ownership of the volumes is left to
+// the fsGroup handling of kubelet.
+func ProbeIDMappedMounts() bool {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		klog.Warningf("idmapped mounts: could not get the kernel version: %v", err)
+		return false
+	}
+	release := unix.ByteSliceToString(uts.Release[:])
+	v, err := parseKernelVersion(release)
+	if err != nil {
+		klog.Warningf("idmapped mounts: %v", err)
+		return false
+	}
+	if !v.atLeast(idmapMinKernel["ext4"]) {
+		klog.Infof("idmapped mounts: not supported by kernel %s, falling back to fsGroup ownership change", release)
+		return false
+	}
+	idmapKernel = v
+	klog.Infof("idmapped mounts: supported by kernel %s", release)
+	return true
+}
+
+// SupportsIDMappedMounts checks if volumes of the filesystem type can be
+// idmapped on this node.
+func SupportsIDMappedMounts(fsType string) bool {
+	if fsType == "" {
+		fsType = "ext4"
+	}
+	min, ok := idmapMinKernel[fsType]
+	return ok && idmapKernel.atLeast(min)
+}
+
+// parseKernelVersion parses the major and minor version from a kernel
+// release like 5.15.0-91-generic.
+func parseKernelVersion(release string) (kernelVersion, error) {
+	var v kernelVersion
+	if _, err := fmt.Sscanf(release, "%d.%d", &v.major, &v.minor); err != nil {
+		return v, fmt.Errorf("invalid kernel release %q: %v", release, err)
+	}
+	return v, nil
+}
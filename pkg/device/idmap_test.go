@@ -0,0 +1,61 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+)
+
+func Test_SupportsIDMappedMounts(t *testing.T) {
+	defer func(v kernelVersion) { idmapKernel = v }(idmapKernel)
+
+	tests := []struct {
+		name      string
+		release   string
+		fsType    string
+		supported bool
+	}{
+		{name: "disabled", release: "", fsType: "ext4", supported: false},
+		{name: "old kernel", release: "5.4.0-150-generic", fsType: "ext4", supported: false},
+		{name: "ext4", release: "5.12.0", fsType: "ext4", supported: true},
+		{name: "default fs type", release: "5.15.0-91-generic", fsType: "", supported: true},
+		{name: "btrfs on 5.12", release: "5.12.19", fsType: "btrfs", supported: false},
+		{name: "btrfs on 6.1", release: "6.1.0-13-amd64", fsType: "btrfs", supported: true},
+		{name: "unknown fs type", release: "6.1.0", fsType: "ext3", supported: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idmapKernel = kernelVersion{}
+			if tt.release != "" {
+				v, err := parseKernelVersion(tt.release)
+				if err != nil {
+					t.Fatalf("parseKernelVersion() error = %v", err)
+				}
+				idmapKernel = v
+			}
+			if got := SupportsIDMappedMounts(tt.fsType); got != tt.supported {
+				t.Errorf("SupportsIDMappedMounts() got = %v, want %v", got, tt.supported)
+			}
+		})
+	}
+}
+
+func Test_parseKernelVersion(t *testing.T) {
+	if _, err := parseKernelVersion("unknown"); err == nil {
+		t.Errorf("parseKernelVersion() expected error for invalid release")
+	}
+}
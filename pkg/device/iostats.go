@@ -0,0 +1,74 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+)
+
+// PartitionStatPath is the sysfs file holding the IO statistics of a
+// partition of a disk, relative to SysRoot.
+const PartitionStatPath = "block/%s/%s/stat"
+
+// IOStats holds the IO statistics of a partition. The sectors are always
+// 512 bytes long, whatever the sector size of the disk.
+type IOStats struct {
+	ReadOps      uint64
+	ReadSectors  uint64
+	WriteOps     uint64
+	WriteSectors uint64
+}
+
+// GetPartitionIOStats reads the IO statistics of the partition. The error
+// satisfies os.IsNotExist if the partition doesn't expose them.
+func GetPartitionIOStats(part PartUsed) (IOStats, error) {
+	out, err := ioutil.ReadFile(sysfsPath(PartitionStatPath,
+		part.DiskName, filepath.Base(part.DevicePath)))
+	if err != nil {
+		return IOStats{}, err
+	}
+	return parseIOStats(string(out))
+}
+
+// parseIOStats decodes the stat file of a block device. Its fields are the
+// read ios, read merges, read sectors, read ticks, write ios, write merges
+// and write sectors, followed by others depending on the kernel version.
+func parseIOStats(content string) (IOStats, error) {
+	fields := strings.Fields(content)
+	if len(fields) < 7 {
+		return IOStats{}, errors.Errorf("invalid block device stat %q", content)
+	}
+	var values [7]uint64
+	for i := range values {
+		value, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return IOStats{}, errors.Wrapf(err, "invalid block device stat %q", content)
+		}
+		values[i] = value
+	}
+	return IOStats{
+		ReadOps:      values[0],
+		ReadSectors:  values[2],
+		WriteOps:     values[4],
+		WriteSectors: values[6],
+	}, nil
+}
@@ -0,0 +1,55 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseIOStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    IOStats
+		wantErr bool
+	}{
+		{
+			name:    "kernel 5.x stat",
+			content: "    4521     1203   402124     2341     9876     3012  1204450    21003        0    12040    23344        0        0        0        0      120       45\n",
+			want:    IOStats{ReadOps: 4521, ReadSectors: 402124, WriteOps: 9876, WriteSectors: 1204450},
+		},
+		{
+			name:    "kernel 4.x stat",
+			content: "     120        0     3072      200       40        4      512      100        0      300      300\n",
+			want:    IOStats{ReadOps: 120, ReadSectors: 3072, WriteOps: 40, WriteSectors: 512},
+		},
+		{name: "truncated stat", content: "120 0 3072", wantErr: true},
+		{name: "invalid stat", content: "120 0 many 200 40 4 512", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIOStats(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIOStats() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseIOStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// Layout is the partition layout of the disks of a node carrying the meta
+// partition, along with the DeviceVolumes of the partitions, exported for
+// recreating the DeviceVolumes on a rebuilt node having the same disks.
+type Layout struct {
+	// Node is the node the layout got exported from.
+	Node    string         `json:"node"`
+	Disks   []LayoutDisk   `json:"disks"`
+	Volumes []LayoutVolume `json:"volumes"`
+}
+
+// LayoutDisk is a disk of the layout along with its partitions.
+type LayoutDisk struct {
+	// WWN is the world wide identifier of the disk, empty if it has none.
+	WWN string `json:"wwn,omitempty"`
+	// UUID is the identifier of the disk, i.e. the GPT disk identifier or
+	// the WWID of a multipath device.
+	UUID string `json:"uuid"`
+	// Device is the device name of the meta partition of the disk.
+	Device string `json:"device"`
+	// SectorSize is the logical sector size of the disk.
+	SectorSize uint64            `json:"sectorSize"`
+	Partitions []LayoutPartition `json:"partitions"`
+}
+
+// LayoutPartition is a partition of a disk of the layout.
+type LayoutPartition struct {
+	Number uint32 `json:"number"`
+	// UUID is the unique GUID of the partition.
+	UUID        string `json:"uuid"`
+	Name        string `json:"name"`
+	StartSector uint64 `json:"startSector"`
+	EndSector   uint64 `json:"endSector"`
+	// Volume is the DeviceVolume the partition belongs to, empty for the
+	// meta partition and the partitions not managed by the driver.
+	Volume string `json:"volume,omitempty"`
+}
+
+// LayoutVolume is a DeviceVolume of the layout.
+type LayoutVolume struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Spec        apis.VolumeInfo   `json:"spec"`
+	State       string            `json:"state"`
+	Capacity    string            `json:"capacity,omitempty"`
+	// AppliedAttributes and RootDirInitialized are kept, so that the
+	// attributes and the mode of the root directory are not applied again,
+	// along with Unformatted, the partitions being imported as they are.
+	AppliedAttributes  map[string]string `json:"appliedAttributes,omitempty"`
+	RootDirInitialized bool              `json:"rootDirInitialized,omitempty"`
+	Unformatted        bool              `json:"unformatted,omitempty"`
+}
+
+// partitionVolumeName returns the name of the DeviceVolume the partition
+// named partitionName belongs to, i.e. the volume of its growth reserve or
+// of the striped volume it is a member of.
+func partitionVolumeName(partitionName string) string {
+	name := strings.TrimSuffix(partitionName, ReservePartitionSuffix)
+	name = stripeMemberRegex.ReplaceAllString(name, "")
+	return "pvc-" + name
+}
+
+// PartitionVolumeName returns the name of the DeviceVolume the partition
+// named partitionName belongs to, the partitions taking part in the
+// relocation of the volume included.
+func PartitionVolumeName(partitionName string) string {
+	for _, suffix := range []string{relocatingSuffix, copiedSuffix, relocatedSuffix} {
+		partitionName = strings.TrimSuffix(partitionName, suffix)
+	}
+	return partitionVolumeName(partitionName)
+}
+
+// MatchesDevName checks if the device of the meta partition name is
+// matched by the devname of a volume, a regular expression, the way
+// GetPartitionList matches the disks of the volume.
+func MatchesDevName(devName, diskMetaName string) bool {
+	devRegex, err := regexp.Compile(devName)
+	if err != nil {
+		return false
+	}
+	return devRegex.MatchString(diskMetaName)
+}
+
+// ExportLayout exports the partition layout of the disks of the node
+// carrying the meta partition, along with the DeviceVolumes of the node
+// having their partitions on them.
+func ExportLayout() (*Layout, error) {
+	if NodeID == "" {
+		return nil, errors.New("node id is not set, the layout has to be exported from the node agent")
+	}
+	disks, err := listLayoutDisks()
+	if err != nil {
+		return nil, err
+	}
+	volList, err := ListDeviceVolumes()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list the volumes")
+	}
+	return buildLayout(NodeID, disks, volList.Items), nil
+}
+
+// listLayoutDisks returns the disks of the node carrying the meta
+// partition, along with their partitions read from the primary GPT.
+func listLayoutDisks() ([]LayoutDisk, error) {
+	diskList, err := getDiskList()
+	if err != nil {
+		return nil, err
+	}
+	var disks []LayoutDisk
+	for _, disk := range diskList {
+		metaName, err := getDiskMetaName(disk.DiskName)
+		if err != nil {
+			continue
+		}
+		id, err := getDiskIdentifier(disk.DiskName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get the identifier of disk %s", disk.DiskName)
+		}
+		sectorSize := getDiskSectorSize(disk.DiskName)
+		_, entries, err := readGPTTable(devicePath(disk.DiskName), sectorSize)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read the partition table of disk %s", disk.DiskName)
+		}
+		layoutDisk := LayoutDisk{
+			WWN:        getDiskWWN(disk.DiskName),
+			UUID:       id,
+			Device:     metaName,
+			SectorSize: sectorSize,
+		}
+		for _, entry := range entries {
+			layoutDisk.Partitions = append(layoutDisk.Partitions, LayoutPartition{
+				Number:      entry.number,
+				UUID:        entry.guid,
+				Name:        entry.name,
+				StartSector: entry.first,
+				EndSector:   entry.last,
+			})
+		}
+		disks = append(disks, layoutDisk)
+	}
+	return disks, nil
+}
+
+// buildLayout returns the layout of the disks of the node, along with the
+// volumes of the node having their partitions on the disks.
+func buildLayout(node string, disks []LayoutDisk, volumes []apis.DeviceVolume) *Layout {
+	owned := map[string]*apis.DeviceVolume{}
+	for i := range volumes {
+		if volumes[i].Spec.OwnerNodeID == node && volumes[i].DeletionTimestamp == nil {
+			owned[volumes[i].Name] = &volumes[i]
+		}
+	}
+
+	layout := &Layout{Node: node}
+	exported := map[string]bool{}
+	for _, disk := range disks {
+		for i, part := range disk.Partitions {
+			if part.Number == 1 || part.Name == "" {
+				continue
+			}
+			volName := PartitionVolumeName(part.Name)
+			vol, ok := owned[volName]
+			if !ok || !MatchesDevName(vol.Spec.DevName, disk.Device) {
+				continue
+			}
+			disk.Partitions[i].Volume = volName
+			if !exported[volName] {
+				exported[volName] = true
+				layout.Volumes = append(layout.Volumes, LayoutVolume{
+					Name:               vol.Name,
+					Labels:             vol.Labels,
+					Annotations:        vol.Annotations,
+					Spec:               vol.Spec,
+					State:              vol.Status.State,
+					Capacity:           vol.Status.Capacity,
+					AppliedAttributes:  vol.Status.AppliedAttributes,
+					RootDirInitialized: vol.Status.RootDirInitialized,
+					Unformatted:        vol.Status.Unformatted,
+				})
+			}
+		}
+		layout.Disks = append(layout.Disks, disk)
+	}
+	return layout
+}
+
+// ImportLayout recreates the DeviceVolumes of the layout on the node,
+// owned by the node. The disks of the layout are matched with the disks of
+// the node by their identifier, or by their WWN, and the partitions of the
+// volumes have to be found on them as exported, else nothing is created.
+// The volumes already present are left untouched. It returns the created
+// volumes.
+func ImportLayout(layout *Layout) ([]*apis.DeviceVolume, error) {
+	if NodeID == "" {
+		return nil, errors.New("node id is not set, the layout has to be imported from the node agent")
+	}
+	local, err := listLayoutDisks()
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := layoutVolumes(layout, local, NodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []*apis.DeviceVolume
+	for _, vol := range volumes {
+		if _, err = GetDeviceVolume(vol.Name); err == nil {
+			klog.Infof("volume %s already exists, leaving it as is", vol.Name)
+			continue
+		} else if !k8serror.IsNotFound(err) {
+			return created, err
+		}
+		imported, err := ProvisionVolume(vol)
+		if err != nil {
+			return created, errors.Wrapf(err, "could not create volume %s", vol.Name)
+		}
+		klog.Infof("imported volume %s from the layout of node %s", vol.Name, layout.Node)
+		created = append(created, imported)
+	}
+	return created, nil
+}
+
+// matchLayoutDisk returns the local disk matching the disk of the layout,
+// by its identifier or else by its WWN.
+func matchLayoutDisk(disk LayoutDisk, local []LayoutDisk) (LayoutDisk, bool) {
+	for _, l := range local {
+		if l.UUID == disk.UUID {
+			return l, true
+		}
+	}
+	if disk.WWN == "" {
+		return LayoutDisk{}, false
+	}
+	for _, l := range local {
+		if l.WWN == disk.WWN {
+			return l, true
+		}
+	}
+	return LayoutDisk{}, false
+}
+
+// checkLayoutPartitions checks that the partitions of the volumes on the
+// disk of the layout are present on the local disk as exported.
+func checkLayoutPartitions(disk, local LayoutDisk) error {
+	if disk.Device != local.Device {
+		return errors.Errorf("disk %s carries the meta partition of device %s instead of %s",
+			disk.UUID, local.Device, disk.Device)
+	}
+	if disk.SectorSize != local.SectorSize {
+		return errors.Errorf("disk %s has sectors of %d bytes instead of %d",
+			disk.UUID, local.SectorSize, disk.SectorSize)
+	}
+	parts := map[uint32]LayoutPartition{}
+	for _, part := range local.Partitions {
+		parts[part.Number] = part
+	}
+	for _, part := range disk.Partitions {
+		if part.Volume == "" {
+			continue
+		}
+		found, ok := parts[part.Number]
+		if !ok {
+			return errors.Errorf("partition %d of volume %s is missing on disk %s", part.Number, part.Volume, disk.UUID)
+		}
+		found.Volume = part.Volume
+		if found != part {
+			return errors.Errorf("partition %d of volume %s on disk %s changed: found %+v, exported %+v",
+				part.Number, part.Volume, disk.UUID, found, part)
+		}
+	}
+	return nil
+}
+
+// layoutVolumes returns the DeviceVolumes of the layout to be created on
+// the node, after checking their partitions on the local disks.
+func layoutVolumes(layout *Layout, local []LayoutDisk, node string) ([]*apis.DeviceVolume, error) {
+	// the disk holding the first partition of each volume
+	volDisks := map[string]LayoutDisk{}
+	for _, disk := range layout.Disks {
+		var volumes []string
+		for _, part := range disk.Partitions {
+			if part.Volume != "" {
+				volumes = append(volumes, part.Volume)
+			}
+		}
+		if len(volumes) == 0 {
+			continue
+		}
+		l, ok := matchLayoutDisk(disk, local)
+		if !ok {
+			return nil, errors.Errorf("disk %s (wwn %q) holding volumes %s is not found on the node",
+				disk.UUID, disk.WWN, strings.Join(volumes, ", "))
+		}
+		if err := checkLayoutPartitions(disk, l); err != nil {
+			return nil, err
+		}
+		for _, volName := range volumes {
+			if _, ok := volDisks[volName]; !ok {
+				volDisks[volName] = l
+			}
+		}
+	}
+
+	var volumes []*apis.DeviceVolume
+	for _, lv := range layout.Volumes {
+		disk, ok := volDisks[lv.Name]
+		if !ok {
+			return nil, errors.Errorf("volume %s has no partition in the layout", lv.Name)
+		}
+		labels := map[string]string{}
+		for key, value := range lv.Labels {
+			labels[key] = value
+		}
+		labels[DeviceNodeKey] = node
+		vol := &apis.DeviceVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        lv.Name,
+				Namespace:   DeviceNamespace,
+				Labels:      labels,
+				Annotations: lv.Annotations,
+				Finalizers:  []string{DeviceFinalizer},
+			},
+			Spec: lv.Spec,
+			Status: apis.VolStatus{
+				State:              lv.State,
+				Capacity:           lv.Capacity,
+				DiskUUID:           disk.UUID,
+				DiskWWN:            disk.WWN,
+				AppliedAttributes:  lv.AppliedAttributes,
+				RootDirInitialized: lv.RootDirInitialized,
+				Unformatted:        lv.Unformatted,
+			},
+		}
+		vol.Spec.OwnerNodeID = node
+		volumes = append(volumes, vol)
+	}
+	return volumes, nil
+}
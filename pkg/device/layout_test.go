@@ -0,0 +1,254 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func Test_readGPTTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "disk.img")
+
+	writeGPT(t, path, 512, 128, 0)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// partition 3, with the linux filesystem type
+	entry := data[512*2+2*gptDefaultEntrySize:]
+	copy(entry, []byte{0xaf, 0x3d, 0xc6, 0x0f, 0x83, 0x84, 0x72, 0x47, 0x8e, 0x79, 0x3d, 0x69, 0xd8, 0x47, 0x7d, 0xe4})
+	copy(entry[16:], []byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x78, 0x56, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78})
+	binary.LittleEndian.PutUint64(entry[32:], 4096)
+	binary.LittleEndian.PutUint64(entry[40:], 8191)
+	for i, unit := range utf16.Encode([]rune("0f9a2c3e-volume")) {
+		binary.LittleEndian.PutUint16(entry[56+2*i:], unit)
+	}
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	count, entries, err := readGPTTable(path, 512)
+	if err != nil {
+		t.Fatalf("readGPTTable() unexpected error %v", err)
+	}
+	want := []gptEntry{{number: 3, guid: "12345678-1234-5678-9abc-def012345678", first: 4096, last: 8191, name: "0f9a2c3e-volume"}}
+	if count != 128 || !reflect.DeepEqual(entries, want) {
+		t.Errorf("readGPTTable() = %d, %+v, want 128, %+v", count, entries, want)
+	}
+}
+
+func Test_partitionVolumeName(t *testing.T) {
+	tests := map[string]string{
+		"0f9a2c3e":         "pvc-0f9a2c3e",
+		"0f9a2c3e-reserve": "pvc-0f9a2c3e",
+		"0f9a2c3e-m1":      "pvc-0f9a2c3e",
+		"0f9a2c3e-m12":     "pvc-0f9a2c3e",
+	}
+	for name, want := range tests {
+		if got := partitionVolumeName(name); got != want {
+			t.Errorf("partitionVolumeName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func Test_layoutRoundTrip(t *testing.T) {
+	disks := func() []LayoutDisk {
+		return []LayoutDisk{
+			{WWN: "0x5000c500a1b2c3d4", UUID: "5a6c7e8f-disk-1", Device: "test-device", SectorSize: 512,
+				Partitions: []LayoutPartition{
+					{Number: 1, UUID: "guid-1", Name: "test-device", StartSector: 2048, EndSector: 4095},
+					{Number: 2, UUID: "guid-2", Name: "data", StartSector: 4096, EndSector: 2101247},
+					{Number: 3, UUID: "guid-3", Name: "data-reserve", StartSector: 2101248, EndSector: 4198399},
+					{Number: 4, UUID: "guid-4", Name: "unmanaged", StartSector: 4198400, EndSector: 4200447},
+				}},
+			{UUID: "5a6c7e8f-disk-2", Device: "test-device", SectorSize: 512,
+				Partitions: []LayoutPartition{
+					{Number: 1, UUID: "guid-5", Name: "test-device", StartSector: 2048, EndSector: 4095},
+				}},
+		}
+	}
+	volumes := []apis.DeviceVolume{
+		{
+			Spec:   apis.VolumeInfo{OwnerNodeID: "node-1", DevName: "test-device", Capacity: "1073741824"},
+			Status: apis.VolStatus{State: DeviceStatusReady, Capacity: "1073741824", RootDirInitialized: true},
+		},
+		// the volume of another node
+		{Spec: apis.VolumeInfo{OwnerNodeID: "node-2", DevName: "test-device"}},
+	}
+	volumes[0].Name = "pvc-data"
+	volumes[0].Labels = map[string]string{DeviceNodeKey: "node-1", "team": "db"}
+	volumes[1].Name = "pvc-unmanaged"
+
+	layout := buildLayout("node-1", disks(), volumes)
+	data, err := json.Marshal(layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var imported Layout
+	if err = json.Unmarshal(data, &imported); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&imported, layout) {
+		t.Fatalf("layout changed in the round trip: %+v, want %+v", imported, *layout)
+	}
+
+	var inLayout []string
+	for _, part := range layout.Disks[0].Partitions {
+		inLayout = append(inLayout, part.Volume)
+	}
+	if want := []string{"", "pvc-data", "pvc-data", ""}; !reflect.DeepEqual(inLayout, want) {
+		t.Errorf("volumes of the partitions = %q, want %q", inLayout, want)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(local []LayoutDisk) []LayoutDisk
+		wantErr bool
+	}{
+		{name: "same disks", change: func(local []LayoutDisk) []LayoutDisk { return local }},
+		{name: "disk listed in another order", change: func(local []LayoutDisk) []LayoutDisk {
+			return []LayoutDisk{local[1], local[0]}
+		}},
+		{name: "disk matched by wwn", change: func(local []LayoutDisk) []LayoutDisk {
+			local[0].UUID = "5a6c7e8f-disk-3"
+			return local
+		}},
+		{name: "unmanaged partition changed", change: func(local []LayoutDisk) []LayoutDisk {
+			local[0].Partitions = local[0].Partitions[:3]
+			return local
+		}},
+		{name: "disk missing", wantErr: true, change: func(local []LayoutDisk) []LayoutDisk {
+			return local[1:]
+		}},
+		{name: "partition moved", wantErr: true, change: func(local []LayoutDisk) []LayoutDisk {
+			local[0].Partitions[1].StartSector = 8192
+			return local
+		}},
+		{name: "partition recreated", wantErr: true, change: func(local []LayoutDisk) []LayoutDisk {
+			local[0].Partitions[1].UUID = "guid-6"
+			return local
+		}},
+		{name: "reserve deleted", wantErr: true, change: func(local []LayoutDisk) []LayoutDisk {
+			local[0].Partitions = local[0].Partitions[:2]
+			return local
+		}},
+		{name: "disk of another device", wantErr: true, change: func(local []LayoutDisk) []LayoutDisk {
+			local[0].Device = "other-device"
+			return local
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := layoutVolumes(&imported, tt.change(disks()), "node-3")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("layoutVolumes() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("layoutVolumes() unexpected error %v", err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("layoutVolumes() = %d volumes, want 1", len(got))
+			}
+			vol := got[0]
+			if vol.Name != "pvc-data" || vol.Spec.OwnerNodeID != "node-3" || vol.Labels[DeviceNodeKey] != "node-3" ||
+				vol.Labels["team"] != "db" || vol.Spec.Capacity != "1073741824" {
+				t.Errorf("layoutVolumes() = %+v", vol)
+			}
+			if vol.Status.State != DeviceStatusReady || vol.Status.Capacity != "1073741824" ||
+				!vol.Status.RootDirInitialized || vol.Status.DiskWWN != "0x5000c500a1b2c3d4" {
+				t.Errorf("layoutVolumes() status = %+v", vol.Status)
+			}
+			if !reflect.DeepEqual(vol.Finalizers, []string{DeviceFinalizer}) {
+				t.Errorf("layoutVolumes() finalizers = %v", vol.Finalizers)
+			}
+		})
+	}
+}
+
+func Test_buildLayoutMatchedVolumes(t *testing.T) {
+	disks := []LayoutDisk{
+		{UUID: "5a6c7e8f-disk-1", Device: "test-device-1", SectorSize: 512,
+			Partitions: []LayoutPartition{
+				{Number: 1, UUID: "guid-1", Name: "test-device-1", StartSector: 2048, EndSector: 4095},
+				{Number: 2, UUID: "guid-2", Name: "data", StartSector: 4096, EndSector: 2101247},
+			}},
+		{UUID: "5a6c7e8f-disk-2", Device: "test-device-2", SectorSize: 512,
+			Partitions: []LayoutPartition{
+				{Number: 1, UUID: "guid-3", Name: "test-device-2", StartSector: 2048, EndSector: 4095},
+				{Number: 2, UUID: "guid-4", Name: "data" + relocatingSuffix, StartSector: 4096, EndSector: 2101247},
+				{Number: 3, UUID: "guid-5", Name: "logs", StartSector: 2101248, EndSector: 4198399},
+			}},
+	}
+	volumes := []apis.DeviceVolume{
+		// matched by the devname regex on both disks
+		{Spec: apis.VolumeInfo{OwnerNodeID: "node-1", DevName: "test-device-[0-9]"}},
+		// the devname matches none of the disks
+		{Spec: apis.VolumeInfo{OwnerNodeID: "node-1", DevName: "other-device"}},
+	}
+	volumes[0].Name = "pvc-data"
+	volumes[1].Name = "pvc-logs"
+
+	layout := buildLayout("node-1", disks, volumes)
+	var inLayout []string
+	for _, disk := range layout.Disks {
+		for _, part := range disk.Partitions {
+			inLayout = append(inLayout, part.Volume)
+		}
+	}
+	if want := []string{"", "pvc-data", "", "pvc-data", ""}; !reflect.DeepEqual(inLayout, want) {
+		t.Errorf("volumes of the partitions = %q, want %q", inLayout, want)
+	}
+	if len(layout.Volumes) != 1 || layout.Volumes[0].Name != "pvc-data" {
+		t.Errorf("exported volumes = %+v, want pvc-data only", layout.Volumes)
+	}
+}
+
+func TestMatchesDevName(t *testing.T) {
+	tests := []struct {
+		devName, device string
+		want            bool
+	}{
+		{devName: "test-device", device: "test-device", want: true},
+		{devName: "test-device", device: "test-device-2", want: true},
+		{devName: "nvme.*", device: "nvme-1", want: true},
+		{devName: "^nvme-1$", device: "nvme-10", want: false},
+		{devName: "nvme.*", device: "test-device", want: false},
+		{devName: "[", device: "test-device", want: false},
+	}
+	for _, tt := range tests {
+		if got := MatchesDevName(tt.devName, tt.device); got != tt.want {
+			t.Errorf("MatchesDevName(%q, %q) = %v, want %v", tt.devName, tt.device, got, tt.want)
+		}
+	}
+}
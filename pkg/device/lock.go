@@ -0,0 +1,92 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+// diskLocks serializes the changes of the partition tables per disk. Running
+// parted on a disk from multiple threads can leave partitions behind, see
+// https://github.com/openebs/device-localpv/issues/21, while the changes on
+// different disks are independent.
+var diskLocks = struct {
+	sync.Mutex
+	locks map[string]*sync.Mutex
+}{locks: map[string]*sync.Mutex{}}
+
+// lockDisks locks the given disks, in sorted order to not deadlock with
+// another caller locking an overlapping set of disks. It returns the
+// function unlocking them.
+func lockDisks(disks []string) func() {
+	sorted := append([]string{}, disks...)
+	sort.Strings(sorted)
+
+	var held []*sync.Mutex
+	for _, disk := range sorted {
+		diskLocks.Lock()
+		lock, ok := diskLocks.locks[disk]
+		if !ok {
+			lock = &sync.Mutex{}
+			diskLocks.locks[disk] = lock
+		}
+		diskLocks.Unlock()
+
+		lock.Lock()
+		held = append(held, lock)
+	}
+	return func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].Unlock()
+		}
+	}
+}
+
+// CountDisks returns the number of disks on the node, zero if they can't be
+// listed.
+func CountDisks() int {
+	diskList, err := getDiskList()
+	if err != nil {
+		return 0
+	}
+	return len(diskList)
+}
+
+// lockMetaDisks locks the disks having a meta partition matching the device
+// name, i.e. all the disks a partition of the device can be created on or
+// deleted from.
+func lockMetaDisks(diskMetaName string) (func(), error) {
+	diskList, err := getDiskList()
+	if err != nil {
+		return nil, err
+	}
+	var disks []string
+	for _, disk := range diskList {
+		tmpList, err := GetPartitionList(disk.DiskName, diskMetaName, false)
+		if err != nil || len(tmpList) == 0 {
+			continue
+		}
+		if _, ok := getMetaPartition(tmpList[0]); ok {
+			disks = append(disks, disk.DiskName)
+		}
+	}
+	klog.V(4).Infof("locking disks %v of device %s", disks, diskMetaName)
+	return lockDisks(disks), nil
+}
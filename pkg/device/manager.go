@@ -0,0 +1,162 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// DeviceManager discovers the disks of the node and operates on their
+// partitions. The controllers of the node agent go through it instead of
+// the package functions, so that their reconcile logic can be tested
+// without root or real disks.
+type DeviceManager interface {
+	// GetDiskDetails discovers the devices of the node.
+	GetDiskDetails() ([]apis.Device, error)
+
+	// ListSignedDevices returns the UUIDs of the disks left out by the
+	// last discovery for carrying a foreign signature.
+	ListSignedDevices() []string
+
+	// ListDiskWWNs returns the world wide identifiers by the disk names.
+	ListDiskWWNs() (map[string]string, error)
+
+	// ListDiskLayouts reads the partitions of the disks carrying the meta
+	// partition from their partition tables.
+	ListDiskLayouts() ([]LayoutDisk, error)
+
+	// IsDiskPartition checks if the device path is a partition of the
+	// disk.
+	IsDiskPartition(disk, devicePath string) bool
+
+	// GetDiskPartitionPath finds the device path of the partition of the
+	// disk.
+	GetDiskPartitionPath(disk, partitionName string) (string, error)
+
+	// SetExcludedDevices sets the UUIDs of the disks not to be used for
+	// new partitions.
+	SetExcludedDevices(uuids []string)
+
+	// SetQuarantinedDevices sets the names of the devices marked as bad,
+	// not to be used for new partitions.
+	SetQuarantinedDevices(names map[string]bool)
+
+	// SetProtectedDevices sets the number of protected leading bytes of
+	// the devices.
+	SetProtectedDevices(devices map[string]uint64)
+
+	// SetDeviceWeights sets the scheduling weights of the devices.
+	SetDeviceWeights(weights map[string]int32)
+
+	// GrowPartitionTables grows the partition tables of the devices to
+	// the given number of entries.
+	GrowPartitionTables(entries map[string]int32) error
+
+	// CreateVolume creates the partition of the volume.
+	CreateVolume(vol *apis.DeviceVolume) error
+
+	// DestroyVolume deletes the partition of the volume.
+	DestroyVolume(vol *apis.DeviceVolume) error
+
+	// ApplyVolumeAttributes applies the mutable attributes modified after
+	// the creation of the volume to its partition.
+	ApplyVolumeAttributes(vol *apis.DeviceVolume) error
+
+	// RelocateVolume runs the next step of the move of the partition of
+	// the volume to another disk, the copy of its data being aborted when
+	// the context is done.
+	RelocateVolume(ctx context.Context, vol *apis.DeviceVolume) error
+
+	// BackfillVolumeStatus fills the status fields missing on the volumes
+	// created by the older releases from the partition of the volume.
+	BackfillVolumeStatus(vol *apis.DeviceVolume) (bool, error)
+}
+
+// hostDeviceManager operates on the disks of the host.
+type hostDeviceManager struct{}
+
+// NewDeviceManager returns the DeviceManager operating on the disks of the
+// host.
+func NewDeviceManager() DeviceManager {
+	return hostDeviceManager{}
+}
+
+func (hostDeviceManager) GetDiskDetails() ([]apis.Device, error) {
+	return GetDiskDetails()
+}
+
+func (hostDeviceManager) ListSignedDevices() []string {
+	return ListSignedDevices()
+}
+
+func (hostDeviceManager) ListDiskWWNs() (map[string]string, error) {
+	return ListDiskWWNs()
+}
+
+func (hostDeviceManager) ListDiskLayouts() ([]LayoutDisk, error) {
+	return listLayoutDisks()
+}
+
+func (hostDeviceManager) IsDiskPartition(disk, devicePath string) bool {
+	return IsDiskPartition(disk, devicePath)
+}
+
+func (hostDeviceManager) GetDiskPartitionPath(disk, partitionName string) (string, error) {
+	return GetDiskPartitionPath(disk, partitionName)
+}
+
+func (hostDeviceManager) SetExcludedDevices(uuids []string) {
+	SetExcludedDevices(uuids)
+}
+
+func (hostDeviceManager) SetQuarantinedDevices(names map[string]bool) {
+	SetQuarantinedDevices(names)
+}
+
+func (hostDeviceManager) SetProtectedDevices(devices map[string]uint64) {
+	SetProtectedDevices(devices)
+}
+
+func (hostDeviceManager) SetDeviceWeights(weights map[string]int32) {
+	SetDeviceWeights(weights)
+}
+
+func (hostDeviceManager) GrowPartitionTables(entries map[string]int32) error {
+	return GrowPartitionTables(entries)
+}
+
+func (hostDeviceManager) CreateVolume(vol *apis.DeviceVolume) error {
+	return CreateVolume(vol)
+}
+
+func (hostDeviceManager) DestroyVolume(vol *apis.DeviceVolume) error {
+	return DestroyVolume(vol)
+}
+
+func (hostDeviceManager) ApplyVolumeAttributes(vol *apis.DeviceVolume) error {
+	return ApplyVolumeAttributes(vol)
+}
+
+func (hostDeviceManager) RelocateVolume(ctx context.Context, vol *apis.DeviceVolume) error {
+	return RelocateVolume(ctx, vol)
+}
+
+func (hostDeviceManager) BackfillVolumeStatus(vol *apis.DeviceVolume) (bool, error) {
+	return BackfillVolumeStatus(vol)
+}
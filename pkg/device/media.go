@@ -0,0 +1,172 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"golang.org/x/sys/unix"
+	"k8s.io/klog"
+)
+
+// Sources of the media type of the disks
+const (
+	MediaTypeSourceSysfs     = "sysfs"
+	MediaTypeSourceBenchmark = "benchmark"
+)
+
+const (
+	// benchmarkReads is the number of the random reads of the benchmark,
+	// taking a fraction of a second even on a rotational disk.
+	benchmarkReads = 32
+	// benchmarkBlockSize is the size of the reads of the benchmark
+	benchmarkBlockSize = 4096
+	// benchmarkSSDLatency is the median random read latency below which
+	// a disk is classified as ssd. Rotational disks need several
+	// milliseconds to seek.
+	benchmarkSSDLatency = 2 * time.Millisecond
+)
+
+// mediaBenchmark classifies the media type of the disks by benchmarking
+// their random read latency, when sysfs reports them as rotational or
+// doesn't report them at all, which is common for virtual disks. The
+// results are cached by the disk identifier, so every disk is benchmarked
+// once.
+type mediaBenchmark struct {
+	mtx     sync.Mutex
+	enabled bool
+	results map[string]string
+	// run returns the media type of the disk of the given size
+	run func(diskName string, size uint64) (string, error)
+}
+
+var benchmark = &mediaBenchmark{results: map[string]string{}, run: benchmarkMediaType}
+
+// SetMediaBenchmark enables the classification of the media type of the
+// disks reported as rotational, or not reported, by sysfs with a random
+// read latency benchmark.
+func SetMediaBenchmark(enabled bool) {
+	benchmark.mtx.Lock()
+	defer benchmark.mtx.Unlock()
+	benchmark.enabled = enabled
+}
+
+// getMediaType returns the media type of the disk and its source. The
+// rotational attribute is trusted when it reports a ssd.
+func getMediaType(disk diskDetail, id string) (string, string) {
+	mediaType := getDiskMediaType(disk.DiskName)
+	if mediaType == MediaTypeSSD {
+		return mediaType, MediaTypeSourceSysfs
+	}
+	if benchmarked, ok := benchmark.classify(disk, id); ok {
+		return benchmarked, MediaTypeSourceBenchmark
+	}
+	if mediaType == "" {
+		return "", ""
+	}
+	return mediaType, MediaTypeSourceSysfs
+}
+
+// classify returns the media type of the disk from the benchmark, running
+// it unless it is cached. It returns false if the benchmark is disabled or
+// failed.
+func (b *mediaBenchmark) classify(disk diskDetail, id string) (string, bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if !b.enabled {
+		return "", false
+	}
+	if mediaType, ok := b.results[id]; ok {
+		return mediaType, true
+	}
+	mediaType, err := b.run(disk.DiskName, disk.Size)
+	if err != nil {
+		klog.Warningf("Device LocalPV: could not benchmark media type of %s: %v", disk.DiskName, err)
+		return "", false
+	}
+	klog.Infof("Device LocalPV: classified %s as %s by benchmark", disk.DiskName, mediaType)
+	b.results[id] = mediaType
+	return mediaType, true
+}
+
+// benchmarkMediaType classifies the disk by the median latency of random
+// reads spread over the disk. The reads bypass the page cache and nothing
+// is written to the disk.
+func benchmarkMediaType(diskName string, size uint64) (string, error) {
+	latencies, err := measureReadLatencies(devicePath(diskName), size, benchmarkReads)
+	if err != nil {
+		return "", err
+	}
+	return classifyLatency(medianLatency(latencies)), nil
+}
+
+// measureReadLatencies reads count blocks at random aligned offsets of the
+// device with direct IO and returns the latency of every read.
+func measureReadLatencies(devicePath string, size uint64, count int) ([]time.Duration, error) {
+	blocks := int64(size / benchmarkBlockSize)
+	if blocks == 0 {
+		return nil, errors.Errorf("device %s is too small to benchmark", devicePath)
+	}
+	f, err := os.OpenFile(devicePath, os.O_RDONLY|unix.O_DIRECT, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// direct IO needs a buffer aligned to the logical block size, which
+	// an anonymous mapping is, being page aligned.
+	buf, err := unix.Mmap(-1, 0, benchmarkBlockSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = unix.Munmap(buf) }()
+
+	latencies := make([]time.Duration, 0, count)
+	for i := 0; i < count; i++ {
+		offset := rand.Int63n(blocks) * benchmarkBlockSize
+		start := time.Now()
+		if _, err := f.ReadAt(buf, offset); err != nil {
+			return nil, errors.Wrapf(err, "read %s at %d", devicePath, offset)
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	return latencies, nil
+}
+
+// medianLatency returns the median of the latencies, which ignores the
+// outliers like the reads hitting the cache of the disk.
+func medianLatency(latencies []time.Duration) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// classifyLatency classifies the media by its median random read latency.
+func classifyLatency(latency time.Duration) string {
+	if latency < benchmarkSSDLatency {
+		return MediaTypeSSD
+	}
+	return MediaTypeHDD
+}
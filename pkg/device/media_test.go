@@ -0,0 +1,74 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMedianLatency(t *testing.T) {
+	assert.Equal(t, time.Duration(0), medianLatency(nil))
+	assert.Equal(t, 5*time.Millisecond, medianLatency([]time.Duration{
+		8 * time.Millisecond, 100 * time.Microsecond, 5 * time.Millisecond,
+	}))
+	assert.Equal(t, 300*time.Microsecond, medianLatency([]time.Duration{
+		200 * time.Microsecond, 20 * time.Millisecond, 300 * time.Microsecond, 250 * time.Microsecond,
+	}))
+}
+
+func TestClassifyLatency(t *testing.T) {
+	assert.Equal(t, MediaTypeSSD, classifyLatency(150*time.Microsecond))
+	assert.Equal(t, MediaTypeSSD, classifyLatency(time.Millisecond))
+	assert.Equal(t, MediaTypeHDD, classifyLatency(benchmarkSSDLatency))
+	assert.Equal(t, MediaTypeHDD, classifyLatency(8*time.Millisecond))
+}
+
+func TestMediaBenchmarkClassify(t *testing.T) {
+	runs := 0
+	b := &mediaBenchmark{results: map[string]string{}}
+	b.run = func(diskName string, size uint64) (string, error) {
+		runs++
+		return MediaTypeSSD, nil
+	}
+	disk := diskDetail{DiskName: "sdb", Size: 1 << 30}
+
+	_, ok := b.classify(disk, "disk-1")
+	assert.False(t, ok, "disabled benchmark")
+	assert.Equal(t, 0, runs)
+
+	b.enabled = true
+	mediaType, ok := b.classify(disk, "disk-1")
+	assert.True(t, ok)
+	assert.Equal(t, MediaTypeSSD, mediaType)
+
+	// the disk got renamed, the result is cached by its identifier
+	mediaType, ok = b.classify(diskDetail{DiskName: "sdc", Size: 1 << 30}, "disk-1")
+	assert.True(t, ok)
+	assert.Equal(t, MediaTypeSSD, mediaType)
+	assert.Equal(t, 1, runs)
+
+	b.run = func(diskName string, size uint64) (string, error) {
+		return "", assert.AnError
+	}
+	_, ok = b.classify(disk, "disk-2")
+	assert.False(t, ok, "failed benchmark")
+	_, cached := b.results["disk-2"]
+	assert.False(t, cached)
+}
@@ -16,11 +16,15 @@ limitations under the License.
 package device
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 
 	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
-	mnt "github.com/openebs/lib-csi/pkg/mount"
+	"github.com/openebs/lib-csi/pkg/common/errors"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"k8s.io/klog"
@@ -49,11 +53,106 @@ type MountInfo struct {
 	MountOptions []string `json:"mountOptions"`
 }
 
-// FormatAndMountVol formats and mounts the created volume to the desired mount path
-func FormatAndMountVol(devicePath string, mountInfo *MountInfo) error {
-	mounter := &mount.SafeFormatAndMount{Interface: mount.New(""), Exec: utilexec.New()}
+// publishedMount is a mount of the partition of a volume at a publish
+// target.
+type publishedMount struct {
+	Path     string
+	ReadOnly bool
+}
 
-	err := mounter.FormatAndMount(devicePath, mountInfo.MountPath, mountInfo.FSType, mountInfo.MountOptions)
+// getPublishedMounts lists the filesystem mounts of the partition.
+func getPublishedMounts(devicePath string) ([]publishedMount, error) {
+	mounts, err := newMounter().List()
+	if err != nil {
+		return nil, err
+	}
+	var published []publishedMount
+	for _, mp := range mounts {
+		if mp.Device == devicePath {
+			published = append(published, publishedMount{
+				Path:     mp.Path,
+				ReadOnly: hasMountOption(mp.Opts, "ro"),
+			})
+		}
+	}
+	return published, nil
+}
+
+// checkPublishMode allows a volume to be published read-only at multiple
+// targets for readers, or read-write, but not both at the same time, so
+// that readers never see the filesystem changing under them.
+func checkPublishMode(mounts []publishedMount, target string, readOnly bool) error {
+	for _, mp := range mounts {
+		if mp.Path == target || mp.ReadOnly == readOnly {
+			continue
+		}
+		if readOnly {
+			return status.Errorf(codes.FailedPrecondition,
+				"volume is published read-write at %s, can't publish it read-only", mp.Path)
+		}
+		return status.Errorf(codes.FailedPrecondition,
+			"volume is published read-only at %s, can't publish it read-write", mp.Path)
+	}
+	return nil
+}
+
+// FilesystemReserve is the command setting the percentage of the blocks of
+// an ext3/ext4 filesystem reserved for the super-user
+const FilesystemReserve = "tune2fs -m %s %s"
+
+// contextExec runs the commands of the formatter, like mkfs, within the
+// context, so that they are killed when the request they serve is aborted.
+// formatArgs are passed to the mkfs commands, which the formatter runs
+// with fixed options.
+type contextExec struct {
+	utilexec.Interface
+	ctx        context.Context
+	formatArgs []string
+}
+
+// Command returns the command bound to the context.
+func (e contextExec) Command(cmd string, args ...string) utilexec.Cmd {
+	if strings.HasPrefix(cmd, "mkfs.") && len(args) > 0 && len(e.formatArgs) > 0 {
+		// the device goes last, anything after it is taken as the size
+		// of the filesystem.
+		last := len(args) - 1
+		args = append(append(append([]string{}, args[:last]...), e.formatArgs...), args[last])
+	}
+	return e.Interface.CommandContext(e.ctx, cmd, args...)
+}
+
+// FormatAndMountVol formats and mounts the created volume to the desired mount path.
+// reservedBlocksPercent and bytesPerInode are applied to the ext3/ext4
+// filesystems created by it, and the label to any filesystem supporting
+// one. The formatting waits for a format slot and is aborted when the
+// context is done.
+func FormatAndMountVol(ctx context.Context, devicePath string, mountInfo *MountInfo,
+	reservedBlocksPercent, bytesPerInode, label string) error {
+	mounter := &mount.SafeFormatAndMount{Interface: newMounter(), Exec: contextExec{
+		Interface:  utilexec.New(),
+		ctx:        ctx,
+		formatArgs: getFormatArgs(mountInfo.FSType, bytesPerInode, label),
+	}}
+
+	existingFormat, err := mounter.GetDiskFormat(devicePath)
+	if err != nil {
+		klog.Errorf("device: failed to get the format of %s, error %v", devicePath, err)
+		return err
+	}
+
+	if existingFormat == "" {
+		// the filesystem gets created, which waits for a format slot.
+		release, err := formats.acquire(ctx)
+		if err != nil {
+			klog.Errorf("device: failed to format volume %s, error %v", devicePath, err)
+			return err
+		}
+		defer release()
+	}
+
+	err = mountWithRetry(ctx, devicePath, func() error {
+		return mounter.FormatAndMount(devicePath, mountInfo.MountPath, mountInfo.FSType, mountInfo.MountOptions)
+	})
 	if err != nil {
 		klog.Errorf(
 			"device: failed to mount volume %s [%s] to %s, error %v",
@@ -62,13 +161,105 @@ func FormatAndMountVol(devicePath string, mountInfo *MountInfo) error {
 		return err
 	}
 
+	if existingFormat == "" && needsReservedBlocks(mountInfo.FSType, reservedBlocksPercent) {
+		// the formatter creates ext3/ext4 filesystems with no reserved
+		// blocks, so the percentage is set once the filesystem is created.
+		_, err = RunCommandContext(ctx, strings.Split(fmt.Sprintf(FilesystemReserve, reservedBlocksPercent, devicePath), " "))
+		if err != nil {
+			klog.Errorf("device: failed to reserve %s%% of the blocks of %s, error %v",
+				reservedBlocksPercent, devicePath, err)
+			return err
+		}
+	}
+
 	return nil
 }
 
+// needsReservedBlocks checks if the filesystem needs its reserved blocks
+// to be set after its creation.
+func needsReservedBlocks(fsType, reservedBlocksPercent string) bool {
+	if fsType == "" {
+		// kubernetes defaults to ext4
+		fsType = "ext4"
+	}
+	if fsType != "ext3" && fsType != "ext4" {
+		return false
+	}
+	return reservedBlocksPercent != "" && reservedBlocksPercent != "0"
+}
+
+// getFormatArgs returns the extra mkfs arguments of the filesystem, which
+// set the bytes-per-inode ratio of the ext filesystems and the label of
+// the filesystem. The label is truncated to the limit of the filesystem,
+// and left out of the filesystems not supporting one.
+func getFormatArgs(fsType, bytesPerInode, label string) []string {
+	var args []string
+	if isExtFilesystem(fsType) && bytesPerInode != "" {
+		args = append(args, "-i", bytesPerInode)
+	}
+	if label != "" {
+		truncated, err := TruncateFsLabel(fsType, label)
+		switch {
+		case err != nil:
+			klog.Warningf("device: not setting label %q: %v", label, err)
+		case truncated != label:
+			klog.Warningf("device: label %q truncated to %q, the limit of %s", label, truncated, fsType)
+			fallthrough
+		default:
+			args = append(args, "-L", truncated)
+		}
+	}
+	return args
+}
+
+// initRootDir sets the mode of the root directory of the filesystem of the
+// volume on its first use, i.e. while the filesystem holds nothing but the
+// lost+found directory created by mkfs, and marks it in the volume so that
+// the later changes of the users are kept. Read-only mounts are left for
+// the first read-write one. It returns true if the volume got marked, for
+// the caller to record it.
+func initRootDir(vol *apis.DeviceVolume, mountInfo *MountInfo) (bool, error) {
+	if vol.Spec.RootDirMode == "" || vol.Status.RootDirInitialized ||
+		hasMountOption(mountInfo.MountOptions, "ro") {
+		return false, nil
+	}
+	empty, err := isFreshRootDir(mountInfo.MountPath)
+	if err != nil {
+		return false, err
+	}
+	if empty {
+		mode, err := strconv.ParseUint(vol.Spec.RootDirMode, 8, 32)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid root directory mode %q", vol.Spec.RootDirMode)
+		}
+		if err = os.Chmod(mountInfo.MountPath, os.FileMode(mode)); err != nil {
+			return false, err
+		}
+		klog.Infof("device: set mode %s of the root directory of volume %s", vol.Spec.RootDirMode, vol.Name)
+	}
+	vol.Status.RootDirInitialized = true
+	return true, nil
+}
+
+// isFreshRootDir checks if the directory holds nothing but the lost+found
+// directory, as left by mkfs.
+func isFreshRootDir(path string) (bool, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Name() != "lost+found" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // UmountVolume unmounts the volume and the corresponding mount path is removed
 func UmountVolume(vol *apis.DeviceVolume, targetPath string,
 ) error {
-	mounter := &mount.SafeFormatAndMount{Interface: mount.New(""), Exec: utilexec.New()}
+	mounter := &mount.SafeFormatAndMount{Interface: newMounter(), Exec: utilexec.New()}
 
 	dev, ref, err := mount.GetDeviceNameFromMount(mounter, targetPath)
 	if err != nil {
@@ -142,7 +333,7 @@ func verifyMountRequest(vol *apis.DeviceVolume, mountpath string) (bool, error)
 	 * be unmounted before proceeding to the mount
 	 * operation.
 	 */
-	currentMounts, err := mnt.GetMounts(devicePath)
+	currentMounts, err := getPublishedMounts(devicePath)
 	if err != nil {
 		klog.Errorf("can not get mounts for volume:%s dev %s err: %v",
 			vol.Name, devicePath, err.Error())
@@ -150,7 +341,7 @@ func verifyMountRequest(vol *apis.DeviceVolume, mountpath string) (bool, error)
 	} else if len(currentMounts) >= 1 {
 		// if device is already mounted at the mount point, return successful
 		for _, mp := range currentMounts {
-			if mp == mountpath {
+			if mp.Path == mountpath {
 				return true, nil
 			}
 		}
@@ -158,8 +349,9 @@ func verifyMountRequest(vol *apis.DeviceVolume, mountpath string) (bool, error)
 	return false, nil
 }
 
-// MountVolume mounts the disk to the specified path
-func MountVolume(vol *apis.DeviceVolume, mount *MountInfo) error {
+// MountVolume mounts the disk to the specified path, formatting it within
+// the context
+func MountVolume(ctx context.Context, vol *apis.DeviceVolume, mount *MountInfo) error {
 	volume := vol.Name
 	mounted, err := verifyMountRequest(vol, mount.MountPath)
 	if err != nil {
@@ -168,7 +360,16 @@ func MountVolume(vol *apis.DeviceVolume, mount *MountInfo) error {
 
 	if mounted {
 		klog.Infof("device : already mounted %s => %s", volume, mount.MountPath)
-		return nil
+		if !vol.Status.Unformatted {
+			return nil
+		}
+		// the post-format hook failed on the earlier publish, which left
+		// the new filesystem mounted, the hook runs again.
+		devicePath, err := GetVolumeDevPath(vol)
+		if err != nil {
+			return status.Error(codes.Internal, "Not able to find the device Path")
+		}
+		return completeFirstMount(ctx, vol, devicePath, mount)
 	}
 
 	devicePath, err := GetVolumeDevPath(vol)
@@ -176,23 +377,83 @@ func MountVolume(vol *apis.DeviceVolume, mount *MountInfo) error {
 		return status.Error(codes.Internal, "Not able to find the device Path")
 	}
 
-	err = FormatAndMountVol(devicePath, mount)
+	mounts, err := getPublishedMounts(devicePath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "could not list the mounts of %s: %v", devicePath, err)
+	}
+	if err = checkPublishMode(mounts, mount.MountPath, hasMountOption(mount.MountOptions, "ro")); err != nil {
+		return err
+	}
+
+	// the hooks run on the first mount of the new volumes only.
+	if vol.Status.Unformatted {
+		if err = runFormatHook(ctx, PreFormatHook, vol, devicePath, mount); err != nil {
+			return err
+		}
+	}
+
+	err = FormatAndMountVol(ctx, devicePath, mount, vol.Spec.ReservedBlocksPercent, vol.Spec.BytesPerInode,
+		vol.Spec.FsLabel)
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if IsUnmountable(err) {
+		return err
+	}
 	if err != nil {
 		return status.Error(codes.Internal, "not able to format and mount the volume")
 	}
 
+	if err = completeFirstMount(ctx, vol, devicePath, mount); err != nil {
+		return err
+	}
+
 	klog.Infof("device: volume %v mounted %v fs %v", volume, mount.MountPath, mount.FSType)
 
 	return err
 }
 
-// MountFilesystem mounts the disk to the specified path
-func MountFilesystem(vol *apis.DeviceVolume, mount *MountInfo) error {
+// completeFirstMount initializes the root directory of the mounted volume
+// and runs the post-format hook of the new volumes, recording the changes
+// of the status of the volume.
+func completeFirstMount(ctx context.Context, vol *apis.DeviceVolume, devicePath string, mount *MountInfo) error {
+	initialized, err := initRootDir(vol, mount)
+	if err != nil {
+		return status.Errorf(codes.Internal, "could not initialize the root directory of the volume: %v", err)
+	}
+	formatted, hookErr := runPostFormatHook(ctx, vol, devicePath, mount)
+	if formatted || initialized {
+		if err = UpdateVolume(vol); err != nil {
+			return status.Errorf(codes.Internal, "could not record the first mount of the volume: %v", err)
+		}
+	}
+	return hookErr
+}
+
+// runPostFormatHook runs the post-format hook of the volume if its
+// filesystem got created by this mount, and clears its Unformatted status
+// once the hook succeeds. The volume is left unformatted if the hook fails,
+// so that the hook runs again when the publish is retried. It returns true
+// if the status of the volume changed.
+func runPostFormatHook(ctx context.Context, vol *apis.DeviceVolume, devicePath string, mount *MountInfo) (bool, error) {
+	if !vol.Status.Unformatted {
+		return false, nil
+	}
+	if err := runFormatHook(ctx, PostFormatHook, vol, devicePath, mount); err != nil {
+		return false, err
+	}
+	vol.Status.Unformatted = false
+	return true, nil
+}
+
+// MountFilesystem mounts the disk to the specified path, formatting it
+// within the context
+func MountFilesystem(ctx context.Context, vol *apis.DeviceVolume, mount *MountInfo) error {
 	if err := os.MkdirAll(mount.MountPath, 0755); err != nil {
 		return status.Errorf(codes.Internal, "Could not create dir {%q}, err: %v", mount.MountPath, err)
 	}
 
-	return MountVolume(vol, mount)
+	return MountVolume(ctx, vol, mount)
 }
 
 // MountBlock mounts the block disk to the specified path
@@ -204,8 +465,11 @@ func MountBlock(vol *apis.DeviceVolume, mountinfo *MountInfo) error {
 	}
 
 	mountopt := []string{"bind"}
+	if hasMountOption(mountinfo.MountOptions, "ro") {
+		mountopt = append(mountopt, "ro")
+	}
 
-	mounter := &mount.SafeFormatAndMount{Interface: mount.New(""), Exec: utilexec.New()}
+	mounter := &mount.SafeFormatAndMount{Interface: newMounter(), Exec: utilexec.New()}
 
 	// Create the mount point as a file since bind mount device node requires it to be a file
 	err = makeFile(target)
@@ -0,0 +1,275 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	utilexec "k8s.io/utils/exec"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func Test_checkPublishMode(t *testing.T) {
+	readers := []publishedMount{{Path: "/pods/reader-1", ReadOnly: true}, {Path: "/pods/reader-2", ReadOnly: true}}
+	writer := []publishedMount{{Path: "/pods/writer", ReadOnly: false}}
+
+	tests := []struct {
+		name     string
+		mounts   []publishedMount
+		target   string
+		readOnly bool
+		allowed  bool
+	}{
+		{name: "first read-write publish", target: "/pods/writer", readOnly: false, allowed: true},
+		{name: "first read-only publish", target: "/pods/reader-1", readOnly: true, allowed: true},
+		{name: "more readers", mounts: readers, target: "/pods/reader-3", readOnly: true, allowed: true},
+		{name: "writer while readers", mounts: readers, target: "/pods/writer", readOnly: false, allowed: false},
+		{name: "reader while writer", mounts: writer, target: "/pods/reader-1", readOnly: true, allowed: false},
+		{name: "republish of reader", mounts: readers, target: "/pods/reader-1", readOnly: true, allowed: true},
+		{name: "another writer", mounts: writer, target: "/pods/writer-2", readOnly: false, allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPublishMode(tt.mounts, tt.target, tt.readOnly)
+			if tt.allowed {
+				if err != nil {
+					t.Errorf("checkPublishMode() unexpected error %v", err)
+				}
+				return
+			}
+			if status.Code(err) != codes.FailedPrecondition {
+				t.Errorf("checkPublishMode() got %v, want FailedPrecondition", err)
+			}
+		})
+	}
+}
+
+func Test_needsReservedBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsType  string
+		percent string
+		want    bool
+	}{
+		{name: "ext4", fsType: "ext4", percent: "5", want: true},
+		{name: "ext3", fsType: "ext3", percent: "5", want: true},
+		{name: "default fs type", fsType: "", percent: "5", want: true},
+		{name: "xfs", fsType: "xfs", percent: "5", want: false},
+		{name: "no reserved blocks", fsType: "ext4", percent: "0", want: false},
+		{name: "volume created before the parameter", fsType: "ext4", percent: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsReservedBlocks(tt.fsType, tt.percent); got != tt.want {
+				t.Errorf("needsReservedBlocks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isFreshRootDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rootdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	check := func(want bool) {
+		t.Helper()
+		got, err := isFreshRootDir(dir)
+		if err != nil {
+			t.Fatalf("isFreshRootDir() unexpected error %v", err)
+		}
+		if got != want {
+			t.Errorf("isFreshRootDir() = %v, want %v", got, want)
+		}
+	}
+
+	check(true)
+	if err = os.Mkdir(filepath.Join(dir, "lost+found"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	check(true)
+	if err = ioutil.WriteFile(filepath.Join(dir, "data"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	check(false)
+
+	if _, err = isFreshRootDir(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("isFreshRootDir() expected error for a missing directory")
+	}
+}
+
+func Test_contextExec(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// the formatter runs mkfs through Command, which must be aborted at
+	// the deadline of the request.
+	start := time.Now()
+	e := contextExec{Interface: utilexec.New(), ctx: ctx}
+	if _, err := e.Command("sleep", "10").CombinedOutput(); err == nil {
+		t.Errorf("expected command to be aborted at the deadline")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected command to be killed at the deadline, took %v", elapsed)
+	}
+}
+
+// recordingExec records the commands run through it instead of running
+// them.
+type recordingExec struct {
+	utilexec.Interface
+	commands [][]string
+}
+
+func (e *recordingExec) CommandContext(ctx context.Context, cmd string, args ...string) utilexec.Cmd {
+	e.commands = append(e.commands, append([]string{cmd}, args...))
+	return e.Interface.CommandContext(ctx, "true")
+}
+
+func Test_contextExecFormatArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		fsType string
+		ratio  string
+		label  string
+		cmd    []string
+		want   []string
+	}{
+		{
+			name:   "ext4 with bytes per inode",
+			fsType: "ext4", ratio: "4096",
+			cmd:  []string{"mkfs.ext4", "-F", "-m0", "/dev/sdb2"},
+			want: []string{"mkfs.ext4", "-F", "-m0", "-i", "4096", "/dev/sdb2"},
+		},
+		{
+			name:   "default fs type",
+			fsType: "", ratio: "8192",
+			cmd:  []string{"mkfs.ext4", "-F", "-m0", "/dev/sdb2"},
+			want: []string{"mkfs.ext4", "-F", "-m0", "-i", "8192", "/dev/sdb2"},
+		},
+		{
+			name:   "default ratio",
+			fsType: "ext4", ratio: "",
+			cmd:  []string{"mkfs.ext4", "-F", "-m0", "/dev/sdb2"},
+			want: []string{"mkfs.ext4", "-F", "-m0", "/dev/sdb2"},
+		},
+		{
+			name:   "xfs",
+			fsType: "xfs", ratio: "4096",
+			cmd:  []string{"mkfs.xfs", "/dev/sdb2"},
+			want: []string{"mkfs.xfs", "/dev/sdb2"},
+		},
+		{
+			name:   "ext4 with label",
+			fsType: "ext4", ratio: "4096", label: "pvc-data",
+			cmd:  []string{"mkfs.ext4", "-F", "-m0", "/dev/sdb2"},
+			want: []string{"mkfs.ext4", "-F", "-m0", "-i", "4096", "-L", "pvc-data", "/dev/sdb2"},
+		},
+		{
+			name:   "ext4 label truncated",
+			fsType: "ext4", label: "pvc-0123456789abcdef",
+			cmd:  []string{"mkfs.ext4", "-F", "-m0", "/dev/sdb2"},
+			want: []string{"mkfs.ext4", "-F", "-m0", "-L", "pvc-0123456789ab", "/dev/sdb2"},
+		},
+		{
+			name:   "xfs label truncated",
+			fsType: "xfs", ratio: "4096", label: "pvc-0123456789abcdef",
+			cmd:  []string{"mkfs.xfs", "/dev/sdb2"},
+			want: []string{"mkfs.xfs", "-L", "pvc-01234567", "/dev/sdb2"},
+		},
+		{
+			name:   "label not supported",
+			fsType: "vfat", label: "data",
+			cmd:  []string{"mkfs.vfat", "/dev/sdb2"},
+			want: []string{"mkfs.vfat", "/dev/sdb2"},
+		},
+		{
+			name:   "other commands",
+			fsType: "ext4", ratio: "4096", label: "data",
+			cmd:  []string{"blkid", "-p", "/dev/sdb2"},
+			want: []string{"blkid", "-p", "/dev/sdb2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingExec{Interface: utilexec.New()}
+			e := contextExec{Interface: rec, ctx: context.Background(), formatArgs: getFormatArgs(tt.fsType, tt.ratio, tt.label)}
+			if _, err := e.Command(tt.cmd[0], tt.cmd[1:]...).CombinedOutput(); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(rec.commands) != 1 || !reflect.DeepEqual(rec.commands[0], tt.want) {
+				t.Errorf("ran %v, want %v", rec.commands, tt.want)
+			}
+		})
+	}
+}
+
+func Test_initRootDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rootdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name        string
+		mode        string
+		initialized bool
+		options     []string
+		want        bool
+	}{
+		{name: "first mount", mode: "0770", want: true},
+		{name: "no mode", mode: "", want: false},
+		{name: "already initialized", mode: "0770", initialized: true, want: false},
+		{name: "read-only mount", mode: "0770", options: []string{"ro"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vol := &apis.DeviceVolume{}
+			vol.Spec.RootDirMode = tt.mode
+			vol.Status.RootDirInitialized = tt.initialized
+			got, err := initRootDir(vol, &MountInfo{MountPath: dir, MountOptions: tt.options})
+			if err != nil {
+				t.Fatalf("initRootDir() unexpected error %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("initRootDir() = %v, want %v", got, tt.want)
+			}
+			if tt.want && !vol.Status.RootDirInitialized {
+				t.Errorf("initRootDir() did not mark the volume")
+			}
+		})
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0770 {
+		t.Errorf("root directory mode = %v, want 0770", info.Mode().Perm())
+	}
+}
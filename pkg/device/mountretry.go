@@ -0,0 +1,163 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+	"k8s.io/utils/mount"
+)
+
+// DeviceSettle waits for the udev events of the devices to be processed,
+// so that the device node of a new partition is in place.
+const DeviceSettle = "udevadm settle"
+
+// FilesystemUnmountableReason is the reason of the warning events recorded
+// on the volumes whose filesystem can't be mounted for being corrupt or
+// unsupported.
+const FilesystemUnmountableReason = "FilesystemUnmountable"
+
+// Classes of the mount failures
+const (
+	// mountErrorUnknown is a failure of unknown cause, left to the retries
+	// of kubelet.
+	mountErrorUnknown = iota
+	// mountErrorTransient is a failure which goes away once the device
+	// settles, e.g. its device node is not created yet.
+	mountErrorTransient
+	// mountErrorFatal is a failure which retrying doesn't fix, e.g. a
+	// corrupt or an unsupported filesystem.
+	mountErrorFatal
+)
+
+// messages of the mount failures, as printed by mount(8) and the kernel.
+var (
+	fatalMountMessages = []string{
+		"wrong fs type",
+		"bad superblock",
+		"unknown filesystem type",
+		"structure needs cleaning",
+		"can't read superblock",
+	}
+	transientMountMessages = []string{
+		"no such device or address",
+		"no such file or directory",
+		"does not exist",
+		"device or resource busy",
+	}
+)
+
+// mountRetries is the number of times a mount failing for a transient
+// cause is retried, waiting for the devices to settle in between, after
+// mountRetryBackoff doubled on each retry.
+var (
+	mountRetries      = 3
+	mountRetryBackoff = time.Second
+)
+
+// SetMountRetry sets the number of times a mount failing for a transient
+// cause is retried and the backoff before the first retry, doubled on each
+// retry. Zero retries fails the mount on the first failure.
+func SetMountRetry(retries int, backoff time.Duration) {
+	mountRetries = retries
+	mountRetryBackoff = backoff
+}
+
+// classifyMountError returns the class of the failure of a mount.
+func classifyMountError(err error) int {
+	if mountErr, ok := err.(mount.MountError); ok {
+		switch mountErr.Type {
+		case mount.HasFilesystemErrors, mount.FilesystemMismatch, mount.UnformattedReadOnly:
+			return mountErrorFatal
+		case mount.GetDiskFormatFailed:
+			return mountErrorTransient
+		}
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range fatalMountMessages {
+		if strings.Contains(msg, m) {
+			return mountErrorFatal
+		}
+	}
+	for _, m := range transientMountMessages {
+		if strings.Contains(msg, m) {
+			return mountErrorTransient
+		}
+	}
+	return mountErrorUnknown
+}
+
+// unmountableError is the failure of a mount for a corrupt or an
+// unsupported filesystem.
+type unmountableError struct {
+	err error
+}
+
+func (e *unmountableError) Error() string {
+	return "filesystem can't be mounted: " + e.err.Error()
+}
+
+// GRPCStatus fails the request with FailedPrecondition, as the filesystem
+// has to be repaired first.
+func (e *unmountableError) GRPCStatus() *status.Status {
+	return status.New(codes.FailedPrecondition, e.Error())
+}
+
+// IsUnmountable checks if the mount failed for a corrupt or an unsupported
+// filesystem, which retrying doesn't fix.
+func IsUnmountable(err error) bool {
+	_, ok := err.(*unmountableError)
+	return ok
+}
+
+// mountWithRetry mounts with mountFn, retrying the transient failures after
+// the devices settle. The fatal failures are returned as unmountableError
+// right away.
+func mountWithRetry(ctx context.Context, devicePath string, mountFn func() error) error {
+	backoff := mountRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := mountFn()
+		if err == nil {
+			return nil
+		}
+		switch classifyMountError(err) {
+		case mountErrorFatal:
+			return &unmountableError{err: err}
+		case mountErrorUnknown:
+			return err
+		}
+		if attempt >= mountRetries {
+			klog.Errorf("device: mount of %s still failing after %d retries: %v", devicePath, attempt, err)
+			return err
+		}
+		klog.Warningf("device: mount of %s failed, retrying once the devices settle: %v", devicePath, err)
+		if _, serr := RunCommandContext(ctx, strings.Split(DeviceSettle, " ")); serr != nil {
+			klog.V(4).Infof("device: could not settle the devices: %v", serr)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
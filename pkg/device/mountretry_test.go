@@ -0,0 +1,113 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/utils/mount"
+)
+
+// flakyMounter fails its mounts with the queued errors before mounting.
+type flakyMounter struct {
+	*mount.FakeMounter
+	errs  []error
+	calls int
+}
+
+func (m *flakyMounter) Mount(source, target, fstype string, options []string) error {
+	m.calls++
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return err
+	}
+	return m.FakeMounter.Mount(source, target, fstype, options)
+}
+
+func Test_mountWithRetry(t *testing.T) {
+	notSettled := errors.New("mount: /var/lib/kubelet/pods/a/mount: special device /dev/sdb2 does not exist.")
+	busy := errors.New("mount: /var/lib/kubelet/pods/a/mount: /dev/sdb2 already mounted or mount point busy: Device or resource busy")
+	corrupt := mount.NewMountError(mount.HasFilesystemErrors, "'fsck' found errors on device /dev/sdb2 but could not correct them")
+	badSuperblock := errors.New("mount: /var/lib/kubelet/pods/a/mount: wrong fs type, bad option, bad superblock on /dev/sdb2, missing codepage or helper program, or other error.")
+	unknown := errors.New("mount: permission denied")
+
+	tests := []struct {
+		name        string
+		retries     int
+		errs        []error
+		wantCalls   int
+		wantErr     bool
+		unmountable bool
+	}{
+		{name: "mounted", retries: 3, wantCalls: 1},
+		{name: "device settled", retries: 3, errs: []error{notSettled, busy}, wantCalls: 3},
+		{name: "device never settled", retries: 3, errs: []error{notSettled, notSettled, notSettled, notSettled, notSettled},
+			wantCalls: 4, wantErr: true},
+		{name: "retries disabled", retries: 0, errs: []error{notSettled}, wantCalls: 1, wantErr: true},
+		{name: "uncorrectable fsck errors", retries: 3, errs: []error{corrupt}, wantCalls: 1, wantErr: true, unmountable: true},
+		{name: "bad superblock", retries: 3, errs: []error{badSuperblock}, wantCalls: 1, wantErr: true, unmountable: true},
+		{name: "transient then corrupt", retries: 3, errs: []error{notSettled, badSuperblock}, wantCalls: 2, wantErr: true, unmountable: true},
+		{name: "unknown failure", retries: 3, errs: []error{unknown}, wantCalls: 1, wantErr: true},
+	}
+	defer SetMountRetry(mountRetries, mountRetryBackoff)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetMountRetry(tt.retries, time.Millisecond)
+			m := &flakyMounter{FakeMounter: mount.NewFakeMounter(nil), errs: tt.errs}
+			err := mountWithRetry(context.Background(), "/dev/sdb2", func() error {
+				return m.Mount("/dev/sdb2", "/var/lib/kubelet/pods/a/mount", "ext4", nil)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mountWithRetry() error %v, wantErr %v", err, tt.wantErr)
+			}
+			if m.calls != tt.wantCalls {
+				t.Errorf("mountWithRetry() mounted %d times, want %d", m.calls, tt.wantCalls)
+			}
+			if IsUnmountable(err) != tt.unmountable {
+				t.Errorf("IsUnmountable(%v) = %v, want %v", err, !tt.unmountable, tt.unmountable)
+			}
+			if tt.unmountable && status.Code(err) != codes.FailedPrecondition {
+				t.Errorf("mountWithRetry() got %v, want FailedPrecondition", err)
+			}
+			if !tt.wantErr && len(m.MountPoints) != 1 {
+				t.Errorf("expected the volume to be mounted, got %v", m.MountPoints)
+			}
+		})
+	}
+}
+
+func Test_mountWithRetryCanceled(t *testing.T) {
+	defer SetMountRetry(mountRetries, mountRetryBackoff)
+	SetMountRetry(3, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := mountWithRetry(ctx, "/dev/sdb2", func() error {
+		calls++
+		return errors.New("special device /dev/sdb2 does not exist")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("mountWithRetry() = %v after %d mounts, want the failure of the first mount", err, calls)
+	}
+}
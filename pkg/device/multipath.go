@@ -0,0 +1,188 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// MultipathRefresh updates the device mapper devices of the partitions of
+// a multipath device after its partition table is modified.
+const MultipathRefresh = "kpartx -u %s"
+
+// multipathUUIDPrefix prefixes the device mapper uuid of the multipath
+// devices, followed by their WWID.
+const multipathUUIDPrefix = "mpath-"
+
+// multipathDevice is a dm-multipath device, along with the paths to the
+// disk it is built from.
+type multipathDevice struct {
+	// name is the kernel name of the device, e.g. dm-3.
+	name  string
+	wwid  string
+	size  uint64
+	paths []string
+}
+
+// getMultipathWWID returns the WWID of the disk if it is a multipath
+// device, read from the device mapper uuid of the disk in sysfs root.
+func getMultipathWWID(root, disk string) (string, bool) {
+	if !strings.HasPrefix(disk, "dm-") {
+		return "", false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(root, disk, "dm", "uuid"))
+	if err != nil {
+		return "", false
+	}
+	uuid := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(uuid, multipathUUIDPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(uuid, multipathUUIDPrefix), true
+}
+
+// listMultipathDevices reads the multipath devices from sysfs root. It
+// returns none if multipath is not in use.
+func listMultipathDevices(root string) []multipathDevice {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		klog.Warningf("Device LocalPV: could not read %s for multipath devices: %v", root, err)
+		return nil
+	}
+	var result []multipathDevice
+	for _, entry := range entries {
+		name := entry.Name()
+		wwid, ok := getMultipathWWID(root, name)
+		if !ok {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(root, name, "size"))
+		if err != nil {
+			klog.Warningf("Device LocalPV: could not read size of multipath device %s: %v", name, err)
+			continue
+		}
+		sectors, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil || sectors == 0 {
+			continue
+		}
+		slaves, err := ioutil.ReadDir(filepath.Join(root, name, "slaves"))
+		if err != nil {
+			klog.Warningf("Device LocalPV: could not read paths of multipath device %s: %v", name, err)
+			continue
+		}
+		dev := multipathDevice{name: name, wwid: wwid, size: sectors * SectorSize}
+		for _, slave := range slaves {
+			dev.paths = append(dev.paths, slave.Name())
+		}
+		result = append(result, dev)
+	}
+	return result
+}
+
+// applyMultipath replaces the paths of the multipath devices among the
+// disks with the multipath devices, so that the partitions are created on
+// the multipath device and not on one of its paths. The multipath devices
+// are listed in place of their first path found.
+func applyMultipath(disks []diskDetail, devices []multipathDevice) []diskDetail {
+	if len(devices) == 0 {
+		return disks
+	}
+	pathOf := map[string]*multipathDevice{}
+	for i := range devices {
+		for _, path := range devices[i].paths {
+			pathOf[path] = &devices[i]
+		}
+	}
+	added := map[string]bool{}
+	var result []diskDetail
+	for _, disk := range disks {
+		dev, ok := pathOf[disk.DiskName]
+		if !ok {
+			result = append(result, disk)
+			continue
+		}
+		if added[dev.name] {
+			continue
+		}
+		added[dev.name] = true
+		klog.V(4).Infof("Device LocalPV: using multipath device %s in place of its paths %v", dev.name, dev.paths)
+		result = append(result, diskDetail{dev.name, dev.size})
+	}
+	return result
+}
+
+// getMultipathPartitionPath returns the path of the device mapper device of
+// the partition partNum of the multipath device, which is one of the holders
+// of the multipath device, named part<N>-mpath-<WWID> by kpartx.
+func getMultipathPartitionPath(root, disk string, partNum uint32) (string, bool) {
+	holders, err := ioutil.ReadDir(filepath.Join(root, disk, "holders"))
+	if err != nil {
+		return "", false
+	}
+	prefix := fmt.Sprintf("part%d-%s", partNum, multipathUUIDPrefix)
+	for _, holder := range holders {
+		data, err := ioutil.ReadFile(filepath.Join(root, holder.Name(), "dm", "uuid"))
+		if err == nil && strings.HasPrefix(strings.TrimSpace(string(data)), prefix) {
+			return devicePath(holder.Name()), true
+		}
+	}
+	return "", false
+}
+
+// multipathPartitioner refreshes the devices of the partitions of the
+// multipath devices after creating or deleting a partition, as the kernel
+// doesn't create them for the device mapper devices.
+type multipathPartitioner struct {
+	partitioner
+}
+
+func (p multipathPartitioner) String() string { return fmt.Sprint(p.partitioner) }
+
+func (p multipathPartitioner) create(disk, name string, startMiB, endMiB uint64) error {
+	if err := p.partitioner.create(disk, name, startMiB, endMiB); err != nil {
+		return err
+	}
+	return refreshMultipathPartitions(disk)
+}
+
+func (p multipathPartitioner) remove(disk string, partNum uint32) error {
+	if err := p.partitioner.remove(disk, partNum); err != nil {
+		return err
+	}
+	return refreshMultipathPartitions(disk)
+}
+
+// refreshMultipathPartitions updates the devices of the partitions of the
+// disk, if it is a multipath device.
+func refreshMultipathPartitions(disk string) error {
+	if _, ok := getMultipathWWID(sysBlockPath(), disk); !ok {
+		return nil
+	}
+	if _, err := exec.LookPath("kpartx"); err != nil {
+		klog.Warningf("Device LocalPV: kpartx is not available, relying on the partitioner "+
+			"for the partitions of multipath device %s", disk)
+		return nil
+	}
+	return runPartitionCommand(MultipathRefresh, devicePath(disk))
+}
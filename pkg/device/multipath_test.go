@@ -0,0 +1,84 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_multipathDevices(t *testing.T) {
+	root, err := ioutil.TempDir("", "sysblock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	write := func(path, data string) {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// dm-0 is a multipath device over sdb and sdc with its partition 2 in
+	// dm-2, dm-1 is an lvm volume.
+	write("dm-0/dm/uuid", "mpath-3600a098038303053453f463045727a6b")
+	write("dm-0/size", "33554432")
+	write("dm-0/slaves/sdb/size", "33554432")
+	write("dm-0/slaves/sdc/size", "33554432")
+	write("dm-0/holders/dm-2/size", "2048")
+	write("dm-1/dm/uuid", "LVM-5e0pNyIRCTTbKHeRNdIWMFGrKoWlNc0z")
+	write("dm-1/size", "2048")
+	write("dm-2/dm/uuid", "part2-mpath-3600a098038303053453f463045727a6b")
+
+	devices := listMultipathDevices(root)
+	want := []multipathDevice{{
+		name:  "dm-0",
+		wwid:  "3600a098038303053453f463045727a6b",
+		size:  17179869184,
+		paths: []string{"sdb", "sdc"},
+	}}
+	if !reflect.DeepEqual(devices, want) {
+		t.Fatalf("listMultipathDevices() = %v, want %v", devices, want)
+	}
+
+	disks := []diskDetail{{"sda", 1048576}, {"sdb", 17179869184}, {"sdc", 17179869184}}
+	got := applyMultipath(disks, devices)
+	wantDisks := []diskDetail{{"sda", 1048576}, {"dm-0", 17179869184}}
+	if !reflect.DeepEqual(got, wantDisks) {
+		t.Errorf("applyMultipath() = %v, want %v", got, wantDisks)
+	}
+	if got := applyMultipath(disks, nil); !reflect.DeepEqual(got, disks) {
+		t.Errorf("applyMultipath() without multipath = %v, want %v", got, disks)
+	}
+
+	if wwid, ok := getMultipathWWID(root, "dm-1"); ok {
+		t.Errorf("getMultipathWWID() = %s for lvm volume", wwid)
+	}
+	if path, ok := getMultipathPartitionPath(root, "dm-0", 2); !ok || path != "/dev/dm-2" {
+		t.Errorf("getMultipathPartitionPath() = %s, %v, want /dev/dm-2", path, ok)
+	}
+	if path, ok := getMultipathPartitionPath(root, "dm-0", 1); ok {
+		t.Errorf("getMultipathPartitionPath() = %s for missing partition", path)
+	}
+}
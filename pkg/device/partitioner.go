@@ -0,0 +1,198 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// Partitioners modifying the partition tables of the disks. The partition
+// tables are read with parted irrespective of the partitioner.
+const (
+	// PartitionerAuto picks sgdisk if it is available, else parted.
+	PartitionerAuto   = "auto"
+	PartitionerSgdisk = "sgdisk"
+	PartitionerParted = "parted"
+)
+
+// Partition commands of the partitioners, on top of the ones in
+// device-util.go.
+const (
+	// the alignment is set to a sector, as the partitions are aligned to
+	// a MiB already and the default alignment of sgdisk is 2048 sectors,
+	// i.e. 8MiB on the disks with 4KiB sectors.
+	SgdiskCreate  = "sgdisk --set-alignment=1 --new=0:%dM:+%dM --change-name=0:%s %s"
+	SgdiskDelete  = "sgdisk --delete=%d %s"
+	SgdiskSetName = "sgdisk --change-name=%d:%s %s"
+	// setting the type of a partition needs parted 3.5 or newer.
+	PartedSetType = "parted %s type %d %s --script"
+)
+
+// partitioner modifies the GPT of the disks. The partitioners create the
+// same layout for the same request, so the partitions are looked up the same
+// way irrespective of the partitioner which created them.
+type partitioner interface {
+	// create creates the partition named name on the disk, spanning from
+	// startMiB till endMiB, with the default partition type.
+	create(disk, name string, startMiB, endMiB uint64) error
+	// remove deletes the partition partNum of the disk.
+	remove(disk string, partNum uint32) error
+	// setName sets the name of the partition partNum of the disk.
+	setName(disk string, partNum uint32, name string) error
+	// setType sets the type of the partition partNum of the disk, given
+	// as a sgdisk type code or as a type GUID.
+	setType(disk string, partNum uint32, partitionType string) error
+	// verify checks the partition table of the disk before modifying it.
+	verify(disk string) error
+}
+
+// activePartitioner is the partitioner in use, sgdisk unless set otherwise
+// at startup. Its operations are recorded in the audit log and refresh the
+// partitions of the multipath devices, and the disks are checked not to be
+// held by another subsystem before being modified.
+var activePartitioner partitioner = auditedPartitioner{multipathPartitioner{exclusivePartitioner{sgdiskPartitioner{}}}}
+
+// SetPartitioner sets the partitioner modifying the partition tables, one
+// of PartitionerSgdisk, PartitionerParted or PartitionerAuto. It fails if
+// the binary of the partitioner is not available.
+func SetPartitioner(name string) error {
+	p, err := newPartitioner(name, exec.LookPath)
+	if err != nil {
+		return err
+	}
+	activePartitioner = auditedPartitioner{multipathPartitioner{exclusivePartitioner{p}}}
+	klog.Infof("using %s for modifying the partition tables", p)
+	return nil
+}
+
+// newPartitioner returns the partitioner of the given name, resolving
+// PartitionerAuto from the binaries found by lookPath.
+func newPartitioner(name string, lookPath func(string) (string, error)) (partitioner, error) {
+	switch name {
+	case PartitionerAuto, "":
+		if _, err := lookPath(PartitionerSgdisk); err == nil {
+			return sgdiskPartitioner{}, nil
+		}
+		name = PartitionerParted
+	case PartitionerSgdisk, PartitionerParted:
+	default:
+		return nil, errors.Errorf("invalid partitioner %q, must be one of %s, %s or %s",
+			name, PartitionerAuto, PartitionerSgdisk, PartitionerParted)
+	}
+	if _, err := lookPath(name); err != nil {
+		return nil, errors.Wrapf(err, "partitioner %s is not available", name)
+	}
+	if name == PartitionerSgdisk {
+		return sgdiskPartitioner{}, nil
+	}
+	return partedPartitioner{}, nil
+}
+
+// runPartitionCommand runs the partition command built from the format and
+// the args.
+func runPartitionCommand(format string, args ...interface{}) error {
+	_, err := RunCommand(strings.Split(fmt.Sprintf(format, args...), " "))
+	return err
+}
+
+// sgdiskPartitioner modifies the partition tables with sgdisk.
+type sgdiskPartitioner struct{}
+
+func (sgdiskPartitioner) String() string { return PartitionerSgdisk }
+
+func (sgdiskPartitioner) create(disk, name string, startMiB, endMiB uint64) error {
+	return runPartitionCommand(SgdiskCreate, startMiB, endMiB-startMiB, name, devicePath(disk))
+}
+
+func (sgdiskPartitioner) remove(disk string, partNum uint32) error {
+	return runPartitionCommand(SgdiskDelete, partNum, devicePath(disk))
+}
+
+func (sgdiskPartitioner) setName(disk string, partNum uint32, name string) error {
+	return runPartitionCommand(SgdiskSetName, partNum, name, devicePath(disk))
+}
+
+func (sgdiskPartitioner) setType(disk string, partNum uint32, partitionType string) error {
+	return runPartitionCommand(PartitionSetType, partNum, partitionType, devicePath(disk))
+}
+
+func (sgdiskPartitioner) verify(disk string) error {
+	return verifyPartitionTable(disk)
+}
+
+// partedPartitioner modifies the partition tables with parted, for the
+// images shipping parted only.
+type partedPartitioner struct{}
+
+func (partedPartitioner) String() string { return PartitionerParted }
+
+func (partedPartitioner) create(disk, name string, startMiB, endMiB uint64) error {
+	return runPartitionCommand(PartitionCreate, devicePath(disk), name, startMiB, endMiB)
+}
+
+func (partedPartitioner) remove(disk string, partNum uint32) error {
+	return runPartitionCommand(PartitionDelete, devicePath(disk), partNum)
+}
+
+func (partedPartitioner) setName(disk string, partNum uint32, name string) error {
+	return runPartitionCommand(PartitionSetName, devicePath(disk), partNum, name)
+}
+
+func (partedPartitioner) setType(disk string, partNum uint32, partitionType string) error {
+	typeGUID, err := getPartitionTypeGUID(partitionType)
+	if err != nil {
+		return err
+	}
+	return runPartitionCommand(PartedSetType, devicePath(disk), partNum, typeGUID)
+}
+
+// verify is a no-op, as parted can't verify the partition table without
+// offering to fix it. The partition tables are verified by sgdisk only.
+func (partedPartitioner) verify(disk string) error {
+	return nil
+}
+
+// partitionTypeGUIDs maps the sgdisk type codes of the common partition
+// types to their type GUIDs.
+var partitionTypeGUIDs = map[string]string{
+	"8200": "0657FD6D-A4AB-43C4-84E5-0933C84B4F4F", // Linux swap
+	"8300": "0FC63DAF-8483-4772-8E79-3D69D8477DE4", // Linux filesystem
+	"8301": "8DA63339-0007-60C0-C436-083AC8230908", // Linux reserved
+	"8302": "933AC7E1-2EB4-4F13-B844-0E14E2AEF915", // Linux /home
+	"8e00": "E6D6D379-F507-44C2-A23C-238F2A3DF928", // Linux LVM
+	"bf01": "6A898CC3-1DD2-11B2-99A6-080020736631", // Solaris /usr & Mac ZFS
+	"fd00": "A19D880F-05FC-4D3B-A006-743F0F84911E", // Linux RAID
+}
+
+// getPartitionTypeGUID returns the type GUID of the partition type given as
+// a sgdisk type code or as a type GUID. parted only accepts the GUIDs.
+func getPartitionTypeGUID(partitionType string) (string, error) {
+	if len(partitionType) > 4 {
+		return strings.ToUpper(partitionType), nil
+	}
+	typeGUID, ok := partitionTypeGUIDs[strings.ToLower(partitionType)]
+	if !ok {
+		return "", errors.Errorf("partition type code %s is not known to the parted partitioner, "+
+			"use its type GUID instead", partitionType)
+	}
+	return typeGUID, nil
+}
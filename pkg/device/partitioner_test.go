@@ -0,0 +1,192 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_newPartitioner(t *testing.T) {
+	lookPath := func(available ...string) func(string) (string, error) {
+		return func(file string) (string, error) {
+			for _, name := range available {
+				if name == file {
+					return "/usr/sbin/" + file, nil
+				}
+			}
+			return "", exec.ErrNotFound
+		}
+	}
+
+	tests := []struct {
+		name      string
+		available []string
+		want      string
+		wantErr   bool
+	}{
+		{name: PartitionerAuto, available: []string{"parted", "sgdisk"}, want: PartitionerSgdisk},
+		{name: PartitionerAuto, available: []string{"parted"}, want: PartitionerParted},
+		{name: "", available: []string{"parted", "sgdisk"}, want: PartitionerSgdisk},
+		{name: PartitionerAuto, wantErr: true},
+		{name: PartitionerParted, available: []string{"parted", "sgdisk"}, want: PartitionerParted},
+		{name: PartitionerSgdisk, available: []string{"parted", "sgdisk"}, want: PartitionerSgdisk},
+		{name: PartitionerSgdisk, available: []string{"parted"}, wantErr: true},
+		{name: "fdisk", available: []string{"parted", "sgdisk"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s with %v", tt.name, tt.available), func(t *testing.T) {
+			got, err := newPartitioner(tt.name, lookPath(tt.available...))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("newPartitioner() expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newPartitioner() unexpected error %v", err)
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("newPartitioner() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getPartitionTypeGUID(t *testing.T) {
+	tests := []struct {
+		partitionType string
+		want          string
+		wantErr       bool
+	}{
+		{partitionType: "8300", want: "0FC63DAF-8483-4772-8E79-3D69D8477DE4"},
+		{partitionType: "8E00", want: "E6D6D379-F507-44C2-A23C-238F2A3DF928"},
+		{partitionType: "a19d880f-05fc-4d3b-a006-743f0f84911e", want: "A19D880F-05FC-4D3B-A006-743F0F84911E"},
+		{partitionType: "ef00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.partitionType, func(t *testing.T) {
+			got, err := getPartitionTypeGUID(tt.partitionType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getPartitionTypeGUID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getPartitionTypeGUID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newLoopDisk attaches a sparse file of the given size as a loop device
+// with an empty GPT, and returns its name.
+func newLoopDisk(t *testing.T, size int64) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "partitioner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	file := filepath.Join(dir, "disk.img")
+	if err = ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Truncate(file, size); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("losetup", "--find", "--show", file).CombinedOutput()
+	if err != nil {
+		t.Skipf("could not attach a loop device: %v, %s", err, out)
+	}
+	loop := strings.TrimSpace(string(out))
+	t.Cleanup(func() { _ = exec.Command("losetup", "--detach", loop).Run() })
+
+	disk := strings.TrimPrefix(loop, "/dev/")
+	if out, err = exec.Command("parted", loop, "mklabel", "gpt", "--script").CombinedOutput(); err != nil {
+		t.Fatalf("could not create the GPT of %s: %v, %s", loop, err, out)
+	}
+	return disk
+}
+
+// partitionLayout returns the partitions of the disk in the machine
+// readable parted output, along with their type GUIDs, leaving out the disk
+// line which names the disk.
+func partitionLayout(t *testing.T, disk string) []string {
+	t.Helper()
+	out, err := exec.Command("parted", "-m", "/dev/"+disk, "unit", "b", "print", "--script").CombinedOutput()
+	if err != nil {
+		t.Fatalf("could not print the partitions of %s: %v, %s", disk, err, out)
+	}
+	var layout []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n")[2:] {
+		num := strings.SplitN(line, ":", 2)[0]
+		typeGUID, err := exec.Command("sgdisk", "--info="+num, "/dev/"+disk).CombinedOutput()
+		if err != nil {
+			t.Fatalf("could not get the type of partition %s of %s: %v, %s", num, disk, err, typeGUID)
+		}
+		guid := strings.SplitN(string(typeGUID), "\n", 2)[0]
+		layout = append(layout, line+" "+guid)
+	}
+	return layout
+}
+
+func Test_partitionersLayout(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("creating the loop devices needs root")
+	}
+	for _, bin := range []string{"losetup", "parted", "sgdisk"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s is not available", bin)
+		}
+	}
+
+	// the same requests, including a removed partition leaving a gap
+	// which gets reused, must end up with the same partition tables.
+	apply := func(p partitioner, disk string) {
+		t.Helper()
+		steps := []error{
+			p.create(disk, "meta", 1, 2),
+			p.create(disk, "vol-1", 2, 12),
+			p.create(disk, "vol-1-reserve", 12, 14),
+			p.create(disk, "vol-2", 14, 30),
+			p.setType(disk, 4, "8e00"),
+			p.remove(disk, 2),
+			p.create(disk, "vol-3", 2, 6),
+			p.setName(disk, 3, "vol-1-renamed"),
+		}
+		for i, err := range steps {
+			if err != nil {
+				t.Fatalf("%v: step %d failed: %v", p, i, err)
+			}
+		}
+	}
+
+	sgdiskDisk, partedDisk := newLoopDisk(t, 64*PartitionAlignmentBytes), newLoopDisk(t, 64*PartitionAlignmentBytes)
+	apply(sgdiskPartitioner{}, sgdiskDisk)
+	apply(partedPartitioner{}, partedDisk)
+
+	got, want := partitionLayout(t, partedDisk), partitionLayout(t, sgdiskDisk)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("parted layout\n%s\ndiffers from sgdisk layout\n%s",
+			strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
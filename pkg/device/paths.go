@@ -0,0 +1,69 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// DevRoot is the directory holding the device nodes of the disks and of
+// their partitions, /dev unless set otherwise at startup.
+var DevRoot = "/dev"
+
+// SysRoot is the directory sysfs is mounted at, /sys unless set otherwise
+// at startup.
+var SysRoot = "/sys"
+
+// SetDeviceRoots sets the directories the device nodes and sysfs are read
+// from, for the runtimes exposing them at other paths, e.g. a bind mounted
+// subset of /dev. It fails if either of them isn't a directory, and needs
+// to be called before the disk discovery is set up.
+func SetDeviceRoots(devRoot, sysRoot string) error {
+	for _, dir := range []string{devRoot, sysRoot} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return errors.Wrapf(err, "invalid device root")
+		}
+		if !info.IsDir() {
+			return errors.Errorf("invalid device root %s: not a directory", dir)
+		}
+	}
+	DevRoot, SysRoot = devRoot, sysRoot
+	klog.Infof("Device LocalPV: reading the devices from %s and sysfs from %s", devRoot, sysRoot)
+	return nil
+}
+
+// devicePath returns the path of the device node of the disk or partition.
+func devicePath(name string) string {
+	return filepath.Join(DevRoot, name)
+}
+
+// sysBlockPath returns the sysfs directory listing the block devices.
+func sysBlockPath() string {
+	return filepath.Join(SysRoot, "block")
+}
+
+// sysfsPath returns the path of the sysfs attribute given by the format,
+// relative to SysRoot.
+func sysfsPath(format string, args ...interface{}) string {
+	return filepath.Join(SysRoot, fmt.Sprintf(format, args...))
+}
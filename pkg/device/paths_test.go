@@ -0,0 +1,75 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetDeviceRoots(t *testing.T) {
+	root, err := ioutil.TempDir("", "roots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	defer func() { DevRoot, SysRoot = "/dev", "/sys" }()
+
+	devRoot, sysRoot := filepath.Join(root, "dev"), filepath.Join(root, "sys")
+	if err = SetDeviceRoots(devRoot, sysRoot); err == nil {
+		t.Errorf("SetDeviceRoots() expected error for missing directories")
+	}
+	for _, dir := range []string{devRoot, filepath.Join(sysRoot, "block", "sdb", "queue")} {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(root, "file")
+	if err = ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = SetDeviceRoots(devRoot, file); err == nil {
+		t.Errorf("SetDeviceRoots() expected error for a file")
+	}
+	if DevRoot != "/dev" {
+		t.Errorf("SetDeviceRoots() changed the roots on error")
+	}
+	if err = SetDeviceRoots(devRoot, sysRoot); err != nil {
+		t.Fatalf("SetDeviceRoots() unexpected error %v", err)
+	}
+
+	paths := map[string]string{
+		getPartitionPath("sdb", 2):                  filepath.Join(devRoot, "sdb2"),
+		getPartitionPath("nvme0n1", 3):              filepath.Join(devRoot, "nvme0n1p3"),
+		sysfsPath(DiskRotationalPath, "sdb"):        filepath.Join(sysRoot, "block/sdb/queue/rotational"),
+		sysfsPath(PartitionStatPath, "sdb", "sdb2"): filepath.Join(sysRoot, "block/sdb/sdb2/stat"),
+	}
+	for got, want := range paths {
+		if got != want {
+			t.Errorf("got path %s, want %s", got, want)
+		}
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(sysRoot, "block/sdb/queue/rotational"), []byte("0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if media := getDiskMediaType("sdb"); media != MediaTypeSSD {
+		t.Errorf("getDiskMediaType() = %q, want %q", media, MediaTypeSSD)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"sync"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+)
+
+// protectedRegions holds the number of bytes at the start of the disks
+// which the allocator never hands out, protecting the bootloaders and
+// the other data written there outside of the partition table.
+var protectedRegions = struct {
+	sync.RWMutex
+	// defaultBytes applies to the disks without a per-device setting.
+	defaultBytes uint64
+	// devices maps the UUID or the name of the devices to their setting.
+	devices map[string]uint64
+}{}
+
+// SetProtectedLeadingBytes sets the number of bytes at the start of every
+// disk to be left out of the free regions. Zero protects only the primary
+// GPT.
+func SetProtectedLeadingBytes(bytes int64) error {
+	if bytes < 0 {
+		return errors.Errorf("invalid protected leading bytes %d", bytes)
+	}
+	protectedRegions.Lock()
+	defer protectedRegions.Unlock()
+	protectedRegions.defaultBytes = uint64(bytes)
+	return nil
+}
+
+// SetProtectedDevices sets the number of protected leading bytes of the
+// devices, referred by their UUID or by their name, overriding the
+// setting for every disk. The UUID takes precedence over the name.
+func SetProtectedDevices(devices map[string]uint64) {
+	protectedRegions.Lock()
+	defer protectedRegions.Unlock()
+	protectedRegions.devices = devices
+}
+
+// getProtectedBytes returns the number of bytes at the start of the disk
+// of the given device which are never allocated, including the primary
+// GPT.
+func getProtectedBytes(uuid, name string) uint64 {
+	protectedRegions.RLock()
+	defer protectedRegions.RUnlock()
+	bytes, ok := protectedRegions.devices[uuid]
+	if !ok {
+		bytes, ok = protectedRegions.devices[name]
+	}
+	if !ok {
+		bytes = protectedRegions.defaultBytes
+	}
+	if bytes < GPTPrimaryReservedBytes {
+		return GPTPrimaryReservedBytes
+	}
+	return bytes
+}
+
+// diskProtectedBytes returns the number of protected leading bytes of
+// the disk. The disk is identified only when there are per-device
+// settings, falling back to the setting for every disk if it can't be.
+func diskProtectedBytes(diskName string) uint64 {
+	protectedRegions.RLock()
+	perDevice := len(protectedRegions.devices) > 0
+	protectedRegions.RUnlock()
+	if !perDevice {
+		return getProtectedBytes("", "")
+	}
+	name, _ := getDiskMetaName(diskName)
+	uuid, _ := getDiskIdentifier(diskName)
+	return getProtectedBytes(uuid, name)
+}
@@ -0,0 +1,95 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_getProtectedBytes(t *testing.T) {
+	defer SetProtectedLeadingBytes(0)
+	defer SetProtectedDevices(nil)
+
+	if got := getProtectedBytes("uuid-1", "fast"); got != GPTPrimaryReservedBytes {
+		t.Errorf("getProtectedBytes() by default = %d, want the primary GPT %d", got, GPTPrimaryReservedBytes)
+	}
+	if err := SetProtectedLeadingBytes(-1); err == nil {
+		t.Errorf("SetProtectedLeadingBytes() expected error for a negative size")
+	}
+	if err := SetProtectedLeadingBytes(8 * PartitionAlignmentBytes); err != nil {
+		t.Fatal(err)
+	}
+	SetProtectedDevices(map[string]uint64{"uuid-1": 0, "fast": 64 * PartitionAlignmentBytes})
+
+	tests := []struct {
+		name      string
+		uuid, dev string
+		wantBytes uint64
+	}{
+		{name: "uuid takes precedence", uuid: "uuid-1", dev: "fast", wantBytes: GPTPrimaryReservedBytes},
+		{name: "by name", uuid: "uuid-2", dev: "fast", wantBytes: 64 * PartitionAlignmentBytes},
+		{name: "global setting", uuid: "uuid-3", dev: "slow", wantBytes: 8 * PartitionAlignmentBytes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getProtectedBytes(tt.uuid, tt.dev); got != tt.wantBytes {
+				t.Errorf("getProtectedBytes() = %d, want %d", got, tt.wantBytes)
+			}
+		})
+	}
+}
+
+func Test_parseFreeRegionsProtected(t *testing.T) {
+	const diskSize = 16 * 1024 * 1024 * 1024
+	rows := [][]string{
+		{"17408B", "1048575B", "1031168B", "Free", "Space"},
+		{"1", "1048576B", "10485759B", "9437184B", "test-device"},
+		{"10485760B", "104857599B", "94371840B", "Free", "Space"},
+		{"2", "104857600B", "1084227583B", "979369984B", "ext4", "5d8d56cb-e291-4dfd-81ac-fb664dd5ec75"},
+		{"1084227584B", "17179852287B", "16095624704B", "Free", "Space"},
+	}
+	tests := []struct {
+		name      string
+		protected uint64
+		want      []partFree
+	}{
+		{
+			name:      "primary GPT only",
+			protected: 0,
+			want:      []partFree{{"sdc", 1, 1, 0}, {"sdc", 10, 100, 90}, {"sdc", 1034, 16383, 15349}},
+		},
+		{
+			name:      "region partly protected",
+			protected: 50*PartitionAlignmentBytes + 1,
+			want:      []partFree{{"sdc", 51, 100, 49}, {"sdc", 1034, 16383, 15349}},
+		},
+		{
+			name:      "region fully protected",
+			protected: 200 * PartitionAlignmentBytes,
+			want:      []partFree{{"sdc", 1034, 16383, 15349}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseFreeRegions("sdc", diskSize, tt.protected, rows)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFreeRegions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"strings"
+	"sync"
+)
+
+// ParseQuarantinedDevices parses the quarantined devices annotation of a
+// DeviceNode, the comma separated names of the devices marked as bad.
+func ParseQuarantinedDevices(annotation string) map[string]bool {
+	quarantined := map[string]bool{}
+	for _, name := range strings.Split(annotation, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			quarantined[name] = true
+		}
+	}
+	return quarantined
+}
+
+// quarantinedDevices holds the names of the devices marked as bad in the
+// DeviceNode.
+var quarantinedDevices = struct {
+	sync.RWMutex
+	names map[string]bool
+}{}
+
+// SetQuarantinedDevices sets the names of the devices marked as bad, no new
+// partition is placed on their disks. The existing partitions on them are
+// left intact.
+func SetQuarantinedDevices(names map[string]bool) {
+	quarantinedDevices.Lock()
+	defer quarantinedDevices.Unlock()
+	quarantinedDevices.names = names
+}
+
+// hasQuarantinedDevices checks if any device is marked as bad.
+func hasQuarantinedDevices() bool {
+	quarantinedDevices.RLock()
+	defer quarantinedDevices.RUnlock()
+	return len(quarantinedDevices.names) > 0
+}
+
+// isDiskOfQuarantinedDevice checks if the device of the meta partition of
+// the disk is marked as bad. The devname of the volumes is a regular
+// expression matching the meta partition names of many devices, so the
+// disk is checked by its own meta partition. The disk is only looked up
+// when there are quarantined devices.
+func isDiskOfQuarantinedDevice(diskName string) bool {
+	if !hasQuarantinedDevices() {
+		return false
+	}
+	name, err := getDiskMetaName(diskName)
+	if err != nil {
+		return false
+	}
+	return isDeviceQuarantined(name)
+}
+
+// isDeviceQuarantined checks if the device of the meta partition name is
+// marked as bad.
+func isDeviceQuarantined(diskMetaName string) bool {
+	quarantinedDevices.RLock()
+	defer quarantinedDevices.RUnlock()
+	return quarantinedDevices.names[diskMetaName]
+}
@@ -0,0 +1,109 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestParseQuarantinedDevices(t *testing.T) {
+	tests := map[string]struct {
+		annotation string
+		want       map[string]bool
+	}{
+		"no annotation":   {annotation: "", want: map[string]bool{}},
+		"single device":   {annotation: "test-device", want: map[string]bool{"test-device": true}},
+		"spaces and gaps": {annotation: " fast, ,slow ", want: map[string]bool{"fast": true, "slow": true}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := ParseQuarantinedDevices(tt.annotation); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQuarantinedDevices(%q) = %v, want %v", tt.annotation, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isDeviceQuarantined(t *testing.T) {
+	defer SetQuarantinedDevices(nil)
+
+	if isDeviceQuarantined("test-device") {
+		t.Errorf("expected no device quarantined without the annotation")
+	}
+	SetQuarantinedDevices(ParseQuarantinedDevices("other-device, test-device"))
+	if !isDeviceQuarantined("test-device") {
+		t.Errorf("expected test-device quarantined")
+	}
+	if isDeviceQuarantined("fast") {
+		t.Errorf("expected fast not quarantined")
+	}
+	// clearing the annotation makes the device available again
+	SetQuarantinedDevices(ParseQuarantinedDevices(""))
+	if isDeviceQuarantined("test-device") {
+		t.Errorf("expected test-device available once the quarantine is cleared")
+	}
+}
+
+func Test_isDiskOfQuarantinedDevice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// parted lists the meta partition of the device nvme-1 on every disk.
+	script := `#!/bin/sh
+echo "Model: NVMe Device (nvme)"
+echo "Disk /dev/nvme0n1: 107374182400B"
+echo "Partition Table: gpt"
+echo ""
+echo "Number  Start     End        Size      File system  Name    Flags"
+echo " 1      1048576B  2097151B   1048576B               nvme-1"
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "parted"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	defer os.Setenv("PATH", path)
+	defer SetQuarantinedDevices(nil)
+
+	// the devname of the volumes is a regular expression matching the
+	// device, never its name.
+	devname := "nvme.*"
+	if !regexp.MustCompile(devname).MatchString("nvme-1") {
+		t.Fatalf("expected devname %s to match device nvme-1", devname)
+	}
+	if isDiskOfQuarantinedDevice("nvme0n1") {
+		t.Errorf("expected no disk quarantined without the annotation")
+	}
+	SetQuarantinedDevices(ParseQuarantinedDevices("nvme-1"))
+	if isDeviceQuarantined(devname) {
+		t.Errorf("expected the devname not to be taken as a device name")
+	}
+	if !isDiskOfQuarantinedDevice("nvme0n1") {
+		t.Errorf("expected the disk of the quarantined device nvme-1 to be quarantined")
+	}
+	SetQuarantinedDevices(ParseQuarantinedDevices("nvme-2"))
+	if isDiskOfQuarantinedDevice("nvme0n1") {
+		t.Errorf("expected the disk of device nvme-1 available when nvme-2 is quarantined")
+	}
+}
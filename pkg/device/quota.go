@@ -0,0 +1,159 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// ProjectIDGet prints the project id of a directory, it works for both ext4
+// and xfs
+const ProjectIDGet = "lsattr -pd %s"
+
+// quotaBlockSize is the unit of the block counts printed by xfs_quota
+const quotaBlockSize = 1024
+
+// ProjectQuota is the usage and the limits of the project quota the root
+// directory of a volume is accounted to. The block values are in bytes, a
+// zero limit means there is no limit.
+type ProjectQuota struct {
+	ID         uint32
+	BlockUsed  int64
+	BlockLimit int64
+	InodeUsed  int64
+	InodeLimit int64
+}
+
+// GetProjectQuota returns the project quota of the root directory of the
+// filesystem mounted at path. It returns nil if the directory isn't in a
+// project, if project quotas are not enabled on the filesystem or if the
+// project has no limits, so that the usage of the whole filesystem is
+// reported instead.
+func GetProjectQuota(path string) (*ProjectQuota, error) {
+	out, err := RunCommand(strings.Split(fmt.Sprintf(ProjectIDGet, path), " "))
+	if err != nil {
+		// lsattr fails on filesystems not supporting project ids
+		klog.V(4).Infof("device: could not get the project id of %s: %v", path, err)
+		return nil, nil
+	}
+	id, err := parseProjectID(out)
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		return nil, nil
+	}
+
+	// -f lets xfs_quota work on the other filesystems like ext4 as well
+	out, err = RunCommand([]string{"xfs_quota", "-f",
+		"-c", fmt.Sprintf("quota -p -N -b %d", id),
+		"-c", fmt.Sprintf("quota -p -N -i %d", id),
+		path})
+	if err != nil {
+		klog.V(4).Infof("device: could not get the quota of project %d on %s: %v", id, path, err)
+		return nil, nil
+	}
+	q, err := parseProjectQuota(id, out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse the quota of project %d on %s", id, path)
+	}
+	if q == nil || (q.BlockLimit == 0 && q.InodeLimit == 0) {
+		return nil, nil
+	}
+	return q, nil
+}
+
+// parseProjectID parses the project id out of the lsattr -pd output, e.g.
+// "   42 --------------e------- /mnt/vol".
+func parseProjectID(out string) (uint32, error) {
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return 0, errors.Errorf("empty lsattr output")
+	}
+	id, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid project id in %q", strings.TrimSpace(out))
+	}
+	return uint32(id), nil
+}
+
+// parseProjectQuota parses the output of the block and the inode quota
+// commands of xfs_quota, one line each, e.g.
+//
+//	/dev/sdb2 524288 0 1048576 00 [--------] /mnt/vol
+//	/dev/sdb2 12 0 1000 00 [--------] /mnt/vol
+//
+// Nothing is printed when the quota is not enabled or the project has
+// neither usage nor limits, nil is returned then.
+func parseProjectQuota(id uint32, out string) (*ProjectQuota, error) {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	if len(lines) != 2 {
+		return nil, errors.Errorf("expected a block and an inode line, got %q", out)
+	}
+
+	bUsed, bLimit, err := parseQuotaLine(lines[0])
+	if err != nil {
+		return nil, err
+	}
+	iUsed, iLimit, err := parseQuotaLine(lines[1])
+	if err != nil {
+		return nil, err
+	}
+	return &ProjectQuota{
+		ID:         id,
+		BlockUsed:  bUsed * quotaBlockSize,
+		BlockLimit: bLimit * quotaBlockSize,
+		InodeUsed:  iUsed,
+		InodeLimit: iLimit,
+	}, nil
+}
+
+// parseQuotaLine returns the usage and the limit in a quota line, the hard
+// limit if it is set, the soft one otherwise.
+func parseQuotaLine(line string) (int64, int64, error) {
+	// device used soft hard ...
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return 0, 0, errors.Errorf("invalid quota line %q", line)
+	}
+	var values [3]int64
+	for i := range values {
+		v, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "invalid quota line %q", line)
+		}
+		values[i] = v
+	}
+	used, soft, hard := values[0], values[1], values[2]
+	if hard != 0 {
+		return used, hard, nil
+	}
+	return used, soft, nil
+}
@@ -0,0 +1,94 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseProjectID(t *testing.T) {
+	tests := []struct {
+		name      string
+		out       string
+		id        uint32
+		expectErr bool
+	}{
+		{name: "project", out: "   42 --------------e------- /mnt/vol\n", id: 42},
+		{name: "no project", out: "    0 --------------e------- /mnt/vol\n", id: 0},
+		{name: "empty output", out: "", expectErr: true},
+		{name: "no project id", out: "--------------e------- /mnt/vol\n", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := parseProjectID(tt.out)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("parseProjectID() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if id != tt.id {
+				t.Errorf("parseProjectID() = %d, want %d", id, tt.id)
+			}
+		})
+	}
+}
+
+func Test_parseProjectQuota(t *testing.T) {
+	tests := []struct {
+		name      string
+		out       string
+		want      *ProjectQuota
+		expectErr bool
+	}{
+		{
+			name: "hard limits",
+			out: "/dev/sdb2 524288 0 1048576 00 [--------] /mnt/vol\n" +
+				"/dev/sdb2 12 0 1000 00 [--------] /mnt/vol\n",
+			want: &ProjectQuota{ID: 42, BlockUsed: 536870912, BlockLimit: 1073741824, InodeUsed: 12, InodeLimit: 1000},
+		},
+		{
+			name: "soft limit only",
+			out: "/dev/sdb2 1024 2048 0 00 [--------] /mnt/vol\n" +
+				"/dev/sdb2 12 0 0 00 [--------] /mnt/vol\n",
+			want: &ProjectQuota{ID: 42, BlockUsed: 1048576, BlockLimit: 2097152, InodeUsed: 12},
+		},
+		{
+			name: "grace period running",
+			out: "/dev/sdb2 4096 2048 8192 01 [6 days] /mnt/vol\n" +
+				"/dev/sdb2 12 0 0 00 [--------] /mnt/vol\n",
+			want: &ProjectQuota{ID: 42, BlockUsed: 4194304, BlockLimit: 8388608, InodeUsed: 12},
+		},
+		{name: "quota not enabled", out: "", want: nil},
+		{name: "block line only", out: "/dev/sdb2 1024 0 2048 00 [--------] /mnt/vol\n", expectErr: true},
+		{
+			name: "invalid usage",
+			out: "/dev/sdb2 1K 0 2048 00 [--------] /mnt/vol\n" +
+				"/dev/sdb2 12 0 0 00 [--------] /mnt/vol\n",
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProjectQuota(42, tt.out)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("parseProjectQuota() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProjectQuota() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
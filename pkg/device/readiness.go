@@ -0,0 +1,153 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// readinessCheckBytes is the size of the start of the disk read to check
+// that a new disk is readable.
+const readinessCheckBytes = 4096
+
+// readinessTracker holds back the disks which appeared since the node
+// agent started till they have been present for a number of discoveries
+// in a row and can be read, as a hot added disk may show up before its
+// firmware is done initializing.
+type readinessTracker struct {
+	mu sync.Mutex
+	// discoveries is the number of discoveries in a row a new disk must
+	// be present for, zero makes the disks ready right away.
+	discoveries int
+	// started is set once the first discovery completed, the disks found
+	// by it are ready.
+	started bool
+	// seen counts the discoveries in a row the pending disks were present
+	// for, by their UUID.
+	seen  map[string]int
+	ready map[string]bool
+	// check checks that the disk can be read.
+	check func(diskName string) error
+}
+
+func newReadinessTracker(discoveries int) *readinessTracker {
+	return &readinessTracker{
+		discoveries: discoveries,
+		seen:        map[string]int{},
+		ready:       map[string]bool{},
+		check:       checkDiskReadable,
+	}
+}
+
+var readiness = newReadinessTracker(0)
+
+// SetDeviceReadyDiscoveries sets the number of discoveries in a row a disk
+// appearing while the node agent runs must be present for before it gets
+// used by the new volumes. Zero uses the disks right away.
+func SetDeviceReadyDiscoveries(discoveries int) error {
+	if discoveries < 0 {
+		return errors.Errorf("invalid number of discoveries %d", discoveries)
+	}
+	readiness = newReadinessTracker(discoveries)
+	return nil
+}
+
+// observe records the presence of the disk of the given UUID in a
+// discovery and checks if it is still pending readiness.
+func (t *readinessTracker) observe(id, diskName string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.discoveries == 0 || t.ready[id] {
+		return false
+	}
+	if !t.started {
+		t.ready[id] = true
+		return false
+	}
+	t.seen[id]++
+	if t.seen[id] < t.discoveries {
+		klog.Infof("Device LocalPV: disk %s (%s) pending readiness, present for %d of %d discoveries",
+			diskName, id, t.seen[id], t.discoveries)
+		return true
+	}
+	if err := t.check(diskName); err != nil {
+		klog.Warningf("Device LocalPV: disk %s (%s) pending readiness, it can't be read: %v", diskName, id, err)
+		return true
+	}
+	klog.Infof("Device LocalPV: disk %s (%s) is ready", diskName, id)
+	delete(t.seen, id)
+	t.ready[id] = true
+	return false
+}
+
+// endDiscovery forgets the disks which were not present in the discovery,
+// so that a disk showing up again goes through the readiness again.
+func (t *readinessTracker) endDiscovery(present map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.started = true
+	for id := range t.seen {
+		if !present[id] {
+			delete(t.seen, id)
+		}
+	}
+	for id := range t.ready {
+		if !present[id] {
+			delete(t.ready, id)
+		}
+	}
+}
+
+// isPending checks if the disk of the given UUID was found pending
+// readiness by the last discovery.
+func (t *readinessTracker) isPending(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.discoveries > 0 && t.started && !t.ready[id]
+}
+
+// isDiskPendingReadiness checks if the disk is left out of the allocation
+// of the new partitions till it is ready.
+func isDiskPendingReadiness(diskName string) bool {
+	if readiness.discoveries == 0 {
+		return false
+	}
+	id, err := getDiskIdentifier(diskName)
+	if err != nil {
+		return false
+	}
+	return readiness.isPending(id)
+}
+
+// checkDiskReadable reads the start of the disk.
+func checkDiskReadable(diskName string) error {
+	f, err := os.Open(devicePath(diskName))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := make([]byte, readinessCheckBytes)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return errors.Wrapf(err, "failed to read the first %d bytes", readinessCheckBytes)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+)
+
+func Test_readinessTracker(t *testing.T) {
+	unreadable := map[string]bool{}
+	tr := newReadinessTracker(3)
+	tr.check = func(diskName string) error {
+		if unreadable[diskName] {
+			return errors.New("i/o error")
+		}
+		return nil
+	}
+	// discover runs a discovery of the disks, by their UUID, and returns
+	// the ones pending readiness.
+	discover := func(ids ...string) map[string]bool {
+		present, pending := map[string]bool{}, map[string]bool{}
+		for _, id := range ids {
+			present[id] = true
+			if tr.observe(id, "disk-"+id) {
+				pending[id] = true
+			}
+		}
+		tr.endDiscovery(present)
+		return pending
+	}
+
+	// the disks present at the start are ready.
+	if pending := discover("a"); len(pending) != 0 {
+		t.Fatalf("first discovery got %v pending, want none", pending)
+	}
+	// a new disk is pending till it is present for 3 discoveries.
+	for i := 1; i < 3; i++ {
+		if pending := discover("a", "b"); !pending["b"] || pending["a"] {
+			t.Fatalf("discovery %d got %v pending, want b only", i, pending)
+		}
+		if !tr.isPending("b") || tr.isPending("a") {
+			t.Errorf("discovery %d isPending(b) = %v, isPending(a) = %v", i, tr.isPending("b"), tr.isPending("a"))
+		}
+	}
+	if pending := discover("a", "b"); len(pending) != 0 {
+		t.Fatalf("third discovery got %v pending, want none", pending)
+	}
+
+	// a disk disappearing starts over, along with one which can't be read.
+	unreadable["disk-c"] = true
+	discover("a", "c")
+	discover("a")
+	for i := 1; i < 3; i++ {
+		if pending := discover("a", "b", "c"); !pending["b"] || !pending["c"] {
+			t.Fatalf("discovery %d after the reappearance got %v pending, want b and c", i, pending)
+		}
+	}
+	if pending := discover("a", "b", "c"); pending["b"] || !pending["c"] {
+		t.Fatalf("third discovery after the reappearance got %v pending, want c only", pending)
+	}
+	unreadable["disk-c"] = false
+	if pending := discover("a", "b", "c"); len(pending) != 0 {
+		t.Errorf("got %v pending once c is readable, want none", pending)
+	}
+}
+
+func Test_readinessTrackerDisabled(t *testing.T) {
+	tr := newReadinessTracker(0)
+	tr.endDiscovery(map[string]bool{})
+	if tr.observe("a", "disk-a") || tr.isPending("a") {
+		t.Errorf("expected the new disks to be ready right away")
+	}
+	if err := SetDeviceReadyDiscoveries(-1); err == nil {
+		t.Errorf("expected a negative number of discoveries to be rejected")
+	}
+}
+
+func Test_checkDiskReadable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "readiness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	devRoot := DevRoot
+	DevRoot = dir
+	defer func() { DevRoot = devRoot }()
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "sdb"), make([]byte, 2*readinessCheckBytes), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sdc"), make([]byte, 512), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkDiskReadable("sdb"); err != nil {
+		t.Errorf("checkDiskReadable(sdb) unexpected error %v", err)
+	}
+	if err := checkDiskReadable("sdc"); err == nil {
+		t.Errorf("expected a short read to fail")
+	}
+	if err := checkDiskReadable("sdd"); err == nil {
+		t.Errorf("expected a missing disk to fail")
+	}
+}
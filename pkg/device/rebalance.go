@@ -0,0 +1,318 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// RebalanceMove is a recommended relocation of the partition of a volume
+// to another disk of the device, along with the expected effect of the
+// move on the free space of the disks.
+type RebalanceMove struct {
+	Volume   string `json:"volume"`
+	FromDisk string `json:"fromDisk"`
+	ToDisk   string `json:"toDisk"`
+	// SizeMiB is the size of the partition of the volume, including its
+	// growth reserve.
+	SizeMiB uint64 `json:"sizeMiB"`
+	// ImbalanceBeforeMiB and ImbalanceAfterMiB are the difference between
+	// the free space of the disks having the most and the least free space,
+	// before and after the move.
+	ImbalanceBeforeMiB uint64 `json:"imbalanceBeforeMiB"`
+	ImbalanceAfterMiB  uint64 `json:"imbalanceAfterMiB"`
+	// LargestFreeBeforeMiB and LargestFreeAfterMiB are the size of the
+	// largest partition that can be created on the disks, before and after
+	// the move.
+	LargestFreeBeforeMiB uint64 `json:"largestFreeBeforeMiB"`
+	LargestFreeAfterMiB  uint64 `json:"largestFreeAfterMiB"`
+}
+
+// layoutPart is a partition of a disk, spanning the bytes [start, end).
+type layoutPart struct {
+	name       string
+	start, end uint64
+	meta       bool
+}
+
+// sizeMiB returns the size of the partition in MiB, as allocated.
+func (p layoutPart) sizeMiB() uint64 {
+	return (p.end - p.start + PartitionAlignmentBytes - 1) / PartitionAlignmentBytes
+}
+
+// diskLayout is the layout of the partitions of a disk, used for planning
+// the moves of the volumes between the disks.
+type diskLayout struct {
+	name  string
+	size  uint64
+	parts []layoutPart
+	// protected is the number of leading bytes never allocated.
+	protected uint64
+}
+
+// volumeUnit is the partition of a volume along with the partition of its
+// growth reserve, which are moved together.
+type volumeUnit struct {
+	name  string
+	parts []layoutPart
+}
+
+func (u volumeUnit) sizeMiB() uint64 {
+	var size uint64
+	for _, p := range u.parts {
+		size += p.sizeMiB()
+	}
+	return size
+}
+
+func (d *diskLayout) clone() *diskLayout {
+	return &diskLayout{name: d.name, size: d.size, parts: append([]layoutPart{}, d.parts...), protected: d.protected}
+}
+
+// freeRegions returns the free regions of the disk, as computed by the
+// allocator from the parted output.
+func (d *diskLayout) freeRegions() []partFree {
+	parts := append([]layoutPart{}, d.parts...)
+	sort.Slice(parts, func(i, j int) bool { return parts[i].start < parts[j].start })
+
+	freeRow := func(start, end uint64) []string {
+		// parted reports the end of a region inclusively
+		return []string{fmt.Sprintf("%dB", start), fmt.Sprintf("%dB", end-1),
+			fmt.Sprintf("%dB", end-start), "Free", "Space"}
+	}
+	var rows [][]string
+	var next uint64
+	for _, p := range parts {
+		if p.start > next {
+			rows = append(rows, freeRow(next, p.start))
+		}
+		if p.end > next {
+			next = p.end
+		}
+	}
+	if d.size > next {
+		rows = append(rows, freeRow(next, d.size))
+	}
+	return usableRegions(parseFreeRegions(d.name, d.size, d.protected, rows))
+}
+
+func (d *diskLayout) freeMiB() uint64 {
+	var free uint64
+	for _, region := range d.freeRegions() {
+		free += region.SizeMiB
+	}
+	return free
+}
+
+// units returns the volumes on the disk, sorted by name. The members of
+// the striped volumes are left out, moving one of them next to another
+// member would defeat the striping.
+func (d *diskLayout) units() []volumeUnit {
+	byName := map[string]*volumeUnit{}
+	var names []string
+	for _, p := range d.parts {
+		if p.meta || isStripeMember(p.name) {
+			continue
+		}
+		name := strings.TrimSuffix(p.name, ReservePartitionSuffix)
+		unit, ok := byName[name]
+		if !ok {
+			unit = &volumeUnit{name: name}
+			byName[name] = unit
+			names = append(names, name)
+		}
+		// the volume partition goes first, its reserve right after it
+		if isReservePart(p.name) {
+			unit.parts = append(unit.parts, p)
+		} else {
+			unit.parts = append([]layoutPart{p}, unit.parts...)
+		}
+	}
+	sort.Strings(names)
+	units := make([]volumeUnit, 0, len(names))
+	for _, name := range names {
+		units = append(units, *byName[name])
+	}
+	return units
+}
+
+// move moves the volume from the disk to the start of the free region of
+// the target disk.
+func (d *diskLayout) move(unit volumeUnit, target *diskLayout, region partFree) {
+	moved := map[string]bool{}
+	for _, p := range unit.parts {
+		moved[p.name] = true
+	}
+	var parts []layoutPart
+	for _, p := range d.parts {
+		if !moved[p.name] {
+			parts = append(parts, p)
+		}
+	}
+	d.parts = parts
+
+	start := region.StartMiB * PartitionAlignmentBytes
+	for _, p := range unit.parts {
+		end := start + p.sizeMiB()*PartitionAlignmentBytes
+		target.parts = append(target.parts, layoutPart{name: p.name, start: start, end: end})
+		start = end
+	}
+}
+
+// layoutStats returns the difference between the free space of the disks
+// having the most and the least free space, and the largest free region.
+func layoutStats(layouts []*diskLayout) (imbalance, largest uint64) {
+	var most, least uint64
+	for i, d := range layouts {
+		free := d.freeMiB()
+		if i == 0 || free > most {
+			most = free
+		}
+		if i == 0 || free < least {
+			least = free
+		}
+		if region := largestFreeRegion(d.freeRegions()); region > largest {
+			largest = region
+		}
+	}
+	return most - least, largest
+}
+
+// recommendMoves plans up to maxMoves moves of the volumes between the
+// disks, picking at each step the move that reduces the imbalance of the
+// free space of the disks the most, and then grows the largest free region
+// the most. The target region of a move is picked the way the allocator
+// picks the region of a new partition. It stops when no move improves the
+// layout.
+func recommendMoves(layouts []*diskLayout, maxMoves int) []RebalanceMove {
+	var moves []RebalanceMove
+	for len(moves) < maxMoves {
+		imbalance, largest := layoutStats(layouts)
+
+		var (
+			best    *RebalanceMove
+			bestSrc int
+			bestDst int
+			bestU   volumeUnit
+			bestR   partFree
+		)
+		for si, src := range layouts {
+			for _, unit := range src.units() {
+				for di, dst := range layouts {
+					if di == si {
+						continue
+					}
+					region, ok := selectFreeRegion(dst.freeRegions(), unit.sizeMiB())
+					if !ok {
+						continue
+					}
+					trial := make([]*diskLayout, len(layouts))
+					for i, d := range layouts {
+						trial[i] = d.clone()
+					}
+					trial[si].move(unit, trial[di], region)
+					afterImbalance, afterLargest := layoutStats(trial)
+					if afterImbalance > imbalance || (afterImbalance == imbalance && afterLargest <= largest) {
+						continue
+					}
+					if best != nil && (afterImbalance > best.ImbalanceAfterMiB ||
+						(afterImbalance == best.ImbalanceAfterMiB && afterLargest <= best.LargestFreeAfterMiB)) {
+						continue
+					}
+					best = &RebalanceMove{
+						Volume:               "pvc-" + unit.name,
+						FromDisk:             src.name,
+						ToDisk:               dst.name,
+						SizeMiB:              unit.sizeMiB(),
+						ImbalanceBeforeMiB:   imbalance,
+						ImbalanceAfterMiB:    afterImbalance,
+						LargestFreeBeforeMiB: largest,
+						LargestFreeAfterMiB:  afterLargest,
+					}
+					bestSrc, bestDst, bestU, bestR = si, di, unit, region
+				}
+			}
+		}
+		if best == nil {
+			break
+		}
+		layouts[bestSrc].move(bestU, layouts[bestDst], bestR)
+		moves = append(moves, *best)
+	}
+	return moves
+}
+
+// readDiskLayouts reads the layouts of the disks having the meta partition
+// of the device, leaving out the disks excluded in the DeviceNode spec.
+func readDiskLayouts(diskMetaName string) ([]*diskLayout, error) {
+	diskList, err := getDiskList()
+	if err != nil {
+		return nil, err
+	}
+	var layouts []*diskLayout
+	for _, disk := range diskList {
+		if isDiskExcluded(disk.DiskName) {
+			continue
+		}
+		rows, err := GetPartitionList(disk.DiskName, diskMetaName, false)
+		if err != nil || len(rows) == 0 {
+			continue
+		}
+		if _, ok := getMetaPartition(rows[0]); !ok {
+			continue
+		}
+		layout := &diskLayout{name: disk.DiskName, size: disk.Size, protected: diskProtectedBytes(disk.DiskName)}
+		for i, row := range rows {
+			if len(row) < 4 {
+				continue
+			}
+			start, err := strconv.ParseUint(strings.TrimSuffix(row[1], "B"), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid start of partition for disk %q - %+v", disk.DiskName, row)
+			}
+			end, err := strconv.ParseUint(strings.TrimSuffix(row[2], "B"), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid end of partition for disk %q - %+v", disk.DiskName, row)
+			}
+			layout.parts = append(layout.parts, layoutPart{
+				name: row[len(row)-1], start: start, end: end + 1, meta: i == 0,
+			})
+		}
+		layouts = append(layouts, layout)
+	}
+	sort.Slice(layouts, func(i, j int) bool { return layouts[i].name < layouts[j].name })
+	return layouts, nil
+}
+
+// RecommendRebalance analyzes the layout of the partitions on the disks of
+// the device and recommends up to maxMoves moves of the volumes between the
+// disks, which even out the free space of the disks or grow the largest
+// partition that can be created. The moves are not performed, and each
+// move assumes the previous ones are done.
+func RecommendRebalance(diskMetaName string, maxMoves int) ([]RebalanceMove, error) {
+	layouts, err := readDiskLayouts(diskMetaName)
+	if err != nil {
+		return nil, err
+	}
+	klog.V(4).Infof("planning rebalance of %d disks of device %s", len(layouts), diskMetaName)
+	return recommendMoves(layouts, maxMoves), nil
+}
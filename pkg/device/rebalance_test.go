@@ -0,0 +1,101 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_recommendMoves(t *testing.T) {
+	const mib = PartitionAlignmentBytes
+	part := func(name string, startMiB, endMiB uint64) layoutPart {
+		return layoutPart{name: name, start: startMiB * mib, end: endMiB * mib}
+	}
+	meta := layoutPart{name: "test-device", start: 1 * mib, end: 2 * mib, meta: true}
+	disk := func(name string, parts ...layoutPart) *diskLayout {
+		return &diskLayout{name: name, size: 1024 * mib, parts: append([]layoutPart{meta}, parts...)}
+	}
+
+	tests := []struct {
+		name     string
+		layouts  []*diskLayout
+		maxMoves int
+		want     []RebalanceMove
+	}{
+		{
+			name: "volumes on one disk",
+			layouts: []*diskLayout{
+				disk("sda", part("a", 2, 302), part("b", 302, 502)),
+				disk("sdb"),
+			},
+			maxMoves: 10,
+			// moving either volume evens out the disks as much, moving b
+			// leaves the larger free region.
+			want: []RebalanceMove{{
+				Volume: "pvc-b", FromDisk: "sda", ToDisk: "sdb", SizeMiB: 200,
+				ImbalanceBeforeMiB: 500, ImbalanceAfterMiB: 100,
+				LargestFreeBeforeMiB: 1021, LargestFreeAfterMiB: 821,
+			}},
+		},
+		{
+			name: "volume with growth reserve",
+			layouts: []*diskLayout{
+				disk("sda", part("a", 2, 102), part("a-reserve", 102, 202), part("c", 202, 602)),
+				disk("sdb"),
+			},
+			maxMoves: 10,
+			// the reserve moves along with the volume, leaving as large a
+			// free region as moving c.
+			want: []RebalanceMove{{
+				Volume: "pvc-a", FromDisk: "sda", ToDisk: "sdb", SizeMiB: 200,
+				ImbalanceBeforeMiB: 600, ImbalanceAfterMiB: 200,
+				LargestFreeBeforeMiB: 1021, LargestFreeAfterMiB: 821,
+			}},
+		},
+		{
+			name: "balanced disks",
+			layouts: []*diskLayout{
+				disk("sda", part("a", 2, 302)),
+				disk("sdb", part("b", 2, 302)),
+			},
+			maxMoves: 10,
+		},
+		{
+			name: "moves limited",
+			layouts: []*diskLayout{
+				disk("sda", part("a", 2, 202), part("b", 202, 402), part("c", 402, 602), part("d", 602, 802)),
+				disk("sdb"),
+				disk("sdc"),
+			},
+			maxMoves: 1,
+			want: []RebalanceMove{{
+				Volume: "pvc-a", FromDisk: "sda", ToDisk: "sdb", SizeMiB: 200,
+				ImbalanceBeforeMiB: 800, ImbalanceAfterMiB: 600,
+				LargestFreeBeforeMiB: 1021, LargestFreeAfterMiB: 1021,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := recommendMoves(tt.layouts, tt.maxMoves)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recommendMoves() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
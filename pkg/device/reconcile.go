@@ -0,0 +1,150 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+)
+
+const (
+	// SlowReconcileReason is the reason of the warning events recorded on
+	// the objects whose reconcile was slow.
+	SlowReconcileReason = "SlowReconcile"
+	// slowReconcileLogInterval is the minimum interval between the logs of
+	// the slow reconciles of a controller.
+	slowReconcileLogInterval = time.Minute
+)
+
+// ReconcileDuration observes the duration of the reconciles of the
+// controllers of the node agent.
+var ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "openebs",
+	Subsystem: "device",
+	Name:      "reconcile_duration_seconds",
+	Help:      "Duration of the reconciles of the controller.",
+	Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+}, []string{"controller"})
+
+// slowReconcileThreshold is the duration after which a reconcile is
+// reported as slow, zero disables the reporting.
+var slowReconcileThreshold time.Duration
+
+// SetSlowReconcileThreshold sets the duration after which a reconcile is
+// reported as slow. Zero disables the reporting.
+func SetSlowReconcileThreshold(threshold time.Duration) {
+	slowReconcileThreshold = threshold
+}
+
+// SlowReconcile describes a reconcile which took longer than the threshold.
+type SlowReconcile struct {
+	Controller string
+	Key        string
+	Duration   time.Duration
+	// Command is the slowest command run during the reconcile, nil if no
+	// command was run. The workers of a controller run concurrently, so it
+	// may have been run by another reconcile.
+	Command *CommandRecord
+}
+
+// String describes the slow reconcile along with its slowest command.
+func (s *SlowReconcile) String() string {
+	msg := fmt.Sprintf("%s reconcile of %s took %v", s.Controller, s.Key, s.Duration.Round(time.Millisecond))
+	if s.Command == nil {
+		return msg
+	}
+	return fmt.Sprintf("%s, slowest disk operation %q took %v", msg,
+		s.Command.Command, s.Command.Duration.Round(time.Millisecond))
+}
+
+// logLimiter rate limits the logs of the slow reconciles per controller,
+// counting the ones left out.
+type logLimiter struct {
+	mtx        sync.Mutex
+	interval   time.Duration
+	last       map[string]time.Time
+	suppressed map[string]int
+}
+
+var slowReconcileLogs = newLogLimiter(slowReconcileLogInterval)
+
+func newLogLimiter(interval time.Duration) *logLimiter {
+	return &logLimiter{
+		interval:   interval,
+		last:       map[string]time.Time{},
+		suppressed: map[string]int{},
+	}
+}
+
+// allow reports whether a log of the controller is allowed at now, along
+// with the number of the logs suppressed since the last allowed one.
+func (l *logLimiter) allow(controller string, now time.Time) (bool, int) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if last, ok := l.last[controller]; ok && now.Sub(last) < l.interval {
+		l.suppressed[controller]++
+		return false, 0
+	}
+	suppressed := l.suppressed[controller]
+	l.last[controller] = now
+	l.suppressed[controller] = 0
+	return true, suppressed
+}
+
+// ObserveReconcile records the duration of the reconcile of the key by the
+// controller started at the given time. It returns the slow reconcile if
+// it took longer than the threshold, nil otherwise.
+func ObserveReconcile(controller, key string, start time.Time) *SlowReconcile {
+	now := time.Now()
+	duration := now.Sub(start)
+	ReconcileDuration.WithLabelValues(controller).Observe(duration.Seconds())
+
+	threshold := slowReconcileThreshold
+	if threshold == 0 || duration <= threshold {
+		return nil
+	}
+	slow := &SlowReconcile{
+		Controller: controller,
+		Key:        key,
+		Duration:   duration,
+		Command:    slowestCommandSince(history.recent(), start),
+	}
+	if ok, suppressed := slowReconcileLogs.allow(controller, now); ok {
+		klog.Warningf("slow reconcile: %s, exceeding %v; %d slow reconciles suppressed since the last log",
+			slow, threshold, suppressed)
+	}
+	return slow
+}
+
+// slowestCommandSince returns the slowest of the commands started at or
+// after the given time, nil if there are none.
+func slowestCommandSince(records []CommandRecord, start time.Time) *CommandRecord {
+	var slowest *CommandRecord
+	for i := range records {
+		if records[i].StartTime.Before(start) {
+			continue
+		}
+		if slowest == nil || records[i].Duration > slowest.Duration {
+			slowest = &records[i]
+		}
+	}
+	return slowest
+}
@@ -0,0 +1,87 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowestCommandSince(t *testing.T) {
+	start := time.Now()
+	records := []CommandRecord{
+		{Command: "parted /dev/sdb print", StartTime: start.Add(-time.Minute), Duration: time.Minute},
+		{Command: "parted /dev/sdb mkpart", StartTime: start.Add(time.Second), Duration: 20 * time.Second},
+		{Command: "wipefs /dev/sdb2", StartTime: start.Add(30 * time.Second), Duration: time.Second},
+	}
+
+	slowest := slowestCommandSince(records, start)
+	if assert.NotNil(t, slowest) {
+		assert.Equal(t, "parted /dev/sdb mkpart", slowest.Command)
+	}
+	assert.Nil(t, slowestCommandSince(records, start.Add(time.Hour)))
+	assert.Nil(t, slowestCommandSince(nil, start))
+}
+
+func TestObserveReconcile(t *testing.T) {
+	defer SetSlowReconcileThreshold(0)
+
+	SetSlowReconcileThreshold(0)
+	assert.Nil(t, ObserveReconcile("devicevolume", "openebs/pvc-1", time.Now().Add(-time.Minute)))
+
+	SetSlowReconcileThreshold(10 * time.Second)
+	assert.Nil(t, ObserveReconcile("devicevolume", "openebs/pvc-1", time.Now()))
+
+	slow := ObserveReconcile("devicevolume", "openebs/pvc-1", time.Now().Add(-time.Minute))
+	if assert.NotNil(t, slow) {
+		assert.Equal(t, "devicevolume", slow.Controller)
+		assert.Equal(t, "openebs/pvc-1", slow.Key)
+		assert.True(t, slow.Duration >= time.Minute)
+	}
+}
+
+func TestSlowReconcileString(t *testing.T) {
+	slow := &SlowReconcile{Controller: "devicevolume", Key: "openebs/pvc-1", Duration: 12 * time.Second}
+	assert.Equal(t, "devicevolume reconcile of openebs/pvc-1 took 12s", slow.String())
+
+	slow.Command = &CommandRecord{Command: "parted /dev/sdb mkpart", Duration: 11 * time.Second}
+	assert.Equal(t, `devicevolume reconcile of openebs/pvc-1 took 12s, slowest disk operation "parted /dev/sdb mkpart" took 11s`,
+		slow.String())
+}
+
+func TestLogLimiter(t *testing.T) {
+	l := newLogLimiter(time.Minute)
+	now := time.Now()
+
+	ok, suppressed := l.allow("devicevolume", now)
+	assert.True(t, ok)
+	assert.Equal(t, 0, suppressed)
+
+	ok, _ = l.allow("devicevolume", now.Add(time.Second))
+	assert.False(t, ok)
+	ok, _ = l.allow("devicevolume", now.Add(2*time.Second))
+	assert.False(t, ok)
+
+	ok, _ = l.allow("devicenode", now.Add(2*time.Second))
+	assert.True(t, ok, "controllers are limited separately")
+
+	ok, suppressed = l.allow("devicevolume", now.Add(time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, 2, suppressed)
+}
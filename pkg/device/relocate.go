@@ -0,0 +1,465 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// Phases of the relocation of a volume
+const (
+	RelocationAllocating = "Allocating"
+	RelocationCopying    = "Copying"
+	RelocationSwitching  = "Switching"
+	RelocationReleasing  = "Releasing"
+	RelocationCompleted  = "Completed"
+	RelocationFailed     = "Failed"
+)
+
+// The progress of a relocation is kept in the names of the partitions
+// taking part in it, so that an interrupted relocation is resumed from the
+// partitions found on the disks.
+const (
+	// relocatingSuffix names the partition allocated on the target disk
+	// till the data of the volume got copied to it.
+	relocatingSuffix = "-relocating"
+	// copiedSuffix names the partition on the target disk holding a
+	// complete copy of the data of the volume.
+	copiedSuffix = "-copied"
+	// relocatedSuffix names the partition left on the source disk once the
+	// volume got switched to the target disk.
+	relocatedSuffix = "-relocated"
+)
+
+// PartitionCopy copies the data of a partition to another one.
+const PartitionCopy = "dd if=%s of=%s bs=4M conv=fsync status=none"
+
+// relocationStep is a step of the relocation of a volume, run by a single
+// reconcile of the volume.
+type relocationStep int
+
+const (
+	// relocationAllocate creates the partition on the target disk.
+	relocationAllocate relocationStep = iota
+	// relocationCopy copies the data to the partition on the target disk.
+	relocationCopy
+	// relocationSwitchSource renames the partition on the source disk, so
+	// that it is not the partition of the volume anymore.
+	relocationSwitchSource
+	// relocationSwitchTarget renames the partition on the target disk to
+	// the partition of the volume.
+	relocationSwitchTarget
+	// relocationRelease deletes the partition left on the source disk.
+	relocationRelease
+	// relocationDone records the end of the relocation.
+	relocationDone
+)
+
+// phase returns the phase of the relocation while the step is to be run.
+func (s relocationStep) phase() string {
+	switch s {
+	case relocationAllocate:
+		return RelocationAllocating
+	case relocationCopy:
+		return RelocationCopying
+	case relocationSwitchSource, relocationSwitchTarget:
+		return RelocationSwitching
+	case relocationRelease:
+		return RelocationReleasing
+	default:
+		return RelocationCompleted
+	}
+}
+
+// relocationParts are the partitions taking part in the relocation of a
+// volume, nil when not found.
+type relocationParts struct {
+	// source is the partition of the volume on another disk than the
+	// target one.
+	source *PartUsed
+	// current is the partition of the volume on the target disk.
+	current *PartUsed
+	// relocating and copied are the partitions on the target disk, before
+	// and after the copy of the data.
+	relocating *PartUsed
+	copied     *PartUsed
+	// relocated is the partition left on the source disk after the switch.
+	relocated *PartUsed
+}
+
+// nextRelocationStep returns the step to be run next by the relocation of a
+// volume, from the partitions found on the disks.
+func nextRelocationStep(p relocationParts) (relocationStep, error) {
+	switch {
+	case p.current != nil:
+		if p.relocating != nil || p.copied != nil || p.source != nil {
+			return 0, errors.New("partition of the volume found on both the source and the target disks")
+		}
+		if p.relocated != nil {
+			return relocationRelease, nil
+		}
+		return relocationDone, nil
+	case p.source != nil:
+		if p.relocated != nil || (p.relocating != nil && p.copied != nil) {
+			return 0, errors.New("partitions of an earlier relocation found")
+		}
+		if p.copied != nil {
+			return relocationSwitchSource, nil
+		}
+		if p.relocating != nil {
+			return relocationCopy, nil
+		}
+		return relocationAllocate, nil
+	case p.copied != nil && p.relocated != nil:
+		return relocationSwitchTarget, nil
+	default:
+		return 0, errors.New("partition of the volume not found")
+	}
+}
+
+// listRelocationParts finds the partitions taking part in the relocation
+// of the partition partitionName to the target disk, on the disks having
+// the meta partition diskMetaName.
+func listRelocationParts(diskMetaName, partitionName, target string) (relocationParts, error) {
+	var parts relocationParts
+	diskList, err := getDiskList()
+	if err != nil {
+		return parts, err
+	}
+	for _, disk := range diskList {
+		tmpList, err := GetPartitionList(disk.DiskName, diskMetaName, false)
+		if err != nil {
+			continue
+		}
+		for _, tmp := range tmpList {
+			var slot **PartUsed
+			switch name := tmp[len(tmp)-1]; {
+			case name == partitionName && disk.DiskName == target:
+				slot = &parts.current
+			case name == partitionName:
+				slot = &parts.source
+			case name == partitionName+relocatingSuffix && disk.DiskName == target:
+				slot = &parts.relocating
+			case name == partitionName+copiedSuffix && disk.DiskName == target:
+				slot = &parts.copied
+			case name == partitionName+relocatedSuffix:
+				slot = &parts.relocated
+			default:
+				continue
+			}
+			if *slot != nil {
+				return parts, errors.Errorf("more than one partition named %s", tmp[len(tmp)-1])
+			}
+			part, err := parsePartUsed(disk.DiskName, tmp)
+			if err != nil {
+				return parts, err
+			}
+			*slot = &part
+		}
+	}
+	return parts, nil
+}
+
+// relocationError is a failure of the relocation which is not retried, the
+// partition created on the target disk is deleted.
+type relocationError struct {
+	error
+}
+
+// relocationTarget returns the disk the partition of the volume is moved
+// to, as named by its relocate-to annotation or, once the annotation got
+// removed, by the relocation in progress.
+func relocationTarget(vol *apis.DeviceVolume) string {
+	if target := vol.Annotations[RelocateToKey]; target != "" {
+		return target
+	}
+	if r := vol.Status.Relocation; r != nil && isRelocationRunning(r) {
+		return r.TargetDisk
+	}
+	return ""
+}
+
+// isRelocationRunning checks whether the relocation got started and is not
+// over yet.
+func isRelocationRunning(r *apis.VolumeRelocation) bool {
+	return r.Phase != "" && r.Phase != RelocationCompleted && r.Phase != RelocationFailed
+}
+
+// IsRelocationPending checks whether the partition of the volume is to be
+// moved to another disk. A relocation can't be cancelled, it is carried on
+// even if the annotation requesting it got removed. A failed relocation is
+// not retried till it gets requested again.
+func IsRelocationPending(vol *apis.DeviceVolume) bool {
+	target := relocationTarget(vol)
+	if target == "" {
+		return false
+	}
+	r := vol.Status.Relocation
+	return r == nil || r.TargetDisk != target || r.Phase != RelocationFailed
+}
+
+// CheckVolumeNotRelocating fails if the partition of the volume is being
+// moved to another disk, so that it is not mounted while its data is
+// copied. kubelet keeps retrying the mount, which goes through once the
+// relocation is over.
+func CheckVolumeNotRelocating(vol *apis.DeviceVolume) error {
+	if IsRelocationPending(vol) {
+		return status.Errorf(codes.FailedPrecondition,
+			"volume %s is being relocated to disk %s", vol.Name, relocationTarget(vol))
+	}
+	return nil
+}
+
+// RequestVolumeRelocation requests the move of the partition of the volume
+// to the given disk of its node, run by the node agent. A failed
+// relocation is retried.
+func RequestVolumeRelocation(volName, disk string) error {
+	vol, err := GetDeviceVolume(volName)
+	if err != nil {
+		return err
+	}
+	if IsStripedVolume(vol) {
+		return errors.Errorf("volume %s is striped, it can't be relocated", volName)
+	}
+	if r := vol.Status.Relocation; r != nil && isRelocationRunning(r) {
+		return errors.Errorf("volume %s is being relocated to disk %s", volName, r.TargetDisk)
+	}
+	if vol.Annotations == nil {
+		vol.Annotations = map[string]string{}
+	}
+	vol.Annotations[RelocateToKey] = disk
+	vol.Status.Relocation = nil
+	if err = UpdateVolume(vol); err != nil {
+		return errors.Wrapf(err, "could not relocate volume %s", volName)
+	}
+	klog.Infof("requested the relocation of volume %s to disk %s", volName, disk)
+	return nil
+}
+
+// RelocateVolume runs the next step of the move of the partition of the
+// volume to the disk named by its relocate-to annotation, and records the
+// progress in the status of the volume. The update of the volume triggers
+// the next step. A failure before the switch of the volume to the target
+// disk rolls the relocation back and marks it failed, while the later
+// failures are retried, the data being safe on the target disk by then.
+// The copy of the data is aborted when the context is done, and resumed by
+// the next reconcile.
+func RelocateVolume(ctx context.Context, vol *apis.DeviceVolume) error {
+	target := relocationTarget(vol)
+	partitionName := vol.Name[4:]
+
+	unlock, err := lockMetaDisks(vol.Spec.DevName)
+	if err != nil {
+		return err
+	}
+	defer func() { unlock() }()
+
+	if vol.Status.Relocation == nil || vol.Status.Relocation.TargetDisk != target {
+		vol.Status.Relocation = &apis.VolumeRelocation{TargetDisk: target}
+	}
+	parts, err := listRelocationParts(vol.Spec.DevName, partitionName, target)
+	if err != nil {
+		return err
+	}
+	step, err := nextRelocationStep(parts)
+	if err != nil {
+		return errors.Wrapf(err, "could not relocate volume %s", vol.Name)
+	}
+
+	if step == relocationCopy {
+		// the copy only writes the partition named with the relocating
+		// suffix, which no other volume gets, so the disks are unlocked
+		// while it runs and only the changes of the partition tables are
+		// serialized.
+		unlock()
+		unlock = func() {}
+		err = copyRelocationData(ctx, parts, partitionName, target)
+		relock, lockErr := lockMetaDisks(vol.Spec.DevName)
+		if lockErr != nil {
+			return lockErr
+		}
+		unlock = relock
+	}
+	if err == nil {
+		err = runRelocationStep(vol, step, parts, partitionName, target)
+	}
+	if err != nil {
+		if _, ok := err.(*relocationError); !ok {
+			return err
+		}
+		klog.Errorf("relocation of volume %s to disk %s failed: %v", vol.Name, target, err)
+		if rbErr := rollbackRelocation(parts); rbErr != nil {
+			return errors.Wrapf(rbErr, "could not roll back relocation of volume %s", vol.Name)
+		}
+		vol.Status.Relocation.Phase = RelocationFailed
+		vol.Status.Relocation.Message = err.Error()
+		return UpdateVolume(vol)
+	}
+
+	vol.Status.Relocation.Phase = (step + 1).phase()
+	vol.Status.Relocation.Message = ""
+	if step >= relocationSwitchTarget {
+		setDiskUUID(vol, target)
+	}
+	if step >= relocationRelease {
+		delete(vol.Annotations, RelocateToKey)
+		klog.Infof("relocated volume %s to disk %s", vol.Name, target)
+	}
+	return UpdateVolume(vol)
+}
+
+// runRelocationStep runs the step of the relocation of the partition
+// partitionName to the target disk.
+func runRelocationStep(vol *apis.DeviceVolume, step relocationStep, parts relocationParts,
+	partitionName, target string) error {
+	switch step {
+	case relocationAllocate:
+		return allocateRelocation(vol, parts.source, partitionName, target)
+	case relocationCopy:
+		// the data got copied by copyRelocationData, the partition gets
+		// the type of the volume before taking its place.
+		err := setPartitionType(parts.relocating.DiskName, parts.relocating.PartNum, vol.Spec.PartitionType)
+		if err != nil {
+			return err
+		}
+		return renamePart(parts.relocating, partitionName+copiedSuffix)
+	case relocationSwitchSource:
+		// the data written after the copy would be lost.
+		if err := checkPartitionNotHeld(parts.source.DevicePath); err != nil {
+			return &relocationError{errors.Wrapf(err, "volume got used during its copy")}
+		}
+		return renamePart(parts.source, partitionName+relocatedSuffix)
+	case relocationSwitchTarget:
+		if err := renamePart(parts.copied, partitionName); err != nil {
+			return err
+		}
+		if vol.Status.DevicePath != "" {
+			vol.Status.DevicePath = parts.copied.DevicePath
+		}
+		return nil
+	case relocationRelease:
+		if err := activePartitioner.verify(parts.relocated.DiskName); err != nil {
+			return err
+		}
+		return wipefsAndDeletePart(parts.relocated.DiskName, parts.relocated.PartNum)
+	}
+	return nil
+}
+
+// copyRelocationData copies the data of the source partition to the
+// partition allocated on the target disk. The source partition must not be
+// in use whatever the exclusive device check, as the data written during
+// the copy would be lost.
+func copyRelocationData(ctx context.Context, parts relocationParts, partitionName, target string) error {
+	if err := checkPartitionNotHeld(parts.source.DevicePath); err != nil {
+		return &relocationError{errors.Wrapf(err, "volume is in use, its pods have to be stopped")}
+	}
+	klog.Infof("copying partition %s of disk %s to disk %s", partitionName, parts.source.DiskName, target)
+	// the copy of a large partition outlasts the command timeout, and would
+	// hold an execution slot for as long.
+	cmd := fmt.Sprintf(PartitionCopy, parts.source.DevicePath, parts.relocating.DevicePath)
+	if _, _, err := runUnslottedCommand(ctx, strings.Split(cmd, " ")); err != nil {
+		if ctx.Err() != nil {
+			// the copy is started over by the next reconcile.
+			return err
+		}
+		return &relocationError{err}
+	}
+	return nil
+}
+
+// allocateRelocation creates the partition on the target disk the data of
+// the volume is copied to, as large as the source partition.
+func allocateRelocation(vol *apis.DeviceVolume, source *PartUsed, partitionName, target string) error {
+	if IsStripedVolume(vol) {
+		return &relocationError{errors.New("striped volumes can't be relocated")}
+	}
+	if vol.Spec.GrowthReserve != "" {
+		return &relocationError{errors.New("volumes with a growth reserve can't be relocated")}
+	}
+	if err := checkPartitionNotHeld(source.DevicePath); err != nil {
+		return &relocationError{errors.Wrapf(err, "volume is in use, its pods have to be stopped")}
+	}
+
+	pList, disks, err := getAllPartsFreeTraced(vol.Spec.DevName)
+	if err != nil {
+		return err
+	}
+	reason, ok := disks[target]
+	if !ok {
+		return &relocationError{errors.Errorf("disk %s not found", target)}
+	}
+	if reason != "" {
+		return &relocationError{errors.Errorf("disk %s can't be used: %s", target, reason)}
+	}
+	var regions []partFree
+	for _, region := range pList {
+		if region.DiskName == target {
+			regions = append(regions, region)
+		}
+	}
+	sizeMiB := (source.Size + PartitionAlignmentBytes - 1) / PartitionAlignmentBytes
+	region, ok := selectFreeRegion(regions, sizeMiB)
+	if !ok {
+		return &relocationError{errors.Errorf("disk %s has no free region of %d MiB", target, sizeMiB)}
+	}
+
+	if err = activePartitioner.verify(target); err != nil {
+		return err
+	}
+	klog.Infof("allocating %d MiB on disk %s for relocating volume %s", sizeMiB, target, vol.Name)
+	err = activePartitioner.create(target, partitionName+relocatingSuffix, region.StartMiB, region.StartMiB+sizeMiB)
+	if err != nil {
+		return &relocationError{err}
+	}
+	return nil
+}
+
+// renamePart sets the name of the partition.
+func renamePart(part *PartUsed, name string) error {
+	if err := activePartitioner.setName(part.DiskName, part.PartNum, name); err != nil {
+		return errors.Wrapf(err, "could not name partition %d of disk %s", part.PartNum, part.DiskName)
+	}
+	return nil
+}
+
+// rollbackRelocation deletes the partition created on the target disk by
+// a relocation which failed before the switch of the volume. The partition
+// on the source disk is left untouched till then.
+func rollbackRelocation(parts relocationParts) error {
+	for _, part := range []*PartUsed{parts.relocating, parts.copied} {
+		if part == nil {
+			continue
+		}
+		if err := activePartitioner.verify(part.DiskName); err != nil {
+			return err
+		}
+		if err := wipefsAndDeletePart(part.DiskName, part.PartNum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
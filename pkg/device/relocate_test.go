@@ -0,0 +1,193 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func Test_nextRelocationStep(t *testing.T) {
+	part := &PartUsed{}
+	tests := []struct {
+		name    string
+		parts   relocationParts
+		want    relocationStep
+		wantErr bool
+	}{
+		{name: "not started", parts: relocationParts{source: part}, want: relocationAllocate},
+		{name: "allocated", parts: relocationParts{source: part, relocating: part}, want: relocationCopy},
+		{name: "copied", parts: relocationParts{source: part, copied: part}, want: relocationSwitchSource},
+		{name: "source renamed", parts: relocationParts{relocated: part, copied: part}, want: relocationSwitchTarget},
+		{name: "switched", parts: relocationParts{current: part, relocated: part}, want: relocationRelease},
+		{name: "released", parts: relocationParts{current: part}, want: relocationDone},
+		{name: "volume lost", parts: relocationParts{}, wantErr: true},
+		{name: "only the copy left", parts: relocationParts{copied: part}, wantErr: true},
+		{name: "volume on both disks", parts: relocationParts{current: part, source: part}, wantErr: true},
+		{name: "copy along with the volume on the target", parts: relocationParts{current: part, copied: part}, wantErr: true},
+		{name: "both partitions on the target", parts: relocationParts{source: part, relocating: part, copied: part}, wantErr: true},
+		{name: "leftover of an earlier relocation", parts: relocationParts{source: part, relocated: part}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextRelocationStep(tt.parts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nextRelocationStep() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("nextRelocationStep() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_relocationStepPhase(t *testing.T) {
+	// the phase recorded after a step is the phase of the next one.
+	want := []string{RelocationCopying, RelocationSwitching, RelocationSwitching,
+		RelocationReleasing, RelocationCompleted, RelocationCompleted}
+	for step := relocationAllocate; step <= relocationDone; step++ {
+		if got := (step + 1).phase(); got != want[step] {
+			t.Errorf("phase after step %d = %s, want %s", step, got, want[step])
+		}
+	}
+	if got := relocationAllocate.phase(); got != RelocationAllocating {
+		t.Errorf("phase of the first step = %s, want %s", got, RelocationAllocating)
+	}
+}
+
+func TestIsRelocationPending(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		relocation *apis.VolumeRelocation
+		want       bool
+		wantTarget string
+	}{
+		{name: "not requested", want: false},
+		{name: "requested", annotation: "sdc", want: true, wantTarget: "sdc"},
+		{
+			name: "in progress", annotation: "sdc", want: true, wantTarget: "sdc",
+			relocation: &apis.VolumeRelocation{TargetDisk: "sdc", Phase: RelocationCopying},
+		},
+		{
+			name: "failed", annotation: "sdc", want: false, wantTarget: "sdc",
+			relocation: &apis.VolumeRelocation{TargetDisk: "sdc", Phase: RelocationFailed},
+		},
+		{
+			name: "failed, requested to another disk", annotation: "sdd", want: true, wantTarget: "sdd",
+			relocation: &apis.VolumeRelocation{TargetDisk: "sdc", Phase: RelocationFailed},
+		},
+		{
+			name: "annotation removed while switching", want: true, wantTarget: "sdc",
+			relocation: &apis.VolumeRelocation{TargetDisk: "sdc", Phase: RelocationSwitching},
+		},
+		{
+			name:       "completed",
+			relocation: &apis.VolumeRelocation{TargetDisk: "sdc", Phase: RelocationCompleted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vol := &apis.DeviceVolume{}
+			if tt.annotation != "" {
+				vol.Annotations = map[string]string{RelocateToKey: tt.annotation}
+			}
+			vol.Status.Relocation = tt.relocation
+			if got := IsRelocationPending(vol); got != tt.want {
+				t.Errorf("IsRelocationPending() = %v, want %v", got, tt.want)
+			}
+			if got := relocationTarget(vol); got != tt.wantTarget {
+				t.Errorf("relocationTarget() = %q, want %q", got, tt.wantTarget)
+			}
+			err := CheckVolumeNotRelocating(vol)
+			if tt.want && status.Code(err) != codes.FailedPrecondition {
+				t.Errorf("CheckVolumeNotRelocating() got %v, want FailedPrecondition", err)
+			}
+			if !tt.want && err != nil {
+				t.Errorf("CheckVolumeNotRelocating() unexpected error %v", err)
+			}
+		})
+	}
+}
+
+// recordingPartitioner records the operations instead of modifying the
+// partition tables.
+type recordingPartitioner struct {
+	ops *[]string
+}
+
+func (p recordingPartitioner) record(format string, args ...interface{}) error {
+	*p.ops = append(*p.ops, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (p recordingPartitioner) create(disk, name string, startMiB, endMiB uint64) error {
+	return p.record("create %s %s %d %d", disk, name, startMiB, endMiB)
+}
+
+func (p recordingPartitioner) remove(disk string, partNum uint32) error {
+	return p.record("remove %s %d", disk, partNum)
+}
+
+func (p recordingPartitioner) setName(disk string, partNum uint32, name string) error {
+	return p.record("setName %s %d %s", disk, partNum, name)
+}
+
+func (p recordingPartitioner) setType(disk string, partNum uint32, partitionType string) error {
+	return p.record("setType %s %d %s", disk, partNum, partitionType)
+}
+
+func (p recordingPartitioner) verify(disk string) error {
+	return p.record("verify %s", disk)
+}
+
+func Test_runRelocationStepCopy(t *testing.T) {
+	partitioner := activePartitioner
+	defer func() { activePartitioner = partitioner }()
+
+	tests := []struct {
+		partitionType string
+		want          []string
+	}{
+		{partitionType: "", want: []string{"setName sdc 2 data-copied"}},
+		{partitionType: DefaultPartitionType, want: []string{"setName sdc 2 data-copied"}},
+		{partitionType: "8e00", want: []string{"setType sdc 2 8e00", "setName sdc 2 data-copied"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.partitionType, func(t *testing.T) {
+			var ops []string
+			activePartitioner = recordingPartitioner{ops: &ops}
+			vol := &apis.DeviceVolume{Spec: apis.VolumeInfo{PartitionType: tt.partitionType}}
+			parts := relocationParts{
+				source:     &PartUsed{DiskName: "sdb", PartNum: 3},
+				relocating: &PartUsed{DiskName: "sdc", PartNum: 2},
+			}
+			if err := runRelocationStep(vol, relocationCopy, parts, "data", "sdc"); err != nil {
+				t.Fatalf("runRelocationStep() unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(ops, tt.want) {
+				t.Errorf("runRelocationStep() ran %q, want %q", ops, tt.want)
+			}
+		})
+	}
+}
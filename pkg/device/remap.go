@@ -0,0 +1,75 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+)
+
+// ListDiskWWNs returns the world wide identifiers of the disks present on
+// the node, by the disk name. The disks not exposing it are left out.
+func ListDiskWWNs() (map[string]string, error) {
+	diskList, err := getDiskList()
+	if err != nil {
+		return nil, err
+	}
+	wwns := map[string]string{}
+	for _, disk := range diskList {
+		if wwn := getDiskWWN(disk.DiskName); wwn != "" {
+			wwns[disk.DiskName] = wwn
+		}
+	}
+	return wwns, nil
+}
+
+// IsDiskPartition checks if the device path is the device node of a
+// partition of the disk, as per sysfs.
+func IsDiskPartition(disk, devicePath string) bool {
+	name := filepath.Base(devicePath)
+	// the partitions of the multipath devices are device mapper devices
+	// holding the disk, instead of the partitions of the disk.
+	for _, path := range []string{filepath.Join(sysBlockPath(), disk, name),
+		filepath.Join(sysBlockPath(), disk, "holders", name)} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDiskPartitionPath returns the device path of the partition of the
+// disk having the given name.
+func GetDiskPartitionPath(disk, partitionName string) (string, error) {
+	tmpList, err := GetPartitionList(disk, "", false)
+	if err != nil {
+		return "", err
+	}
+	for _, tmp := range tmpList {
+		if tmp[len(tmp)-1] != partitionName {
+			continue
+		}
+		part, err := parsePartUsed(disk, tmp)
+		if err != nil {
+			return "", err
+		}
+		return part.DevicePath, nil
+	}
+	return "", errors.Errorf("partition %s not found on disk %s", partitionName, disk)
+}
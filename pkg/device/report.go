@@ -0,0 +1,264 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/builder/nodebuilder"
+	"github.com/openebs/device-localpv/pkg/builder/volbuilder"
+)
+
+// Formats of the capacity report.
+const (
+	ReportFormatCSV  = "csv"
+	ReportFormatJSON = "json"
+)
+
+// Kinds of the rows of the capacity report.
+const (
+	ReportKindDevice = "device"
+	ReportKindVolume = "volume"
+)
+
+// ReportRow is a row of the capacity report, either a device of a node or
+// a volume. The sizes are in bytes.
+type ReportRow struct {
+	Kind   string
+	Node   string
+	Device string
+	// UUID is the identifier of the disk of the device, or of the disk
+	// holding the partition of the volume.
+	UUID   string
+	Volume string
+	State  string
+	// Total, Used, Free and LargestRegion are set for the devices, Free
+	// being what is not used by the volumes, and LargestRegion the size
+	// of the largest volume that can be created on the device.
+	Total         int64
+	Used          int64
+	Free          int64
+	LargestRegion int64
+	// Size is set for the volumes.
+	Size int64
+}
+
+// reportColumn is a column of the capacity report, value returns nil when
+// the column doesn't apply to the kind of the row.
+type reportColumn struct {
+	name  string
+	value func(row ReportRow) interface{}
+}
+
+func deviceValue(value func(row ReportRow) int64) func(row ReportRow) interface{} {
+	return func(row ReportRow) interface{} {
+		if row.Kind != ReportKindDevice {
+			return nil
+		}
+		return value(row)
+	}
+}
+
+func volumeValue(value func(row ReportRow) interface{}) func(row ReportRow) interface{} {
+	return func(row ReportRow) interface{} {
+		if row.Kind != ReportKindVolume {
+			return nil
+		}
+		return value(row)
+	}
+}
+
+// reportColumns are the columns of the capacity report, in their default
+// order.
+var reportColumns = []reportColumn{
+	{"kind", func(row ReportRow) interface{} { return row.Kind }},
+	{"node", func(row ReportRow) interface{} { return row.Node }},
+	{"device", func(row ReportRow) interface{} { return row.Device }},
+	{"uuid", func(row ReportRow) interface{} { return row.UUID }},
+	{"total", deviceValue(func(row ReportRow) int64 { return row.Total })},
+	{"used", deviceValue(func(row ReportRow) int64 { return row.Used })},
+	{"free", deviceValue(func(row ReportRow) int64 { return row.Free })},
+	{"largestRegion", deviceValue(func(row ReportRow) int64 { return row.LargestRegion })},
+	{"volume", volumeValue(func(row ReportRow) interface{} { return row.Volume })},
+	{"state", volumeValue(func(row ReportRow) interface{} { return row.State })},
+	{"size", volumeValue(func(row ReportRow) interface{} { return row.Size })},
+}
+
+// ReportColumnNames returns the names of the columns of the capacity
+// report, in their default order.
+func ReportColumnNames() []string {
+	names := make([]string, len(reportColumns))
+	for i, col := range reportColumns {
+		names[i] = col.name
+	}
+	return names
+}
+
+// selectReportColumns returns the columns of the given names, all of them
+// if none is given.
+func selectReportColumns(names []string) ([]reportColumn, error) {
+	if len(names) == 0 {
+		return reportColumns, nil
+	}
+	var selected []reportColumn
+	for _, name := range names {
+		found := false
+		for _, col := range reportColumns {
+			if strings.EqualFold(col.name, strings.TrimSpace(name)) {
+				selected = append(selected, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown column %q, must be one of %s",
+				name, strings.Join(ReportColumnNames(), ", "))
+		}
+	}
+	return selected, nil
+}
+
+// BuildCapacityReport returns the devices of the nodes followed by the
+// volumes, each sorted by node and name.
+func BuildCapacityReport(nodes []apis.DeviceNode, vols []apis.DeviceVolume) []ReportRow {
+	var devices, volumes []ReportRow
+	for _, node := range nodes {
+		for _, dev := range node.Devices {
+			total, used := dev.Size.Value(), dev.Used.Value()
+			free := total - used
+			if free < 0 {
+				free = 0
+			}
+			devices = append(devices, ReportRow{
+				Kind:          ReportKindDevice,
+				Node:          node.Name,
+				Device:        dev.Name,
+				UUID:          dev.UUID,
+				Total:         total,
+				Used:          used,
+				Free:          free,
+				LargestRegion: dev.Free.Value(),
+			})
+		}
+	}
+	for _, vol := range vols {
+		capacity := vol.Status.Capacity
+		if capacity == "" {
+			capacity = vol.Spec.Capacity
+		}
+		size, _ := strconv.ParseInt(capacity, 10, 64)
+		volumes = append(volumes, ReportRow{
+			Kind:   ReportKindVolume,
+			Node:   vol.Spec.OwnerNodeID,
+			Device: vol.Spec.DevName,
+			UUID:   vol.Status.DiskUUID,
+			Volume: vol.Name,
+			State:  vol.Status.State,
+			Size:   size,
+		})
+	}
+	sort.SliceStable(devices, func(i, j int) bool {
+		if devices[i].Node != devices[j].Node {
+			return devices[i].Node < devices[j].Node
+		}
+		return devices[i].Device < devices[j].Device
+	})
+	sort.SliceStable(volumes, func(i, j int) bool {
+		if volumes[i].Node != volumes[j].Node {
+			return volumes[i].Node < volumes[j].Node
+		}
+		return volumes[i].Volume < volumes[j].Volume
+	})
+	return append(devices, volumes...)
+}
+
+// WriteCapacityReport writes the rows of the capacity report with the
+// given columns, all of them if none is given, as csv with a header or as
+// a json array of objects. The columns not applying to a row are empty in
+// csv and left out in json.
+func WriteCapacityReport(w io.Writer, format string, columns []string, rows []ReportRow) error {
+	selected, err := selectReportColumns(columns)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case ReportFormatCSV:
+		cw := csv.NewWriter(w)
+		header := make([]string, len(selected))
+		for i, col := range selected {
+			header[i] = col.name
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			record := make([]string, len(selected))
+			for i, col := range selected {
+				if v := col.value(row); v != nil {
+					record[i] = fmt.Sprint(v)
+				}
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case ReportFormatJSON:
+		objects := make([]map[string]interface{}, 0, len(rows))
+		for _, row := range rows {
+			obj := map[string]interface{}{}
+			for _, col := range selected {
+				if v := col.value(row); v != nil {
+					obj[col.name] = v
+				}
+			}
+			objects = append(objects, obj)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(objects)
+	}
+	return errors.Errorf("invalid report format %q, must be %s or %s", format, ReportFormatCSV, ReportFormatJSON)
+}
+
+// GetCapacityReport reads the DeviceNodes and the DeviceVolumes of the
+// install through the given kubeconfig, the in-cluster config if empty,
+// and builds the capacity report.
+func GetCapacityReport(kubeConfigPath string) ([]ReportRow, error) {
+	nodes, err := nodebuilder.NewKubeclient(nodebuilder.WithKubeConfigPath(kubeConfigPath)).
+		WithNamespace(DeviceNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the device nodes")
+	}
+	vols, err := volbuilder.NewKubeclient(volbuilder.WithKubeConfigPath(kubeConfigPath)).
+		WithNamespace(DeviceNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the volumes")
+	}
+	return BuildCapacityReport(nodes.Items, vols.Items), nil
+}
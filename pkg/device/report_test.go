@@ -0,0 +1,142 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"bytes"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestWriteCapacityReport(t *testing.T) {
+	newDevice := func(name, uuid string, size, used, free int64) apis.Device {
+		return apis.Device{
+			Name: name,
+			UUID: uuid,
+			Size: *resource.NewQuantity(size, resource.BinarySI),
+			Used: *resource.NewQuantity(used, resource.BinarySI),
+			Free: *resource.NewQuantity(free, resource.BinarySI),
+		}
+	}
+	nodes := []apis.DeviceNode{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-b"},
+			Devices:    []apis.Device{newDevice("ssd", "uuid-3", 1000, 0, 900)},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+			Devices: []apis.Device{newDevice("ssd", "uuid-2", 1000, 600, 300),
+				newDevice("hdd", "uuid-1", 2000, 500, 1400)},
+		},
+	}
+	vols := []apis.DeviceVolume{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-2"},
+			Spec:       apis.VolumeInfo{OwnerNodeID: "node-a", DevName: "ssd", Capacity: "500"},
+			Status:     apis.VolStatus{State: "Ready", Capacity: "600", DiskUUID: "uuid-2"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+			Spec:       apis.VolumeInfo{OwnerNodeID: "node-a", DevName: "hdd", Capacity: "500"},
+			Status:     apis.VolStatus{State: "Pending"},
+		},
+	}
+	rows := BuildCapacityReport(nodes, vols)
+
+	tests := []struct {
+		name    string
+		format  string
+		columns []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "csv",
+			format: ReportFormatCSV,
+			want: `kind,node,device,uuid,total,used,free,largestRegion,volume,state,size
+device,node-a,hdd,uuid-1,2000,500,1500,1400,,,
+device,node-a,ssd,uuid-2,1000,600,400,300,,,
+device,node-b,ssd,uuid-3,1000,0,1000,900,,,
+volume,node-a,hdd,,,,,,pvc-1,Pending,500
+volume,node-a,ssd,uuid-2,,,,,pvc-2,Ready,600
+`,
+		},
+		{
+			name:    "csv columns",
+			format:  ReportFormatCSV,
+			columns: []string{"node", "volume", "free", "size"},
+			want: `node,volume,free,size
+node-a,,1500,
+node-a,,400,
+node-b,,1000,
+node-a,pvc-1,,500
+node-a,pvc-2,,600
+`,
+		},
+		{
+			name:    "json columns",
+			format:  ReportFormatJSON,
+			columns: []string{"kind", "Node", "largestRegion", "size"},
+			want: `[
+  {
+    "kind": "device",
+    "largestRegion": 1400,
+    "node": "node-a"
+  },
+  {
+    "kind": "device",
+    "largestRegion": 300,
+    "node": "node-a"
+  },
+  {
+    "kind": "device",
+    "largestRegion": 900,
+    "node": "node-b"
+  },
+  {
+    "kind": "volume",
+    "node": "node-a",
+    "size": 500
+  },
+  {
+    "kind": "volume",
+    "node": "node-a",
+    "size": 600
+  }
+]
+`,
+		},
+		{name: "unknown column", format: ReportFormatCSV, columns: []string{"node", "wwn"}, wantErr: true},
+		{name: "unknown format", format: "yaml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteCapacityReport(&buf, tt.format, tt.columns, rows)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteCapacityReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && buf.String() != tt.want {
+				t.Errorf("WriteCapacityReport() got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+// Foreign signature probe commands
+const (
+	DiskSignatureType  = "blkid -p -o value -s TYPE %s"
+	LVMPhysicalVolumes = "pvs --noheadings -o pv_name"
+	MDRaidMembers      = "mdadm --examine --scan --verbose"
+)
+
+// Foreign signatures found on the disks
+const (
+	// SignatureLVM denotes the disk or one of its partitions is an LVM
+	// physical volume.
+	SignatureLVM = "lvm"
+	// SignatureMDRaid denotes the disk or one of its partitions is a
+	// member of an mdraid array.
+	SignatureMDRaid = "mdraid"
+)
+
+// blkid types of the foreign signatures
+var signatureTypes = map[string]string{
+	"LVM2_member":       SignatureLVM,
+	"linux_raid_member": SignatureMDRaid,
+}
+
+// allowForeignSignatures lets the disks carrying the LVM or mdraid
+// signatures be used.
+var allowForeignSignatures bool
+
+// SetAllowForeignSignatures sets whether the disks carrying the LVM or
+// mdraid signatures are used, for the nodes where they are reused on
+// purpose. They are left out by default, as using them destroys the data
+// of the volume groups and the arrays.
+func SetAllowForeignSignatures(allow bool) {
+	allowForeignSignatures = allow
+}
+
+// ForeignSignature is an LVM or mdraid signature found on a disk.
+type ForeignSignature struct {
+	// Kind is either SignatureLVM or SignatureMDRaid.
+	Kind string
+	// DevicePath is the disk or the partition carrying the signature.
+	DevicePath string
+}
+
+func (s ForeignSignature) String() string {
+	return fmt.Sprintf("%s signature on %s", s.Kind, s.DevicePath)
+}
+
+// signatureProbe finds the foreign signatures on the disks. The physical
+// volumes and the raid members are listed once for all the disks.
+type signatureProbe struct {
+	pvs     []string
+	members []string
+	// diskType returns the blkid type of the whole disk.
+	diskType func(disk string) string
+	// isPartition checks if the device path is a partition of the disk.
+	isPartition func(disk, devicePath string) bool
+}
+
+// newSignatureProbe lists the physical volumes and the raid members of the
+// node. The nodes without the lvm or mdadm tools have none of them, so
+// the failures of the commands are only logged.
+func newSignatureProbe() *signatureProbe {
+	p := &signatureProbe{diskType: getDiskSignatureType, isPartition: IsDiskPartition}
+	if out, err := RunCommand(strings.Split(LVMPhysicalVolumes, " ")); err == nil {
+		p.pvs = parsePhysicalVolumes(out)
+	} else {
+		klog.V(4).Infof("Device LocalPV: could not list LVM physical volumes: %v", err)
+	}
+	if out, err := RunCommand(strings.Split(MDRaidMembers, " ")); err == nil {
+		p.members = parseMDRaidMembers(out)
+	} else {
+		klog.V(4).Infof("Device LocalPV: could not list mdraid members: %v", err)
+	}
+	return p
+}
+
+// find returns the foreign signature on the disk or one of its
+// partitions, if any.
+func (p *signatureProbe) find(disk string) (ForeignSignature, bool) {
+	if kind, ok := signatureTypes[p.diskType(disk)]; ok {
+		return ForeignSignature{Kind: kind, DevicePath: devicePath(disk)}, true
+	}
+	for _, sig := range []struct {
+		kind  string
+		paths []string
+	}{{SignatureLVM, p.pvs}, {SignatureMDRaid, p.members}} {
+		for _, path := range sig.paths {
+			if filepath.Base(path) == disk || p.isPartition(disk, path) {
+				return ForeignSignature{Kind: sig.kind, DevicePath: path}, true
+			}
+		}
+	}
+	return ForeignSignature{}, false
+}
+
+// getDiskSignatureType returns the blkid type of the signature on the whole
+// disk, empty if there is none or it could not be probed.
+func getDiskSignatureType(disk string) string {
+	path := devicePath(disk)
+	out, code, err := runCommand(context.Background(), strings.Split(fmt.Sprintf(DiskSignatureType, path), " "), nil, limits.timeout)
+	if code == blkidNotFoundRet {
+		return ""
+	}
+	if err != nil {
+		klog.Warningf("Device LocalPV: could not probe signatures of %s: %v", path, err)
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// parsePhysicalVolumes returns the device paths of the physical volumes
+// listed by pvs, skipping the warnings it prints.
+func parsePhysicalVolumes(out string) []string {
+	var pvs []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "/dev/") {
+			pvs = append(pvs, line)
+		}
+	}
+	return pvs
+}
+
+// parseMDRaidMembers returns the device paths of the raid members listed
+// by mdadm --examine --scan --verbose. for example:
+//
+//	ARRAY /dev/md/0  level=raid1 metadata=1.2 num-devices=2 UUID=8f1c2a3b:... name=node-1:0
+//	   devices=/dev/sdb1,/dev/sdc1
+func parseMDRaidMembers(out string) []string {
+	var members []string
+	for _, field := range strings.Fields(out) {
+		if !strings.HasPrefix(field, "devices=") {
+			continue
+		}
+		for _, path := range strings.Split(strings.TrimPrefix(field, "devices="), ",") {
+			if path != "" {
+				members = append(members, path)
+			}
+		}
+	}
+	return members
+}
+
+// signedDevices holds the foreign signatures of the disks left out by the
+// last discovery, by the UUIDs of the disks.
+var signedDevices = struct {
+	sync.RWMutex
+	uuids map[string]ForeignSignature
+}{}
+
+func setSignedDevices(signed map[string]ForeignSignature) {
+	signedDevices.Lock()
+	defer signedDevices.Unlock()
+	signedDevices.uuids = signed
+}
+
+// ListSignedDevices returns the UUIDs of the disks left out by the last
+// discovery for carrying a foreign signature. They are still present on
+// the node, along with the volumes on them.
+func ListSignedDevices() []string {
+	signedDevices.RLock()
+	defer signedDevices.RUnlock()
+	uuids := make([]string, 0, len(signedDevices.uuids))
+	for uuid := range signedDevices.uuids {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	return uuids
+}
@@ -0,0 +1,82 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parsePhysicalVolumes(t *testing.T) {
+	out := `  WARNING: Device /dev/sdf has size of 0 sectors which is smaller than corresponding PV size.
+  /dev/sdb1
+  /dev/sdd
+`
+	want := []string{"/dev/sdb1", "/dev/sdd"}
+	if got := parsePhysicalVolumes(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePhysicalVolumes() = %v, want %v", got, want)
+	}
+	if got := parsePhysicalVolumes(""); got != nil {
+		t.Errorf("parsePhysicalVolumes() = %v, want none", got)
+	}
+}
+
+func Test_parseMDRaidMembers(t *testing.T) {
+	out := `ARRAY /dev/md/0  level=raid1 metadata=1.2 num-devices=2 UUID=8f1c2a3b:5d6e7f80:91a2b3c4:d5e6f708 name=node-1:0
+   devices=/dev/sdb2,/dev/sdc2
+ARRAY /dev/md/1  level=raid0 metadata=1.2 num-devices=1 UUID=0a1b2c3d:4e5f6071:8293a4b5:c6d7e8f9 name=node-1:1
+   devices=/dev/sde
+`
+	want := []string{"/dev/sdb2", "/dev/sdc2", "/dev/sde"}
+	if got := parseMDRaidMembers(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMDRaidMembers() = %v, want %v", got, want)
+	}
+}
+
+func Test_signatureProbeFind(t *testing.T) {
+	probe := &signatureProbe{
+		pvs:     parsePhysicalVolumes("  /dev/sdb1\n  /dev/sdd\n"),
+		members: parseMDRaidMembers("ARRAY /dev/md/0 metadata=1.2\n   devices=/dev/sdc2,/dev/sdg2\n"),
+		diskType: func(disk string) string {
+			return map[string]string{"sde": "linux_raid_member", "sdf": "LVM2_member"}[disk]
+		},
+		isPartition: func(disk, devicePath string) bool {
+			return strings.HasPrefix(devicePath, "/dev/"+disk) && devicePath != "/dev/"+disk
+		},
+	}
+	tests := []struct {
+		disk string
+		want ForeignSignature
+		ok   bool
+	}{
+		{disk: "sda", ok: false},
+		{disk: "sdb", want: ForeignSignature{Kind: SignatureLVM, DevicePath: "/dev/sdb1"}, ok: true},
+		{disk: "sdc", want: ForeignSignature{Kind: SignatureMDRaid, DevicePath: "/dev/sdc2"}, ok: true},
+		{disk: "sdd", want: ForeignSignature{Kind: SignatureLVM, DevicePath: "/dev/sdd"}, ok: true},
+		{disk: "sde", want: ForeignSignature{Kind: SignatureMDRaid, DevicePath: devicePath("sde")}, ok: true},
+		{disk: "sdf", want: ForeignSignature{Kind: SignatureLVM, DevicePath: devicePath("sdf")}, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.disk, func(t *testing.T) {
+			got, ok := probe.find(tt.disk)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("find() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// slotBudget holds the GPT partition entries taken by the volumes of a
+// class on the disks of the node, so that a class creating many small
+// volumes can't use up the partition tables of the disks it shares with
+// the other classes.
+type slotBudget struct {
+	class string
+	limit int
+	// slots are the entries taken by the volume being created.
+	slots int
+	// used are the entries taken by the other volumes of the class, by
+	// the identifiers of their disks.
+	used map[string]int
+	// exhausted are the disks left out for the class having used up its
+	// budget on them.
+	exhausted []string
+}
+
+// VolumeSlots returns the number of GPT partition entries the volume
+// takes on its disk, its partition and the one of its growth reserve.
+func VolumeSlots(vol *apis.DeviceVolume) int {
+	reserve, _ := strconv.ParseUint(vol.Spec.GrowthReserve, 10, 64)
+	if reserve > 0 {
+		return 2
+	}
+	return 1
+}
+
+// getSlotBudget returns the GPT partition entries taken by the class of
+// the volume, nil if the volume has no slot budget.
+func getSlotBudget(vol *apis.DeviceVolume) (*slotBudget, error) {
+	if vol.Spec.SlotBudget == "" {
+		return nil, nil
+	}
+	volumes, err := ListDeviceVolumes()
+	if err != nil {
+		return nil, errors.Wrapf(err, "list volumes of slot class %s", vol.Spec.SlotClass)
+	}
+	return newSlotBudget(vol, volumes.Items)
+}
+
+// newSlotBudget counts the GPT partition entries taken by the other
+// volumes of the class of the volume on each disk of its node. The volumes
+// which have no partition yet are left out, the ones being deleted are
+// counted till their partition is gone.
+func newSlotBudget(vol *apis.DeviceVolume, volumes []apis.DeviceVolume) (*slotBudget, error) {
+	limit, err := strconv.Atoi(vol.Spec.SlotBudget)
+	if err != nil || limit < 1 {
+		return nil, errors.Errorf("invalid slot budget %q of volume %s", vol.Spec.SlotBudget, vol.Name)
+	}
+	b := &slotBudget{
+		class: vol.Spec.SlotClass,
+		limit: limit,
+		slots: VolumeSlots(vol),
+		used:  map[string]int{},
+	}
+	for i := range volumes {
+		peer := &volumes[i]
+		if peer.Name == vol.Name ||
+			peer.Spec.SlotClass != b.class ||
+			peer.Spec.OwnerNodeID != vol.Spec.OwnerNodeID ||
+			peer.Status.DiskUUID == "" {
+			continue
+		}
+		b.used[peer.Status.DiskUUID] += VolumeSlots(peer)
+	}
+	return b, nil
+}
+
+// filter leaves out the free regions on the disks where the class can't
+// take the entries of the volume, recording them as rejected in disks.
+// diskID returns the identifier of a disk.
+func (b *slotBudget) filter(pList []partFree, disks map[string]string, diskID func(string) string) []partFree {
+	if b == nil {
+		return pList
+	}
+	b.exhausted = nil
+	for disk, rejected := range disks {
+		if rejected == "" && b.used[diskID(disk)]+b.slots > b.limit {
+			disks[disk] = TraceRejectedSlotBudget
+			b.exhausted = append(b.exhausted, disk)
+		}
+	}
+	if len(b.exhausted) == 0 {
+		return pList
+	}
+	sort.Strings(b.exhausted)
+
+	var kept []partFree
+	for _, region := range pList {
+		if disks[region.DiskName] != TraceRejectedSlotBudget {
+			kept = append(kept, region)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,131 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"testing"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func Test_newSlotBudget(t *testing.T) {
+	volume := func(name, class, node, disk, reserve string) apis.DeviceVolume {
+		vol := apis.DeviceVolume{}
+		vol.Name = name
+		vol.Spec.SlotClass = class
+		vol.Spec.SlotBudget = "4"
+		vol.Spec.OwnerNodeID = node
+		vol.Spec.GrowthReserve = reserve
+		vol.Status.DiskUUID = disk
+		return vol
+	}
+
+	vol := volume("pvc-new", "tiny", "node-1", "", "")
+	budget, err := newSlotBudget(&vol, []apis.DeviceVolume{
+		volume("pvc-new", "tiny", "node-1", "uuid-0", ""),
+		volume("pvc-1", "tiny", "node-1", "uuid-1", ""),
+		volume("pvc-2", "tiny", "node-1", "uuid-1", "1048576"),
+		volume("pvc-3", "large", "node-1", "uuid-1", ""),
+		volume("pvc-4", "tiny", "node-2", "uuid-2", ""),
+		volume("pvc-pending", "tiny", "node-1", "", ""),
+		volume("pvc-5", "tiny", "node-1", "uuid-5", "0"),
+	})
+	if err != nil {
+		t.Fatalf("newSlotBudget() error = %v", err)
+	}
+	want := map[string]int{"uuid-1": 3, "uuid-5": 1}
+	if !reflect.DeepEqual(budget.used, want) {
+		t.Errorf("newSlotBudget() used = %v, want %v", budget.used, want)
+	}
+	if budget.limit != 4 || budget.slots != 1 {
+		t.Errorf("newSlotBudget() limit = %d, slots = %d, want 4 and 1", budget.limit, budget.slots)
+	}
+
+	vol.Spec.SlotBudget = "0"
+	if _, err = newSlotBudget(&vol, nil); err == nil {
+		t.Errorf("newSlotBudget() expected an error for a budget of 0")
+	}
+}
+
+func Test_slotBudgetFilter(t *testing.T) {
+	pList := []partFree{
+		{"sdb", 2, 1002, 1000},
+		{"sdc", 2, 502, 500},
+		{"sdd", 2, 2002, 2000},
+	}
+	ids := map[string]string{"sdb": "uuid-b", "sdc": "uuid-c", "sdd": "uuid-d"}
+	diskID := func(disk string) string { return ids[disk] }
+
+	tests := []struct {
+		name      string
+		budget    *slotBudget
+		disks     []string
+		exhausted []string
+	}{
+		{
+			name:   "no slot budget",
+			budget: nil,
+			disks:  []string{"sdb", "sdc", "sdd"},
+		},
+		{
+			name:   "room left on all the disks",
+			budget: &slotBudget{class: "tiny", limit: 4, slots: 1, used: map[string]int{"uuid-b": 3}},
+			disks:  []string{"sdb", "sdc", "sdd"},
+		},
+		{
+			name:   "budget used up on a disk",
+			budget: &slotBudget{class: "tiny", limit: 4, slots: 1, used: map[string]int{"uuid-b": 4, "uuid-c": 2}},
+			disks:  []string{"sdc", "sdd"}, exhausted: []string{"sdb"},
+		},
+		{
+			name:   "growth reserve doesn't fit the budget",
+			budget: &slotBudget{class: "tiny", limit: 4, slots: 2, used: map[string]int{"uuid-b": 3, "uuid-d": 2}},
+			disks:  []string{"sdc", "sdd"}, exhausted: []string{"sdb"},
+		},
+		{
+			name:      "budget used up everywhere",
+			budget:    &slotBudget{class: "tiny", limit: 1, slots: 1, used: map[string]int{"uuid-b": 1, "uuid-c": 1, "uuid-d": 1}},
+			exhausted: []string{"sdb", "sdc", "sdd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disks := map[string]string{"sdb": "", "sdc": "", "sdd": "", "sde": TraceRejectedDevName}
+			got := tt.budget.filter(pList, disks, diskID)
+			var gotDisks []string
+			for _, region := range got {
+				gotDisks = append(gotDisks, region.DiskName)
+			}
+			if !reflect.DeepEqual(gotDisks, tt.disks) {
+				t.Errorf("filter() kept %v, want %v", gotDisks, tt.disks)
+			}
+			var rejected []string
+			for _, disk := range []string{"sdb", "sdc", "sdd", "sde"} {
+				if disks[disk] == TraceRejectedSlotBudget {
+					rejected = append(rejected, disk)
+				}
+			}
+			if !reflect.DeepEqual(rejected, tt.exhausted) {
+				t.Errorf("filter() rejected %v, want %v", rejected, tt.exhausted)
+			}
+			if tt.budget != nil && !reflect.DeepEqual(tt.budget.exhausted, tt.exhausted) {
+				t.Errorf("filter() exhausted %v, want %v", tt.budget.exhausted, tt.exhausted)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"k8s.io/utils/mount"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// StaleMount is a mount of a partition of the driver which doesn't
+// belong to a live volume or pod anymore, e.g. left behind by a crash.
+type StaleMount struct {
+	Volume    string `json:"volume"`
+	MountPath string `json:"mountPath"`
+	Reason    string `json:"reason"`
+}
+
+// deviceNumber is the major:minor number of a block device
+type deviceNumber struct {
+	major, minor int
+}
+
+// managedDevices maps the partitions, and the RAID0 arrays, of the
+// driver to the volumes they belong to, by their device number and by the
+// name of their device node.
+type managedDevices struct {
+	numbers map[deviceNumber]string
+	nodes   map[string]string
+}
+
+// FindStaleMounts lists the mounts of the partitions of the driver whose
+// volume is gone or being deleted, and the publish targets under
+// kubeletDir of the pods which are not on the node anymore. The pods are
+// not checked if they could not be listed.
+func FindStaleMounts(kubeletDir string) ([]StaleMount, error) {
+	if NodeID == "" {
+		return nil, errors.New("node id is not set, the stale mounts have to be found from the node agent")
+	}
+	parts, err := ListPartUsed()
+	if err != nil {
+		return nil, err
+	}
+	vols, err := ListDeviceVolumes()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the volumes")
+	}
+	mounts, err := mount.ParseMountInfo(mountInfoPath())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the mounts")
+	}
+	pods, err := getNodePods()
+	if err != nil {
+		klog.Warningf("failed to list the pods of node %s, the mounts of gone pods are not detected: %v", NodeID, err)
+		pods = nil
+	}
+
+	devices := managedDevices{numbers: map[deviceNumber]string{}, nodes: map[string]string{}}
+	addDevice := func(path, volName string) {
+		major, minor, nodeName, err := getDeviceNumber(path)
+		if err != nil {
+			klog.V(4).Infof("skipping device %s of volume %s: %v", path, volName, err)
+			return
+		}
+		devices.numbers[deviceNumber{major, minor}] = volName
+		devices.nodes[nodeName] = volName
+	}
+	for _, p := range parts {
+		if name := getPartitionVolume(p.Name); name != "" && !isReservePart(p.Name) {
+			addDevice(p.DevicePath, name)
+		}
+	}
+
+	nodeVols := map[string]*apis.DeviceVolume{}
+	for i := range vols.Items {
+		vol := &vols.Items[i]
+		if vol.Spec.OwnerNodeID != NodeID {
+			continue
+		}
+		nodeVols[vol.Name] = vol
+		if IsStripedVolume(vol) {
+			addDevice(getStripeDevicePath(vol.Name[4:]), vol.Name)
+		}
+	}
+	return findStaleMounts(mounts, devices, nodeVols, pods, kubeletDir), nil
+}
+
+// findStaleMounts returns the mounts of the managed devices whose volume
+// is not among the volumes of the node or is being deleted, and the ones
+// published to pods which are not among the pods of the node, unless pods
+// is nil.
+func findStaleMounts(mounts []mount.MountInfo, devices managedDevices,
+	vols map[string]*apis.DeviceVolume, pods map[string]string, kubeletDir string) []StaleMount {
+	var stale []StaleMount
+	for _, mi := range mounts {
+		volName, ok := devices.numbers[deviceNumber{mi.Major, mi.Minor}]
+		if !ok && mi.FsType == "devtmpfs" && len(mi.Root) > 1 {
+			// the bind mounts of the device nodes of the block volumes
+			volName, ok = devices.nodes[mi.Root[1:]]
+		}
+		if !ok {
+			continue
+		}
+
+		var reason string
+		vol, found := vols[volName]
+		uid, published := parsePodUID(kubeletDir, mi.MountPoint)
+		switch {
+		case !found:
+			reason = fmt.Sprintf("volume %s does not exist on the node", volName)
+		case vol.DeletionTimestamp != nil:
+			reason = fmt.Sprintf("volume %s is being deleted", volName)
+		case published && pods != nil && pods[uid] == "":
+			reason = fmt.Sprintf("pod %s is not on the node", uid)
+		default:
+			continue
+		}
+		stale = append(stale, StaleMount{Volume: volName, MountPath: mi.MountPoint, Reason: reason})
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].MountPath < stale[j].MountPath
+	})
+	return stale
+}
+
+// CleanupStaleMount unmounts the stale mount and removes its mount point,
+// the way the volumes get unpublished.
+func CleanupStaleMount(m StaleMount) error {
+	vol := &apis.DeviceVolume{ObjectMeta: metav1.ObjectMeta{Name: m.Volume}}
+	if err := UmountVolume(vol, m.MountPath); err != nil {
+		return errors.Wrapf(err, "could not clean up the stale mount %s of volume %s", m.MountPath, m.Volume)
+	}
+	klog.Infof("cleaned up the stale mount %s of volume %s: %s", m.MountPath, m.Volume, m.Reason)
+	return nil
+}
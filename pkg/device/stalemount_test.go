@@ -0,0 +1,75 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/mount"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func Test_findStaleMounts(t *testing.T) {
+	kubeletDir := "/var/lib/kubelet"
+	podMount := func(uid, vol string) string {
+		return kubeletDir + "/pods/" + uid + "/volumes/kubernetes.io~csi/" + vol + "/mount"
+	}
+	now := metav1.Now()
+
+	devices := managedDevices{
+		numbers: map[deviceNumber]string{{8, 18}: "pvc-live", {8, 19}: "pvc-gone", {8, 20}: "pvc-deleting"},
+		nodes:   map[string]string{"sdb2": "pvc-live", "sdb3": "pvc-gone", "sdb4": "pvc-deleting"},
+	}
+	vols := map[string]*apis.DeviceVolume{
+		"pvc-live":     {},
+		"pvc-deleting": {ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now}},
+	}
+	mounts := []mount.MountInfo{
+		{Major: 0, Minor: 5, Root: "/", FsType: "devtmpfs", MountPoint: "/dev"},
+		{Major: 8, Minor: 1, Root: "/", FsType: "ext4", MountPoint: "/"},
+		{Major: 8, Minor: 18, Root: "/", FsType: "ext4", MountPoint: podMount("uid-1", "pvc-live")},
+		{Major: 8, Minor: 18, Root: "/", FsType: "ext4", MountPoint: podMount("uid-gone", "pvc-live")},
+		{Major: 8, Minor: 18, Root: "/", FsType: "ext4", MountPoint: "/mnt/by-hand"},
+		{Major: 8, Minor: 19, Root: "/", FsType: "xfs", MountPoint: podMount("uid-2", "pvc-gone")},
+		{Major: 8, Minor: 20, Root: "/", FsType: "ext4", MountPoint: podMount("uid-3", "pvc-deleting")},
+		{Major: 0, Minor: 5, Root: "/sdb2", FsType: "devtmpfs", MountPoint: kubeletDir + "/pods/uid-1/volumeDevices/kubernetes.io~csi/pvc-live"},
+		{Major: 0, Minor: 5, Root: "/sdb3", FsType: "devtmpfs", MountPoint: kubeletDir + "/pods/uid-2/volumeDevices/kubernetes.io~csi/pvc-gone"},
+		{Major: 8, Minor: 33, Root: "/", FsType: "ext4", MountPoint: podMount("uid-4", "pvc-other-driver")},
+	}
+	pods := map[string]string{"uid-1": "default/app-1", "uid-2": "default/app-2", "uid-3": "default/app-3", "uid-4": "default/app-4"}
+
+	want := []StaleMount{
+		{Volume: "pvc-gone", MountPath: kubeletDir + "/pods/uid-2/volumeDevices/kubernetes.io~csi/pvc-gone", Reason: "volume pvc-gone does not exist on the node"},
+		{Volume: "pvc-gone", MountPath: podMount("uid-2", "pvc-gone"), Reason: "volume pvc-gone does not exist on the node"},
+		{Volume: "pvc-deleting", MountPath: podMount("uid-3", "pvc-deleting"), Reason: "volume pvc-deleting is being deleted"},
+		{Volume: "pvc-live", MountPath: podMount("uid-gone", "pvc-live"), Reason: "pod uid-gone is not on the node"},
+	}
+	got := findStaleMounts(mounts, devices, vols, pods, kubeletDir)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findStaleMounts() = %+v, want %+v", got, want)
+	}
+
+	// the mounts of the gone pods are not reported if the pods could not
+	// be listed.
+	got = findStaleMounts(mounts, devices, vols, nil, kubeletDir)
+	if len(got) != 3 {
+		t.Errorf("findStaleMounts() without pods = %+v, want the mounts of the gone volumes only", got)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// Values of the standby field of the volumes
+const (
+	StandbyEnabled  = "true"
+	StandbyDisabled = "false"
+)
+
+// IsStandbyVolume checks whether the volume is a warm standby reservation
+// which is not activated yet.
+func IsStandbyVolume(vol *apis.DeviceVolume) bool {
+	return vol.Spec.Standby == StandbyEnabled
+}
+
+// IsVolumeCreated checks whether the partition of the volume has been
+// created, i.e. the volume is ready or reserved as a standby.
+func IsVolumeCreated(vol *apis.DeviceVolume) bool {
+	return vol.Status.State == DeviceStatusReady ||
+		vol.Status.State == DeviceStatusReserved
+}
+
+// getCreatedState returns the state of the volume once its partition got
+// created. The standby volumes are held reserved till their activation.
+func getCreatedState(vol *apis.DeviceVolume) string {
+	if IsStandbyVolume(vol) {
+		return DeviceStatusReserved
+	}
+	return DeviceStatusReady
+}
+
+// IsActivationPending checks whether the volume is reserved and got
+// activated, so the node agent has to mark it ready.
+func IsActivationPending(vol *apis.DeviceVolume) bool {
+	return vol.Status.State == DeviceStatusReserved && !IsStandbyVolume(vol)
+}
+
+// CheckVolumeActive fails if the volume is a standby reservation, so that
+// it is not mounted till it gets activated. kubelet keeps retrying the
+// mount, which goes through once the volume is ready.
+func CheckVolumeActive(vol *apis.DeviceVolume) error {
+	if vol.Status.State == DeviceStatusReserved {
+		return status.Errorf(codes.FailedPrecondition,
+			"volume %s is a standby reservation, it has to be activated before use", vol.Name)
+	}
+	return nil
+}
+
+// ActivateStandbyVolume clears the standby flag of the volume, after which
+// the node agent marks it ready for use.
+func ActivateStandbyVolume(volName string) error {
+	vol, err := GetDeviceVolume(volName)
+	if err != nil {
+		return err
+	}
+	if !IsStandbyVolume(vol) {
+		return errors.Errorf("volume %s is not a standby volume", volName)
+	}
+	vol.Spec.Standby = StandbyDisabled
+	if err = UpdateVolume(vol); err != nil {
+		return errors.Wrapf(err, "could not activate volume %s", volName)
+	}
+	klog.Infof("requested the activation of standby volume %s", volName)
+	return nil
+}
+
+// MarkVolumeActivated marks the reserved volume, which got activated,
+// ready for use. The partition is already there, so there is nothing else
+// to do.
+func MarkVolumeActivated(vol *apis.DeviceVolume) error {
+	vol.Status.State = DeviceStatusReady
+	if err := UpdateVolume(vol); err != nil {
+		return err
+	}
+	klog.Infof("activated standby volume %s", vol.Name)
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestStandbyVolumeLifecycle(t *testing.T) {
+	vol := &apis.DeviceVolume{
+		Spec:   apis.VolumeInfo{Standby: StandbyEnabled},
+		Status: apis.VolStatus{State: DeviceStatusPending},
+	}
+	vol.Name = "pvc-standby"
+
+	// the partition got created, the volume is held reserved.
+	vol.Status.State = getCreatedState(vol)
+	if vol.Status.State != DeviceStatusReserved {
+		t.Fatalf("state = %s, want %s", vol.Status.State, DeviceStatusReserved)
+	}
+	if !IsVolumeCreated(vol) {
+		t.Errorf("reserved volume must count as created")
+	}
+	if IsActivationPending(vol) {
+		t.Errorf("reserved volume must not be activated before the standby flag is cleared")
+	}
+	if err := CheckVolumeActive(vol); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("CheckVolumeActive() = %v, want FailedPrecondition", err)
+	}
+
+	// activation clears the standby flag, then the node agent marks the
+	// volume ready.
+	vol.Spec.Standby = StandbyDisabled
+	if !IsActivationPending(vol) {
+		t.Fatalf("activated volume must be pending to be marked ready")
+	}
+	vol.Status.State = DeviceStatusReady
+	if IsActivationPending(vol) {
+		t.Errorf("ready volume must not be pending activation")
+	}
+	if err := CheckVolumeActive(vol); err != nil {
+		t.Errorf("CheckVolumeActive() unexpected error %v", err)
+	}
+}
+
+func Test_getCreatedState(t *testing.T) {
+	tests := []struct {
+		name    string
+		standby string
+		want    string
+	}{
+		{name: "regular volume", standby: "", want: DeviceStatusReady},
+		{name: "standby volume", standby: StandbyEnabled, want: DeviceStatusReserved},
+		{name: "activated volume", standby: StandbyDisabled, want: DeviceStatusReady},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vol := &apis.DeviceVolume{Spec: apis.VolumeInfo{Standby: tt.standby}}
+			if got := getCreatedState(vol); got != tt.want {
+				t.Errorf("getCreatedState() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
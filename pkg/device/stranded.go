@@ -0,0 +1,82 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"github.com/openebs/lib-csi/pkg/common/errors"
+)
+
+// minFreeRegionMiB is the size below which the free regions are treated
+// as unusable. Zero treats every free region as usable.
+var minFreeRegionMiB uint64
+
+// SetMinFreeRegion sets the size in MiB below which the free regions left
+// between the partitions are treated as unusable, so that they count
+// neither as free capacity nor as the largest free region of the disks.
+func SetMinFreeRegion(sizeMiB int) error {
+	if sizeMiB < 0 {
+		return errors.Errorf("invalid minimum free region size %d MiB", sizeMiB)
+	}
+	minFreeRegionMiB = uint64(sizeMiB)
+	return nil
+}
+
+// usableRegions returns the free regions large enough to be used.
+func usableRegions(pList []partFree) []partFree {
+	if minFreeRegionMiB == 0 {
+		return pList
+	}
+	var usable []partFree
+	for _, p := range pList {
+		if p.SizeMiB >= minFreeRegionMiB {
+			usable = append(usable, p)
+		}
+	}
+	return usable
+}
+
+// strandedMiB returns the total size in MiB of the free regions too small
+// to be used.
+func strandedMiB(pList []partFree) uint64 {
+	var stranded uint64
+	for _, p := range pList {
+		if p.SizeMiB < minFreeRegionMiB {
+			stranded += p.SizeMiB
+		}
+	}
+	return stranded
+}
+
+// AllocationEfficiency returns the used capacity of a device as a fraction
+// of its used and stranded capacity, in bytes. It is 1 for a device with
+// nothing stranded, a device losing more to fragmentation scores lower.
+func AllocationEfficiency(usedBytes, strandedBytes int64) float64 {
+	if strandedBytes <= 0 {
+		return 1
+	}
+	if usedBytes < 0 {
+		usedBytes = 0
+	}
+	return float64(usedBytes) / float64(usedBytes+strandedBytes)
+}
+
+// efficiencyPercent returns the allocation efficiency of a device in whole
+// percent, rounded down so that a device with anything stranded is never
+// reported as fully efficient.
+func efficiencyPercent(usedBytes, strandedBytes int64) int32 {
+	return int32(AllocationEfficiency(usedBytes, strandedBytes) * 100)
+}
@@ -0,0 +1,128 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_usableRegions(t *testing.T) {
+	pList := []partFree{
+		{DiskName: "sdb", StartMiB: 1, EndMiB: 4, SizeMiB: 3},
+		{DiskName: "sdb", StartMiB: 100, EndMiB: 108, SizeMiB: 8},
+		{DiskName: "sdb", StartMiB: 200, EndMiB: 1200, SizeMiB: 1000},
+		{DiskName: "sdc", StartMiB: 50, EndMiB: 55, SizeMiB: 5},
+	}
+	tests := []struct {
+		name         string
+		minMiB       int
+		wantStarts   []uint64
+		wantLargest  uint64
+		wantStranded uint64
+	}{
+		{name: "every region usable", minMiB: 0, wantStarts: []uint64{1, 100, 200, 50}, wantLargest: 1000, wantStranded: 0},
+		{name: "slivers left out", minMiB: 8, wantStarts: []uint64{100, 200}, wantLargest: 1000, wantStranded: 8},
+		{name: "only the large region", minMiB: 9, wantStarts: []uint64{200}, wantLargest: 1000, wantStranded: 16},
+		{name: "nothing usable", minMiB: 2000, wantStarts: nil, wantLargest: 0, wantStranded: 1016},
+	}
+	defer SetMinFreeRegion(0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetMinFreeRegion(tt.minMiB); err != nil {
+				t.Fatal(err)
+			}
+			usable := usableRegions(pList)
+			var starts []uint64
+			for _, p := range usable {
+				starts = append(starts, p.StartMiB)
+			}
+			if !reflect.DeepEqual(starts, tt.wantStarts) {
+				t.Errorf("usableRegions() starts = %v, want %v", starts, tt.wantStarts)
+			}
+			if got := largestFreeRegion(usable); got != tt.wantLargest {
+				t.Errorf("largest usable region = %d, want %d", got, tt.wantLargest)
+			}
+			if got := strandedMiB(pList); got != tt.wantStranded {
+				t.Errorf("strandedMiB() = %d, want %d", got, tt.wantStranded)
+			}
+		})
+	}
+}
+
+func Test_usableRegionsAllocation(t *testing.T) {
+	defer SetMinFreeRegion(0)
+	if err := SetMinFreeRegion(8); err != nil {
+		t.Fatal(err)
+	}
+	// only the sliver fits the smallest partitions, which must not be
+	// handed out.
+	pList := usableRegions([]partFree{
+		{DiskName: "sdb", StartMiB: 1, EndMiB: 5, SizeMiB: 4},
+		{DiskName: "sdc", StartMiB: 10, EndMiB: 110, SizeMiB: 100},
+	})
+	region, ok := selectFreeRegion(pList, 2)
+	if !ok || region.DiskName != "sdc" {
+		t.Errorf("selectFreeRegion() = %+v, %v, want the region of sdc", region, ok)
+	}
+
+	if err := SetMinFreeRegion(-1); err == nil {
+		t.Errorf("SetMinFreeRegion() expected error for a negative size")
+	}
+}
+
+func Test_AllocationEfficiency(t *testing.T) {
+	defer SetMinFreeRegion(0)
+	if err := SetMinFreeRegion(8); err != nil {
+		t.Fatal(err)
+	}
+	// both disks hold 800 MiB of partitions, the fragmented one has them
+	// scattered, leaving slivers too small for a volume in between.
+	const mib = int64(PartitionAlignmentBytes)
+	contiguous := []partFree{
+		{DiskName: "sdb", StartMiB: 801, EndMiB: 1001, SizeMiB: 200},
+	}
+	fragmented := []partFree{
+		{DiskName: "sdc", StartMiB: 101, EndMiB: 105, SizeMiB: 4},
+		{DiskName: "sdc", StartMiB: 305, EndMiB: 311, SizeMiB: 6},
+		{DiskName: "sdc", StartMiB: 511, EndMiB: 701, SizeMiB: 190},
+	}
+	tests := []struct {
+		name        string
+		usedMiB     int64
+		pList       []partFree
+		wantPercent int32
+	}{
+		{name: "contiguous", usedMiB: 800, pList: contiguous, wantPercent: 100},
+		{name: "fragmented", usedMiB: 800, pList: fragmented, wantPercent: 98},
+		{name: "empty disk", usedMiB: 0, pList: contiguous, wantPercent: 100},
+		{name: "only slivers used", usedMiB: 0, pList: fragmented, wantPercent: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stranded := int64(strandedMiB(tt.pList)) * mib
+			if got := efficiencyPercent(tt.usedMiB*mib, stranded); got != tt.wantPercent {
+				t.Errorf("efficiencyPercent() = %d, want %d", got, tt.wantPercent)
+			}
+		})
+	}
+
+	// the fragmented disk strands 10 MiB next to the 800 MiB used
+	if got, want := AllocationEfficiency(800*mib, 10*mib), 800.0/810.0; got != want {
+		t.Errorf("AllocationEfficiency() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,327 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// Striped volume commands
+const (
+	StripeCreate         = "mdadm --create %s --run --level=0 --raid-devices=%d --metadata=1.2 --data-offset=%dM --name=%s %s"
+	StripeAssemble       = "mdadm --assemble %s %s"
+	StripeStop           = "mdadm --stop %s"
+	StripeZeroSuperblock = "mdadm --zero-superblock %s"
+)
+
+// StripePartitionType is the sgdisk type code of the Linux RAID partition
+// type, set on the member partitions of the striped volumes.
+const StripePartitionType = "fd00"
+
+// StripeDataOffsetMiB is the space at the start of each member partition
+// of a striped volume holding the md superblock instead of the data of the
+// volume.
+const StripeDataOffsetMiB = 1
+
+// stripeMemberRegex matches the name of a member partition of a striped
+// volume, which is the partition name of the volume with the index of the
+// member appended.
+var stripeMemberRegex = regexp.MustCompile(`-m[0-9]+$`)
+
+// IsStripedVolume checks if the volume is a RAID0 array striped across the
+// partitions on several disks, instead of a single partition.
+func IsStripedVolume(vol *apis.DeviceVolume) bool {
+	return vol.Spec.StripeCount != ""
+}
+
+// getStripeCount returns the number of disks the volume is striped across.
+func getStripeCount(vol *apis.DeviceVolume) (int, error) {
+	stripes, err := strconv.Atoi(vol.Spec.StripeCount)
+	if err != nil || stripes < 2 {
+		return 0, errors.Errorf("invalid stripe count %q of volume %s", vol.Spec.StripeCount, vol.Name)
+	}
+	return stripes, nil
+}
+
+// stripeMemberName returns the name of the index-th member partition of the
+// striped volume.
+func stripeMemberName(partitionName string, index int) string {
+	return fmt.Sprintf("%s-m%d", partitionName, index)
+}
+
+// isStripeMember checks if the partition is a member of a striped volume.
+func isStripeMember(partitionName string) bool {
+	return stripeMemberRegex.MatchString(partitionName)
+}
+
+// getStripeDevicePath returns the path of the md device of the striped
+// volume.
+func getStripeDevicePath(partitionName string) string {
+	return devicePath("md/" + partitionName)
+}
+
+// getStripeMemberSizeMiB returns the size in MiB of each member partition
+// of a volume of capacityMiB striped across stripes disks.
+func getStripeMemberSizeMiB(capacityMiB uint64, stripes int) uint64 {
+	n := uint64(stripes)
+	return (capacityMiB+n-1)/n + StripeDataOffsetMiB
+}
+
+// selectStripeRegions picks a free region of memberMiB on each of stripes
+// distinct disks. The disks whose smallest fitting region is the smallest
+// are preferred, packing the members like the binpack placement does.
+func selectStripeRegions(pList []partFree, memberMiB uint64, stripes int) ([]partFree, bool) {
+	diskRegions := map[string][]partFree{}
+	for _, tmp := range pList {
+		diskRegions[tmp.DiskName] = append(diskRegions[tmp.DiskName], tmp)
+	}
+	var candidates []partFree
+	for _, regions := range diskRegions {
+		if region, ok := selectFreeRegion(regions, memberMiB); ok {
+			candidates = append(candidates, region)
+		}
+	}
+	if len(candidates) < stripes {
+		return nil, false
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].SizeMiB != candidates[j].SizeMiB {
+			return candidates[i].SizeMiB < candidates[j].SizeMiB
+		}
+		return candidates[i].DiskName < candidates[j].DiskName
+	})
+	return candidates[:stripes], true
+}
+
+// getStripeMembers returns the existing member partitions of the striped
+// volume, ordered by their index.
+func getStripeMembers(diskMetaName, partitionName string, stripes int) ([]PartUsed, error) {
+	var members []PartUsed
+	for i := 0; i < stripes; i++ {
+		pList, err := getAllPartsUsed(diskMetaName, stripeMemberName(partitionName, i))
+		if err != nil {
+			return nil, err
+		}
+		if len(pList) > 1 {
+			return nil, errors.Errorf("more than one member partition named %s", stripeMemberName(partitionName, i))
+		}
+		members = append(members, pList...)
+	}
+	return members, nil
+}
+
+// memberPaths returns the device paths of the member partitions.
+func memberPaths(members []PartUsed) []string {
+	paths := make([]string, 0, len(members))
+	for _, member := range members {
+		paths = append(paths, member.DevicePath)
+	}
+	return paths
+}
+
+// createStripedVolume creates a member partition on each of stripes disks
+// having the device name and assembles them as a RAID0 array. Member
+// partitions left over by a failed attempt are removed first, while a
+// complete set of members is only assembled, making the creation
+// idempotent.
+func createStripedVolume(vol *apis.DeviceVolume, diskMetaName, partitionName string,
+	capacityMiB uint64, stripes int) error {
+	members, err := getStripeMembers(diskMetaName, partitionName, stripes)
+	if err != nil {
+		return err
+	}
+	mdPath := getStripeDevicePath(partitionName)
+	if len(members) == stripes {
+		klog.Infof("Members of striped volume %s already exist, Skipping creation", vol.Name)
+		if err = assembleStripe(mdPath, members); err != nil {
+			return err
+		}
+		setStripeStatus(vol, members)
+		return nil
+	}
+	if len(members) > 0 {
+		klog.Infof("Removing %d members of striped volume %s left over by a failed creation", len(members), vol.Name)
+		if err = removeStripeMembers(members); err != nil {
+			return err
+		}
+	}
+
+	memberMiB := getStripeMemberSizeMiB(capacityMiB, stripes)
+	pList, err := getAllPartsFree(diskMetaName)
+	if err != nil {
+		return err
+	}
+	regions, ok := selectStripeRegions(pList, memberMiB, stripes)
+	if !ok {
+		return errors.Errorf("no %d disks with device name %s have a free region of %d MiB",
+			stripes, diskMetaName, memberMiB)
+	}
+	for i, region := range regions {
+		if err = activePartitioner.verify(region.DiskName); err == nil {
+			err = wipefsAndCreatePart(region.DiskName, region.StartMiB, stripeMemberName(partitionName, i),
+				memberMiB, diskMetaName, StripePartitionType)
+		}
+		if err != nil {
+			cleanupStripeMembers(diskMetaName, partitionName, stripes)
+			return err
+		}
+	}
+
+	members, err = getStripeMembers(diskMetaName, partitionName, stripes)
+	if err == nil && len(members) != stripes {
+		err = errors.Errorf("found %d of the %d members of striped volume %s", len(members), stripes, vol.Name)
+	}
+	if err == nil {
+		klog.Infof("Creating striped volume %s on %v", vol.Name, memberPaths(members))
+		_, err = RunCommand(strings.Split(fmt.Sprintf(StripeCreate, mdPath, stripes, StripeDataOffsetMiB,
+			partitionName, strings.Join(memberPaths(members), " ")), " "))
+	}
+	if err != nil {
+		klog.Errorf("Create striped volume %s failed %s", vol.Name, err)
+		cleanupStripeMembers(diskMetaName, partitionName, stripes)
+		return err
+	}
+	setStripeStatus(vol, members)
+	return nil
+}
+
+// setStripeStatus records the capacity and the members of the striped
+// volume. The capacity of the array leaves out the superblock area of
+// each member.
+func setStripeStatus(vol *apis.DeviceVolume, members []PartUsed) {
+	memberMiB := members[0].Size / PartitionAlignmentBytes
+	for _, member := range members[1:] {
+		if size := member.Size / PartitionAlignmentBytes; size < memberMiB {
+			memberMiB = size
+		}
+	}
+	dataMiB := uint64(0)
+	if memberMiB > StripeDataOffsetMiB {
+		dataMiB = memberMiB - StripeDataOffsetMiB
+	}
+	vol.Status.Capacity = strconv.FormatUint(uint64(len(members))*dataMiB*PartitionAlignmentBytes, 10)
+	vol.Status.Members = memberPaths(members)
+}
+
+// cleanupStripeMembers removes the member partitions created during a
+// failed creation of the striped volume, so that it can be retried as a
+// whole.
+func cleanupStripeMembers(diskMetaName, partitionName string, stripes int) {
+	members, err := getStripeMembers(diskMetaName, partitionName, stripes)
+	if err == nil {
+		err = removeStripeMembers(members)
+	}
+	if err != nil {
+		klog.Errorf("could not delete members of striped volume %s, created during CreateVolume(). Error: %s",
+			partitionName, err)
+	}
+}
+
+// removeStripeMembers clears the md superblock of the member partitions
+// and deletes them. The superblock is missing on the members of an array
+// which failed to get created, so failing to clear it is not fatal.
+func removeStripeMembers(members []PartUsed) error {
+	for _, member := range members {
+		if err := activePartitioner.verify(member.DiskName); err != nil {
+			return err
+		}
+		_, err := RunCommand(strings.Split(fmt.Sprintf(StripeZeroSuperblock, member.DevicePath), " "))
+		if err != nil {
+			klog.Warningf("could not clear md superblock of %s: %v", member.DevicePath, err)
+		}
+		if err = wipefsAndDeletePart(member.DiskName, member.PartNum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assembleStripe assembles the RAID0 array from its members, unless it is
+// assembled already, e.g. by the incremental assembly of udev at boot.
+func assembleStripe(mdPath string, members []PartUsed) error {
+	if _, err := os.Stat(mdPath); err == nil {
+		return nil
+	}
+	klog.Infof("Assembling striped volume %s from %v", mdPath, memberPaths(members))
+	_, err := RunCommand(strings.Split(fmt.Sprintf(StripeAssemble, mdPath,
+		strings.Join(memberPaths(members), " ")), " "))
+	if err != nil {
+		return errors.Wrapf(err, "could not assemble %s", mdPath)
+	}
+	return nil
+}
+
+// destroyStripedVolume stops the RAID0 array of the striped volume and
+// deletes all of its member partitions.
+func destroyStripedVolume(vol *apis.DeviceVolume, diskMetaName, partitionName string, stripes int) error {
+	members, err := getStripeMembers(diskMetaName, partitionName, stripes)
+	if err != nil {
+		return err
+	}
+	mdPath := getStripeDevicePath(partitionName)
+	if _, err = os.Stat(mdPath); err == nil {
+		klog.Infof("Stopping striped volume %s", vol.Name)
+		if _, err = RunCommand(strings.Split(fmt.Sprintf(StripeStop, mdPath), " ")); err != nil {
+			return errors.Wrapf(err, "could not stop %s", mdPath)
+		}
+	}
+	if len(members) == 0 {
+		klog.Infof("%s Members not found, Skipping Deletion\n", partitionName)
+		return nil
+	}
+	return removeStripeMembers(members)
+}
+
+// getStripedVolumeDevPath returns the path of the md device of the striped
+// volume, assembling the array if needed. All of the members have to be
+// present, as RAID0 has no redundancy.
+func getStripedVolumeDevPath(vol *apis.DeviceVolume) (string, error) {
+	stripes, err := getStripeCount(vol)
+	if err != nil {
+		return "", err
+	}
+	partitionName := vol.Name[4:]
+	unlock, err := lockMetaDisks(vol.Spec.DevName)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	members, err := getStripeMembers(vol.Spec.DevName, partitionName, stripes)
+	if err != nil {
+		return "", err
+	}
+	if len(members) != stripes {
+		return "", errors.Errorf("striped volume %s has %d of its %d members, the data of the volume is lost",
+			vol.Name, len(members), stripes)
+	}
+	mdPath := getStripeDevicePath(partitionName)
+	if err = assembleStripe(mdPath, members); err != nil {
+		return "", err
+	}
+	return mdPath, nil
+}
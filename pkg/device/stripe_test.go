@@ -0,0 +1,141 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func Test_getStripeMemberSizeMiB(t *testing.T) {
+	tests := []struct {
+		name        string
+		capacityMiB uint64
+		stripes     int
+		want        uint64
+	}{
+		{name: "even split", capacityMiB: 1024, stripes: 2, want: 513},
+		{name: "uneven split", capacityMiB: 1000, stripes: 3, want: 335},
+		{name: "smaller than the stripes", capacityMiB: 1, stripes: 4, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getStripeMemberSizeMiB(tt.capacityMiB, tt.stripes); got != tt.want {
+				t.Errorf("getStripeMemberSizeMiB() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_selectStripeRegions(t *testing.T) {
+	pList := []partFree{
+		{DiskName: "sda", StartMiB: 100, EndMiB: 600, SizeMiB: 500},
+		{DiskName: "sda", StartMiB: 700, EndMiB: 800, SizeMiB: 100},
+		{DiskName: "sdb", StartMiB: 10, EndMiB: 310, SizeMiB: 300},
+		{DiskName: "sdc", StartMiB: 10, EndMiB: 1010, SizeMiB: 1000},
+		{DiskName: "sdd", StartMiB: 10, EndMiB: 60, SizeMiB: 50},
+	}
+	tests := []struct {
+		name      string
+		memberMiB uint64
+		stripes   int
+		want      []string
+		wantOK    bool
+	}{
+		{name: "smallest fitting disks", memberMiB: 100, stripes: 2, want: []string{"sda:700", "sdb:10"}, wantOK: true},
+		{name: "one member per disk", memberMiB: 300, stripes: 3, want: []string{"sdb:10", "sda:100", "sdc:10"}, wantOK: true},
+		{name: "all disks", memberMiB: 50, stripes: 4, want: []string{"sdd:10", "sda:700", "sdb:10", "sdc:10"}, wantOK: true},
+		{name: "not enough disks", memberMiB: 400, stripes: 3, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regions, ok := selectStripeRegions(pList, tt.memberMiB, tt.stripes)
+			if ok != tt.wantOK {
+				t.Fatalf("selectStripeRegions() ok = %v, want %v", ok, tt.wantOK)
+			}
+			var got []string
+			for _, region := range regions {
+				got = append(got, fmt.Sprintf("%s:%d", region.DiskName, region.StartMiB))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectStripeRegions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isStripeMember(t *testing.T) {
+	tests := map[string]bool{
+		"7f1b-m0":      true,
+		"7f1b-m12":     true,
+		"7f1b":         false,
+		"7f1b-reserve": false,
+		"7f1b-m":       false,
+	}
+	for name, want := range tests {
+		if got := isStripeMember(name); got != want {
+			t.Errorf("isStripeMember(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func Test_setStripeStatus(t *testing.T) {
+	vol := &apis.DeviceVolume{ObjectMeta: metav1.ObjectMeta{Name: "pvc-7f1b"}}
+	members := []PartUsed{
+		{DiskName: "sda", PartNum: 2, DevicePath: "/dev/sda2", Size: 513 * PartitionAlignmentBytes},
+		{DiskName: "sdb", PartNum: 3, DevicePath: "/dev/sdb3", Size: 514 * PartitionAlignmentBytes},
+	}
+	setStripeStatus(vol, members)
+	if want := "1073741824"; vol.Status.Capacity != want {
+		t.Errorf("capacity = %s, want %s", vol.Status.Capacity, want)
+	}
+	if want := []string{"/dev/sda2", "/dev/sdb3"}; !reflect.DeepEqual(vol.Status.Members, want) {
+		t.Errorf("members = %v, want %v", vol.Status.Members, want)
+	}
+}
+
+func TestIsStripedVolume(t *testing.T) {
+	vol := &apis.DeviceVolume{ObjectMeta: metav1.ObjectMeta{Name: "pvc-7f1b"}}
+	vol.Spec.PartitionType = DefaultPartitionType
+	if IsStripedVolume(vol) {
+		t.Errorf("IsStripedVolume() = true for a single partition volume")
+	}
+	if _, ok := getAttributes(vol)[AttributePartitionType]; !ok {
+		t.Errorf("partition type missing in the attributes of a single partition volume")
+	}
+
+	vol.Spec.StripeCount = "3"
+	if !IsStripedVolume(vol) {
+		t.Errorf("IsStripedVolume() = false for a striped volume")
+	}
+	if stripes, err := getStripeCount(vol); err != nil || stripes != 3 {
+		t.Errorf("getStripeCount() = %d, %v, want 3", stripes, err)
+	}
+	if _, ok := getAttributes(vol)[AttributePartitionType]; ok {
+		t.Errorf("partition type of a striped volume must not be applied to its members")
+	}
+
+	vol.Spec.StripeCount = "1"
+	if _, err := getStripeCount(vol); err == nil {
+		t.Errorf("getStripeCount() expected error for a single stripe")
+	}
+}
@@ -0,0 +1,146 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// diskTemperaturePaths are the patterns of the hwmon temperature inputs of
+// a disk in sysfs, in millidegrees Celsius. The NVMe disks expose the
+// sensor of their controller, the SATA and SAS disks expose theirs once
+// the drivetemp module is loaded.
+var diskTemperaturePaths = []string{
+	"block/%s/device/hwmon*/temp1_input",
+	"block/%s/device/hwmon/hwmon*/temp1_input",
+}
+
+// hotTemperature is the temperature in degrees Celsius above which the
+// disks are avoided by the new partitions. Zero disables it.
+var hotTemperature int32
+
+// SetHotTemperature sets the temperature in degrees Celsius above which the
+// disks are avoided by the new partitions while the other disks have room
+// for them. Zero disables it.
+func SetHotTemperature(celsius int) error {
+	if celsius < 0 {
+		return errors.Errorf("invalid hot temperature %d", celsius)
+	}
+	hotTemperature = int32(celsius)
+	return nil
+}
+
+// getDiskTemperature reads the temperature of the disk in degrees Celsius.
+// It returns false if the disk doesn't report it.
+func getDiskTemperature(diskName string) (int32, bool) {
+	for _, pattern := range diskTemperaturePaths {
+		matches, _ := filepath.Glob(sysfsPath(pattern, diskName))
+		for _, path := range matches {
+			out, err := ioutil.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			milli, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+			if err != nil {
+				klog.V(4).Infof("Device LocalPV: invalid temperature %q of %s", out, diskName)
+				continue
+			}
+			return int32(milli / 1000), true
+		}
+	}
+	klog.V(4).Infof("Device LocalPV: could not read temperature of %s", diskName)
+	return 0, false
+}
+
+// isHot checks if a disk of the given temperature is avoided by the new
+// partitions.
+func isHot(celsius int32) bool {
+	return hotTemperature > 0 && celsius > hotTemperature
+}
+
+// hotDevices holds the UUIDs of the disks found hot by the last discovery.
+var hotDevices = struct {
+	sync.RWMutex
+	uuids map[string]bool
+}{}
+
+func setHotDevices(hot map[string]bool) {
+	hotDevices.Lock()
+	defer hotDevices.Unlock()
+	hotDevices.uuids = hot
+}
+
+// isDiskHot checks if the disk was found hot by the last discovery.
+func isDiskHot(diskName string) bool {
+	if hotTemperature == 0 {
+		return false
+	}
+	hotDevices.RLock()
+	defer hotDevices.RUnlock()
+	if len(hotDevices.uuids) == 0 {
+		return false
+	}
+	id, err := getDiskIdentifier(diskName)
+	if err != nil {
+		return false
+	}
+	return hotDevices.uuids[id]
+}
+
+// avoidHotDisks narrows the free regions down to the disks which are not
+// hot, for the placement policy to pick from. It is a no-op when the hot
+// temperature is not set.
+func avoidHotDisks(pList []partFree, partSize uint64) []partFree {
+	if hotTemperature == 0 || len(pList) == 0 {
+		return pList
+	}
+	hot := map[string]bool{}
+	for _, region := range pList {
+		if _, ok := hot[region.DiskName]; !ok {
+			hot[region.DiskName] = isDiskHot(region.DiskName)
+		}
+	}
+	return selectCoolDisks(pList, hot, partSize)
+}
+
+// selectCoolDisks keeps the free regions of the disks which are not hot if
+// one of them can hold a partition of partSize MiB. The hot disks are only
+// avoided, all the regions are kept when none of the other disks has room
+// for the partition.
+func selectCoolDisks(pList []partFree, hot map[string]bool, partSize uint64) []partFree {
+	var cool []partFree
+	for _, region := range pList {
+		if !hot[region.DiskName] {
+			cool = append(cool, region)
+		}
+	}
+	if len(cool) == len(pList) {
+		return pList
+	}
+	if _, ok := selectFreeRegion(cool, partSize); !ok {
+		klog.Infof("all the disks with room for a partition of %d MiB are hot, using them", partSize)
+		return pList
+	}
+	return cool
+}
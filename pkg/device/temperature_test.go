@@ -0,0 +1,120 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_selectCoolDisks(t *testing.T) {
+	pList := []partFree{
+		{"sdb", 2, 1002, 1000},
+		{"sdc", 2, 202, 200},
+		{"sdd", 2, 502, 500},
+	}
+	tests := []struct {
+		name     string
+		hot      map[string]bool
+		partSize uint64
+		want     []string
+	}{
+		{name: "no hot disk", hot: map[string]bool{}, partSize: 100, want: []string{"sdb", "sdc", "sdd"}},
+		{name: "hot disk avoided", hot: map[string]bool{"sdb": true}, partSize: 100, want: []string{"sdc", "sdd"}},
+		{name: "cool disk without room", hot: map[string]bool{"sdb": true, "sdd": true}, partSize: 100, want: []string{"sdc"}},
+		{name: "only hot disks have room", hot: map[string]bool{"sdb": true}, partSize: 800, want: []string{"sdb", "sdc", "sdd"}},
+		{name: "all disks hot", hot: map[string]bool{"sdb": true, "sdc": true, "sdd": true}, partSize: 100, want: []string{"sdb", "sdc", "sdd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, region := range selectCoolDisks(pList, tt.hot, tt.partSize) {
+				got = append(got, region.DiskName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectCoolDisks() kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isHot(t *testing.T) {
+	defer func() { hotTemperature = 0 }()
+	if isHot(70) {
+		t.Errorf("isHot() with no hot temperature = true, want false")
+	}
+	if err := SetHotTemperature(-1); err == nil {
+		t.Errorf("SetHotTemperature() expected error for a negative temperature")
+	}
+	if err := SetHotTemperature(55); err != nil {
+		t.Fatal(err)
+	}
+	for celsius, want := range map[int32]bool{0: false, 55: false, 56: true, 70: true} {
+		if got := isHot(celsius); got != want {
+			t.Errorf("isHot(%d) = %v, want %v", celsius, got, want)
+		}
+	}
+}
+
+func Test_getDiskTemperature(t *testing.T) {
+	root, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	defer func(sysRoot string) { SysRoot = sysRoot }(SysRoot)
+	SysRoot = root
+
+	sensors := map[string]string{
+		// the sensor of the controller of an nvme disk
+		"block/nvme0n1/device/hwmon2/temp1_input": "41850\n",
+		// a sata disk through drivetemp
+		"block/sdb/device/hwmon/hwmon3/temp1_input": "38000\n",
+		"block/sdc/device/hwmon/hwmon4/temp1_input": "n/a\n",
+	}
+	for path, value := range sensors {
+		path = filepath.Join(root, path)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		disk   string
+		want   int32
+		wantOK bool
+	}{
+		{disk: "nvme0n1", want: 41, wantOK: true},
+		{disk: "sdb", want: 38, wantOK: true},
+		{disk: "sdc", want: 0, wantOK: false},
+		{disk: "sdd", want: 0, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.disk, func(t *testing.T) {
+			got, ok := getDiskTemperature(tt.disk)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("getDiskTemperature() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
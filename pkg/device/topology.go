@@ -0,0 +1,34 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+// TopologyLabels returns the labels of the Kubernetes Node among the given
+// topology keys, e.g. the region, zone and rack labels, to be imported onto
+// the DeviceNode and the DeviceVolumes of the node. The keys missing on the
+// node are left out.
+func TopologyLabels(nodeLabels map[string]string, keys []string) map[string]string {
+	if len(keys) == 0 {
+		return nil
+	}
+	labels := map[string]string{}
+	for _, key := range keys {
+		if value, ok := nodeLabels[key]; ok {
+			labels[key] = value
+		}
+	}
+	return labels
+}
@@ -0,0 +1,181 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// AllocationTraceKey is the DeviceVolume annotation recording, as json,
+// the disks the allocator considered for the partition of the volume and
+// the one it picked.
+const AllocationTraceKey = "device.openebs.io/allocation-trace"
+
+// Reasons for which the allocator didn't pick a disk
+const (
+	// TraceRejectedDevName denotes the disk has no meta partition of the
+	// device name of the volume.
+	TraceRejectedDevName = "wrong-devname"
+	// TraceRejectedExcluded denotes the disk is excluded in the spec of
+	// the DeviceNode.
+	TraceRejectedExcluded = "excluded"
+	// TraceRejectedZeroWeight denotes the disk has a scheduling weight of
+	// zero in the DeviceNode.
+	TraceRejectedZeroWeight = "zero-weight"
+	// TraceRejectedSignature denotes the disk carries an LVM or mdraid
+	// signature.
+	TraceRejectedSignature = "foreign-signature"
+	// TraceRejectedAntiAffinity denotes the disk holds another volume of
+	// the anti-affinity group of the volume.
+	TraceRejectedAntiAffinity = "anti-affinity"
+	// TraceRejectedFull denotes the disk has no free region large enough
+	// for the partition.
+	TraceRejectedFull = "full"
+	// TraceRejectedPendingReadiness denotes the disk appeared recently and
+	// is not ready yet.
+	TraceRejectedPendingReadiness = "pending-readiness"
+	// TraceRejectedQuarantined denotes the device is marked as bad in the
+	// DeviceNode.
+	TraceRejectedQuarantined = "quarantined"
+	// TraceRejectedSlotBudget denotes the slot class of the volume used
+	// up its budget of GPT partition entries on the disk.
+	TraceRejectedSlotBudget = "slot-budget"
+	// TraceRejectedOutranked denotes the disk could hold the partition,
+	// but the placement policy preferred another disk.
+	TraceRejectedOutranked = "outranked"
+)
+
+// maxTraceCandidates bounds the disks listed in the trace, the others are
+// only counted by the reason they were left out.
+const maxTraceCandidates = 16
+
+// TraceCandidate is a disk considered for the partition of a volume.
+type TraceCandidate struct {
+	Disk string `json:"disk"`
+	// LargestFreeMiB is the size of the largest free region of the disk.
+	LargestFreeMiB uint64 `json:"largestFreeMiB,omitempty"`
+	// Partitions is the number of volume partitions on the disk, known
+	// for the spread placement only.
+	Partitions int `json:"partitions,omitempty"`
+	// Rejected is the reason the disk wasn't picked, empty for the
+	// picked disk.
+	Rejected string `json:"rejected,omitempty"`
+}
+
+// AllocationTrace is the decision of the allocator placing the partition
+// of a volume.
+type AllocationTrace struct {
+	Time       time.Time        `json:"time"`
+	Node       string           `json:"node"`
+	DevName    string           `json:"devName"`
+	Placement  string           `json:"placement,omitempty"`
+	SizeMiB    uint64           `json:"sizeMiB"`
+	Candidates []TraceCandidate `json:"candidates"`
+	// Omitted counts the disks left out of the candidates by the reason
+	// they weren't picked, when there are too many to list.
+	Omitted  map[string]int `json:"omitted,omitempty"`
+	Disk     string         `json:"disk"`
+	StartMiB uint64         `json:"startMiB"`
+}
+
+// traceRank orders the candidates in the trace, the picked disk and the
+// disks which could hold the partition go first.
+var traceRank = map[string]int{
+	"":                            0,
+	TraceRejectedOutranked:        1,
+	TraceRejectedFull:             2,
+	TraceRejectedAntiAffinity:     2,
+	TraceRejectedSlotBudget:       2,
+	TraceRejectedExcluded:         3,
+	TraceRejectedZeroWeight:       3,
+	TraceRejectedSignature:        3,
+	TraceRejectedPendingReadiness: 3,
+	TraceRejectedQuarantined:      3,
+	TraceRejectedDevName:          4,
+}
+
+// newAllocationTrace builds the trace of the allocation from its record.
+func newAllocationTrace(rec AllocationRecord) AllocationTrace {
+	trace := AllocationTrace{
+		Time:      rec.Time,
+		Node:      NodeID,
+		DevName:   rec.DevName,
+		Placement: rec.Placement,
+		SizeMiB:   rec.SizeMiB,
+		Disk:      rec.Disk,
+		StartMiB:  rec.StartMiB,
+	}
+
+	diskRegions := map[string][]partFree{}
+	for _, region := range rec.FreeRegions {
+		diskRegions[region.DiskName] = append(diskRegions[region.DiskName], region)
+	}
+	candidates := make([]TraceCandidate, 0, len(rec.Disks))
+	for disk, rejected := range rec.Disks {
+		candidate := TraceCandidate{Disk: disk, Rejected: rejected}
+		if rejected == "" {
+			regions := diskRegions[disk]
+			candidate.LargestFreeMiB = largestFreeRegion(regions)
+			candidate.Partitions = rec.PartitionCounts[disk]
+			if disk != rec.Disk {
+				candidate.Rejected = TraceRejectedOutranked
+				if _, ok := selectFreeRegion(regions, rec.SizeMiB); !ok {
+					candidate.Rejected = TraceRejectedFull
+				}
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ri, rj := traceRank[candidates[i].Rejected], traceRank[candidates[j].Rejected]
+		if ri != rj {
+			return ri < rj
+		}
+		return candidates[i].Disk < candidates[j].Disk
+	})
+
+	if len(candidates) > maxTraceCandidates {
+		trace.Omitted = map[string]int{}
+		for _, candidate := range candidates[maxTraceCandidates:] {
+			trace.Omitted[candidate.Rejected]++
+		}
+		candidates = candidates[:maxTraceCandidates]
+	}
+	trace.Candidates = candidates
+	return trace
+}
+
+// setAllocationTrace records the trace of the allocation of the partition
+// of the volume as its annotation. Failing to encode it only loses the
+// trace.
+func setAllocationTrace(vol *apis.DeviceVolume, rec AllocationRecord) {
+	data, err := json.Marshal(newAllocationTrace(rec))
+	if err != nil {
+		klog.Warningf("could not encode the allocation trace of volume %s: %v", vol.Name, err)
+		return
+	}
+	if vol.Annotations == nil {
+		vol.Annotations = map[string]string{}
+	}
+	vol.Annotations[AllocationTraceKey] = string(data)
+}
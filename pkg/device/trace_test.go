@@ -0,0 +1,116 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func Test_setAllocationTrace(t *testing.T) {
+	nodeID := NodeID
+	NodeID = "node1"
+	defer func() { NodeID = nodeID }()
+
+	rec := AllocationRecord{
+		Time:      time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC),
+		Partition: "7f1b",
+		DevName:   "test-device",
+		Placement: PlacementSpread,
+		SizeMiB:   200,
+		FreeRegions: []partFree{
+			{DiskName: "sdb", StartMiB: 100, EndMiB: 400, SizeMiB: 300},
+			{DiskName: "sdc", StartMiB: 10, EndMiB: 1010, SizeMiB: 1000},
+			{DiskName: "sdd", StartMiB: 10, EndMiB: 60, SizeMiB: 50},
+		},
+		PartitionCounts: map[string]int{"sdb": 1, "sdc": 3, "sdd": 2},
+		Disks: map[string]string{
+			"sda": TraceRejectedDevName,
+			"sdb": "",
+			"sdc": "",
+			"sdd": "",
+			"sde": "",
+			"sdf": TraceRejectedExcluded,
+		},
+		Disk:     "sdb",
+		StartMiB: 100,
+	}
+	vol := &apis.DeviceVolume{ObjectMeta: metav1.ObjectMeta{Name: "pvc-7f1b"}}
+	setAllocationTrace(vol, rec)
+
+	var trace AllocationTrace
+	if err := json.Unmarshal([]byte(vol.Annotations[AllocationTraceKey]), &trace); err != nil {
+		t.Fatalf("could not decode the trace %q: %v", vol.Annotations[AllocationTraceKey], err)
+	}
+	want := AllocationTrace{
+		Time:      rec.Time,
+		Node:      "node1",
+		DevName:   "test-device",
+		Placement: PlacementSpread,
+		SizeMiB:   200,
+		Candidates: []TraceCandidate{
+			{Disk: "sdb", LargestFreeMiB: 300, Partitions: 1},
+			{Disk: "sdc", LargestFreeMiB: 1000, Partitions: 3, Rejected: TraceRejectedOutranked},
+			{Disk: "sdd", LargestFreeMiB: 50, Partitions: 2, Rejected: TraceRejectedFull},
+			{Disk: "sde", Rejected: TraceRejectedFull},
+			{Disk: "sdf", Rejected: TraceRejectedExcluded},
+			{Disk: "sda", Rejected: TraceRejectedDevName},
+		},
+		Disk:     "sdb",
+		StartMiB: 100,
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %+v, want %+v", trace, want)
+	}
+}
+
+func Test_newAllocationTraceBounded(t *testing.T) {
+	rec := AllocationRecord{
+		DevName: "test-device",
+		SizeMiB: 100,
+		Disks:   map[string]string{},
+		Disk:    "sdz",
+	}
+	for i := 0; i < 30; i++ {
+		rec.Disks[fmt.Sprintf("sd%02d", i)] = TraceRejectedDevName
+	}
+	for i := 0; i < 10; i++ {
+		disk := fmt.Sprintf("nvme%dn1", i)
+		rec.Disks[disk] = ""
+		rec.FreeRegions = append(rec.FreeRegions, partFree{DiskName: disk, StartMiB: 1, EndMiB: 51, SizeMiB: 50})
+	}
+	rec.Disks["sdz"] = ""
+	rec.FreeRegions = append(rec.FreeRegions, partFree{DiskName: "sdz", StartMiB: 1, EndMiB: 201, SizeMiB: 200})
+
+	trace := newAllocationTrace(rec)
+	if len(trace.Candidates) != maxTraceCandidates {
+		t.Fatalf("got %d candidates, want %d", len(trace.Candidates), maxTraceCandidates)
+	}
+	if trace.Candidates[0].Disk != "sdz" || trace.Candidates[0].Rejected != "" {
+		t.Errorf("picked disk must be listed first, got %+v", trace.Candidates[0])
+	}
+	if want := map[string]int{TraceRejectedDevName: 25}; !reflect.DeepEqual(trace.Omitted, want) {
+		t.Errorf("omitted = %v, want %v", trace.Omitted, want)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// VolumeTrim discards the unused blocks of a mounted filesystem
+const VolumeTrim = "fstrim -v %s"
+
+// sysfs attributes of the disk used to decide on trimming, relative to
+// SysRoot
+const (
+	DiskDiscardMaxPath = "block/%s/queue/discard_max_bytes"
+	DiskInflightPath   = "block/%s/inflight"
+)
+
+// trimBusyInflight is the number of in-flight requests of the disk above
+// which the trimming is postponed to not add to a heavy IO load.
+const trimBusyInflight = 32
+
+// trimmedRegex matches the number of bytes in the fstrim -v output, e.g.
+// "/mnt/vol: 1.2 GiB (1288490188 bytes) trimmed".
+var trimmedRegex = regexp.MustCompile(`\((\d+) bytes\) trimmed`)
+
+// TrimVolume runs fstrim on the filesystem of the volume and returns the
+// number of bytes trimmed. The volume is skipped, with the reason returned,
+// if it is striped across disks, if its disk is not a discard capable SSD,
+// if it is mounted with the discard option which trims already, if it is
+// not mounted as a filesystem or if its disk is busy.
+func TrimVolume(vol *apis.DeviceVolume) (int64, string, error) {
+	if IsStripedVolume(vol) {
+		return 0, "volume is striped across disks", nil
+	}
+	pList, err := getAllPartsUsed(vol.Spec.DevName, vol.Name[4:])
+	if err != nil {
+		return 0, "", err
+	}
+	if len(pList) != 1 {
+		return 0, "", errors.Errorf("found %d partitions for volume %s", len(pList), vol.Name)
+	}
+	disk := pList[0].DiskName
+
+	if getDiskMediaType(disk) != MediaTypeSSD {
+		return 0, "disk is not an ssd", nil
+	}
+	if readSysfsInt(sysfsPath(DiskDiscardMaxPath, disk)) <= 0 {
+		return 0, "disk doesn't support discard", nil
+	}
+
+	mountPath, reason, err := getTrimMountPath(getPartitionPath(disk, pList[0].PartNum))
+	if err != nil || reason != "" {
+		return 0, reason, err
+	}
+
+	if inflight := readInflight(disk); inflight > trimBusyInflight {
+		return 0, fmt.Sprintf("disk is busy with %d requests in flight", inflight), nil
+	}
+
+	out, err := RunCommand(strings.Split(fmt.Sprintf(VolumeTrim, mountPath), " "))
+	if err != nil {
+		return 0, "", err
+	}
+	return parseTrimOutput(out)
+}
+
+// getTrimMountPath finds a filesystem mount of the partition to run fstrim
+// on. All the mounts of a filesystem share its blocks, so one is enough.
+func getTrimMountPath(devicePath string) (string, string, error) {
+	mounts, err := newMounter().List()
+	if err != nil {
+		return "", "", err
+	}
+	var mountPath string
+	for _, mp := range mounts {
+		if mp.Device != devicePath {
+			continue
+		}
+		if hasMountOption(mp.Opts, "discard") {
+			return "", "mounted with discard", nil
+		}
+		if mountPath == "" {
+			mountPath = mp.Path
+		}
+	}
+	if mountPath == "" {
+		return "", "not mounted as a filesystem", nil
+	}
+	return mountPath, "", nil
+}
+
+func hasMountOption(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrimOutput returns the number of bytes trimmed from the fstrim -v
+// output.
+func parseTrimOutput(out string) (int64, string, error) {
+	match := trimmedRegex.FindStringSubmatch(out)
+	if match == nil {
+		return 0, "", errors.Errorf("unexpected fstrim output %q", out)
+	}
+	trimmed, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, "", errors.Wrapf(err, "invalid trimmed bytes in fstrim output %q", out)
+	}
+	return trimmed, "", nil
+}
+
+// readInflight returns the number of read and write requests in flight on
+// the disk, zero if it could not be read.
+func readInflight(disk string) int64 {
+	out, err := ioutil.ReadFile(sysfsPath(DiskInflightPath, disk))
+	if err != nil {
+		return 0
+	}
+	var inflight int64
+	for _, field := range strings.Fields(string(out)) {
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err == nil {
+			inflight += n
+		}
+	}
+	return inflight
+}
+
+func readSysfsInt(path string) int64 {
+	out, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
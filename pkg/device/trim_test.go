@@ -0,0 +1,66 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"testing"
+)
+
+func Test_parseTrimOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		out       string
+		trimmed   int64
+		expectErr bool
+	}{
+		{
+			name:    "trimmed",
+			out:     "/var/lib/kubelet/pods/pod-1/volumes/kubernetes.io~csi/pvc-1/mount: 1.2 GiB (1288490188 bytes) trimmed\n",
+			trimmed: 1288490188,
+		},
+		{
+			name:    "trimmed with device",
+			out:     "/mnt/vol: 0 B (0 bytes) trimmed on /dev/sdb2\n",
+			trimmed: 0,
+		},
+		{
+			name:      "unexpected output",
+			out:       "fstrim: /mnt/vol: the discard operation is not supported\n",
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trimmed, _, err := parseTrimOutput(tt.out)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("parseTrimOutput() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if trimmed != tt.trimmed {
+				t.Errorf("parseTrimOutput() got = %v, want %v", trimmed, tt.trimmed)
+			}
+		})
+	}
+}
+
+func Test_hasMountOption(t *testing.T) {
+	if !hasMountOption([]string{"rw", "relatime", "discard"}, "discard") {
+		t.Errorf("expected discard option to be found")
+	}
+	if hasMountOption([]string{"rw", "nodiscard"}, "discard") {
+		t.Errorf("expected nodiscard not to match discard")
+	}
+}
@@ -0,0 +1,220 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	k8sapi "github.com/openebs/lib-csi/pkg/client/k8s"
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"golang.org/x/sys/unix"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"k8s.io/utils/mount"
+)
+
+// DeviceUser is an active mount of a volume on a disk of the node, along
+// with the pod consuming it.
+type DeviceUser struct {
+	Disk      string `json:"disk"`
+	Volume    string `json:"volume"`
+	MountPath string `json:"mountPath"`
+	// PodUID is the uid of the pod, if the mount path is a publish target
+	// of kubelet.
+	PodUID string `json:"podUID,omitempty"`
+	// Pod is the namespace/name of the pod, empty if it could not be
+	// resolved.
+	Pod string `json:"pod,omitempty"`
+}
+
+// volumeParts denotes the disks and partitions holding a volume.
+type volumeParts struct {
+	disks map[string]bool
+	paths []string
+}
+
+// ListDeviceUsers lists the active mounts of the volumes on the disks of
+// the node, from the mounts of the namespace the volumes are mounted in
+// and the DeviceVolumes of the node, and resolves the pods consuming them
+// from the publish targets under kubeletDir. A striped volume is listed
+// once per disk it spans, as pulling any of them breaks it.
+func ListDeviceUsers(kubeletDir string) ([]DeviceUser, error) {
+	if NodeID == "" {
+		return nil, errors.New("node id is not set, the users have to be listed from the node agent")
+	}
+	parts, err := ListPartUsed()
+	if err != nil {
+		return nil, err
+	}
+	vols, err := ListDeviceVolumes()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the volumes")
+	}
+	mounts, err := mount.ParseMountInfo(mountInfoPath())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the mounts")
+	}
+	pods, err := getNodePods()
+	if err != nil {
+		klog.Warningf("failed to list the pods of node %s, the pods of the mounts are not resolved: %v", NodeID, err)
+	}
+
+	volParts := map[string]*volumeParts{}
+	for _, p := range parts {
+		name := getPartitionVolume(p.Name)
+		vp, ok := volParts[name]
+		if !ok {
+			vp = &volumeParts{disks: map[string]bool{}}
+			volParts[name] = vp
+		}
+		vp.disks[p.DiskName] = true
+		vp.paths = append(vp.paths, p.DevicePath)
+	}
+
+	var users []DeviceUser
+	for i := range vols.Items {
+		vol := &vols.Items[i]
+		vp, ok := volParts[vol.Name]
+		if vol.Spec.OwnerNodeID != NodeID || !ok {
+			continue
+		}
+		paths := vp.paths
+		if IsStripedVolume(vol) {
+			paths = []string{getStripeDevicePath(vol.Name[4:])}
+		}
+		for _, path := range paths {
+			major, minor, nodeName, err := getDeviceNumber(path)
+			if err != nil {
+				klog.Warningf("skipping device %s of volume %s: %v", path, vol.Name, err)
+				continue
+			}
+			for _, mountPath := range deviceMounts(mounts, major, minor, nodeName) {
+				uid, _ := parsePodUID(kubeletDir, mountPath)
+				for disk := range vp.disks {
+					users = append(users, DeviceUser{
+						Disk:      disk,
+						Volume:    vol.Name,
+						MountPath: mountPath,
+						PodUID:    uid,
+						Pod:       pods[uid],
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Disk != users[j].Disk {
+			return users[i].Disk < users[j].Disk
+		}
+		if users[i].Volume != users[j].Volume {
+			return users[i].Volume < users[j].Volume
+		}
+		return users[i].MountPath < users[j].MountPath
+	})
+	return users, nil
+}
+
+// mountInfoPath returns the mountinfo file of the namespace the volumes
+// are mounted in.
+func mountInfoPath() string {
+	if hostMountNamespace == "" {
+		return "/proc/self/mountinfo"
+	}
+	return filepath.Join(filepath.Dir(filepath.Dir(hostMountNamespace)), "mountinfo")
+}
+
+// getDeviceNumber returns the device number of the block device at path
+// and the name of its device node, following the symlinks like the ones
+// of the RAID0 arrays.
+func getDeviceNumber(path string) (int, int, string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	var st unix.Stat_t
+	if err = unix.Stat(resolved, &st); err != nil {
+		return 0, 0, "", err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return 0, 0, "", errors.Errorf("%s is not a block device", resolved)
+	}
+	rdev := uint64(st.Rdev)
+	return int(unix.Major(rdev)), int(unix.Minor(rdev)), filepath.Base(resolved), nil
+}
+
+// deviceMounts returns the mount points of the device with the given
+// number: the mounts of the filesystem on the device, and the bind mounts
+// of its device node, which the block volumes are published with.
+func deviceMounts(mounts []mount.MountInfo, major, minor int, nodeName string) []string {
+	var paths []string
+	for _, mi := range mounts {
+		if (mi.Major == major && mi.Minor == minor) ||
+			(mi.FsType == "devtmpfs" && mi.Root == "/"+nodeName) {
+			paths = append(paths, mi.MountPoint)
+		}
+	}
+	return paths
+}
+
+// parsePodUID returns the uid of the pod a volume is published to at the
+// mount path, from the layout of the kubelet directory. It returns false
+// for the other mounts, e.g. made by hand.
+func parsePodUID(kubeletDir, mountPath string) (string, bool) {
+	rel, err := filepath.Rel(kubeletDir, mountPath)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	switch {
+	// pods/<uid>/volumes/kubernetes.io~csi/<pv>/mount for the filesystem
+	// volumes and pods/<uid>/volumeDevices/kubernetes.io~csi/<pv> for the
+	// block volumes.
+	case len(parts) >= 3 && parts[0] == "pods" &&
+		(parts[2] == "volumes" || parts[2] == "volumeDevices"):
+		return parts[1], true
+	// plugins/kubernetes.io/csi/volumeDevices/publish/<pv>/<uid> for the
+	// block volumes.
+	case len(parts) == 7 &&
+		strings.Join(parts[:5], "/") == "plugins/kubernetes.io/csi/volumeDevices/publish":
+		return parts[6], true
+	}
+	return "", false
+}
+
+// getNodePods maps the uids of the pods on the node to their
+// namespace/name.
+func getNodePods() (map[string]string, error) {
+	client, err := k8sapi.Clientset().Get()
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + NodeID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	uids := map[string]string{}
+	for _, pod := range pods.Items {
+		uids[string(pod.UID)] = pod.Namespace + "/" + pod.Name
+	}
+	return uids, nil
+}
@@ -0,0 +1,87 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/utils/mount"
+)
+
+func Test_parsePodUID(t *testing.T) {
+	tests := []struct {
+		name      string
+		mountPath string
+		uid       string
+		ok        bool
+	}{
+		{
+			name:      "filesystem volume",
+			mountPath: "/var/lib/kubelet/pods/6b1f3c1e-0b6a-4a7e-8d0a-1c2b3d4e5f60/volumes/kubernetes.io~csi/pvc-1/mount",
+			uid:       "6b1f3c1e-0b6a-4a7e-8d0a-1c2b3d4e5f60", ok: true,
+		},
+		{
+			name:      "block volume in the pod directory",
+			mountPath: "/var/lib/kubelet/pods/6b1f3c1e-0b6a-4a7e-8d0a-1c2b3d4e5f60/volumeDevices/kubernetes.io~csi/pvc-1",
+			uid:       "6b1f3c1e-0b6a-4a7e-8d0a-1c2b3d4e5f60", ok: true,
+		},
+		{
+			name:      "block volume publish target",
+			mountPath: "/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/publish/pvc-1/6b1f3c1e-0b6a-4a7e-8d0a-1c2b3d4e5f60",
+			uid:       "6b1f3c1e-0b6a-4a7e-8d0a-1c2b3d4e5f60", ok: true,
+		},
+		{
+			name:      "staging path",
+			mountPath: "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-1/globalmount",
+		},
+		{
+			name:      "mounted by hand",
+			mountPath: "/mnt/data",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uid, ok := parsePodUID("/var/lib/kubelet", tt.mountPath)
+			if uid != tt.uid || ok != tt.ok {
+				t.Errorf("parsePodUID() = %q, %v, want %q, %v", uid, ok, tt.uid, tt.ok)
+			}
+		})
+	}
+}
+
+func Test_deviceMounts(t *testing.T) {
+	mounts := []mount.MountInfo{
+		{Major: 0, Minor: 5, Root: "/", FsType: "devtmpfs", MountPoint: "/dev"},
+		{Major: 8, Minor: 19, Root: "/", FsType: "ext4", MountPoint: "/var/lib/kubelet/pods/uid-1/volumes/kubernetes.io~csi/pvc-1/mount"},
+		{Major: 8, Minor: 19, Root: "/", FsType: "ext4", MountPoint: "/mnt/data"},
+		{Major: 8, Minor: 20, Root: "/", FsType: "xfs", MountPoint: "/var/lib/kubelet/pods/uid-2/volumes/kubernetes.io~csi/pvc-2/mount"},
+		{Major: 0, Minor: 5, Root: "/sdb3", FsType: "devtmpfs", MountPoint: "/var/lib/kubelet/pods/uid-3/volumeDevices/kubernetes.io~csi/pvc-1"},
+		{Major: 0, Minor: 5, Root: "/sdb4", FsType: "devtmpfs", MountPoint: "/var/lib/kubelet/pods/uid-4/volumeDevices/kubernetes.io~csi/pvc-2"},
+	}
+	want := []string{
+		"/var/lib/kubelet/pods/uid-1/volumes/kubernetes.io~csi/pvc-1/mount",
+		"/mnt/data",
+		"/var/lib/kubelet/pods/uid-3/volumeDevices/kubernetes.io~csi/pvc-1",
+	}
+	if got := deviceMounts(mounts, 8, 19, "sdb3"); !reflect.DeepEqual(got, want) {
+		t.Errorf("deviceMounts() = %v, want %v", got, want)
+	}
+	if got := deviceMounts(mounts, 8, 21, "sdb5"); got != nil {
+		t.Errorf("deviceMounts() = %v, want no mounts", got)
+	}
+}
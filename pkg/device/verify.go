@@ -0,0 +1,100 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// Partition table integrity commands
+const (
+	PartitionVerify = "sgdisk --verify %s"
+	// rewriting the GPT makes sgdisk regenerate the damaged header and
+	// partition entries from the valid copy, moving the backup to the end
+	// of the disk.
+	PartitionRepair = "sgdisk --move-second-header %s"
+)
+
+// PartitionTableError is returned when the partition table of a disk fails
+// the verification. The disk is not modified till the table is repaired.
+type PartitionTableError struct {
+	Disk     string
+	Problems string
+}
+
+func (e *PartitionTableError) Error() string {
+	return fmt.Sprintf("partition table of disk %s failed verification, not modifying it, "+
+		"repair it with `device-driver repair-partition-table %s`: %s", e.Disk, e.Disk, e.Problems)
+}
+
+// verifyPartitionTable checks the primary and backup GPT of the disk before
+// modifying it, as sgdisk may fix a damaged table in surprising ways.
+func verifyPartitionTable(disk string) error {
+	out, err := RunCommand(strings.Split(fmt.Sprintf(PartitionVerify, devicePath(disk)), " "))
+	if err != nil {
+		return &PartitionTableError{Disk: disk, Problems: err.Error()}
+	}
+	if problems, ok := parseVerifyOutput(out); !ok {
+		return &PartitionTableError{Disk: disk, Problems: problems}
+	}
+	return nil
+}
+
+// parseVerifyOutput parses the output of sgdisk --verify. It returns the
+// problems found and false if the table is damaged.
+func parseVerifyOutput(out string) (string, bool) {
+	if strings.Contains(out, "No problems found") {
+		return "", true
+	}
+	var problems []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Problem:") || strings.HasPrefix(line, "Caution:") ||
+			strings.HasPrefix(line, "Warning!") {
+			problems = append(problems, line)
+		}
+	}
+	if len(problems) == 0 {
+		return truncateOutput(strings.TrimSpace(out)), false
+	}
+	return strings.Join(problems, " "), false
+}
+
+// RepairPartitionTable rewrites the partition table of the disk from its
+// valid copy and verifies it again. It is run by the operator after
+// checking that the valid copy is the one to keep, e.g. after backing up
+// the table with `sgdisk --backup`.
+func RepairPartitionTable(disk string) error {
+	if err := verifyPartitionTable(disk); err == nil {
+		klog.Infof("partition table of disk %s has no problems, nothing to repair", disk)
+		return nil
+	}
+	if err := auditOperation(AuditOperationRepair, disk, 0, "", func() error {
+		_, err := RunCommand(strings.Split(fmt.Sprintf(PartitionRepair, devicePath(disk)), " "))
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := verifyPartitionTable(disk); err != nil {
+		return err
+	}
+	klog.Infof("repaired partition table of disk %s", disk)
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_parseVerifyOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		out      string
+		ok       bool
+		problems string
+	}{
+		{
+			name: "valid table",
+			out: "No problems found. 2014 free sectors (1007.0 KiB) available in 1\n" +
+				"segments, the largest of which is 2014 (1007.0 KiB) in size.\n",
+			ok: true,
+		},
+		{
+			name: "header mismatch",
+			out: "Caution: invalid backup GPT header, but valid main header; regenerating\n" +
+				"backup header from main header.\n\n" +
+				"Problem: The CRC for the backup partition table is invalid. This table may\n" +
+				"be corrupt. This program will automatically create a new backup partition\n\n" +
+				"Identified 1 problems!\n",
+			ok: false,
+			problems: "Caution: invalid backup GPT header, but valid main header; regenerating " +
+				"Problem: The CRC for the backup partition table is invalid. This table may",
+		},
+		{
+			name:     "unknown output",
+			out:      "Identified 2 problems!\n",
+			ok:       false,
+			problems: "Identified 2 problems!",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems, ok := parseVerifyOutput(tt.out)
+			if ok != tt.ok {
+				t.Errorf("parseVerifyOutput() ok = %v, want %v", ok, tt.ok)
+			}
+			if problems != tt.problems {
+				t.Errorf("parseVerifyOutput() problems = %q, want %q", problems, tt.problems)
+			}
+		})
+	}
+}
+
+func Test_PartitionTableError(t *testing.T) {
+	err := &PartitionTableError{Disk: "sdb", Problems: "Identified 1 problems!"}
+	if !strings.Contains(err.Error(), "repair-partition-table sdb") {
+		t.Errorf("expected the error to point to the repair command, got %q", err.Error())
+	}
+}
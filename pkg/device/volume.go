@@ -50,14 +50,42 @@ const (
 	DeviceStatusFailed string = "Failed"
 	// DeviceStatusReady shows object has been processed
 	DeviceStatusReady string = "Ready"
+	// DeviceStatusReserved shows the partition of a standby volume has
+	// been created, but the volume is not activated yet
+	DeviceStatusReserved string = "Reserved"
 	// OpenEBSCasTypeKey for the cas-type label
 	OpenEBSCasTypeKey string = "openebs.io/cas-type"
 	// LocalDeviceCasTypeName for the name of the cas-type
 	LocalDeviceCasTypeName string = "localpv-device"
+	// QuarantinedDevicesKey is the DeviceNode annotation listing the comma
+	// separated names of the devices which are marked as bad on the node
+	QuarantinedDevicesKey string = "device.openebs.io/quarantined-devices"
+	// DeviceWeightsKey is the DeviceNode annotation listing the comma
+	// separated device=weight pairs setting the scheduling weights of the
+	// devices, referred by their UUID or name
+	DeviceWeightsKey string = "device.openebs.io/device-weights"
+	// ReplacementAcknowledgedKey is the DeviceVolume annotation set by the
+	// operators to acknowledge that the disk of the volume got replaced and
+	// the data of the volume is lost
+	ReplacementAcknowledgedKey string = "device.openebs.io/replacement-acknowledged"
+	// PVCNameKey is the DeviceVolume annotation recording the name of the
+	// PVC the volume got provisioned for
+	PVCNameKey string = "device.openebs.io/pvc-name"
+	// PVCNamespaceKey is the DeviceVolume annotation recording the
+	// namespace of the PVC the volume got provisioned for
+	PVCNamespaceKey string = "device.openebs.io/pvc-namespace"
+	// AdoptedKey is the DeviceVolume annotation marking the volumes created
+	// for the existing partitions rather than provisioned by the driver
+	AdoptedKey string = "device.openebs.io/adopted"
+	// RelocateToKey is the DeviceVolume annotation set by the operators to
+	// move the partition of the volume to the named disk of the node
+	RelocateToKey string = "device.openebs.io/relocate-to"
 )
 
 var (
-	// DeviceNamespace is openebs system namespace
+	// DeviceNamespace is openebs system namespace, holding the DeviceNode
+	// and DeviceVolume objects of the install. It is read from the
+	// environment and can be overridden by the --namespace flag.
 	DeviceNamespace string
 
 	// NodeID is the NodeID of the node on which the pod is present
@@ -69,10 +97,9 @@ var (
 
 func init() {
 
+	// the namespace may also be set by the flag, it is checked once the
+	// flags are parsed.
 	DeviceNamespace = os.Getenv(DeviceNamespaceKey)
-	if DeviceNamespace == "" && os.Getenv("OPENEBS_NODE_DRIVER") != "" {
-		klog.Fatalf("DEVICE_DRIVER_NAMESPACE environment variable not set")
-	}
 	NodeID = os.Getenv("OPENEBS_NODE_ID")
 	if NodeID == "" && os.Getenv("OPENEBS_NODE_DRIVER") != "" {
 		klog.Fatalf("NodeID environment variable not set")
@@ -113,6 +140,22 @@ func GetDeviceVolume(volumeID string) (*apis.DeviceVolume, error) {
 	return vol, err
 }
 
+// ListDeviceVolumes fetches all the DeviceVolumes
+func ListDeviceVolumes() (*apis.DeviceVolumeList, error) {
+	listOptions := metav1.ListOptions{}
+	return volbuilder.NewKubeclient().
+		WithNamespace(DeviceNamespace).List(listOptions)
+}
+
+// ListNodeDeviceVolumes fetches the DeviceVolumes created on the given node
+func ListNodeDeviceVolumes(node string) (*apis.DeviceVolumeList, error) {
+	listOptions := metav1.ListOptions{
+		LabelSelector: DeviceNodeKey + "=" + node,
+	}
+	return volbuilder.NewKubeclient().
+		WithNamespace(DeviceNamespace).List(listOptions)
+}
+
 // GetDeviceVolumeState returns DeviceVolume OwnerNode and State for
 // the given volume. CreateVolume request may call it again and
 // again until volume is "Ready".
@@ -139,7 +182,7 @@ func UpdateVolInfo(vol *apis.DeviceVolume) error {
 
 	newVol, err := volbuilder.BuildFrom(vol).
 		WithFinalizer(finalizers).
-		WithVolumeStatus(DeviceStatusReady).
+		WithVolumeStatus(getCreatedState(vol)).
 		WithLabels(labels).Build()
 
 	if err != nil {
@@ -150,6 +193,38 @@ func UpdateVolInfo(vol *apis.DeviceVolume) error {
 	return err
 }
 
+// UpdateVolume updates the given DeviceVolume
+func UpdateVolume(vol *apis.DeviceVolume) error {
+	_, err := volbuilder.NewKubeclient().WithNamespace(DeviceNamespace).Update(vol)
+	return err
+}
+
+// GetVolumeCondition returns the condition of the given type of the volume,
+// nil if the volume doesn't have it.
+func GetVolumeCondition(vol *apis.DeviceVolume,
+	condType apis.VolumeConditionType) *apis.VolumeCondition {
+	for i := range vol.Status.Conditions {
+		if vol.Status.Conditions[i].Type == condType {
+			return &vol.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// RemoveVolumeCondition removes the condition of the given type from the
+// volume. It returns true if the volume had the condition.
+func RemoveVolumeCondition(vol *apis.DeviceVolume, condType apis.VolumeConditionType) bool {
+	var conditions []apis.VolumeCondition
+	for _, cond := range vol.Status.Conditions {
+		if cond.Type != condType {
+			conditions = append(conditions, cond)
+		}
+	}
+	removed := len(conditions) != len(vol.Status.Conditions)
+	vol.Status.Conditions = conditions
+	return removed
+}
+
 // RemoveVolFinalizer adds finalizer to DeviceVolume CR
 func RemoveVolFinalizer(vol *apis.DeviceVolume) error {
 	vol.Finalizers = nil
@@ -159,7 +234,7 @@ func RemoveVolFinalizer(vol *apis.DeviceVolume) error {
 }
 
 // WaitForDeviceVolumeProcessed waits till the device volume becomes
-// ready or failed (i.e reaches to terminal state).
+// ready, reserved or failed (i.e reaches to terminal state).
 func WaitForDeviceVolumeProcessed(ctx context.Context, volumeID string) (*apis.DeviceVolume, error) {
 	timer := time.NewTimer(0)
 	defer timer.Stop()
@@ -175,6 +250,7 @@ func WaitForDeviceVolumeProcessed(ctx context.Context, volumeID string) (*apis.D
 				"device: wait failed, not able to get the volume %s %s", volumeID, err.Error())
 		}
 		if vol.Status.State == DeviceStatusReady ||
+			vol.Status.State == DeviceStatusReserved ||
 			vol.Status.State == DeviceStatusFailed {
 			return vol, nil
 		}
@@ -0,0 +1,177 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+// DefaultDeviceWeight is the scheduling weight of the devices without a
+// weight in the device weights annotation.
+const DefaultDeviceWeight int32 = 100
+
+// ParseDeviceWeights parses the device weights annotation of a DeviceNode,
+// the comma separated device=weight pairs, the devices referred by their
+// UUID or by their name. The invalid pairs are ignored.
+func ParseDeviceWeights(annotation string) map[string]int32 {
+	weights := map[string]int32{}
+	for _, pair := range strings.Split(annotation, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			klog.Warningf("ignoring device weight %q, it is not of the form device=weight", pair)
+			continue
+		}
+		dev := strings.TrimSpace(kv[0])
+		weight, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 32)
+		if dev == "" || err != nil || weight < 0 {
+			klog.Warningf("ignoring invalid device weight %q", pair)
+			continue
+		}
+		weights[dev] = int32(weight)
+	}
+	return weights
+}
+
+// DeviceWeight returns the weight of the device of the given UUID and
+// name. The UUID takes precedence over the name.
+func DeviceWeight(weights map[string]int32, uuid, name string) int32 {
+	if weight, ok := weights[uuid]; ok && uuid != "" {
+		return weight
+	}
+	if weight, ok := weights[name]; ok && name != "" {
+		return weight
+	}
+	return DefaultDeviceWeight
+}
+
+// deviceWeights holds the scheduling weights of the devices set in the
+// DeviceNode, by their UUID or name.
+var deviceWeights = struct {
+	sync.RWMutex
+	devices map[string]int32
+}{}
+
+// SetDeviceWeights sets the scheduling weights of the devices, referred by
+// their UUID or by their name. The allocator prefers the disks of higher
+// weight and never places new partitions on the disks of weight zero.
+func SetDeviceWeights(weights map[string]int32) {
+	deviceWeights.Lock()
+	defer deviceWeights.Unlock()
+	deviceWeights.devices = weights
+}
+
+func hasDeviceWeights() bool {
+	deviceWeights.RLock()
+	defer deviceWeights.RUnlock()
+	return len(deviceWeights.devices) > 0
+}
+
+// diskWeight returns the scheduling weight of the disk. The disk is
+// identified only when there are weights set, falling back to the default
+// weight if it can't be.
+func diskWeight(diskName string) int32 {
+	if !hasDeviceWeights() {
+		return DefaultDeviceWeight
+	}
+	name, _ := getDiskMetaName(diskName)
+	uuid, _ := getDiskIdentifier(diskName)
+	deviceWeights.RLock()
+	defer deviceWeights.RUnlock()
+	return DeviceWeight(deviceWeights.devices, uuid, name)
+}
+
+// weighDisks narrows the free regions down to the disks preferred by their
+// weights, for the placement policy to pick from. It is a no-op when no
+// weights are set.
+func weighDisks(pList []partFree, partSize uint64) []partFree {
+	if !hasDeviceWeights() || len(pList) == 0 {
+		return pList
+	}
+	diskList, err := getDiskList()
+	if err != nil {
+		klog.Warningf("not weighing the disks, could not list them: %v", err)
+		return pList
+	}
+	sizes := map[string]uint64{}
+	for _, disk := range diskList {
+		sizes[disk.DiskName] = disk.Size
+	}
+	weights := map[string]int32{}
+	for _, region := range pList {
+		if _, ok := weights[region.DiskName]; !ok {
+			weights[region.DiskName] = diskWeight(region.DiskName)
+		}
+	}
+	return selectWeightedDisks(pList, sizes, weights, partSize)
+}
+
+// selectWeightedDisks keeps the free regions of the disks of the highest
+// score among the disks which can hold a partition of partSize MiB. The
+// score of a disk is its weight scaled by the fraction of its size which
+// is free, so that a disk of higher weight is preferred until it is
+// relatively fuller than the others. The regions are kept as they are if
+// the disks which can hold the partition all have the same weight, leaving
+// the choice to the placement policy.
+func selectWeightedDisks(pList []partFree, sizes map[string]uint64,
+	weights map[string]int32, partSize uint64) []partFree {
+	diskFree := map[string]uint64{}
+	diskRegions := map[string][]partFree{}
+	for _, region := range pList {
+		diskFree[region.DiskName] += region.SizeMiB
+		diskRegions[region.DiskName] = append(diskRegions[region.DiskName], region)
+	}
+
+	scores := map[string]float64{}
+	weighted := false
+	for disk, regions := range diskRegions {
+		if _, ok := selectFreeRegion(regions, partSize); !ok {
+			continue
+		}
+		for other := range scores {
+			weighted = weighted || weights[other] != weights[disk]
+		}
+		freeFraction := 1.0
+		if sizeMiB := sizes[disk] / PartitionAlignmentBytes; sizeMiB > 0 {
+			freeFraction = float64(diskFree[disk]) / float64(sizeMiB)
+		}
+		scores[disk] = float64(weights[disk]) * freeFraction
+	}
+	if !weighted {
+		return pList
+	}
+
+	var best float64
+	for _, score := range scores {
+		if score > best {
+			best = score
+		}
+	}
+	var preferred []partFree
+	for _, region := range pList {
+		if score, ok := scores[region.DiskName]; ok && score == best {
+			preferred = append(preferred, region)
+		}
+	}
+	return preferred
+}
@@ -0,0 +1,115 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package device
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ParseDeviceWeights(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       map[string]int32
+	}{
+		{name: "empty", annotation: "", want: map[string]int32{}},
+		{name: "uuid and name", annotation: "uuid-1=200, slow=0", want: map[string]int32{"uuid-1": 200, "slow": 0}},
+		{name: "trailing comma", annotation: "fast=50,", want: map[string]int32{"fast": 50}},
+		{name: "invalid pairs ignored", annotation: "fast,slow=-1,=10,nvme=x,ssd=20", want: map[string]int32{"ssd": 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseDeviceWeights(tt.annotation); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDeviceWeights() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_DeviceWeight(t *testing.T) {
+	weights := map[string]int32{"uuid-1": 300, "fast": 0}
+	tests := []struct {
+		name string
+		uuid string
+		dev  string
+		want int32
+	}{
+		{name: "by uuid", uuid: "uuid-1", dev: "fast", want: 300},
+		{name: "by name", uuid: "uuid-2", dev: "fast", want: 0},
+		{name: "default", uuid: "uuid-3", dev: "slow", want: DefaultDeviceWeight},
+		{name: "unknown disk", want: DefaultDeviceWeight},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeviceWeight(weights, tt.uuid, tt.dev); got != tt.want {
+				t.Errorf("DeviceWeight() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_selectWeightedDisks(t *testing.T) {
+	// two disks of the same size, sdb of twice the weight of sdc.
+	pList := []partFree{
+		{"sdb", 2, 1002, 1000},
+		{"sdc", 2, 1002, 1000},
+	}
+	sizes := map[string]uint64{"sdb": 1000 << 20, "sdc": 1000 << 20}
+	weights := map[string]int32{"sdb": 200, "sdc": 100}
+
+	allocate := func(size uint64) string {
+		region, ok := selectFreeRegion(selectWeightedDisks(pList, sizes, weights, size), size)
+		if !ok {
+			t.Fatalf("no region found for %d MiB", size)
+		}
+		for i := range pList {
+			if pList[i] == region {
+				pList[i].StartMiB += size
+				pList[i].SizeMiB -= size
+			}
+		}
+		return region.DiskName
+	}
+
+	// sdb is preferred till it is half full, the smallest fitting region
+	// breaking the tie at half.
+	for i := 0; i < 6; i++ {
+		if disk := allocate(100); disk != "sdb" {
+			t.Fatalf("allocation %d on %s, want sdb", i, disk)
+		}
+	}
+	if disk := allocate(100); disk != "sdc" {
+		t.Errorf("allocation on %s once sdb got relatively fuller, want sdc", disk)
+	}
+
+	// a disk which can't hold the partition is not preferred
+	if disk := allocate(500); disk != "sdc" {
+		t.Errorf("large allocation on %s, want sdc", disk)
+	}
+
+	// the same weights leave the choice to the placement policy
+	same := map[string]int32{"sdb": 100, "sdc": 100}
+	if got := selectWeightedDisks(pList, sizes, same, 100); !reflect.DeepEqual(got, pList) {
+		t.Errorf("selectWeightedDisks() with the same weights = %v, want %v", got, pList)
+	}
+
+	// no disk can hold the partition
+	if got := selectWeightedDisks(pList, sizes, weights, 5000); !reflect.DeepEqual(got, pList) {
+		t.Errorf("selectWeightedDisks() with no fitting disk = %v, want %v", got, pList)
+	}
+}
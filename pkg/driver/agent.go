@@ -18,14 +18,16 @@ package driver
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/openebs/device-localpv/pkg/collector"
 	"github.com/openebs/device-localpv/pkg/config"
 	k8sapi "github.com/openebs/lib-csi/pkg/client/k8s"
-	"github.com/openebs/lib-csi/pkg/mount"
+	"github.com/openebs/lib-csi/pkg/common/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -33,7 +35,12 @@ import (
 	"golang.org/x/sys/unix"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
 
@@ -48,6 +55,20 @@ import (
 // for CSI NodeServer
 type node struct {
 	driver *CSIDriver
+
+	// statsCache caches the recent volume stats
+	statsCache *volumeStatsCache
+
+	// idmappedMounts denotes that the kernel supports idmapped mounts
+	// and they are enabled
+	idmappedMounts bool
+
+	// fsckOnMount denotes that the dirty ext filesystems are checked
+	// before mounting them
+	fsckOnMount bool
+
+	// recorder records the events on the volumes
+	recorder record.EventRecorder
 }
 
 // NewNode returns a new instance
@@ -58,42 +79,167 @@ func NewNode(d *CSIDriver) csi.NodeServer {
 	// set up signals so we handle the first shutdown signal gracefully
 	stopCh := signals.SetupSignalHandler()
 
+	if err := checkWritablePaths(getWritablePaths(d.config), checkWritable); err != nil {
+		klog.Fatalf("Preflight check failed: %s", err.Error())
+	}
+
+	device.SetCommandHistorySize(d.config.CommandHistorySize)
+	device.SetCommandLimits(d.config.MaxConcurrentCommands, d.config.CommandTimeout, d.config.MutatingCommandTimeout)
+	if err := device.SetMaxConcurrentFormats(d.config.MaxConcurrentFormats); err != nil {
+		klog.Fatalf("Failed to set up the format limit: %s", err.Error())
+	}
+	if err := device.SetFormatHooks(d.config.FormatHooksDir, d.config.PreFormatHook, d.config.PostFormatHook); err != nil {
+		klog.Fatalf("Failed to set up the format hooks: %s", err.Error())
+	}
+	device.SetSlowReconcileThreshold(d.config.SlowReconcileThreshold)
+	device.SetMediaBenchmark(d.config.MediaTypeBenchmark)
+	device.SetExclusiveCheck(d.config.ExclusiveDeviceCheck)
+	if err := device.SetMinFreeRegion(d.config.MinFreeRegion); err != nil {
+		klog.Fatalf("Failed to set up the allocator: %s", err.Error())
+	}
+	if err := device.SetProtectedLeadingBytes(d.config.ProtectedLeadingBytes); err != nil {
+		klog.Fatalf("Failed to set up the allocator: %s", err.Error())
+	}
+	if err := device.SetMinVolumeSize(d.config.MinVolumeSize); err != nil {
+		klog.Fatalf("Failed to set up the allocator: %s", err.Error())
+	}
+	device.SetSkipFullDevices(d.config.SkipFullDevices)
+	if err := device.SetDeviceReadyDiscoveries(d.config.DeviceReadyDiscoveries); err != nil {
+		klog.Fatalf("Failed to set up the allocator: %s", err.Error())
+	}
+	if err := device.SetHotTemperature(d.config.HotDiskTemperature); err != nil {
+		klog.Fatalf("Failed to set up the allocator: %s", err.Error())
+	}
+	device.SetAllowForeignSignatures(d.config.AllowForeignSignatures)
+	if err := device.SetDeviceRoots(d.config.DevRoot, d.config.SysRoot); err != nil {
+		klog.Fatalf("Failed to set up the device paths: %s", err.Error())
+	}
+	if err := device.SetPartitioner(d.config.Partitioner); err != nil {
+		klog.Fatalf("Failed to set up the partitioner: %s", err.Error())
+	}
+	if err := device.SetAuditLog(d.config.AuditLog); err != nil {
+		klog.Fatalf("Failed to set up the audit log: %s", err.Error())
+	}
+	if err := device.InitDiskDiscovery(d.config.DiskDiscovery); err != nil {
+		klog.Fatalf("Failed to set up disk discovery: %s", err.Error())
+	}
+	if err := device.SetHostMountNamespace(d.config.HostMountNamespace); err != nil {
+		klog.Fatalf("Failed to set up host mount namespace: %s", err.Error())
+	}
+
 	// start the device node resource watcher
 	go func() {
-		err := devicenode.Start(&ControllerMutex, stopCh)
+		err := devicenode.Start(&ControllerMutex, d.config.DeviceVerifyInterval,
+			d.config.DeviceMissingGracePeriod, d.config.DeviceNodeOwner,
+			d.config.TopologyLabels, stopCh)
 		if err != nil {
 			klog.Fatalf("Failed to start Device node controller: %s", err.Error())
 		}
 	}()
 
+	threadiness, err := volume.ResolveThreadiness(d.config.Threadiness, device.CountDisks())
+	if err != nil {
+		klog.Fatalf("Failed to start Device volume management controller: %s", err.Error())
+	}
+
 	// start the device volume  watcher
 	go func() {
-		err := volume.Start(&ControllerMutex, stopCh)
+		err := volume.Start(&ControllerMutex, threadiness, d.config.MaxCreateFailures,
+			d.config.ResyncRate, d.config.BackfillRate, stopCh)
 		if err != nil {
 			klog.Fatalf("Failed to start Device volume management controller: %s", err.Error())
 		}
 	}()
 
+	statsCache := newVolumeStatsCache(d.config.VolumeStatsCacheTTL)
+
+	idmappedMounts := d.config.IDMappedMounts && device.ProbeIDMappedMounts()
+
+	if d.config.TrimInterval > 0 {
+		go runTrimmer(d.config.TrimInterval, stopCh)
+	}
+
+	if d.config.StaleMountCheckInterval > 0 {
+		go runStaleMountChecker(d.config.KubeletDir, d.config.StaleMountCheckInterval,
+			d.config.StaleMountThreshold, stopCh)
+	}
+
+	if d.config.ReadinessGate {
+		d.readiness = newReadinessGate(d.config.DriverName, d.config.NodeID)
+	}
+
 	if d.config.ListenAddress != "" {
-		exposeMetrics(d.config, stopCh)
+		http.Handle(ReadyzPath, d.readiness)
+		exposeMetrics(d.config, stopCh, statsCache, devicenode.DriftTotal, TrimmedBytesTotal,
+			StaleMounts, device.ReconcileDuration, devicenode.WorkqueueMetrics, devicenode.TrackedDevices,
+			devicenode.DiscoveryDuration, devicenode.DiscoveredDevices,
+			devicenode.FullDevices, devicenode.DeviceEfficiency, device.FormatsInFlight, device.FormatWaitDuration,
+			devicenode.ClassSlots)
+	}
+
+	if d.config.DebugAddress != "" {
+		startDebugServer(d.config.DebugAddress, map[string]http.HandlerFunc{
+			DebugStatePath:  device.DebugStateHandler,
+			DebugResyncPath: volume.ResyncHandler,
+		})
+	}
+
+	if d.config.FsckOnMount {
+		device.SetFsckTimeout(d.config.FsckTimeout)
+	}
+	device.SetMountRetry(d.config.MountRetries, d.config.MountRetryBackoff)
+	// the failed filesystem checks and mounts are recorded on the volumes
+	recorder, err := newEventRecorder()
+	if err != nil {
+		klog.Fatalf("Failed to set up event recorder: %s", err.Error())
 	}
 
 	return &node{
-		driver: d,
+		driver:         d,
+		statsCache:     statsCache,
+		idmappedMounts: idmappedMounts,
+		fsckOnMount:    d.config.FsckOnMount,
+		recorder:       recorder,
 	}
 }
 
+// newEventRecorder returns a recorder of the events on the volumes of the
+// node.
+func newEventRecorder() (record.EventRecorder, error) {
+	cfg, err := k8sapi.Config().Get()
+	if err != nil {
+		return nil, errors.Wrap(err, "error building kubeconfig")
+	}
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "error building kubernetes clientset")
+	}
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme,
+		corev1.EventSource{Component: "device-node-agent", Host: device.NodeID}), nil
+}
+
+// CommandHistoryPath is the http path where the last executed commands
+// are exposed, if the command history is enabled.
+const CommandHistoryPath = "/debug/commands"
+
 type promErrorLog struct{}
 
 func (p *promErrorLog) Println(v ...interface{}) {
 	klog.Errorln(v...)
 }
 
-func exposeMetrics(c *config.Config, stopCh <-chan struct{}) {
+func exposeMetrics(c *config.Config, stopCh <-chan struct{}, cs ...prometheus.Collector) {
 	registry := prometheus.NewRegistry()
 	if err := registry.Register(collector.NewDeviceCollector(stopCh)); err != nil {
 		klog.Fatalf("failed to register device metrics collector: %v", err)
 	}
+	for _, col := range cs {
+		if err := registry.Register(col); err != nil {
+			klog.Fatalf("failed to register metrics collector: %v", err)
+		}
+	}
 	if !c.DisableExporterMetrics {
 		if err := registry.Register(collectors.NewProcessCollector(
 			collectors.ProcessCollectorOpts{})); err != nil {
@@ -106,6 +252,9 @@ func exposeMetrics(c *config.Config, stopCh <-chan struct{}) {
 
 	http.Handle(c.MetricsPath, promhttp.InstrumentMetricHandler(registry,
 		promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: &promErrorLog{}})))
+	if c.CommandHistorySize > 0 {
+		http.HandleFunc(CommandHistoryPath, device.CommandHistoryHandler)
+	}
 	http.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
 		_, _ = w.Write([]byte(`<html>
                                <head><title>Device Exporter</title></head>
@@ -133,7 +282,7 @@ func GetVolAndMountInfo(
 	mountinfo.MountPath = req.GetTargetPath()
 	mountinfo.MountOptions = append(mountinfo.MountOptions, req.GetVolumeCapability().GetMount().GetMountFlags()...)
 
-	if req.GetReadonly() {
+	if req.GetReadonly() || isReadOnlyAccessMode(req.GetVolumeCapability().GetAccessMode().GetMode()) {
 		mountinfo.MountOptions = append(mountinfo.MountOptions, "ro")
 	}
 
@@ -167,26 +316,91 @@ func (ns *node) NodePublishVolume(
 	if err = ns.validateNodePublishReq(req); err != nil {
 		return nil, err
 	}
+	if err = ns.driver.readiness.check(); err != nil {
+		return nil, err
+	}
 
 	vol, mountInfo, err := GetVolAndMountInfo(req)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	if err = device.CheckVolumeActive(vol); err != nil {
+		return nil, err
+	}
+	if err = device.CheckVolumeNotRelocating(vol); err != nil {
+		return nil, err
+	}
+
+	// the device operations are aborted once the sidecar gives up on the
+	// request, so that its retries don't run along with them.
+	ctx, cancel := operationContext(ctx, ns.driver.config.OperationTimeout)
+	defer cancel()
 
 	switch req.GetVolumeCapability().GetAccessType().(type) {
 	case *csi.VolumeCapability_Mount:
-		err = device.MountFilesystem(vol, mountInfo)
+		if ns.idmappedMounts && !device.SupportsIDMappedMounts(mountInfo.FSType) {
+			klog.Infof("idmapped mounts are not supported for %s volume %s, "+
+				"ownership is left to the fsGroup handling of kubelet", mountInfo.FSType, vol.Name)
+		}
+		// an unformatted volume has no filesystem to check yet
+		if ns.fsckOnMount && !vol.Status.Unformatted {
+			if err = ns.checkFilesystem(ctx, vol, mountInfo.FSType); err != nil {
+				return nil, err
+			}
+		}
+		err = device.MountFilesystem(ctx, vol, mountInfo)
 	case *csi.VolumeCapability_Block:
 		err = device.MountBlock(vol, mountInfo)
 	}
 
 	if err != nil {
+		// the corrupt or unsupported filesystems are not fixed by the
+		// retries of kubelet, the operators have to step in.
+		if device.IsUnmountable(err) {
+			ns.recorder.Event(vol, corev1.EventTypeWarning, device.FilesystemUnmountableReason, err.Error())
+		}
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
+// operationContext bounds the device operations of a request by the
+// timeout, on top of the deadline of the request. Zero disables the
+// timeout.
+func operationContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// checkFilesystem repairs the filesystem of the volume if it is marked
+// dirty, recording an event when a repair runs so that the operators know
+// the recovery happened.
+func (ns *node) checkFilesystem(ctx context.Context, vol *apis.DeviceVolume, fsType string) error {
+	devicePath, err := device.GetVolumeDevPath(vol)
+	if err != nil {
+		return status.Error(codes.Internal, "Not able to find the device Path")
+	}
+	repaired, err := device.CheckFilesystem(ctx, devicePath, fsType)
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		ns.recorder.Event(vol, corev1.EventTypeWarning, "FilesystemCheckFailed", err.Error())
+		return status.Errorf(codes.Internal, "filesystem check of volume %s failed: %v", vol.Name, err)
+	}
+	if repaired {
+		ns.recorder.Eventf(vol, corev1.EventTypeWarning, "FilesystemRepaired",
+			"filesystem on %s was not clean and got repaired by e2fsck", devicePath)
+	}
+	return nil
+}
+
 // NodeUnpublishVolume unpublishes (unmounts) the volume
 // from the corresponding node from the given path
 //
@@ -221,6 +435,7 @@ func (ns *node) NodeUnpublishVolume(
 			"unable to umount the volume %s err : %s",
 			volumeID, err.Error())
 	}
+	ns.statsCache.invalidate(volumeID)
 	klog.Infof("hostpath: volume %s path: %s has been unmounted.",
 		volumeID, targetPath)
 
@@ -258,14 +473,23 @@ func (ns *node) NodeGetInfo(
 	 * }
 	 */
 
-	// support all the keys that node has
-	topology := node.Labels
+	// support all the keys that node has, or the configured ones
+	topology := topologySegments(node.Labels, ns.driver.config.TopologyLabels)
 
 	// add driver's topology key
 	topology[device.DeviceTopologyKey] = ns.driver.config.NodeID
 
+	// let user namespaced pods get scheduled to the nodes where the
+	// volumes can be idmapped instead of chowned. The key is reported by
+	// every node, as the topology keys of the driver have to be the same
+	// on all the nodes.
+	topology[device.IDMappedMountsTopologyKey] = strconv.FormatBool(ns.idmappedMounts)
+
 	return &csi.NodeGetInfoResponse{
 		NodeId: ns.driver.config.NodeID,
+		// kubelet keeps the scheduler from placing more volumes on the node
+		// than advertised, zero leaves them unlimited.
+		MaxVolumesPerNode: ns.driver.config.MaxVolumesPerNode,
 		AccessibleTopology: &csi.Topology{
 			Segments: topology,
 		},
@@ -364,7 +588,11 @@ func (ns *node) NodeGetVolumeStats(
 		return nil, status.Error(codes.InvalidArgument, "path is not provided")
 	}
 
-	if mount.IsMountPath(path) == false {
+	if usage, ok := ns.statsCache.get(volID, path); ok {
+		return &csi.NodeGetVolumeStatsResponse{Usage: usage}, nil
+	}
+
+	if device.IsMountPath(path) == false {
 		return nil, status.Error(codes.NotFound, "path is not a mount path")
 	}
 
@@ -373,19 +601,15 @@ func (ns *node) NodeGetVolumeStats(
 		return nil, status.Errorf(codes.Internal, "statfs on %s failed: %v", path, err)
 	}
 
-	var usage []*csi.VolumeUsage
-	usage = append(usage, &csi.VolumeUsage{
-		Unit:      csi.VolumeUsage_BYTES,
-		Total:     int64(sfs.Blocks) * int64(sfs.Bsize),
-		Used:      int64(sfs.Blocks-sfs.Bfree) * int64(sfs.Bsize),
-		Available: int64(sfs.Bavail) * int64(sfs.Bsize),
-	})
-	usage = append(usage, &csi.VolumeUsage{
-		Unit:      csi.VolumeUsage_INODES,
-		Total:     int64(sfs.Files),
-		Used:      int64(sfs.Files - sfs.Ffree),
-		Available: int64(sfs.Ffree),
-	})
+	// report the usage against the project quota of the volume if any,
+	// the whole filesystem otherwise
+	quota, err := device.GetProjectQuota(path)
+	if err != nil {
+		klog.Warningf("could not get the project quota of %s: %v", path, err)
+	}
+
+	usage := getVolumeUsage(&sfs, quota)
+	ns.statsCache.set(volID, path, usage)
 
 	return &csi.NodeGetVolumeStatsResponse{Usage: usage}, nil
 }
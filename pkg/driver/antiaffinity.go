@@ -0,0 +1,49 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog"
+)
+
+// antiAffinityGroup returns the anti-affinity group of the volume, as the
+// anti-affinity label of its claim along with its value. It is empty if
+// the parameters set no anti-affinity label or the claim doesn't have it.
+func antiAffinityGroup(pvcs corelisters.PersistentVolumeClaimLister, params *VolumeParams) (string, error) {
+	if params.AntiAffinityLabel == "" {
+		return "", nil
+	}
+	if params.PVCName == "" {
+		return "", status.Errorf(codes.InvalidArgument,
+			"antiAffinityLabel needs the claim of the volume, run the csi-provisioner with --extra-create-metadata")
+	}
+	pvc, err := pvcs.PersistentVolumeClaims(params.PVCNamespace).Get(params.PVCName)
+	if err != nil {
+		return "", status.Errorf(codes.Unavailable, "failed to get claim %s/%s of the volume: %v",
+			params.PVCNamespace, params.PVCName, err)
+	}
+	value, ok := pvc.Labels[params.AntiAffinityLabel]
+	if !ok {
+		klog.Infof("claim %s/%s has no label %s, creating its volume without anti-affinity",
+			params.PVCNamespace, params.PVCName, params.AntiAffinityLabel)
+		return "", nil
+	}
+	return params.AntiAffinityLabel + "=" + value, nil
+}
@@ -0,0 +1,73 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestAntiAffinityGroup(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default", Name: "data-db-0", Labels: map[string]string{"app": "db"},
+	}}))
+	assert.NoError(t, indexer.Add(&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default", Name: "scratch",
+	}}))
+	pvcs := corelisters.NewPersistentVolumeClaimLister(indexer)
+
+	tests := map[string]struct {
+		params   VolumeParams
+		expected string
+		code     codes.Code
+	}{
+		"no anti-affinity": {
+			params: VolumeParams{PVCName: "data-db-0", PVCNamespace: "default"},
+		},
+		"labelled claim": {
+			params:   VolumeParams{AntiAffinityLabel: "app", PVCName: "data-db-0", PVCNamespace: "default"},
+			expected: "app=db",
+		},
+		"claim without the label": {
+			params: VolumeParams{AntiAffinityLabel: "app", PVCName: "scratch", PVCNamespace: "default"},
+		},
+		"claim not passed by the provisioner": {
+			params: VolumeParams{AntiAffinityLabel: "app"},
+			code:   codes.InvalidArgument,
+		},
+		"missing claim": {
+			params: VolumeParams{AntiAffinityLabel: "app", PVCName: "data-db-1", PVCNamespace: "default"},
+			code:   codes.Unavailable,
+		},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			group, err := antiAffinityGroup(pvcs, &test.params)
+			assert.Equal(t, test.code, status.Code(err))
+			assert.Equal(t, test.expected, group)
+		})
+	}
+}
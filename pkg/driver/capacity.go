@@ -0,0 +1,69 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// updateDeviceNode is the update event handler of the DeviceNodes. It
+// logs the capacity change of the devices of the node, e.g. after a LUN got
+// grown. GetCapacity reads the DeviceNode informer, so it reports the new
+// capacity right away, and the external provisioner, which owns the
+// CSIStorageCapacity objects, picks it up on its next poll.
+func (cs *controller) updateDeviceNode(oldObj, newObj interface{}) {
+	oldNode, ok := oldObj.(*apis.DeviceNode)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("couldn't get DeviceNode from %#v", oldObj))
+		return
+	}
+	newNode, ok := newObj.(*apis.DeviceNode)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("couldn't get DeviceNode from %#v", newObj))
+		return
+	}
+	if !deviceCapacityChanged(oldNode, newNode) {
+		return
+	}
+	klog.Infof("capacity of the devices of node %s changed, the provisioner "+
+		"republishes it on its next capacity poll", newNode.Name)
+}
+
+// deviceCapacityChanged checks whether the size of any of the devices of
+// the node changed, or devices got added or removed.
+func deviceCapacityChanged(oldNode, newNode *apis.DeviceNode) bool {
+	if len(oldNode.Devices) != len(newNode.Devices) {
+		return true
+	}
+	sizes := make(map[string]resource.Quantity, len(oldNode.Devices))
+	for _, dev := range oldNode.Devices {
+		sizes[dev.UUID] = dev.Size
+	}
+	for _, dev := range newNode.Devices {
+		size, ok := sizes[dev.UUID]
+		if !ok || size.Cmp(dev.Size) != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/config"
+)
+
+func TestCapacityOnDeviceGrowth(t *testing.T) {
+	oldNode := newTestDeviceNode("node-1", 10*Gi, 4*Gi)
+	cs := newTestController(t, oldNode, newTestDeviceNode("node-2", 10*Gi, 4*Gi))
+	cs.driver = &CSIDriver{config: &config.Config{DriverName: "device.csi.openebs.io"}}
+	k8sNodes := cache.NewSharedIndexInformer(&cache.ListWatch{}, &corev1.Node{}, 0, cache.Indexers{})
+	cs.k8sNodeInformer = k8sNodes
+	for _, name := range []string{"node-1", "node-2"} {
+		assert.NoError(t, k8sNodes.GetIndexer().Add(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Labels: map[string]string{"openebs.io/nodename": name},
+		}}))
+	}
+	getCapacity := func(node string) int64 {
+		resp, err := cs.GetCapacity(context.TODO(), &csi.GetCapacityRequest{
+			Parameters:         map[string]string{"devname": "test-device"},
+			AccessibleTopology: &csi.Topology{Segments: map[string]string{"openebs.io/nodename": node}},
+		})
+		assert.NoError(t, err)
+		return resp.GetAvailableCapacity()
+	}
+	assert.Equal(t, int64(4*Gi), getCapacity("node-1"))
+
+	// the LUN of node-1 grew by 6Gi, the next poll of the provisioner
+	// gets the grown capacity.
+	newNode := newTestDeviceNode("node-1", 16*Gi, 10*Gi)
+	assert.NoError(t, cs.deviceNodeInformer.GetIndexer().Update(newNode))
+	cs.updateDeviceNode(oldNode, newNode)
+	assert.Equal(t, int64(10*Gi), getCapacity("node-1"))
+	assert.Equal(t, int64(4*Gi), getCapacity("node-2"))
+}
+
+func TestDeviceCapacityChanged(t *testing.T) {
+	node := newTestDeviceNode("node-1", 10*Gi, 4*Gi)
+	tests := map[string]struct {
+		newNode *apis.DeviceNode
+		changed bool
+	}{
+		"unchanged":          {newNode: newTestDeviceNode("node-1", 10*Gi, 4*Gi), changed: false},
+		"free space changed": {newNode: newTestDeviceNode("node-1", 10*Gi, 2*Gi), changed: false},
+		"device grew":        {newNode: newTestDeviceNode("node-1", 12*Gi, 6*Gi), changed: true},
+		"device replaced":    {newNode: newTestDeviceNode("node-2", 10*Gi, 4*Gi), changed: true},
+		"device removed":     {newNode: &apis.DeviceNode{}, changed: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.changed, deviceCapacityChanged(node, tt.newNode))
+		})
+	}
+}
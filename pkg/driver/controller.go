@@ -18,7 +18,10 @@ package driver
 
 import (
 	"fmt"
+	"math"
+	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -38,6 +41,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
@@ -66,10 +70,18 @@ type controller struct {
 
 	indexedLabel string
 
-	k8sNodeInformer    cache.SharedIndexInformer
-	deviceNodeInformer cache.SharedIndexInformer
+	k8sNodeInformer     cache.SharedIndexInformer
+	deviceNodeInformer  cache.SharedIndexInformer
+	deviceQuotaInformer cache.SharedIndexInformer
 
 	leakProtection *csipv.LeakProtectionController
+
+	// pvcLister looks up the claims of the volumes for their labels
+	pvcLister corelisters.PersistentVolumeClaimLister
+
+	reservations *capacityReservations
+
+	extender *schedulerExtender
 }
 
 // NewController returns a new instance
@@ -78,21 +90,45 @@ func NewController(d *CSIDriver) csi.ControllerServer {
 	ctrl := &controller{
 		driver:       d,
 		capabilities: newControllerCapabilities(),
+		reservations: newCapacityReservations(),
+		extender: newSchedulerExtender(d.config.SchedulerExtender,
+			d.config.SchedulerExtenderTimeout, d.config.SchedulerExtenderFailOpen),
 	}
+	ctrl.reservations.nodeVersion = ctrl.getDeviceNodeVersion
 
 	if err := ctrl.init(); err != nil {
 		klog.Fatalf("init controller: %v", err)
 	}
 
+	if d.config.DebugAddress != "" {
+		startDebugServer(d.config.DebugAddress, map[string]http.HandlerFunc{
+			DebugReservationsPath: ctrl.reservations.serveHTTP,
+		})
+	}
+
 	return ctrl
 }
 
 // SupportedVolumeCapabilityAccessModes contains the list of supported access
-// modes for the volume
+// modes for the volume. The volume is local to a node, so the multi node
+// reader mode, which kubernetes uses for ReadOnlyMany, is honored for the
+// readers on the node of the volume only.
 var SupportedVolumeCapabilityAccessModes = []*csi.VolumeCapability_AccessMode{
 	&csi.VolumeCapability_AccessMode{
 		Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
 	},
+	&csi.VolumeCapability_AccessMode{
+		Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+	},
+	&csi.VolumeCapability_AccessMode{
+		Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+	},
+}
+
+// isReadOnlyAccessMode checks if the access mode allows readers only.
+func isReadOnlyAccessMode(mode csi.VolumeCapability_AccessMode_Mode) bool {
+	return mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY ||
+		mode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY
 }
 
 // getRoundedCapacity rounds the capacity on 1024 base
@@ -111,6 +147,16 @@ func getRoundedCapacity(size int64) int64 {
 	return ((size + Mi - 1) / Mi) * Mi
 }
 
+// getAllocatedCapacity returns the capacity allocated for the device volume,
+// falling back to the requested size if the node hasn't reported it.
+func getAllocatedCapacity(vol *apis.DeviceVolume, size int64) int64 {
+	allocated, err := strconv.ParseInt(vol.Status.Capacity, 10, 64)
+	if err != nil || allocated < size {
+		return size
+	}
+	return allocated
+}
+
 // waitForDeviceVolume waits for completion of any processing of device volume.
 // It returns the final status of device volume along with a boolean denoting
 // whether it should be rescheduled on some other device name or node.
@@ -126,8 +172,9 @@ func waitForDeviceVolume(ctx context.Context,
 			return nil, false, err
 		}
 	}
-	// if device volume is ready, return the provisioned node.
-	if vol.Status.State == device.DeviceStatusReady {
+	// if device volume is ready, or reserved as a standby, return the
+	// provisioned node.
+	if device.IsVolumeCreated(vol) {
 		return vol, false, nil
 	}
 
@@ -141,6 +188,14 @@ func waitForDeviceVolume(ctx context.Context,
 		errMsg = fmt.Sprintf("failed devicevol must have error set")
 	}
 
+	// the node agent gave up on creating the volume. Rescheduling it
+	// would likely fail the same way, so the failure is reported as
+	// final and the claim has to be recreated.
+	if device.GetVolumeCondition(vol, apis.CreationFailed) != nil {
+		return vol, false, status.Errorf(codes.FailedPrecondition,
+			"volume %s failed permanently: %s", vol.GetName(), errMsg)
+	}
+
 	if reschedule {
 		// if rescheduling is required, we can deleted the existing device volume object,
 		// so that it can be recreated.
@@ -183,6 +238,7 @@ func (cs *controller) init() error {
 
 	cs.k8sNodeInformer = kubeInformerFactory.Core().V1().Nodes().Informer()
 	cs.deviceNodeInformer = openebsInformerfactory.Local().V1alpha1().DeviceNodes().Informer()
+	cs.deviceQuotaInformer = openebsInformerfactory.Local().V1alpha1().DeviceQuotas().Informer()
 
 	if err = cs.deviceNodeInformer.AddIndexers(map[string]cache.IndexFunc{
 		LabelIndexName(cs.indexedLabel): LabelIndexFunc(cs.indexedLabel),
@@ -190,18 +246,33 @@ func (cs *controller) init() error {
 		return errors.Wrapf(err, "failed to add index on label %v", cs.indexedLabel)
 	}
 
+	// the volumes of the nodes removed from the cluster are marked lost.
+	cs.k8sNodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: cs.deleteK8sNode,
+	})
+	// the resizes of the devices of a node get logged, the external
+	// provisioner republishes the capacity on its poll.
+	cs.deviceNodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: cs.updateDeviceNode,
+		DeleteFunc: cs.deleteDeviceNode,
+	})
+
 	go cs.k8sNodeInformer.Run(stopCh)
 	go cs.deviceNodeInformer.Run(stopCh)
+	go cs.deviceQuotaInformer.Run(stopCh)
 
 	// wait for all the caches to be populated.
-	klog.Info("waiting for k8s & device node informer caches to be synced")
+	klog.Info("waiting for k8s, device node & device quota informer caches to be synced")
 	cache.WaitForCacheSync(stopCh,
 		cs.k8sNodeInformer.HasSynced,
-		cs.deviceNodeInformer.HasSynced)
-	klog.Info("synced k8s & device node informer caches")
+		cs.deviceNodeInformer.HasSynced,
+		cs.deviceQuotaInformer.HasSynced)
+	klog.Info("synced k8s, device node & device quota informer caches")
+	go cs.reconcileLostNodes()
 
 	klog.Infof("initializing csi provisioning leak protection controller")
 	pvcInformer := kubeInformerFactory.Core().V1().PersistentVolumeClaims()
+	cs.pvcLister = pvcInformer.Lister()
 	go pvcInformer.Informer().Run(stopCh)
 	if cs.leakProtection, err = csipv.NewLeakProtectionController(kubeClient,
 		pvcInformer, cs.driver.config.DriverName,
@@ -219,11 +290,32 @@ func (cs *controller) init() error {
 }
 
 // CreateDeviceVolume create new device volume for csi volume request
-func CreateDeviceVolume(ctx context.Context, req *csi.CreateVolumeRequest,
+func (cs *controller) CreateDeviceVolume(ctx context.Context, req *csi.CreateVolumeRequest,
 	params *VolumeParams) (*apis.DeviceVolume, error) {
 	volName := strings.ToLower(req.GetName())
-	capacity := strconv.FormatInt(getRoundedCapacity(
-		req.GetCapacityRange().RequiredBytes), 10)
+	size, err := resolveCapacity(req.GetCapacityRange(), params)
+	if err != nil {
+		return nil, err
+	}
+	capacity := strconv.FormatInt(size, 10)
+	if params.CapacityRounding != "" {
+		klog.Infof("resolved the capacity of volume %s to %s bytes, %d bytes requested rounded %s to the %s",
+			volName, capacity, req.GetCapacityRange().GetRequiredBytes(), params.CapacityRounding, params.CapacityRoundingUnit)
+	}
+
+	var stripeCount string
+	if params.StripeCount > 0 {
+		stripeCount = strconv.Itoa(params.StripeCount)
+	}
+
+	group, err := antiAffinityGroup(cs.pvcLister, params)
+	if err != nil {
+		return nil, err
+	}
+	var antiAffinityPolicy string
+	if group != "" {
+		antiAffinityPolicy = params.AntiAffinityPolicy
+	}
 
 	vol, err := device.GetDeviceVolume(volName)
 	if err != nil {
@@ -240,7 +332,9 @@ func CreateDeviceVolume(ctx context.Context, req *csi.CreateVolumeRequest,
 				return nil, err
 			}
 		} else {
-			if vol.Spec.Capacity != capacity {
+			if !isSameCapacity(vol, capacity, params.SizePercent) ||
+				!isSamePartitionType(vol.Spec.PartitionType, params.PartitionType) ||
+				vol.Spec.StripeCount != stripeCount {
 				return nil, status.Errorf(codes.AlreadyExists,
 					"volume %s already present", volName)
 			}
@@ -254,6 +348,12 @@ func CreateDeviceVolume(ctx context.Context, req *csi.CreateVolumeRequest,
 		}
 	}
 
+	// fail right away the volumes no device can hold, rather than
+	// leaving their claims pending.
+	if err = cs.checkVolumeFits(size, params); err != nil {
+		return nil, err
+	}
+
 	nmap, err := getNodeMap(params.Scheduler, params.DeviceName)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "get node map failed : %s", err.Error())
@@ -266,13 +366,91 @@ func CreateDeviceVolume(ctx context.Context, req *csi.CreateVolumeRequest,
 		return nil, status.Error(codes.Internal, "scheduler failed, not able to select a node to create the PV")
 	}
 
-	owner := selected[0]
+	// let the scheduler extender, if configured, filter and order the
+	// nodes picked by the built-in scheduler.
+	selected, err = cs.extender.prioritize(ctx, newExtenderRequest(volName, size, req, params, selected, nmap))
+	if err != nil {
+		return nil, err
+	}
+
+	quota, err := cs.getNamespaceQuota(volName, params)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	volLimit, err := cs.getNodeVolumeLimit(volName)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// book the capacity on the selected node till the DeviceNode accounts
+	// for the partition, so that concurrent requests don't target the same
+	// region.
+	owner, size, release, err := cs.reserveCapacity(volName, selected, size,
+		req.GetCapacityRange().GetLimitBytes(), params, quota, volLimit)
+	if err != nil {
+		return nil, err
+	}
+	var created bool
+	defer func() { release(created) }()
 	klog.Infof("scheduling the volume %s/%s on node %s", params.DeviceName, volName, owner)
 
+	var sizePercent string
+	if params.SizePercent > 0 {
+		sizePercent = strconv.Itoa(params.SizePercent)
+		capacity = strconv.FormatInt(size, 10)
+		klog.Infof("resolved the capacity of volume %s to %s bytes, %s%% of the free capacity",
+			volName, capacity, sizePercent)
+	}
+
+	var fitTolerance string
+	if params.Placement == device.PlacementFit {
+		fitTolerance = strconv.Itoa(params.FitTolerancePercent)
+	}
+
+	var bytesPerInode string
+	if params.BytesPerInode > 0 {
+		bytesPerInode = strconv.FormatInt(params.BytesPerInode, 10)
+	}
+
+	var slotBudget string
+	if params.SlotBudget > 0 {
+		slotBudget = strconv.Itoa(params.SlotBudget)
+	}
+
+	var standby string
+	if params.Standby {
+		standby = device.StandbyEnabled
+	}
+
+	// record the claim of the volume, if passed by the external
+	// provisioner, so that the node can attribute its metrics to it.
+	var annotations map[string]string
+	if params.PVCName != "" {
+		annotations = map[string]string{
+			device.PVCNameKey:      params.PVCName,
+			device.PVCNamespaceKey: params.PVCNamespace,
+		}
+	}
+
 	volObj, err := volbuilder.NewBuilder().
 		WithName(volName).
+		WithLabels(cs.ownerTopologyLabels(owner)).
+		WithAnnotations(annotations).
 		WithCapacity(capacity).
 		WithDeviceName(params.DeviceName).
+		WithPartitionType(params.PartitionType).
+		WithPlacement(params.Placement).
+		WithFitTolerancePercent(fitTolerance).
+		WithAntiAffinity(group, antiAffinityPolicy).
+		WithReservedBlocksPercent(strconv.Itoa(params.ReservedBlocksPercent)).
+		WithBytesPerInode(bytesPerInode).
+		WithFsLabel(params.FsLabel).
+		WithRootDirMode(params.RootDirMode).
+		WithSizePercent(sizePercent).
+		WithStripeCount(stripeCount).
+		WithSlotBudget(params.SlotClass, slotBudget).
+		WithStandby(standby).
 		WithOwnerNode(owner).
 		WithVolumeStatus(device.DeviceStatusPending).Build()
 
@@ -285,9 +463,116 @@ func CreateDeviceVolume(ctx context.Context, req *csi.CreateVolumeRequest,
 		return nil, status.Errorf(codes.Internal, "not able to provision the volume %s", err.Error())
 	}
 	vol, _, err = waitForDeviceVolume(ctx, vol)
+	created = err == nil
 	return vol, err
 }
 
+// isSamePartitionType checks whether the partition type of an existing
+// volume matches the requested one. Volumes created before the partition
+// type was recorded have the default type.
+func isSamePartitionType(existing, requested string) bool {
+	if existing == "" {
+		existing = device.DefaultPartitionType
+	}
+	return strings.EqualFold(existing, requested)
+}
+
+// isSameCapacity checks whether the capacity of an existing volume matches
+// the requested one. The capacity of the volumes sized by a percentage
+// depends on the free capacity at the time of their creation, so only the
+// percentage is compared for them.
+func isSameCapacity(vol *apis.DeviceVolume, capacity string, sizePercent int) bool {
+	if sizePercent > 0 {
+		return vol.Spec.SizePercent == strconv.Itoa(sizePercent)
+	}
+	return vol.Spec.Capacity == capacity
+}
+
+// reserveCapacity books the capacity of the volume, along with its growth
+// reserve, on the first of the selected nodes that can fit it, after
+// accounting for the capacity already booked by the in-flight requests.
+// Nodes without a DeviceNode are picked without a reservation, as their free
+// capacity is not known yet. It returns the node and the size of the volume,
+// which is resolved from the free capacity of the node if the volume is
+// sized by a percentage. If quota is set, nodes on which the volume can't
+// fit the quota of its namespace are skipped. If volLimit is set, nodes
+// holding the maximum number of volumes are skipped.
+func (cs *controller) reserveCapacity(volName string, selected []string,
+	size, limit int64, params *VolumeParams, quota *namespaceQuota,
+	volLimit *nodeVolumeLimit) (string, int64, func(created bool), error) {
+	var quotaErr, limitErr error
+	for _, node := range selected {
+		free, known, err := cs.getNodeFreeCapacity(node, params.DeviceName, params.OvercommitRatio, params.StripeCount)
+		if err != nil {
+			return "", 0, nil, status.Error(codes.Internal, err.Error())
+		}
+		volSize := size
+		if params.SizePercent > 0 {
+			if !known {
+				klog.Infof("skipping node %s for volume %s: free capacity is not known yet", node, volName)
+				continue
+			}
+			volSize, err = resolvePercentSize(free, params.SizePercent, size, limit)
+			if err != nil {
+				klog.Infof("skipping node %s for volume %s: %v", node, volName, err)
+				continue
+			}
+		}
+		if !known {
+			if quota == nil && volLimit == nil {
+				return node, volSize, func(bool) {}, nil
+			}
+			// book the capacity against the quota and the volume
+			// limit only.
+			free = math.MaxInt64
+		}
+		release, err := cs.reservations.reserve(volName, node, volSize, free, quota, volLimit)
+		if err != nil {
+			switch errors.Cause(err) {
+			case errQuotaExceeded:
+				quotaErr = err
+			case errVolumeLimitReached:
+				limitErr = err
+			}
+			klog.Infof("skipping node %s for volume %s: %v", node, volName, err)
+			continue
+		}
+		return node, volSize, release, nil
+	}
+	if quotaErr != nil {
+		return "", 0, nil, status.Errorf(codes.ResourceExhausted,
+			"namespace %s exceeds its quota on device %s: %s",
+			quota.namespace, params.DeviceName, quotaErr.Error())
+	}
+	if limitErr != nil {
+		return "", 0, nil, status.Errorf(codes.ResourceExhausted,
+			"no node can hold more than %d volumes: %s", volLimit.limit, limitErr.Error())
+	}
+	if params.SizePercent > 0 {
+		return "", 0, nil, status.Errorf(codes.ResourceExhausted,
+			"no node has %d%% of its free capacity on device %s larger than %d bytes",
+			params.SizePercent, params.DeviceName, size)
+	}
+	return "", 0, nil, status.Error(codes.ResourceExhausted,
+		cs.explainNoMatch(selected, size, params))
+}
+
+// resolvePercentSize returns percent of the free bytes, aligned down to
+// the partition alignment, as the size of a volume. The requested size is
+// the minimum size of the volume and the limit, if set, its maximum.
+func resolvePercentSize(free int64, percent int, required, limit int64) (int64, error) {
+	size := free * int64(percent) / 100
+	if limit > 0 && size > limit {
+		size = limit
+	}
+	size = size / Mi * Mi
+	if size <= 0 || size < required {
+		return 0, errors.Errorf("%d%% of the %d bytes free is less than the %d bytes requested",
+			percent, free, required)
+	}
+	return size, nil
+}
+
 // CreateVolume provisions a volume
 func (cs *controller) CreateVolume(
 	ctx context.Context,
@@ -306,8 +591,15 @@ func (cs *controller) CreateVolume(
 			"failed to parse csi volume params: %v", err)
 	}
 
+	if params.FsLabel, err = resolveFsLabel(params, req.GetVolumeCapabilities()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid fsLabel: %v", err)
+	}
+
 	volName := strings.ToLower(req.GetName())
-	size := getRoundedCapacity(req.GetCapacityRange().GetRequiredBytes())
+	size, err := resolveCapacity(req.GetCapacityRange(), params)
+	if err != nil {
+		return nil, err
+	}
 	contentSource := req.GetVolumeContentSource()
 
 	var vol *apis.DeviceVolume
@@ -323,12 +615,16 @@ func (cs *controller) CreateVolume(
 		return nil, err
 	}
 	defer finishCreateVolume()
-	vol, err = CreateDeviceVolume(ctx, req, params)
+	vol, err = cs.CreateDeviceVolume(ctx, req, params)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// report the capacity actually allocated by the node, so that the
+	// size seen by kubernetes matches the size of the partition.
+	size = getAllocatedCapacity(vol, size)
+
 	topology := map[string]string{device.DeviceTopologyKey: vol.Spec.OwnerNodeID}
 	cntx := map[string]string{device.DeviceNameKey: params.DeviceName, device.OpenEBSCasTypeKey: device.LocalDeviceCasTypeName}
 
@@ -376,6 +672,19 @@ func (cs *controller) deleteVolume(ctx context.Context, volumeID string) error {
 				"failed to handle delete volume request for {%s}", volumeID)
 		}
 	}
+	// no node agent is left to delete the partition of a lost volume.
+	if device.GetVolumeCondition(vol, apis.NodeLost) != nil {
+		klog.Infof("volume %s is lost along with its node, releasing it", volumeID)
+		if vol, err = device.GetDeviceVolume(volumeID); err != nil {
+			if k8serror.IsNotFound(err) {
+				return nil
+			}
+			return errors.Wrapf(err, "failed to get volume for {%s}", volumeID)
+		}
+		if err = device.RemoveVolFinalizer(vol); err != nil {
+			return errors.Wrapf(err, "failed to remove the finalizer of volume {%s}", volumeID)
+		}
+	}
 	if err = device.WaitForDeviceVolumeDestroy(ctx, volumeID); err != nil {
 		return err
 	}
@@ -534,38 +843,31 @@ func (cs *controller) GetCapacity(
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	deviceNodeCache := cs.deviceNodeInformer.GetIndexer()
 	params := req.GetParameters()
 	deviceParam := helpers.GetInsensitiveParameter(&params, "devname")
 
+	ratio := 1.0
+	if value := helpers.GetInsensitiveParameter(&params, "overcommitratio"); value != "" {
+		if ratio, err = parseOvercommitRatio(value); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	var stripes int
+	if value := helpers.GetInsensitiveParameter(&params, "stripecount"); value != "" {
+		if stripes, err = parseStripeCount(value); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
 	var availableCapacity int64
 	for _, nodeName := range nodeNames {
-		v, exists, err := deviceNodeCache.GetByKey(device.DeviceNamespace + "/" + nodeName)
+		freeCapacity, _, err := cs.getNodeFreeCapacity(nodeName, deviceParam, ratio, stripes)
 		if err != nil {
-			klog.Warning("unexpected error after querying the deviceNode informer cache")
-			continue
+			return nil, status.Error(codes.Internal, err.Error())
 		}
-		if !exists {
-			continue
-		}
-		deviceNode := v.(*apis.DeviceNode)
-		// rather than summing all free capacity, we are calculating maximum
-		// partition size that gets fit in given device.
-		// See https://github.com/kubernetes/enhancements/tree/master/keps/sig-storage/1472-storage-capacity-tracking#available-capacity-vs-maximum-volume-size &
-		// https://github.com/container-storage-interface/spec/issues/432 for more details
-		for _, device := range deviceNode.Devices {
-			devRegex, err := regexp.Compile(deviceParam)
-			if err != nil {
-				klog.Infof("Disk: Regex compile failure %s, %+v", deviceParam, err)
-				return nil, err
-			}
-			if !devRegex.MatchString(device.Name) {
-				continue
-			}
-			freeCapacity := device.Free.Value()
-			if availableCapacity < freeCapacity {
-				availableCapacity = freeCapacity
-			}
+		if availableCapacity < freeCapacity {
+			availableCapacity = freeCapacity
 		}
 	}
 
@@ -574,6 +876,106 @@ func (cs *controller) GetCapacity(
 	}, nil
 }
 
+// getNodeFreeCapacity returns the size of the largest partition that can be
+// created on the node's devices matching deviceParam, leaving out the
+// quarantined devices and the devices of weight zero, and not committing
+// more than ratio of the capacity of a device. If stripes is above 1, it
+// returns the size of the largest volume that can be striped across that
+// many devices instead. The boolean is false if the node has not published
+// its devices yet.
+func (cs *controller) getNodeFreeCapacity(nodeName, deviceParam string, ratio float64, stripes int) (int64, bool, error) {
+	v, exists, err := cs.deviceNodeInformer.GetIndexer().GetByKey(device.DeviceNamespace + "/" + nodeName)
+	if err != nil {
+		klog.Warning("unexpected error after querying the deviceNode informer cache")
+		return 0, false, nil
+	}
+	if !exists {
+		return 0, false, nil
+	}
+
+	devRegex, err := regexp.Compile(deviceParam)
+	if err != nil {
+		klog.Infof("Disk: Regex compile failure %s, %+v", deviceParam, err)
+		return 0, false, err
+	}
+
+	deviceNode := v.(*apis.DeviceNode)
+	quarantined := device.ParseQuarantinedDevices(deviceNode.Annotations[device.QuarantinedDevicesKey])
+	weights := device.ParseDeviceWeights(deviceNode.Annotations[device.DeviceWeightsKey])
+	// rather than summing all free capacity, we are calculating maximum
+	// partition size that gets fit in given device.
+	// See https://github.com/kubernetes/enhancements/tree/master/keps/sig-storage/1472-storage-capacity-tracking#available-capacity-vs-maximum-volume-size &
+	// https://github.com/container-storage-interface/spec/issues/432 for more details
+	var frees []int64
+	for _, device := range deviceNode.Devices {
+		if !devRegex.MatchString(device.Name) || quarantined[device.Name] ||
+			device.PendingReadiness || isDisabledDevice(weights, device) {
+			continue
+		}
+		frees = append(frees, allocatableCapacity(device, ratio))
+	}
+	return stripedCapacity(frees, stripes), true, nil
+}
+
+// getDeviceNodeVersion returns the resource version of the DeviceNode of
+// the node, empty if not known.
+func (cs *controller) getDeviceNodeVersion(nodeName string) string {
+	if cs.deviceNodeInformer == nil {
+		return ""
+	}
+	v, exists, err := cs.deviceNodeInformer.GetIndexer().GetByKey(device.DeviceNamespace + "/" + nodeName)
+	if err != nil || !exists {
+		return ""
+	}
+	return v.(*apis.DeviceNode).ResourceVersion
+}
+
+// stripedCapacity returns the size of the largest volume that can be
+// striped across stripes of the devices having the given allocatable
+// capacities. Each member gets a partition on a distinct device, so the
+// size is bounded by the stripes-th largest device, less the md superblock
+// area of the members. For stripes up to 1, it is the largest capacity.
+func stripedCapacity(frees []int64, stripes int) int64 {
+	sorted := make([]int64, len(frees))
+	copy(sorted, frees)
+	sort.Slice(sorted, func(i, j int) bool {
+		// ">" Descending order
+		return sorted[i] > sorted[j]
+	})
+	if stripes <= 1 {
+		if len(sorted) == 0 {
+			return 0
+		}
+		return sorted[0]
+	}
+	if len(sorted) < stripes {
+		return 0
+	}
+	member := sorted[stripes-1] - device.StripeDataOffsetMiB*device.PartitionAlignmentBytes
+	if member <= 0 {
+		return 0
+	}
+	return member * int64(stripes)
+}
+
+// allocatableCapacity returns the size of the largest partition that can be
+// created on the device without committing more than ratio of its capacity
+// to the volumes.
+func allocatableCapacity(device apis.Device, ratio float64) int64 {
+	free := device.Free.Value()
+	if ratio >= 1 {
+		return free
+	}
+	ceiling := int64(float64(device.Size.Value())*ratio) - device.Used.Value()
+	if ceiling < 0 {
+		return 0
+	}
+	if ceiling < free {
+		return ceiling
+	}
+	return free
+}
+
 func (cs *controller) filterNodesByTopology(segments map[string]string) ([]string, error) {
 	nodesCache := cs.k8sNodeInformer.GetIndexer()
 	if len(segments) == 0 {
@@ -617,13 +1019,85 @@ func (cs *controller) filterNodesByTopology(segments map[string]string) ([]strin
 
 // ListVolumes lists all the volumes
 //
+// The volumes are returned in the order of their names and the
+// next_token is the name of the first volume of the next page, so that
+// the pagination is not affected by volumes deleted between the calls.
+// Publish status is not reported as the driver doesn't implement
+// ControllerPublishVolume.
+//
 // This implements csi.ControllerServer
 func (cs *controller) ListVolumes(
 	ctx context.Context,
 	req *csi.ListVolumesRequest,
 ) (*csi.ListVolumesResponse, error) {
 
-	return nil, status.Error(codes.Unimplemented, "")
+	if req.GetMaxEntries() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"invalid max_entries %d", req.GetMaxEntries())
+	}
+
+	volList, err := device.ListDeviceVolumes()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"failed to list device volumes: %v", err)
+	}
+
+	vols, nextToken := paginateVolumes(volList.Items,
+		req.GetStartingToken(), int(req.GetMaxEntries()))
+
+	entries := make([]*csi.ListVolumesResponse_Entry, 0, len(vols))
+	for i := range vols {
+		entries = append(entries, &csi.ListVolumesResponse_Entry{
+			Volume: getCSIVolume(&vols[i]),
+		})
+	}
+
+	return &csi.ListVolumesResponse{
+		Entries:   entries,
+		NextToken: nextToken,
+	}, nil
+}
+
+// paginateVolumes returns the ready volumes starting from the volume named
+// startingToken, limited to maxEntries if it is non zero, along with the
+// name of the first volume of the next page.
+func paginateVolumes(vols []apis.DeviceVolume,
+	startingToken string, maxEntries int) ([]apis.DeviceVolume, string) {
+	var ready []apis.DeviceVolume
+	for _, vol := range vols {
+		if vol.Status.State != device.DeviceStatusReady ||
+			vol.DeletionTimestamp != nil {
+			continue
+		}
+		if vol.Name < startingToken {
+			continue
+		}
+		ready = append(ready, vol)
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		return ready[i].Name < ready[j].Name
+	})
+
+	if maxEntries == 0 || len(ready) <= maxEntries {
+		return ready, ""
+	}
+	return ready[:maxEntries], ready[maxEntries].Name
+}
+
+// getCSIVolume returns the csi representation of the device volume.
+func getCSIVolume(vol *apis.DeviceVolume) *csi.Volume {
+	size, _ := strconv.ParseInt(vol.Spec.Capacity, 10, 64)
+	return &csi.Volume{
+		VolumeId:      vol.Name,
+		CapacityBytes: getAllocatedCapacity(vol, size),
+		VolumeContext: map[string]string{
+			device.DeviceNameKey:     vol.Spec.DevName,
+			device.OpenEBSCasTypeKey: device.LocalDeviceCasTypeName,
+		},
+		AccessibleTopology: []*csi.Topology{{
+			Segments: map[string]string{device.DeviceTopologyKey: vol.Spec.OwnerNodeID},
+		}},
+	}
 }
 
 // validateCapabilities validates if provided capabilities
@@ -692,6 +1166,7 @@ func newControllerCapabilities() []*csi.ControllerServiceCapability {
 	for _, cap := range []csi.ControllerServiceCapability_RPC_Type{
 		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
 		csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
 	} {
 		capabilities = append(capabilities, fromType(cap))
 	}
@@ -17,9 +17,18 @@ limitations under the License.
 package driver
 
 import (
+	"context"
 	"testing"
 
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
 )
 
 func TestRoundOff(t *testing.T) {
@@ -43,3 +52,249 @@ func TestRoundOff(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAllocatedCapacity(t *testing.T) {
+	tests := map[string]struct {
+		allocated string
+		size      int64
+		expected  int64
+	}{
+		"capacity not reported":       {allocated: "", size: Mi, expected: Mi},
+		"allocated same as requested": {allocated: "1048576", size: Mi, expected: Mi},
+		"allocated larger":            {allocated: "2097152", size: Mi, expected: 2 * Mi},
+		"invalid allocated capacity":  {allocated: "2Mi", size: Mi, expected: Mi},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			vol := &apis.DeviceVolume{}
+			vol.Status.Capacity = test.allocated
+			assert.Equal(t, test.expected, getAllocatedCapacity(vol, test.size))
+		})
+	}
+}
+
+func TestPaginateVolumes(t *testing.T) {
+	newVol := func(name, state string) apis.DeviceVolume {
+		vol := apis.DeviceVolume{}
+		vol.Name = name
+		vol.Status.State = state
+		return vol
+	}
+	vols := []apis.DeviceVolume{
+		newVol("pvc-c", device.DeviceStatusReady),
+		newVol("pvc-a", device.DeviceStatusReady),
+		newVol("pvc-p", device.DeviceStatusPending),
+		newVol("pvc-b", device.DeviceStatusReady),
+	}
+
+	tests := map[string]struct {
+		vols          []apis.DeviceVolume
+		startingToken string
+		maxEntries    int
+		expected      []string
+		nextToken     string
+	}{
+		"empty list":                {vols: nil, expected: nil},
+		"all volumes without limit": {vols: vols, expected: []string{"pvc-a", "pvc-b", "pvc-c"}},
+		"first page":                {vols: vols, maxEntries: 2, expected: []string{"pvc-a", "pvc-b"}, nextToken: "pvc-c"},
+		"last page":                 {vols: vols, startingToken: "pvc-c", maxEntries: 2, expected: []string{"pvc-c"}},
+		"page size equals volumes":  {vols: vols, maxEntries: 3, expected: []string{"pvc-a", "pvc-b", "pvc-c"}},
+		"token of deleted volume":   {vols: vols, startingToken: "pvc-aa", maxEntries: 1, expected: []string{"pvc-b"}, nextToken: "pvc-c"},
+		"token past the last":       {vols: vols, startingToken: "pvc-z", expected: nil},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			page, nextToken := paginateVolumes(test.vols, test.startingToken, test.maxEntries)
+			var names []string
+			for _, vol := range page {
+				names = append(names, vol.Name)
+			}
+			assert.Equal(t, test.expected, names)
+			assert.Equal(t, test.nextToken, nextToken)
+		})
+	}
+}
+
+func TestIsValidVolumeCapabilities(t *testing.T) {
+	volCap := func(mode csi.VolumeCapability_AccessMode_Mode) *csi.VolumeCapability {
+		return &csi.VolumeCapability{AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode}}
+	}
+	tests := map[string]struct {
+		modes []csi.VolumeCapability_AccessMode_Mode
+		valid bool
+	}{
+		"single node writer":   {modes: []csi.VolumeCapability_AccessMode_Mode{csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}, valid: true},
+		"single node reader":   {modes: []csi.VolumeCapability_AccessMode_Mode{csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY}, valid: true},
+		"read only many":       {modes: []csi.VolumeCapability_AccessMode_Mode{csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY}, valid: true},
+		"read write many":      {modes: []csi.VolumeCapability_AccessMode_Mode{csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER}, valid: false},
+		"multi node one write": {modes: []csi.VolumeCapability_AccessMode_Mode{csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER}, valid: false},
+		"writer and readers": {
+			modes: []csi.VolumeCapability_AccessMode_Mode{
+				csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+			},
+			valid: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var caps []*csi.VolumeCapability
+			for _, mode := range test.modes {
+				caps = append(caps, volCap(mode))
+			}
+			assert.Equal(t, test.valid, isValidVolumeCapabilities(caps))
+		})
+	}
+}
+
+func TestAllocatableCapacity(t *testing.T) {
+	dev := apis.Device{
+		Name: "test-device",
+		Size: *resource.NewQuantity(100*Gi, resource.BinarySI),
+		Free: *resource.NewQuantity(40*Gi, resource.BinarySI),
+		Used: *resource.NewQuantity(50*Gi, resource.BinarySI),
+	}
+	tests := map[string]struct {
+		ratio    float64
+		expected int64
+	}{
+		"no overcommit":       {ratio: 1, expected: 40 * Gi},
+		"ceiling below free":  {ratio: 0.8, expected: 30 * Gi},
+		"ceiling above free":  {ratio: 0.95, expected: 40 * Gi},
+		"ceiling already hit": {ratio: 0.5, expected: 0},
+		"ceiling exceeded":    {ratio: 0.3, expected: 0},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, allocatableCapacity(dev, test.ratio))
+		})
+	}
+}
+
+func TestStripedCapacity(t *testing.T) {
+	offset := int64(device.StripeDataOffsetMiB * device.PartitionAlignmentBytes)
+	tests := map[string]struct {
+		frees    []int64
+		stripes  int
+		expected int64
+	}{
+		"no devices":              {frees: nil, stripes: 0, expected: 0},
+		"largest device":          {frees: []int64{10 * Gi, 40 * Gi, 20 * Gi}, stripes: 0, expected: 40 * Gi},
+		"two stripes":             {frees: []int64{10 * Gi, 40 * Gi, 20 * Gi}, stripes: 2, expected: 2 * (20*Gi - offset)},
+		"three stripes":           {frees: []int64{10 * Gi, 40 * Gi, 20 * Gi}, stripes: 3, expected: 3 * (10*Gi - offset)},
+		"fewer devices":           {frees: []int64{10 * Gi, 40 * Gi}, stripes: 3, expected: 0},
+		"device without capacity": {frees: []int64{40 * Gi, 0}, stripes: 2, expected: 0},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, stripedCapacity(test.frees, test.stripes))
+		})
+	}
+}
+
+func TestReserveCapacityCeiling(t *testing.T) {
+	node := newTestDeviceNode("node1", 100*Gi, 80*Gi)
+	node.Devices[0].Used = *resource.NewQuantity(20*Gi, resource.BinarySI)
+	cs := newTestController(t, node)
+	ceiling := &VolumeParams{DeviceName: "test-device", OvercommitRatio: 0.5}
+
+	// 50% of the device can be committed, 20Gi of which is already used
+	_, _, _, err := cs.reserveCapacity("pvc-1", []string{"node1"}, 20*Gi, 0, ceiling, nil, nil)
+	assert.NoError(t, err)
+	_, _, _, err = cs.reserveCapacity("pvc-2", []string{"node1"}, 20*Gi, 0, ceiling, nil, nil)
+	assert.Error(t, err, "volumes must not be committed above the ceiling")
+	_, _, _, err = cs.reserveCapacity("pvc-2", []string{"node1"}, 10*Gi, 0, ceiling, nil, nil)
+	assert.NoError(t, err)
+
+	// without a ceiling the free capacity of the device is the limit
+	_, _, _, err = cs.reserveCapacity("pvc-3", []string{"node1"}, 50*Gi, 0,
+		&VolumeParams{DeviceName: "test-device", OvercommitRatio: 1}, nil, nil)
+	assert.NoError(t, err)
+}
+
+func TestResolvePercentSize(t *testing.T) {
+	tests := map[string]struct {
+		free     int64
+		percent  int
+		required int64
+		limit    int64
+		expected int64
+		wantErr  bool
+	}{
+		"whole free capacity":         {free: 100 * Gi, percent: 100, required: Mi, expected: 100 * Gi},
+		"half of free capacity":       {free: 100 * Gi, percent: 50, required: Mi, expected: 50 * Gi},
+		"aligned down to Mi":          {free: 10 * Mi, percent: 25, required: Mi, expected: 2 * Mi},
+		"capped by the limit":         {free: 100 * Gi, percent: 100, required: Gi, limit: 10 * Gi, expected: 10 * Gi},
+		"request is the minimum":      {free: 100 * Gi, percent: 10, required: 10 * Gi, expected: 10 * Gi},
+		"less than the request":       {free: 100 * Gi, percent: 10, required: 20 * Gi, wantErr: true},
+		"less than the alignment":     {free: Mi, percent: 50, required: 1, wantErr: true},
+		"limit less than the request": {free: 100 * Gi, percent: 100, required: 2 * Gi, limit: Gi, wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			size, err := resolvePercentSize(test.free, test.percent, test.required, test.limit)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, size)
+		})
+	}
+}
+
+func TestReserveCapacitySizePercent(t *testing.T) {
+	cs := newTestController(t, newTestDeviceNode("node1", 100*Gi, 10*Gi),
+		newTestDeviceNode("node2", 100*Gi, 100*Gi))
+	params := &VolumeParams{DeviceName: "test-device", OvercommitRatio: 1, SizePercent: 50}
+
+	// the size is resolved from the free capacity of the picked node
+	node, size, _, err := cs.reserveCapacity("pvc-1", []string{"node1"}, Gi, 0, params, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "node1", node)
+	assert.Equal(t, int64(5*Gi), size)
+
+	// nodes which can't fit the requested size are skipped
+	node, size, _, err = cs.reserveCapacity("pvc-2", []string{"node1", "node2"}, 20*Gi, 0, params, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "node2", node)
+	assert.Equal(t, int64(50*Gi), size)
+
+	// nodes without a DeviceNode can't resolve the size
+	_, _, _, err = cs.reserveCapacity("pvc-3", []string{"node3"}, Gi, 0, params, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestIsSameCapacity(t *testing.T) {
+	vol := &apis.DeviceVolume{}
+	vol.Spec.Capacity = "10737418240"
+	assert.True(t, isSameCapacity(vol, "10737418240", 0))
+	assert.False(t, isSameCapacity(vol, "1073741824", 0))
+
+	// the resolved capacity differs from the requested one
+	vol.Spec.SizePercent = "100"
+	assert.True(t, isSameCapacity(vol, "1073741824", 100))
+	assert.False(t, isSameCapacity(vol, "10737418240", 50))
+}
+
+func TestWaitForDeviceVolumeCreationFailed(t *testing.T) {
+	vol := &apis.DeviceVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+		Status: apis.VolStatus{
+			State: device.DeviceStatusFailed,
+			Error: &apis.VolumeError{Code: apis.Internal, Message: "sgdisk failed"},
+			Conditions: []apis.VolumeCondition{
+				{Type: apis.CreationFailed, Message: "sgdisk failed"},
+			},
+		},
+	}
+
+	// the volume is neither deleted nor rescheduled.
+	got, reschedule, err := waitForDeviceVolume(context.Background(), vol)
+	assert.Equal(t, vol, got)
+	assert.False(t, reschedule)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, err.Error(), "sgdisk failed")
+}
@@ -0,0 +1,74 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+const (
+	// DebugStatePath is the http path of the debug server where the node
+	// agent exposes the partitions, free regions and recent allocation
+	// decisions and reconciles.
+	DebugStatePath = "/debug/state"
+
+	// DebugResyncPath is the http path of the debug server where the node
+	// agent starts a resync of all its volumes on POST, and reports the
+	// progress of the last one on GET.
+	DebugResyncPath = "/debug/resync"
+
+	// DebugReservationsPath is the http path of the debug server where the
+	// controller exposes the capacity reservations.
+	DebugReservationsPath = "/debug/reservations"
+)
+
+// checkLoopbackAddress makes sure that the debug server is reachable from
+// the pod only, e.g. through kubectl port-forward, as it has no authn/authz
+// of its own.
+func checkLoopbackAddress(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid debug address %q", addr)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return errors.Errorf("debug address %q is not a loopback address", addr)
+	}
+	return nil
+}
+
+// startDebugServer serves the given handlers on the loopback address addr.
+func startDebugServer(addr string, handlers map[string]http.HandlerFunc) {
+	if err := checkLoopbackAddress(addr); err != nil {
+		klog.Fatalf("failed to start debug server: %v", err)
+	}
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		mux.HandleFunc(path, handler)
+	}
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			klog.Fatalf("failed to start debug server on %s: %v", addr, err)
+		}
+	}()
+}
@@ -0,0 +1,48 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLoopbackAddress(t *testing.T) {
+	tests := map[string]struct {
+		addr    string
+		wantErr bool
+	}{
+		"ipv4 loopback": {addr: "127.0.0.1:9081"},
+		"ipv6 loopback": {addr: "[::1]:9081"},
+		"localhost":     {addr: "localhost:9081"},
+		"all addresses": {addr: ":9081", wantErr: true},
+		"pod address":   {addr: "10.0.0.12:9081", wantErr: true},
+		"hostname":      {addr: "node-1:9081", wantErr: true},
+		"missing port":  {addr: "127.0.0.1", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkLoopbackAddress(tt.addr)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ package driver
 import (
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	config "github.com/openebs/device-localpv/pkg/config"
+	"github.com/openebs/device-localpv/pkg/device"
 	"k8s.io/klog"
 )
 
@@ -41,6 +42,10 @@ type CSIDriver struct {
 	cs     csi.ControllerServer
 
 	cap []*csi.VolumeCapability_AccessMode
+
+	// readiness holds the node plugin back till it is ready, nil for
+	// the controller plugin.
+	readiness *readinessGate
 }
 
 // GetVolumeCapabilityAccessModes fetches the access
@@ -48,6 +53,8 @@ type CSIDriver struct {
 func GetVolumeCapabilityAccessModes() []*csi.VolumeCapability_AccessMode {
 	supported := []csi.VolumeCapability_AccessMode_Mode{
 		csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+		csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
 	}
 
 	var vcams []*csi.VolumeCapability_AccessMode
@@ -69,6 +76,11 @@ func New(config *config.Config) *CSIDriver {
 		cap:    GetVolumeCapabilityAccessModes(),
 	}
 
+	// the objects of the install are all kept in its namespace
+	if err := CheckNamespace(device.DeviceNamespace); err != nil {
+		klog.Fatalf("Preflight check failed: %s", err.Error())
+	}
+
 	switch config.PluginType {
 	case "controller":
 		driver.cs = NewController(driver)
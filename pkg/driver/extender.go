@@ -0,0 +1,214 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+)
+
+// extenderMaxResponse bounds the size of the response read from the
+// scheduler extender.
+const extenderMaxResponse = 1 << 20
+
+// ExtenderRequest is the JSON body POSTed to the scheduler extender for
+// each volume to be placed.
+type ExtenderRequest struct {
+	// Volume is the name of the volume, e.g. pvc-<uid>
+	Volume string `json:"volume"`
+
+	// PVCName and PVCNamespace identify the claim of the volume, if passed
+	// by the external provisioner
+	PVCName      string `json:"pvcName,omitempty"`
+	PVCNamespace string `json:"pvcNamespace,omitempty"`
+
+	// DeviceName is the device name of the storage class
+	DeviceName string `json:"deviceName"`
+
+	// Capacity is the requested size of the volume in bytes
+	Capacity int64 `json:"capacity"`
+
+	// Parameters are the storage class parameters of the volume
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// Nodes are the candidate nodes, in the order the built-in scheduler
+	// prefers them
+	Nodes []ExtenderNode `json:"nodes"`
+}
+
+// ExtenderNode is a candidate node of the ExtenderRequest
+type ExtenderNode struct {
+	Name string `json:"name"`
+
+	// Weight is the weight the built-in scheduler ranked the node by, the
+	// number of volumes or the bytes provisioned on the device name
+	// depending on the scheduler of the storage class
+	Weight int64 `json:"weight"`
+}
+
+// ExtenderResponse is the JSON body the scheduler extender replies with.
+type ExtenderResponse struct {
+	// Nodes are the names of the candidate nodes the volume may be placed
+	// on, most preferred first. The nodes not among the candidates are
+	// ignored, and an empty list rejects all of them.
+	Nodes []string `json:"nodes"`
+
+	// Error, if set, fails the call to the extender
+	Error string `json:"error,omitempty"`
+}
+
+// schedulerExtender calls an external HTTP endpoint to filter and order
+// the nodes picked by the built-in scheduler. If the call fails, the
+// order of the built-in scheduler is used when failOpen is set, the
+// volume creation fails otherwise.
+type schedulerExtender struct {
+	url      string
+	client   *http.Client
+	failOpen bool
+}
+
+// newSchedulerExtender returns the extender calling url, nil if url is
+// empty.
+func newSchedulerExtender(url string, timeout time.Duration, failOpen bool) *schedulerExtender {
+	if url == "" {
+		return nil
+	}
+	return &schedulerExtender{
+		url:      url,
+		client:   &http.Client{Timeout: timeout},
+		failOpen: failOpen,
+	}
+}
+
+// prioritize returns the selected nodes filtered and ordered by the
+// extender. The selected nodes are returned as is if there is no extender.
+func (e *schedulerExtender) prioritize(ctx context.Context, req *ExtenderRequest) ([]string, error) {
+	selected := make([]string, 0, len(req.Nodes))
+	for _, node := range req.Nodes {
+		selected = append(selected, node.Name)
+	}
+	if e == nil {
+		return selected, nil
+	}
+
+	nodes, err := e.call(ctx, req)
+	if err != nil {
+		if e.failOpen {
+			klog.Warningf("scheduler extender failed for volume %s, using the built-in order: %v", req.Volume, err)
+			return selected, nil
+		}
+		return nil, status.Errorf(codes.Unavailable, "scheduler extender failed for volume %s: %v", req.Volume, err)
+	}
+	nodes = filterCandidates(nodes, selected)
+	if len(nodes) == 0 {
+		return nil, status.Errorf(codes.ResourceExhausted,
+			"scheduler extender rejected all the %d candidate nodes for volume %s", len(selected), req.Volume)
+	}
+	klog.Infof("scheduler extender ordered the nodes for volume %s: %v", req.Volume, nodes)
+	return nodes, nil
+}
+
+// call POSTs the request to the extender and returns the nodes of the
+// response.
+func (e *schedulerExtender) call(ctx context.Context, req *ExtenderRequest) ([]string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode the request")
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not build the request")
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, extenderMaxResponse))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	var extResp ExtenderResponse
+	if err = json.Unmarshal(data, &extResp); err != nil {
+		return nil, errors.Wrap(err, "could not decode the response")
+	}
+	if extResp.Error != "" {
+		return nil, errors.New(extResp.Error)
+	}
+	return extResp.Nodes, nil
+}
+
+// filterCandidates returns the nodes which are among the candidates, in
+// their order, ignoring the unknown and the duplicate ones.
+func filterCandidates(nodes, candidates []string) []string {
+	allowed := make(map[string]bool, len(candidates))
+	for _, node := range candidates {
+		allowed[node] = true
+	}
+	seen := map[string]bool{}
+	var filtered []string
+	for _, node := range nodes {
+		if !allowed[node] {
+			klog.Warningf("scheduler extender returned node %s which is not a candidate", node)
+			continue
+		}
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+		filtered = append(filtered, node)
+	}
+	return filtered
+}
+
+// newExtenderRequest returns the extender request for the volume with the
+// nodes selected by the built-in scheduler as the candidates.
+func newExtenderRequest(volName string, size int64, req *csi.CreateVolumeRequest,
+	params *VolumeParams, selected []string, nmap map[string]int64) *ExtenderRequest {
+	nodes := make([]ExtenderNode, 0, len(selected))
+	for _, node := range selected {
+		nodes = append(nodes, ExtenderNode{Name: node, Weight: nmap[node]})
+	}
+	return &ExtenderRequest{
+		Volume:       volName,
+		PVCName:      params.PVCName,
+		PVCNamespace: params.PVCNamespace,
+		DeviceName:   params.DeviceName,
+		Capacity:     size,
+		Parameters:   req.GetParameters(),
+		Nodes:        nodes,
+	}
+}
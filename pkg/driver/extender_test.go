@@ -0,0 +1,135 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stubExtender replies to the extender requests with the response built
+// by reply, recording the last request.
+func stubExtender(t *testing.T, reply func(req *ExtenderRequest) (int, interface{})) (*httptest.Server, *ExtenderRequest) {
+	var last ExtenderRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		if err := json.NewDecoder(r.Body).Decode(&last); err != nil {
+			t.Errorf("could not decode the extender request: %v", err)
+		}
+		code, body := reply(&last)
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(body)
+	}))
+	return srv, &last
+}
+
+func newTestExtenderRequest() *ExtenderRequest {
+	return &ExtenderRequest{
+		Volume:       "pvc-1",
+		PVCName:      "data",
+		PVCNamespace: "tenant-a",
+		DeviceName:   "test-device",
+		Capacity:     1 << 30,
+		Nodes:        []ExtenderNode{{Name: "node-1", Weight: 10}, {Name: "node-2", Weight: 20}, {Name: "node-3", Weight: 30}},
+	}
+}
+
+func TestSchedulerExtender(t *testing.T) {
+	tests := map[string]struct {
+		code     int
+		response interface{}
+		delay    time.Duration
+		failOpen bool
+		want     []string
+		wantCode codes.Code
+	}{
+		"reordered": {
+			code: http.StatusOK, response: ExtenderResponse{Nodes: []string{"node-3", "node-1"}},
+			want: []string{"node-3", "node-1"},
+		},
+		"unknown and duplicate nodes ignored": {
+			code: http.StatusOK, response: ExtenderResponse{Nodes: []string{"node-4", "node-2", "node-2"}},
+			want: []string{"node-2"},
+		},
+		"all nodes rejected": {
+			code: http.StatusOK, response: ExtenderResponse{Nodes: []string{}}, failOpen: true,
+			wantCode: codes.ResourceExhausted,
+		},
+		"error fails open": {
+			code: http.StatusOK, response: ExtenderResponse{Error: "tenant unknown"}, failOpen: true,
+			want: []string{"node-1", "node-2", "node-3"},
+		},
+		"error fails closed": {
+			code: http.StatusOK, response: ExtenderResponse{Error: "tenant unknown"},
+			wantCode: codes.Unavailable,
+		},
+		"bad status fails open": {
+			code: http.StatusInternalServerError, response: "oops", failOpen: true,
+			want: []string{"node-1", "node-2", "node-3"},
+		},
+		"invalid response fails closed": {
+			code: http.StatusOK, response: "not a response",
+			wantCode: codes.Unavailable,
+		},
+		"timeout fails open": {
+			code: http.StatusOK, response: ExtenderResponse{Nodes: []string{"node-3"}}, delay: time.Second, failOpen: true,
+			want: []string{"node-1", "node-2", "node-3"},
+		},
+		"timeout fails closed": {
+			code: http.StatusOK, response: ExtenderResponse{Nodes: []string{"node-3"}}, delay: time.Second,
+			wantCode: codes.Unavailable,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv, last := stubExtender(t, func(*ExtenderRequest) (int, interface{}) {
+				time.Sleep(tt.delay)
+				return tt.code, tt.response
+			})
+			defer srv.Close()
+
+			e := newSchedulerExtender(srv.URL, 100*time.Millisecond, tt.failOpen)
+			got, err := e.prioritize(context.Background(), newTestExtenderRequest())
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantCode, status.Code(err), "unexpected error %v", err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+			if tt.delay == 0 {
+				assert.Equal(t, *newTestExtenderRequest(), *last)
+			}
+		})
+	}
+}
+
+func TestSchedulerExtenderDisabled(t *testing.T) {
+	e := newSchedulerExtender("", time.Second, false)
+	assert.Nil(t, e)
+	got, err := e.prioritize(context.Background(), newTestExtenderRequest())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node-1", "node-2", "node-3"}, got)
+}
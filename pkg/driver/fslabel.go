@@ -0,0 +1,100 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// fsLabelPlaceholderRegex matches the placeholders of the fsLabel
+// parameter.
+var fsLabelPlaceholderRegex = regexp.MustCompile(`\$\{[^}]*\}`)
+
+// validateFsLabel checks that the fsLabel parameter is not empty and only
+// refers to the known placeholders.
+func validateFsLabel(label string) error {
+	if label == "" {
+		return errors.New("invalid fsLabel, must not be empty")
+	}
+	for _, placeholder := range fsLabelPlaceholderRegex.FindAllString(label, -1) {
+		switch placeholder {
+		case "${pvc.name}", "${pvc.namespace}", "${pv.name}":
+		default:
+			return errors.Errorf("invalid fsLabel %q, unknown placeholder %s, "+
+				"must be one of ${pvc.name}, ${pvc.namespace} or ${pv.name}", label, placeholder)
+		}
+	}
+	return nil
+}
+
+// resolveFsLabel returns the label of the filesystem of the volume, the
+// placeholders of the fsLabel parameter replaced by the claim of the volume
+// and truncated to the length limit of the filesystem of the volume. The
+// block volumes get no label.
+func resolveFsLabel(params *VolumeParams, caps []*csi.VolumeCapability) (string, error) {
+	if params.FsLabel == "" {
+		return "", nil
+	}
+	fsType, ok := getMountFsType(caps)
+	if !ok {
+		return "", nil
+	}
+
+	values := map[string]string{
+		"${pvc.name}":      params.PVCName,
+		"${pvc.namespace}": params.PVCNamespace,
+		"${pv.name}":       params.PVName,
+	}
+	var missing []string
+	label := fsLabelPlaceholderRegex.ReplaceAllStringFunc(params.FsLabel, func(placeholder string) string {
+		if values[placeholder] == "" {
+			missing = append(missing, placeholder)
+		}
+		return values[placeholder]
+	})
+	if len(missing) > 0 {
+		return "", errors.Errorf("%s not passed by the provisioner, "+
+			"enable the --extra-create-metadata flag of the csi-provisioner", strings.Join(missing, ", "))
+	}
+
+	truncated, err := device.TruncateFsLabel(fsType, label)
+	if err != nil {
+		return "", err
+	}
+	if truncated != label {
+		klog.Warningf("filesystem label %q truncated to %q, the limit of %s", label, truncated, fsType)
+	}
+	return truncated, nil
+}
+
+// getMountFsType returns the filesystem type of the mount capability, if
+// any.
+func getMountFsType(caps []*csi.VolumeCapability) (string, bool) {
+	for _, c := range caps {
+		if mnt := c.GetMount(); mnt != nil {
+			return mnt.GetFsType(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,67 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveFsLabel(t *testing.T) {
+	mountCap := func(fsType string) []*csi.VolumeCapability {
+		return []*csi.VolumeCapability{{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: fsType}},
+		}}
+	}
+	blockCap := []*csi.VolumeCapability{{AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}}}}
+
+	tests := map[string]struct {
+		label   string
+		pvc     string
+		caps    []*csi.VolumeCapability
+		want    string
+		wantErr bool
+	}{
+		"no label":               {label: "", caps: mountCap("ext4"), want: ""},
+		"literal label":          {label: "data", caps: mountCap("ext4"), want: "data"},
+		"claim name":             {label: "${pvc.name}", pvc: "mysql", caps: mountCap("xfs"), want: "mysql"},
+		"default fs type":        {label: "db-${pvc.name}", pvc: "mysql", caps: mountCap(""), want: "db-mysql"},
+		"truncated for ext4":     {label: "${pvc.name}", pvc: "data-mysql-primary-0", caps: mountCap("ext4"), want: "data-mysql-prima"},
+		"truncated for xfs":      {label: "${pvc.name}", pvc: "data-mysql-primary-0", caps: mountCap("xfs"), want: "data-mysql-p"},
+		"claim name not passed":  {label: "${pvc.name}", caps: mountCap("ext4"), wantErr: true},
+		"unsupported filesystem": {label: "data", caps: mountCap("vfat"), wantErr: true},
+		"block volume":           {label: "${pvc.name}", caps: blockCap, want: ""},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			params := &VolumeParams{FsLabel: test.label, PVCName: test.pvc, PVCNamespace: "default"}
+			got, err := resolveFsLabel(params, test.caps)
+			assert.Equal(t, test.wantErr, err != nil, "resolveFsLabel() error %v", err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestValidateFsLabel(t *testing.T) {
+	assert.NoError(t, validateFsLabel("data"))
+	assert.NoError(t, validateFsLabel("${pvc.namespace}-${pvc.name}"))
+	assert.NoError(t, validateFsLabel("${pv.name}"))
+	assert.Error(t, validateFsLabel(""))
+	assert.Error(t, validateFsLabel("${pod.name}"))
+}
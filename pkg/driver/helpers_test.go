@@ -0,0 +1,61 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// newTestDeviceNode returns the DeviceNode of the node with a single device
+// named test-device.
+func newTestDeviceNode(name string, size, free int64) *apis.DeviceNode {
+	return &apis.DeviceNode{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: device.DeviceNamespace},
+		Devices: []apis.Device{{
+			Name: "test-device", UUID: "uuid-" + name,
+			Size: *resource.NewQuantity(size, resource.BinarySI),
+			Free: *resource.NewQuantity(free, resource.BinarySI),
+		}},
+	}
+}
+
+// newTestController returns a controller whose DeviceNode informer holds
+// the given DeviceNodes, moved to the namespace of the driver. The
+// namespace of the driver is set to openebs till the end of the test.
+func newTestController(t *testing.T, nodes ...*apis.DeviceNode) *controller {
+	namespace := device.DeviceNamespace
+	device.DeviceNamespace = "openebs"
+	t.Cleanup(func() { device.DeviceNamespace = namespace })
+
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &apis.DeviceNode{}, 0, cache.Indexers{})
+	for _, node := range nodes {
+		node.Namespace = device.DeviceNamespace
+		assert.NoError(t, informer.GetIndexer().Add(node))
+	}
+	return &controller{
+		deviceNodeInformer: informer,
+		reservations:       newCapacityReservations(),
+	}
+}
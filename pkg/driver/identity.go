@@ -18,6 +18,7 @@ package driver
 
 import (
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/openebs/device-localpv/pkg/version"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
@@ -64,10 +65,8 @@ func (id *identity) GetPluginInfo(
 	}, nil
 }
 
-// TODO
-// Need to implement this
-//
-// Probe checks if the plugin is running or not
+// Probe checks if the plugin is ready. The node plugin is ready
+// once the disks got discovered and kubelet registered it.
 //
 // This implements csi.IdentityServer
 func (id *identity) Probe(
@@ -75,7 +74,9 @@ func (id *identity) Probe(
 	req *csi.ProbeRequest,
 ) (*csi.ProbeResponse, error) {
 
-	return &csi.ProbeResponse{}, nil
+	return &csi.ProbeResponse{
+		Ready: &wrappers.BoolValue{Value: id.driver.readiness.isReady()},
+	}, nil
 }
 
 // GetPluginCapabilities returns supported capabilities
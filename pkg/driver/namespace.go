@@ -0,0 +1,62 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	k8sapi "github.com/openebs/lib-csi/pkg/client/k8s"
+	"github.com/openebs/lib-csi/pkg/common/errors"
+)
+
+// getNamespaceFunc fetches the namespace of the given name.
+type getNamespaceFunc func(name string) error
+
+// CheckNamespace checks that the namespace of the DeviceNode and
+// DeviceVolume objects is set and exists, so that a misconfigured install
+// fails at startup rather than on every volume.
+func CheckNamespace(namespace string) error {
+	return checkNamespace(namespace, func(name string) error {
+		cfg, err := k8sapi.Config().Get()
+		if err != nil {
+			return errors.Wrap(err, "error building kubeconfig")
+		}
+		client, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return errors.Wrap(err, "error building kubernetes clientset")
+		}
+		_, err = client.CoreV1().Namespaces().Get(context.TODO(), name, metav1.GetOptions{})
+		return err
+	})
+}
+
+func checkNamespace(namespace string, get getNamespaceFunc) error {
+	if namespace == "" {
+		return errors.New("namespace not set, set the --namespace flag or the DEVICE_DRIVER_NAMESPACE environment variable")
+	}
+	if err := get(namespace); err != nil {
+		if k8serror.IsNotFound(err) {
+			return errors.Errorf("namespace %s does not exist", namespace)
+		}
+		return errors.Wrapf(err, "could not check namespace %s", namespace)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCheckNamespace(t *testing.T) {
+	existing := func(name string) error {
+		if name == "tenant-a" {
+			return nil
+		}
+		return k8serror.NewNotFound(schema.GroupResource{Resource: "namespaces"}, name)
+	}
+	unreachable := func(string) error { return errors.New("connection refused") }
+
+	tests := map[string]struct {
+		namespace string
+		get       getNamespaceFunc
+		wantErr   string
+	}{
+		"existing namespace": {namespace: "tenant-a", get: existing},
+		"not set":            {namespace: "", get: existing, wantErr: "namespace not set"},
+		"missing namespace":  {namespace: "tenant-b", get: existing, wantErr: "namespace tenant-b does not exist"},
+		"api server failure": {namespace: "tenant-a", get: unreachable, wantErr: "could not check namespace tenant-a"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkNamespace(test.namespace, test.get)
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.wantErr)
+			}
+		})
+	}
+}
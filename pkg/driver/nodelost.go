@@ -0,0 +1,139 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// deleteDeviceNode is the delete event handler of the DeviceNodes. It checks
+// whether the node of the DeviceNode is removed from the cluster.
+func (cs *controller) deleteDeviceNode(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*apis.DeviceNode)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("couldn't get DeviceNode from %#v", obj))
+		return
+	}
+	cs.reconcileLostNode(node.Name)
+}
+
+// deleteK8sNode is the delete event handler of the kubernetes nodes. The
+// DeviceNode is garbage collected after its kubernetes node, but the events
+// of the two informers are not ordered, so both check the node.
+func (cs *controller) deleteK8sNode(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(metav1.Object)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("couldn't get Node from %#v", obj))
+		return
+	}
+	cs.reconcileLostNode(node.GetName())
+}
+
+// isNodeLost returns true if the node is removed from the cluster, i.e.
+// both the kubernetes node and its DeviceNode are gone. A DeviceNode deleted
+// while its node is still around, e.g. by an operator or while the node is
+// unreachable, is recreated by the node agent once it runs again, so the
+// volumes on the node are left intact.
+func (cs *controller) isNodeLost(nodeName string) bool {
+	if _, exists, err := cs.k8sNodeInformer.GetIndexer().GetByKey(nodeName); err != nil || exists {
+		return false
+	}
+	_, exists, err := cs.deviceNodeInformer.GetIndexer().GetByKey(device.DeviceNamespace + "/" + nodeName)
+	return err == nil && !exists
+}
+
+// reconcileLostNode marks the volumes of the node with the NodeLost
+// condition, if the node is removed from the cluster. No node agent is left
+// to delete the partitions of the volumes, so the finalizer of the volumes
+// being deleted is removed as well.
+func (cs *controller) reconcileLostNode(nodeName string) {
+	if !cs.isNodeLost(nodeName) {
+		return
+	}
+	vols, err := device.ListDeviceVolumes()
+	if err != nil {
+		klog.Errorf("failed to list the volumes of lost node %s: %v", nodeName, err)
+		return
+	}
+	now := metav1.Now()
+	for i := range vols.Items {
+		vol := vols.Items[i].DeepCopy()
+		if vol.Spec.OwnerNodeID != nodeName {
+			continue
+		}
+		if vol.DeletionTimestamp != nil {
+			klog.Infof("node %s is removed from the cluster, releasing deleted volume %s", nodeName, vol.Name)
+			if err = device.RemoveVolFinalizer(vol); err != nil {
+				klog.Errorf("failed to remove the finalizer of volume %s: %v", vol.Name, err)
+			}
+			continue
+		}
+		if !markVolumeNodeLost(vol, now) {
+			continue
+		}
+		klog.Warningf("node %s is removed from the cluster, marking volume %s as lost", nodeName, vol.Name)
+		if err = device.UpdateVolume(vol); err != nil {
+			klog.Errorf("failed to mark volume %s as lost: %v", vol.Name, err)
+		}
+	}
+}
+
+// reconcileLostNodes checks the nodes of all the volumes, so that the nodes
+// removed while the controller was not running are not missed.
+func (cs *controller) reconcileLostNodes() {
+	vols, err := device.ListDeviceVolumes()
+	if err != nil {
+		klog.Errorf("failed to list the volumes for lost nodes: %v", err)
+		return
+	}
+	checked := map[string]bool{}
+	for _, vol := range vols.Items {
+		if node := vol.Spec.OwnerNodeID; node != "" && !checked[node] {
+			checked[node] = true
+			cs.reconcileLostNode(node)
+		}
+	}
+}
+
+// markVolumeNodeLost adds the NodeLost condition to the volume. It returns
+// false if the volume already has it.
+func markVolumeNodeLost(vol *apis.DeviceVolume, now metav1.Time) bool {
+	if device.GetVolumeCondition(vol, apis.NodeLost) != nil {
+		return false
+	}
+	vol.Status.Conditions = append(vol.Status.Conditions, apis.VolumeCondition{
+		Type: apis.NodeLost,
+		Message: fmt.Sprintf("node %s holding the volume is removed from the cluster, "+
+			"the data of the volume is lost", vol.Spec.OwnerNodeID),
+		LastTransitionTime: now,
+	})
+	return true
+}
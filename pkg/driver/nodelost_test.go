@@ -0,0 +1,61 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+func TestIsNodeLost(t *testing.T) {
+	// node1 is up, the DeviceNode of node2 is deleted by an operator and
+	// node3 is removed from the cluster. the DeviceNode of node4 is not
+	// garbage collected yet.
+	cs := newTestController(t, newTestDeviceNode("node1", 0, 0), newTestDeviceNode("node4", 0, 0))
+	k8sNodes := cache.NewSharedIndexInformer(&cache.ListWatch{}, &corev1.Node{}, 0, cache.Indexers{})
+	cs.k8sNodeInformer = k8sNodes
+	for _, name := range []string{"node1", "node2"} {
+		assert.NoError(t, k8sNodes.GetIndexer().Add(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	assert.False(t, cs.isNodeLost("node1"))
+	assert.False(t, cs.isNodeLost("node2"))
+	assert.True(t, cs.isNodeLost("node3"))
+	assert.False(t, cs.isNodeLost("node4"))
+}
+
+func TestMarkVolumeNodeLost(t *testing.T) {
+	vol := &apis.DeviceVolume{}
+	vol.Spec.OwnerNodeID = "node1"
+	now := metav1.Now()
+
+	assert.True(t, markVolumeNodeLost(vol, now))
+	cond := device.GetVolumeCondition(vol, apis.NodeLost)
+	if assert.NotNil(t, cond) {
+		assert.Contains(t, cond.Message, "node node1")
+		assert.Equal(t, now, cond.LastTransitionTime)
+	}
+	assert.False(t, markVolumeNodeLost(vol, metav1.Now()), "volume must be marked once")
+	assert.Len(t, vol.Status.Conditions, 1)
+}
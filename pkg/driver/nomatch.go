@@ -0,0 +1,140 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// Reasons a node can't hold a volume, in the order the constraints of the
+// StorageClass are applied.
+const (
+	noMatchNoDeviceNode = "have not published their devices yet"
+	noMatchNoDevice     = "have no device matching devname"
+	noMatchQuarantined  = "have only quarantined devices matching devname"
+	noMatchPending      = "have only devices pending readiness matching devname"
+	noMatchCapacity     = "have not enough free capacity on the matching devices"
+	noMatchOvercommit   = "have not enough capacity under the overcommitRatio on the matching devices"
+	noMatchReserved     = "have their free capacity booked by the volumes being created"
+)
+
+// maxNoMatchNodes is the number of nodes named for each reason.
+const maxNoMatchNodes = 3
+
+// diagnoseNode returns the constraint which eliminates the node for a
+// volume of required bytes, nil deviceNode meaning the node has not
+// published its devices. It returns noMatchReserved if the node could hold
+// the volume but for the in-flight requests.
+func diagnoseNode(deviceNode *apis.DeviceNode, devRegex *regexp.Regexp,
+	required int64, ratio float64) string {
+	if deviceNode == nil {
+		return noMatchNoDeviceNode
+	}
+	quarantined := device.ParseQuarantinedDevices(deviceNode.Annotations[device.QuarantinedDevicesKey])
+	var matched, ready, usable int
+	var free, allocatable int64
+	for _, dev := range deviceNode.Devices {
+		if !devRegex.MatchString(dev.Name) {
+			continue
+		}
+		matched++
+		if dev.PendingReadiness {
+			continue
+		}
+		ready++
+		if quarantined[dev.Name] {
+			continue
+		}
+		usable++
+		if dev.Free.Value() > free {
+			free = dev.Free.Value()
+		}
+		if a := allocatableCapacity(dev, ratio); a > allocatable {
+			allocatable = a
+		}
+	}
+	switch {
+	case matched == 0:
+		return noMatchNoDevice
+	case ready == 0:
+		return noMatchPending
+	case usable == 0:
+		return noMatchQuarantined
+	case free < required:
+		return noMatchCapacity
+	case allocatable < required:
+		return noMatchOvercommit
+	}
+	return noMatchReserved
+}
+
+// describeNoMatch describes why none of the nodes can hold the volume,
+// grouping the nodes by the constraint eliminating them.
+func describeNoMatch(params *VolumeParams, required int64, reasons map[string][]string) string {
+	selector := fmt.Sprintf("devname: %q", params.DeviceName)
+	if params.OvercommitRatio < 1 {
+		selector += fmt.Sprintf(", overcommitRatio: %g", params.OvercommitRatio)
+	}
+	var parts []string
+	for _, reason := range []string{noMatchNoDeviceNode, noMatchNoDevice, noMatchPending, noMatchQuarantined,
+		noMatchCapacity, noMatchOvercommit, noMatchReserved} {
+		nodes := reasons[reason]
+		if len(nodes) == 0 {
+			continue
+		}
+		sort.Strings(nodes)
+		named := nodes
+		if len(named) > maxNoMatchNodes {
+			named = named[:maxNoMatchNodes]
+		}
+		list := strings.Join(named, ", ")
+		if len(nodes) > len(named) {
+			list += fmt.Sprintf(" and %d more", len(nodes)-len(named))
+		}
+		parts = append(parts, fmt.Sprintf("%d node(s) %s (%s)", len(nodes), reason, list))
+	}
+	return fmt.Sprintf("no device matching selector {%s} with at least %s free on any node: %s",
+		selector, resource.NewQuantity(required, resource.BinarySI).String(), strings.Join(parts, "; "))
+}
+
+// explainNoMatch describes why none of the selected nodes can hold a volume
+// of required bytes, as per the DeviceNodes in the cache.
+func (cs *controller) explainNoMatch(selected []string, required int64, params *VolumeParams) string {
+	devRegex, err := regexp.Compile(params.DeviceName)
+	if err != nil {
+		return fmt.Sprintf("invalid devname %q: %v", params.DeviceName, err)
+	}
+	reasons := map[string][]string{}
+	for _, node := range selected {
+		var deviceNode *apis.DeviceNode
+		v, exists, err := cs.deviceNodeInformer.GetIndexer().GetByKey(device.DeviceNamespace + "/" + node)
+		if err == nil && exists {
+			deviceNode = v.(*apis.DeviceNode)
+		}
+		reason := diagnoseNode(deviceNode, devRegex, required, params.OvercommitRatio)
+		reasons[reason] = append(reasons[reason], node)
+	}
+	return describeNoMatch(params, required, reasons)
+}
@@ -0,0 +1,127 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+func TestReserveCapacityNoMatch(t *testing.T) {
+	newDevice := func(name string, size, free int64) apis.Device {
+		return apis.Device{
+			Name: name,
+			Size: *resource.NewQuantity(size, resource.BinarySI),
+			Free: *resource.NewQuantity(free, resource.BinarySI),
+			Used: *resource.NewQuantity(size-free, resource.BinarySI),
+		}
+	}
+	var nodes []*apis.DeviceNode
+	for name, node := range map[string]*apis.DeviceNode{
+		"hdd-node": {Devices: []apis.Device{newDevice("hdd-pool", 100*Gi, 100*Gi)}},
+		"full-node": {Devices: []apis.Device{newDevice("ssd-pool", 100*Gi, 10*Gi),
+			newDevice("ssd-other", 100*Gi, 100*Gi)}},
+		"bad-node": {
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{device.QuarantinedDevicesKey: "ssd-pool"}},
+			Devices:    []apis.Device{newDevice("ssd-pool", 100*Gi, 100*Gi)},
+		},
+		"used-node": {Devices: []apis.Device{newDevice("ssd-pool", 100*Gi, 60*Gi)}},
+		"new-node": {Devices: []apis.Device{func() apis.Device {
+			dev := newDevice("ssd-pool", 100*Gi, 100*Gi)
+			dev.PendingReadiness = true
+			return dev
+		}()}},
+	} {
+		node.Name = name
+		nodes = append(nodes, node)
+	}
+	cs := newTestController(t, nodes...)
+
+	tests := map[string]struct {
+		nodes    []string
+		params   *VolumeParams
+		size     int64
+		expected string
+	}{
+		"devname matches nothing": {
+			nodes:    []string{"hdd-node"},
+			params:   &VolumeParams{DeviceName: "ssd-pool", OvercommitRatio: 1},
+			size:     Gi,
+			expected: `no device matching selector {devname: "ssd-pool"} with at least 1Gi free on any node: 1 node(s) have no device matching devname (hdd-node)`,
+		},
+		"device quarantined": {
+			nodes:    []string{"bad-node"},
+			params:   &VolumeParams{DeviceName: "ssd-pool", OvercommitRatio: 1},
+			size:     Gi,
+			expected: "1 node(s) have only quarantined devices matching devname (bad-node)",
+		},
+		"device pending readiness": {
+			nodes:    []string{"new-node"},
+			params:   &VolumeParams{DeviceName: "ssd-pool", OvercommitRatio: 1},
+			size:     Gi,
+			expected: "1 node(s) have only devices pending readiness matching devname (new-node)",
+		},
+		"device full": {
+			nodes:    []string{"full-node"},
+			params:   &VolumeParams{DeviceName: "ssd-pool", OvercommitRatio: 1},
+			size:     50 * Gi,
+			expected: "with at least 50Gi free on any node: 1 node(s) have not enough free capacity on the matching devices (full-node)",
+		},
+		"overcommit ratio": {
+			nodes:    []string{"used-node"},
+			params:   &VolumeParams{DeviceName: "ssd-pool", OvercommitRatio: 0.5},
+			size:     20 * Gi,
+			expected: `{devname: "ssd-pool", overcommitRatio: 0.5}`,
+		},
+		"several nodes": {
+			nodes:  []string{"hdd-node", "full-node", "bad-node"},
+			params: &VolumeParams{DeviceName: "ssd-pool", OvercommitRatio: 1},
+			size:   50 * Gi,
+			expected: "1 node(s) have no device matching devname (hdd-node); " +
+				"1 node(s) have only quarantined devices matching devname (bad-node); " +
+				"1 node(s) have not enough free capacity on the matching devices (full-node)",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, _, err := cs.reserveCapacity("pvc-1", test.nodes, test.size, 0, test.params, nil, nil)
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+			assert.Contains(t, status.Convert(err).Message(), test.expected)
+		})
+	}
+}
+
+func TestDescribeNoMatch(t *testing.T) {
+	params := &VolumeParams{DeviceName: "ssd-.*", OvercommitRatio: 1}
+	msg := describeNoMatch(params, 50*Gi, map[string][]string{
+		noMatchNoDevice:     {"node-e", "node-d", "node-c", "node-b"},
+		noMatchNoDeviceNode: {"node-a"},
+		noMatchReserved:     {"node-f"},
+	})
+	assert.Equal(t, `no device matching selector {devname: "ssd-.*"} with at least 50Gi free on any node: `+
+		`1 node(s) have not published their devices yet (node-a); `+
+		`4 node(s) have no device matching devname (node-b, node-c, node-d and 1 more); `+
+		`1 node(s) have their free capacity booked by the volumes being created (node-f)`, msg)
+}
@@ -0,0 +1,80 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"regexp"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// largestClusterRegion returns the largest free region of the devices
+// matching devRegex across all the nodes, the quarantined devices and the
+// devices of weight zero left out. It returns false if a node has not published its devices yet, as
+// its devices might hold the volume.
+func (cs *controller) largestClusterRegion(devRegex *regexp.Regexp) (int64, bool) {
+	var largest int64
+	for _, node := range cs.k8sNodeInformer.GetIndexer().ListKeys() {
+		v, exists, err := cs.deviceNodeInformer.GetIndexer().GetByKey(device.DeviceNamespace + "/" + node)
+		if err != nil || !exists {
+			return 0, false
+		}
+		deviceNode := v.(*apis.DeviceNode)
+		quarantined := device.ParseQuarantinedDevices(deviceNode.Annotations[device.QuarantinedDevicesKey])
+		weights := device.ParseDeviceWeights(deviceNode.Annotations[device.DeviceWeightsKey])
+		for _, dev := range deviceNode.Devices {
+			if !devRegex.MatchString(dev.Name) || quarantined[dev.Name] || isDisabledDevice(weights, dev) {
+				continue
+			}
+			if dev.Free.Value() > largest {
+				largest = dev.Free.Value()
+			}
+		}
+	}
+	return largest, true
+}
+
+// checkVolumeFits fails a volume of required bytes larger than the largest
+// free region on any node, as per the DeviceNodes in the cache, which
+// would otherwise keep its claim pending. The volumes striped across the
+// disks, and the ones sized by a percentage of the free capacity, are not
+// bound by a single region.
+func (cs *controller) checkVolumeFits(required int64, params *VolumeParams) error {
+	if !cs.driver.config.RejectOversizedVolumes || params.StripeCount > 0 || params.SizePercent > 0 {
+		return nil
+	}
+	devRegex, err := regexp.Compile(params.DeviceName)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid devname %q: %v", params.DeviceName, err)
+	}
+	largest, known := cs.largestClusterRegion(devRegex)
+	if !known || largest >= required {
+		return nil
+	}
+	klog.Infof("rejecting volume of %d bytes on device %s, the largest free region is %d bytes",
+		required, params.DeviceName, largest)
+	return status.Errorf(codes.OutOfRange,
+		"the largest free region of the devices matching devname %q on any node is %s, smaller than the requested %s",
+		params.DeviceName, resource.NewQuantity(largest, resource.BinarySI).String(),
+		resource.NewQuantity(required, resource.BinarySI).String())
+}
@@ -0,0 +1,98 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/config"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+func TestCheckVolumeFits(t *testing.T) {
+	k8sNodes := cache.NewSharedIndexInformer(&cache.ListWatch{}, &corev1.Node{}, 0, cache.Indexers{})
+	nodes := map[string][]apis.Device{
+		"node1": {
+			{Name: "fast", Free: resource.MustParse("100Gi")},
+			{Name: "slow", Free: resource.MustParse("500Gi")},
+			{Name: "retired", UUID: "uuid-retired", Free: resource.MustParse("800Gi")},
+		},
+		"node2": {
+			{Name: "fast", Free: resource.MustParse("200Gi")},
+			{Name: "broken", Free: resource.MustParse("1Ti")},
+		},
+	}
+	var deviceNodes []*apis.DeviceNode
+	for name, devices := range nodes {
+		assert.NoError(t, k8sNodes.GetIndexer().Add(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+		deviceNode := &apis.DeviceNode{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Devices:    devices,
+		}
+		if name == "node1" {
+			deviceNode.Annotations = map[string]string{device.DeviceWeightsKey: "uuid-retired=0"}
+		}
+		if name == "node2" {
+			deviceNode.Annotations = map[string]string{device.QuarantinedDevicesKey: "broken"}
+		}
+		deviceNodes = append(deviceNodes, deviceNode)
+	}
+	cs := newTestController(t, deviceNodes...)
+	cs.k8sNodeInformer = k8sNodes
+
+	gi := int64(1 << 30)
+	tests := map[string]struct {
+		disabled bool
+		required int64
+		params   VolumeParams
+		unknown  bool
+		wantCode codes.Code
+	}{
+		"fits":                  {required: 150 * gi, params: VolumeParams{DeviceName: "fast"}, wantCode: codes.OK},
+		"just fits":             {required: 200 * gi, params: VolumeParams{DeviceName: "fast"}, wantCode: codes.OK},
+		"larger than any":       {required: 200*gi + 1<<20, params: VolumeParams{DeviceName: "fast"}, wantCode: codes.OutOfRange},
+		"other device matched":  {required: 400 * gi, params: VolumeParams{DeviceName: "fast|slow"}, wantCode: codes.OK},
+		"quarantined device":    {required: 600 * gi, params: VolumeParams{DeviceName: "broken"}, wantCode: codes.OutOfRange},
+		"device of weight zero": {required: 700 * gi, params: VolumeParams{DeviceName: "retired"}, wantCode: codes.OutOfRange},
+		"no matching device":    {required: gi, params: VolumeParams{DeviceName: "nvme"}, wantCode: codes.OutOfRange},
+		"striped":               {required: 300 * gi, params: VolumeParams{DeviceName: "fast", StripeCount: 2}, wantCode: codes.OK},
+		"sized by percent":      {required: 300 * gi, params: VolumeParams{DeviceName: "fast", SizePercent: 50}, wantCode: codes.OK},
+		"disabled":              {disabled: true, required: 300 * gi, params: VolumeParams{DeviceName: "fast"}, wantCode: codes.OK},
+		"node not published":    {unknown: true, required: 300 * gi, params: VolumeParams{DeviceName: "fast"}, wantCode: codes.OK},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if test.unknown {
+				node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node3"}}
+				assert.NoError(t, k8sNodes.GetIndexer().Add(node))
+				defer k8sNodes.GetIndexer().Delete(node)
+			}
+			cs.driver = &CSIDriver{config: &config.Config{RejectOversizedVolumes: !test.disabled}}
+			err := cs.checkVolumeFits(test.required, &test.params)
+			assert.Equal(t, test.wantCode, status.Code(err), "checkVolumeFits() error %v", err)
+		})
+	}
+}
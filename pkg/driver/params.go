@@ -17,9 +17,102 @@
 package driver
 
 import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
 	"github.com/openebs/lib-csi/pkg/common/helpers"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// partitionTypeRegex matches a sgdisk type code like 8300 or a GPT
+// partition type GUID.
+var partitionTypeRegex = regexp.MustCompile(
+	`^([0-9a-fA-F]{4}|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
+// rootDirModeRegex matches an octal permission mode like 0770 or 750.
+var rootDirModeRegex = regexp.MustCompile(`^0?[0-7]{3}$`)
+
+// maxReservedBlocksPercent is the largest percentage of the filesystem
+// blocks which can be reserved for the super-user.
+const maxReservedBlocksPercent = 50
+
+// Bounds of the bytes-per-inode ratio accepted by mke2fs.
+const (
+	minBytesPerInode = 1024
+	maxBytesPerInode = 64 * 1024 * 1024
 )
 
+// parseBytesPerInode parses the bytes-per-inode ratio of the ext3/ext4
+// filesystems.
+func parseBytesPerInode(value string) (int64, error) {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil || quantity.Value() < minBytesPerInode || quantity.Value() > maxBytesPerInode {
+		return 0, errors.Errorf("invalid bytesPerInode %q, must be a size "+
+			"from %d to %d bytes", value, minBytesPerInode, maxBytesPerInode)
+	}
+	return quantity.Value(), nil
+}
+
+// parseOvercommitRatio parses the ratio of the capacity of a device which
+// can be committed to the volumes. The volumes get partitions allocated
+// upfront, so they can't be thin provisioned and the ratio can't go above
+// 1.0, i.e. no overcommit.
+func parseOvercommitRatio(value string) (float64, error) {
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil || ratio <= 0 {
+		return 0, errors.Errorf("invalid overcommitRatio %q, must be a number "+
+			"above 0", value)
+	}
+	if ratio > 1 {
+		return 0, errors.Errorf("invalid overcommitRatio %q, overcommit needs "+
+			"thin provisioning which is not supported by the partitions backing "+
+			"the volumes", value)
+	}
+	return ratio, nil
+}
+
+// parseReservedBlocksPercent parses the percentage of the filesystem
+// blocks reserved for the super-user.
+func parseReservedBlocksPercent(value string) (int, error) {
+	percent, err := strconv.Atoi(value)
+	if err != nil || percent < 0 || percent > maxReservedBlocksPercent {
+		return 0, errors.Errorf("invalid reservedBlocksPercent %q, must be "+
+			"a number from 0 to %d", value, maxReservedBlocksPercent)
+	}
+	return percent, nil
+}
+
+// parseFitTolerancePercent parses how much larger than the partitions, in
+// percent, the free regions preferred by the fit placement can be.
+func parseFitTolerancePercent(value string) (int, error) {
+	percent, err := strconv.Atoi(value)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, errors.Errorf("invalid fitTolerancePercent %q, must be "+
+			"a number from 0 to 100", value)
+	}
+	return percent, nil
+}
+
+// maxStripeCount is the largest number of disks a volume can be striped
+// across.
+const maxStripeCount = 8
+
+// parseStripeCount parses the number of disks the volumes are striped
+// across.
+func parseStripeCount(value string) (int, error) {
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 2 || count > maxStripeCount {
+		return 0, errors.Errorf("invalid stripeCount %q, must be a number "+
+			"from 2 to %d", value, maxStripeCount)
+	}
+	return count, nil
+}
+
 // VolumeParams holds collection of supported settings that can
 // be configured in storage class.
 type VolumeParams struct {
@@ -30,6 +123,80 @@ type VolumeParams struct {
 	Scheduler string
 	Shared    string
 
+	// PartitionType specifies the GPT partition type of the partitions,
+	// either as a sgdisk type code or as a type GUID.
+	PartitionType string
+
+	// Placement specifies the policy for picking the disk of the
+	// partitions among the disks having the device name.
+	Placement string
+
+	// FitTolerancePercent specifies how much larger than the partitions,
+	// in percent, the free regions preferred by the fit placement can be.
+	FitTolerancePercent int
+
+	// AntiAffinityLabel specifies the label key of the claims grouping the
+	// volumes whose partitions are kept on distinct disks of a node.
+	AntiAffinityLabel string
+
+	// AntiAffinityPolicy specifies whether the volumes are created on the
+	// disks of their group when no other disk has room for them.
+	AntiAffinityPolicy string
+
+	// ReservedBlocksPercent specifies the percentage of the blocks of
+	// ext3/ext4 filesystems reserved for the super-user.
+	ReservedBlocksPercent int
+
+	// BytesPerInode specifies the bytes-per-inode ratio of ext3/ext4
+	// filesystems. Zero leaves the default of mkfs.
+	BytesPerInode int64
+
+	// FsLabel specifies the label of the filesystems of the volumes, which
+	// may refer to the claim of the volume through the ${pvc.name},
+	// ${pvc.namespace} and ${pv.name} placeholders. Empty sets no label.
+	FsLabel string
+
+	// OvercommitRatio specifies the ratio of the capacity of a device
+	// which can be committed to the volumes.
+	OvercommitRatio float64
+
+	// RootDirMode specifies the mode, in octal, of the root directory of
+	// the filesystem of the volumes. Empty leaves the mode set by mkfs.
+	RootDirMode string
+
+	// SizePercent specifies the percentage of the free capacity of a
+	// device the capacity of the volumes is resolved from. Zero means the
+	// requested capacity is used.
+	SizePercent int
+
+	// CapacityRounding specifies the policy for rounding the requested
+	// capacity of the volumes to CapacityRoundingUnit, up, down or to the
+	// nearest unit. Empty keeps the default rounding.
+	CapacityRounding string
+
+	// CapacityRoundingUnit specifies the unit the requested capacity of
+	// the volumes gets rounded to, the sector or the partition alignment.
+	CapacityRoundingUnit string
+
+	// StripeCount specifies the number of disks the volumes are striped
+	// across as a RAID0 array. Zero means the volumes are single
+	// partitions.
+	StripeCount int
+
+	// Standby specifies that the volumes are created as warm standby
+	// reservations, holding their partitions without being usable till
+	// they get activated.
+	Standby bool
+
+	// SlotClass specifies the class the GPT partition entries of the
+	// volumes are accounted to, shared by the storage classes which name
+	// the same class.
+	SlotClass string
+
+	// SlotBudget specifies the number of GPT partition entries the volumes
+	// of SlotClass can take on each disk. Zero means no budget.
+	SlotBudget int
+
 	// extra optional metadata passed by external provisioner
 	// if enabled. See --extra-create-metadata flag for more details.
 	// https://github.com/kubernetes-csi/external-provisioner#recommended-optional-arguments
@@ -41,7 +208,10 @@ type VolumeParams struct {
 // NewVolumeParams parses the input params and instantiates new VolumeParams.
 func NewVolumeParams(m map[string]string) (*VolumeParams, error) {
 	params := &VolumeParams{ // set up defaults, if any.
-		Scheduler: CapacityWeighted,
+		Scheduler:       CapacityWeighted,
+		PartitionType:   device.DefaultPartitionType,
+		Placement:       device.PlacementBinpack,
+		OvercommitRatio: 1,
 	}
 	// parameter keys may be mistyped from the CRD specification when declaring
 	// the storageclass, which kubectl validation will not catch. Because
@@ -52,7 +222,9 @@ func NewVolumeParams(m map[string]string) (*VolumeParams, error) {
 
 	// parse string params
 	stringParams := map[string]*string{
-		"scheduler": &params.Scheduler,
+		"scheduler":     &params.Scheduler,
+		"partitiontype": &params.PartitionType,
+		"placement":     &params.Placement,
 	}
 	for key, param := range stringParams {
 		value, ok := m[key]
@@ -62,6 +234,145 @@ func NewVolumeParams(m map[string]string) (*VolumeParams, error) {
 		*param = value
 	}
 
+	if !partitionTypeRegex.MatchString(params.PartitionType) {
+		return nil, errors.Errorf("invalid partition type %q, must be a "+
+			"type code like 8300 or a type GUID", params.PartitionType)
+	}
+
+	if params.Placement != device.PlacementBinpack &&
+		params.Placement != device.PlacementSpread &&
+		params.Placement != device.PlacementFit {
+		return nil, errors.Errorf("invalid placement %q, must be %s, %s or %s",
+			params.Placement, device.PlacementBinpack, device.PlacementSpread, device.PlacementFit)
+	}
+
+	if params.Placement == device.PlacementFit {
+		params.FitTolerancePercent = device.DefaultFitTolerancePercent
+	}
+	if percent, ok := m["fittolerancepercent"]; ok {
+		if params.Placement != device.PlacementFit {
+			return nil, errors.Errorf("fitTolerancePercent needs the %s placement", device.PlacementFit)
+		}
+		var err error
+		if params.FitTolerancePercent, err = parseFitTolerancePercent(percent); err != nil {
+			return nil, err
+		}
+	}
+
+	if label, ok := m["antiaffinitylabel"]; ok {
+		if errs := validation.IsQualifiedName(label); len(errs) > 0 {
+			return nil, errors.Errorf("invalid antiAffinityLabel %q: %s", label, strings.Join(errs, ", "))
+		}
+		params.AntiAffinityLabel = label
+		params.AntiAffinityPolicy = device.AntiAffinityStrict
+	}
+	if policy, ok := m["antiaffinitypolicy"]; ok {
+		if params.AntiAffinityLabel == "" {
+			return nil, errors.Errorf("antiAffinityPolicy needs antiAffinityLabel")
+		}
+		if policy != device.AntiAffinityStrict && policy != device.AntiAffinityRelaxed {
+			return nil, errors.Errorf("invalid antiAffinityPolicy %q, must be %s or %s",
+				policy, device.AntiAffinityStrict, device.AntiAffinityRelaxed)
+		}
+		params.AntiAffinityPolicy = policy
+	}
+
+	// nothing could grow a volume into its reserve before the volume
+	// expansion is supported.
+	if _, ok := m["growthreservebytes"]; ok {
+		return nil, errors.New("growthReserveBytes is not supported, volume expansion is not implemented")
+	}
+
+	if percent, ok := m["reservedblockspercent"]; ok {
+		var err error
+		if params.ReservedBlocksPercent, err = parseReservedBlocksPercent(percent); err != nil {
+			return nil, err
+		}
+	}
+
+	if ratio, ok := m["bytesperinode"]; ok {
+		var err error
+		if params.BytesPerInode, err = parseBytesPerInode(ratio); err != nil {
+			return nil, err
+		}
+	}
+
+	if label, ok := m["fslabel"]; ok {
+		if err := validateFsLabel(label); err != nil {
+			return nil, err
+		}
+		params.FsLabel = label
+	}
+
+	if ratio, ok := m["overcommitratio"]; ok {
+		var err error
+		if params.OvercommitRatio, err = parseOvercommitRatio(ratio); err != nil {
+			return nil, err
+		}
+	}
+
+	if mode, ok := m["rootdirmode"]; ok {
+		if !rootDirModeRegex.MatchString(mode) {
+			return nil, errors.Errorf("invalid rootDirMode %q, must be an "+
+				"octal permission mode like 0770", mode)
+		}
+		params.RootDirMode = mode
+	}
+
+	if percent, ok := m["sizepercent"]; ok {
+		value, err := strconv.Atoi(percent)
+		if err != nil || value < 1 || value > 100 {
+			return nil, errors.Errorf("invalid sizePercent %q, must be "+
+				"a number from 1 to 100", percent)
+		}
+		params.SizePercent = value
+	}
+
+	var err error
+	if params.CapacityRounding, params.CapacityRoundingUnit, err = parseCapacityRounding(
+		m["capacityrounding"], m["capacityroundingunit"]); err != nil {
+		return nil, err
+	}
+
+	if count, ok := m["stripecount"]; ok {
+		var err error
+		if params.StripeCount, err = parseStripeCount(count); err != nil {
+			return nil, err
+		}
+		// the members are placed on distinct disks of the size of the
+		// stripe each and get the Linux RAID partition type.
+		for _, name := range []string{"sizePercent", "partitionType", "antiAffinityLabel", "slotBudget"} {
+			if _, ok := m[strings.ToLower(name)]; ok {
+				return nil, errors.Errorf("%s can't be used along with stripeCount", name)
+			}
+		}
+	}
+
+	if standby, ok := m["standby"]; ok {
+		value, err := strconv.ParseBool(standby)
+		if err != nil {
+			return nil, errors.Errorf("invalid standby %q, must be true or false", standby)
+		}
+		params.Standby = value
+	}
+
+	if budget, ok := m["slotbudget"]; ok {
+		value, err := strconv.Atoi(budget)
+		if err != nil || value < 1 {
+			return nil, errors.Errorf("invalid slotBudget %q, must be a positive number", budget)
+		}
+		params.SlotBudget = value
+		params.SlotClass = m["slotclass"]
+		if params.SlotClass == "" {
+			return nil, errors.Errorf("slotBudget needs slotClass naming the class of the budget")
+		}
+		if errs := validation.IsDNS1123Subdomain(params.SlotClass); len(errs) > 0 {
+			return nil, errors.Errorf("invalid slotClass %q: %s", params.SlotClass, strings.Join(errs, ", "))
+		}
+	} else if _, ok := m["slotclass"]; ok {
+		return nil, errors.Errorf("slotClass needs slotBudget")
+	}
+
 	params.PVCName = m["csi.storage.k8s.io/pvc/name"]
 	params.PVCNamespace = m["csi.storage.k8s.io/pvc/namespace"]
 	params.PVName = m["csi.storage.k8s.io/pv/name"]
@@ -0,0 +1,445 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVolumeParamsPartitionType(t *testing.T) {
+	tests := map[string]struct {
+		value     *string
+		expected  string
+		expectErr bool
+	}{
+		"default type":        {value: nil, expected: "8300"},
+		"type code":           {value: strPtr("8e00"), expected: "8e00"},
+		"type guid":           {value: strPtr("E6D6D379-F507-44C2-A23C-238F2A3DF928"), expected: "E6D6D379-F507-44C2-A23C-238F2A3DF928"},
+		"short type code":     {value: strPtr("830"), expectErr: true},
+		"non hex type code":   {value: strPtr("zz00"), expectErr: true},
+		"truncated type guid": {value: strPtr("E6D6D379-F507-44C2-A23C"), expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			if test.value != nil {
+				m["partitionType"] = *test.value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.PartitionType)
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestNewVolumeParamsGrowthReserve(t *testing.T) {
+	tests := map[string]struct {
+		value     *string
+		expectErr bool
+	}{
+		"no reserve": {value: nil},
+		"bytes":      {value: strPtr("1073741824"), expectErr: true},
+		"quantity":   {value: strPtr("512Mi"), expectErr: true},
+		"zero":       {value: strPtr("0"), expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			if test.value != nil {
+				m["growthReserveBytes"] = *test.value
+			}
+			_, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewVolumeParamsFitTolerancePercent(t *testing.T) {
+	tests := map[string]struct {
+		placement string
+		value     *string
+		expected  int
+		expectErr bool
+	}{
+		"binpack placement":     {placement: "binpack", value: nil, expected: 0},
+		"default tolerance":     {placement: "fit", value: nil, expected: 10},
+		"tolerance":             {placement: "fit", value: strPtr("25"), expected: 25},
+		"zero tolerance":        {placement: "fit", value: strPtr("0"), expected: 0},
+		"tolerance above 100":   {placement: "fit", value: strPtr("101"), expectErr: true},
+		"invalid tolerance":     {placement: "fit", value: strPtr("some"), expectErr: true},
+		"tolerance without fit": {placement: "spread", value: strPtr("25"), expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device", "placement": test.placement}
+			if test.value != nil {
+				m["fitTolerancePercent"] = *test.value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.FitTolerancePercent)
+		})
+	}
+}
+
+func TestNewVolumeParamsAntiAffinity(t *testing.T) {
+	tests := map[string]struct {
+		params    map[string]string
+		label     string
+		policy    string
+		expectErr bool
+	}{
+		"no anti-affinity":     {params: map[string]string{}},
+		"default policy":       {params: map[string]string{"antiAffinityLabel": "app"}, label: "app", policy: "strict"},
+		"relaxed policy":       {params: map[string]string{"antiAffinityLabel": "app.kubernetes.io/name", "antiAffinityPolicy": "relaxed"}, label: "app.kubernetes.io/name", policy: "relaxed"},
+		"invalid label":        {params: map[string]string{"antiAffinityLabel": "app name"}, expectErr: true},
+		"invalid policy":       {params: map[string]string{"antiAffinityLabel": "app", "antiAffinityPolicy": "sometimes"}, expectErr: true},
+		"policy without label": {params: map[string]string{"antiAffinityPolicy": "relaxed"}, expectErr: true},
+		"along with stripes":   {params: map[string]string{"antiAffinityLabel": "app", "stripeCount": "2"}, expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			for key, value := range test.params {
+				m[key] = value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.label, params.AntiAffinityLabel)
+			assert.Equal(t, test.policy, params.AntiAffinityPolicy)
+		})
+	}
+}
+
+func TestNewVolumeParamsReservedBlocksPercent(t *testing.T) {
+	tests := map[string]struct {
+		value     *string
+		expected  int
+		expectErr bool
+	}{
+		"default":          {value: nil, expected: 0},
+		"zero":             {value: strPtr("0"), expected: 0},
+		"percent":          {value: strPtr("5"), expected: 5},
+		"maximum":          {value: strPtr("50"), expected: 50},
+		"above maximum":    {value: strPtr("51"), expectErr: true},
+		"negative percent": {value: strPtr("-1"), expectErr: true},
+		"fraction":         {value: strPtr("2.5"), expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			if test.value != nil {
+				m["reservedBlocksPercent"] = *test.value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.ReservedBlocksPercent)
+		})
+	}
+}
+
+func TestNewVolumeParamsBytesPerInode(t *testing.T) {
+	tests := map[string]struct {
+		value     *string
+		expected  int64
+		expectErr bool
+	}{
+		"mkfs default":    {value: nil, expected: 0},
+		"bytes":           {value: strPtr("4096"), expected: 4096},
+		"quantity":        {value: strPtr("16Ki"), expected: 16384},
+		"smallest ratio":  {value: strPtr("1024"), expected: 1024},
+		"largest ratio":   {value: strPtr("64Mi"), expected: 64 * 1024 * 1024},
+		"below the limit": {value: strPtr("512"), expectErr: true},
+		"above the limit": {value: strPtr("128Mi"), expectErr: true},
+		"invalid ratio":   {value: strPtr("dense"), expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			if test.value != nil {
+				m["bytesPerInode"] = *test.value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.BytesPerInode)
+		})
+	}
+}
+
+func TestNewVolumeParamsOvercommitRatio(t *testing.T) {
+	tests := map[string]struct {
+		value     *string
+		expected  float64
+		expectErr bool
+	}{
+		"default":        {value: nil, expected: 1},
+		"no overcommit":  {value: strPtr("1.0"), expected: 1},
+		"ceiling":        {value: strPtr("0.8"), expected: 0.8},
+		"overcommit":     {value: strPtr("1.5"), expectErr: true},
+		"zero ratio":     {value: strPtr("0"), expectErr: true},
+		"negative ratio": {value: strPtr("-0.5"), expectErr: true},
+		"invalid ratio":  {value: strPtr("half"), expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			if test.value != nil {
+				m["overcommitRatio"] = *test.value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.OvercommitRatio)
+		})
+	}
+}
+
+func TestNewVolumeParamsSizePercent(t *testing.T) {
+	tests := map[string]struct {
+		value     *string
+		expected  int
+		expectErr bool
+	}{
+		"requested size":  {value: nil, expected: 0},
+		"whole device":    {value: strPtr("100"), expected: 100},
+		"percent":         {value: strPtr("25"), expected: 25},
+		"zero percent":    {value: strPtr("0"), expectErr: true},
+		"above 100":       {value: strPtr("101"), expectErr: true},
+		"invalid percent": {value: strPtr("all"), expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			if test.value != nil {
+				m["sizePercent"] = *test.value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.SizePercent)
+		})
+	}
+}
+
+func TestNewVolumeParamsCapacityRounding(t *testing.T) {
+	tests := map[string]struct {
+		params       map[string]string
+		expected     string
+		expectedUnit string
+		expectErr    bool
+	}{
+		"default rounding":  {params: map[string]string{}},
+		"policy only":       {params: map[string]string{"capacityRounding": "down"}, expected: RoundDown, expectedUnit: RoundToAlignment},
+		"unit only":         {params: map[string]string{"capacityRoundingUnit": "sector"}, expected: RoundUp, expectedUnit: RoundToSector},
+		"policy and unit":   {params: map[string]string{"capacityRounding": "nearest", "capacityRoundingUnit": "alignment"}, expected: RoundNearest, expectedUnit: RoundToAlignment},
+		"invalid policy":    {params: map[string]string{"capacityRounding": "half-up"}, expectErr: true},
+		"invalid unit":      {params: map[string]string{"capacityRoundingUnit": "Gi"}, expectErr: true},
+		"case of the value": {params: map[string]string{"capacityRounding": "Up"}, expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			for key, value := range test.params {
+				m[key] = value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.CapacityRounding)
+			assert.Equal(t, test.expectedUnit, params.CapacityRoundingUnit)
+		})
+	}
+}
+
+func TestNewVolumeParamsSlotBudget(t *testing.T) {
+	tests := map[string]struct {
+		params        map[string]string
+		expected      int
+		expectedClass string
+		expectErr     bool
+	}{
+		"no budget":              {params: map[string]string{}},
+		"budget of the class":    {params: map[string]string{"slotBudget": "16", "slotClass": "tiny"}, expected: 16, expectedClass: "tiny"},
+		"budget without a class": {params: map[string]string{"slotBudget": "16"}, expectErr: true},
+		"class without a budget": {params: map[string]string{"slotClass": "tiny"}, expectErr: true},
+		"zero budget":            {params: map[string]string{"slotBudget": "0", "slotClass": "tiny"}, expectErr: true},
+		"invalid budget":         {params: map[string]string{"slotBudget": "many", "slotClass": "tiny"}, expectErr: true},
+		"invalid class":          {params: map[string]string{"slotBudget": "16", "slotClass": "Tiny Volumes"}, expectErr: true},
+		"along with stripes":     {params: map[string]string{"slotBudget": "16", "slotClass": "tiny", "stripeCount": "2"}, expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			for key, value := range test.params {
+				m[key] = value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.SlotBudget)
+			assert.Equal(t, test.expectedClass, params.SlotClass)
+		})
+	}
+}
+
+func TestNewVolumeParamsStripeCount(t *testing.T) {
+	tests := map[string]struct {
+		params    map[string]string
+		expected  int
+		expectErr bool
+	}{
+		"single partition":      {params: map[string]string{}, expected: 0},
+		"two disks":             {params: map[string]string{"stripeCount": "2"}, expected: 2},
+		"most disks":            {params: map[string]string{"stripeCount": "8"}, expected: 8},
+		"one disk":              {params: map[string]string{"stripeCount": "1"}, expectErr: true},
+		"too many disks":        {params: map[string]string{"stripeCount": "9"}, expectErr: true},
+		"invalid count":         {params: map[string]string{"stripeCount": "two"}, expectErr: true},
+		"with size percent":     {params: map[string]string{"stripeCount": "2", "sizePercent": "50"}, expectErr: true},
+		"with partition type":   {params: map[string]string{"stripeCount": "2", "partitionType": "8300"}, expectErr: true},
+		"with spread placement": {params: map[string]string{"stripeCount": "2", "placement": "spread"}, expected: 2},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			for key, value := range test.params {
+				m[key] = value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.StripeCount)
+		})
+	}
+}
+
+func TestNewVolumeParamsRootDirMode(t *testing.T) {
+	tests := map[string]struct {
+		value     *string
+		expected  string
+		expectErr bool
+	}{
+		"default":         {value: nil, expected: ""},
+		"leading zero":    {value: strPtr("0770"), expected: "0770"},
+		"three digits":    {value: strPtr("750"), expected: "750"},
+		"not octal":       {value: strPtr("0780"), expectErr: true},
+		"setuid bits":     {value: strPtr("4770"), expectErr: true},
+		"symbolic":        {value: strPtr("u+rwx"), expectErr: true},
+		"too few digits":  {value: strPtr("77"), expectErr: true},
+		"empty parameter": {value: strPtr(""), expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			if test.value != nil {
+				m["rootDirMode"] = *test.value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.RootDirMode)
+		})
+	}
+}
+
+func TestNewVolumeParamsStandby(t *testing.T) {
+	tests := map[string]struct {
+		value     *string
+		expected  bool
+		expectErr bool
+	}{
+		"not set":       {value: nil, expected: false},
+		"standby":       {value: strPtr("true"), expected: true},
+		"not standby":   {value: strPtr("false"), expected: false},
+		"invalid value": {value: strPtr("reserved"), expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			m := map[string]string{"devname": "test-device"}
+			if test.value != nil {
+				m["standby"] = *test.value
+			}
+			params, err := NewVolumeParams(m)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, params.Standby)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+
+	"github.com/openebs/device-localpv/pkg/config"
+)
+
+// writablePath is a directory the node plugin writes to, along with what
+// it writes there.
+type writablePath struct {
+	dir     string
+	purpose string
+}
+
+// getWritablePaths returns the directories the node plugin writes to with
+// the config. Nothing else is written, so that the plugin runs on the
+// nodes having a read-only root filesystem once these are writable.
+func getWritablePaths(cfg *config.Config) []writablePath {
+	var paths []writablePath
+	if proto, addr, err := parseEndpoint(cfg.Endpoint); err == nil && proto == "unix" {
+		paths = append(paths, writablePath{
+			dir:     filepath.Dir("/" + addr),
+			purpose: "the CSI socket served to kubelet",
+		})
+	}
+	if cfg.KubeletDir != "" {
+		paths = append(paths, writablePath{
+			dir:     cfg.KubeletDir,
+			purpose: "the mount targets of the volumes",
+		})
+	}
+	if cfg.AuditLog != "" {
+		paths = append(paths, writablePath{
+			dir:     filepath.Dir(cfg.AuditLog),
+			purpose: "the audit log of the disk operations",
+		})
+	}
+	return paths
+}
+
+// checkWritablePaths checks all of the paths with check and fails naming
+// every one which is not writable.
+func checkWritablePaths(paths []writablePath, check func(string) error) error {
+	var problems []string
+	for _, path := range paths {
+		if err := check(path.dir); err != nil {
+			problems = append(problems, fmt.Sprintf("%s, for %s: %v", path.dir, path.purpose, err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("directories not writable, mount a writable volume like an emptyDir "+
+			"or a hostPath at them: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkWritable checks that a file can be created in the directory.
+func checkWritable(dir string) error {
+	file, err := ioutil.TempFile(dir, ".device-localpv-preflight-")
+	if err != nil {
+		// the path is already named by the caller, keep the cause only,
+		// e.g. read-only file system.
+		if pathErr, ok := err.(*os.PathError); ok {
+			return pathErr.Err
+		}
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,88 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openebs/device-localpv/pkg/config"
+)
+
+func TestGetWritablePaths(t *testing.T) {
+	cfg := &config.Config{
+		Endpoint:   "unix:///plugin/csi.sock",
+		KubeletDir: "/var/lib/kubelet",
+	}
+	dirs := func(paths []writablePath) []string {
+		var result []string
+		for _, path := range paths {
+			result = append(result, path.dir)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"/plugin", "/var/lib/kubelet"}, dirs(getWritablePaths(cfg)))
+
+	cfg.AuditLog = "/var/log/device-localpv/audit.log"
+	assert.Equal(t, []string{"/plugin", "/var/lib/kubelet", "/var/log/device-localpv"}, dirs(getWritablePaths(cfg)))
+
+	cfg.Endpoint = "tcp://127.0.0.1:10000"
+	assert.Equal(t, []string{"/var/lib/kubelet", "/var/log/device-localpv"}, dirs(getWritablePaths(cfg)))
+}
+
+func TestCheckWritablePaths(t *testing.T) {
+	paths := []writablePath{
+		{dir: "/plugin", purpose: "the CSI socket served to kubelet"},
+		{dir: "/var/lib/kubelet", purpose: "the mount targets of the volumes"},
+		{dir: "/var/log", purpose: "the audit log of the disk operations"},
+	}
+	assert.NoError(t, checkWritablePaths(paths, func(string) error { return nil }))
+
+	err := checkWritablePaths(paths, func(dir string) error {
+		if dir == "/plugin" {
+			return nil
+		}
+		return syscall.EROFS
+	})
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "/plugin")
+	assert.Contains(t, err.Error(), "/var/lib/kubelet, for the mount targets of the volumes: read-only file system")
+	assert.Contains(t, err.Error(), "/var/log, for the audit log of the disk operations: read-only file system")
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preflight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, checkWritable(dir))
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files, "the probe file must be removed")
+
+	err = checkWritable(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "no such file"), err.Error())
+}
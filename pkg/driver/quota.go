@@ -0,0 +1,121 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"strconv"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// namespaceQuota is the capacity a namespace can take on the devices of a
+// node, as set by a DeviceQuota, along with the capacity already taken by
+// the volumes of the namespace.
+type namespaceQuota struct {
+	// name is the name of the DeviceQuota.
+	name      string
+	namespace string
+	limit     int64
+	// used is the capacity taken by the volumes of the namespace on each
+	// node, including their growth reserves.
+	used map[string]int64
+	// volumes are the volumes counted in used.
+	volumes map[string]bool
+}
+
+// newNamespaceQuota returns the quota along with the capacity taken by the
+// volumes of its namespace on its device pool. The volume being created is
+// left out, so that a retried request isn't counted twice. The volumes are
+// counted till their DeviceVolume is gone, i.e. till their partition is
+// deleted.
+func newNamespaceQuota(quota *apis.DeviceQuota, vols []apis.DeviceVolume, volName string) *namespaceQuota {
+	q := &namespaceQuota{
+		name:      quota.Name,
+		namespace: quota.Spec.Namespace,
+		limit:     quota.Spec.Capacity.Value(),
+		used:      map[string]int64{},
+		volumes:   map[string]bool{},
+	}
+	for i := range vols {
+		vol := &vols[i]
+		if vol.Name == volName ||
+			vol.Annotations[device.PVCNamespaceKey] != q.namespace ||
+			vol.Spec.DevName != quota.Spec.DeviceName {
+			continue
+		}
+		size, _ := strconv.ParseInt(vol.Spec.Capacity, 10, 64)
+		reserve, _ := strconv.ParseInt(vol.Spec.GrowthReserve, 10, 64)
+		q.used[vol.Spec.OwnerNodeID] += getAllocatedCapacity(vol, size) + reserve
+		q.volumes[vol.Name] = true
+	}
+	return q
+}
+
+// errQuotaExceeded is the cause of the errors returned when a volume can't
+// fit the quota of its namespace.
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// check returns an error if size bytes can't fit the quota on the node,
+// after accounting for the reserved bytes booked against the quota by the
+// in-flight requests.
+func (q *namespaceQuota) check(node string, reserved, size int64) error {
+	used := q.used[node]
+	if used+reserved+size > q.limit {
+		return errors.Wrapf(errQuotaExceeded,
+			"node %s has %d of the %d bytes allotted by quota %s used with %d bytes reserved, can not fit %d bytes",
+			node, used, q.limit, q.name, reserved, size)
+	}
+	return nil
+}
+
+// findQuota returns the DeviceQuota of the namespace on the device pool.
+// If there are more than one, the one with the least capacity is returned.
+func findQuota(objs []interface{}, namespace, deviceName string) *apis.DeviceQuota {
+	var found *apis.DeviceQuota
+	for _, obj := range objs {
+		quota, ok := obj.(*apis.DeviceQuota)
+		if !ok || quota.Spec.Namespace != namespace || quota.Spec.DeviceName != deviceName {
+			continue
+		}
+		if found == nil || quota.Spec.Capacity.Cmp(found.Spec.Capacity) < 0 {
+			found = quota
+		}
+	}
+	return found
+}
+
+// getNamespaceQuota returns the quota of the namespace of the volume's claim
+// on the device pool of the volume. It returns nil if the namespace has no
+// quota or if the namespace of the claim is not known, i.e. the external
+// provisioner doesn't pass the claim metadata.
+func (cs *controller) getNamespaceQuota(volName string, params *VolumeParams) (*namespaceQuota, error) {
+	if params.PVCNamespace == "" || cs.deviceQuotaInformer == nil {
+		return nil, nil
+	}
+	quota := findQuota(cs.deviceQuotaInformer.GetStore().List(), params.PVCNamespace, params.DeviceName)
+	if quota == nil {
+		return nil, nil
+	}
+	vols, err := device.ListDeviceVolumes()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the volumes of quota %s", quota.Name)
+	}
+	return newNamespaceQuota(quota, vols.Items, volName), nil
+}
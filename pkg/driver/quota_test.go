@@ -0,0 +1,138 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+func newQuota(name, namespace, deviceName string, capacity int64) *apis.DeviceQuota {
+	return &apis.DeviceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: apis.DeviceQuotaSpec{
+			Namespace:  namespace,
+			DeviceName: deviceName,
+			Capacity:   *resource.NewQuantity(capacity, resource.BinarySI),
+		},
+	}
+}
+
+func newQuotaVolume(name, namespace, deviceName, node string, size, reserve int64) apis.DeviceVolume {
+	vol := apis.DeviceVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{device.PVCNamespaceKey: namespace},
+		},
+	}
+	vol.Spec.DevName = deviceName
+	vol.Spec.OwnerNodeID = node
+	vol.Spec.Capacity = strconv.FormatInt(size, 10)
+	if reserve > 0 {
+		vol.Spec.GrowthReserve = strconv.FormatInt(reserve, 10)
+	}
+	return vol
+}
+
+func TestFindQuota(t *testing.T) {
+	quotas := []interface{}{
+		newQuota("team-a", "team-a", "test-device", 20*Gi),
+		newQuota("team-a-small", "team-a", "test-device", 10*Gi),
+		newQuota("team-a-other", "team-a", "other-device", 5*Gi),
+		newQuota("team-b", "team-b", "test-device", 5*Gi),
+	}
+	assert.Equal(t, "team-a-small", findQuota(quotas, "team-a", "test-device").Name)
+	assert.Equal(t, "team-a-other", findQuota(quotas, "team-a", "other-device").Name)
+	assert.Nil(t, findQuota(quotas, "team-c", "test-device"))
+}
+
+func TestNewNamespaceQuota(t *testing.T) {
+	vols := []apis.DeviceVolume{
+		newQuotaVolume("pvc-1", "team-a", "test-device", "node1", 10*Gi, 0),
+		newQuotaVolume("pvc-2", "team-a", "test-device", "node1", 5*Gi, Gi),
+		newQuotaVolume("pvc-3", "team-a", "test-device", "node2", 5*Gi, 0),
+		newQuotaVolume("pvc-4", "team-b", "test-device", "node1", 10*Gi, 0),
+		newQuotaVolume("pvc-5", "team-a", "other-device", "node1", 10*Gi, 0),
+		newQuotaVolume("pvc-6", "team-a", "test-device", "node1", 10*Gi, 0),
+	}
+	// the partition got allocated larger than requested
+	vols[0].Status.Capacity = strconv.FormatInt(11*Gi, 10)
+
+	q := newNamespaceQuota(newQuota("team-a", "team-a", "test-device", 20*Gi), vols, "pvc-6")
+	assert.Equal(t, int64(20*Gi), q.limit)
+	assert.Equal(t, map[string]int64{"node1": 17 * Gi, "node2": 5 * Gi}, q.used)
+	assert.Equal(t, map[string]bool{"pvc-1": true, "pvc-2": true, "pvc-3": true}, q.volumes)
+}
+
+func TestReserveCapacityQuota(t *testing.T) {
+	cs := newTestController(t, newTestDeviceNode("node1", 100*Gi, 100*Gi),
+		newTestDeviceNode("node2", 100*Gi, 100*Gi))
+	params := &VolumeParams{DeviceName: "test-device", OvercommitRatio: 1}
+	quota := newQuota("team-a", "team-a", "test-device", 20*Gi)
+	vols := []apis.DeviceVolume{newQuotaVolume("pvc-1", "team-a", "test-device", "node1", 15*Gi, 0)}
+
+	// the namespace has 5Gi left on node1
+	_, _, _, err := cs.reserveCapacity("pvc-2", []string{"node1"}, 10*Gi, 0, params,
+		newNamespaceQuota(quota, vols, "pvc-2"), nil)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Contains(t, err.Error(), "namespace team-a exceeds its quota on device test-device")
+
+	// the quota applies to each node separately
+	node, _, release, err := cs.reserveCapacity("pvc-2", []string{"node1", "node2"}, 10*Gi, 0, params,
+		newNamespaceQuota(quota, vols, "pvc-2"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "node2", node)
+	release(false)
+
+	// the in-flight requests are booked against the quota
+	_, _, _, err = cs.reserveCapacity("pvc-3", []string{"node1"}, 4*Gi, 0, params,
+		newNamespaceQuota(quota, vols, "pvc-3"), nil)
+	assert.NoError(t, err)
+	_, _, _, err = cs.reserveCapacity("pvc-4", []string{"node1"}, 4*Gi, 0, params,
+		newNamespaceQuota(quota, vols, "pvc-4"), nil)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// a created volume is not counted twice while its request is in-flight
+	vols = append(vols, newQuotaVolume("pvc-3", "team-a", "test-device", "node1", 4*Gi, 0))
+	_, _, _, err = cs.reserveCapacity("pvc-4", []string{"node1"}, Gi, 0, params,
+		newNamespaceQuota(quota, vols, "pvc-4"), nil)
+	assert.NoError(t, err)
+
+	// deleting a volume releases its capacity
+	vols = vols[1:]
+	_, _, _, err = cs.reserveCapacity("pvc-5", []string{"node1"}, 10*Gi, 0, params,
+		newNamespaceQuota(quota, vols, "pvc-5"), nil)
+	assert.NoError(t, err)
+
+	// other namespaces are not limited by the quota
+	_, _, _, err = cs.reserveCapacity("pvc-6", []string{"node1"}, 30*Gi, 0, params, nil, nil)
+	assert.NoError(t, err)
+
+	// nodes without a DeviceNode are still bound by the quota
+	_, _, _, err = cs.reserveCapacity("pvc-7", []string{"node3"}, 30*Gi, 0, params,
+		newNamespaceQuota(quota, vols, "pvc-7"), nil)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,133 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	k8sapi "github.com/openebs/lib-csi/pkg/client/k8s"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// ReadyzPath is the http path where the node agent reports whether it is
+// ready to serve the volumes, for the startup and readiness probes.
+const ReadyzPath = "/readyz"
+
+// readinessGate holds the node plugin back from publishing the volumes
+// till the disks got discovered and kubelet registered the plugin, which
+// may take a while on the fast booting nodes. Once open, it stays open.
+// A nil gate is always open.
+type readinessGate struct {
+	// mtx serializes the checks till the gate opens.
+	mtx sync.Mutex
+	// discovered checks if the disks got discovered.
+	discovered func() bool
+	// registered checks if kubelet registered the plugin.
+	registered func() (bool, error)
+	// open is set once the gate opened.
+	open int32
+}
+
+// newReadinessGate returns the gate waiting for the discovery of the disks
+// and for the registration of the driver on the node.
+func newReadinessGate(driverName, nodeName string) *readinessGate {
+	var client kubernetes.Interface
+	return &readinessGate{
+		discovered: device.IsDiscovered,
+		registered: func() (bool, error) {
+			if client == nil {
+				cfg, err := k8sapi.Config().Get()
+				if err != nil {
+					return false, errors.Wrap(err, "error building kubeconfig")
+				}
+				if client, err = kubernetes.NewForConfig(cfg); err != nil {
+					return false, errors.Wrap(err, "error building kubernetes clientset")
+				}
+			}
+			csiNode, err := client.StorageV1().CSINodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+			if err != nil {
+				return false, errors.Wrapf(err, "fetch csinode %s", nodeName)
+			}
+			return isDriverRegistered(csiNode, driverName), nil
+		},
+	}
+}
+
+// isDriverRegistered checks if the CSINode lists the driver, which kubelet
+// does once it registered the plugin.
+func isDriverRegistered(csiNode *storagev1.CSINode, driverName string) bool {
+	for _, driver := range csiNode.Spec.Drivers {
+		if driver.Name == driverName {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns nil if the gate is open, else the Unavailable error with
+// the reason it is closed, so that the callers retry.
+func (g *readinessGate) check() error {
+	if g == nil || atomic.LoadInt32(&g.open) == 1 {
+		return nil
+	}
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	if atomic.LoadInt32(&g.open) == 1 {
+		return nil
+	}
+	if !g.discovered() {
+		return status.Error(codes.Unavailable, "node plugin is starting, the disks are not discovered yet")
+	}
+	registered, err := g.registered()
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "node plugin is starting, could not check its registration: %v", err)
+	}
+	if !registered {
+		return status.Error(codes.Unavailable, "node plugin is starting, kubelet has not registered it yet")
+	}
+	atomic.StoreInt32(&g.open, 1)
+	klog.Info("node plugin is ready, the disks got discovered and kubelet registered it")
+	return nil
+}
+
+// isReady checks if the gate is open.
+func (g *readinessGate) isReady() bool {
+	return g.check() == nil
+}
+
+// ServeHTTP responds with 200 once the gate is open, and with 503 along
+// with the reason till then.
+func (g *readinessGate) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := g.check(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprintln(w, status.Convert(err).Message())
+		return
+	}
+	_, _ = fmt.Fprintln(w, "ok")
+}
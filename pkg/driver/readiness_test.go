@@ -0,0 +1,116 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+func TestReadinessGate(t *testing.T) {
+	var (
+		discovered  bool
+		registered  bool
+		registerErr error
+		checks      int
+	)
+	g := &readinessGate{
+		discovered: func() bool { return discovered },
+		registered: func() (bool, error) {
+			checks++
+			return registered, registerErr
+		},
+	}
+
+	steps := []struct {
+		name        string
+		discovered  bool
+		registered  bool
+		registerErr error
+		ready       bool
+	}{
+		{name: "starting"},
+		{name: "discovered", discovered: true},
+		{name: "registration check failure", discovered: true, registerErr: errors.New("timeout")},
+		{name: "registered", discovered: true, registered: true, ready: true},
+		// once open, the gate stays open.
+		{name: "discovery failure later", discovered: false, registered: true, ready: true},
+	}
+	for _, step := range steps {
+		discovered, registered, registerErr = step.discovered, step.registered, step.registerErr
+		err := g.check()
+		if step.ready {
+			if err != nil {
+				t.Errorf("%s: check() unexpected error %v", step.name, err)
+			}
+			continue
+		}
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("%s: check() got %v, want Unavailable", step.name, err)
+		}
+	}
+	if checks != 3 {
+		t.Errorf("registration checked %d times, want 3", checks)
+	}
+
+	var nilGate *readinessGate
+	if err := nilGate.check(); err != nil {
+		t.Errorf("nil gate check() unexpected error %v", err)
+	}
+}
+
+func TestReadinessGateHTTP(t *testing.T) {
+	g := &readinessGate{
+		discovered: func() bool { return false },
+		registered: func() (bool, error) { return true, nil },
+	}
+	tests := []struct {
+		name    string
+		handler http.Handler
+		code    int
+	}{
+		{name: "not ready", handler: g, code: http.StatusServiceUnavailable},
+		{name: "gate disabled", handler: (*readinessGate)(nil), code: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadyzPath, nil))
+			if rec.Code != tt.code {
+				t.Errorf("%s got %d, want %d: %s", ReadyzPath, rec.Code, tt.code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestIsDriverRegistered(t *testing.T) {
+	csiNode := &storagev1.CSINode{Spec: storagev1.CSINodeSpec{Drivers: []storagev1.CSINodeDriver{
+		{Name: "other.csi.io"}, {Name: "device.csi.openebs.io"},
+	}}}
+	if !isDriverRegistered(csiNode, "device.csi.openebs.io") {
+		t.Errorf("isDriverRegistered() = false, want true")
+	}
+	if isDriverRegistered(&storagev1.CSINode{}, "device.csi.openebs.io") {
+		t.Errorf("isDriverRegistered() = true for a node without drivers")
+	}
+}
@@ -0,0 +1,228 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// reservationTTL is the maximum duration a reservation is held. It makes
+// sure that reservations of a create request which never completes (e.g.
+// a stuck node agent) don't keep the capacity booked forever.
+const reservationTTL = 5 * time.Minute
+
+type reservation struct {
+	node      string
+	size      int64
+	expiresAt time.Time
+	// quota is the DeviceQuota the reservation is booked against, if any.
+	quota string
+	// settled is set once the partition of the volume got created. The
+	// reservation is then held till the DeviceNode of the node gets
+	// updated past nodeVersion, i.e. till its free capacity accounts for
+	// the partition.
+	settled     bool
+	nodeVersion string
+}
+
+// capacityReservations tracks the capacity booked on the nodes by the
+// in-flight volume create requests. The free capacity published in the
+// DeviceNode objects doesn't account for the partitions which are yet to
+// be created, so without the reservations, concurrent create requests can
+// all pass the capacity check against the same free region and then all
+// but one fail while creating the partition.
+//
+// A reservation is released as soon as its request fails. Once the
+// partition got created, it is held till the next update of the DeviceNode
+// of the node, as the free capacity of the DeviceNode doesn't account for
+// the partition till the node agent discovers the devices again.
+//
+// The reservations are only kept in memory. If the controller restarts
+// while a create request is in-flight, the request is retried by the
+// external provisioner, which books the capacity again, and the expired
+// reservations are dropped lazily.
+type capacityReservations struct {
+	ttl time.Duration
+	now func() time.Time
+	// nodeVersion returns the resource version of the DeviceNode of the
+	// node, empty if not known.
+	nodeVersion func(node string) string
+
+	mtx          sync.Mutex
+	reservations map[string]reservation
+}
+
+func newCapacityReservations() *capacityReservations {
+	return &capacityReservations{
+		ttl:          reservationTTL,
+		now:          time.Now,
+		nodeVersion:  func(string) string { return "" },
+		reservations: map[string]reservation{},
+	}
+}
+
+// reserve books size bytes for the given volume on the node, provided that
+// available bytes minus the capacity already booked on the node by other
+// volumes can fit the volume. If quota is set, the volume has to fit the
+// quota as well, after accounting for the capacity booked against it by
+// other volumes. If volLimit is set, the node has to be able to hold one
+// more volume, after accounting for the volumes booked on it. It returns a
+// func to call once the request is done, telling whether the partition of
+// the volume got created.
+func (r *capacityReservations) reserve(volName, node string, size, available int64,
+	quota *namespaceQuota, volLimit *nodeVolumeLimit) (func(created bool), error) {
+	version := r.nodeVersion(node)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := r.now()
+	var reserved, quotaReserved, volsReserved int64
+	for name, res := range r.reservations {
+		if !now.Before(res.expiresAt) {
+			delete(r.reservations, name)
+			continue
+		}
+		if res.node != node || name == volName {
+			continue
+		}
+		// the DeviceNode got updated since the partition got created.
+		if res.settled && version != "" && version != res.nodeVersion {
+			delete(r.reservations, name)
+			continue
+		}
+		reserved += res.size
+		// the volumes which got created are already counted on the node.
+		if volLimit != nil && !volLimit.volumes[node][name] {
+			volsReserved++
+		}
+		// the volumes which got created are already counted in the
+		// usage of the quota.
+		if quota != nil && res.quota == quota.name && !quota.volumes[name] {
+			quotaReserved += res.size
+		}
+	}
+	if available-reserved < size {
+		return nil, fmt.Errorf("node %s has %d bytes free with %d bytes reserved, can not fit %d bytes",
+			node, available, reserved, size)
+	}
+
+	if volLimit != nil {
+		if err := volLimit.check(node, volsReserved); err != nil {
+			return nil, err
+		}
+	}
+
+	res := reservation{
+		node:      node,
+		size:      size,
+		expiresAt: now.Add(r.ttl),
+	}
+	if quota != nil {
+		if err := quota.check(node, quotaReserved, size); err != nil {
+			return nil, err
+		}
+		res.quota = quota.name
+	}
+	r.reservations[volName] = res
+	return func(created bool) {
+		if created {
+			r.settle(volName, node)
+			return
+		}
+		r.release(volName, node)
+	}, nil
+}
+
+// settle holds the reservation of the volume whose partition got created
+// on the node till the DeviceNode of the node gets updated, or for the ttl
+// if the DeviceNode is never updated.
+func (r *capacityReservations) settle(volName, node string) {
+	version := r.nodeVersion(node)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	res, ok := r.reservations[volName]
+	if !ok || res.node != node {
+		return
+	}
+	res.settled = true
+	res.nodeVersion = version
+	res.expiresAt = r.now().Add(r.ttl)
+	r.reservations[volName] = res
+}
+
+// release drops the reservation of the volume on the given node.
+func (r *capacityReservations) release(volName, node string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if res, ok := r.reservations[volName]; ok && res.node == node {
+		delete(r.reservations, volName)
+	}
+}
+
+// reservationState is the json view of a reservation.
+type reservationState struct {
+	Volume    string    `json:"volume"`
+	Node      string    `json:"node"`
+	Size      int64     `json:"size"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Quota     string    `json:"quota,omitempty"`
+	// Settled is set once the partition of the volume got created.
+	Settled bool `json:"settled,omitempty"`
+}
+
+// list returns the reservations which are not expired, ordered by volume.
+func (r *capacityReservations) list() []reservationState {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := r.now()
+	states := []reservationState{}
+	for name, res := range r.reservations {
+		if !now.Before(res.expiresAt) {
+			continue
+		}
+		states = append(states, reservationState{
+			Volume:    name,
+			Node:      res.node,
+			Size:      res.size,
+			ExpiresAt: res.expiresAt,
+			Quota:     res.quota,
+			Settled:   res.settled,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Volume < states[j].Volume
+	})
+	return states
+}
+
+// serveHTTP serves the reservations as json.
+func (r *capacityReservations) serveHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(r.list()); err != nil {
+		klog.Errorf("could not encode reservations: %v", err)
+	}
+}
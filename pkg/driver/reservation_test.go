@@ -0,0 +1,143 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapacityReservationsConcurrent(t *testing.T) {
+	r := newCapacityReservations()
+
+	const (
+		requests  = 50
+		size      = 10 * Gi
+		available = 100 * Gi
+	)
+
+	var (
+		wg       sync.WaitGroup
+		reserved int32
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := r.reserve(fmt.Sprintf("pvc-%d", i), "node1", size, available, nil, nil); err == nil {
+				atomic.AddInt32(&reserved, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(available/size), reserved,
+		"concurrent requests must not book more than the available capacity")
+
+	// other nodes are not affected by the reservations on node1
+	_, err := r.reserve("pvc-other", "node2", size, size, nil, nil)
+	assert.NoError(t, err)
+}
+
+func TestCapacityReservationsRelease(t *testing.T) {
+	now := time.Now()
+	r := newCapacityReservations()
+	r.now = func() time.Time { return now }
+
+	release, err := r.reserve("pvc-1", "node1", 10, 10, nil, nil)
+	assert.NoError(t, err)
+
+	_, err = r.reserve("pvc-2", "node1", 10, 10, nil, nil)
+	assert.Error(t, err, "region is booked by pvc-1")
+
+	// retry of the same volume must not count its own reservation
+	_, err = r.reserve("pvc-1", "node1", 10, 10, nil, nil)
+	assert.NoError(t, err)
+
+	release(false)
+	release2, err := r.reserve("pvc-2", "node1", 10, 10, nil, nil)
+	assert.NoError(t, err, "released capacity must be available again")
+
+	// reservations which are never released expire after the ttl
+	now = now.Add(reservationTTL)
+	_, err = r.reserve("pvc-3", "node1", 10, 10, nil, nil)
+	assert.NoError(t, err, "expired reservation must not block the capacity")
+
+	// releasing an expired and re-booked reservation is a no-op
+	release2(false)
+	_, err = r.reserve("pvc-4", "node1", 10, 10, nil, nil)
+	assert.Error(t, err, "region is booked by pvc-3")
+}
+
+func TestCapacityReservationsSettle(t *testing.T) {
+	now := time.Now()
+	version := "1"
+	r := newCapacityReservations()
+	r.now = func() time.Time { return now }
+	r.nodeVersion = func(node string) string { return version }
+
+	done, err := r.reserve("pvc-1", "node1", 10, 10, nil, nil)
+	assert.NoError(t, err)
+	now = now.Add(time.Minute)
+	done(true)
+
+	// the DeviceNode doesn't account for the partition yet
+	_, err = r.reserve("pvc-2", "node1", 10, 10, nil, nil)
+	assert.Error(t, err, "created partition must stay booked till the DeviceNode is updated")
+
+	// the DeviceNode got updated with the partition, its free capacity is
+	// checked as it is
+	version = "2"
+	_, err = r.reserve("pvc-2", "node1", 10, 10, nil, nil)
+	assert.NoError(t, err, "settled reservation must be dropped once the DeviceNode is updated")
+
+	// the settled reservations expire after the ttl when the DeviceNode is
+	// never updated
+	done, err = r.reserve("pvc-3", "node2", 10, 10, nil, nil)
+	assert.NoError(t, err)
+	done(true)
+	now = now.Add(reservationTTL)
+	_, err = r.reserve("pvc-4", "node2", 10, 10, nil, nil)
+	assert.NoError(t, err, "settled reservation must expire")
+}
+
+func TestCapacityReservationsList(t *testing.T) {
+	now := time.Now()
+	r := newCapacityReservations()
+	r.now = func() time.Time { return now }
+
+	assert.Empty(t, r.list())
+
+	_, err := r.reserve("pvc-2", "node1", 10, 100, nil, nil)
+	assert.NoError(t, err)
+	_, err = r.reserve("pvc-1", "node2", 20, 100, nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []reservationState{
+		{Volume: "pvc-1", Node: "node2", Size: 20, ExpiresAt: now.Add(reservationTTL)},
+		{Volume: "pvc-2", Node: "node1", Size: 10, ExpiresAt: now.Add(reservationTTL)},
+	}, r.list())
+
+	// expired reservations are not listed
+	now = now.Add(reservationTTL)
+	assert.Empty(t, r.list())
+}
@@ -0,0 +1,118 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// Policies for rounding the requested capacity of the volumes to the
+// rounding unit.
+const (
+	RoundUp      = "up"
+	RoundDown    = "down"
+	RoundNearest = "nearest"
+)
+
+// Units the requested capacity of the volumes gets rounded to. An unset
+// unit keeps the rounding of getRoundedCapacity.
+const (
+	RoundToSector    = "sector"
+	RoundToAlignment = "alignment"
+)
+
+// parseCapacityRounding validates the rounding policy and unit of the
+// capacity of the volumes. Setting only the policy rounds to the
+// partition alignment.
+func parseCapacityRounding(policy, unit string) (string, string, error) {
+	switch policy {
+	case "":
+		if unit == "" {
+			return "", "", nil
+		}
+		policy = RoundUp
+	case RoundUp, RoundDown, RoundNearest:
+	default:
+		return "", "", errors.Errorf("invalid capacityRounding %q, must be %s, %s or %s",
+			policy, RoundUp, RoundDown, RoundNearest)
+	}
+	switch unit {
+	case "":
+		unit = RoundToAlignment
+	case RoundToSector, RoundToAlignment:
+	default:
+		return "", "", errors.Errorf("invalid capacityRoundingUnit %q, must be %s or %s",
+			unit, RoundToSector, RoundToAlignment)
+	}
+	return policy, unit, nil
+}
+
+// roundingUnitBytes returns the size in bytes of the rounding unit.
+func roundingUnitBytes(unit string) int64 {
+	if unit == RoundToSector {
+		return device.SectorSize
+	}
+	return device.PartitionAlignmentBytes
+}
+
+// resolveCapacity returns the size in bytes of the volume for the capacity
+// range of the request. The required bytes are the bytes of the storage
+// requested by the claim, as kubernetes resolves the quantity, so 100G is
+// 100000000000 bytes and 100Gi is 107374182400 bytes.
+//
+// Without a rounding policy, the size is rounded up by getRoundedCapacity.
+// Otherwise it is rounded to the rounding unit following the policy, and
+// it fails with OutOfRange if the rounded size is less than the required
+// bytes, as kubernetes doesn't bind a volume smaller than its claim, or
+// more than the limit.
+func resolveCapacity(capRange *csi.CapacityRange, params *VolumeParams) (int64, error) {
+	required, limit := capRange.GetRequiredBytes(), capRange.GetLimitBytes()
+	if params.CapacityRounding == "" {
+		return getRoundedCapacity(required), nil
+	}
+
+	unit := roundingUnitBytes(params.CapacityRoundingUnit)
+	var size int64
+	switch params.CapacityRounding {
+	case RoundDown:
+		size = required / unit * unit
+	case RoundNearest:
+		size = (required + unit/2) / unit * unit
+	default:
+		size = (required + unit - 1) / unit * unit
+	}
+	if size < unit {
+		size = unit
+	}
+
+	if size < required {
+		return 0, status.Errorf(codes.OutOfRange,
+			"%d bytes requested, rounded %s to the %s is %d bytes, which is less than requested",
+			required, params.CapacityRounding, params.CapacityRoundingUnit, size)
+	}
+	if limit > 0 && size > limit {
+		return 0, status.Errorf(codes.OutOfRange,
+			"%d bytes requested, rounded %s to the %s is %d bytes, which is more than the limit of %d bytes",
+			required, params.CapacityRounding, params.CapacityRoundingUnit, size, limit)
+	}
+	return size, nil
+}
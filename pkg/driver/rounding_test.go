@@ -0,0 +1,77 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestResolveCapacity(t *testing.T) {
+	tests := map[string]struct {
+		request   string
+		limit     string
+		policy    string
+		unit      string
+		expected  int64
+		expectErr bool
+	}{
+		// the claims are resolved to bytes by kubernetes, G is a power
+		// of 10 and Gi a power of 2.
+		"G, default rounding":         {request: "100G", expected: 94 * Gi},
+		"Gi, default rounding":        {request: "100Gi", expected: 100 * Gi},
+		"M, default rounding":         {request: "100M", expected: 96 * Mi},
+		"G, up to alignment":          {request: "100G", policy: RoundUp, unit: RoundToAlignment, expected: 95368 * Mi},
+		"Gi, up to alignment":         {request: "100Gi", policy: RoundUp, unit: RoundToAlignment, expected: 100 * Gi},
+		"G, up to sector":             {request: "100G", policy: RoundUp, unit: RoundToSector, expected: 100 * GB},
+		"M, up to sector":             {request: "1000001", policy: RoundUp, unit: RoundToSector, expected: 1000448},
+		"G, down to alignment":        {request: "100G", policy: RoundDown, unit: RoundToAlignment, expectErr: true},
+		"Gi, down to alignment":       {request: "100Gi", policy: RoundDown, unit: RoundToAlignment, expected: 100 * Gi},
+		"G, down to sector":           {request: "100G", policy: RoundDown, unit: RoundToSector, expected: 100 * GB},
+		"G, nearest alignment":        {request: "100G", policy: RoundNearest, unit: RoundToAlignment, expectErr: true},
+		"nearest alignment above":     {request: "1572865", policy: RoundNearest, unit: RoundToAlignment, expected: 2 * Mi},
+		"Gi, nearest alignment":       {request: "100Gi", policy: RoundNearest, unit: RoundToAlignment, expected: 100 * Gi},
+		"nearest sector":              {request: "1000300", policy: RoundNearest, unit: RoundToSector, expected: 1000448},
+		"sub-sector request":          {request: "1", policy: RoundUp, unit: RoundToSector, expected: 512},
+		"rounded up within the limit": {request: "100G", limit: "101G", policy: RoundUp, unit: RoundToAlignment, expected: 95368 * Mi},
+		"rounded up above the limit":  {request: "100G", limit: "100G", policy: RoundUp, unit: RoundToAlignment, expectErr: true},
+	}
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			request := resource.MustParse(test.request)
+			capRange := &csi.CapacityRange{RequiredBytes: request.Value()}
+			if test.limit != "" {
+				limit := resource.MustParse(test.limit)
+				capRange.LimitBytes = limit.Value()
+			}
+			params := &VolumeParams{CapacityRounding: test.policy, CapacityRoundingUnit: test.unit}
+			size, err := resolveCapacity(capRange, params)
+			if test.expectErr {
+				assert.Equal(t, codes.OutOfRange, status.Code(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, size)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	k8sapi "github.com/openebs/lib-csi/pkg/client/k8s"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// StaleMountsCondition is the type of the node condition set when the
+// stale mounts of the node exceed the threshold.
+const StaleMountsCondition corev1.NodeConditionType = "DeviceLocalPVStaleMounts"
+
+// staleMountsListed bounds the number of stale mounts listed in the
+// message of the node condition.
+const staleMountsListed = 5
+
+// StaleMounts is the number of stale mounts found on the node by the last
+// check.
+var StaleMounts = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "device_localpv_stale_mounts",
+	Help: "Number of mounts of the partitions of the driver which don't belong to a live volume or pod.",
+})
+
+// runStaleMountChecker looks for the stale mounts of the node every
+// interval, until stopCh is closed.
+func runStaleMountChecker(kubeletDir string, interval time.Duration, threshold int, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+		checkStaleMounts(kubeletDir, threshold)
+	}
+}
+
+// checkStaleMounts exports the number of stale mounts and sets the node
+// condition as per the threshold.
+func checkStaleMounts(kubeletDir string, threshold int) {
+	stale, err := device.FindStaleMounts(kubeletDir)
+	if err != nil {
+		klog.Errorf("stale mounts: %v", err)
+		return
+	}
+	StaleMounts.Set(float64(len(stale)))
+	for _, m := range stale {
+		klog.Warningf("stale mounts: %s of volume %s: %s", m.MountPath, m.Volume, m.Reason)
+	}
+
+	cond := staleMountsCondition(stale, threshold, metav1.Now())
+	if err = patchNodeCondition(device.NodeID, cond); err != nil {
+		klog.Errorf("stale mounts: set condition of node %s: %v", device.NodeID, err)
+	}
+}
+
+// staleMountsCondition returns the node condition for the stale mounts,
+// true if there are more than threshold of them.
+func staleMountsCondition(stale []device.StaleMount, threshold int, now metav1.Time) corev1.NodeCondition {
+	cond := corev1.NodeCondition{
+		Type:               StaleMountsCondition,
+		Status:             corev1.ConditionFalse,
+		Reason:             "NoStaleMounts",
+		Message:            fmt.Sprintf("%d stale mounts, the threshold is %d", len(stale), threshold),
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+	if len(stale) <= threshold {
+		return cond
+	}
+
+	var paths []string
+	for i, m := range stale {
+		if i == staleMountsListed {
+			paths = append(paths, "...")
+			break
+		}
+		paths = append(paths, m.MountPath)
+	}
+	cond.Status = corev1.ConditionTrue
+	cond.Reason = "StaleMountsFound"
+	cond.Message = fmt.Sprintf("%d stale mounts, the threshold is %d: %s",
+		len(stale), threshold, strings.Join(paths, ", "))
+	return cond
+}
+
+// patchNodeCondition sets the condition in the status of the node, keeping
+// its last transition time if the status didn't change.
+func patchNodeCondition(nodeName string, cond corev1.NodeCondition) error {
+	client, err := k8sapi.Clientset().Get()
+	if err != nil {
+		return err
+	}
+	node, err := client.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	for _, existing := range node.Status.Conditions {
+		if existing.Type == cond.Type && existing.Status == cond.Status {
+			cond.LastTransitionTime = existing.LastTransitionTime
+		}
+	}
+
+	// the conditions are merged by their type
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.NodeCondition{cond},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Nodes().PatchStatus(context.TODO(), nodeName, patch)
+	return err
+}
@@ -0,0 +1,63 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+func TestStaleMountsCondition(t *testing.T) {
+	var stale []device.StaleMount
+	for _, path := range []string{"/mnt/a", "/mnt/b", "/mnt/c", "/mnt/d", "/mnt/e", "/mnt/f"} {
+		stale = append(stale, device.StaleMount{Volume: "pvc-1", MountPath: path, Reason: "volume pvc-1 is being deleted"})
+	}
+	now := metav1.Now()
+
+	tests := map[string]struct {
+		stale     []device.StaleMount
+		threshold int
+		status    corev1.ConditionStatus
+	}{
+		"no stale mounts":       {stale: nil, threshold: 0, status: corev1.ConditionFalse},
+		"any stale mount":       {stale: stale[:1], threshold: 0, status: corev1.ConditionTrue},
+		"at the threshold":      {stale: stale[:2], threshold: 2, status: corev1.ConditionFalse},
+		"above the threshold":   {stale: stale[:3], threshold: 2, status: corev1.ConditionTrue},
+		"more than listed ones": {stale: stale, threshold: 0, status: corev1.ConditionTrue},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cond := staleMountsCondition(tt.stale, tt.threshold, now)
+			assert.Equal(t, StaleMountsCondition, cond.Type)
+			assert.Equal(t, tt.status, cond.Status)
+			assert.Equal(t, now, cond.LastTransitionTime)
+			if tt.status == corev1.ConditionTrue {
+				assert.Contains(t, cond.Message, tt.stale[0].MountPath)
+			}
+		})
+	}
+
+	cond := staleMountsCondition(stale, 0, now)
+	assert.False(t, strings.Contains(cond.Message, "/mnt/f"), "only the first stale mounts are listed")
+	assert.True(t, strings.HasSuffix(cond.Message, "..."))
+}
@@ -0,0 +1,125 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxStatsCacheEntries bounds the number of volumes whose stats are cached.
+const maxStatsCacheEntries = 1024
+
+type statsCacheEntry struct {
+	path      string
+	usage     []*csi.VolumeUsage
+	expiresAt time.Time
+}
+
+// volumeStatsCache keeps the recent NodeGetVolumeStats results keyed by
+// volume id, so that rapid repeated queries from kubelet don't end up
+// calling statfs for every volume again and again.
+type volumeStatsCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mtx     sync.Mutex
+	entries map[string]statsCacheEntry
+
+	requests *prometheus.CounterVec
+}
+
+func newVolumeStatsCache(ttl time.Duration) *volumeStatsCache {
+	return &volumeStatsCache{
+		ttl:        ttl,
+		maxEntries: maxStatsCacheEntries,
+		now:        time.Now,
+		entries:    map[string]statsCacheEntry{},
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "openebs",
+			Subsystem: "volume_stats",
+			Name:      "cache_requests_total",
+			Help:      "Number of volume stats requests served from (hit) or missed by the stats cache",
+		}, []string{"result"}),
+	}
+}
+
+// get returns the cached usage of the volume if present and not expired.
+func (c *volumeStatsCache) get(volID, path string) ([]*csi.VolumeUsage, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	e, ok := c.entries[volID]
+	if !ok || e.path != path || !c.now().Before(e.expiresAt) {
+		c.requests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	c.requests.WithLabelValues("hit").Inc()
+	return e.usage, true
+}
+
+// set caches the usage of the volume, evicting the expired entries or
+// the entry closest to expiry if the cache is full.
+func (c *volumeStatsCache) set(volID, path string, usage []*csi.VolumeUsage) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	now := c.now()
+	if _, ok := c.entries[volID]; !ok && len(c.entries) >= c.maxEntries {
+		var oldest string
+		for id, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, id)
+				continue
+			}
+			if oldest == "" || e.expiresAt.Before(c.entries[oldest].expiresAt) {
+				oldest = id
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[volID] = statsCacheEntry{
+		path:      path,
+		usage:     usage,
+		expiresAt: now.Add(c.ttl),
+	}
+}
+
+// invalidate removes the cached usage of the volume.
+func (c *volumeStatsCache) invalidate(volID string) {
+	c.mtx.Lock()
+	delete(c.entries, volID)
+	c.mtx.Unlock()
+}
+
+func (c *volumeStatsCache) Describe(descs chan<- *prometheus.Desc) {
+	c.requests.Describe(descs)
+}
+
+func (c *volumeStatsCache) Collect(metrics chan<- prometheus.Metric) {
+	c.requests.Collect(metrics)
+}
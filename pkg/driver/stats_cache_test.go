@@ -0,0 +1,62 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeStatsCache(t *testing.T) {
+	now := time.Now()
+	c := newVolumeStatsCache(5 * time.Second)
+	c.maxEntries = 2
+	c.now = func() time.Time { return now }
+
+	usage := []*csi.VolumeUsage{{Unit: csi.VolumeUsage_BYTES, Total: 10}}
+
+	_, ok := c.get("vol1", "/mnt/vol1")
+	assert.False(t, ok, "empty cache must miss")
+
+	c.set("vol1", "/mnt/vol1", usage)
+	got, ok := c.get("vol1", "/mnt/vol1")
+	assert.True(t, ok)
+	assert.Equal(t, usage, got)
+
+	_, ok = c.get("vol1", "/mnt/other")
+	assert.False(t, ok, "different path must miss")
+
+	now = now.Add(5 * time.Second)
+	_, ok = c.get("vol1", "/mnt/vol1")
+	assert.False(t, ok, "expired entry must miss")
+
+	c.set("vol1", "/mnt/vol1", usage)
+	c.invalidate("vol1")
+	_, ok = c.get("vol1", "/mnt/vol1")
+	assert.False(t, ok, "invalidated entry must miss")
+
+	c.set("vol1", "/mnt/vol1", usage)
+	now = now.Add(time.Second)
+	c.set("vol2", "/mnt/vol2", usage)
+	c.set("vol3", "/mnt/vol3", usage)
+	assert.Len(t, c.entries, 2, "cache must stay bounded")
+	_, ok = c.get("vol1", "/mnt/vol1")
+	assert.False(t, ok, "entry closest to expiry must be evicted")
+}
@@ -0,0 +1,64 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// topologySegments returns the topology segments of the node advertised in
+// NodeGetInfo. All the labels of the node are segments unless the topology
+// keys to import are configured, in which case only those are, so that the
+// nodes advertise the same keys whatever their other labels.
+func topologySegments(nodeLabels map[string]string, keys []string) map[string]string {
+	if len(keys) > 0 {
+		segments := device.TopologyLabels(nodeLabels, keys)
+		for _, key := range keys {
+			if _, ok := segments[key]; !ok {
+				klog.Warningf("topology label %s is not set on the node", key)
+			}
+		}
+		return segments
+	}
+	segments := make(map[string]string, len(nodeLabels))
+	for key, value := range nodeLabels {
+		segments[key] = value
+	}
+	return segments
+}
+
+// ownerTopologyLabels returns the topology labels imported from the node
+// owning a volume, to be set on the DeviceVolume.
+func (cs *controller) ownerTopologyLabels(owner string) map[string]string {
+	keys := cs.driver.config.TopologyLabels
+	if len(keys) == 0 {
+		return nil
+	}
+	obj, exists, err := cs.k8sNodeInformer.GetIndexer().GetByKey(owner)
+	if err != nil || !exists {
+		klog.Warningf("could not get the node %s for its topology labels: %v", owner, err)
+		return nil
+	}
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return nil
+	}
+	return device.TopologyLabels(node.Labels, keys)
+}
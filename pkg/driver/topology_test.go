@@ -0,0 +1,107 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openebs/device-localpv/pkg/config"
+)
+
+var rackNodeLabels = map[string]string{
+	"kubernetes.io/hostname":        "node-1",
+	"topology.kubernetes.io/region": "eu-west",
+	"topology.kubernetes.io/zone":   "eu-west-1a",
+	"example.com/rack":              "rack-7",
+}
+
+func TestTopologySegments(t *testing.T) {
+	tests := map[string]struct {
+		keys []string
+		want map[string]string
+	}{
+		"all the labels": {keys: nil, want: rackNodeLabels},
+		"configured keys": {
+			keys: []string{"topology.kubernetes.io/zone", "example.com/rack"},
+			want: map[string]string{"topology.kubernetes.io/zone": "eu-west-1a", "example.com/rack": "rack-7"},
+		},
+		"key missing on the node": {
+			keys: []string{"example.com/rack", "example.com/row"},
+			want: map[string]string{"example.com/rack": "rack-7"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			segments := topologySegments(rackNodeLabels, test.keys)
+			assert.Equal(t, test.want, segments)
+			// the driver keys added to the segments must not leak into
+			// the labels of the cached node.
+			segments["openebs.io/nodename"] = "node-1"
+			assert.NotContains(t, rackNodeLabels, "openebs.io/nodename")
+		})
+	}
+}
+
+func TestOwnerTopologyLabels(t *testing.T) {
+	k8sNodes := cache.NewSharedIndexInformer(&cache.ListWatch{}, &corev1.Node{}, 0, cache.Indexers{})
+	assert.NoError(t, k8sNodes.GetIndexer().Add(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: rackNodeLabels},
+	}))
+
+	tests := map[string]struct {
+		keys  []string
+		owner string
+		want  map[string]string
+	}{
+		"nothing imported": {keys: nil, owner: "node-1", want: nil},
+		"rack and zone": {
+			keys: []string{"topology.kubernetes.io/zone", "example.com/rack"}, owner: "node-1",
+			want: map[string]string{"topology.kubernetes.io/zone": "eu-west-1a", "example.com/rack": "rack-7"},
+		},
+		"unknown node": {keys: []string{"example.com/rack"}, owner: "node-2", want: nil},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cs := &controller{
+				driver:          &CSIDriver{config: &config.Config{TopologyLabels: test.keys}},
+				k8sNodeInformer: k8sNodes,
+			}
+			assert.Equal(t, test.want, cs.ownerTopologyLabels(test.owner))
+		})
+	}
+}
+
+func TestFilterNodesByRack(t *testing.T) {
+	k8sNodes := cache.NewSharedIndexInformer(&cache.ListWatch{}, &corev1.Node{}, 0, cache.Indexers{})
+	for name, rack := range map[string]string{"node-1": "rack-7", "node-2": "rack-7", "node-3": "rack-8"} {
+		assert.NoError(t, k8sNodes.GetIndexer().Add(&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"example.com/rack": rack}},
+		}))
+	}
+	cs := &controller{k8sNodeInformer: k8sNodes}
+
+	// the capacity of a rack, advertised as a topology segment, is the
+	// capacity of its nodes only.
+	names, err := cs.filterNodesByTopology(map[string]string{"example.com/rack": "rack-7"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"node-1", "node-2"}, names)
+}
@@ -0,0 +1,78 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// TrimmedBytesTotal counts the bytes trimmed by the periodic fstrim of the
+// volumes.
+var TrimmedBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "openebs",
+	Subsystem: "device_volume",
+	Name:      "trimmed_bytes_total",
+	Help:      "Number of bytes discarded by the periodic fstrim of the volume.",
+}, []string{"volumename"})
+
+// runTrimmer trims the filesystems of the volumes of the node every
+// interval, until stopCh is closed.
+func runTrimmer(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+		trimVolumes()
+	}
+}
+
+// trimVolumes runs fstrim on the ready volumes of the node, one at a time
+// to spread the discards over time.
+func trimVolumes() {
+	vols, err := device.ListDeviceVolumes()
+	if err != nil {
+		klog.Errorf("trim: list volumes: %v", err)
+		return
+	}
+	for i := range vols.Items {
+		vol := &vols.Items[i]
+		if vol.Spec.OwnerNodeID != device.NodeID ||
+			vol.Status.State != device.DeviceStatusReady ||
+			vol.DeletionTimestamp != nil {
+			continue
+		}
+		trimmed, skipped, err := device.TrimVolume(vol)
+		switch {
+		case err != nil:
+			klog.Errorf("trim: volume %s: %v", vol.Name, err)
+		case skipped != "":
+			klog.V(4).Infof("trim: skipping volume %s: %s", vol.Name, skipped)
+		default:
+			klog.Infof("trim: trimmed %d bytes of volume %s", trimmed, vol.Name)
+			TrimmedBytesTotal.WithLabelValues(vol.Name).Add(float64(trimmed))
+		}
+	}
+}
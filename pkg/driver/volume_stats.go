@@ -0,0 +1,66 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/sys/unix"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// getVolumeUsage returns the bytes and the inodes usage of a volume out of
+// the statfs of its filesystem. When the root directory of the volume is
+// limited by a project quota, the limit of the quota is reported as the
+// total and the usage of the project as the used, the available is capped
+// by what is left on the filesystem.
+func getVolumeUsage(sfs *unix.Statfs_t, quota *device.ProjectQuota) []*csi.VolumeUsage {
+	bytes := &csi.VolumeUsage{
+		Unit:      csi.VolumeUsage_BYTES,
+		Total:     int64(sfs.Blocks) * int64(sfs.Bsize),
+		Used:      int64(sfs.Blocks-sfs.Bfree) * int64(sfs.Bsize),
+		Available: int64(sfs.Bavail) * int64(sfs.Bsize),
+	}
+	inodes := &csi.VolumeUsage{
+		Unit:      csi.VolumeUsage_INODES,
+		Total:     int64(sfs.Files),
+		Used:      int64(sfs.Files - sfs.Ffree),
+		Available: int64(sfs.Ffree),
+	}
+
+	if quota != nil && quota.BlockLimit > 0 {
+		applyQuotaLimit(bytes, quota.BlockLimit, quota.BlockUsed)
+	}
+	if quota != nil && quota.InodeLimit > 0 {
+		applyQuotaLimit(inodes, quota.InodeLimit, quota.InodeUsed)
+	}
+	return []*csi.VolumeUsage{bytes, inodes}
+}
+
+// applyQuotaLimit reports the usage against the quota limit
+func applyQuotaLimit(usage *csi.VolumeUsage, limit, used int64) {
+	available := limit - used
+	if available > usage.Available {
+		available = usage.Available
+	}
+	if available < 0 {
+		available = 0
+	}
+	usage.Total = limit
+	usage.Used = used
+	usage.Available = available
+}
@@ -0,0 +1,79 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+func TestGetVolumeUsage(t *testing.T) {
+	// 100 blocks of 4KiB with 40 free, 30 of them available, 1000 inodes
+	// with 900 free
+	sfs := &unix.Statfs_t{Bsize: 4096, Blocks: 100, Bfree: 40, Bavail: 30, Files: 1000, Ffree: 900}
+
+	tests := []struct {
+		name   string
+		quota  *device.ProjectQuota
+		bytes  csi.VolumeUsage
+		inodes csi.VolumeUsage
+	}{
+		{
+			name:   "no quota",
+			bytes:  csi.VolumeUsage{Unit: csi.VolumeUsage_BYTES, Total: 409600, Used: 245760, Available: 122880},
+			inodes: csi.VolumeUsage{Unit: csi.VolumeUsage_INODES, Total: 1000, Used: 100, Available: 900},
+		},
+		{
+			name:   "block and inode quota",
+			quota:  &device.ProjectQuota{ID: 42, BlockLimit: 102400, BlockUsed: 40960, InodeLimit: 50, InodeUsed: 10},
+			bytes:  csi.VolumeUsage{Unit: csi.VolumeUsage_BYTES, Total: 102400, Used: 40960, Available: 61440},
+			inodes: csi.VolumeUsage{Unit: csi.VolumeUsage_INODES, Total: 50, Used: 10, Available: 40},
+		},
+		{
+			name:   "block quota only",
+			quota:  &device.ProjectQuota{ID: 42, BlockLimit: 102400, BlockUsed: 40960, InodeUsed: 10},
+			bytes:  csi.VolumeUsage{Unit: csi.VolumeUsage_BYTES, Total: 102400, Used: 40960, Available: 61440},
+			inodes: csi.VolumeUsage{Unit: csi.VolumeUsage_INODES, Total: 1000, Used: 100, Available: 900},
+		},
+		{
+			name:   "quota above the free space of the filesystem",
+			quota:  &device.ProjectQuota{ID: 42, BlockLimit: 409600, BlockUsed: 4096},
+			bytes:  csi.VolumeUsage{Unit: csi.VolumeUsage_BYTES, Total: 409600, Used: 4096, Available: 122880},
+			inodes: csi.VolumeUsage{Unit: csi.VolumeUsage_INODES, Total: 1000, Used: 100, Available: 900},
+		},
+		{
+			name:   "usage over the limit",
+			quota:  &device.ProjectQuota{ID: 42, BlockLimit: 4096, BlockUsed: 8192},
+			bytes:  csi.VolumeUsage{Unit: csi.VolumeUsage_BYTES, Total: 4096, Used: 8192, Available: 0},
+			inodes: csi.VolumeUsage{Unit: csi.VolumeUsage_INODES, Total: 1000, Used: 100, Available: 900},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := getVolumeUsage(sfs, tt.quota)
+			if assert.Len(t, usage, 2) {
+				assert.Equal(t, tt.bytes, *usage[0])
+				assert.Equal(t, tt.inodes, *usage[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"github.com/openebs/lib-csi/pkg/common/errors"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// nodeVolumeLimit is the number of volumes a node can hold across all of
+// its devices, along with the volumes already on the nodes.
+type nodeVolumeLimit struct {
+	limit int64
+	// volumes are the volumes on each node.
+	volumes map[string]map[string]bool
+}
+
+// newNodeVolumeLimit returns the limit along with the volumes on each node.
+// The volume being created is left out, so that a retried request isn't
+// counted twice. The volumes are counted till their DeviceVolume is gone,
+// i.e. till their partition is deleted.
+func newNodeVolumeLimit(limit int64, vols []apis.DeviceVolume, volName string) *nodeVolumeLimit {
+	l := &nodeVolumeLimit{
+		limit:   limit,
+		volumes: map[string]map[string]bool{},
+	}
+	for i := range vols {
+		vol := &vols[i]
+		if vol.Name == volName {
+			continue
+		}
+		node := vol.Spec.OwnerNodeID
+		if l.volumes[node] == nil {
+			l.volumes[node] = map[string]bool{}
+		}
+		l.volumes[node][vol.Name] = true
+	}
+	return l
+}
+
+// errVolumeLimitReached is the cause of the errors returned when a node
+// holds the maximum number of volumes.
+var errVolumeLimitReached = errors.New("volume limit reached")
+
+// check returns an error if the node can't hold one more volume, after
+// accounting for the volumes reserved on it by the in-flight requests.
+func (l *nodeVolumeLimit) check(node string, reserved int64) error {
+	count := int64(len(l.volumes[node]))
+	if count+reserved >= l.limit {
+		return errors.Wrapf(errVolumeLimitReached,
+			"node %s holds %d volumes with %d reserved, the maximum is %d",
+			node, count, reserved, l.limit)
+	}
+	return nil
+}
+
+// getNodeVolumeLimit returns the limit of the number of volumes on a node,
+// nil if the number is not limited.
+func (cs *controller) getNodeVolumeLimit(volName string) (*nodeVolumeLimit, error) {
+	if cs.driver.config.MaxVolumesPerNode <= 0 {
+		return nil, nil
+	}
+	vols, err := device.ListDeviceVolumes()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the volumes for the volume limit")
+	}
+	return newNodeVolumeLimit(cs.driver.config.MaxVolumesPerNode, vols.Items, volName), nil
+}
@@ -0,0 +1,97 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestNewNodeVolumeLimit(t *testing.T) {
+	vols := []apis.DeviceVolume{
+		newQuotaVolume("pvc-1", "team-a", "test-device", "node1", Gi, 0),
+		newQuotaVolume("pvc-2", "team-b", "other-device", "node1", Gi, 0),
+		newQuotaVolume("pvc-3", "team-a", "test-device", "node2", Gi, 0),
+		newQuotaVolume("pvc-4", "team-a", "test-device", "node1", Gi, 0),
+	}
+	l := newNodeVolumeLimit(3, vols, "pvc-4")
+	assert.Equal(t, map[string]map[string]bool{
+		"node1": {"pvc-1": true, "pvc-2": true},
+		"node2": {"pvc-3": true},
+	}, l.volumes)
+
+	assert.NoError(t, l.check("node1", 0))
+	assert.Error(t, l.check("node1", 1))
+	assert.NoError(t, l.check("node3", 2))
+}
+
+func TestReserveCapacityVolumeLimit(t *testing.T) {
+	cs := newTestController(t, newTestDeviceNode("node1", 100*Gi, 100*Gi),
+		newTestDeviceNode("node2", 100*Gi, 100*Gi))
+	params := &VolumeParams{DeviceName: "test-device", OvercommitRatio: 1}
+	// node1 holds volumes of all the devices up to the limit
+	vols := []apis.DeviceVolume{
+		newQuotaVolume("pvc-1", "team-a", "test-device", "node1", Gi, 0),
+		newQuotaVolume("pvc-2", "team-a", "other-device", "node1", Gi, 0),
+	}
+
+	_, _, _, err := cs.reserveCapacity("pvc-3", []string{"node1"}, Gi, 0, params, nil,
+		newNodeVolumeLimit(2, vols, "pvc-3"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Contains(t, err.Error(), "no node can hold more than 2 volumes")
+
+	// the next selected node is picked
+	node, _, release, err := cs.reserveCapacity("pvc-3", []string{"node1", "node2"}, Gi, 0, params, nil,
+		newNodeVolumeLimit(2, vols, "pvc-3"))
+	assert.NoError(t, err)
+	assert.Equal(t, "node2", node)
+	release(false)
+
+	// the in-flight requests are counted
+	_, _, _, err = cs.reserveCapacity("pvc-3", []string{"node2"}, Gi, 0, params, nil,
+		newNodeVolumeLimit(2, vols, "pvc-3"))
+	assert.NoError(t, err)
+	_, _, _, err = cs.reserveCapacity("pvc-4", []string{"node2"}, Gi, 0, params, nil,
+		newNodeVolumeLimit(2, vols, "pvc-4"))
+	assert.NoError(t, err)
+	_, _, _, err = cs.reserveCapacity("pvc-5", []string{"node2"}, Gi, 0, params, nil,
+		newNodeVolumeLimit(2, vols, "pvc-5"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// a created volume is not counted twice while its request is in-flight
+	vols = append(vols, newQuotaVolume("pvc-3", "team-a", "test-device", "node2", Gi, 0))
+	_, _, _, err = cs.reserveCapacity("pvc-4", []string{"node2"}, Gi, 0, params, nil,
+		newNodeVolumeLimit(2, vols, "pvc-4"))
+	assert.NoError(t, err)
+
+	// deleting a volume frees its slot
+	vols = vols[1:]
+	_, _, _, err = cs.reserveCapacity("pvc-5", []string{"node1"}, Gi, 0, params, nil,
+		newNodeVolumeLimit(2, vols, "pvc-5"))
+	assert.NoError(t, err)
+
+	// nodes without a DeviceNode are still bound by the limit
+	vols = append(vols, newQuotaVolume("pvc-7", "team-a", "test-device", "node3", Gi, 0))
+	_, _, _, err = cs.reserveCapacity("pvc-6", []string{"node3"}, Gi, 0, params, nil,
+		newNodeVolumeLimit(1, vols, "pvc-6"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
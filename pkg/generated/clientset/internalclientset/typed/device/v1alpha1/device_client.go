@@ -27,6 +27,7 @@ import (
 type LocalV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	DeviceNodesGetter
+	DeviceQuotasGetter
 	DeviceVolumesGetter
 }
 
@@ -39,6 +40,10 @@ func (c *LocalV1alpha1Client) DeviceNodes(namespace string) DeviceNodeInterface
 	return newDeviceNodes(c, namespace)
 }
 
+func (c *LocalV1alpha1Client) DeviceQuotas(namespace string) DeviceQuotaInterface {
+	return newDeviceQuotas(c, namespace)
+}
+
 func (c *LocalV1alpha1Client) DeviceVolumes(namespace string) DeviceVolumeInterface {
 	return newDeviceVolumes(c, namespace)
 }
@@ -32,6 +32,10 @@ func (c *FakeLocalV1alpha1) DeviceNodes(namespace string) v1alpha1.DeviceNodeInt
 	return &FakeDeviceNodes{c, namespace}
 }
 
+func (c *FakeLocalV1alpha1) DeviceQuotas(namespace string) v1alpha1.DeviceQuotaInterface {
+	return &FakeDeviceQuotas{c, namespace}
+}
+
 func (c *FakeLocalV1alpha1) DeviceVolumes(namespace string) v1alpha1.DeviceVolumeInterface {
 	return &FakeDeviceVolumes{c, namespace}
 }
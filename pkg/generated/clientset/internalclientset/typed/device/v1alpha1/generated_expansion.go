@@ -20,4 +20,6 @@ package v1alpha1
 
 type DeviceNodeExpansion interface{}
 
+type DeviceQuotaExpansion interface{}
+
 type DeviceVolumeExpansion interface{}
@@ -26,6 +26,8 @@ import (
 type Interface interface {
 	// DeviceNodes returns a DeviceNodeInformer.
 	DeviceNodes() DeviceNodeInformer
+	// DeviceQuotas returns a DeviceQuotaInformer.
+	DeviceQuotas() DeviceQuotaInformer
 	// DeviceVolumes returns a DeviceVolumeInformer.
 	DeviceVolumes() DeviceVolumeInformer
 }
@@ -46,6 +48,11 @@ func (v *version) DeviceNodes() DeviceNodeInformer {
 	return &deviceNodeInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// DeviceQuotas returns a DeviceQuotaInformer.
+func (v *version) DeviceQuotas() DeviceQuotaInformer {
+	return &deviceQuotaInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // DeviceVolumes returns a DeviceVolumeInformer.
 func (v *version) DeviceVolumes() DeviceVolumeInformer {
 	return &deviceVolumeInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
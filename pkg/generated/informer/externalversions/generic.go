@@ -55,6 +55,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=local.openebs.io, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithResource("devicenodes"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Local().V1alpha1().DeviceNodes().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("devicequotas"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Local().V1alpha1().DeviceQuotas().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("devicevolumes"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Local().V1alpha1().DeviceVolumes().Informer()}, nil
 
@@ -26,6 +26,14 @@ type DeviceNodeListerExpansion interface{}
 // DeviceNodeNamespaceLister.
 type DeviceNodeNamespaceListerExpansion interface{}
 
+// DeviceQuotaListerExpansion allows custom methods to be added to
+// DeviceQuotaLister.
+type DeviceQuotaListerExpansion interface{}
+
+// DeviceQuotaNamespaceListerExpansion allows custom methods to be added to
+// DeviceQuotaNamespaceLister.
+type DeviceQuotaNamespaceListerExpansion interface{}
+
 // DeviceVolumeListerExpansion allows custom methods to be added to
 // DeviceVolumeLister.
 type DeviceVolumeListerExpansion interface{}
@@ -29,6 +29,7 @@ import (
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 
+	"github.com/openebs/device-localpv/pkg/device"
 	clientset "github.com/openebs/device-localpv/pkg/generated/clientset/internalclientset"
 	openebsScheme "github.com/openebs/device-localpv/pkg/generated/clientset/internalclientset/scheme"
 	informers "github.com/openebs/device-localpv/pkg/generated/informer/externalversions"
@@ -64,8 +65,29 @@ type NodeController struct {
 	// pollInterval controls the polling frequency of syncing up the device metadata.
 	pollInterval time.Duration
 
+	// verifyInterval controls the frequency of verifying the device metadata
+	// against the partition tables of the disks. Zero disables it.
+	verifyInterval time.Duration
+
 	// ownerRef is used to set the owner reference to devicenode objects.
 	ownerRef metav1.OwnerReference
+
+	// missing holds the missing devices for the grace period.
+	missing *missingDevices
+
+	// devices discovers the disks of the node.
+	devices device.DeviceManager
+
+	// topologyKeys are the keys of the labels of the kubernetes node
+	// copied onto the DeviceNode.
+	topologyKeys []string
+
+	// getNodeLabels fetches the labels of the kubernetes node.
+	getNodeLabels getNodeLabelsFunc
+
+	// newNodeClient returns the client creating and updating the
+	// DeviceNode objects.
+	newNodeClient newNodeClientFunc
 }
 
 // NodeControllerBuilder is the builder object for controller.
@@ -106,8 +128,10 @@ func (cb *NodeControllerBuilder) withNodeSynced(sl informers.SharedInformerFacto
 	return cb
 }
 
-// withWorkqueue adds workqueue to controller object.
+// withWorkqueue adds workqueue to controller object. The workqueue reports
+// its metrics to WorkqueueMetrics.
 func (cb *NodeControllerBuilder) withWorkqueueRateLimiting() *NodeControllerBuilder {
+	workqueue.SetProvider(WorkqueueMetrics)
 	cb.NodeController.workqueue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Node")
 	return cb
 }
@@ -143,6 +167,32 @@ func (cb *NodeControllerBuilder) withPollInterval(interval time.Duration) *NodeC
 	return cb
 }
 
+func (cb *NodeControllerBuilder) withVerifyInterval(interval time.Duration) *NodeControllerBuilder {
+	cb.NodeController.verifyInterval = interval
+	return cb
+}
+
+func (cb *NodeControllerBuilder) withMissingGracePeriod(gracePeriod time.Duration) *NodeControllerBuilder {
+	cb.NodeController.missing = newMissingDevices(gracePeriod)
+	return cb
+}
+
+func (cb *NodeControllerBuilder) withDeviceManager(devices device.DeviceManager) *NodeControllerBuilder {
+	cb.NodeController.devices = devices
+	return cb
+}
+
+func (cb *NodeControllerBuilder) withTopologyLabels(kubeClient kubernetes.Interface, keys []string) *NodeControllerBuilder {
+	cb.NodeController.topologyKeys = keys
+	cb.NodeController.getNodeLabels = newNodeLabelsGetter(kubeClient)
+	return cb
+}
+
+func (cb *NodeControllerBuilder) withNodeClient(newNodeClient newNodeClientFunc) *NodeControllerBuilder {
+	cb.NodeController.newNodeClient = newNodeClient
+	return cb
+}
+
 func (cb *NodeControllerBuilder) withOwnerReference(ownerRef metav1.OwnerReference) *NodeControllerBuilder {
 	cb.NodeController.ownerRef = ownerRef
 	return cb
@@ -0,0 +1,40 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/builder/nodebuilder"
+)
+
+// nodeClient creates, fetches and updates the DeviceNode objects of a
+// namespace on the api server.
+type nodeClient interface {
+	Create(node *apis.DeviceNode) (*apis.DeviceNode, error)
+	Get(name string, opts metav1.GetOptions) (*apis.DeviceNode, error)
+	Update(node *apis.DeviceNode) (*apis.DeviceNode, error)
+}
+
+// newNodeClientFunc returns the client of the DeviceNode objects of the
+// namespace.
+type newNodeClientFunc func(namespace string) nodeClient
+
+func newNodeKubeclient(namespace string) nodeClient {
+	return nodebuilder.NewKubeclient().WithNamespace(namespace)
+}
@@ -19,8 +19,10 @@ package devicenode
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/runtime"
@@ -35,7 +37,14 @@ import (
 )
 
 func (c *NodeController) listDeviceNames() ([]apis.Device, error) {
-	return device.GetDiskDetails()
+	start := time.Now()
+	discovered, err := c.devices.GetDiskDetails()
+	DiscoveryDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, err
+	}
+	DiscoveredDevices.Set(float64(len(discovered)))
+	return c.missing.hold(discovered, time.Now()), nil
 }
 
 // syncHandler compares the actual state with the desired, and attempts to
@@ -51,6 +60,20 @@ func (c *NodeController) syncHandler(key string) error {
 	return c.syncNode(namespace, name)
 }
 
+// reportSlowReconcile records a warning event on the device node whose
+// reconcile took longer than the slow reconcile threshold.
+func (c *NodeController) reportSlowReconcile(slow *device.SlowReconcile) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(slow.Key)
+	if err != nil {
+		return
+	}
+	node, err := c.NodeLister.DeviceNodes(namespace).Get(name)
+	if err != nil {
+		return
+	}
+	c.recorder.Event(node, corev1.EventTypeWarning, device.SlowReconcileReason, slow.String())
+}
+
 // syncNode is the function which tries to converge to a desired state for the
 // DeviceNode
 func (c *NodeController) syncNode(namespace string, name string) error {
@@ -64,27 +87,83 @@ func (c *NodeController) syncNode(namespace string, name string) error {
 		node = cachedNode.DeepCopy()
 	}
 
-	devices, err := c.listDeviceNames()
+	var spec apis.DeviceNodeSpec
+	var weights, quarantined string
+	if node != nil {
+		spec = node.Spec
+		weights = node.Annotations[device.DeviceWeightsKey]
+		quarantined = node.Annotations[device.QuarantinedDevicesKey]
+	}
+	// the free space of the devices leaves out their protected regions
+	c.devices.SetProtectedDevices(protectedDevices(spec))
+	c.devices.SetDeviceWeights(device.ParseDeviceWeights(weights))
+	c.devices.SetQuarantinedDevices(device.ParseQuarantinedDevices(quarantined))
+	// the partition tables are grown before the discovery, so that the
+	// devices report their new number of entries.
+	if err = c.devices.GrowPartitionTables(spec.MaxPartitionEntries); err != nil {
+		klog.Errorf("device node controller: grow partition tables: %v", err)
+		if node != nil {
+			c.recorder.Event(node, corev1.EventTypeWarning, device.PartitionTableResizeFailedReason, err.Error())
+		}
+	}
+
+	discovered, err := c.listDeviceNames()
 	if err != nil {
 		return err
 	}
-	klog.Infof("Devices List %+v", devices)
+	klog.Infof("Devices List %+v", discovered)
+
+	// the volumes on the devices left out by the spec are still present
+	if err = c.syncVolumeDisks(discovered); err != nil {
+		klog.Errorf("device node controller: sync volume disks: %v", err)
+	}
+
+	devices, excluded := filterDevices(spec, discovered)
+	// the disks are listed in no stable order, the devices are stored
+	// sorted so that a reordering alone doesn't update the node.
+	sortDevices(devices)
+	c.devices.SetExcludedDevices(excluded)
+	TrackedDevices.Set(float64(len(devices)))
+	c.reportFullDevices(node, devices)
+	reportEfficiency(devices)
+
+	labels := nodeLabels(devices)
+	// the topology labels of the kubernetes node are copied as they are,
+	// failing to fetch them leaves the recorded ones in place.
+	topology, err := c.topologyLabels(name)
+	if err != nil {
+		klog.Errorf("device node controller: import topology labels: %v", err)
+	}
+	for key, value := range topology {
+		labels[key] = value
+	}
 
 	if node == nil { // if it doesn't exists, create device node object
 		if node, err = nodebuilder.NewBuilder().
 			WithNamespace(namespace).WithName(name).
 			WithDevices(devices).
+			WithLabels(labels).
 			WithOwnerReferences(c.ownerRef).
 			Build(); err != nil {
 			return err
 		}
 
 		klog.Infof("device node controller: creating new node object for %+v", node)
-		if node, err = nodebuilder.NewKubeclient().WithNamespace(namespace).Create(node); err != nil {
+		created, err := c.newNodeClient(namespace).Create(node)
+		if err == nil {
+			klog.Infof("device node controller: created node object %s/%s", namespace, name)
+			return nil
+		}
+		if !k8serror.IsAlreadyExists(err) {
 			return fmt.Errorf("create device node %s/%s: %v", namespace, name, err)
 		}
-		klog.Infof("device node controller: created node object %s/%s", namespace, name)
-		return nil
+		// another reconcile created the node since the lister got read,
+		// e.g. on the first start of the agent, so it is updated instead.
+		klog.Infof("device node controller: node object %s/%s created concurrently, updating it", namespace, name)
+		if created, err = c.newNodeClient(namespace).Get(name, metav1.GetOptions{}); err != nil {
+			return fmt.Errorf("get device node %s/%s: %v", namespace, name, err)
+		}
+		node = created
 	}
 
 	// device node already exists check if we need to update it.
@@ -98,19 +177,27 @@ func (c *NodeController) syncNode(namespace string, name string) error {
 	}
 
 	// validate if node devices are upto date.
-	if !equality.Semantic.DeepEqual(node.Devices, devices) {
+	if isDevicesUpdateRequired(node.Devices, devices) {
 		klog.Infof("device node controller: node devices updated current=%+v, required=%+v",
 			node.Devices, devices)
 		node.Devices = devices
 		updateRequired = true
 	}
 
+	// refresh the labels describing the device composition, keeping
+	// the labels applied by the operators intact.
+	if labels, req := mergeLabels(node.Labels, labels); req {
+		klog.Infof("device node controller: node labels updated to %+v", labels)
+		node.Labels = labels
+		updateRequired = true
+	}
+
 	if !updateRequired {
 		return nil
 	}
 
 	klog.Infof("device node controller: updating node object with %+v", node)
-	if node, err = nodebuilder.NewKubeclient().WithNamespace(namespace).Update(node); err != nil {
+	if node, err = c.newNodeClient(namespace).Update(node); err != nil {
 		return fmt.Errorf("update device node %s/%s: %v", namespace, name, err)
 	}
 	klog.Infof("device node controller: updated node object %s/%s", namespace, name)
@@ -142,7 +229,10 @@ func (c *NodeController) updateNode(oldObj, newObj interface{}) {
 	c.enqueueNode(newNode)
 }
 
-// deleteNode is the delete event handler for DeviceNode
+// deleteNode is the delete event handler for DeviceNode. The node agent is
+// running, so the node is not gone and the DeviceNode is recreated. The
+// volumes of the nodes removed from the cluster are marked lost by the
+// controller.
 func (c *NodeController) deleteNode(obj interface{}) {
 	node, ok := obj.(*apis.DeviceNode)
 	if !ok {
@@ -207,18 +297,25 @@ func (c *NodeController) Run(threadiness int, stopCh <-chan struct{}) error {
 
 	klog.Info("Started Node workers")
 
+	if c.verifyInterval > 0 {
+		go c.runVerify(c.verifyInterval, stopCh)
+	}
+
+	refresh := watchRefreshSignal(stopCh)
 	timer := time.NewTimer(0)
 	defer timer.Stop()
+	item := device.DeviceNamespace + "/" + device.NodeID
 	for {
 		select {
 		case <-timer.C:
+			timer.Reset(c.pollInterval)
+		case <-refresh:
+			klog.Info("Refreshing the devices on SIGHUP")
 		case <-stopCh:
 			klog.Info("Shutting down Node controller")
 			return nil
 		}
-		item := device.DeviceNamespace + "/" + device.NodeID
 		c.workqueue.Add(item) // add the item to worker queue.
-		timer.Reset(c.pollInterval)
 	}
 }
 
@@ -265,7 +362,13 @@ func (c *NodeController) processNextWorkItem() bool {
 		}
 		// Run the syncHandler, passing it the namespace/name string of the
 		// Node resource to be synced.
-		if err := c.syncHandler(key); err != nil {
+		start := time.Now()
+		err := c.syncHandler(key)
+		device.RecordReconcile("devicenode", key, start, err)
+		if slow := device.ObserveReconcile("devicenode", key, start); slow != nil {
+			c.reportSlowReconcile(slow)
+		}
+		if err != nil {
 			// Put the item back on the workqueue to handle any transient errors.
 			c.workqueue.AddRateLimited(key)
 			return fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error())
@@ -290,20 +393,91 @@ func (c *NodeController) processNextWorkItem() bool {
 // to be set.
 func (c *NodeController) isOwnerRefsUpdateRequired(ownerRefs []metav1.OwnerReference) ([]metav1.OwnerReference, bool) {
 	updated := false
+	found := false
 	reqOwnerRef := c.ownerRef
+	isFalse := false
 	for idx := range ownerRefs {
 		if ownerRefs[idx].UID != reqOwnerRef.UID {
+			// only one owner can be the controller, which is not the
+			// case once the owner is switched between node and workload.
+			if ownerRefs[idx].Controller != nil && *ownerRefs[idx].Controller &&
+				reqOwnerRef.Controller != nil && *reqOwnerRef.Controller {
+				updated = true
+				ownerRefs[idx].Controller = &isFalse
+			}
 			continue
 		}
+		found = true
 		// in case owner reference exists, validate
 		// if controller field is set correctly or not.
 		if !reflect.DeepEqual(ownerRefs[idx].Controller, reqOwnerRef.Controller) {
 			updated = true
 			ownerRefs[idx].Controller = reqOwnerRef.Controller
 		}
-		return ownerRefs, updated
 	}
-	updated = true
-	ownerRefs = append(ownerRefs, reqOwnerRef)
+	if !found {
+		updated = true
+		ownerRefs = append(ownerRefs, reqOwnerRef)
+	}
 	return ownerRefs, updated
 }
+
+// isDevicesUpdateRequired checks if the recorded devices differ from the
+// discovered ones. The informational attributes like the firmware revision
+// and the queue depth are left out, so that they don't trigger updates on
+// their own. They get refreshed along with the other changes, or once when
+// they are missing from the recorded devices, e.g. as the node got recorded
+// by an older release.
+func isDevicesUpdateRequired(current, required []apis.Device) bool {
+	recorded, discovered := withoutInfoAttrs(current), withoutInfoAttrs(required)
+	// the devices recorded unsorted by the older releases are compared
+	// regardless of their order as well.
+	sortDevices(recorded)
+	sortDevices(discovered)
+	return !equality.Semantic.DeepEqual(recorded, discovered) || isInfoBackfillRequired(current, required)
+}
+
+// sortDevices sorts the devices by their name, and by their UUID among the
+// devices of a name.
+func sortDevices(devices []apis.Device) {
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Name != devices[j].Name {
+			return devices[i].Name < devices[j].Name
+		}
+		return devices[i].UUID < devices[j].UUID
+	})
+}
+
+// isInfoBackfillRequired checks if the recorded devices miss the stable
+// informational attributes the discovered ones have. The temperature is
+// left out, as it is refreshed along with the other changes anyway.
+func isInfoBackfillRequired(current, required []apis.Device) bool {
+	recorded := make(map[string]apis.Device, len(current))
+	for _, dev := range current {
+		recorded[dev.UUID] = dev
+	}
+	for _, dev := range required {
+		rec, ok := recorded[dev.UUID]
+		if !ok {
+			continue
+		}
+		if (rec.Firmware == "" && dev.Firmware != "") || (rec.QueueDepth == 0 && dev.QueueDepth != 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutInfoAttrs(devices []apis.Device) []apis.Device {
+	if devices == nil {
+		return nil
+	}
+	result := make([]apis.Device, len(devices))
+	for i, dev := range devices {
+		dev.Firmware = ""
+		dev.QueueDepth = 0
+		dev.Temperature = 0
+		result[i] = dev
+	}
+	return result
+}
@@ -0,0 +1,370 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+	"github.com/openebs/device-localpv/pkg/device/fake"
+	listers "github.com/openebs/device-localpv/pkg/generated/lister/device/v1alpha1"
+)
+
+func TestIsDevicesUpdateRequired(t *testing.T) {
+	recorded := []apis.Device{{
+		Name: "test-device", UUID: "uuid-1",
+		Size: resource.MustParse("100Gi"), Free: resource.MustParse("50Gi"),
+		Firmware: "1.0", QueueDepth: 64,
+	}}
+	withChange := func(change func(*apis.Device)) []apis.Device {
+		devices := []apis.Device{recorded[0]}
+		change(&devices[0])
+		return devices
+	}
+
+	tests := map[string]struct {
+		discovered []apis.Device
+		required   bool
+	}{
+		"unchanged":           {discovered: withChange(func(*apis.Device) {}), required: false},
+		"firmware upgraded":   {discovered: withChange(func(d *apis.Device) { d.Firmware = "2.0" }), required: false},
+		"queue depth tuned":   {discovered: withChange(func(d *apis.Device) { d.QueueDepth = 256 }), required: false},
+		"info not available":  {discovered: withChange(func(d *apis.Device) { d.Firmware, d.QueueDepth = "", 0 }), required: false},
+		"temperature changed": {discovered: withChange(func(d *apis.Device) { d.Temperature = 45 }), required: false},
+		"device got hot":      {discovered: withChange(func(d *apis.Device) { d.Temperature, d.Hot = 60, true }), required: true},
+		"free space changed":  {discovered: withChange(func(d *apis.Device) { d.Free = resource.MustParse("40Gi") }), required: true},
+		"device removed":      {discovered: nil, required: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.required, isDevicesUpdateRequired(recorded, test.discovered))
+		})
+	}
+
+	// the devices recorded by an older release, without the informational
+	// attributes, get them backfilled.
+	older := []apis.Device{recorded[0]}
+	older[0].Firmware, older[0].QueueDepth = "", 0
+	assert.True(t, isDevicesUpdateRequired(older, recorded), "firmware and queue depth missing")
+	older[0].Firmware = "1.0"
+	assert.True(t, isDevicesUpdateRequired(older, recorded), "queue depth missing")
+	assert.False(t, isDevicesUpdateRequired(recorded, older), "discovery without the queue depth")
+
+	// the order of the devices doesn't matter.
+	other := apis.Device{Name: "test-device", UUID: "uuid-0", Size: resource.MustParse("1Ti")}
+	assert.False(t, isDevicesUpdateRequired([]apis.Device{recorded[0], other}, []apis.Device{other, recorded[0]}),
+		"reordered devices")
+}
+
+func TestSyncNode(t *testing.T) {
+	fast := apis.Device{Name: "fast", UUID: "uuid-1", Size: resource.MustParse("100Gi")}
+	slow := apis.Device{Name: "slow", UUID: "uuid-2", Size: resource.MustParse("1Ti")}
+	ownerRef := metav1.OwnerReference{APIVersion: "v1", Kind: "Node", Name: "node-1", UID: types.UID("uid-1")}
+
+	tests := map[string]struct {
+		annotations  map[string]string
+		spec         apis.DeviceNodeSpec
+		recorded     []apis.Device
+		discoveryErr error
+		growErr      error
+		wantErr      bool
+		excluded     []string
+		protected    map[string]uint64
+		weights      map[string]int32
+		quarantined  map[string]bool
+		events       int
+	}{
+		"up to date": {
+			recorded:  []apis.Device{fast, slow},
+			protected: map[string]uint64{},
+		},
+		"blocked device already left out": {
+			spec:      apis.DeviceNodeSpec{BlockedDevices: []string{"slow"}},
+			recorded:  []apis.Device{fast},
+			excluded:  []string{"uuid-2"},
+			protected: map[string]uint64{},
+		},
+		"protected region of the spec": {
+			spec: apis.DeviceNodeSpec{ProtectedLeadingBytes: map[string]resource.Quantity{
+				"uuid-1": resource.MustParse("64Mi"),
+			}},
+			recorded:  []apis.Device{fast, slow},
+			protected: map[string]uint64{"uuid-1": 64 << 20},
+		},
+		"device weights of the annotation": {
+			annotations: map[string]string{device.DeviceWeightsKey: "uuid-1=300, slow=0"},
+			recorded:    []apis.Device{fast, slow},
+			protected:   map[string]uint64{},
+			weights:     map[string]int32{"uuid-1": 300, "slow": 0},
+		},
+		"quarantined devices of the annotation": {
+			annotations: map[string]string{device.QuarantinedDevicesKey: "slow, "},
+			recorded:    []apis.Device{fast, slow},
+			protected:   map[string]uint64{},
+			quarantined: map[string]bool{"slow": true},
+		},
+		"partition tables grown": {
+			spec:      apis.DeviceNodeSpec{MaxPartitionEntries: map[string]int32{"uuid-1": 512}},
+			recorded:  []apis.Device{fast, slow},
+			protected: map[string]uint64{},
+		},
+		"partition tables not grown": {
+			spec:      apis.DeviceNodeSpec{MaxPartitionEntries: map[string]int32{"uuid-1": 100000}},
+			recorded:  []apis.Device{fast, slow},
+			growErr:   errors.New("partition table of disk sdb can hold at most 8184 entries"),
+			protected: map[string]uint64{},
+			events:    1,
+		},
+		"discovery failure": {
+			recorded:     []apis.Device{fast, slow},
+			discoveryErr: errors.New("lsblk failed"),
+			wantErr:      true,
+			protected:    map[string]uint64{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			node := &apis.DeviceNode{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "openebs", Name: "node-1",
+					Annotations:     test.annotations,
+					Labels:          nodeLabels(test.recorded),
+					OwnerReferences: []metav1.OwnerReference{ownerRef},
+				},
+				Spec:    test.spec,
+				Devices: test.recorded,
+			}
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			assert.NoError(t, indexer.Add(node))
+
+			manager := fake.NewDeviceManager(fast, slow)
+			manager.DiscoveryErr = test.discoveryErr
+			manager.GrowErr = test.growErr
+			recorder := record.NewFakeRecorder(10)
+			c := &NodeController{
+				NodeLister: listers.NewDeviceNodeLister(indexer),
+				recorder:   recorder,
+				ownerRef:   ownerRef,
+				devices:    manager,
+			}
+
+			// the node is up to date, so syncNode doesn't reach the
+			// api server for updating it.
+			err := c.syncNode("openebs", "node-1")
+			assert.Equal(t, test.wantErr, err != nil, "syncNode() error %v", err)
+			assert.Equal(t, test.protected, manager.Protected)
+			assert.Equal(t, test.excluded, manager.Excluded)
+			weights := test.weights
+			if weights == nil {
+				weights = map[string]int32{}
+			}
+			assert.Equal(t, weights, manager.Weights)
+			quarantined := test.quarantined
+			if quarantined == nil {
+				quarantined = map[string]bool{}
+			}
+			assert.Equal(t, quarantined, manager.Quarantined)
+			assert.Equal(t, test.spec.MaxPartitionEntries, manager.Grown)
+			assert.Equal(t, test.events, len(recorder.Events))
+		})
+	}
+}
+
+// fakeNodeClient is a nodeClient holding a single node, created by another
+// reconcile when createErr is set.
+type fakeNodeClient struct {
+	node      *apis.DeviceNode
+	createErr error
+	updated   []*apis.DeviceNode
+}
+
+func (f *fakeNodeClient) Create(node *apis.DeviceNode) (*apis.DeviceNode, error) {
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	f.node = node
+	return node, nil
+}
+
+func (f *fakeNodeClient) Get(name string, _ metav1.GetOptions) (*apis.DeviceNode, error) {
+	if f.node == nil {
+		return nil, k8serror.NewNotFound(apis.Resource("devicenode"), name)
+	}
+	return f.node.DeepCopy(), nil
+}
+
+func (f *fakeNodeClient) Update(node *apis.DeviceNode) (*apis.DeviceNode, error) {
+	f.updated = append(f.updated, node)
+	f.node = node
+	return node, nil
+}
+
+func TestSyncNodeCreateRace(t *testing.T) {
+	fast := apis.Device{Name: "fast", UUID: "uuid-1", Size: resource.MustParse("100Gi")}
+	slow := apis.Device{Name: "slow", UUID: "uuid-2", Size: resource.MustParse("1Ti")}
+	ownerRef := metav1.OwnerReference{APIVersion: "v1", Kind: "Node", Name: "node-1", UID: types.UID("uid-1")}
+	alreadyExists := k8serror.NewAlreadyExists(apis.Resource("devicenode"), "node-1")
+
+	tests := map[string]struct {
+		// existing is the node created by the other reconcile.
+		existing    *apis.DeviceNode
+		createErr   error
+		wantErr     bool
+		wantUpdates int
+	}{
+		"created": {},
+		"created concurrently with other devices": {
+			existing: &apis.DeviceNode{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "openebs", Name: "node-1",
+					OwnerReferences: []metav1.OwnerReference{ownerRef}},
+				Devices: []apis.Device{fast},
+			},
+			createErr:   alreadyExists,
+			wantUpdates: 1,
+		},
+		"created concurrently and up to date": {
+			existing: &apis.DeviceNode{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "openebs", Name: "node-1",
+					Labels:          nodeLabels([]apis.Device{fast, slow}),
+					OwnerReferences: []metav1.OwnerReference{ownerRef}},
+				Devices: []apis.Device{fast, slow},
+			},
+			createErr: alreadyExists,
+		},
+		"created and deleted concurrently": {
+			createErr: alreadyExists,
+			wantErr:   true,
+		},
+		"creation failure": {
+			createErr: errors.New("connection refused"),
+			wantErr:   true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// the lister doesn't know the node yet.
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			client := &fakeNodeClient{node: test.existing, createErr: test.createErr}
+			c := &NodeController{
+				NodeLister:    listers.NewDeviceNodeLister(indexer),
+				recorder:      record.NewFakeRecorder(10),
+				ownerRef:      ownerRef,
+				devices:       fake.NewDeviceManager(fast, slow),
+				newNodeClient: func(string) nodeClient { return client },
+			}
+
+			err := c.syncNode("openebs", "node-1")
+			assert.Equal(t, test.wantErr, err != nil, "syncNode() error %v", err)
+			assert.Equal(t, test.wantUpdates, len(client.updated))
+			if !test.wantErr {
+				assert.Equal(t, []apis.Device{fast, slow}, client.node.Devices)
+			}
+		})
+	}
+}
+
+func TestSyncNodeReorderedDevices(t *testing.T) {
+	fast := apis.Device{Name: "fast", UUID: "uuid-1", Size: resource.MustParse("100Gi")}
+	slow := apis.Device{Name: "slow", UUID: "uuid-2", Size: resource.MustParse("1Ti")}
+	slower := apis.Device{Name: "slow", UUID: "uuid-3", Size: resource.MustParse("4Ti")}
+	ownerRef := metav1.OwnerReference{APIVersion: "v1", Kind: "Node", Name: "node-1", UID: types.UID("uid-1")}
+
+	tests := map[string]struct {
+		recorded   []apis.Device
+		discovered []apis.Device
+	}{
+		"discovered in another order": {
+			recorded:   []apis.Device{fast, slow, slower},
+			discovered: []apis.Device{slower, fast, slow},
+		},
+		"recorded unsorted": {
+			recorded:   []apis.Device{slower, slow, fast},
+			discovered: []apis.Device{slow, slower, fast},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			node := &apis.DeviceNode{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "openebs", Name: "node-1",
+					Labels:          nodeLabels(test.recorded),
+					OwnerReferences: []metav1.OwnerReference{ownerRef},
+				},
+				Devices: test.recorded,
+			}
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			assert.NoError(t, indexer.Add(node))
+			client := &fakeNodeClient{node: node}
+			c := &NodeController{
+				NodeLister:    listers.NewDeviceNodeLister(indexer),
+				recorder:      record.NewFakeRecorder(10),
+				ownerRef:      ownerRef,
+				devices:       fake.NewDeviceManager(test.discovered...),
+				newNodeClient: func(string) nodeClient { return client },
+			}
+
+			assert.NoError(t, c.syncNode("openebs", "node-1"))
+			assert.Empty(t, client.updated, "reordered devices updated the node")
+		})
+	}
+}
+
+func TestEnqueueNode(t *testing.T) {
+	namespace, nodeID := device.DeviceNamespace, device.NodeID
+	device.DeviceNamespace, device.NodeID = "tenant-a", "node-1"
+	defer func() { device.DeviceNamespace, device.NodeID = namespace, nodeID }()
+
+	tests := map[string]struct {
+		namespace string
+		name      string
+		queued    bool
+	}{
+		"node of the install":         {namespace: "tenant-a", name: "node-1", queued: true},
+		"node of another install":     {namespace: "tenant-b", name: "node-1"},
+		"node of the default install": {namespace: "openebs", name: "node-1"},
+		"other node of the install":   {namespace: "tenant-a", name: "node-2"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer queue.ShutDown()
+			c := &NodeController{workqueue: queue}
+			c.enqueueNode(&apis.DeviceNode{
+				ObjectMeta: metav1.ObjectMeta{Namespace: test.namespace, Name: test.name},
+			})
+			if !test.queued {
+				assert.Equal(t, 0, queue.Len())
+				return
+			}
+			if assert.Equal(t, 1, queue.Len()) {
+				key, _ := queue.Get()
+				assert.Equal(t, test.namespace+"/"+test.name, key)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// reportEfficiency sets the allocation efficiency of the devices in the
+// metrics, the devices no longer present being dropped.
+func reportEfficiency(devices []apis.Device) {
+	DeviceEfficiency.Reset()
+	for _, dev := range devices {
+		DeviceEfficiency.WithLabelValues(dev.Name, dev.UUID).
+			Set(device.AllocationEfficiency(dev.Used.Value(), dev.Stranded.Value()))
+	}
+}
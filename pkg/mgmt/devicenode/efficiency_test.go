@@ -0,0 +1,54 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestReportEfficiency(t *testing.T) {
+	contiguous := apis.Device{Name: "fast", UUID: "uuid-1",
+		Used: resource.MustParse("800Mi"), Stranded: resource.MustParse("0")}
+	fragmented := apis.Device{Name: "fast", UUID: "uuid-2",
+		Used: resource.MustParse("600Mi"), Stranded: resource.MustParse("200Mi")}
+
+	efficiency := func(dev apis.Device) float64 {
+		var m dto.Metric
+		if err := DeviceEfficiency.WithLabelValues(dev.Name, dev.UUID).Write(&m); err != nil {
+			t.Fatalf("read metric: %v", err)
+		}
+		return m.GetGauge().GetValue()
+	}
+
+	reportEfficiency([]apis.Device{contiguous, fragmented})
+	assert.Equal(t, 1.0, efficiency(contiguous))
+	assert.Equal(t, 0.75, efficiency(fragmented))
+
+	// the devices gone are dropped from the metric
+	reportEfficiency([]apis.Device{contiguous})
+	metrics := make(chan prometheus.Metric, 10)
+	DeviceEfficiency.Collect(metrics)
+	close(metrics)
+	assert.Equal(t, 1, len(metrics))
+}
@@ -0,0 +1,91 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+const (
+	// DeviceFullReason is the reason of the event recorded on the
+	// DeviceNode when no volume fits on one of its devices anymore.
+	DeviceFullReason = "DeviceFull"
+	// DeviceNotFullReason is the reason of the event recorded on the
+	// DeviceNode when a full device has room for a volume again.
+	DeviceNotFullReason = "DeviceNotFull"
+)
+
+// fullTransitions returns the devices which became full and the ones which
+// got room again, since the devices got recorded in the DeviceNode.
+func fullTransitions(recorded, devices []apis.Device) (filled, freed []apis.Device) {
+	wasFull := map[string]bool{}
+	for _, dev := range recorded {
+		wasFull[dev.UUID] = dev.Full
+	}
+	for _, dev := range devices {
+		switch {
+		case dev.Full && !wasFull[dev.UUID]:
+			filled = append(filled, dev)
+		case !dev.Full && wasFull[dev.UUID]:
+			freed = append(freed, dev)
+		}
+	}
+	return filled, freed
+}
+
+// reportFullDevices sets the full state of the devices in the metrics and
+// records an event on the DeviceNode for the devices entering or leaving
+// it. The node is nil till the DeviceNode got created.
+func (c *NodeController) reportFullDevices(node *apis.DeviceNode, devices []apis.Device) {
+	FullDevices.Reset()
+	for _, dev := range devices {
+		var full float64
+		if dev.Full {
+			full = 1
+		}
+		FullDevices.WithLabelValues(dev.Name, dev.UUID).Set(full)
+	}
+
+	var recorded []apis.Device
+	if node != nil {
+		recorded = node.Devices
+	}
+	filled, freed := fullTransitions(recorded, devices)
+	for _, dev := range filled {
+		klog.Warningf("device node controller: device %s (%s) is full, its largest free region is %s",
+			dev.Name, dev.UUID, dev.Free.String())
+		if node != nil {
+			c.recorder.Event(node, corev1.EventTypeWarning, DeviceFullReason,
+				fmt.Sprintf("device %s (%s) is full, its largest free region of %s is smaller than the minimum volume size",
+					dev.Name, dev.UUID, dev.Free.String()))
+		}
+	}
+	for _, dev := range freed {
+		klog.Infof("device node controller: device %s (%s) is no longer full, its largest free region is %s",
+			dev.Name, dev.UUID, dev.Free.String())
+		if node != nil {
+			c.recorder.Event(node, corev1.EventTypeNormal, DeviceNotFullReason,
+				fmt.Sprintf("device %s (%s) is no longer full, its largest free region is %s",
+					dev.Name, dev.UUID, dev.Free.String()))
+		}
+	}
+}
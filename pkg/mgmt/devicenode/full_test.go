@@ -0,0 +1,85 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestReportFullDevices(t *testing.T) {
+	roomy := apis.Device{Name: "fast", UUID: "uuid-1", Free: resource.MustParse("10Gi")}
+	full := apis.Device{Name: "fast", UUID: "uuid-1", Free: resource.MustParse("4Mi"), Full: true}
+	other := apis.Device{Name: "slow", UUID: "uuid-2", Free: resource.MustParse("1Ti")}
+
+	fullMetric := func(dev apis.Device) float64 {
+		var m dto.Metric
+		if err := FullDevices.WithLabelValues(dev.Name, dev.UUID).Write(&m); err != nil {
+			t.Fatalf("read metric: %v", err)
+		}
+		return m.GetGauge().GetValue()
+	}
+
+	tests := []struct {
+		name       string
+		recorded   []apis.Device
+		discovered []apis.Device
+		event      string
+		metric     float64
+	}{
+		{name: "still room", recorded: []apis.Device{roomy, other}, discovered: []apis.Device{roomy, other}, metric: 0},
+		{name: "entering full", recorded: []apis.Device{roomy, other}, discovered: []apis.Device{full, other},
+			event: "Warning " + DeviceFullReason, metric: 1},
+		{name: "still full", recorded: []apis.Device{full, other}, discovered: []apis.Device{full, other}, metric: 1},
+		{name: "leaving full", recorded: []apis.Device{full, other}, discovered: []apis.Device{roomy, other},
+			event: "Normal " + DeviceNotFullReason, metric: 0},
+		{name: "new full device", recorded: []apis.Device{other}, discovered: []apis.Device{full, other},
+			event: "Warning " + DeviceFullReason, metric: 1},
+		{name: "full device removed", recorded: []apis.Device{full, other}, discovered: []apis.Device{other}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &apis.DeviceNode{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "openebs", Name: "node-1"},
+				Devices:    tt.recorded,
+			}
+			recorder := record.NewFakeRecorder(10)
+			c := &NodeController{recorder: recorder}
+			c.reportFullDevices(node, tt.discovered)
+
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			if tt.event == "" {
+				assert.Empty(t, events)
+			} else if assert.Len(t, events, 1) {
+				assert.True(t, strings.HasPrefix(events[0], tt.event+" "), "got event %q, want %s", events[0], tt.event)
+			}
+			assert.Equal(t, tt.metric, fullMetric(full))
+			assert.Equal(t, float64(0), fullMetric(other))
+		})
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"fmt"
+	"runtime"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// Labels set on the DeviceNode object describing the node and its devices.
+// These can be used to select subsets of device nodes, for example
+// kubectl get devicenode -l device.openebs.io/media-mix=ssd-only
+const (
+	ArchLabelKey          = "device.openebs.io/arch"
+	DiskCountLabelKey     = "device.openebs.io/disk-count"
+	TotalCapacityLabelKey = "device.openebs.io/total-capacity"
+	MediaMixLabelKey      = "device.openebs.io/media-mix"
+)
+
+// Values of the media mix label
+const (
+	MediaMixSSDOnly = "ssd-only"
+	MediaMixHDDOnly = "hdd-only"
+	MediaMixMixed   = "mixed"
+	MediaMixUnknown = "unknown"
+)
+
+// nodeLabels returns the labels reflecting the attributes of the node
+// and the given devices.
+func nodeLabels(devices []apis.Device) map[string]string {
+	var totalBytes int64
+	var ssd, hdd, unknown int
+	for _, dev := range devices {
+		totalBytes += dev.Size.Value()
+		switch dev.MediaType {
+		case device.MediaTypeSSD:
+			ssd++
+		case device.MediaTypeHDD:
+			hdd++
+		default:
+			unknown++
+		}
+	}
+
+	mediaMix := MediaMixUnknown
+	switch {
+	case unknown > 0:
+	case ssd > 0 && hdd > 0:
+		mediaMix = MediaMixMixed
+	case ssd > 0:
+		mediaMix = MediaMixSSDOnly
+	case hdd > 0:
+		mediaMix = MediaMixHDDOnly
+	}
+
+	return map[string]string{
+		ArchLabelKey:          runtime.GOARCH,
+		DiskCountLabelKey:     fmt.Sprint(len(devices)),
+		TotalCapacityLabelKey: fmt.Sprintf("%dGi", totalBytes>>30),
+		MediaMixLabelKey:      mediaMix,
+	}
+}
+
+// mergeLabels adds or updates the required labels to the current labels,
+// leaving all the other labels untouched. It returns the merged labels
+// along with a boolean denoting whether any label got changed.
+func mergeLabels(current, required map[string]string) (map[string]string, bool) {
+	updated := false
+	for key, value := range required {
+		if v, ok := current[key]; ok && v == value {
+			continue
+		}
+		if current == nil {
+			current = map[string]string{}
+		}
+		current[key] = value
+		updated = true
+	}
+	return current, updated
+}
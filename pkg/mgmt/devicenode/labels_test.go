@@ -0,0 +1,83 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestNodeLabels(t *testing.T) {
+	newDevice := func(size string, media string) apis.Device {
+		return apis.Device{Size: resource.MustParse(size), MediaType: media}
+	}
+	tests := map[string]struct {
+		devices  []apis.Device
+		count    string
+		capacity string
+		mediaMix string
+	}{
+		"no devices": {
+			count: "0", capacity: "0Gi", mediaMix: MediaMixUnknown,
+		},
+		"ssd only": {
+			devices: []apis.Device{newDevice("100Gi", "ssd"), newDevice("50Gi", "ssd")},
+			count:   "2", capacity: "150Gi", mediaMix: MediaMixSSDOnly,
+		},
+		"hdd only": {
+			devices: []apis.Device{newDevice("1Ti", "hdd")},
+			count:   "1", capacity: "1024Gi", mediaMix: MediaMixHDDOnly,
+		},
+		"mixed": {
+			devices: []apis.Device{newDevice("100Gi", "ssd"), newDevice("1Ti", "hdd")},
+			count:   "2", capacity: "1124Gi", mediaMix: MediaMixMixed,
+		},
+		"undetected media": {
+			devices: []apis.Device{newDevice("100Gi", "ssd"), newDevice("100Gi", "")},
+			count:   "2", capacity: "200Gi", mediaMix: MediaMixUnknown,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			labels := nodeLabels(test.devices)
+			assert.Equal(t, runtime.GOARCH, labels[ArchLabelKey])
+			assert.Equal(t, test.count, labels[DiskCountLabelKey])
+			assert.Equal(t, test.capacity, labels[TotalCapacityLabelKey])
+			assert.Equal(t, test.mediaMix, labels[MediaMixLabelKey])
+		})
+	}
+}
+
+func TestMergeLabels(t *testing.T) {
+	current := map[string]string{"team": "storage", MediaMixLabelKey: MediaMixHDDOnly}
+
+	merged, updated := mergeLabels(current, map[string]string{MediaMixLabelKey: MediaMixMixed})
+	assert.True(t, updated)
+	assert.Equal(t, map[string]string{"team": "storage", MediaMixLabelKey: MediaMixMixed}, merged)
+
+	_, updated = mergeLabels(merged, map[string]string{MediaMixLabelKey: MediaMixMixed})
+	assert.False(t, updated, "unchanged labels must not require an update")
+
+	merged, updated = mergeLabels(nil, map[string]string{MediaMixLabelKey: MediaMixMixed})
+	assert.True(t, updated)
+	assert.Equal(t, map[string]string{MediaMixLabelKey: MediaMixMixed}, merged)
+}
@@ -0,0 +1,179 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// TrackedDevices is the number of devices of the node recorded in the
+// DeviceNode by the last reconcile.
+var TrackedDevices = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "openebs",
+	Subsystem: "device_node",
+	Name:      "devices",
+	Help:      "Number of devices of the node tracked in the DeviceNode.",
+})
+
+// DiscoveryDuration observes the duration of the discoveries of the
+// devices of the node, done on each reconcile of the DeviceNode.
+var DiscoveryDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "device_localpv_discovery_duration_seconds",
+	Help:    "Duration of the discovery of the devices of the node.",
+	Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+})
+
+// DiscoveredDevices is the number of devices found on the node by the last
+// discovery. Unlike TrackedDevices, it leaves out the missing devices held
+// for the grace period and the devices left out by the spec.
+var DiscoveredDevices = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "device_localpv_discovered_devices",
+	Help: "Number of devices found on the node by the last discovery.",
+})
+
+// FullDevices is set for each device of the node recorded in the DeviceNode
+// by the last reconcile, to 1 if no volume fits on it anymore and to 0
+// otherwise.
+var FullDevices = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "device_localpv_device_full",
+	Help: "Whether the largest free region of the device is smaller than the minimum volume size.",
+}, []string{"name", "uuid"})
+
+// DeviceEfficiency is set for each device of the node recorded in the
+// DeviceNode by the last reconcile to its allocation efficiency, its used
+// capacity over its used and stranded capacity.
+var DeviceEfficiency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "device_localpv_device_allocation_efficiency",
+	Help: "Used capacity of the device over its used capacity and its free capacity stranded in the regions too small to be used.",
+}, []string{"name", "uuid"})
+
+// ClassSlots is set for each disk of the node holding volumes of a slot
+// class, to the GPT partition entries taken by the volumes of the class on
+// the disk, as of the last reconcile.
+var ClassSlots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "device_localpv_class_partition_slots",
+	Help: "Number of GPT partition entries taken by the volumes of the slot class on the disk.",
+}, []string{"uuid", "class"})
+
+// WorkqueueMetrics are the standard client-go workqueue metrics of the
+// queues of the controllers, labelled by the name of the queue. It is set
+// as the workqueue metrics provider before the queue of the node controller
+// is created.
+var WorkqueueMetrics = newWorkqueueMetrics()
+
+// workqueueMetrics implements workqueue.MetricsProvider with the metrics
+// client-go workqueues report in the kubernetes components.
+type workqueueMetrics struct {
+	depth                   *prometheus.GaugeVec
+	adds                    *prometheus.CounterVec
+	latency                 *prometheus.HistogramVec
+	workDuration            *prometheus.HistogramVec
+	unfinishedWorkSeconds   *prometheus.GaugeVec
+	longestRunningProcessor *prometheus.GaugeVec
+	retries                 *prometheus.CounterVec
+}
+
+func newWorkqueueMetrics() *workqueueMetrics {
+	labels := []string{"name"}
+	buckets := prometheus.ExponentialBuckets(10e-9, 10, 10)
+	return &workqueueMetrics{
+		depth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: "workqueue",
+			Name:      "depth",
+			Help:      "Current depth of workqueue",
+		}, labels),
+		adds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Subsystem: "workqueue",
+			Name:      "adds_total",
+			Help:      "Total number of adds handled by workqueue",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Subsystem: "workqueue",
+			Name:      "queue_duration_seconds",
+			Help:      "How long in seconds an item stays in workqueue before being requested.",
+			Buckets:   buckets,
+		}, labels),
+		workDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Subsystem: "workqueue",
+			Name:      "work_duration_seconds",
+			Help:      "How long in seconds processing an item from workqueue takes.",
+			Buckets:   buckets,
+		}, labels),
+		unfinishedWorkSeconds: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: "workqueue",
+			Name:      "unfinished_work_seconds",
+			Help: "How many seconds of work has done that is in progress and hasn't " +
+				"been observed by work_duration. Large values indicate stuck threads.",
+		}, labels),
+		longestRunningProcessor: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: "workqueue",
+			Name:      "longest_running_processor_seconds",
+			Help:      "How many seconds has the longest running processor for workqueue been running.",
+		}, labels),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Subsystem: "workqueue",
+			Name:      "retries_total",
+			Help:      "Total number of retries handled by workqueue",
+		}, labels),
+	}
+}
+
+func (m *workqueueMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.depth, m.adds, m.latency, m.workDuration,
+		m.unfinishedWorkSeconds, m.longestRunningProcessor, m.retries}
+}
+
+func (m *workqueueMetrics) Describe(descs chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(descs)
+	}
+}
+
+func (m *workqueueMetrics) Collect(metrics chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(metrics)
+	}
+}
+
+func (m *workqueueMetrics) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return m.depth.WithLabelValues(name)
+}
+
+func (m *workqueueMetrics) NewAddsMetric(name string) workqueue.CounterMetric {
+	return m.adds.WithLabelValues(name)
+}
+
+func (m *workqueueMetrics) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return m.latency.WithLabelValues(name)
+}
+
+func (m *workqueueMetrics) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return m.workDuration.WithLabelValues(name)
+}
+
+func (m *workqueueMetrics) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return m.unfinishedWorkSeconds.WithLabelValues(name)
+}
+
+func (m *workqueueMetrics) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return m.longestRunningProcessor.WithLabelValues(name)
+}
+
+func (m *workqueueMetrics) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return m.retries.WithLabelValues(name)
+}
@@ -0,0 +1,139 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device/fake"
+)
+
+func TestWorkqueueMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	for _, c := range []prometheus.Collector{WorkqueueMetrics, TrackedDevices} {
+		if err := registry.Register(c); err != nil {
+			t.Fatalf("register metrics: %v", err)
+		}
+	}
+
+	c := NewNodeControllerBuilder().withWorkqueueRateLimiting().NodeController
+	defer c.workqueue.ShutDown()
+	c.workqueue.Add("openebs/node1")
+	item, _ := c.workqueue.Get()
+	c.workqueue.AddRateLimited(item)
+	c.workqueue.Done(item)
+	TrackedDevices.Set(2)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	queues := map[string]string{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "name" {
+					queues[f.GetName()] = l.GetValue()
+				}
+			}
+		}
+		if f.GetName() == "openebs_device_node_devices" {
+			queues[f.GetName()] = ""
+		}
+	}
+	for _, name := range []string{
+		"workqueue_depth",
+		"workqueue_adds_total",
+		"workqueue_queue_duration_seconds",
+		"workqueue_work_duration_seconds",
+		"workqueue_unfinished_work_seconds",
+		"workqueue_longest_running_processor_seconds",
+		"workqueue_retries_total",
+	} {
+		if queue, ok := queues[name]; !ok || queue != "Node" {
+			t.Errorf("expected %s of the Node queue, got %q", name, queue)
+		}
+	}
+	if _, ok := queues["openebs_device_node_devices"]; !ok {
+		t.Errorf("expected openebs_device_node_devices to be registered")
+	}
+}
+
+func TestDiscoveryMetrics(t *testing.T) {
+	discoveries := func() uint64 {
+		var m dto.Metric
+		if err := DiscoveryDuration.Write(&m); err != nil {
+			t.Fatalf("write discovery duration: %v", err)
+		}
+		return m.GetHistogram().GetSampleCount()
+	}
+	discovered := func() float64 {
+		var m dto.Metric
+		if err := DiscoveredDevices.Write(&m); err != nil {
+			t.Fatalf("write discovered devices: %v", err)
+		}
+		return m.GetGauge().GetValue()
+	}
+
+	manager := fake.NewDeviceManager(
+		apis.Device{Name: "fast", UUID: "uuid-1"},
+		apis.Device{Name: "slow", UUID: "uuid-2"},
+	)
+	c := &NodeController{devices: manager, missing: newMissingDevices(time.Hour)}
+
+	before := discoveries()
+	if _, err := c.listDeviceNames(); err != nil {
+		t.Fatalf("listDeviceNames() unexpected error %v", err)
+	}
+	if got := discoveries() - before; got != 1 {
+		t.Errorf("expected 1 discovery observed, got %d", got)
+	}
+	if got := discovered(); got != 2 {
+		t.Errorf("expected 2 discovered devices, got %v", got)
+	}
+
+	// a removed disk is no longer counted, even while it is held
+	manager.Devices = manager.Devices[:1]
+	devices, err := c.listDeviceNames()
+	if err != nil {
+		t.Fatalf("listDeviceNames() unexpected error %v", err)
+	}
+	if len(devices) != 2 {
+		t.Errorf("expected the removed disk to be held, got %d devices", len(devices))
+	}
+	if got := discovered(); got != 1 {
+		t.Errorf("expected 1 discovered device, got %v", got)
+	}
+
+	// a failed discovery is timed and keeps the last count
+	manager.DiscoveryErr = errors.New("lsblk failed")
+	if _, err := c.listDeviceNames(); err == nil {
+		t.Errorf("listDeviceNames() expected error")
+	}
+	if got := discoveries() - before; got != 3 {
+		t.Errorf("expected 3 discoveries observed, got %d", got)
+	}
+	if got := discovered(); got != 1 {
+		t.Errorf("expected 1 discovered device, got %v", got)
+	}
+}
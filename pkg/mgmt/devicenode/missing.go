@@ -0,0 +1,96 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// missingDevices dampens the devices disappearing momentarily from the
+// node, e.g. on a reset of the controller or the bus of the disk. A device
+// which goes missing is kept as last seen for the grace period, so that
+// the DeviceNode and the volumes on the device are left untouched if it
+// comes back within it.
+type missingDevices struct {
+	gracePeriod time.Duration
+
+	mtx sync.Mutex
+	// known is the last seen state of the devices, by UUID.
+	known map[string]apis.Device
+	// since is the time the devices in the grace period went missing.
+	since map[string]time.Time
+}
+
+func newMissingDevices(gracePeriod time.Duration) *missingDevices {
+	return &missingDevices{
+		gracePeriod: gracePeriod,
+		known:       map[string]apis.Device{},
+		since:       map[string]time.Time{},
+	}
+}
+
+// hold returns the discovered devices along with the devices missing
+// for less than the grace period.
+func (m *missingDevices) hold(discovered []apis.Device, now time.Time) []apis.Device {
+	if m == nil || m.gracePeriod <= 0 {
+		return discovered
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	present := map[string]bool{}
+	for _, dev := range discovered {
+		present[dev.UUID] = true
+		if since, ok := m.since[dev.UUID]; ok {
+			klog.Infof("device node controller: device %s (%s) is back after %s, leaving the grace period",
+				dev.Name, dev.UUID, now.Sub(since).Round(time.Second))
+			delete(m.since, dev.UUID)
+		}
+	}
+
+	devices := discovered
+	for uuid, dev := range m.known {
+		if present[uuid] {
+			continue
+		}
+		since, ok := m.since[uuid]
+		if !ok {
+			klog.Warningf("device node controller: device %s (%s) is missing, entering the grace period of %s",
+				dev.Name, uuid, m.gracePeriod)
+			since = now
+			m.since[uuid] = now
+		}
+		if now.Sub(since) >= m.gracePeriod {
+			klog.Warningf("device node controller: device %s (%s) is missing for %s, treating it as gone",
+				dev.Name, uuid, now.Sub(since).Round(time.Second))
+			delete(m.since, uuid)
+			continue
+		}
+		devices = append(devices, dev)
+	}
+
+	m.known = map[string]apis.Device{}
+	for _, dev := range devices {
+		m.known[dev.UUID] = dev
+	}
+	return devices
+}
@@ -0,0 +1,62 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestMissingDevicesHold(t *testing.T) {
+	sdb := apis.Device{Name: "test-device", UUID: "uuid-sdb"}
+	sdc := apis.Device{Name: "test-device", UUID: "uuid-sdc"}
+	start := time.Now()
+
+	m := newMissingDevices(time.Minute)
+	assert.Equal(t, []apis.Device{sdb, sdc}, m.hold([]apis.Device{sdb, sdc}, start))
+
+	// a device missing for less than the grace period is kept
+	assert.Equal(t, []apis.Device{sdb, sdc}, m.hold([]apis.Device{sdb}, start.Add(10*time.Second)))
+	assert.Equal(t, []apis.Device{sdb, sdc}, m.hold([]apis.Device{sdb}, start.Add(50*time.Second)))
+
+	// reappearing within the grace period resets it
+	assert.Equal(t, []apis.Device{sdb, sdc}, m.hold([]apis.Device{sdb, sdc}, start.Add(55*time.Second)))
+	assert.Equal(t, []apis.Device{sdb, sdc}, m.hold([]apis.Device{sdb}, start.Add(100*time.Second)))
+
+	// the device is gone once missing for the grace period
+	assert.Equal(t, []apis.Device{sdb}, m.hold([]apis.Device{sdb}, start.Add(160*time.Second)))
+	assert.Equal(t, []apis.Device{sdb}, m.hold([]apis.Device{sdb}, start.Add(170*time.Second)))
+
+	// a new device shows up right away
+	assert.Equal(t, []apis.Device{sdb, sdc}, m.hold([]apis.Device{sdb, sdc}, start.Add(180*time.Second)))
+}
+
+func TestMissingDevicesHoldDisabled(t *testing.T) {
+	sdb := apis.Device{Name: "test-device", UUID: "uuid-sdb"}
+	sdc := apis.Device{Name: "test-device", UUID: "uuid-sdc"}
+
+	var m *missingDevices
+	assert.Equal(t, []apis.Device{sdb}, m.hold([]apis.Device{sdb}, time.Now()))
+
+	m = newMissingDevices(0)
+	m.hold([]apis.Device{sdb, sdc}, time.Now())
+	assert.Equal(t, []apis.Device{sdb}, m.hold([]apis.Device{sdb}, time.Now()))
+}
@@ -0,0 +1,155 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// Owners of the DeviceNode objects
+const (
+	// OwnerNode sets the kubernetes node as the owner of its DeviceNode.
+	OwnerNode = "node"
+	// OwnerWorkload sets the workload running the node agent, i.e. the
+	// DaemonSet or the Deployment, as the owner of the DeviceNode.
+	OwnerWorkload = "workload"
+)
+
+// environment variables set through the downward api, used for
+// resolving the workload running the node agent.
+const (
+	podNameEnv      = "POD_NAME"
+	podNamespaceEnv = "POD_NAMESPACE"
+)
+
+// getObjectFunc fetches the object of given kind, namespace and name.
+type getObjectFunc func(kind, namespace, name string) (metav1.Object, error)
+
+// getOwnerReference returns the owner reference to be set on the DeviceNode
+// as per the given owner.
+func getOwnerReference(kubeClient kubernetes.Interface, owner string) (metav1.OwnerReference, error) {
+	switch owner {
+	case OwnerNode, "":
+		k8sNode, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), device.NodeID, metav1.GetOptions{})
+		if err != nil {
+			return metav1.OwnerReference{}, errors.Wrapf(err, "fetch k8s node %s", device.NodeID)
+		}
+		// as object returned by client go clears all TypeMeta from it.
+		nodeGVK := &schema.GroupVersionKind{
+			Group: "", Version: "v1", Kind: "Node",
+		}
+		return newControllerRef(nodeGVK.GroupVersion().String(), nodeGVK.Kind, k8sNode), nil
+	case OwnerWorkload:
+		podName, podNamespace := os.Getenv(podNameEnv), os.Getenv(podNamespaceEnv)
+		if podName == "" || podNamespace == "" {
+			return metav1.OwnerReference{}, errors.Errorf("%s and %s environment variables must be set",
+				podNameEnv, podNamespaceEnv)
+		}
+		// DeviceNode objects can only be owned by the objects in their namespace
+		if podNamespace != device.DeviceNamespace {
+			return metav1.OwnerReference{}, errors.Errorf(
+				"workload in namespace %s can't own DeviceNode in namespace %s",
+				podNamespace, device.DeviceNamespace)
+		}
+		pod, err := kubeClient.CoreV1().Pods(podNamespace).Get(context.TODO(), podName, metav1.GetOptions{})
+		if err != nil {
+			return metav1.OwnerReference{}, errors.Wrapf(err, "fetch pod %s/%s", podNamespace, podName)
+		}
+		return resolveWorkloadOwner(pod, newObjectGetter(kubeClient))
+	default:
+		return metav1.OwnerReference{}, errors.Errorf("invalid device node owner %q", owner)
+	}
+}
+
+// resolveWorkloadOwner walks up the controller references of the pod and
+// returns the reference to the top most controller, e.g. the Deployment
+// owning the ReplicaSet of the pod, or the DaemonSet owning the pod.
+func resolveWorkloadOwner(pod metav1.Object, getObject getObjectFunc) (metav1.OwnerReference, error) {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return metav1.OwnerReference{}, errors.Errorf("pod %s/%s is not managed by a workload",
+			pod.GetNamespace(), pod.GetName())
+	}
+
+	for {
+		obj, err := getObject(ref.Kind, pod.GetNamespace(), ref.Name)
+		if err != nil {
+			return metav1.OwnerReference{}, err
+		}
+		if obj == nil {
+			// kind of the controller is not followed further
+			break
+		}
+		parent := metav1.GetControllerOf(obj)
+		if parent == nil {
+			break
+		}
+		ref = parent
+	}
+
+	isTrue := true
+	owner := *ref
+	owner.Controller = &isTrue
+	owner.BlockOwnerDeletion = nil
+	return owner, nil
+}
+
+// newObjectGetter returns a getObjectFunc fetching the workload kinds
+// which can own a pod directly or through a ReplicaSet.
+func newObjectGetter(kubeClient kubernetes.Interface) getObjectFunc {
+	return func(kind, namespace, name string) (metav1.Object, error) {
+		var (
+			obj metav1.Object
+			err error
+		)
+		switch kind {
+		case "ReplicaSet":
+			obj, err = kubeClient.AppsV1().ReplicaSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		case "Deployment":
+			obj, err = kubeClient.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		case "DaemonSet":
+			obj, err = kubeClient.AppsV1().DaemonSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		case "StatefulSet":
+			obj, err = kubeClient.AppsV1().StatefulSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		default:
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetch %s %s/%s", kind, namespace, name)
+		}
+		return obj, nil
+	}
+}
+
+func newControllerRef(apiVersion, kind string, obj metav1.Object) metav1.OwnerReference {
+	isTrue := true
+	return metav1.OwnerReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+		Controller: &isTrue,
+	}
+}
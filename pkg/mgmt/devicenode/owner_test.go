@@ -0,0 +1,110 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestResolveWorkloadOwner(t *testing.T) {
+	isTrue := true
+	controllerRef := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{
+			APIVersion: "apps/v1", Kind: kind, Name: name,
+			UID: types.UID(name + "-uid"), Controller: &isTrue, BlockOwnerDeletion: &isTrue,
+		}}
+	}
+	newPod := func(owners []metav1.OwnerReference) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: "agent-1", Namespace: "openebs", OwnerReferences: owners,
+		}}
+	}
+	objects := map[string]metav1.Object{
+		"ReplicaSet/agent-rs": &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Name: "agent-rs", Namespace: "openebs", OwnerReferences: controllerRef("Deployment", "agent"),
+		}},
+		"Deployment/agent": &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name: "agent", Namespace: "openebs",
+		}},
+		"DaemonSet/agent-ds": &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{
+			Name: "agent-ds", Namespace: "openebs",
+		}},
+	}
+	getObject := func(kind, namespace, name string) (metav1.Object, error) {
+		return objects[kind+"/"+name], nil
+	}
+
+	tests := map[string]struct {
+		pod       *corev1.Pod
+		kind      string
+		name      string
+		expectErr bool
+	}{
+		"daemonset":                {pod: newPod(controllerRef("DaemonSet", "agent-ds")), kind: "DaemonSet", name: "agent-ds"},
+		"deployment":               {pod: newPod(controllerRef("ReplicaSet", "agent-rs")), kind: "Deployment", name: "agent"},
+		"unmanaged pod":            {pod: newPod(nil), expectErr: true},
+		"controller of other kind": {pod: newPod(controllerRef("Job", "agent-job")), kind: "Job", name: "agent-job"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			owner, err := resolveWorkloadOwner(test.pod, getObject)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.kind, owner.Kind)
+			assert.Equal(t, test.name, owner.Name)
+			assert.Equal(t, types.UID(test.name+"-uid"), owner.UID)
+			assert.True(t, *owner.Controller)
+			assert.Nil(t, owner.BlockOwnerDeletion)
+		})
+	}
+}
+
+func TestIsOwnerRefsUpdateRequired(t *testing.T) {
+	isTrue, isFalse := true, false
+	c := &NodeController{ownerRef: metav1.OwnerReference{
+		APIVersion: "apps/v1", Kind: "DaemonSet", Name: "agent-ds", UID: "ds-uid", Controller: &isTrue,
+	}}
+
+	nodeRef := metav1.OwnerReference{APIVersion: "v1", Kind: "Node", Name: "node-1", UID: "node-uid"}
+	refs, updated := c.isOwnerRefsUpdateRequired([]metav1.OwnerReference{nodeRef})
+	assert.True(t, updated)
+	assert.Len(t, refs, 2)
+
+	_, updated = c.isOwnerRefsUpdateRequired(refs)
+	assert.False(t, updated)
+
+	refs[1].Controller = &isFalse
+	refs, updated = c.isOwnerRefsUpdateRequired(refs)
+	assert.True(t, updated)
+	assert.True(t, *refs[1].Controller)
+
+	// switching the owner from node to workload leaves a single controller
+	nodeRef.Controller = &isTrue
+	refs, updated = c.isOwnerRefsUpdateRequired([]metav1.OwnerReference{nodeRef})
+	assert.True(t, updated)
+	assert.False(t, *refs[0].Controller)
+	assert.True(t, *refs[1].Controller)
+}
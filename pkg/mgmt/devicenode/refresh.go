@@ -0,0 +1,72 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// refreshDebounce is the time a refresh of the devices waits after the
+// signal requesting it, so that a burst of signals triggers one refresh.
+const refreshDebounce = 2 * time.Second
+
+// watchRefreshSignal returns a channel receiving a value when the devices
+// are to be discovered again on the request of an operator, i.e. on
+// SIGHUP, without waiting for the next poll.
+func watchRefreshSignal(stopCh <-chan struct{}) <-chan struct{} {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	go func() {
+		<-stopCh
+		signal.Stop(sigCh)
+	}()
+	return debounce(sigCh, refreshDebounce, stopCh)
+}
+
+// debounce sends a single value on the returned channel for each burst of
+// values received on in, delay after the first of them. A value not yet
+// received from the returned channel absorbs the later bursts.
+func debounce(in <-chan os.Signal, delay time.Duration, stopCh <-chan struct{}) <-chan struct{} {
+	out := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case <-in:
+			case <-stopCh:
+				return
+			}
+			select {
+			case <-time.After(delay):
+			case <-stopCh:
+				return
+			}
+			// drop the values received during the delay.
+			select {
+			case <-in:
+			default:
+			}
+			select {
+			case out <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return out
+}
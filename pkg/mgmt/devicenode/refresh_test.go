@@ -0,0 +1,57 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_debounce(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	in := make(chan os.Signal, 1)
+	out := debounce(in, 50*time.Millisecond, stopCh)
+
+	// a burst of signals triggers a single refresh.
+	for i := 0; i < 3; i++ {
+		select {
+		case in <- syscall.SIGHUP:
+		default:
+		}
+	}
+	select {
+	case <-out:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a refresh after the signals")
+	}
+	select {
+	case <-out:
+		t.Fatalf("expected a single refresh for the burst")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// a later signal triggers a refresh again.
+	in <- syscall.SIGHUP
+	select {
+	case <-out:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a refresh after the later signal")
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// DeviceRemappedReason is the reason of the event recorded on the volume
+// when the device path of its partition changes.
+const DeviceRemappedReason = "DeviceRemapped"
+
+// diskIdentities holds the identities of the disks present on the node,
+// used to follow the disks of the volumes across the changes of their
+// kernel names.
+type diskIdentities struct {
+	// names are the disk names by the disk identifiers.
+	names map[string]string
+	// wwns are the world wide identifiers by the disk names.
+	wwns map[string]string
+	// isPartition checks if the device path is a partition of the disk.
+	isPartition func(disk, devicePath string) bool
+	// partitionPath finds the device path of the partition of the disk.
+	partitionPath func(disk, partitionName string) (string, error)
+}
+
+func newDiskIdentities(devices []apis.Device, manager device.DeviceManager) (*diskIdentities, error) {
+	wwns, err := manager.ListDiskWWNs()
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	for _, dev := range devices {
+		names[dev.UUID] = dev.Name
+	}
+	return &diskIdentities{
+		names:         names,
+		wwns:          wwns,
+		isPartition:   manager.IsDiskPartition,
+		partitionPath: manager.GetDiskPartitionPath,
+	}, nil
+}
+
+// reconcileVolumeDevice follows the disk of the volume by its world wide
+// identifier and updates the device path of the volume if the partition
+// moved, e.g. the disk got another kernel name after a reboot. The path is
+// updated only if a single disk has the identifier, else the volume is
+// marked with the DeviceRemapAmbiguous condition for the operator to
+// review. It returns true if the volume got updated, along with the
+// description of the remap if the device path changed.
+func reconcileVolumeDevice(vol *apis.DeviceVolume, disks *diskIdentities, now metav1.Time) (bool, string) {
+	if !device.IsVolumeCreated(vol) ||
+		device.GetVolumeCondition(vol, apis.DeviceMissing) != nil {
+		return false, ""
+	}
+
+	var updated bool
+	if vol.Status.DiskWWN == "" {
+		// the volumes created before the identifier was recorded
+		name, ok := disks.names[vol.Status.DiskUUID]
+		if !ok || disks.wwns[name] == "" {
+			return false, ""
+		}
+		vol.Status.DiskWWN = disks.wwns[name]
+		updated = true
+	}
+
+	var matches []string
+	for name, wwn := range disks.wwns {
+		if wwn == vol.Status.DiskWWN {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	if len(matches) == 0 {
+		// the missing disks are handled by the DeviceMissing condition
+		return updated, ""
+	}
+	if len(matches) > 1 {
+		if device.GetVolumeCondition(vol, apis.DeviceRemapAmbiguous) != nil {
+			return updated, ""
+		}
+		vol.Status.Conditions = append(vol.Status.Conditions, apis.VolumeCondition{
+			Type: apis.DeviceRemapAmbiguous,
+			Message: fmt.Sprintf("disks %v have the identifier %s of the disk holding the volume, "+
+				"the device path of the volume is not updated till only one of them is present",
+				matches, vol.Status.DiskWWN),
+			LastTransitionTime: now,
+		})
+		return true, ""
+	}
+	if device.RemoveVolumeCondition(vol, apis.DeviceRemapAmbiguous) {
+		updated = true
+	}
+
+	disk := matches[0]
+	if vol.Status.DevicePath != "" && disks.isPartition(disk, vol.Status.DevicePath) {
+		return updated, ""
+	}
+	path, err := disks.partitionPath(disk, vol.Name[4:])
+	if err != nil {
+		klog.Errorf("device node controller: find partition of volume %s on disk %s: %v", vol.Name, disk, err)
+		return updated, ""
+	}
+	if path == vol.Status.DevicePath {
+		return updated, ""
+	}
+	old := vol.Status.DevicePath
+	vol.Status.DevicePath = path
+	if old == "" {
+		return true, ""
+	}
+	return true, fmt.Sprintf("device of the volume moved from %s to %s on disk %s", old, path, vol.Status.DiskWWN)
+}
@@ -0,0 +1,107 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// fakeDisks returns the identities of the disks by their names, with the
+// partition of the volumes being partition 2 of the disks.
+func fakeDisks(wwns map[string]string) *diskIdentities {
+	return &diskIdentities{
+		names: map[string]string{"gpt-uuid": "sdb"},
+		wwns:  wwns,
+		isPartition: func(disk, devicePath string) bool {
+			return strings.HasPrefix(filepath.Base(devicePath), disk)
+		},
+		partitionPath: func(disk, partitionName string) (string, error) {
+			if _, ok := wwns[disk]; !ok {
+				return "", fmt.Errorf("disk %s not found", disk)
+			}
+			return "/dev/" + disk + "2", nil
+		},
+	}
+}
+
+func TestReconcileVolumeDevice(t *testing.T) {
+	now := metav1.Now()
+	vol := &apis.DeviceVolume{}
+	vol.Name = "pvc-1"
+	vol.Status.State = device.DeviceStatusReady
+	vol.Status.DiskUUID = "gpt-uuid"
+
+	// the volume created before the identifier was recorded gets it from
+	// the disk having its disk identifier, along with the device path.
+	updated, remap := reconcileVolumeDevice(vol, fakeDisks(map[string]string{"sdb": "wwn-1", "sdc": "wwn-2"}), now)
+	assert.True(t, updated)
+	assert.Empty(t, remap, "recording the device path first is not a remap")
+	assert.Equal(t, "wwn-1", vol.Status.DiskWWN)
+	assert.Equal(t, "/dev/sdb2", vol.Status.DevicePath)
+
+	updated, _ = reconcileVolumeDevice(vol, fakeDisks(map[string]string{"sdb": "wwn-1", "sdc": "wwn-2"}), now)
+	assert.False(t, updated, "unchanged disk must not update the volume")
+
+	// the disks swap their names after a reboot
+	updated, remap = reconcileVolumeDevice(vol, fakeDisks(map[string]string{"sdc": "wwn-1", "sdb": "wwn-2"}), now)
+	assert.True(t, updated)
+	assert.Equal(t, "/dev/sdc2", vol.Status.DevicePath)
+	assert.Contains(t, remap, "from /dev/sdb2 to /dev/sdc2")
+
+	// the disk is seen twice, e.g. by two paths without multipath
+	disks := fakeDisks(map[string]string{"sdc": "wwn-1", "sdd": "wwn-1"})
+	updated, remap = reconcileVolumeDevice(vol, disks, now)
+	assert.True(t, updated)
+	assert.Empty(t, remap)
+	assert.Equal(t, "/dev/sdc2", vol.Status.DevicePath, "ambiguous disk must not remap the volume")
+	assert.NotNil(t, device.GetVolumeCondition(vol, apis.DeviceRemapAmbiguous))
+	updated, _ = reconcileVolumeDevice(vol, disks, now)
+	assert.False(t, updated, "condition must be added only once")
+
+	// the operator removes the extra path and the disk gets another name
+	updated, remap = reconcileVolumeDevice(vol, fakeDisks(map[string]string{"sde": "wwn-1"}), now)
+	assert.True(t, updated)
+	assert.Nil(t, device.GetVolumeCondition(vol, apis.DeviceRemapAmbiguous))
+	assert.Equal(t, "/dev/sde2", vol.Status.DevicePath)
+	assert.NotEmpty(t, remap)
+
+	// the missing disk is left to the DeviceMissing condition
+	updated, _ = reconcileVolumeDevice(vol, fakeDisks(map[string]string{"sdb": "wwn-2"}), now)
+	assert.False(t, updated)
+	assert.Equal(t, "/dev/sde2", vol.Status.DevicePath)
+}
+
+func TestReconcileVolumeDeviceWithoutWWN(t *testing.T) {
+	vol := &apis.DeviceVolume{}
+	vol.Name = "pvc-1"
+	vol.Status.State = device.DeviceStatusReady
+	vol.Status.DiskUUID = "gpt-uuid"
+
+	// virtual disks don't expose a world wide identifier to follow
+	updated, _ := reconcileVolumeDevice(vol, fakeDisks(map[string]string{}), metav1.Now())
+	assert.False(t, updated)
+	assert.Empty(t, vol.Status.DevicePath)
+}
@@ -0,0 +1,126 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// syncVolumeDisks checks that the disks holding the volumes of the node are
+// still present, and marks the volumes whose disk is gone with the
+// DeviceMissing condition. Once the operator acknowledges the replacement
+// of the disk, the volume is marked failed as its data is lost. The device
+// paths of the volumes are updated as per the current names of their disks.
+func (c *NodeController) syncVolumeDisks(devices []apis.Device) error {
+	vols, err := device.ListNodeDeviceVolumes(device.NodeID)
+	if err != nil {
+		return fmt.Errorf("list device volumes: %v", err)
+	}
+	reportClassSlots(vols.Items)
+	disks, err := newDiskIdentities(devices, c.devices)
+	if err != nil {
+		return fmt.Errorf("list disk identifiers: %v", err)
+	}
+
+	present := map[string]bool{}
+	for _, dev := range devices {
+		present[dev.UUID] = true
+	}
+	// the disks left out for carrying a foreign signature are still there
+	for _, uuid := range c.devices.ListSignedDevices() {
+		present[uuid] = true
+	}
+
+	now := metav1.Now()
+	for i := range vols.Items {
+		vol := vols.Items[i].DeepCopy()
+		if vol.Spec.OwnerNodeID != device.NodeID || vol.DeletionTimestamp != nil {
+			continue
+		}
+		updated := reconcileVolumeDisk(vol, present, now)
+		remapped, remap := reconcileVolumeDevice(vol, disks, now)
+		if !updated && !remapped {
+			continue
+		}
+		klog.Infof("device node controller: updating disk status of volume %s to %+v",
+			vol.Name, vol.Status)
+		if err = device.UpdateVolume(vol); err != nil {
+			klog.Errorf("device node controller: update volume %s: %v", vol.Name, err)
+			continue
+		}
+		if remap != "" {
+			klog.Infof("device node controller: volume %s: %s", vol.Name, remap)
+			c.recorder.Event(vol, corev1.EventTypeNormal, DeviceRemappedReason, remap)
+		}
+	}
+	return nil
+}
+
+// reconcileVolumeDisk updates the DeviceMissing condition of the volume as
+// per the presence of its disk, and marks the volume failed if the
+// replacement of the disk is acknowledged. It returns true if the volume got
+// updated.
+func reconcileVolumeDisk(vol *apis.DeviceVolume, present map[string]bool, now metav1.Time) bool {
+	if !device.IsVolumeCreated(vol) || vol.Status.DiskUUID == "" {
+		return false
+	}
+
+	missing := device.GetVolumeCondition(vol, apis.DeviceMissing) != nil
+	if present[vol.Status.DiskUUID] {
+		if !missing {
+			return false
+		}
+		// the disk is back, e.g. it was disconnected temporarily.
+		device.RemoveVolumeCondition(vol, apis.DeviceMissing)
+		return true
+	}
+
+	if !missing {
+		vol.Status.Conditions = append(vol.Status.Conditions, apis.VolumeCondition{
+			Type: apis.DeviceMissing,
+			Message: fmt.Sprintf("disk %s holding the volume is not present on the node, "+
+				"annotate the volume with %s if the disk is replaced",
+				vol.Status.DiskUUID, device.ReplacementAcknowledgedKey),
+			LastTransitionTime: now,
+		})
+		return true
+	}
+
+	if _, ok := vol.Annotations[device.ReplacementAcknowledgedKey]; !ok {
+		return false
+	}
+	// the data of the volume is lost along with the disk. An empty
+	// partition handed out under the same PV would pass for the volume,
+	// so the volume is failed and left for the operator to delete.
+	klog.Infof("device node controller: replacement of disk %s acknowledged, marking volume %s failed",
+		vol.Status.DiskUUID, vol.Name)
+	delete(vol.Annotations, device.ReplacementAcknowledgedKey)
+	vol.Status.State = device.DeviceStatusFailed
+	vol.Status.Error = &apis.VolumeError{
+		Code: apis.Internal,
+		Message: fmt.Sprintf("disk %s holding the volume got replaced, the data of the volume is lost",
+			vol.Status.DiskUUID),
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+func TestReconcileVolumeDisk(t *testing.T) {
+	now := metav1.Now()
+	vol := &apis.DeviceVolume{}
+	vol.Name = "pvc-1"
+	vol.Status.State = device.DeviceStatusReady
+	vol.Status.DiskUUID = "old-disk"
+	vol.Status.Capacity = "1048576"
+
+	oldDisk := map[string]bool{"old-disk": true}
+	newDisk := map[string]bool{"new-disk": true}
+
+	assert.False(t, reconcileVolumeDisk(vol, oldDisk, now), "present disk must not update the volume")
+
+	assert.True(t, reconcileVolumeDisk(vol, newDisk, now))
+	assert.NotNil(t, device.GetVolumeCondition(vol, apis.DeviceMissing))
+	assert.False(t, reconcileVolumeDisk(vol, newDisk, now), "condition must be added only once")
+	assert.Len(t, vol.Status.Conditions, 1)
+
+	// the disk comes back before the replacement is acknowledged
+	assert.True(t, reconcileVolumeDisk(vol, oldDisk, now))
+	assert.Nil(t, device.GetVolumeCondition(vol, apis.DeviceMissing))
+
+	// the disk is replaced and the replacement acknowledged
+	assert.True(t, reconcileVolumeDisk(vol, newDisk, now))
+	vol.Annotations = map[string]string{device.ReplacementAcknowledgedKey: "true"}
+	assert.True(t, reconcileVolumeDisk(vol, newDisk, now))
+	// the volume is not reprovisioned, an empty partition would pass for
+	// the lost data.
+	assert.Equal(t, device.DeviceStatusFailed, vol.Status.State)
+	assert.NotNil(t, vol.Status.Error)
+	assert.Equal(t, "old-disk", vol.Status.DiskUUID)
+	assert.NotNil(t, device.GetVolumeCondition(vol, apis.DeviceMissing))
+	assert.NotContains(t, vol.Annotations, device.ReplacementAcknowledgedKey)
+
+	// failed volume is left for the operator to delete
+	assert.False(t, reconcileVolumeDisk(vol, newDisk, now))
+	assert.False(t, reconcileVolumeDisk(vol, oldDisk, now))
+}
+
+func TestReconcileVolumeDiskUnknownDisk(t *testing.T) {
+	// volumes created before the disk identifier was recorded are skipped
+	vol := &apis.DeviceVolume{}
+	vol.Status.State = device.DeviceStatusReady
+	assert.False(t, reconcileVolumeDisk(vol, map[string]bool{}, metav1.Now()))
+}
@@ -0,0 +1,52 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// classDisk is a disk of the node holding volumes of a slot class.
+type classDisk struct {
+	uuid  string
+	class string
+}
+
+// classSlots counts the GPT partition entries taken by the volumes of each
+// slot class on the disks of the node, the way the allocator accounts them
+// against the slot budgets.
+func classSlots(vols []apis.DeviceVolume) map[classDisk]int {
+	used := map[classDisk]int{}
+	for i := range vols {
+		vol := &vols[i]
+		if vol.Spec.OwnerNodeID != device.NodeID || vol.Spec.SlotClass == "" || vol.Status.DiskUUID == "" {
+			continue
+		}
+		used[classDisk{uuid: vol.Status.DiskUUID, class: vol.Spec.SlotClass}] += device.VolumeSlots(vol)
+	}
+	return used
+}
+
+// reportClassSlots sets the GPT partition entries taken by each slot class
+// on the disks of the node in the metrics.
+func reportClassSlots(vols []apis.DeviceVolume) {
+	ClassSlots.Reset()
+	for key, slots := range classSlots(vols) {
+		ClassSlots.WithLabelValues(key.uuid, key.class).Set(float64(slots))
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+func TestClassSlots(t *testing.T) {
+	volume := func(class, node, disk, reserve string) apis.DeviceVolume {
+		vol := apis.DeviceVolume{}
+		vol.Spec.SlotClass = class
+		vol.Spec.OwnerNodeID = node
+		vol.Spec.GrowthReserve = reserve
+		vol.Status.DiskUUID = disk
+		return vol
+	}
+	node := device.NodeID
+	vols := []apis.DeviceVolume{
+		volume("tiny", node, "uuid-1", ""),
+		volume("tiny", node, "uuid-1", "1048576"),
+		volume("tiny", node, "uuid-2", ""),
+		volume("large", node, "uuid-1", ""),
+		volume("", node, "uuid-1", ""),
+		volume("tiny", node+"-other", "uuid-3", ""),
+		volume("tiny", node, "", ""),
+	}
+	assert.Equal(t, map[classDisk]int{
+		{uuid: "uuid-1", class: "tiny"}:  3,
+		{uuid: "uuid-2", class: "tiny"}:  1,
+		{uuid: "uuid-1", class: "large"}: 1,
+	}, classSlots(vols))
+}
@@ -0,0 +1,72 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+// filterDevices merges the spec of the DeviceNode with the discovered
+// devices. A discovered device is kept if AllowedDevices is empty or lists
+// its UUID or name, unless BlockedDevices lists it. The spec only narrows
+// down the discovered devices: the devices it lists which are not present
+// on the node are ignored. It also returns the UUIDs of the devices left
+// out, which must not get new partitions.
+func filterDevices(spec apis.DeviceNodeSpec, discovered []apis.Device) ([]apis.Device, []string) {
+	if len(spec.AllowedDevices) == 0 && len(spec.BlockedDevices) == 0 {
+		return discovered, nil
+	}
+	allowed, blocked := toSet(spec.AllowedDevices), toSet(spec.BlockedDevices)
+
+	var devices []apis.Device
+	var excluded []string
+	for _, dev := range discovered {
+		if (len(allowed) > 0 && !allowed[dev.UUID] && !allowed[dev.Name]) ||
+			blocked[dev.UUID] || blocked[dev.Name] {
+			excluded = append(excluded, dev.UUID)
+			continue
+		}
+		devices = append(devices, dev)
+	}
+	return devices, excluded
+}
+
+// protectedDevices returns the number of protected leading bytes of the
+// devices listed in the spec, by their UUID or name. The negative sizes
+// are ignored.
+func protectedDevices(spec apis.DeviceNodeSpec) map[string]uint64 {
+	protected := make(map[string]uint64, len(spec.ProtectedLeadingBytes))
+	for dev, size := range spec.ProtectedLeadingBytes {
+		if size.Sign() < 0 {
+			klog.Warningf("device node controller: ignoring negative protected leading bytes %s of device %s",
+				size.String(), dev)
+			continue
+		}
+		protected[dev] = uint64(size.Value())
+	}
+	return protected
+}
+
+func toSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, item := range list {
+		set[item] = true
+	}
+	return set
+}
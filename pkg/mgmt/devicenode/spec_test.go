@@ -0,0 +1,84 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+)
+
+func TestFilterDevices(t *testing.T) {
+	discovered := []apis.Device{
+		{Name: "fast", UUID: "uuid-1"},
+		{Name: "fast", UUID: "uuid-2"},
+		{Name: "slow", UUID: "uuid-3"},
+	}
+	tests := map[string]struct {
+		spec     apis.DeviceNodeSpec
+		kept     []string
+		excluded []string
+	}{
+		"empty spec": {
+			kept: []string{"uuid-1", "uuid-2", "uuid-3"},
+		},
+		"allowed by name": {
+			spec:     apis.DeviceNodeSpec{AllowedDevices: []string{"fast"}},
+			kept:     []string{"uuid-1", "uuid-2"},
+			excluded: []string{"uuid-3"},
+		},
+		"blocked by uuid": {
+			spec:     apis.DeviceNodeSpec{BlockedDevices: []string{"uuid-2"}},
+			kept:     []string{"uuid-1", "uuid-3"},
+			excluded: []string{"uuid-2"},
+		},
+		"blocked takes precedence": {
+			spec:     apis.DeviceNodeSpec{AllowedDevices: []string{"fast"}, BlockedDevices: []string{"uuid-1"}},
+			kept:     []string{"uuid-2"},
+			excluded: []string{"uuid-1", "uuid-3"},
+		},
+		"absent devices are ignored": {
+			spec:     apis.DeviceNodeSpec{AllowedDevices: []string{"uuid-3", "uuid-9"}, BlockedDevices: []string{"other"}},
+			kept:     []string{"uuid-3"},
+			excluded: []string{"uuid-1", "uuid-2"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			devices, excluded := filterDevices(test.spec, discovered)
+			var kept []string
+			for _, dev := range devices {
+				kept = append(kept, dev.UUID)
+			}
+			assert.Equal(t, test.kept, kept)
+			assert.Equal(t, test.excluded, excluded)
+		})
+	}
+}
+
+func TestProtectedDevices(t *testing.T) {
+	spec := apis.DeviceNodeSpec{ProtectedLeadingBytes: map[string]resource.Quantity{
+		"uuid-1": resource.MustParse("16Mi"),
+		"slow":   resource.MustParse("1G"),
+		"bad":    resource.MustParse("-1Mi"),
+	}}
+	assert.Equal(t, map[string]uint64{"uuid-1": 16 << 20, "slow": 1000000000}, protectedDevices(spec))
+	assert.Empty(t, protectedDevices(apis.DeviceNodeSpec{}))
+}
@@ -17,7 +17,6 @@
 package devicenode
 
 import (
-	"context"
 	"sync"
 	"time"
 
@@ -25,7 +24,6 @@ import (
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/openebs/device-localpv/pkg/device"
@@ -33,8 +31,15 @@ import (
 	informers "github.com/openebs/device-localpv/pkg/generated/informer/externalversions"
 )
 
-// Start starts the devicenode controller.
-func Start(controllerMtx *sync.RWMutex, stopCh <-chan struct{}) error {
+// Start starts the devicenode controller. The DeviceNode is verified
+// against the partition tables of the disks every verifyInterval, zero
+// disables the verification. A device has to be missing for the
+// missingGracePeriod before it is treated as gone. owner denotes the object
+// set as the owner of the DeviceNode, see OwnerNode and OwnerWorkload.
+// The labels of the kubernetes node with the topologyKeys are copied onto
+// the DeviceNode.
+func Start(controllerMtx *sync.RWMutex, verifyInterval, missingGracePeriod time.Duration,
+	owner string, topologyKeys []string, stopCh <-chan struct{}) error {
 
 	// Get in cluster config
 	cfg, err := k8sapi.Config().Get()
@@ -61,21 +66,9 @@ func Start(controllerMtx *sync.RWMutex, stopCh <-chan struct{}) error {
 			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", device.NodeID).String()
 		}))
 
-	k8sNode, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), device.NodeID, metav1.GetOptions{})
+	ownerRef, err := getOwnerReference(kubeClient, owner)
 	if err != nil {
-		return errors.Wrapf(err, "fetch k8s node %s", device.NodeID)
-	}
-	isTrue := true
-	// as object returned by client go clears all TypeMeta from it.
-	nodeGVK := &schema.GroupVersionKind{
-		Group: "", Version: "v1", Kind: "Node",
-	}
-	ownerRef := metav1.OwnerReference{
-		APIVersion: nodeGVK.GroupVersion().String(),
-		Kind:       nodeGVK.Kind,
-		Name:       k8sNode.Name,
-		UID:        k8sNode.GetUID(),
-		Controller: &isTrue,
+		return errors.Wrap(err, "error resolving device node owner")
 	}
 
 	// Build() fn of all controllers calls AddToScheme to adds all types of this
@@ -92,8 +85,13 @@ func Start(controllerMtx *sync.RWMutex, stopCh <-chan struct{}) error {
 		withNodeLister(nodeInformerFactory).
 		withRecorder(kubeClient).
 		withEventHandler(nodeInformerFactory).
-		withPollInterval(60 * time.Second).
+		withPollInterval(60*time.Second).
+		withVerifyInterval(verifyInterval).
+		withMissingGracePeriod(missingGracePeriod).
+		withDeviceManager(device.NewDeviceManager()).
 		withOwnerReference(ownerRef).
+		withNodeClient(newNodeKubeclient).
+		withTopologyLabels(kubeClient, topologyKeys).
 		withWorkqueueRateLimiting().Build()
 
 	// blocking call, can't use defer to release the lock
@@ -0,0 +1,53 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// getNodeLabelsFunc fetches the labels of the kubernetes node of given name.
+type getNodeLabelsFunc func(name string) (map[string]string, error)
+
+func newNodeLabelsGetter(kubeClient kubernetes.Interface) getNodeLabelsFunc {
+	return func(name string) (map[string]string, error) {
+		k8sNode, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetch k8s node %s", name)
+		}
+		return k8sNode.Labels, nil
+	}
+}
+
+// topologyLabels returns the topology labels of the kubernetes node to be
+// set on its DeviceNode, none if no topology keys are configured.
+func (c *NodeController) topologyLabels(name string) (map[string]string, error) {
+	if len(c.topologyKeys) == 0 {
+		return nil, nil
+	}
+	labels, err := c.getNodeLabels(name)
+	if err != nil {
+		return nil, err
+	}
+	return device.TopologyLabels(labels, c.topologyKeys), nil
+}
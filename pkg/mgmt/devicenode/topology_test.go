@@ -0,0 +1,111 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device/fake"
+	listers "github.com/openebs/device-localpv/pkg/generated/lister/device/v1alpha1"
+)
+
+func TestTopologyLabels(t *testing.T) {
+	k8sNodeLabels := map[string]string{
+		"kubernetes.io/hostname":      "node-1",
+		"topology.kubernetes.io/zone": "eu-west-1a",
+		"example.com/rack":            "rack-7",
+	}
+	tests := map[string]struct {
+		keys    []string
+		nodeErr error
+		want    map[string]string
+		wantErr bool
+	}{
+		"nothing imported": {},
+		"zone and rack": {
+			keys: []string{"topology.kubernetes.io/zone", "example.com/rack"},
+			want: map[string]string{"topology.kubernetes.io/zone": "eu-west-1a", "example.com/rack": "rack-7"},
+		},
+		"key missing on the node": {
+			keys: []string{"example.com/row"},
+			want: map[string]string{},
+		},
+		"node not fetched": {
+			keys:    []string{"example.com/rack"},
+			nodeErr: errors.New("node node-1 not found"),
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &NodeController{
+				topologyKeys: test.keys,
+				getNodeLabels: func(name string) (map[string]string, error) {
+					assert.Equal(t, "node-1", name)
+					return k8sNodeLabels, test.nodeErr
+				},
+			}
+			labels, err := c.topologyLabels("node-1")
+			assert.Equal(t, test.wantErr, err != nil, "topologyLabels() error %v", err)
+			assert.Equal(t, test.want, labels)
+		})
+	}
+}
+
+func TestSyncNodeTopologyLabels(t *testing.T) {
+	dev := apis.Device{Name: "fast", UUID: "uuid-1", Size: resource.MustParse("100Gi")}
+	rack := map[string]string{"example.com/rack": "rack-7"}
+	ownerRef := metav1.OwnerReference{APIVersion: "v1", Kind: "Node", Name: "node-1", UID: types.UID("uid-1")}
+
+	// the DeviceNode carrying the rack of the node is up to date, so
+	// syncNode doesn't reach the api server for updating it, whether the
+	// node got fetched or not.
+	labels, _ := mergeLabels(nodeLabels([]apis.Device{dev}), rack)
+	node := &apis.DeviceNode{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "openebs", Name: "node-1",
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{ownerRef},
+		},
+		Devices: []apis.Device{dev},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(node))
+
+	for _, nodeErr := range []error{nil, errors.New("node node-1 not found")} {
+		c := &NodeController{
+			NodeLister:   listers.NewDeviceNodeLister(indexer),
+			recorder:     record.NewFakeRecorder(10),
+			ownerRef:     ownerRef,
+			devices:      fake.NewDeviceManager(dev),
+			topologyKeys: []string{"example.com/rack"},
+			getNodeLabels: func(string) (map[string]string, error) {
+				return rack, nodeErr
+			},
+		}
+		assert.NoError(t, c.syncNode("openebs", "node-1"))
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// Kinds of drift between the DeviceNode, the DeviceVolumes and the disks
+const (
+	DriftMissing    = "missing"
+	DriftUnexpected = "unexpected"
+	DriftName       = "name"
+	DriftSize       = "size"
+	// DriftOrphan is a partition on the disks belonging to no DeviceVolume
+	// of the node, taking space no volume accounts for.
+	DriftOrphan = "orphan"
+	// DriftLost is a created DeviceVolume with no partition on the disks.
+	DriftLost = "lost"
+	// DriftDisk is a DeviceVolume whose partition is on another disk than
+	// the one recorded in its status.
+	DriftDisk = "disk"
+)
+
+// PartitionTableDriftReason is the reason of the event recorded on the
+// device node when the partitions on its disks drifted from the volumes.
+const PartitionTableDriftReason = "PartitionTableDrift"
+
+// DriftTotal counts the discrepancies found between the DeviceNode, the
+// DeviceVolumes of the node and the partition tables read from the disks.
+var DriftTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "openebs",
+	Subsystem: "device_node",
+	Name:      "drift_total",
+	Help:      "Number of discrepancies found between the DeviceNode, the DeviceVolumes and the disks by the periodic verification.",
+}, []string{"kind"})
+
+// deviceDrift describes a discrepancy found for a device.
+type deviceDrift struct {
+	kind    string
+	message string
+}
+
+// runVerify verifies the DeviceNode against the disks every interval,
+// until stopCh is closed.
+func (c *NodeController) runVerify(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+		if err := c.verifyNode(device.DeviceNamespace, device.NodeID); err != nil {
+			klog.Errorf("device node controller: verify node: %v", err)
+		}
+	}
+}
+
+// verifyNode reads the partition tables of the disks afresh and compares
+// the partitions on them with the DeviceVolumes of the node, along with the
+// devices with the ones recorded in the DeviceNode. Any discrepancy is
+// logged and counted. The drift of the devices is corrected by queueing the
+// node for a sync, the drift of the partitions is left to the operator as
+// no partition can safely be deleted or created for it, and recorded as an
+// event on the node.
+func (c *NodeController) verifyNode(namespace, name string) error {
+	node, err := c.NodeLister.DeviceNodes(namespace).Get(name)
+	if err != nil {
+		if k8serror.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	discovered, err := c.listDeviceNames()
+	if err != nil {
+		return err
+	}
+
+	disks, err := c.devices.ListDiskLayouts()
+	if err != nil {
+		return err
+	}
+	// the volumes not created yet are not labelled with their node, yet
+	// their partition may already be on the disks.
+	vols, err := device.ListDeviceVolumes()
+	if err != nil {
+		return err
+	}
+
+	devices, _ := filterDevices(node.Spec, discovered)
+	deviceDrifts := diffDevices(node.Devices, devices)
+	partitionDrifts := diffPartitions(disks, vols.Items, name)
+	if len(deviceDrifts) == 0 && len(partitionDrifts) == 0 {
+		klog.V(4).Infof("device node controller: node %s/%s is consistent with the disks", namespace, name)
+		return nil
+	}
+	for _, drift := range append(deviceDrifts, partitionDrifts...) {
+		klog.Warningf("device node controller: node %s/%s drifted from the disks: %s",
+			namespace, name, drift.message)
+		DriftTotal.WithLabelValues(drift.kind).Inc()
+	}
+	if len(partitionDrifts) != 0 {
+		var messages []string
+		for _, drift := range partitionDrifts {
+			messages = append(messages, drift.message)
+		}
+		c.recorder.Event(node, corev1.EventTypeWarning, PartitionTableDriftReason, strings.Join(messages, "; "))
+	}
+	if len(deviceDrifts) != 0 {
+		c.workqueue.Add(namespace + "/" + name)
+	}
+	return nil
+}
+
+// diffDevices compares the recorded devices with the actual ones, matching
+// them by their UUID. The free space is not compared, it changes with every
+// partition created or deleted and gets refreshed by the sync of the node.
+func diffDevices(recorded, actual []apis.Device) []deviceDrift {
+	var drifts []deviceDrift
+	actualByUUID := map[string]apis.Device{}
+	for _, dev := range actual {
+		actualByUUID[dev.UUID] = dev
+	}
+
+	seen := map[string]bool{}
+	for _, rec := range recorded {
+		seen[rec.UUID] = true
+		dev, ok := actualByUUID[rec.UUID]
+		if !ok {
+			drifts = append(drifts, deviceDrift{DriftMissing,
+				fmt.Sprintf("device %s (%s) is not present on the disks", rec.Name, rec.UUID)})
+			continue
+		}
+		if rec.Name != dev.Name {
+			drifts = append(drifts, deviceDrift{DriftName,
+				fmt.Sprintf("device %s is named %s, recorded %s", rec.UUID, dev.Name, rec.Name)})
+		}
+		if rec.Size.Cmp(dev.Size) != 0 {
+			drifts = append(drifts, deviceDrift{DriftSize,
+				fmt.Sprintf("device %s has size %s, recorded %s", rec.Name, dev.Size.String(), rec.Size.String())})
+		}
+	}
+	for _, dev := range actual {
+		if !seen[dev.UUID] {
+			drifts = append(drifts, deviceDrift{DriftUnexpected,
+				fmt.Sprintf("device %s (%s) is not recorded", dev.Name, dev.UUID)})
+		}
+	}
+	return drifts
+}
+
+// diffPartitions compares the partitions on the disks with the DeviceVolumes
+// owned by the node, matching them by the name of the partitions.
+func diffPartitions(disks []device.LayoutDisk, vols []apis.DeviceVolume, node string) []deviceDrift {
+	owned := map[string]*apis.DeviceVolume{}
+	for i := range vols {
+		if vols[i].Spec.OwnerNodeID == node {
+			owned[vols[i].Name] = &vols[i]
+		}
+	}
+
+	var drifts []deviceDrift
+	found := map[string]bool{}
+	for _, disk := range disks {
+		for _, part := range disk.Partitions {
+			if part.Number == 1 || part.Name == "" {
+				continue
+			}
+			volName := device.PartitionVolumeName(part.Name)
+			vol, ok := owned[volName]
+			if !ok || !device.MatchesDevName(vol.Spec.DevName, disk.Device) {
+				drifts = append(drifts, deviceDrift{DriftOrphan,
+					fmt.Sprintf("partition %d (%s) of disk %s belongs to no volume", part.Number, part.Name, disk.UUID)})
+				continue
+			}
+			found[volName] = true
+			if part.Name == strings.TrimPrefix(vol.Name, "pvc-") &&
+				vol.Status.DiskUUID != "" && vol.Status.DiskUUID != disk.UUID {
+				drifts = append(drifts, deviceDrift{DriftDisk,
+					fmt.Sprintf("volume %s is on disk %s, recorded %s", vol.Name, disk.UUID, vol.Status.DiskUUID)})
+			}
+		}
+	}
+
+	for i := range vols {
+		vol := &vols[i]
+		if vol.Spec.OwnerNodeID != node || found[vol.Name] || !device.IsVolumeCreated(vol) ||
+			vol.DeletionTimestamp != nil || device.GetVolumeCondition(vol, apis.DeviceMissing) != nil {
+			continue
+		}
+		drifts = append(drifts, deviceDrift{DriftLost,
+			fmt.Sprintf("volume %s has no partition on the disks", vol.Name)})
+	}
+	return drifts
+}
@@ -0,0 +1,162 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package devicenode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+func TestDiffDevices(t *testing.T) {
+	newDevice := func(name, uuid, size, free string) apis.Device {
+		return apis.Device{
+			Name: name, UUID: uuid,
+			Size: resource.MustParse(size), Free: resource.MustParse(free),
+		}
+	}
+	tests := map[string]struct {
+		recorded []apis.Device
+		actual   []apis.Device
+		kinds    []string
+	}{
+		"consistent": {
+			recorded: []apis.Device{newDevice("dev", "u1", "10Gi", "4Gi")},
+			actual:   []apis.Device{newDevice("dev", "u1", "10Gi", "4096Mi")},
+		},
+		"free space changed": {
+			recorded: []apis.Device{newDevice("dev", "u1", "10Gi", "4Gi")},
+			actual:   []apis.Device{newDevice("dev", "u1", "10Gi", "6Gi")},
+		},
+		"renamed and resized": {
+			recorded: []apis.Device{newDevice("dev", "u1", "10Gi", "4Gi")},
+			actual:   []apis.Device{newDevice("dev2", "u1", "20Gi", "4Gi")},
+			kinds:    []string{DriftName, DriftSize},
+		},
+		"replaced device": {
+			recorded: []apis.Device{newDevice("dev", "u1", "10Gi", "4Gi")},
+			actual:   []apis.Device{newDevice("dev", "u2", "10Gi", "10Gi")},
+			kinds:    []string{DriftMissing, DriftUnexpected},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var kinds []string
+			for _, drift := range diffDevices(test.recorded, test.actual) {
+				kinds = append(kinds, drift.kind)
+			}
+			assert.Equal(t, test.kinds, kinds)
+		})
+	}
+}
+
+func TestDiffPartitions(t *testing.T) {
+	newVol := func(name, state, diskUUID string) apis.DeviceVolume {
+		vol := apis.DeviceVolume{}
+		vol.Name = name
+		vol.Spec.OwnerNodeID = "node-1"
+		vol.Spec.DevName = "dev"
+		vol.Status.State = state
+		vol.Status.DiskUUID = diskUUID
+		return vol
+	}
+	newDisk := func(uuid string, names ...string) device.LayoutDisk {
+		disk := device.LayoutDisk{UUID: uuid, Device: "dev",
+			Partitions: []device.LayoutPartition{{Number: 1, Name: "dev"}}}
+		for i, name := range names {
+			disk.Partitions = append(disk.Partitions, device.LayoutPartition{Number: uint32(i + 2), Name: name})
+		}
+		return disk
+	}
+	newDevNameVol := func(name, devName string) apis.DeviceVolume {
+		vol := newVol(name, device.DeviceStatusReady, "")
+		vol.Spec.DevName = devName
+		return vol
+	}
+	missing := newVol("pvc-3", device.DeviceStatusReady, "u3")
+	missing.Status.Conditions = []apis.VolumeCondition{{Type: apis.DeviceMissing}}
+	other := newVol("pvc-4", device.DeviceStatusReady, "u1")
+	other.Spec.OwnerNodeID = "node-2"
+
+	tests := map[string]struct {
+		disks []device.LayoutDisk
+		vols  []apis.DeviceVolume
+		kinds []string
+	}{
+		"consistent": {
+			disks: []device.LayoutDisk{newDisk("u1", "1", "1-reserve"), newDisk("u2", "2-relocating")},
+			vols: []apis.DeviceVolume{newVol("pvc-1", device.DeviceStatusReady, "u1"),
+				newVol("pvc-2", device.DeviceStatusReady, "u2")},
+		},
+		"partition of no volume": {
+			disks: []device.LayoutDisk{newDisk("u1", "1", "9")},
+			vols:  []apis.DeviceVolume{newVol("pvc-1", device.DeviceStatusReady, "u1")},
+			kinds: []string{DriftOrphan},
+		},
+		"partition of a volume of another node": {
+			disks: []device.LayoutDisk{newDisk("u1", "4")},
+			vols:  []apis.DeviceVolume{other},
+			kinds: []string{DriftOrphan},
+		},
+		"partition of a volume being created": {
+			disks: []device.LayoutDisk{newDisk("u1", "1")},
+			vols:  []apis.DeviceVolume{newVol("pvc-1", device.DeviceStatusPending, "")},
+		},
+		"volume without partition": {
+			disks: []device.LayoutDisk{newDisk("u1")},
+			vols: []apis.DeviceVolume{newVol("pvc-1", device.DeviceStatusReady, "u1"),
+				newVol("pvc-2", device.DeviceStatusPending, ""), missing},
+			kinds: []string{DriftLost},
+		},
+		"devname matching the device": {
+			disks: []device.LayoutDisk{newDisk("u1", "1"), newDisk("u2", "2")},
+			vols: []apis.DeviceVolume{newDevNameVol("pvc-1", "d.*"),
+				newDevNameVol("pvc-2", "de")},
+		},
+		"devname not matching the device": {
+			disks: []device.LayoutDisk{newDisk("u1", "1")},
+			vols:  []apis.DeviceVolume{newDevNameVol("pvc-1", "nvme.*")},
+			kinds: []string{DriftOrphan, DriftLost},
+		},
+		"volume on another disk": {
+			disks: []device.LayoutDisk{newDisk("u1"), newDisk("u2", "1")},
+			vols:  []apis.DeviceVolume{newVol("pvc-1", device.DeviceStatusReady, "u1")},
+			kinds: []string{DriftDisk},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var kinds []string
+			for _, drift := range diffPartitions(test.disks, test.vols, "node-1") {
+				kinds = append(kinds, drift.kind)
+			}
+			assert.Equal(t, test.kinds, kinds)
+		})
+	}
+
+	// the partition of a volume being deleted is not a drift, nor is the
+	// volume once its partition is gone.
+	deleting := newVol("pvc-1", device.DeviceStatusReady, "u1")
+	deleting.DeletionTimestamp = &metav1.Time{}
+	assert.Empty(t, diffPartitions([]device.LayoutDisk{newDisk("u1", "1")}, []apis.DeviceVolume{deleting}, "node-1"))
+	assert.Empty(t, diffPartitions([]device.LayoutDisk{newDisk("u1")}, []apis.DeviceVolume{deleting}, "node-1"))
+}
@@ -0,0 +1,131 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package volume
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// DefaultBackfillRate is the default number of volumes per second whose
+// missing status fields get backfilled from their partition.
+const DefaultBackfillRate = 1
+
+// backfiller spreads the backfills of the status of the volumes created by
+// the older releases over time, as each of them scans the partition tables
+// of all the disks and all the volumes get reconciled at once when the
+// node agent starts after an upgrade. A volume is backfilled once per run
+// of the node agent, unless it fails, so that a volume whose partition
+// can't be found doesn't scan the disks on every reconcile.
+type backfiller struct {
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	now     func() time.Time
+	// scheduled holds the time the volumes delayed by the limiter can be
+	// backfilled at, by their key.
+	scheduled map[string]time.Time
+	// attempted holds the keys of the volumes backfilled already.
+	attempted map[string]bool
+}
+
+// newBackfiller returns a backfiller running rate backfills per second,
+// nil disabling the backfills if the rate is not positive.
+func newBackfiller(r float64) *backfiller {
+	if r <= 0 {
+		return nil
+	}
+	return &backfiller{
+		limiter:   rate.NewLimiter(rate.Limit(r), 1),
+		now:       time.Now,
+		scheduled: map[string]time.Time{},
+		attempted: map[string]bool{},
+	}
+}
+
+// reserve checks if the volume of key can be backfilled now. Otherwise it
+// returns the time to wait before its turn comes, zero if the volume was
+// backfilled already.
+func (b *backfiller) reserve(key string) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.attempted[key] {
+		return false, 0
+	}
+	now := b.now()
+	at, ok := b.scheduled[key]
+	if !ok {
+		at = now.Add(b.limiter.ReserveN(now, 1).DelayFrom(now))
+	}
+	if at.After(now) {
+		b.scheduled[key] = at
+		return false, at.Sub(now)
+	}
+	delete(b.scheduled, key)
+	b.attempted[key] = true
+	return true, 0
+}
+
+// retry makes the volume of key backfilled again by a later reconcile.
+func (b *backfiller) retry(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.attempted, key)
+}
+
+// backfillVol fills the status fields of the volume missing for having
+// been created by an older release, once its turn comes, and records them.
+// The volume is requeued for its turn if the backfills are running behind.
+// It returns true if the volume got updated.
+func (c *VolController) backfillVol(vol *apis.DeviceVolume) (bool, error) {
+	if c.backfill == nil {
+		return false, nil
+	}
+	key, err := cache.MetaNamespaceKeyFunc(vol)
+	if err != nil {
+		return false, err
+	}
+	run, delay := c.backfill.reserve(key)
+	if delay > 0 {
+		c.workqueue.AddAfter(key, delay)
+	}
+	if !run {
+		return false, nil
+	}
+
+	filled, err := c.devices.BackfillVolumeStatus(vol)
+	if err != nil {
+		klog.Errorf("volume controller: backfill status of volume %s: %v", vol.Name, err)
+		c.backfill.retry(key)
+		return false, nil
+	}
+	if !filled {
+		return false, nil
+	}
+	klog.Infof("volume controller: backfilled status of volume %s to %+v", vol.Name, vol.Status)
+	if err = device.UpdateVolume(vol); err != nil {
+		c.backfill.retry(key)
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,143 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package volume
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+	"github.com/openebs/device-localpv/pkg/device/fake"
+)
+
+func TestBackfillerReserve(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	b := newBackfiller(2)
+	b.now = func() time.Time { return now }
+
+	// the first volume is backfilled right away, the next ones are
+	// spread at the rate.
+	if run, delay := b.reserve("openebs/pvc-1"); !run || delay != 0 {
+		t.Fatalf("reserve(pvc-1) = %v, %v, want true, 0", run, delay)
+	}
+	if run, delay := b.reserve("openebs/pvc-2"); run || delay != 500*time.Millisecond {
+		t.Fatalf("reserve(pvc-2) = %v, %v, want false, 500ms", run, delay)
+	}
+	if run, delay := b.reserve("openebs/pvc-3"); run || delay != time.Second {
+		t.Fatalf("reserve(pvc-3) = %v, %v, want false, 1s", run, delay)
+	}
+	// a volume requeued before its turn keeps it.
+	now = now.Add(200 * time.Millisecond)
+	if run, delay := b.reserve("openebs/pvc-2"); run || delay != 300*time.Millisecond {
+		t.Fatalf("reserve(pvc-2) = %v, %v, want false, 300ms", run, delay)
+	}
+	now = now.Add(300 * time.Millisecond)
+	if run, _ := b.reserve("openebs/pvc-2"); !run {
+		t.Fatalf("reserve(pvc-2) on its turn = false, want true")
+	}
+
+	// the volumes are backfilled once, unless retried.
+	if run, delay := b.reserve("openebs/pvc-1"); run || delay != 0 {
+		t.Fatalf("reserve(pvc-1) again = %v, %v, want false, 0", run, delay)
+	}
+	b.retry("openebs/pvc-1")
+	now = now.Add(time.Second)
+	if run, _ := b.reserve("openebs/pvc-1"); !run {
+		t.Fatalf("reserve(pvc-1) after retry = false, want true")
+	}
+
+	if newBackfiller(0) != nil {
+		t.Errorf("newBackfiller(0) is not nil, want the backfills disabled")
+	}
+}
+
+func TestSyncVolBackfill(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         apis.VolStatus
+		disabled       bool
+		backfillErr    error
+		wantBackfilled []string
+		wantRetried    bool
+	}{
+		{
+			name:   "up to date volume is not backfilled",
+			status: apis.VolStatus{State: device.DeviceStatusReady, Capacity: "1048576", DiskUUID: "uuid-1"},
+		},
+		{
+			name:           "older volume without its partition found",
+			status:         apis.VolStatus{State: device.DeviceStatusReady},
+			wantBackfilled: []string{"pvc-1"},
+		},
+		{
+			name:           "failed backfill is retried",
+			status:         apis.VolStatus{State: device.DeviceStatusReady},
+			backfillErr:    errors.New("parted failed"),
+			wantBackfilled: []string{"pvc-1"},
+			wantRetried:    true,
+		},
+		{
+			name:     "backfills disabled",
+			status:   apis.VolStatus{State: device.DeviceStatusReady},
+			disabled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vol := &apis.DeviceVolume{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "openebs", Name: "pvc-1"},
+				Status:     tt.status,
+			}
+			manager := fake.NewDeviceManager()
+			manager.BackfillErr = tt.backfillErr
+			c := &VolController{
+				workqueue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+				recorder:  record.NewFakeRecorder(10),
+				devices:   manager,
+			}
+			defer c.workqueue.ShutDown()
+			if !tt.disabled {
+				c.backfill = newBackfiller(DefaultBackfillRate)
+			}
+
+			// nothing gets backfilled, so syncVol doesn't reach the api
+			// server for updating the volume.
+			if err := c.syncVol(vol); err != nil {
+				t.Fatalf("syncVol() error = %v", err)
+			}
+			if !reflect.DeepEqual(manager.Backfilled, tt.wantBackfilled) {
+				t.Errorf("backfilled %v, want %v", manager.Backfilled, tt.wantBackfilled)
+			}
+			// the attributes are applied all the same.
+			if !reflect.DeepEqual(manager.Applied, []string{"pvc-1"}) {
+				t.Errorf("applied %v, want [pvc-1]", manager.Applied)
+			}
+			if c.backfill != nil {
+				if retried := !c.backfill.attempted["openebs/pvc-1"] && tt.wantBackfilled != nil; retried != tt.wantRetried {
+					t.Errorf("retried = %v, want %v", retried, tt.wantRetried)
+				}
+			}
+		})
+	}
+}
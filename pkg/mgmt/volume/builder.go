@@ -17,6 +17,7 @@ limitations under the License.
 package volume
 
 import (
+	"github.com/openebs/device-localpv/pkg/device"
 	clientset "github.com/openebs/device-localpv/pkg/generated/clientset/internalclientset"
 	openebsScheme "github.com/openebs/device-localpv/pkg/generated/clientset/internalclientset/scheme"
 	informers "github.com/openebs/device-localpv/pkg/generated/informer/externalversions"
@@ -56,6 +57,25 @@ type VolController struct {
 	// recorder is an event recorder for recording Event resources to the
 	// Kubernetes API.
 	recorder record.EventRecorder
+
+	// maxCreateFailures is the number of consecutive failures after which
+	// the creation of a volume is not retried anymore. Zero retries it
+	// forever.
+	maxCreateFailures int
+
+	// devices operates on the partitions of the volumes.
+	devices device.DeviceManager
+
+	// resync enqueues all the volumes of the node on the request of an
+	// operator.
+	resync *resyncer
+
+	// backfill spreads the backfills of the status of the volumes created
+	// by the older releases, nil disables them.
+	backfill *backfiller
+	// stopCh is closed when the controller is stopped, aborting the
+	// relocations of the volumes in progress.
+	stopCh <-chan struct{}
 }
 
 // VolControllerBuilder is the builder object for controller.
@@ -102,6 +122,33 @@ func (cb *VolControllerBuilder) withWorkqueueRateLimiting() *VolControllerBuilde
 	return cb
 }
 
+// withMaxCreateFailures sets the number of consecutive failures after
+// which the creation of a volume is given up.
+func (cb *VolControllerBuilder) withMaxCreateFailures(limit int) *VolControllerBuilder {
+	cb.VolController.maxCreateFailures = limit
+	return cb
+}
+
+// withDeviceManager sets the manager of the partitions of the volumes.
+func (cb *VolControllerBuilder) withDeviceManager(devices device.DeviceManager) *VolControllerBuilder {
+	cb.VolController.devices = devices
+	return cb
+}
+
+// withResyncRate sets the number of volumes enqueued per second by a
+// resync of all the volumes of the node.
+func (cb *VolControllerBuilder) withResyncRate(rate float64) *VolControllerBuilder {
+	cb.VolController.resync = newResyncer(rate)
+	return cb
+}
+
+// withBackfillRate sets the number of volumes per second whose missing
+// status fields get backfilled, zero disables the backfills.
+func (cb *VolControllerBuilder) withBackfillRate(rate float64) *VolControllerBuilder {
+	cb.VolController.backfill = newBackfiller(rate)
+	return cb
+}
+
 // withRecorder adds recorder to controller object.
 func (cb *VolControllerBuilder) withRecorder(ks kubernetes.Interface) *VolControllerBuilder {
 	klog.Infof("Creating event broadcaster")
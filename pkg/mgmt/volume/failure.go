@@ -0,0 +1,87 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package volume
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// isCreationFailed checks whether the creation of a volume which failed
+// with err, after the given number of consecutive failures, should not be
+// retried anymore. The partition table errors are left to be retried, as
+// the creation succeeds once an operator repairs the table. Zero limit
+// retries the creation forever.
+func isCreationFailed(failures, limit int, err error) bool {
+	if err == nil || limit <= 0 {
+		return false
+	}
+	if _, ok := err.(*device.PartitionTableError); ok {
+		return false
+	}
+	return failures >= limit
+}
+
+// markCreationFailed sets the volume in the terminal Failed state with the
+// CreationFailed condition, recording the last error of its creation.
+func markCreationFailed(vol *apis.DeviceVolume, err error, failures int, now metav1.Time) {
+	msg := fmt.Sprintf("creation of the volume failed %d times in a row, last error: %v", failures, err)
+	vol.Status.State = device.DeviceStatusFailed
+	vol.Status.Error = &apis.VolumeError{
+		Code:    apis.Internal,
+		Message: msg,
+	}
+	if device.GetVolumeCondition(vol, apis.CreationFailed) == nil {
+		vol.Status.Conditions = append(vol.Status.Conditions, apis.VolumeCondition{
+			Type:               apis.CreationFailed,
+			Message:            msg,
+			LastTransitionTime: now,
+		})
+	}
+}
+
+// checkCreationFailure gives up on the volume whose creation failed with
+// err, if it failed maxCreateFailures times in a row. The failures are
+// counted by the rate limiter of the workqueue, so they start over when
+// the node agent restarts. It returns nil once the volume is marked as
+// failed, so that it is not requeued.
+func (c *VolController) checkCreationFailure(vol *apis.DeviceVolume, err error) error {
+	key, kerr := cache.MetaNamespaceKeyFunc(vol)
+	if kerr != nil {
+		return err
+	}
+	// the failure being handled is not requeued yet.
+	failures := c.workqueue.NumRequeues(key) + 1
+	if !isCreationFailed(failures, c.maxCreateFailures, err) {
+		return err
+	}
+
+	markCreationFailed(vol, err, failures, metav1.Now())
+	klog.Errorf("volume controller: giving up on volume %s: %s", vol.Name, vol.Status.Error.Message)
+	c.recorder.Event(vol, corev1.EventTypeWarning, string(apis.CreationFailed), vol.Status.Error.Message)
+	if uerr := device.UpdateVolume(vol); uerr != nil {
+		return fmt.Errorf("mark volume %s as failed: %v", vol.Name, uerr)
+	}
+	return nil
+}
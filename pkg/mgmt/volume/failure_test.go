@@ -0,0 +1,80 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package volume
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+func Test_isCreationFailed(t *testing.T) {
+	failure := errors.New("sgdisk failed")
+	tests := []struct {
+		name     string
+		failures int
+		limit    int
+		err      error
+		want     bool
+	}{
+		{name: "below the limit", failures: 3, limit: 5, err: failure, want: false},
+		{name: "at the limit", failures: 5, limit: 5, err: failure, want: true},
+		{name: "past the limit", failures: 6, limit: 5, err: failure, want: true},
+		{name: "no limit", failures: 100, limit: 0, err: failure, want: false},
+		{name: "success", failures: 5, limit: 5, err: nil, want: false},
+		{
+			name: "partition table error", failures: 5, limit: 5,
+			err:  &device.PartitionTableError{Disk: "/dev/sdb"},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCreationFailed(tt.failures, tt.limit, tt.err); got != tt.want {
+				t.Errorf("isCreationFailed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_markCreationFailed(t *testing.T) {
+	vol := &apis.DeviceVolume{Status: apis.VolStatus{State: device.DeviceStatusPending}}
+	now := metav1.Now()
+
+	markCreationFailed(vol, errors.New("sgdisk failed"), 5, now)
+	if vol.Status.State != device.DeviceStatusFailed {
+		t.Errorf("state = %s, want %s", vol.Status.State, device.DeviceStatusFailed)
+	}
+	if vol.Status.Error == nil || !strings.Contains(vol.Status.Error.Message, "sgdisk failed") {
+		t.Errorf("error = %+v, want the last error of the creation", vol.Status.Error)
+	}
+	cond := device.GetVolumeCondition(vol, apis.CreationFailed)
+	if cond == nil || cond.LastTransitionTime != now {
+		t.Fatalf("condition = %+v, want CreationFailed set at %v", cond, now)
+	}
+
+	// marking the volume again doesn't duplicate the condition.
+	markCreationFailed(vol, errors.New("sgdisk failed"), 6, metav1.Now())
+	if len(vol.Status.Conditions) != 1 {
+		t.Errorf("conditions = %+v, want a single CreationFailed", vol.Status.Conditions)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package volume
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"github.com/openebs/device-localpv/pkg/device"
+)
+
+// DefaultResyncRate is the default number of volumes enqueued per second
+// by a resync of all the volumes of the node.
+const DefaultResyncRate = 5
+
+// errResyncRunning is returned when a resync is requested while the
+// volumes of the previous one are still being enqueued.
+var errResyncRunning = errors.New("a resync is already running")
+
+// ResyncProgress is the progress of the last resync of all the volumes of
+// the node.
+type ResyncProgress struct {
+	// Running is true while the volumes are being enqueued.
+	Running    bool      `json:"running"`
+	StartTime  time.Time `json:"startTime"`
+	Total      int       `json:"total"`
+	Enqueued   int       `json:"enqueued"`
+	Reconciled int       `json:"reconciled"`
+	Remaining  int       `json:"remaining"`
+}
+
+// resyncer enqueues all the volumes of the node for reconciliation, one
+// every interval so that the disks are not flooded with the reconciles,
+// and tracks how many of them got reconciled since.
+type resyncer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	// list and enqueue are bound to the controller once it runs.
+	list     func() ([]string, error)
+	enqueue  func(key string)
+	stopCh   <-chan struct{}
+	progress ResyncProgress
+	// pending holds the keys of the volumes not reconciled yet.
+	pending map[string]bool
+}
+
+// activeResync is the resyncer of the running volume controller, served
+// by ResyncHandler.
+var (
+	activeResyncMtx sync.Mutex
+	activeResync    *resyncer
+)
+
+// newResyncer returns a resyncer enqueuing rate volumes per second, the
+// default rate if not positive.
+func newResyncer(rate float64) *resyncer {
+	if rate <= 0 {
+		rate = DefaultResyncRate
+	}
+	return &resyncer{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// bind makes the resyncer list and enqueue the volumes through the
+// controller, until stopCh is closed.
+func (r *resyncer) bind(list func() ([]string, error), enqueue func(key string), stopCh <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.list = list
+	r.enqueue = enqueue
+	r.stopCh = stopCh
+}
+
+// start enqueues all the volumes of the node in the background. It fails
+// if the volumes of the previous resync are still being enqueued.
+func (r *resyncer) start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.list == nil {
+		return errors.New("volume controller is not running")
+	}
+	if r.progress.Running {
+		return errResyncRunning
+	}
+	keys, err := r.list()
+	if err != nil {
+		return errors.Wrap(err, "failed to list the volumes")
+	}
+	r.pending = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		r.pending[key] = true
+	}
+	r.progress = ResyncProgress{
+		Running:   true,
+		StartTime: time.Now(),
+		Total:     len(keys),
+		Remaining: len(keys),
+	}
+	klog.Infof("resyncing %d volumes, one every %v", len(keys), r.interval)
+	go r.run(keys, r.enqueue, r.stopCh)
+	return nil
+}
+
+// run enqueues the keys one every interval.
+func (r *resyncer) run(keys []string, enqueue func(key string), stopCh <-chan struct{}) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for i, key := range keys {
+		if i > 0 {
+			select {
+			case <-ticker.C:
+			case <-stopCh:
+				r.finish()
+				return
+			}
+		}
+		enqueue(key)
+		r.mu.Lock()
+		r.progress.Enqueued++
+		r.mu.Unlock()
+	}
+	r.finish()
+	klog.Infof("enqueued the %d volumes of the resync", len(keys))
+}
+
+// finish marks the end of the enqueuing of the volumes.
+func (r *resyncer) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.progress.Running = false
+}
+
+// reconciled records a successful reconcile of the volume of key.
+func (r *resyncer) reconciled(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.pending[key] {
+		return
+	}
+	delete(r.pending, key)
+	r.progress.Reconciled++
+	r.progress.Remaining--
+}
+
+// getProgress returns the progress of the last resync.
+func (r *resyncer) getProgress() ResyncProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.progress
+}
+
+// listNodeVols returns the keys of the volumes owned by the node, sorted so
+// that a resync goes through them in a stable order.
+func (c *VolController) listNodeVols() ([]string, error) {
+	vols, err := c.VolLister.DeviceVolumes(device.DeviceNamespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, vol := range vols {
+		if vol.Spec.OwnerNodeID != device.NodeID {
+			continue
+		}
+		key, err := cache.MetaNamespaceKeyFunc(vol)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// ResyncHandler starts a resync of all the volumes of the node on POST and
+// serves the progress of the last one as json on GET.
+func ResyncHandler(w http.ResponseWriter, req *http.Request) {
+	activeResyncMtx.Lock()
+	r := activeResync
+	activeResyncMtx.Unlock()
+	if r == nil {
+		http.Error(w, "volume controller is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	code := http.StatusOK
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := r.start(); err != nil {
+			code = http.StatusInternalServerError
+			if err == errResyncRunning {
+				code = http.StatusConflict
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		code = http.StatusAccepted
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(r.getProgress()); err != nil {
+		klog.Errorf("Device LocalPV: could not encode resync progress: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package volume
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+	listers "github.com/openebs/device-localpv/pkg/generated/lister/device/v1alpha1"
+)
+
+func TestResync(t *testing.T) {
+	const rate = 50
+	keys := []string{"openebs/pvc-1", "openebs/pvc-2", "openebs/pvc-3", "openebs/pvc-4", "openebs/pvc-5"}
+
+	var mu sync.Mutex
+	var enqueued []string
+	var times []time.Time
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	r := newResyncer(rate)
+	r.bind(func() ([]string, error) { return keys, nil }, func(key string) {
+		mu.Lock()
+		defer mu.Unlock()
+		enqueued = append(enqueued, key)
+		times = append(times, time.Now())
+	}, stopCh)
+
+	if err := r.start(); err != nil {
+		t.Fatalf("start() unexpected error %v", err)
+	}
+	if err := r.start(); err != errResyncRunning {
+		t.Errorf("start() while running got %v, want %v", err, errResyncRunning)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for r.getProgress().Running {
+		if time.Now().After(deadline) {
+			t.Fatalf("resync did not finish, progress %+v", r.getProgress())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(enqueued, keys) {
+		t.Errorf("enqueued %v, want %v", enqueued, keys)
+	}
+	// the volumes are enqueued one every interval, the ticker may only
+	// deliver late.
+	interval := time.Second / rate
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < interval*9/10 {
+			t.Errorf("volume %d enqueued %v after the previous one, want at least %v", i, gap, interval)
+		}
+	}
+
+	r.reconciled("openebs/pvc-2")
+	r.reconciled("openebs/pvc-2")
+	r.reconciled("openebs/other")
+	got := r.getProgress()
+	got.StartTime = time.Time{}
+	want := ResyncProgress{Total: 5, Enqueued: 5, Reconciled: 1, Remaining: 4}
+	if got != want {
+		t.Errorf("progress = %+v, want %+v", got, want)
+	}
+}
+
+func TestListNodeVols(t *testing.T) {
+	nodeID, namespace := device.NodeID, device.DeviceNamespace
+	device.NodeID, device.DeviceNamespace = "node-1", "openebs"
+	defer func() { device.NodeID, device.DeviceNamespace = nodeID, namespace }()
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, vol := range []struct{ name, namespace, node string }{
+		{"pvc-b", "openebs", "node-1"},
+		{"pvc-a", "openebs", "node-1"},
+		{"pvc-c", "openebs", "node-2"},
+		{"pvc-d", "other", "node-1"},
+	} {
+		obj := &apis.DeviceVolume{ObjectMeta: metav1.ObjectMeta{Name: vol.name, Namespace: vol.namespace}}
+		obj.Spec.OwnerNodeID = vol.node
+		if err := indexer.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &VolController{VolLister: listers.NewDeviceVolumeLister(indexer)}
+	got, err := c.listNodeVols()
+	if err != nil {
+		t.Fatalf("listNodeVols() unexpected error %v", err)
+	}
+	want := []string{"openebs/pvc-a", "openebs/pvc-b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listNodeVols() = %v, want %v", got, want)
+	}
+}
+
+func TestResyncHandler(t *testing.T) {
+	defer func() { activeResync = nil }()
+
+	serve := func(method string) (int, ResyncProgress) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		ResyncHandler(rec, httptest.NewRequest(method, "/debug/resync", nil))
+		var progress ResyncProgress
+		if rec.Code == http.StatusOK || rec.Code == http.StatusAccepted {
+			if err := json.NewDecoder(rec.Body).Decode(&progress); err != nil {
+				t.Fatalf("invalid progress %q: %v", rec.Body.String(), err)
+			}
+		}
+		return rec.Code, progress
+	}
+
+	if code, _ := serve(http.MethodPost); code != http.StatusServiceUnavailable {
+		t.Errorf("POST without controller got %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	activeResync = newResyncer(1)
+	activeResync.bind(func() ([]string, error) { return []string{"openebs/pvc-1", "openebs/pvc-2"}, nil },
+		func(string) {}, stopCh)
+
+	code, progress := serve(http.MethodPost)
+	if code != http.StatusAccepted || progress.Total != 2 || !progress.Running {
+		t.Errorf("POST got %d %+v, want %d with 2 volumes running", code, progress, http.StatusAccepted)
+	}
+	if code, _ = serve(http.MethodPost); code != http.StatusConflict {
+		t.Errorf("POST while running got %d, want %d", code, http.StatusConflict)
+	}
+	if code, progress = serve(http.MethodGet); code != http.StatusOK || progress.Remaining != 2 {
+		t.Errorf("GET got %d %+v, want %d with 2 volumes remaining", code, progress, http.StatusOK)
+	}
+	if code, _ = serve(http.MethodDelete); code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE got %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"time"
 
+	"github.com/openebs/device-localpv/pkg/device"
 	clientset "github.com/openebs/device-localpv/pkg/generated/clientset/internalclientset"
 	informers "github.com/openebs/device-localpv/pkg/generated/informer/externalversions"
 	kubeinformers "k8s.io/client-go/informers"
@@ -37,8 +38,13 @@ var (
 	kubeconfig string
 )
 
-// Start starts the devicevolume controller.
-func Start(controllerMtx *sync.RWMutex, stopCh <-chan struct{}) error {
+// Start starts the devicevolume controller. The creation of a volume is
+// given up after maxCreateFailures consecutive failures, zero retries it
+// forever. A resync of all the volumes enqueues resyncRate of them per
+// second, and the status of backfillRate volumes created by the older
+// releases is backfilled per second.
+func Start(controllerMtx *sync.RWMutex, threadiness, maxCreateFailures int, resyncRate, backfillRate float64,
+	stopCh <-chan struct{}) error {
 	// Get in cluster config
 	cfg, err := getClusterConfig(kubeconfig)
 	if err != nil {
@@ -58,7 +64,10 @@ func Start(controllerMtx *sync.RWMutex, stopCh <-chan struct{}) error {
 	}
 
 	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, time.Second*30)
-	VolInformerFactory := informers.NewSharedInformerFactory(openebsClient, time.Second*30)
+	// only the volumes of this install are watched, the other installs
+	// of the cluster use their own namespaces.
+	VolInformerFactory := informers.NewSharedInformerFactoryWithOptions(openebsClient,
+		time.Second*30, informers.WithNamespace(device.DeviceNamespace))
 	// Build() fn of all controllers calls AddToScheme to adds all types of this
 	// clientset into the given scheme.
 	// If multiple controllers happen to call this AddToScheme same time,
@@ -73,6 +82,10 @@ func Start(controllerMtx *sync.RWMutex, stopCh <-chan struct{}) error {
 		withVolLister(VolInformerFactory).
 		withRecorder(kubeClient).
 		withEventHandler(VolInformerFactory).
+		withMaxCreateFailures(maxCreateFailures).
+		withDeviceManager(device.NewDeviceManager()).
+		withResyncRate(resyncRate).
+		withBackfillRate(backfillRate).
 		withWorkqueueRateLimiting().Build()
 
 	// blocking call, can't use defer to release the lock
@@ -82,15 +95,20 @@ func Start(controllerMtx *sync.RWMutex, stopCh <-chan struct{}) error {
 		return errors.Wrapf(err, "error building controller instance")
 	}
 
+	activeResyncMtx.Lock()
+	activeResync = controller.resync
+	activeResyncMtx.Unlock()
+
 	go kubeInformerFactory.Start(stopCh)
 	go VolInformerFactory.Start(stopCh)
 
 	// Threadiness defines the number of workers to be launched in Run function
-	// The no.of threads is set to 1 here as using `parted` command for creation/deletion
-	// of partitions from multiple threads can lead to race condition and eventually some
-	// partitions not getting cleaned up from the disk.
+	// Using `parted` command for creation/deletion of partitions on a disk from
+	// multiple threads can lead to race condition and eventually some partitions
+	// not getting cleaned up from the disk, so the workers lock the disks they
+	// change.
 	// Ref: https://github.com/openebs/device-localpv/issues/21
-	return controller.Run(1, stopCh)
+	return controller.Run(threadiness, stopCh)
 }
 
 // GetClusterConfig return the config for k8s.
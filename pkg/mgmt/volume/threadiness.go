@@ -0,0 +1,105 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package volume
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/openebs/lib-csi/pkg/common/errors"
+	"k8s.io/klog"
+)
+
+// ThreadinessAuto derives the number of volume workers from the CPUs
+// available to the node agent.
+const ThreadinessAuto = "auto"
+
+// cgroupRoot is where the cgroup filesystem is mounted.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// ResolveThreadiness returns the number of workers of the volume controller
+// for the given setting, i.e. "auto" or a number, clamped to the number of
+// disks since the partitions of a disk are changed by a worker at a time.
+func ResolveThreadiness(value string, disks int) (int, error) {
+	return resolveThreadiness(value, disks, runtime.GOMAXPROCS(0), cgroupRoot)
+}
+
+func resolveThreadiness(value string, disks, maxProcs int, cgroupRoot string) (int, error) {
+	var threadiness int
+	if value == ThreadinessAuto {
+		threadiness = maxProcs
+		if limit, ok := cgroupCPULimit(cgroupRoot); ok && limit < threadiness {
+			threadiness = limit
+		}
+	} else {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return 0, errors.Errorf("invalid threadiness %q, must be %s or a positive number",
+				value, ThreadinessAuto)
+		}
+		threadiness = n
+	}
+
+	if threadiness > disks {
+		threadiness = disks
+	}
+	if threadiness < 1 {
+		threadiness = 1
+	}
+	klog.Infof("volume controller: using %d workers for threadiness %s and %d disks",
+		threadiness, value, disks)
+	return threadiness, nil
+}
+
+// cgroupCPULimit returns the number of CPUs the process is limited to by
+// the cgroup CPU quota, rounded up. It returns false if there is no quota.
+func cgroupCPULimit(root string) (int, bool) {
+	// cgroup v2 exposes "<quota> <period>" or "max <period>"
+	if out, err := ioutil.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(out))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+		return cpuLimit(fields[0], fields[1])
+	}
+
+	// cgroup v1 exposes the quota, -1 if unlimited, and the period apart
+	quota, err := ioutil.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, false
+	}
+	period, err := ioutil.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, false
+	}
+	return cpuLimit(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func cpuLimit(quota, period string) (int, bool) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return int((q + p - 1) / p), true
+}
@@ -0,0 +1,96 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package volume
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveThreadiness(t *testing.T) {
+	writeFiles := func(t *testing.T, files map[string]string) string {
+		root, err := ioutil.TempDir("", "cgroup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			path := filepath.Join(root, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return root
+	}
+
+	tests := map[string]struct {
+		value     string
+		disks     int
+		maxProcs  int
+		cgroup    map[string]string
+		expected  int
+		expectErr bool
+	}{
+		"fixed":                 {value: "4", disks: 8, maxProcs: 16, expected: 4},
+		"fixed clamped":         {value: "4", disks: 2, maxProcs: 16, expected: 2},
+		"no disks":              {value: "4", disks: 0, maxProcs: 16, expected: 1},
+		"invalid":               {value: "many", disks: 8, maxProcs: 16, expectErr: true},
+		"zero":                  {value: "0", disks: 8, maxProcs: 16, expectErr: true},
+		"auto without quota":    {value: "auto", disks: 32, maxProcs: 16, expected: 16},
+		"auto clamped to disks": {value: "auto", disks: 3, maxProcs: 16, expected: 3},
+		"auto cgroup v2 quota": {
+			value: "auto", disks: 32, maxProcs: 16, expected: 3,
+			cgroup: map[string]string{"cpu.max": "250000 100000\n"},
+		},
+		"auto cgroup v2 unlimited": {
+			value: "auto", disks: 32, maxProcs: 16, expected: 16,
+			cgroup: map[string]string{"cpu.max": "max 100000\n"},
+		},
+		"auto cgroup v1 quota": {
+			value: "auto", disks: 32, maxProcs: 16, expected: 2,
+			cgroup: map[string]string{"cpu/cpu.cfs_quota_us": "200000\n", "cpu/cpu.cfs_period_us": "100000\n"},
+		},
+		"auto cgroup v1 unlimited": {
+			value: "auto", disks: 32, maxProcs: 16, expected: 16,
+			cgroup: map[string]string{"cpu/cpu.cfs_quota_us": "-1\n", "cpu/cpu.cfs_period_us": "100000\n"},
+		},
+		"auto quota above procs": {
+			value: "auto", disks: 32, maxProcs: 2, expected: 2,
+			cgroup: map[string]string{"cpu.max": "800000 100000\n"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := writeFiles(t, test.cgroup)
+			defer os.RemoveAll(root)
+
+			threadiness, err := resolveThreadiness(test.value, test.disks, test.maxProcs, root)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, threadiness)
+		})
+	}
+}
@@ -17,12 +17,15 @@ limitations under the License.
 package volume
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
 	"github.com/openebs/device-localpv/pkg/device"
+	corev1 "k8s.io/api/core/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
@@ -54,8 +57,7 @@ func (c *VolController) syncHandler(key string) error {
 		return err
 	}
 	VolCopy := Vol.DeepCopy()
-	err = c.syncVol(VolCopy)
-	return err
+	return c.syncVol(VolCopy)
 }
 
 // enqueueVol takes a DeviceVolume resource and converts it into a namespace/name
@@ -78,22 +80,98 @@ func (c *VolController) syncVol(vol *apis.DeviceVolume) error {
 	var err error
 	// Device Volume should be deleted. Check if deletion timestamp is set
 	if c.isDeletionCandidate(vol) {
-		err = device.DestroyVolume(vol)
+		err = c.devices.DestroyVolume(vol)
 		if err == nil {
 			err = device.RemoveVolFinalizer(vol)
 		}
+		c.reportPartitionTableError(vol, err)
 		return err
 	}
+	// the creation of the volume is not retried anymore, till the volume
+	// gets deleted.
+	if vol.Status.State == device.DeviceStatusFailed {
+		return nil
+	}
+	// the partition of a standby volume is held till it gets activated.
+	if vol.Status.State == device.DeviceStatusReserved {
+		if device.IsActivationPending(vol) {
+			return device.MarkVolumeActivated(vol)
+		}
+		return nil
+	}
 	// if finalizer is not set then it means we are creating
 	// the volume. And if it is set then volume has already been
 	// created and this event is for property change only.
 	if vol.Status.State != device.DeviceStatusReady {
-		err = device.CreateVolume(vol)
+		err = c.devices.CreateVolume(vol)
 		if err == nil {
+			device.RemoveVolumeCondition(vol, apis.PartitionTableInvalid)
+			device.SetAppliedAttributes(vol)
+			// the partition is formatted by the first mount of the
+			// volume, which may never come.
+			vol.Status.Unformatted = true
 			err = device.UpdateVolInfo(vol)
 		}
+		c.reportPartitionTableError(vol, err)
+		if err != nil {
+			return c.checkCreationFailure(vol, err)
+		}
+		return nil
+	}
+	// the partition is moved to another disk a step per reconcile, the
+	// update of the volume recording a step triggers the next one.
+	if device.IsRelocationPending(vol) {
+		ctx, cancel := c.stopContext()
+		defer cancel()
+		return c.devices.RelocateVolume(ctx, vol)
+	}
+	// the volumes created by the older releases miss some of the status
+	// fields, the update recording them triggers the next reconcile.
+	if device.NeedsStatusBackfill(vol) {
+		if updated, err := c.backfillVol(vol); updated || err != nil {
+			return err
+		}
 	}
-	return err
+	// the mutable attributes modified after the creation of the volume
+	return c.devices.ApplyVolumeAttributes(vol)
+}
+
+// reportPartitionTableError flags the volume with the PartitionTableInvalid
+// condition and a warning event if its partition could not be created or
+// deleted because the partition table of the disk failed the verification,
+// so that an operator can repair the table.
+func (c *VolController) reportPartitionTableError(vol *apis.DeviceVolume, err error) {
+	ptErr, ok := err.(*device.PartitionTableError)
+	if !ok {
+		return
+	}
+	c.recorder.Event(vol, corev1.EventTypeWarning, string(apis.PartitionTableInvalid), ptErr.Error())
+
+	if device.GetVolumeCondition(vol, apis.PartitionTableInvalid) != nil {
+		return
+	}
+	vol.Status.Conditions = append(vol.Status.Conditions, apis.VolumeCondition{
+		Type:               apis.PartitionTableInvalid,
+		Message:            ptErr.Error(),
+		LastTransitionTime: metav1.Now(),
+	})
+	if err = device.UpdateVolume(vol); err != nil {
+		klog.Errorf("volume controller: update conditions of volume %s: %v", vol.Name, err)
+	}
+}
+
+// reportSlowReconcile records a warning event on the volume whose
+// reconcile took longer than the slow reconcile threshold.
+func (c *VolController) reportSlowReconcile(slow *device.SlowReconcile) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(slow.Key)
+	if err != nil {
+		return
+	}
+	vol, err := c.VolLister.DeviceVolumes(namespace).Get(name)
+	if err != nil {
+		return
+	}
+	c.recorder.Event(vol, corev1.EventTypeWarning, device.SlowReconcileReason, slow.String())
 }
 
 // addVol is the add event handler for DeviceVolume
@@ -127,6 +205,24 @@ func (c *VolController) updateVol(oldObj, newObj interface{}) {
 	if c.isDeletionCandidate(newVol) {
 		klog.Infof("Got update event for deleted Vol %s", newVol.Name)
 		c.enqueueVol(newVol)
+		return
+	}
+
+	if newVol.Status.State == device.DeviceStatusReady && device.HasPendingAttributes(newVol) {
+		klog.Infof("Got update event for modifying Vol %s", newVol.Name)
+		c.enqueueVol(newVol)
+		return
+	}
+
+	if newVol.Status.State == device.DeviceStatusReady && device.IsRelocationPending(newVol) {
+		klog.Infof("Got update event for relocating Vol %s", newVol.Name)
+		c.enqueueVol(newVol)
+		return
+	}
+
+	if device.IsActivationPending(newVol) {
+		klog.Infof("Got update event for activating Vol %s", newVol.Name)
+		c.enqueueVol(newVol)
 	}
 }
 
@@ -154,6 +250,21 @@ func (c *VolController) deleteVol(obj interface{}) {
 	c.enqueueVol(Vol)
 }
 
+// stopContext returns a context done once the controller is stopped, for
+// the device operations outlasting a reconcile, like the copy of the data
+// of a relocated volume.
+func (c *VolController) stopContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-c.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 // Run will set up the event handlers for types we are interested in, as well
 // as syncing informer caches and starting workers. It will block until stopCh
 // is closed, at which point it will shutdown the workqueue and wait for
@@ -170,6 +281,10 @@ func (c *VolController) Run(threadiness int, stopCh <-chan struct{}) error {
 	if ok := cache.WaitForCacheSync(stopCh, c.VolSynced); !ok {
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
+	c.stopCh = stopCh
+	if c.resync != nil {
+		c.resync.bind(c.listNodeVols, func(key string) { c.workqueue.Add(key) }, stopCh)
+	}
 	klog.Info("Starting Vol workers")
 	// Launch worker to process Vol resources
 	// Threadiness will decide the number of workers you want to launch to process work items from queue
@@ -227,7 +342,13 @@ func (c *VolController) processNextWorkItem() bool {
 		}
 		// Run the syncHandler, passing it the namespace/name string of the
 		// Vol resource to be synced.
-		if err := c.syncHandler(key); err != nil {
+		start := time.Now()
+		err := c.syncHandler(key)
+		device.RecordReconcile("devicevolume", key, start, err)
+		if slow := device.ObserveReconcile("devicevolume", key, start); slow != nil {
+			c.reportSlowReconcile(slow)
+		}
+		if err != nil {
 			// Put the item back on the workqueue to handle any transient errors.
 			c.workqueue.AddRateLimited(key)
 			return fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error())
@@ -235,6 +356,9 @@ func (c *VolController) processNextWorkItem() bool {
 		// Finally, if no error occurs we Forget this item so it does not
 		// get queued again until another change happens.
 		c.workqueue.Forget(obj)
+		if c.resync != nil {
+			c.resync.reconciled(key)
+		}
 		klog.Infof("Successfully synced '%s'", key)
 		return nil
 	}(obj)
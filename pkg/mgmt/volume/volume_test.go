@@ -0,0 +1,118 @@
+/*
+ Copyright © 2021 The OpenEBS Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package volume
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	apis "github.com/openebs/device-localpv/pkg/apis/openebs.io/device/v1alpha1"
+	"github.com/openebs/device-localpv/pkg/device"
+	"github.com/openebs/device-localpv/pkg/device/fake"
+)
+
+func TestSyncVol(t *testing.T) {
+	failure := errors.New("sgdisk failed")
+	now := metav1.Now()
+	tests := []struct {
+		name          string
+		state         string
+		deleted       bool
+		createErr     error
+		destroyErr    error
+		wantErr       bool
+		wantCreated   []string
+		wantDestroyed []string
+		wantApplied   []string
+		relocateTo    string
+		wantRelocated []string
+	}{
+		{
+			name: "deletion failure keeps the finalizer", state: device.DeviceStatusReady, deleted: true,
+			destroyErr: failure, wantErr: true, wantDestroyed: []string{"pvc-1"},
+		},
+		{
+			name: "creation failure is retried", state: device.DeviceStatusPending,
+			createErr: failure, wantErr: true, wantCreated: []string{"pvc-1"},
+		},
+		{
+			name: "failed volume is left alone", state: device.DeviceStatusFailed,
+		},
+		{
+			name: "standby volume is held", state: device.DeviceStatusReserved,
+		},
+		{
+			name: "ready volume gets its attributes", state: device.DeviceStatusReady,
+			wantApplied: []string{"pvc-1"},
+		},
+		{
+			name: "relocation is run before the attributes", state: device.DeviceStatusReady,
+			relocateTo: "sdc", wantRelocated: []string{"pvc-1"},
+		},
+		{
+			name: "relocation of a volume not created yet waits", state: device.DeviceStatusReserved,
+			relocateTo: "sdc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vol := &apis.DeviceVolume{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "openebs", Name: "pvc-1"},
+				Spec:       apis.VolumeInfo{Standby: device.StandbyEnabled},
+				Status:     apis.VolStatus{State: tt.state},
+			}
+			if tt.deleted {
+				vol.DeletionTimestamp = &now
+			}
+			if tt.relocateTo != "" {
+				vol.Annotations = map[string]string{device.RelocateToKey: tt.relocateTo}
+			}
+			manager := fake.NewDeviceManager()
+			manager.CreateErr, manager.DestroyErr = tt.createErr, tt.destroyErr
+			c := &VolController{
+				workqueue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+				recorder:  record.NewFakeRecorder(10),
+				devices:   manager,
+			}
+			defer c.workqueue.ShutDown()
+
+			// none of the cases reach the api server for updating the
+			// volume.
+			err := c.syncVol(vol)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("syncVol() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(manager.Created, tt.wantCreated) {
+				t.Errorf("created %v, want %v", manager.Created, tt.wantCreated)
+			}
+			if !reflect.DeepEqual(manager.Destroyed, tt.wantDestroyed) {
+				t.Errorf("destroyed %v, want %v", manager.Destroyed, tt.wantDestroyed)
+			}
+			if !reflect.DeepEqual(manager.Applied, tt.wantApplied) {
+				t.Errorf("applied %v, want %v", manager.Applied, tt.wantApplied)
+			}
+			if !reflect.DeepEqual(manager.Relocated, tt.wantRelocated) {
+				t.Errorf("relocated %v, want %v", manager.Relocated, tt.wantRelocated)
+			}
+		})
+	}
+}